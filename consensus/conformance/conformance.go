@@ -0,0 +1,145 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package conformance checks that a consensus plugin behaves as the validating
+peer expects. A plugin author calls Run from an ordinary Go test:
+
+	func TestConformance(t *testing.T) {
+		conformance.Run(t, New, conformance.Options{})
+	}
+
+Run starts an in-memory network of replicas, each running a fresh instance of
+the plugin, submits transactions to them and verifies that every replica
+commits every transaction exactly once and in the same order.
+*/
+package conformance
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Options tune a conformance run, the zero value selects the defaults
+type Options struct {
+	Replicas int           // number of validating replicas, defaults to 4
+	Timeout  time.Duration // time allowed for each check to converge, defaults to 10s
+}
+
+// Run executes the conformance checks against the plugin built by factory.
+// The factory is invoked once per replica and must return a new instance on
+// every call, singleton accessors such as the ones used by the peer are not
+// suitable.
+func Run(t *testing.T, factory consensus.PluginFactory, opts Options) {
+	if opts.Replicas == 0 {
+		opts.Replicas = 4
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	net := NewNetwork(opts.Replicas, factory)
+	defer net.Stop()
+
+	// A transaction submitted to one replica is committed by all of them
+	tx := newTransaction(0)
+	if err := net.Submit(0, tx); err != nil {
+		t.Fatalf("Could not submit transaction: %s", err)
+	}
+	if err := waitCommitted(net, 1, opts.Timeout); err != nil {
+		t.Fatalf("Single transaction: %s", err)
+	}
+
+	// Transactions submitted to different replicas are committed in the same order everywhere
+	for i := 1; i <= 2*opts.Replicas; i++ {
+		if err := net.Submit(i%opts.Replicas, newTransaction(i)); err != nil {
+			t.Fatalf("Could not submit transaction: %s", err)
+		}
+	}
+	total := 2*opts.Replicas + 1
+	if err := waitCommitted(net, total, opts.Timeout); err != nil {
+		t.Fatalf("Concurrent transactions: %s", err)
+	}
+	if err := checkAgreement(net); err != nil {
+		t.Fatalf("Concurrent transactions: %s", err)
+	}
+
+	// Nothing is committed twice, even after the network had time to settle
+	time.Sleep(opts.Timeout / 10)
+	for _, r := range net.Replicas {
+		seen := make(map[string]bool)
+		for _, tx := range r.Committed() {
+			if seen[tx.Uuid] {
+				t.Errorf("Replica %d committed transaction %s more than once", r.ID, tx.Uuid)
+			}
+			seen[tx.Uuid] = true
+		}
+		if len(seen) != total {
+			t.Errorf("Replica %d committed %d distinct transactions, expected %d", r.ID, len(seen), total)
+		}
+	}
+}
+
+func newTransaction(n int) *pb.Transaction {
+	return &pb.Transaction{
+		Type:      pb.Transaction_CHAINCODE_INVOKE,
+		Uuid:      fmt.Sprintf("conformance-%d-%s", n, util.GenerateUUID()),
+		Timestamp: util.CreateUtcTimestamp(),
+	}
+}
+
+// waitCommitted polls until every replica committed at least count transactions
+func waitCommitted(net *Network, count int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		var behind *Replica
+		for _, r := range net.Replicas {
+			if len(r.Committed()) < count {
+				behind = r
+				break
+			}
+		}
+		if behind == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("replica %d committed %d transactions after %s, expected %d", behind.ID, len(behind.Committed()), timeout, count)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// checkAgreement verifies all replicas committed the same transactions in the same order
+func checkAgreement(net *Network) error {
+	reference := net.Replicas[0].Committed()
+	for _, r := range net.Replicas[1:] {
+		txs := r.Committed()
+		if len(txs) != len(reference) {
+			return fmt.Errorf("replica %d committed %d transactions, replica 0 committed %d", r.ID, len(txs), len(reference))
+		}
+		for i := range txs {
+			if txs[i].Uuid != reference[i].Uuid {
+				return fmt.Errorf("replica %d committed %s at position %d, replica 0 committed %s", r.ID, txs[i].Uuid, i, reference[i].Uuid)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,508 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Network is an in-memory validating network, each replica runs its own
+// instance of a consensus plugin on top of a mock consensus.Stack
+type Network struct {
+	Replicas []*Replica
+}
+
+// Replica is a single validator of the Network. It implements
+// consensus.Stack with an in-memory ledger, so plugins can be exercised
+// without a peer, a database or chaincode containers.
+type Replica struct {
+	ID        uint64
+	Consenter consensus.Consenter
+
+	net      *Network
+	handle   *pb.PeerID
+	inbox    *workQueue // serializes calls to RecvMsg
+	executor *workQueue // serializes Execute/Commit/Rollback/UpdateState
+
+	lock        sync.Mutex
+	blocks      []*pb.Block
+	executed    []*pb.Transaction
+	batches     map[interface{}][]*pb.Transaction
+	persisted   map[string][]byte
+	stateValid  bool
+	stateHeight uint64
+}
+
+// NewNetwork creates a network of n replicas named vp0 to vp(n-1) and
+// starts one plugin instance per replica. The factory must construct a new
+// plugin instance on every invocation.
+func NewNetwork(n int, factory consensus.PluginFactory) *Network {
+	net := &Network{}
+	for i := 0; i < n; i++ {
+		r := &Replica{
+			ID:         uint64(i),
+			net:        net,
+			handle:     &pb.PeerID{Name: fmt.Sprintf("vp%d", i)},
+			inbox:      newWorkQueue(),
+			executor:   newWorkQueue(),
+			batches:    make(map[interface{}][]*pb.Transaction),
+			persisted:  make(map[string][]byte),
+			stateValid: true,
+		}
+		r.blocks = []*pb.Block{r.newBlock(nil, nil)} // genesis
+		net.Replicas = append(net.Replicas, r)
+	}
+	for _, r := range net.Replicas {
+		r.Consenter = factory(r)
+	}
+	return net
+}
+
+// Submit hands a transaction to a replica the way a client facing peer does
+func (net *Network) Submit(replica int, tx *pb.Transaction) error {
+	payload, err := proto.Marshal(tx)
+	if err != nil {
+		return err
+	}
+	r := net.Replicas[replica]
+	msg := &pb.Message{Type: pb.Message_CHAIN_TRANSACTION, Payload: payload, Timestamp: util.CreateUtcTimestamp()}
+	r.inbox.push(func() {
+		r.Consenter.RecvMsg(msg, r.handle)
+	})
+	return nil
+}
+
+// Stop halts all plugin instances and the message delivery between them
+func (net *Network) Stop() {
+	for _, r := range net.Replicas {
+		if closer, ok := r.Consenter.(interface {
+			Close()
+		}); ok {
+			closer.Close()
+		}
+		r.inbox.stop()
+		r.executor.stop()
+	}
+}
+
+func (net *Network) replicaByHandle(handle *pb.PeerID) (*Replica, error) {
+	for _, r := range net.Replicas {
+		if r.handle.Name == handle.Name {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown replica %s", handle.Name)
+}
+
+// Committed returns the transactions committed by the replica, in order
+func (r *Replica) Committed() []*pb.Transaction {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var txs []*pb.Transaction
+	for _, block := range r.blocks {
+		txs = append(txs, block.Transactions...)
+	}
+	return txs
+}
+
+// StateValid reports whether the plugin currently considers the state valid
+func (r *Replica) StateValid() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.stateValid
+}
+
+func (r *Replica) deliver(msg *pb.Message, sender *pb.PeerID) {
+	r.inbox.push(func() {
+		r.Consenter.RecvMsg(msg, sender)
+	})
+}
+
+// newBlock must be called with the lock held, or before the replica is shared
+func (r *Replica) newBlock(txs []*pb.Transaction, metadata []byte) *pb.Block {
+	block := pb.NewBlock(txs, metadata)
+	if len(r.blocks) > 0 {
+		block.PreviousBlockHash, _ = r.blocks[len(r.blocks)-1].GetHash()
+	}
+	hash := append([]byte(nil), block.PreviousBlockHash...)
+	for _, tx := range txs {
+		hash = util.ComputeCryptoHash(append(hash, []byte(tx.Uuid)...))
+	}
+	block.StateHash = hash
+	return block
+}
+
+func (r *Replica) info() *pb.BlockchainInfo {
+	head := r.blocks[len(r.blocks)-1]
+	hash, _ := head.GetHash()
+	return &pb.BlockchainInfo{
+		Height:            uint64(len(r.blocks)),
+		CurrentBlockHash:  hash,
+		PreviousBlockHash: head.PreviousBlockHash,
+	}
+}
+
+// consensus.NetworkStack
+
+// GetNetworkInfo returns the endpoints of this replica and of all replicas of the network
+func (r *Replica) GetNetworkInfo() (self *pb.PeerEndpoint, network []*pb.PeerEndpoint, err error) {
+	self = &pb.PeerEndpoint{ID: r.handle, Type: pb.PeerEndpoint_VALIDATOR}
+	for _, o := range r.net.Replicas {
+		network = append(network, &pb.PeerEndpoint{ID: o.handle, Type: pb.PeerEndpoint_VALIDATOR})
+	}
+	return
+}
+
+// GetNetworkHandles returns the handles of this replica and of all replicas of the network
+func (r *Replica) GetNetworkHandles() (self *pb.PeerID, network []*pb.PeerID, err error) {
+	self = r.handle
+	for _, o := range r.net.Replicas {
+		network = append(network, o.handle)
+	}
+	return
+}
+
+// Broadcast delivers the message to all other replicas
+func (r *Replica) Broadcast(msg *pb.Message, peerType pb.PeerEndpoint_Type) error {
+	if peerType == pb.PeerEndpoint_NON_VALIDATOR {
+		return nil
+	}
+	for _, o := range r.net.Replicas {
+		if o != r {
+			o.deliver(msg, r.handle)
+		}
+	}
+	return nil
+}
+
+// Unicast delivers the message to a single replica
+func (r *Replica) Unicast(msg *pb.Message, receiverHandle *pb.PeerID) error {
+	o, err := r.net.replicaByHandle(receiverHandle)
+	if err != nil {
+		return err
+	}
+	o.deliver(msg, r.handle)
+	return nil
+}
+
+// consensus.SecurityUtils
+
+// Sign returns the message itself as its signature
+func (r *Replica) Sign(msg []byte) ([]byte, error) {
+	return msg, nil
+}
+
+// Verify accepts every signature
+func (r *Replica) Verify(peerID *pb.PeerID, signature []byte, message []byte) error {
+	return nil
+}
+
+// consensus.Executor
+
+// Start is a no-op, the executor is started with the network
+func (r *Replica) Start() {}
+
+// Halt is a no-op, the executor is stopped with the network
+func (r *Replica) Halt() {}
+
+// Execute queues the transactions for the next commit
+func (r *Replica) Execute(tag interface{}, txs []*pb.Transaction) {
+	r.executor.push(func() {
+		r.lock.Lock()
+		r.executed = append(r.executed, txs...)
+		r.lock.Unlock()
+		r.Consenter.Executed(tag)
+	})
+}
+
+// Commit appends a block with all executed transactions
+func (r *Replica) Commit(tag interface{}, metadata []byte) {
+	r.executor.push(func() {
+		r.lock.Lock()
+		r.blocks = append(r.blocks, r.newBlock(r.executed, metadata))
+		r.executed = nil
+		info := r.info()
+		r.lock.Unlock()
+		r.Consenter.Committed(tag, info)
+	})
+}
+
+// Rollback discards all executed but uncommitted transactions
+func (r *Replica) Rollback(tag interface{}) {
+	r.executor.push(func() {
+		r.lock.Lock()
+		r.executed = nil
+		r.lock.Unlock()
+		r.Consenter.RolledBack(tag)
+	})
+}
+
+// UpdateState copies the blockchain of the first peer which has reached target
+func (r *Replica) UpdateState(tag interface{}, target *pb.BlockchainInfo, peers []*pb.PeerID) {
+	r.executor.push(func() {
+		for _, peer := range peers {
+			o, err := r.net.replicaByHandle(peer)
+			if err != nil || o == r {
+				continue
+			}
+			o.lock.Lock()
+			var blocks []*pb.Block
+			if target.Height <= uint64(len(o.blocks)) {
+				blocks = append(blocks, o.blocks[:target.Height]...)
+			}
+			o.lock.Unlock()
+			if len(blocks) == 0 {
+				continue
+			}
+
+			r.lock.Lock()
+			r.blocks = blocks
+			r.executed = nil
+			info := r.info()
+			r.lock.Unlock()
+			r.Consenter.StateUpdated(tag, info)
+			return
+		}
+		r.Consenter.StateUpdated(tag, nil)
+	})
+}
+
+// consensus.LegacyExecutor
+
+// BeginTxBatch starts a batch of synchronous executions
+func (r *Replica) BeginTxBatch(id interface{}) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.batches[id]; ok {
+		return fmt.Errorf("Batch %v already in progress", id)
+	}
+	r.batches[id] = []*pb.Transaction{}
+	return nil
+}
+
+// ExecTxs adds the transactions to the batch
+func (r *Replica) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	batch, ok := r.batches[id]
+	if !ok {
+		return nil, fmt.Errorf("Batch %v not started", id)
+	}
+	r.batches[id] = append(batch, txs...)
+	return r.newBlock(r.batches[id], nil).StateHash, nil
+}
+
+// CommitTxBatch appends a block with the transactions of the batch
+func (r *Replica) CommitTxBatch(id interface{}, metadata []byte) (*pb.Block, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	batch, ok := r.batches[id]
+	if !ok {
+		return nil, fmt.Errorf("Batch %v not started", id)
+	}
+	delete(r.batches, id)
+	block := r.newBlock(batch, metadata)
+	r.blocks = append(r.blocks, block)
+	return block, nil
+}
+
+// RollbackTxBatch discards the batch
+func (r *Replica) RollbackTxBatch(id interface{}) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.batches, id)
+	return nil
+}
+
+// PreviewCommitTxBatch returns the block the batch would be committed as
+func (r *Replica) PreviewCommitTxBatch(id interface{}, metadata []byte) ([]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	batch, ok := r.batches[id]
+	if !ok {
+		return nil, fmt.Errorf("Batch %v not started", id)
+	}
+	return r.newBlock(batch, metadata).Bytes()
+}
+
+// consensus.LedgerManager
+
+// InvalidateState records that the plugin considers the state out of date
+func (r *Replica) InvalidateState() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stateValid = false
+}
+
+// ValidateState records that the plugin considers the state up to date
+func (r *Replica) ValidateState() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stateValid = true
+}
+
+// consensus.ReadOnlyLedger
+
+// GetBlock returns the block with the given number
+func (r *Replica) GetBlock(id uint64) (*pb.Block, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if id >= uint64(len(r.blocks)) {
+		return nil, fmt.Errorf("Block %d does not exist", id)
+	}
+	return r.blocks[id], nil
+}
+
+// GetBlockchainSize returns the number of blocks, including genesis
+func (r *Replica) GetBlockchainSize() uint64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return uint64(len(r.blocks))
+}
+
+// GetBlockchainInfo returns the height and head hashes of the blockchain
+func (r *Replica) GetBlockchainInfo() *pb.BlockchainInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.info()
+}
+
+// GetBlockchainInfoBlob returns the marshaled GetBlockchainInfo
+func (r *Replica) GetBlockchainInfoBlob() []byte {
+	raw, _ := proto.Marshal(r.GetBlockchainInfo())
+	return raw
+}
+
+// GetBlockHeadMetadata returns the consensus metadata of the last block
+func (r *Replica) GetBlockHeadMetadata() ([]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.blocks[len(r.blocks)-1].ConsensusMetadata, nil
+}
+
+// consensus.StatePersistor
+
+// StoreState persists a value in memory
+func (r *Replica) StoreState(key string, value []byte) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.persisted[key] = value
+	return nil
+}
+
+// ReadState returns a persisted value
+func (r *Replica) ReadState(key string) ([]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	value, ok := r.persisted[key]
+	if !ok {
+		return nil, fmt.Errorf("Key %s not found", key)
+	}
+	return value, nil
+}
+
+// ReadStateSet returns all persisted values whose key starts with prefix
+func (r *Replica) ReadStateSet(prefix string) (map[string][]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	set := make(map[string][]byte)
+	for key, value := range r.persisted {
+		if strings.HasPrefix(key, prefix) {
+			set[key] = value
+		}
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("No keys with prefix %s found", prefix)
+	}
+	return set, nil
+}
+
+// DelState deletes a persisted value
+func (r *Replica) DelState(key string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.persisted, key)
+}
+
+// workQueue runs pushed functions one at a time, in order, on its own
+// goroutine. Pushing never blocks, so plugins may send messages from within
+// RecvMsg without deadlocking the network.
+type workQueue struct {
+	lock    sync.Mutex
+	work    []func()
+	signal  chan struct{}
+	stopped chan struct{}
+}
+
+func newWorkQueue() *workQueue {
+	q := &workQueue{
+		signal:  make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *workQueue) push(f func()) {
+	q.lock.Lock()
+	q.work = append(q.work, f)
+	q.lock.Unlock()
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (q *workQueue) stop() {
+	close(q.stopped)
+}
+
+func (q *workQueue) run() {
+	for {
+		select {
+		case <-q.stopped:
+			return
+		case <-q.signal:
+		}
+		for {
+			q.lock.Lock()
+			if len(q.work) == 0 {
+				q.lock.Unlock()
+				break
+			}
+			f := q.work[0]
+			q.work = q.work[1:]
+			q.lock.Unlock()
+
+			select {
+			case <-q.stopped:
+				return
+			default:
+			}
+			f()
+		}
+	}
+}
@@ -14,13 +14,25 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+// Package consensus defines the interfaces between a validating peer and its
+// consensus plugin. A plugin implements Consenter, is handed a Stack to reach
+// the network and the ledger, and makes itself available with RegisterPlugin.
+// See docs/tech/consensus-plugins.md and the conformance package.
 package consensus
 
 import (
+	"errors"
+	"time"
+
 	pb "github.com/hyperledger/fabric/protos"
 )
 
-// ExecutionConsumer allows callbacks from asycnhronous execution and statetransfer
+// ErrSaturated may be returned by RecvMsg for a CHAIN_TRANSACTION when the
+// Consenter can't accept more transactions for now, the client should retry later
+var ErrSaturated = errors.New("network saturated, retry later")
+
+// ExecutionConsumer allows callbacks from asynchronous execution and statetransfer,
+// the tag is the one the plugin passed to the corresponding Executor call
 type ExecutionConsumer interface {
 	Executed(tag interface{})                                // Called whenever Execute completes
 	Committed(tag interface{}, target *pb.BlockchainInfo)    // Called whenever Commit completes
@@ -29,12 +41,32 @@ type ExecutionConsumer interface {
 }
 
 // Consenter is used to receive messages from the network
-// Every consensus plugin needs to implement this interface
+// Every consensus plugin needs to implement this interface, it receives
+// CHAIN_TRANSACTION messages from clients and CONSENSUS messages from other
+// validators
 type Consenter interface {
 	RecvMsg(msg *pb.Message, senderHandle *pb.PeerID) error // Called serially with incoming messages from gRPC
 	ExecutionConsumer
 }
 
+// Stopper may be implemented by a Consenter which can hand over its duties
+// to the rest of the network when the validator shuts down in an orderly way
+type Stopper interface {
+	Stop(timeout time.Duration) error // Blocks until the validator may exit, or the timeout expires
+}
+
+// HealthReporter may be implemented by a Consenter which monitors whether
+// the validating network is still able to order transactions
+type HealthReporter interface {
+	Health() *pb.ConsensusHealth // Returns the most recently assessed health
+}
+
+// HealthObserver may be implemented by a Stack which wants to be notified
+// whenever the health assessed by the Consenter changes
+type HealthObserver interface {
+	HealthChanged(health *pb.ConsensusHealth)
+}
+
 // Inquirer is used to retrieve info about the validating network
 type Inquirer interface {
 	GetNetworkInfo() (self *pb.PeerEndpoint, network []*pb.PeerEndpoint, err error)
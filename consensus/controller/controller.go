@@ -32,15 +32,21 @@ var consenter consensus.Consenter
 
 func init() {
 	logger = logging.MustGetLogger("consensus/controller")
+
+	consensus.RegisterPlugin("noops", noops.GetNoops)
+	consensus.RegisterPlugin("pbft", pbft.GetPlugin)
 }
 
 // NewConsenter constructs a Consenter object if not already present
 func NewConsenter(stack consensus.Stack) consensus.Consenter {
 
 	plugin := strings.ToLower(viper.GetString("peer.validator.consensus.plugin"))
-	if plugin == "pbft" {
+	if factory, ok := consensus.GetPluginFactory(plugin); ok {
 		logger.Infof("Creating consensus plugin %s", plugin)
-		return pbft.GetPlugin(stack)
+		return factory(stack)
+	}
+	if plugin != "" {
+		logger.Warningf("Unknown consensus plugin %s, registered plugins are %v", plugin, consensus.RegisteredPlugins())
 	}
 	logger.Info("Creating default consensus plugin (noops)")
 	return noops.GetNoops(stack)
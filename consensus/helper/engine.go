@@ -22,10 +22,12 @@ import (
 
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/hyperledger/fabric/consensus/controller"
 	"github.com/hyperledger/fabric/consensus/util"
 	"github.com/hyperledger/fabric/core/chaincode"
+	"github.com/hyperledger/fabric/core/trace"
 	pb "github.com/hyperledger/fabric/protos"
 	"golang.org/x/net/context"
 )
@@ -35,6 +37,7 @@ type EngineImpl struct {
 	consenter    consensus.Consenter
 	helper       *Helper
 	peerEndpoint *pb.PeerEndpoint
+	queryPolicy  *queryPolicy
 	consensusFan *util.MessageFan
 }
 
@@ -46,7 +49,7 @@ func (eng *EngineImpl) GetHandlerFactory() peer.HandlerFactory {
 // ProcessTransactionMsg processes a Message in context of a Transaction
 func (eng *EngineImpl) ProcessTransactionMsg(msg *pb.Message, tx *pb.Transaction) (response *pb.Response) {
 	//TODO: Do we always verify security, or can we supply a flag on the invoke ot this functions so to bypass check for locally generated transactions?
-	if tx.Type == pb.Transaction_CHAINCODE_QUERY {
+	if tx.Type == pb.Transaction_CHAINCODE_QUERY && !eng.queryPolicy.isOrdered(tx) {
 		if !engine.helper.valid {
 			logger.Warning("Rejecting query because state is currently not valid")
 			return &pb.Response{Status: pb.Response_FAILURE,
@@ -58,7 +61,9 @@ func (eng *EngineImpl) ProcessTransactionMsg(msg *pb.Message, tx *pb.Transaction
 		cxt := context.Background()
 		//query will ignore events as these are not stored on ledger (and query can report
 		//"event" data synchronously anyway)
+		span := trace.StartTransactionSpan("execute", tx, time.Now())
 		result, _, err := chaincode.Execute(cxt, chaincode.GetChain(chaincode.DefaultChain), tx)
+		span.Finish(err)
 		if err != nil {
 			response = &pb.Response{Status: pb.Response_FAILURE,
 				Msg: []byte(fmt.Sprintf("Error:%s", err))}
@@ -79,12 +84,29 @@ func (eng *EngineImpl) ProcessTransactionMsg(msg *pb.Message, tx *pb.Transaction
 		if eng.consenter == nil {
 			return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte("Engine not initialized")}
 		}
+
+		// An ordered query is answered with its result once it was executed in order
+		var result <-chan *pb.Response
+		if tx.Type == pb.Transaction_CHAINCODE_QUERY {
+			result = eng.helper.queries.register(tx.Uuid)
+		}
+
 		// TODO, do we want to put these requests into a queue? This will block until
 		// the consenter gets around to handling the message, but it also provides some
 		// natural feedback to the REST API to determine how long it takes to queue messages
 		err := eng.consenter.RecvMsg(msg, eng.peerEndpoint.ID)
-		if err != nil {
+		if err == consensus.ErrSaturated {
+			response = &pb.Response{Status: pb.Response_SATURATED, Msg: []byte(err.Error())}
+			if result != nil {
+				eng.helper.queries.cancel(tx.Uuid)
+			}
+		} else if err != nil {
 			response = &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}
+			if result != nil {
+				eng.helper.queries.cancel(tx.Uuid)
+			}
+		} else if result != nil {
+			response = eng.helper.queries.wait(tx.Uuid, result, eng.queryPolicy.timeout)
 		}
 	}
 	return response
@@ -100,6 +122,16 @@ func (eng *EngineImpl) setPeerEndpoint(peerEndpoint *pb.PeerEndpoint) *EngineImp
 	return eng
 }
 
+// GetConsensusHealth returns the health of the validating network as
+// assessed by the consenter
+func (eng *EngineImpl) GetConsensusHealth() (*pb.ConsensusHealth, error) {
+	reporter, ok := eng.consenter.(consensus.HealthReporter)
+	if !ok {
+		return nil, fmt.Errorf("Consenter does not report the health of the network")
+	}
+	return reporter.Health(), nil
+}
+
 var engineOnce sync.Once
 
 var engine *EngineImpl
@@ -114,6 +146,7 @@ func GetEngine(coord peer.MessageHandlerCoordinator) (peer.Engine, error) {
 	engineOnce.Do(func() {
 		engine = new(EngineImpl)
 		engine.helper = NewHelper(coord)
+		engine.queryPolicy = newQueryPolicy()
 		engine.consenter = controller.NewConsenter(engine.helper)
 		engine.helper.setConsenter(engine.consenter)
 		engine.peerEndpoint, err = coord.GetPeerEndpoint()
@@ -130,3 +163,20 @@ func GetEngine(coord peer.MessageHandlerCoordinator) (peer.Engine, error) {
 	})
 	return engine, err
 }
+
+// StopConsenter lets the consenter hand over its duties before the peer
+// exits, if it supports doing so
+func StopConsenter(timeout time.Duration) {
+	eng := getEngineImpl()
+	if eng == nil {
+		return
+	}
+	stopper, ok := eng.consenter.(consensus.Stopper)
+	if !ok {
+		return
+	}
+	logger.Infof("Stopping consenter, waiting up to %v", timeout)
+	if err := stopper.Stop(timeout); err != nil {
+		logger.Warningf("Consenter did not stop gracefully: %s", err)
+	}
+}
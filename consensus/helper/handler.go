@@ -48,14 +48,19 @@ type ConsensusHandler struct {
 }
 
 // NewConsensusHandler constructs a new MessageHandler for the plugin.
-// Is instance of peer.HandlerFactory
+// Is instance of peer.HandlerFactory, next is the handler of an established
+// connection to extend, if any
 func NewConsensusHandler(coord peer.MessageHandlerCoordinator,
 	stream peer.ChatStream, initiatedStream bool,
 	next peer.MessageHandler) (peer.MessageHandler, error) {
 
-	peerHandler, err := peer.NewPeerHandler(coord, stream, initiatedStream, nil)
-	if err != nil {
-		return nil, fmt.Errorf("Error creating PeerHandler: %s", err)
+	peerHandler := next
+	if peerHandler == nil {
+		var err error
+		peerHandler, err = peer.NewPeerHandler(coord, stream, initiatedStream, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating PeerHandler: %s", err)
+		}
 	}
 
 	handler := &ConsensusHandler{
@@ -78,6 +83,11 @@ func NewConsensusHandler(coord peer.MessageHandlerCoordinator,
 	return handler, nil
 }
 
+// Extended returns the handler of the connection this handler extends
+func (handler *ConsensusHandler) Extended() peer.MessageHandler {
+	return handler.MessageHandler
+}
+
 // HandleMessage handles the incoming Fabric messages for the Peer
 func (handler *ConsensusHandler) HandleMessage(msg *pb.Message) error {
 	if msg.Type == pb.Message_CONSENSUS {
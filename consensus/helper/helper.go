@@ -18,6 +18,7 @@ package helper
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/spf13/viper"
@@ -30,6 +31,9 @@ import (
 	crypto "github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/core/trace"
+	"github.com/hyperledger/fabric/core/txstatus"
+	"github.com/hyperledger/fabric/events/producer"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -42,6 +46,8 @@ type Helper struct {
 	secHelper    crypto.Peer
 	curBatch     []*pb.Transaction       // TODO, remove after issue 579
 	curBatchErrs []*pb.TransactionResult // TODO, remove after issue 579
+	curQueries   []*pb.TransactionResult // Results of the queries in the current batch
+	queries      *orderedQueries         // Callers waiting for ordered queries submitted through this peer
 	persist.Helper
 
 	executor consensus.Executor
@@ -54,6 +60,7 @@ func NewHelper(mhc peer.MessageHandlerCoordinator) *Helper {
 		secOn:       viper.GetBool("security.enabled"),
 		secHelper:   mhc.GetSecHelper(),
 		valid:       true, // Assume our state is consistent until we are told otherwise, TODO: revisit
+		queries:     newOrderedQueries(),
 	}
 
 	h.executor = executor.NewImpl(h, h, mhc)
@@ -167,6 +174,7 @@ func (h *Helper) BeginTxBatch(id interface{}) error {
 	}
 	h.curBatch = nil     // TODO, remove after issue 579
 	h.curBatchErrs = nil // TODO, remove after issue 579
+	h.curQueries = nil
 	return nil
 }
 
@@ -180,7 +188,16 @@ func (h *Helper) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error)
 	// cxt := context.WithValue(context.Background(), "security", h.coordinator.GetSecHelper())
 	// TODO return directly once underlying implementation no longer returns []error
 
-	succeededTxs, res, ccevents, txerrs, err := chaincode.ExecuteTransactions(context.Background(), chaincode.DefaultChain, txs)
+	start := time.Now()
+	for _, tx := range txs {
+		// Transactions are ordered from their creation to their execution
+		if tx.Timestamp != nil {
+			trace.StartTransactionSpan("order", tx, time.Unix(tx.Timestamp.Seconds, int64(tx.Timestamp.Nanos))).FinishAt(start, nil)
+		}
+	}
+	succeededTxs, res, results, ccevents, txerrs, err := chaincode.ExecuteTransactions(context.Background(), chaincode.DefaultChain, txs)
+	batchExecutionDuration.ObserveSince(start)
+	batchSize.Observe(float64(len(txs)))
 
 	h.curBatch = append(h.curBatch, succeededTxs...) // TODO, remove after issue 579
 
@@ -191,10 +208,18 @@ func (h *Helper) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error)
 	for i, e := range txerrs {
 		//NOTE- it'll be nice if we can have error values. For now success == 0, error == 1
 		if txerrs[i] != nil {
+			transactionsRejected.Inc()
 			txresults[i] = &pb.TransactionResult{Uuid: txs[i].Uuid, Error: e.Error(), ErrorCode: 1, ChaincodeEvent: ccevents[i]}
 		} else {
 			txresults[i] = &pb.TransactionResult{Uuid: txs[i].Uuid, ChaincodeEvent: ccevents[i]}
 		}
+		if txs[i].Type == pb.Transaction_CHAINCODE_QUERY {
+			// Queries only reach consensus when ordered, their result is returned once committed
+			txresults[i].Result = results[i]
+			h.curQueries = append(h.curQueries, txresults[i])
+		} else {
+			txstatus.Default().Ordered(txs[i].Uuid)
+		}
 	}
 	h.curBatchErrs = append(h.curBatchErrs, txresults...) // TODO, remove after issue 579
 
@@ -216,10 +241,20 @@ func (h *Helper) CommitTxBatch(id interface{}, metadata []byte) (*pb.Block, erro
 		return nil, fmt.Errorf("Failed to commit transaction to the ledger: %v", err)
 	}
 
+	for _, result := range h.curQueries {
+		h.queries.complete(result)
+	}
+	for _, result := range h.curBatchErrs {
+		if result.ErrorCode != 0 {
+			txstatus.Default().Rejected(result.Uuid, result.Error)
+		}
+	}
+
 	size := ledger.GetBlockchainSize()
 	defer func() {
 		h.curBatch = nil     // TODO, remove after issue 579
 		h.curBatchErrs = nil // TODO, remove after issue 579
+		h.curQueries = nil
 	}()
 
 	block, err := ledger.GetBlockByNumber(size - 1)
@@ -242,8 +277,12 @@ func (h *Helper) RollbackTxBatch(id interface{}) error {
 	if err := ledger.RollbackTxBatch(id); err != nil {
 		return fmt.Errorf("Failed to rollback transaction with the ledger: %v", err)
 	}
+	for _, result := range h.curBatchErrs {
+		txstatus.Default().Requeued(result.Uuid)
+	}
 	h.curBatch = nil     // TODO, remove after issue 579
 	h.curBatchErrs = nil // TODO, remove after issue 579
+	h.curQueries = nil
 	return nil
 }
 
@@ -386,3 +425,10 @@ func (h *Helper) Start() {}
 
 // Halt is a byproduct of the consensus API needing some cleaning, for now it's a no-op
 func (h *Helper) Halt() {}
+
+// HealthChanged publishes the health assessed by the consenter as a peer event
+func (h *Helper) HealthChanged(health *pb.ConsensusHealth) {
+	if err := producer.Send(producer.CreateConsensusHealthEvent(health)); err != nil {
+		logger.Errorf("Could not send consensus health event: %s", err)
+	}
+}
@@ -0,0 +1,30 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	"github.com/hyperledger/fabric/core/metrics"
+)
+
+var (
+	batchSize = metrics.NewHistogram("fabric_consensus_batch_size",
+		"Transactions of the batches executed by the consensus.", []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000})
+	batchExecutionDuration = metrics.NewHistogram("fabric_consensus_batch_execution_duration_seconds",
+		"Time taken to execute the transactions of a batch.", metrics.DefaultBuckets)
+	transactionsRejected = metrics.NewCounter("fabric_consensus_transactions_rejected_total",
+		"Transactions of the batches executed by the consensus which failed.")
+)
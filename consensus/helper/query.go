@@ -0,0 +1,120 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// allChaincodes in the ordered queries configuration selects every chaincode
+const allChaincodes = "*"
+
+// queryPolicy decides whether a query is answered from the local state of
+// the validator receiving it, or ordered through consensus like an invoke
+type queryPolicy struct {
+	ordered map[string]bool // names of the chaincodes whose queries are ordered
+	timeout time.Duration   // how long to wait for an ordered query to execute
+}
+
+func newQueryPolicy() *queryPolicy {
+	qp := &queryPolicy{ordered: make(map[string]bool)}
+	for _, name := range viper.GetStringSlice("peer.validator.consensus.orderedqueries.chaincodes") {
+		qp.ordered[name] = true
+	}
+	qp.timeout = viper.GetDuration("peer.validator.consensus.orderedqueries.timeout")
+	if qp.timeout <= 0 {
+		qp.timeout = 30 * time.Second
+	}
+	return qp
+}
+
+// isOrdered returns whether the query must go through consensus, queries
+// whose chaincode can't be determined (e.g. because of confidentiality) are
+// only ordered if the queries of all chaincodes are
+func (qp *queryPolicy) isOrdered(tx *pb.Transaction) bool {
+	if qp.ordered[allChaincodes] {
+		return true
+	}
+	cID := &pb.ChaincodeID{}
+	if err := proto.Unmarshal(tx.ChaincodeID, cID); err != nil {
+		return false
+	}
+	return qp.ordered[cID.Name]
+}
+
+// orderedQueries hands the results of ordered queries, once committed, to
+// the callers waiting for them
+type orderedQueries struct {
+	lock    sync.Mutex
+	waiting map[string]chan *pb.Response
+}
+
+func newOrderedQueries() *orderedQueries {
+	return &orderedQueries{waiting: make(map[string]chan *pb.Response)}
+}
+
+// register must be called before the query is submitted to consensus
+func (oq *orderedQueries) register(uuid string) <-chan *pb.Response {
+	oq.lock.Lock()
+	defer oq.lock.Unlock()
+	result := make(chan *pb.Response, 1)
+	oq.waiting[uuid] = result
+	return result
+}
+
+// cancel stops waiting for the query, its result is dropped if it arrives later
+func (oq *orderedQueries) cancel(uuid string) {
+	oq.lock.Lock()
+	defer oq.lock.Unlock()
+	delete(oq.waiting, uuid)
+}
+
+// complete delivers the result of a committed query, queries submitted
+// through other validators have no one waiting and are ignored
+func (oq *orderedQueries) complete(result *pb.TransactionResult) {
+	oq.lock.Lock()
+	defer oq.lock.Unlock()
+	waiting, ok := oq.waiting[result.Uuid]
+	if !ok {
+		return
+	}
+	delete(oq.waiting, result.Uuid)
+	if result.ErrorCode != 0 {
+		waiting <- &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Error:%s", result.Error))}
+	} else {
+		waiting <- &pb.Response{Status: pb.Response_SUCCESS, Msg: result.Result}
+	}
+}
+
+// wait blocks until the result of the query was delivered or the timeout expired
+func (oq *orderedQueries) wait(uuid string, result <-chan *pb.Response, timeout time.Duration) *pb.Response {
+	select {
+	case response := <-result:
+		return response
+	case <-time.After(timeout):
+		oq.cancel(uuid)
+		return &pb.Response{Status: pb.Response_FAILURE,
+			Msg: []byte(fmt.Sprintf("Error: ordered query %s was not executed within %v", uuid, timeout))}
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func queryFor(name string) *pb.Transaction {
+	cID, _ := proto.Marshal(&pb.ChaincodeID{Name: name})
+	return &pb.Transaction{Type: pb.Transaction_CHAINCODE_QUERY, ChaincodeID: cID}
+}
+
+func TestQueryPolicy(t *testing.T) {
+	viper.Set("peer.validator.consensus.orderedqueries.chaincodes", []string{"consistent"})
+	defer viper.Set("peer.validator.consensus.orderedqueries.chaincodes", []string{})
+
+	qp := newQueryPolicy()
+	if !qp.isOrdered(queryFor("consistent")) {
+		t.Errorf("Expected queries of a listed chaincode to be ordered")
+	}
+	if qp.isOrdered(queryFor("fast")) {
+		t.Errorf("Expected queries of other chaincodes to be answered locally")
+	}
+	if qp.isOrdered(&pb.Transaction{Type: pb.Transaction_CHAINCODE_QUERY, ChaincodeID: []byte("encrypted")}) {
+		t.Errorf("Expected queries of an unknown chaincode to be answered locally")
+	}
+
+	qp.ordered[allChaincodes] = true
+	if !qp.isOrdered(queryFor("fast")) {
+		t.Errorf("Expected the wildcard to order the queries of every chaincode")
+	}
+}
+
+func TestOrderedQueries(t *testing.T) {
+	oq := newOrderedQueries()
+
+	result := oq.register("query")
+	oq.complete(&pb.TransactionResult{Uuid: "other", Result: []byte("ignored")})
+	oq.complete(&pb.TransactionResult{Uuid: "query", Result: []byte("value")})
+	response := oq.wait("query", result, time.Second)
+	if response.Status != pb.Response_SUCCESS || string(response.Msg) != "value" {
+		t.Errorf("Expected the committed result to be returned, got %v", response)
+	}
+
+	result = oq.register("failed")
+	oq.complete(&pb.TransactionResult{Uuid: "failed", ErrorCode: 1, Error: "boom"})
+	if response := oq.wait("failed", result, time.Second); response.Status != pb.Response_FAILURE {
+		t.Errorf("Expected a failed query to be reported as failure, got %v", response)
+	}
+
+	result = oq.register("lost")
+	if response := oq.wait("lost", result, 10*time.Millisecond); response.Status != pb.Response_FAILURE {
+		t.Errorf("Expected a query which was never executed to time out, got %v", response)
+	}
+	if len(oq.waiting) != 0 {
+		t.Errorf("Expected no queries to be waiting, %d are", len(oq.waiting))
+	}
+}
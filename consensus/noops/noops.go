@@ -186,6 +186,7 @@ func (i *Noops) processBlock() error {
 		}
 		return nil
 	}
+	var blockNumber uint64
 	var data *pb.Block
 	var delta *statemgmt.StateDelta
 	var err error
@@ -193,10 +194,10 @@ func (i *Noops) processBlock() error {
 	if err = i.processTransactions(); nil != err {
 		return err
 	}
-	if data, delta, err = i.getBlockData(); nil != err {
+	if blockNumber, data, delta, err = i.getBlockData(); nil != err {
 		return err
 	}
-	go i.notifyBlockAdded(data, delta)
+	go i.notifyBlockAdded(blockNumber, data, delta)
 	return nil
 }
 
@@ -242,10 +243,10 @@ func (i *Noops) getTxFromMsg(msg *pb.Message) (*pb.Transaction, error) {
 	return txs.GetTransactions()[0], nil
 }
 
-func (i *Noops) getBlockData() (*pb.Block, *statemgmt.StateDelta, error) {
+func (i *Noops) getBlockData() (uint64, *pb.Block, *statemgmt.StateDelta, error) {
 	ledger, err := ledger.GetLedger()
 	if err != nil {
-		return nil, nil, fmt.Errorf("Fail to get the ledger: %v", err)
+		return 0, nil, nil, fmt.Errorf("Fail to get the ledger: %v", err)
 	}
 
 	blockHeight := ledger.GetBlockchainSize()
@@ -254,27 +255,24 @@ func (i *Noops) getBlockData() (*pb.Block, *statemgmt.StateDelta, error) {
 	}
 	block, err := ledger.GetBlockByNumber(blockHeight - 1)
 	if nil != err {
-		return nil, nil, err
+		return 0, nil, nil, err
 	}
 	//delta, err := ledger.GetStateDeltaBytes(blockHeight)
 	delta, err := ledger.GetStateDelta(blockHeight - 1)
 	if nil != err {
-		return nil, nil, err
+		return 0, nil, nil, err
 	}
 	if logger.IsEnabledFor(logging.DEBUG) {
 		logger.Debugf("Got the delta state of block number %v", blockHeight)
 	}
 
-	return block, delta, nil
+	return blockHeight - 1, block, delta, nil
 }
 
-func (i *Noops) notifyBlockAdded(block *pb.Block, delta *statemgmt.StateDelta) error {
-	//make Payload nil to reduce block size..
-	//anything else to remove .. do we need StateDelta ?
-	for _, tx := range block.Transactions {
-		tx.Payload = nil
-	}
-	data, err := proto.Marshal(&pb.BlockState{Block: block, StateDelta: delta.Marshal()})
+func (i *Noops) notifyBlockAdded(blockNumber uint64, block *pb.Block, delta *statemgmt.StateDelta) error {
+	// The block is sent whole, NVPs append it to their blockchain and the
+	// hash of the next block must match it
+	data, err := proto.Marshal(&pb.BlockState{Block: block, StateDelta: delta.Marshal(), BlockNumber: blockNumber})
 	if err != nil {
 		return fmt.Errorf("Fail to marshall BlockState structure: %v", err)
 	}
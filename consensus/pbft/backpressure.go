@@ -0,0 +1,52 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"sync/atomic"
+
+	"github.com/hyperledger/fabric/consensus"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// RecvMsg rejects transactions from clients while the replica is saturated,
+// instead of queuing them until they time out, all other messages are
+// handed to the event thread
+func (op *obcBatch) RecvMsg(ocMsg *pb.Message, senderHandle *pb.PeerID) error {
+	if ocMsg.Type == pb.Message_CHAIN_TRANSACTION && op.saturated() {
+		saturatedRejections.Inc()
+		logger.Debugf("Replica %d saturated with %d outstanding requests, rejecting client transaction", op.pbft.id, op.outstandingRequests())
+		return consensus.ErrSaturated
+	}
+	return op.externalEventReceiver.RecvMsg(ocMsg, senderHandle)
+}
+
+// updateLoad publishes the number of outstanding requests to other threads,
+// it must be called from the event thread
+func (op *obcBatch) updateLoad() {
+	outstanding := op.reqStore.outstandingRequests.Len()
+	atomic.StoreInt64(&op.outstanding, int64(outstanding))
+	outstandingRequestsGauge.Set(float64(outstanding))
+}
+
+func (op *obcBatch) outstandingRequests() int {
+	return int(atomic.LoadInt64(&op.outstanding))
+}
+
+func (op *obcBatch) saturated() bool {
+	return op.maxOutstanding > 0 && op.outstandingRequests() >= op.maxOutstanding
+}
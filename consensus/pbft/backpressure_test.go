@@ -0,0 +1,59 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/consensus/util/events"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestBackpressureRejectsClientTransactions(t *testing.T) {
+	config := loadConfig()
+	config.Set("general.maxoutstanding", 2)
+	config.Set("general.batchsize", 10)
+	stack := &omniProto{
+		BroadcastImpl: func(msg *pb.Message, peerType pb.PeerEndpoint_Type) error { return nil },
+		UnicastImpl:   func(msg *pb.Message, receiverHandle *pb.PeerID) error { return nil },
+		SignImpl:      func(msg []byte) ([]byte, error) { return msg, nil },
+	}
+	// A backup forwards requests but can't order them on its own
+	op := initObcBatch(1, config, stack, events.NewManagerImpl())
+	defer op.Close()
+
+	sender := &pb.PeerID{Name: "vp1"}
+	for i := 1; i <= 2; i++ {
+		events.SendEvent(op, batchMessageEvent{createTxMsg(int64(i)), sender})
+		if health := op.Health(); health.Outstanding != uint64(i) {
+			t.Fatalf("Expected %d outstanding requests, health reports %d", i, health.Outstanding)
+		}
+	}
+
+	if !op.Health().Saturated {
+		t.Errorf("Expected the replica to report saturation")
+	}
+	if err := op.RecvMsg(createTxMsg(3), sender); err != consensus.ErrSaturated {
+		t.Errorf("Expected a client transaction to be rejected while saturated, got %v", err)
+	}
+
+	op.maxOutstanding = 0
+	if op.saturated() {
+		t.Errorf("Expected no saturation without a limit")
+	}
+}
@@ -37,7 +37,9 @@ type obcBatch struct {
 	broadcaster *broadcaster
 
 	batchSize        int
+	batchBytes       int
 	batchStore       []*Request
+	batchStoreBytes  int
 	batchTimer       events.Timer
 	batchTimerActive bool
 	batchTimeout     time.Duration
@@ -49,8 +51,18 @@ type obcBatch struct {
 
 	reqStore *requestStore // Holds the outstanding and pending requests
 
+	maxOutstanding int   // Client transactions are rejected while this many requests are outstanding, 0 for no limit
+	outstanding    int64 // Number of outstanding requests, read atomically by other threads
+
 	deduplicator *deduplicator
 
+	health *healthMonitor // Assesses whether the network is partitioned
+
+	recorder *recorder // Records the events processed, nil unless general.record is set
+
+	stopping bool          // set once the replica is shutting down
+	stopDone chan struct{} // closed once the departure was announced
+
 	persistForward
 }
 
@@ -68,6 +80,29 @@ type batchMessageEvent batchMessage
 type batchTimerEvent struct{}
 
 func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatch {
+	var rec *recorder
+	var manager events.Manager
+	if path := config.GetString("general.record"); path != "" {
+		var err error
+		if rec, err = newRecorder(path, id); err != nil {
+			panic(fmt.Errorf("Cannot create consensus recording %s: %s", path, err))
+		}
+		logger.Infof("PBFT recording consensus events to %s", path)
+		stack = &recordingStack{Stack: stack, rec: rec}
+		manager = events.NewRecordingManagerImpl(rec.recordEvent)
+	} else {
+		manager = events.NewManagerImpl() // TODO, this is hacky, eventually rip it out
+	}
+
+	op := initObcBatch(id, config, stack, manager)
+	op.recorder = rec
+	op.manager.Start()
+	return op
+}
+
+// initObcBatch creates the replica without starting the manager thread, so
+// that events are only delivered when the caller injects them
+func initObcBatch(id uint64, config *viper.Viper, stack consensus.Stack, manager events.Manager) *obcBatch {
 	var err error
 
 	op := &obcBatch{
@@ -78,21 +113,25 @@ func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatc
 
 	logger.Debugf("Replica %d obtaining startup information", id)
 
-	op.manager = events.NewManagerImpl() // TODO, this is hacky, eventually rip it out
+	op.manager = manager
 	op.manager.SetReceiver(op)
 	etf := events.NewTimerFactoryImpl(op.manager)
 	op.pbft = newPbftCore(id, config, op, etf)
-	op.manager.Start()
 	op.externalEventReceiver.manager = op.manager
 	op.broadcaster = newBroadcaster(id, op.pbft.N, op.pbft.f, stack)
 
 	op.batchSize = config.GetInt("general.batchsize")
+	op.batchBytes = config.GetInt("general.batchbytes")
+	if op.batchBytes < 0 {
+		panic(fmt.Errorf("Batch bytes must not be negative, got %d", op.batchBytes))
+	}
 	op.batchStore = nil
 	op.batchTimeout, err = time.ParseDuration(config.GetString("general.timeout.batch"))
 	if err != nil {
 		panic(fmt.Errorf("Cannot parse batch timeout: %s", err))
 	}
 	logger.Infof("PBFT Batch size = %d", op.batchSize)
+	logger.Infof("PBFT Batch bytes = %d", op.batchBytes)
 	logger.Infof("PBFT Batch timeout = %v", op.batchTimeout)
 
 	if op.batchTimeout >= op.pbft.requestTimeout {
@@ -111,7 +150,18 @@ func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatc
 
 	op.reqStore = newRequestStore()
 
-	op.deduplicator = newDeduplicator()
+	op.maxOutstanding = config.GetInt("general.maxoutstanding")
+	if op.maxOutstanding < 0 {
+		panic(fmt.Errorf("Max outstanding requests must not be negative, got %d", op.maxOutstanding))
+	}
+	logger.Infof("PBFT max outstanding requests = %d", op.maxOutstanding)
+
+	op.deduplicator = newDeduplicator(config.GetInt("general.dedupwindow"))
+	logger.Infof("PBFT deduplication window = %d", op.deduplicator.Window())
+
+	op.health = newHealthMonitor(config, etf)
+	logger.Infof("PBFT health check interval = %v", op.health.interval)
+	op.health.start()
 
 	op.idleChan = make(chan struct{})
 	close(op.idleChan) // TODO remove eventually
@@ -122,16 +172,24 @@ func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatc
 // Close tells us to release resources we are holding
 func (op *obcBatch) Close() {
 	op.batchTimer.Halt()
+	op.health.timer.Halt()
 	op.pbft.close()
+	if op.recorder != nil {
+		op.recorder.close()
+	}
 }
 
 func (op *obcBatch) submitToLeader(req *Request) events.Event {
+	if !op.isNewTransaction(req) {
+		return nil
+	}
+
 	// Broadcast the request to the network, in case we're in the wrong view
 	op.broadcastMsg(&BatchMessage{Payload: &BatchMessage_Request{Request: req}})
 	op.logAddTxFromRequest(req)
 	op.reqStore.storeOutstanding(req)
 	op.startTimerIfOutstandingRequests()
-	if op.pbft.primary(op.pbft.view) == op.pbft.id && op.pbft.activeView {
+	if op.isActivePrimary() && !op.stopping {
 		return op.leaderProcReq(req)
 	}
 	return nil
@@ -196,8 +254,12 @@ func (op *obcBatch) execute(seqNo uint64, reqBatch *RequestBatch) {
 		if outstanding, pending := op.reqStore.remove(req); !outstanding || !pending {
 			logger.Debugf("Batch replica %d missing transaction %s outstanding=%v, pending=%v", op.pbft.id, tx.Uuid, outstanding, pending)
 		}
-		txs = append(txs, tx)
 		op.deduplicator.Execute(req)
+		if !op.deduplicator.ExecuteOnce(tx.Uuid) {
+			logger.Warningf("Batch replica %d skipping transaction %s, it was already executed", op.pbft.id, tx.Uuid)
+			continue
+		}
+		txs = append(txs, tx)
 	}
 	meta, _ := proto.Marshal(&Metadata{seqNo})
 	logger.Debugf("Batch replica %d received exec for seqNo %d containing %d transactions", op.pbft.id, seqNo, len(txs))
@@ -211,15 +273,26 @@ func (op *obcBatch) execute(seqNo uint64, reqBatch *RequestBatch) {
 func (op *obcBatch) leaderProcReq(req *Request) events.Event {
 	// XXX check req sig
 	digest := hash(req)
+	size := proto.Size(req)
+	if op.batchBytes > 0 && len(op.batchStore) > 0 && op.batchStoreBytes+size > op.batchBytes {
+		// The request does not fit, order the current batch first and start a new one with it
+		logger.Debugf("Batch primary %d cutting batch of %d bytes before request %s", op.pbft.id, op.batchStoreBytes, digest)
+		op.manager.Inject(op.sendBatch())
+	}
+	if op.batchBytes > 0 && size > op.batchBytes {
+		logger.Warningf("Batch primary %d received request %s of %d bytes, exceeding the batch limit of %d bytes", op.pbft.id, digest, size, op.batchBytes)
+	}
+
 	logger.Debugf("Batch primary %d queueing new request %s", op.pbft.id, digest)
 	op.batchStore = append(op.batchStore, req)
+	op.batchStoreBytes += size
 	op.reqStore.storePending(req)
 
 	if !op.batchTimerActive {
 		op.startBatchTimer()
 	}
 
-	if len(op.batchStore) >= op.batchSize {
+	if len(op.batchStore) >= op.batchSize || (op.batchBytes > 0 && op.batchStoreBytes >= op.batchBytes) {
 		return op.sendBatch()
 	}
 
@@ -234,8 +307,9 @@ func (op *obcBatch) sendBatch() events.Event {
 	}
 
 	reqBatch := &RequestBatch{Batch: op.batchStore}
+	logger.Infof("Creating batch with %d requests of %d bytes", len(reqBatch.Batch), op.batchStoreBytes)
 	op.batchStore = nil
-	logger.Infof("Creating batch with %d requests", len(reqBatch.Batch))
+	op.batchStoreBytes = 0
 	return reqBatch
 }
 
@@ -276,14 +350,19 @@ func (op *obcBatch) processMessage(ocMsg *pb.Message, senderHandle *pb.PeerID) e
 			logger.Warningf("Replica %d ignoring request as it is too old", op.pbft.id)
 			return nil
 		}
+		if !op.isNewTransaction(req) {
+			return nil
+		}
 
 		op.logAddTxFromRequest(req)
 		op.reqStore.storeOutstanding(req)
-		if (op.pbft.primary(op.pbft.view) == op.pbft.id) && op.pbft.activeView {
+		if op.isActivePrimary() && !op.stopping {
 			return op.leaderProcReq(req)
 		}
 		op.startTimerIfOutstandingRequests()
 		return nil
+	} else if departure := batchMsg.GetDeparture(); departure != nil {
+		return op.recvDeparture(departure, senderHandle)
 	} else if pbftMsg := batchMsg.GetPbftMessage(); pbftMsg != nil {
 		senderID, err := getValidatorID(senderHandle) // who sent this?
 		if err != nil {
@@ -306,6 +385,42 @@ func (op *obcBatch) processMessage(ocMsg *pb.Message, senderHandle *pb.PeerID) e
 	return nil
 }
 
+// isNewTransaction records the transaction wrapped by the request and
+// returns false if it was recently seen, e.g. because a client retried it
+func (op *obcBatch) isNewTransaction(req *Request) bool {
+	tx := &pb.Transaction{}
+	if err := proto.Unmarshal(req.Payload, tx); err != nil {
+		// Let it through, it will be skipped on execution
+		return true
+	}
+	if !op.deduplicator.Received(tx.Uuid) {
+		logger.Debugf("Replica %d dropping request with duplicate transaction %s", op.pbft.id, tx.Uuid)
+		return false
+	}
+	return true
+}
+
+// restoreExecutedTransactions rebuilds the executed transactions known to
+// the deduplicator from the ledger, as state transfer skipped their execution
+func (op *obcBatch) restoreExecutedTransactions() {
+	var uuids []string
+	for n := op.stack.GetBlockchainSize(); n > 0 && len(uuids) < op.deduplicator.Window(); n-- {
+		block, err := op.stack.GetBlock(n - 1)
+		if err != nil {
+			logger.Warningf("Replica %d could not read block %d to restore executed transactions: %s", op.pbft.id, n-1, err)
+			break
+		}
+		txs := block.GetTransactions()
+		for i := len(txs) - 1; i >= 0 && len(uuids) < op.deduplicator.Window(); i-- {
+			uuids = append(uuids, txs[i].Uuid)
+		}
+	}
+	for i, j := 0, len(uuids)-1; i < j; i, j = i+1, j-1 {
+		uuids[i], uuids[j] = uuids[j], uuids[i]
+	}
+	op.deduplicator.ResetExecuted(uuids)
+}
+
 func (op *obcBatch) logAddTxFromRequest(req *Request) {
 	if logger.IsEnabledFor(logging.DEBUG) {
 		// This is potentially a very large expensive debug statement, guard
@@ -325,7 +440,7 @@ func (op *obcBatch) resubmitOutstandingReqs() events.Event {
 	// If we are the primary, and know of outstanding requests, submit them for inclusion in the next batch until
 	// we run out of requests, or a new batch message is triggered (this path will re-enter after execution)
 	// Do not enter while an execution is in progress to prevent duplicating a request
-	if op.pbft.primary(op.pbft.view) == op.pbft.id && op.pbft.activeView && op.pbft.currentExec == nil {
+	if op.isActivePrimary() && !op.stopping && op.pbft.currentExec == nil {
 		needed := op.batchSize - len(op.batchStore)
 
 		for op.reqStore.hasNonPending() {
@@ -345,6 +460,7 @@ func (op *obcBatch) resubmitOutstandingReqs() events.Event {
 // allow the primary to send a batch when the timer expires
 func (op *obcBatch) ProcessEvent(event events.Event) events.Event {
 	logger.Debugf("Replica %d batch main thread looping", op.pbft.id)
+	defer op.updateLoad()
 	switch et := event.(type) {
 	case batchMessageEvent:
 		ocMsg := et
@@ -359,7 +475,15 @@ func (op *obcBatch) ProcessEvent(event events.Event) events.Event {
 			// This may trigger a view change, if so, process it, we will resubmit on new view
 			return res
 		}
+		if op.stopping {
+			return op.maybeDepart()
+		}
 		return op.resubmitOutstandingReqs()
+	case shutdownEvent:
+		return op.startShutdown(et.done)
+	case healthCheckEvent:
+		op.checkHealth(time.Now())
+		op.health.start()
 	case batchTimerEvent:
 		logger.Infof("Replica %d batch timer expired", op.pbft.id)
 		if op.pbft.activeView && (len(op.batchStore) > 0) {
@@ -372,6 +496,7 @@ func (op *obcBatch) ProcessEvent(event events.Event) events.Event {
 		return res
 	case viewChangedEvent:
 		op.batchStore = nil
+		op.batchStoreBytes = 0
 		// Outstanding reqs doesn't make sense for batch, as all the requests in a batch may be processed
 		// in a different batch, but PBFT core can't see through the opaque structure to see this
 		// so, on view change, clear it out
@@ -385,6 +510,7 @@ func (op *obcBatch) ProcessEvent(event events.Event) events.Event {
 		if op.pbft.skipInProgress {
 			// If we're the new primary, but we're in state transfer, we can't trust ourself not to duplicate things
 			op.reqStore.outstandingRequests.empty()
+			op.deduplicator.ForgetReceived()
 		}
 
 		op.reqStore.pendingRequests.empty()
@@ -411,10 +537,17 @@ func (op *obcBatch) ProcessEvent(event events.Event) events.Event {
 			op.reqStore.storePendings(cert.prePrepare.RequestBatch.GetBatch())
 		}
 
+		if op.stopping {
+			return op.maybeDepart()
+		}
 		return op.resubmitOutstandingReqs()
 	case stateUpdatedEvent:
 		// When the state is updated, clear any outstanding requests, they may have been processed while we were gone
 		op.reqStore = newRequestStore()
+		op.deduplicator.ForgetReceived()
+		if et.target != nil {
+			op.restoreExecutedTransactions()
+		}
 		return op.pbft.ProcessEvent(event)
 	default:
 		return op.pbft.ProcessEvent(event)
@@ -21,9 +21,11 @@ import (
 	"time"
 
 	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/consensus/conformance"
 	"github.com/hyperledger/fabric/consensus/util/events"
 	pb "github.com/hyperledger/fabric/protos"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/spf13/viper"
 )
 
@@ -360,3 +362,124 @@ func TestClearBatchStoreOnViewChange(t *testing.T) {
 		t.Fatalf("Should have cleared the batch store on view change")
 	}
 }
+
+func TestBatchConformance(t *testing.T) {
+	config := loadConfig()
+	config.Set("general.batchsize", 1)
+	conformance.Run(t, func(stack consensus.Stack) consensus.Consenter {
+		handle, _, _ := stack.GetNetworkHandles()
+		id, _ := getValidatorID(handle)
+		return newObcBatch(id, config, stack)
+	}, conformance.Options{})
+}
+
+func TestBatchBytesLimit(t *testing.T) {
+	omni := &omniProto{
+		UnicastImpl: func(ocMsg *pb.Message, dest *pb.PeerID) error { return nil },
+	}
+	reqs := make([]*Request, 5)
+	for i := range reqs {
+		reqs[i] = createPbftReq(int64(i+1), 0) // equally sized requests
+	}
+	size := proto.Size(reqs[0])
+
+	config := loadConfig()
+	config.Set("general.batchsize", 100)
+	config.Set("general.batchbytes", 2*size+size/2)
+	b := newObcBatch(0, config, omni)
+	defer b.Close()
+
+	for _, req := range reqs {
+		if ev := b.leaderProcReq(req); ev != nil {
+			events.SendEvent(b, ev)
+		}
+	}
+
+	if len(b.pbft.outstandingReqBatches) != 2 {
+		t.Fatalf("Expected 2 batches to be cut by size, got %d", len(b.pbft.outstandingReqBatches))
+	}
+	for d, reqBatch := range b.pbft.outstandingReqBatches {
+		if len(reqBatch.Batch) != 2 {
+			t.Errorf("Expected batch %s to contain 2 requests, got %d", d, len(reqBatch.Batch))
+		}
+	}
+	if len(b.batchStore) != 1 || b.batchStoreBytes != proto.Size(reqs[4]) {
+		t.Errorf("Expected the last request to remain in the batch store, got %d requests of %d bytes", len(b.batchStore), b.batchStoreBytes)
+	}
+}
+
+func TestBatchBytesOversizedRequest(t *testing.T) {
+	omni := &omniProto{
+		UnicastImpl: func(ocMsg *pb.Message, dest *pb.PeerID) error { return nil },
+	}
+	config := loadConfig()
+	config.Set("general.batchsize", 100)
+	config.Set("general.batchbytes", 1)
+	b := newObcBatch(0, config, omni)
+	defer b.Close()
+
+	ev := b.leaderProcReq(createPbftReq(1, 0))
+	reqBatch, ok := ev.(*RequestBatch)
+	if !ok || len(reqBatch.Batch) != 1 {
+		t.Fatalf("Expected an oversized request to be sent in a batch of its own, got %v", ev)
+	}
+}
+
+func TestBatchSkipsDuplicateTransactions(t *testing.T) {
+	var executed []*pb.Transaction
+	omni := &omniProto{
+		ExecuteImpl: func(tag interface{}, txs []*pb.Transaction) {
+			executed = append(executed, txs...)
+		},
+		UnicastImpl: func(ocMsg *pb.Message, dest *pb.PeerID) error { return nil },
+	}
+	b := newObcBatch(1, loadConfig(), omni)
+	defer b.Close()
+
+	// A client retry wraps the same transaction into a new request
+	tx := createTx(1)
+	tx.Uuid = "retried"
+	first := &Request{Timestamp: createTx(1).Timestamp, ReplicaId: 1, Payload: marshalTx(tx)}
+	retry := &Request{Timestamp: createTx(2).Timestamp, ReplicaId: 2, Payload: marshalTx(tx)}
+
+	b.submitToLeader(first)
+	b.submitToLeader(retry)
+	if b.reqStore.outstandingRequests.Len() != 1 {
+		t.Errorf("Expected the retried request to be dropped, %d requests outstanding", b.reqStore.outstandingRequests.Len())
+	}
+
+	b.execute(1, &RequestBatch{Batch: []*Request{first, retry}})
+	if len(executed) != 1 {
+		t.Fatalf("Expected the transaction to be executed once, executed %d times", len(executed))
+	}
+}
+
+func TestBatchOrdersRetryAfterStateTransfer(t *testing.T) {
+	omni := &omniProto{
+		UnicastImpl: func(ocMsg *pb.Message, dest *pb.PeerID) error { return nil },
+	}
+	b := newObcBatch(0, loadConfig(), omni)
+	defer b.Close()
+	b.batchSize = 1
+
+	tx := createTx(1)
+	tx.Uuid = "lost"
+	first := &Request{Timestamp: createTx(1).Timestamp, ReplicaId: 1, Payload: marshalTx(tx)}
+	retry := &Request{Timestamp: createTx(2).Timestamp, ReplicaId: 2, Payload: marshalTx(tx)}
+
+	b.submitToLeader(first)
+
+	// The request is dropped without being executed when state transfer completes
+	b.manager.Queue() <- stateUpdatedEvent{
+		chkpt: &checkpointMessage{
+			seqNo: 10,
+		},
+	}
+	b.manager.Queue() <- nil
+
+	ev := b.submitToLeader(retry)
+	batch, ok := ev.(*RequestBatch)
+	if !ok || len(batch.Batch) != 1 || batch.Batch[0] != retry {
+		t.Fatalf("Expected the retried transaction to be ordered after state transfer, got %v", ev)
+	}
+}
@@ -24,16 +24,27 @@ import (
 // replica.  Two timestamps are maintained per replica.  One timestamp
 // tracks the most recent Request received from a replica, the other
 // timeout tracks the most recent executed Request.
+//
+// In addition, the UUIDs of the most recently received and executed
+// transactions are remembered, so that the same transaction wrapped in
+// different Requests (e.g. a client retrying against another validator)
+// is neither ordered nor executed twice.
 type deduplicator struct {
 	reqTimestamps  map[uint64]time.Time
 	execTimestamps map[uint64]time.Time
+
+	recvUUIDs *uuidWindow
+	execUUIDs *uuidWindow
 }
 
-// newDeduplicator creates a new deduplicator.
-func newDeduplicator() *deduplicator {
+// newDeduplicator creates a new deduplicator remembering the UUIDs of
+// up to window transactions, a window of 0 disables uuid tracking.
+func newDeduplicator(window int) *deduplicator {
 	d := &deduplicator{}
 	d.reqTimestamps = make(map[uint64]time.Time)
 	d.execTimestamps = make(map[uint64]time.Time)
+	d.recvUUIDs = newUUIDWindow(window)
+	d.execUUIDs = newUUIDWindow(window)
 	return d
 }
 
@@ -70,3 +81,88 @@ func (d *deduplicator) IsNew(req *Request) bool {
 	reqTime := time.Unix(req.Timestamp.Seconds, int64(req.Timestamp.Nanos))
 	return reqTime.After(d.execTimestamps[req.ReplicaId])
 }
+
+// Received records the UUID of the transaction in a newly received
+// Request. It returns false if the same transaction was recently received
+// or executed, in which case the Request should be dropped. Transactions
+// without a UUID cannot be told apart and are never reported as duplicates.
+func (d *deduplicator) Received(uuid string) bool {
+	if uuid == "" {
+		return true
+	}
+	if d.recvUUIDs.contains(uuid) || d.execUUIDs.contains(uuid) {
+		return false
+	}
+	d.recvUUIDs.add(uuid)
+	return true
+}
+
+// ExecuteOnce records the UUID of a transaction about to be executed. It
+// returns false if the same transaction was recently executed, in which
+// case it must be skipped. As it depends only on the sequence of executed
+// transactions, all correct replicas reach the same decision.
+func (d *deduplicator) ExecuteOnce(uuid string) bool {
+	if uuid == "" {
+		return true
+	}
+	if d.execUUIDs.contains(uuid) {
+		return false
+	}
+	d.execUUIDs.add(uuid)
+	return true
+}
+
+// ForgetReceived forgets the transactions received but not executed, once
+// the replica dropped its outstanding requests, so that clients may submit
+// them again
+func (d *deduplicator) ForgetReceived() {
+	d.recvUUIDs = newUUIDWindow(len(d.recvUUIDs.ring))
+}
+
+// ResetExecuted replaces the executed transactions by the given UUIDs,
+// oldest first, after the replica skipped executions by state transfer
+func (d *deduplicator) ResetExecuted(uuids []string) {
+	d.execUUIDs = newUUIDWindow(len(d.execUUIDs.ring))
+	for _, uuid := range uuids {
+		if uuid != "" {
+			d.execUUIDs.add(uuid)
+		}
+	}
+}
+
+// Window returns the number of transactions remembered
+func (d *deduplicator) Window() int {
+	return len(d.execUUIDs.ring)
+}
+
+// uuidWindow is a set of UUIDs bounded to the most recently added
+type uuidWindow struct {
+	ring []string
+	next int
+	set  map[string]struct{}
+}
+
+func newUUIDWindow(capacity int) *uuidWindow {
+	return &uuidWindow{
+		ring: make([]string, capacity),
+		set:  make(map[string]struct{}),
+	}
+}
+
+func (w *uuidWindow) contains(uuid string) bool {
+	_, ok := w.set[uuid]
+	return ok
+}
+
+// add inserts a uuid, evicting the oldest one if the window is full
+func (w *uuidWindow) add(uuid string) {
+	if len(w.ring) == 0 {
+		return
+	}
+	if old := w.ring[w.next]; old != "" {
+		delete(w.set, old)
+	}
+	w.ring[w.next] = uuid
+	w.set[uuid] = struct{}{}
+	w.next = (w.next + 1) % len(w.ring)
+}
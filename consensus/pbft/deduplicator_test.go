@@ -0,0 +1,80 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"testing"
+)
+
+func TestDeduplicatorReceived(t *testing.T) {
+	d := newDeduplicator(2)
+
+	if !d.Received("a") {
+		t.Fatalf("Expected first reception of a to be new")
+	}
+	if d.Received("a") {
+		t.Fatalf("Expected second reception of a to be a duplicate")
+	}
+	if !d.ExecuteOnce("b") {
+		t.Fatalf("Expected first execution of b to be allowed")
+	}
+	if d.Received("b") {
+		t.Fatalf("Expected reception of executed b to be a duplicate")
+	}
+	if !d.Received("") || !d.Received("") {
+		t.Fatalf("Expected transactions without UUID to never be duplicates")
+	}
+}
+
+func TestDeduplicatorForgetReceived(t *testing.T) {
+	d := newDeduplicator(2)
+
+	d.Received("a")
+	d.Received("b")
+	d.ExecuteOnce("b")
+	d.ForgetReceived()
+	if !d.Received("a") {
+		t.Fatalf("Expected a, received but not executed, to be new again")
+	}
+	if d.Received("b") {
+		t.Fatalf("Expected executed b to remain a duplicate")
+	}
+}
+
+func TestDeduplicatorWindow(t *testing.T) {
+	d := newDeduplicator(2)
+
+	d.ExecuteOnce("a")
+	d.ExecuteOnce("b")
+	if d.ExecuteOnce("a") {
+		t.Fatalf("Expected a to still be in the window")
+	}
+	d.ExecuteOnce("c")
+	if !d.ExecuteOnce("a") {
+		t.Fatalf("Expected a to be evicted from the window")
+	}
+
+	d.ResetExecuted([]string{"x", "y", "z"})
+	if d.ExecuteOnce("z") || d.ExecuteOnce("y") {
+		t.Fatalf("Expected the most recent restored transactions to be in the window")
+	}
+
+	disabled := newDeduplicator(0)
+	if !disabled.ExecuteOnce("a") || !disabled.ExecuteOnce("a") {
+		t.Fatalf("Expected a window of 0 to disable deduplication")
+	}
+}
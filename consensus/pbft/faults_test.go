@@ -0,0 +1,82 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"testing"
+)
+
+// TestFaultScenarios runs every scenario under testdata/faults against a
+// pbft network and checks both the liveness expectations of the scenario
+// and that no two replicas diverged in what they executed
+func TestFaultScenarios(t *testing.T) {
+	scenarios, err := loadFaultScenarios("testdata/faults/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatalf("No fault scenarios found")
+	}
+
+	for _, scenario := range scenarios {
+		runFaultScenario(t, scenario)
+	}
+}
+
+func runFaultScenario(t *testing.T, scenario *faultScenario) {
+	t.Logf("Running fault scenario %s: %s", scenario.Name, scenario.Description)
+
+	config := loadConfig()
+	for key, value := range scenario.Config {
+		config.Set(key, value)
+	}
+	net := makePBFTNetwork(scenario.Replicas, config)
+	defer net.stop()
+	injector := newFaultInjector(net.testnet, scenario.Faults)
+
+	for n := 1; n <= scenario.Requests; n++ {
+		reqBatch := createPbftReqBatch(int64(n), uint64(generateBroadcaster(scenario.Replicas)))
+		for _, pe := range net.pbftEndpoints {
+			if containsInt(scenario.SubmitTo, int(pe.id)) {
+				pe.manager.Queue() <- reqBatch
+			}
+		}
+		net.process()
+		injector.wait()
+		net.process()
+	}
+
+	for _, pe := range net.pbftEndpoints {
+		if !containsInt(scenario.Expect.Replicas, int(pe.id)) {
+			continue
+		}
+		if pe.sc.executions != scenario.Expect.Executions {
+			t.Errorf("Scenario %s: replica %d expected %d executions, got %d", scenario.Name, pe.id, scenario.Expect.Executions, pe.sc.executions)
+		}
+		if pe.pbft.view < scenario.Expect.View {
+			t.Errorf("Scenario %s: replica %d expected to reach view %d, is in view %d", scenario.Name, pe.id, scenario.Expect.View, pe.pbft.view)
+		}
+	}
+
+	for _, pe := range net.pbftEndpoints {
+		for _, other := range net.pbftEndpoints {
+			if pe.sc.executions == other.sc.executions && pe.sc.lastExecution != other.sc.lastExecution {
+				t.Errorf("Scenario %s: replicas %d and %d diverged after %d executions", scenario.Name, pe.id, other.id, pe.sc.executions)
+			}
+		}
+	}
+}
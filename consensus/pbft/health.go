@@ -0,0 +1,164 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/consensus/util/events"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Conditions reported when the network is unhealthy
+const (
+	healthQuorumLost        = "QUORUM_LOST"
+	healthViewChangeFailing = "VIEW_CHANGE_FAILING"
+)
+
+// healthCheckEvent is sent when the health check timer expires
+type healthCheckEvent struct{}
+
+// healthMonitor periodically assesses whether the replica is partitioned
+// from the rest of the network, either because it is connected to fewer
+// replicas than a quorum, or because its view changes keep failing
+type healthMonitor struct {
+	interval       time.Duration // how often to check, 0 disables the monitor
+	quorumPeriod   time.Duration // how long the quorum may be lost before reporting it
+	maxViewChanges uint64        // consecutive failed view changes to report, 0 disables the check
+	timer          events.Timer
+
+	quorumLostSince time.Time // zero while a quorum is connected
+
+	lock   sync.Mutex
+	health *pb.ConsensusHealth
+}
+
+func newHealthMonitor(config *viper.Viper, etf events.TimerFactory) *healthMonitor {
+	var err error
+	hm := &healthMonitor{
+		timer:  etf.CreateTimer(),
+		health: &pb.ConsensusHealth{Healthy: true},
+	}
+	if hm.interval, err = time.ParseDuration(config.GetString("general.health.interval")); err != nil {
+		panic(fmt.Errorf("Cannot parse health check interval: %s", err))
+	}
+	if hm.quorumPeriod, err = time.ParseDuration(config.GetString("general.health.quorum")); err != nil {
+		panic(fmt.Errorf("Cannot parse health quorum period: %s", err))
+	}
+	viewChanges := config.GetInt("general.health.viewchanges")
+	if viewChanges < 0 {
+		panic(fmt.Errorf("Health view change threshold must not be negative, got %d", viewChanges))
+	}
+	hm.maxViewChanges = uint64(viewChanges)
+	return hm
+}
+
+func (hm *healthMonitor) start() {
+	if hm.interval > 0 {
+		hm.timer.Reset(hm.interval, healthCheckEvent{})
+	}
+}
+
+// Health returns the most recently assessed health of the network along
+// with the current load of the replica, it implements consensus.HealthReporter
+func (op *obcBatch) Health() *pb.ConsensusHealth {
+	op.health.lock.Lock()
+	health := proto.Clone(op.health.health).(*pb.ConsensusHealth)
+	op.health.lock.Unlock()
+
+	health.Outstanding = uint64(op.outstandingRequests())
+	health.Saturated = op.saturated()
+	return health
+}
+
+// checkHealth assesses the health of the network as of now and reports
+// any change to the stack
+func (op *obcBatch) checkHealth(now time.Time) {
+	hm := op.health
+	health := &pb.ConsensusHealth{
+		Healthy:      true,
+		View:         op.pbft.view,
+		LowWatermark: op.pbft.h,
+		Window:       op.pbft.L,
+	}
+
+	connected, err := op.connectedReplicas()
+	if err != nil {
+		logger.Warningf("Replica %d could not determine connected replicas: %s", op.pbft.id, err)
+		connected = op.pbft.N
+	}
+	quorum := op.pbft.intersectionQuorum()
+	if connected < quorum {
+		if hm.quorumLostSince.IsZero() {
+			hm.quorumLostSince = now
+		}
+		if now.Sub(hm.quorumLostSince) >= hm.quorumPeriod {
+			health.Healthy = false
+			health.Condition = healthQuorumLost
+			health.Detail = fmt.Sprintf("connected to %d of %d replicas since %s, %d needed for quorum",
+				connected, op.pbft.N, hm.quorumLostSince.Format(time.RFC3339), quorum)
+		}
+	} else {
+		hm.quorumLostSince = time.Time{}
+	}
+
+	if health.Healthy && hm.maxViewChanges > 0 && op.pbft.failedViewChanges >= hm.maxViewChanges {
+		health.Healthy = false
+		health.Condition = healthViewChangeFailing
+		health.Detail = fmt.Sprintf("%d consecutive view changes failed, trying to move to view %d",
+			op.pbft.failedViewChanges, op.pbft.view)
+	}
+
+	hm.lock.Lock()
+	changed := health.Healthy != hm.health.Healthy || health.Condition != hm.health.Condition
+	hm.health = health
+	hm.lock.Unlock()
+
+	if !changed {
+		return
+	}
+	if health.Healthy {
+		logger.Infof("Replica %d considers the network healthy again in view %d", op.pbft.id, health.View)
+	} else {
+		logger.Warningf("Replica %d considers the network unhealthy (%s): %s", op.pbft.id, health.Condition, health.Detail)
+	}
+	if observer, ok := op.stack.(consensus.HealthObserver); ok {
+		observer.HealthChanged(proto.Clone(health).(*pb.ConsensusHealth))
+	}
+}
+
+// connectedReplicas returns the number of replicas, including this one,
+// which the replica currently has a connection to
+func (op *obcBatch) connectedReplicas() (int, error) {
+	_, network, err := op.stack.GetNetworkHandles()
+	if err != nil {
+		return 0, err
+	}
+	connected := 1
+	for _, handle := range network {
+		if _, err := getValidatorID(handle); err == nil {
+			connected++
+		}
+	}
+	return connected, nil
+}
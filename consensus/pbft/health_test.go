@@ -0,0 +1,121 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// healthObserverStack records the health changes reported by the consenter
+type healthObserverStack struct {
+	*omniProto
+	changes []*pb.ConsensusHealth
+}
+
+func (hs *healthObserverStack) HealthChanged(health *pb.ConsensusHealth) {
+	hs.changes = append(hs.changes, health)
+}
+
+func newHealthTestBatch(connected *int) (*obcBatch, *healthObserverStack) {
+	config := loadConfig()
+	config.Set("general.health.interval", "0s")
+	config.Set("general.health.quorum", "30s")
+	config.Set("general.health.viewchanges", "3")
+	stack := &healthObserverStack{omniProto: &omniProto{
+		GetNetworkHandlesImpl: func() (*pb.PeerID, []*pb.PeerID, error) {
+			var network []*pb.PeerID
+			for i := 1; i < *connected; i++ {
+				network = append(network, &pb.PeerID{Name: fmt.Sprintf("vp%d", i)})
+			}
+			return &pb.PeerID{Name: "vp0"}, network, nil
+		},
+	}}
+	return newObcBatch(0, config, stack), stack
+}
+
+func TestHealthQuorumLost(t *testing.T) {
+	connected := 2
+	b, stack := newHealthTestBatch(&connected)
+	defer b.Close()
+
+	start := time.Now()
+	b.checkHealth(start)
+	if !b.Health().Healthy {
+		t.Fatalf("Expected a brief loss of quorum to be tolerated")
+	}
+
+	b.checkHealth(start.Add(31 * time.Second))
+	health := b.Health()
+	if health.Healthy || health.Condition != healthQuorumLost {
+		t.Fatalf("Expected %s after a prolonged loss of quorum, got %v", healthQuorumLost, health)
+	}
+	if len(stack.changes) != 1 || stack.changes[0].Condition != healthQuorumLost {
+		t.Fatalf("Expected the stack to be notified once, got %v", stack.changes)
+	}
+
+	b.checkHealth(start.Add(40 * time.Second))
+	if len(stack.changes) != 1 {
+		t.Errorf("Expected no notification while the condition persists, got %d", len(stack.changes))
+	}
+
+	connected = 3
+	b.checkHealth(start.Add(45 * time.Second))
+	if !b.Health().Healthy {
+		t.Errorf("Expected the network to be healthy once a quorum is connected again")
+	}
+	if len(stack.changes) != 2 || !stack.changes[1].Healthy {
+		t.Errorf("Expected the stack to be notified of the recovery, got %v", stack.changes)
+	}
+}
+
+func TestHealthViewChangeFailing(t *testing.T) {
+	connected := 4
+	b, stack := newHealthTestBatch(&connected)
+	defer b.Close()
+
+	b.pbft.failedViewChanges = 2
+	b.checkHealth(time.Now())
+	if !b.Health().Healthy {
+		t.Fatalf("Expected the network to be healthy below the view change threshold")
+	}
+
+	b.pbft.failedViewChanges = 3
+	b.checkHealth(time.Now())
+	health := b.Health()
+	if health.Healthy || health.Condition != healthViewChangeFailing {
+		t.Fatalf("Expected %s after repeated view change failures, got %v", healthViewChangeFailing, health)
+	}
+	if len(stack.changes) != 1 {
+		t.Errorf("Expected the stack to be notified once, got %d notifications", len(stack.changes))
+	}
+}
+
+func TestHealthReportsWatermarkWindow(t *testing.T) {
+	connected := 4
+	b, _ := newHealthTestBatch(&connected)
+	defer b.Close()
+
+	b.checkHealth(time.Now())
+	health := b.Health()
+	if health.LowWatermark != b.pbft.h || health.Window != b.pbft.L {
+		t.Errorf("Expected watermark %d and window %d, got %d and %d", b.pbft.h, b.pbft.L, health.LowWatermark, health.Window)
+	}
+}
@@ -22,6 +22,7 @@ It has these top-level messages:
 	FetchRequestBatch
 	RequestBatch
 	BatchMessage
+	Departure
 	Metadata
 */
 package pbft
@@ -520,6 +521,7 @@ type BatchMessage struct {
 	//	*BatchMessage_RequestBatch
 	//	*BatchMessage_PbftMessage
 	//	*BatchMessage_Complaint
+	//	*BatchMessage_Departure
 	Payload isBatchMessage_Payload `protobuf_oneof:"payload"`
 }
 
@@ -543,11 +545,15 @@ type BatchMessage_PbftMessage struct {
 type BatchMessage_Complaint struct {
 	Complaint *Request `protobuf:"bytes,4,opt,name=complaint,oneof"`
 }
+type BatchMessage_Departure struct {
+	Departure *Departure `protobuf:"bytes,5,opt,name=departure,oneof"`
+}
 
 func (*BatchMessage_Request) isBatchMessage_Payload()      {}
 func (*BatchMessage_RequestBatch) isBatchMessage_Payload() {}
 func (*BatchMessage_PbftMessage) isBatchMessage_Payload()  {}
 func (*BatchMessage_Complaint) isBatchMessage_Payload()    {}
+func (*BatchMessage_Departure) isBatchMessage_Payload()    {}
 
 func (m *BatchMessage) GetPayload() isBatchMessage_Payload {
 	if m != nil {
@@ -584,6 +590,13 @@ func (m *BatchMessage) GetComplaint() *Request {
 	return nil
 }
 
+func (m *BatchMessage) GetDeparture() *Departure {
+	if x, ok := m.GetPayload().(*BatchMessage_Departure); ok {
+		return x.Departure
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*BatchMessage) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), []interface{}) {
 	return _BatchMessage_OneofMarshaler, _BatchMessage_OneofUnmarshaler, []interface{}{
@@ -591,6 +604,7 @@ func (*BatchMessage) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer)
 		(*BatchMessage_RequestBatch)(nil),
 		(*BatchMessage_PbftMessage)(nil),
 		(*BatchMessage_Complaint)(nil),
+		(*BatchMessage_Departure)(nil),
 	}
 }
 
@@ -616,6 +630,11 @@ func _BatchMessage_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
 		if err := b.EncodeMessage(x.Complaint); err != nil {
 			return err
 		}
+	case *BatchMessage_Departure:
+		b.EncodeVarint(5<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Departure); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("BatchMessage.Payload has unexpected type %T", x)
@@ -657,11 +676,28 @@ func _BatchMessage_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.B
 		err := b.DecodeMessage(msg)
 		m.Payload = &BatchMessage_Complaint{msg}
 		return true, err
+	case 5: // payload.departure
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Departure)
+		err := b.DecodeMessage(msg)
+		m.Payload = &BatchMessage_Departure{msg}
+		return true, err
 	default:
 		return false, nil
 	}
 }
 
+type Departure struct {
+	View      uint64 `protobuf:"varint,1,opt,name=view" json:"view,omitempty"`
+	ReplicaId uint64 `protobuf:"varint,2,opt,name=replica_id" json:"replica_id,omitempty"`
+}
+
+func (m *Departure) Reset()         { *m = Departure{} }
+func (m *Departure) String() string { return proto.CompactTextString(m) }
+func (*Departure) ProtoMessage()    {}
+
 type Metadata struct {
 	SeqNo uint64 `protobuf:"varint,1,opt,name=seqNo" json:"seqNo,omitempty"`
 }
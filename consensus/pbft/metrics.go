@@ -0,0 +1,30 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"github.com/hyperledger/fabric/core/metrics"
+)
+
+var (
+	outstandingRequestsGauge = metrics.NewGauge("fabric_consensus_pbft_outstanding_requests",
+		"Requests received by the replica and not yet executed.")
+	saturatedRejections = metrics.NewCounter("fabric_consensus_pbft_saturated_rejections_total",
+		"Client transactions rejected while the replica was saturated with outstanding requests.")
+	viewChanges = metrics.NewCounter("fabric_consensus_pbft_view_changes_total",
+		"View changes started by the replica.")
+)
@@ -0,0 +1,249 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+)
+
+// Fault actions understood by the fault injector
+const (
+	faultDrop      = "drop"
+	faultDelay     = "delay"
+	faultDuplicate = "duplicate"
+	faultCorrupt   = "corrupt"
+)
+
+// faultRule describes one fault, applied to every matching message until
+// its count is exhausted
+type faultRule struct {
+	From   []int    // sending replicas, empty for all
+	To     []int    // receiving replicas, empty for all
+	Types  []string // message types as named in messages.proto, empty for all
+	Action string
+	Delay  string // only used by the delay action
+	Count  int    // number of messages to affect, 0 for unlimited
+
+	delay   time.Duration
+	applied int
+}
+
+// faultExpectation is checked once all requests of a scenario were processed
+type faultExpectation struct {
+	Replicas   []int // replicas the expectation applies to, empty for all
+	Executions uint64
+	View       uint64 // minimum view the replicas must have reached
+}
+
+// faultScenario is the in-memory form of a scenario file under testdata/faults
+type faultScenario struct {
+	Name        string
+	Description string
+	Replicas    int
+	Config      map[string]string
+	SubmitTo    []int `mapstructure:"submitto"`
+	Requests    int
+	Faults      []*faultRule
+	Expect      faultExpectation
+}
+
+// loadFaultScenarios reads all scenario files matching the given glob
+func loadFaultScenarios(pattern string) ([]*faultScenario, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var scenarios []*faultScenario
+	for _, file := range files {
+		v := viper.New()
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("Error reading fault scenario %s: %s", file, err)
+		}
+		scenario := &faultScenario{}
+		if err := v.Unmarshal(scenario); err != nil {
+			return nil, fmt.Errorf("Error parsing fault scenario %s: %s", file, err)
+		}
+		if scenario.Name == "" {
+			scenario.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+		for i, rule := range scenario.Faults {
+			switch rule.Action {
+			case faultDrop, faultDuplicate, faultCorrupt:
+			case faultDelay:
+				if rule.delay, err = time.ParseDuration(rule.Delay); err != nil {
+					return nil, fmt.Errorf("Fault %d of scenario %s has an invalid delay: %s", i, scenario.Name, err)
+				}
+			default:
+				return nil, fmt.Errorf("Fault %d of scenario %s has an unknown action: %s", i, scenario.Name, rule.Action)
+			}
+		}
+		scenarios = append(scenarios, scenario)
+	}
+	return scenarios, nil
+}
+
+// faultInjector drops, delays, duplicates and corrupts PBFT messages
+// travelling through a testnet, it is installed as the testnet filterFn
+type faultInjector struct {
+	lock     sync.Mutex
+	net      *testnet
+	rules    []*faultRule
+	inFlight sync.WaitGroup // delayed and duplicated messages not yet delivered
+}
+
+func newFaultInjector(net *testnet, rules []*faultRule) *faultInjector {
+	fi := &faultInjector{
+		net:   net,
+		rules: rules,
+	}
+	net.filterFn = fi.filter
+	return fi
+}
+
+// wait blocks until all delayed and duplicated messages were delivered
+func (fi *faultInjector) wait() {
+	fi.inFlight.Wait()
+}
+
+func (fi *faultInjector) filter(src int, dst int, payload []byte) []byte {
+	if dst == -1 {
+		// Faults are applied per receiver, when the broadcast is delivered
+		return payload
+	}
+
+	msg := &Message{}
+	if err := proto.Unmarshal(payload, msg); err != nil {
+		return payload
+	}
+	msgType := messageTypeName(msg)
+
+	fi.lock.Lock()
+	rule := fi.match(src, dst, msgType)
+	fi.lock.Unlock()
+	if rule == nil {
+		return payload
+	}
+
+	logger.Infof("Fault injector applying %s to %s from %d to %d", rule.Action, msgType, src, dst)
+	switch rule.Action {
+	case faultDrop:
+		return nil
+	case faultDelay:
+		fi.deliverLater(src, dst, payload, rule.delay)
+		return nil
+	case faultDuplicate:
+		fi.deliverLater(src, dst, payload, 0)
+		return payload
+	case faultCorrupt:
+		corruptMessage(msg)
+		corrupted, _ := proto.Marshal(msg)
+		return corrupted
+	}
+	return payload
+}
+
+// match returns the first rule applying to the message and records its use
+func (fi *faultInjector) match(src int, dst int, msgType string) *faultRule {
+	for _, rule := range fi.rules {
+		if rule.Count > 0 && rule.applied >= rule.Count {
+			continue
+		}
+		if !containsInt(rule.From, src) || !containsInt(rule.To, dst) || !containsString(rule.Types, msgType) {
+			continue
+		}
+		rule.applied++
+		return rule
+	}
+	return nil
+}
+
+func (fi *faultInjector) deliverLater(src int, dst int, payload []byte, delay time.Duration) {
+	senderHandle := fi.net.endpoints[src].getHandle()
+	fi.inFlight.Add(1)
+	go func() {
+		defer fi.inFlight.Done()
+		time.Sleep(delay)
+		select {
+		case <-fi.net.closed:
+			return
+		default:
+		}
+		fi.net.endpoints[dst].deliver(payload, senderHandle)
+	}()
+}
+
+// corruptMessage alters the message the way a byzantine replica would,
+// keeping it well formed so it passes unmarshaling on the receiver
+func corruptMessage(msg *Message) {
+	switch payload := msg.Payload.(type) {
+	case *Message_RequestBatch:
+		payload.RequestBatch.Batch = nil
+	case *Message_PrePrepare:
+		payload.PrePrepare.BatchDigest = corruptDigest(payload.PrePrepare.BatchDigest)
+	case *Message_Prepare:
+		payload.Prepare.BatchDigest = corruptDigest(payload.Prepare.BatchDigest)
+	case *Message_Commit:
+		payload.Commit.BatchDigest = corruptDigest(payload.Commit.BatchDigest)
+	case *Message_Checkpoint:
+		payload.Checkpoint.Id = corruptDigest(payload.Checkpoint.Id)
+	case *Message_ViewChange:
+		payload.ViewChange.Cset = nil
+	case *Message_NewView:
+		payload.NewView.Xset = nil
+	case *Message_FetchRequestBatch:
+		payload.FetchRequestBatch.BatchDigest = corruptDigest(payload.FetchRequestBatch.BatchDigest)
+	case *Message_ReturnRequestBatch:
+		payload.ReturnRequestBatch.Batch = nil
+	}
+}
+
+func corruptDigest(digest string) string {
+	return "corrupt" + digest
+}
+
+func containsInt(set []int, v int) bool {
+	if len(set) == 0 {
+		return true
+	}
+	for _, s := range set {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(set []string, v string) bool {
+	if len(set) == 0 {
+		return true
+	}
+	for _, s := range set {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
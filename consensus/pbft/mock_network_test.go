@@ -197,7 +197,7 @@ func (net *testnet) deliverFilter(msg taggedMsg) {
 		}
 		if payload != nil {
 			net.debugMsg("TEST: Sending unicast\n")
-			net.endpoints[msg.dst].deliver(msg.msg, senderHandle)
+			net.endpoints[msg.dst].deliver(payload, senderHandle)
 		}
 	}
 }
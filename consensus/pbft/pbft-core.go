@@ -162,6 +162,14 @@ type pbftCore struct {
 	viewChangePeriod   uint64        // period between automatic view changes
 	viewChangeSeqNo    uint64        // next seqNo to perform view change
 
+	pipelineDepth   uint64 // max. number of pre-prepared but unexecuted batches of the primary
+	pipelineBlocked bool   // set when a batch was held back because the pipeline was full
+
+	failedViewChanges uint64 // number of consecutive view changes which timed out without a new view
+
+	primaryPolicy   string   // how the primary of a view is selected
+	primarySchedule []uint64 // cyclic primary order for the weighted policy, indexed by view
+
 	missingReqBatches map[string]bool // for all the assigned, non-checkpointed request batches we might be missing during view-change
 
 	// implementation of PBFT `in`
@@ -235,7 +243,15 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, etf events
 		panic("Log multiplier must be greater than or equal to 2")
 	}
 	instance.L = instance.logMultiplier * instance.K // log size
+	instance.pipelineDepth = uint64(config.GetInt("general.pipelinedepth"))
+	if instance.pipelineDepth == 0 {
+		instance.pipelineDepth = instance.L / 2
+	} else if instance.pipelineDepth > instance.L/2 {
+		logger.Warningf("Configured pipeline depth %d exceeds half the log size, limiting to %d", instance.pipelineDepth, instance.L/2)
+		instance.pipelineDepth = instance.L / 2
+	}
 	instance.viewChangePeriod = uint64(config.GetInt("general.viewchangeperiod"))
+	instance.configurePrimaryPolicy(config)
 
 	instance.byzantine = config.GetBool("general.byzantine")
 
@@ -268,11 +284,16 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, etf events
 	logger.Infof("PBFT Checkpoint period (K) = %v", instance.K)
 	logger.Infof("PBFT Log multiplier = %v", instance.logMultiplier)
 	logger.Infof("PBFT log size (L) = %v", instance.L)
+	logger.Infof("PBFT pipeline depth = %v", instance.pipelineDepth)
 	if instance.nullRequestTimeout > 0 {
 		logger.Infof("PBFT null requests timeout = %v", instance.nullRequestTimeout)
 	} else {
 		logger.Infof("PBFT null requests disabled")
 	}
+	logger.Infof("PBFT primary policy = %v", instance.primaryPolicy)
+	if len(instance.primarySchedule) > 0 {
+		logger.Infof("PBFT primary schedule = %v", instance.primarySchedule)
+	}
 	if instance.viewChangePeriod > 0 {
 		logger.Infof("PBFT view change period = %v", instance.viewChangePeriod)
 	} else {
@@ -320,6 +341,9 @@ func (instance *pbftCore) ProcessEvent(e events.Event) events.Event {
 	case viewChangeTimerEvent:
 		logger.Infof("Replica %d view change timer expired, sending view change: %s", instance.id, instance.newViewTimerReason)
 		instance.timerActive = false
+		if !instance.activeView {
+			instance.failedViewChanges++
+		}
 		instance.sendViewChange()
 	case *pbftMessage:
 		return pbftMessageEvent(*et)
@@ -420,6 +444,9 @@ func (instance *pbftCore) ProcessEvent(e events.Event) events.Event {
 
 // Given a certain view n, what is the expected primary?
 func (instance *pbftCore) primary(n uint64) uint64 {
+	if len(instance.primarySchedule) > 0 {
+		return instance.primarySchedule[n%uint64(len(instance.primarySchedule))]
+	}
 	return n % uint64(instance.replicaCount)
 }
 
@@ -644,6 +671,12 @@ func (instance *pbftCore) sendPrePrepare(reqBatch *RequestBatch, digest string)
 		return
 	}
 
+	if n > instance.lastExec+instance.pipelineDepth {
+		logger.Debugf("Replica %d is primary, not sending pre-prepare for request batch %s because %d batches are in flight", instance.id, digest, instance.seqNo-instance.lastExec)
+		instance.pipelineBlocked = true
+		return
+	}
+
 	logger.Debugf("Primary %d broadcasting pre-prepare for view=%d/seqNo=%d and digest %s", instance.id, instance.view, n, digest)
 	instance.seqNo = n
 	preprep := &PrePrepare{
@@ -998,6 +1031,12 @@ func (instance *pbftCore) execDoneSync() {
 	instance.currentExec = nil
 
 	instance.executeOutstanding()
+
+	if instance.pipelineBlocked {
+		// Room was made in the pipeline, pre-prepare the batches held back
+		instance.pipelineBlocked = false
+		instance.resubmitRequestBatches()
+	}
 }
 
 func (instance *pbftCore) moveWatermarks(n uint64) {
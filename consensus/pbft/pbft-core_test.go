@@ -1602,3 +1602,49 @@ func TestCheckpointDiffersFromQuorum(t *testing.T) {
 		t.Fatalf("Replica should have invalidated its state and skipped")
 	}
 }
+
+func TestPipelineDepthHoldsBackPrePrepares(t *testing.T) {
+	config := loadConfig()
+	config.Set("general.pipelinedepth", 1)
+	prePrepares := 0
+	instance := newPbftCore(0, config, &omniProto{
+		broadcastImpl: func(msgPayload []byte) {
+			msg := &Message{}
+			if err := proto.Unmarshal(msgPayload, msg); err == nil && msg.GetPrePrepare() != nil {
+				prePrepares++
+			}
+		},
+	}, &inertTimerFactory{})
+	defer instance.close()
+
+	instance.recvRequestBatch(createPbftReqBatch(1, 0))
+	instance.recvRequestBatch(createPbftReqBatch(2, 0))
+	if prePrepares != 1 || instance.seqNo != 1 {
+		t.Fatalf("Expected a single pre-prepare with a pipeline depth of 1, got %d (seqNo %d)", prePrepares, instance.seqNo)
+	}
+
+	executed := uint64(1)
+	instance.currentExec = &executed
+	instance.execDoneSync()
+	if prePrepares != 2 || instance.seqNo != 2 {
+		t.Fatalf("Expected the held back batch to be pre-prepared after execution, got %d pre-prepares (seqNo %d)", prePrepares, instance.seqNo)
+	}
+}
+
+func TestPipelineDepthNetwork(t *testing.T) {
+	config := loadConfig()
+	config.Set("general.pipelinedepth", 2)
+	net := makePBFTNetwork(4, config)
+	defer net.stop()
+
+	for n := int64(1); n <= 5; n++ {
+		net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(n, 0)
+	}
+	net.process()
+
+	for _, pep := range net.pbftEndpoints {
+		if pep.sc.executions != 5 {
+			t.Errorf("Instance %d executed %d request batches, expected 5", pep.id, pep.sc.executions)
+		}
+	}
+}
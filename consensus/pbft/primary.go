@@ -0,0 +1,118 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Primary selection policies, see general.primary.policy in config.yaml
+const (
+	primaryPolicyRoundRobin = "roundrobin"
+	primaryPolicyWeighted   = "weighted"
+	primaryPolicySticky     = "sticky"
+)
+
+// configurePrimaryPolicy reads the primary selection policy from the config
+// and prepares the pbftCore instance to honor it. Every replica must be
+// configured identically, as the primary of a view is derived locally.
+func (instance *pbftCore) configurePrimaryPolicy(config *viper.Viper) {
+	instance.primaryPolicy = strings.ToLower(config.GetString("general.primary.policy"))
+	switch instance.primaryPolicy {
+	case "":
+		instance.primaryPolicy = primaryPolicyRoundRobin
+	case primaryPolicyRoundRobin:
+	case primaryPolicyWeighted:
+		weights, err := parsePrimaryWeights(config.GetStringSlice("general.primary.weights"), instance.N)
+		if err != nil {
+			panic(fmt.Errorf("Cannot parse primary weights: %s", err))
+		}
+		instance.primarySchedule, err = weightedPrimarySchedule(weights)
+		if err != nil {
+			panic(fmt.Errorf("Cannot build weighted primary schedule: %s", err))
+		}
+	case primaryPolicySticky:
+		if instance.viewChangePeriod > 0 {
+			logger.Warningf("PBFT primary policy is %s, ignoring configured view change period of %d", primaryPolicySticky, instance.viewChangePeriod)
+			instance.viewChangePeriod = 0
+		}
+	default:
+		panic(fmt.Errorf("Invalid PBFT primary policy: %s", instance.primaryPolicy))
+	}
+}
+
+// parsePrimaryWeights converts the configured weights, one per replica, to integers
+func parsePrimaryWeights(raw []string, N int) ([]int, error) {
+	if len(raw) != N {
+		return nil, fmt.Errorf("expected %d weights, one per replica, but got %d", N, len(raw))
+	}
+	weights := make([]int, N)
+	for i, w := range raw {
+		weight, err := strconv.Atoi(w)
+		if err != nil {
+			return nil, fmt.Errorf("weight of replica %d is not an integer: %s", i, w)
+		}
+		if weight < 1 {
+			return nil, fmt.Errorf("weight of replica %d must be at least 1, got %d", i, weight)
+		}
+		weights[i] = weight
+	}
+	return weights, nil
+}
+
+// weightedPrimarySchedule builds a cyclic sequence of replica IDs in which
+// each replica appears as often as its weight. The schedule never lists the
+// same replica twice in a row (including the wrap-around), so a view change
+// away from a faulty primary always selects a different replica.
+func weightedPrimarySchedule(weights []int) ([]uint64, error) {
+	total := 0
+	remaining := make([]int, len(weights))
+	for i, w := range weights {
+		remaining[i] = w
+		total += w
+	}
+
+	schedule := make([]uint64, 0, total)
+	prev := -1
+	for len(schedule) < total {
+		next := -1
+		for i, r := range remaining {
+			if r == 0 || i == prev {
+				continue
+			}
+			if next == -1 || r > remaining[next] {
+				next = i
+			}
+		}
+		if next == -1 {
+			return nil, fmt.Errorf("replica %d holds more than half of the total weight", prev)
+		}
+		schedule = append(schedule, uint64(next))
+		remaining[next]--
+		prev = next
+	}
+
+	if len(schedule) > 1 && schedule[0] == schedule[len(schedule)-1] {
+		return nil, fmt.Errorf("replica %d holds more than half of the total weight", schedule[0])
+	}
+
+	return schedule, nil
+}
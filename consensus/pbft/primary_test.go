@@ -0,0 +1,97 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWeightedPrimarySchedule(t *testing.T) {
+	schedule, err := weightedPrimarySchedule([]int{3, 1, 1, 1})
+	if err != nil {
+		t.Fatalf("Unexpected error building schedule: %s", err)
+	}
+	expected := []uint64{0, 1, 0, 2, 0, 3}
+	if !reflect.DeepEqual(schedule, expected) {
+		t.Fatalf("Expected schedule %v, got %v", expected, schedule)
+	}
+
+	for i := range schedule {
+		if schedule[i] == schedule[(i+1)%len(schedule)] {
+			t.Errorf("Replica %d is primary for two consecutive views", schedule[i])
+		}
+	}
+}
+
+func TestWeightedPrimaryScheduleDominantReplica(t *testing.T) {
+	if _, err := weightedPrimarySchedule([]int{4, 1, 1, 1}); err == nil {
+		t.Fatalf("Expected an error when one replica holds more than half of the weight")
+	}
+}
+
+func TestParsePrimaryWeights(t *testing.T) {
+	if _, err := parsePrimaryWeights([]string{"1", "1", "1"}, 4); err == nil {
+		t.Errorf("Expected an error when the number of weights does not match N")
+	}
+	if _, err := parsePrimaryWeights([]string{"1", "0", "1", "1"}, 4); err == nil {
+		t.Errorf("Expected an error for a zero weight")
+	}
+	if _, err := parsePrimaryWeights([]string{"1", "x", "1", "1"}, 4); err == nil {
+		t.Errorf("Expected an error for a non-integer weight")
+	}
+	weights, err := parsePrimaryWeights([]string{"2", "1", "1", "1"}, 4)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing weights: %s", err)
+	}
+	if !reflect.DeepEqual(weights, []int{2, 1, 1, 1}) {
+		t.Errorf("Unexpected weights: %v", weights)
+	}
+}
+
+func TestWeightedPrimaryPolicy(t *testing.T) {
+	config := loadConfig()
+	config.Set("general.primary.policy", "weighted")
+	config.Set("general.primary.weights", []string{"2", "1", "1", "1"})
+	instance := newPbftCore(0, config, &omniProto{}, &inertTimerFactory{})
+	defer instance.close()
+
+	expected := []uint64{0, 1, 0, 2, 3, 0, 1}
+	for view, primary := range expected {
+		if p := instance.primary(uint64(view)); p != primary {
+			t.Errorf("Expected replica %d to be primary of view %d, got %d", primary, view, p)
+		}
+	}
+}
+
+func TestStickyPrimaryPolicy(t *testing.T) {
+	config := loadConfig()
+	config.Set("general.primary.policy", "sticky")
+	config.Set("general.viewchangeperiod", "1")
+	instance := newPbftCore(0, config, &omniProto{}, &inertTimerFactory{})
+	defer instance.close()
+
+	if instance.viewChangePeriod != 0 {
+		t.Errorf("Expected sticky policy to disable the view change period, got %d", instance.viewChangePeriod)
+	}
+	if instance.viewChangeSeqNo != ^uint64(0) {
+		t.Errorf("Expected no automatic view change to be scheduled, got seqNo %d", instance.viewChangeSeqNo)
+	}
+	if instance.primary(1) != 1 {
+		t.Errorf("Expected replica 1 to be primary of view 1 after a failure, got %d", instance.primary(1))
+	}
+}
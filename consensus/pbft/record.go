@@ -0,0 +1,313 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/consensus/util/events"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Kinds of recorded entries. The first entry of a recording is always a
+// recordStart, event entries are replayed in order, the remaining kinds
+// record what the stack answered when the replica interrogated it.
+const (
+	recordStart = "start"
+
+	recordMessage               = "message"
+	recordExecuted              = "executed"
+	recordCommitted             = "committed"
+	recordRolledBack            = "rolledBack"
+	recordStateUpdated          = "stateUpdated"
+	recordBatchTimer            = "batchTimer"
+	recordViewChangeTimer       = "viewChangeTimer"
+	recordViewChangeResendTimer = "viewChangeResendTimer"
+	recordNullRequest           = "nullRequest"
+	recordHealthCheck           = "healthCheck"
+	recordShutdown              = "shutdown"
+
+	recordReadState          = "readState"
+	recordReadStateSet       = "readStateSet"
+	recordBlockchainInfoBlob = "blockchainInfoBlob"
+	recordBlockHeadMetadata  = "blockHeadMetadata"
+	recordBlockchainSize     = "blockchainSize"
+	recordBlock              = "block"
+	recordVerify             = "verify"
+)
+
+// recordEntry is one line of a recording, fields not needed by the kind are omitted
+type recordEntry struct {
+	Kind    string            `json:"kind"`
+	Time    time.Time         `json:"time"`
+	Replica uint64            `json:"replica,omitempty"`
+	Sender  string            `json:"sender,omitempty"`
+	Key     string            `json:"key,omitempty"`
+	Data    []byte            `json:"data,omitempty"` // raw value or marshaled protobuf message
+	Target  []byte            `json:"target,omitempty"`
+	SeqNo   uint64            `json:"seqNo,omitempty"`
+	Size    uint64            `json:"size,omitempty"`
+	Set     map[string][]byte `json:"set,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// recorder appends the inputs of a replica to a file, one JSON entry per line
+type recorder struct {
+	lock    sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newRecorder(path string, id uint64) (*recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	rec := &recorder{file: file, encoder: json.NewEncoder(file)}
+	rec.write(&recordEntry{Kind: recordStart, Replica: id})
+	return rec, nil
+}
+
+func (rec *recorder) write(entry *recordEntry) {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	if rec.encoder == nil {
+		return
+	}
+	entry.Time = time.Now()
+	if err := rec.encoder.Encode(entry); err != nil {
+		logger.Errorf("Could not write to the consensus recording, recording stopped: %s", err)
+		rec.encoder = nil
+	}
+}
+
+// recordEvent is passed to the event manager, it records every event the
+// replica receives from outside of its event thread
+func (rec *recorder) recordEvent(event events.Event) {
+	entry, err := encodeEvent(event)
+	if err != nil {
+		logger.Warningf("Not recording event: %s", err)
+		return
+	}
+	rec.write(entry)
+}
+
+func (rec *recorder) close() {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	rec.encoder = nil
+	rec.file.Close()
+}
+
+func encodeEvent(event events.Event) (*recordEntry, error) {
+	switch et := event.(type) {
+	case batchMessageEvent:
+		data, err := proto.Marshal(et.msg)
+		if err != nil {
+			return nil, err
+		}
+		return &recordEntry{Kind: recordMessage, Sender: et.sender.Name, Data: data}, nil
+	case executedEvent:
+		tag, _ := et.tag.([]byte)
+		return &recordEntry{Kind: recordExecuted, Data: tag}, nil
+	case committedEvent:
+		tag, _ := et.tag.([]byte)
+		target, err := marshalBlockchainInfo(et.target)
+		if err != nil {
+			return nil, err
+		}
+		return &recordEntry{Kind: recordCommitted, Data: tag, Target: target}, nil
+	case rolledBackEvent:
+		return &recordEntry{Kind: recordRolledBack}, nil
+	case stateUpdatedEvent:
+		target, err := marshalBlockchainInfo(et.target)
+		if err != nil {
+			return nil, err
+		}
+		return &recordEntry{Kind: recordStateUpdated, SeqNo: et.chkpt.seqNo, Data: et.chkpt.id, Target: target}, nil
+	case batchTimerEvent:
+		return &recordEntry{Kind: recordBatchTimer}, nil
+	case viewChangeTimerEvent:
+		return &recordEntry{Kind: recordViewChangeTimer}, nil
+	case viewChangeResendTimerEvent:
+		return &recordEntry{Kind: recordViewChangeResendTimer}, nil
+	case nullRequestEvent:
+		return &recordEntry{Kind: recordNullRequest}, nil
+	case healthCheckEvent:
+		return &recordEntry{Kind: recordHealthCheck}, nil
+	case shutdownEvent:
+		return &recordEntry{Kind: recordShutdown}, nil
+	}
+	return nil, fmt.Errorf("unknown event type %T", event)
+}
+
+// decodeEvent reverses encodeEvent, it returns nil for entries which are not events
+func decodeEvent(entry *recordEntry) (events.Event, error) {
+	switch entry.Kind {
+	case recordMessage:
+		msg := &pb.Message{}
+		if err := proto.Unmarshal(entry.Data, msg); err != nil {
+			return nil, err
+		}
+		return batchMessageEvent{msg: msg, sender: &pb.PeerID{Name: entry.Sender}}, nil
+	case recordExecuted:
+		return executedEvent{tag: entry.Data}, nil
+	case recordCommitted:
+		target, err := unmarshalBlockchainInfo(entry.Target)
+		if err != nil {
+			return nil, err
+		}
+		var tag interface{}
+		if entry.Data != nil {
+			tag = entry.Data
+		}
+		return committedEvent{tag: tag, target: target}, nil
+	case recordRolledBack:
+		return rolledBackEvent{}, nil
+	case recordStateUpdated:
+		target, err := unmarshalBlockchainInfo(entry.Target)
+		if err != nil {
+			return nil, err
+		}
+		return stateUpdatedEvent{chkpt: &checkpointMessage{seqNo: entry.SeqNo, id: entry.Data}, target: target}, nil
+	case recordBatchTimer:
+		return batchTimerEvent{}, nil
+	case recordViewChangeTimer:
+		return viewChangeTimerEvent{}, nil
+	case recordViewChangeResendTimer:
+		return viewChangeResendTimerEvent{}, nil
+	case recordNullRequest:
+		return nullRequestEvent{}, nil
+	case recordHealthCheck:
+		return healthCheckEvent{}, nil
+	case recordShutdown:
+		return shutdownEvent{done: make(chan struct{})}, nil
+	}
+	return nil, nil
+}
+
+func marshalBlockchainInfo(info *pb.BlockchainInfo) ([]byte, error) {
+	if info == nil {
+		return nil, nil
+	}
+	return proto.Marshal(info)
+}
+
+func unmarshalBlockchainInfo(raw []byte) (*pb.BlockchainInfo, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	info := &pb.BlockchainInfo{}
+	if err := proto.Unmarshal(raw, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// readRecording parses a recording into its entries
+func readRecording(r io.Reader) ([]*recordEntry, error) {
+	var entries []*recordEntry
+	decoder := json.NewDecoder(r)
+	for {
+		entry := &recordEntry{}
+		if err := decoder.Decode(entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Error reading entry %d of the recording: %s", len(entries), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 || entries[0].Kind != recordStart {
+		return nil, fmt.Errorf("Recording does not begin with a %s entry", recordStart)
+	}
+	return entries, nil
+}
+
+// recordingStack records the answers of the stack which the replica's
+// behavior depends on, so a replay sees the same ledger and persisted state
+type recordingStack struct {
+	consensus.Stack
+	rec *recorder
+}
+
+func (rs *recordingStack) ReadState(key string) ([]byte, error) {
+	value, err := rs.Stack.ReadState(key)
+	rs.rec.write(&recordEntry{Kind: recordReadState, Key: key, Data: value, Error: errorString(err)})
+	return value, err
+}
+
+func (rs *recordingStack) ReadStateSet(prefix string) (map[string][]byte, error) {
+	set, err := rs.Stack.ReadStateSet(prefix)
+	rs.rec.write(&recordEntry{Kind: recordReadStateSet, Key: prefix, Set: set, Error: errorString(err)})
+	return set, err
+}
+
+func (rs *recordingStack) GetBlockchainInfoBlob() []byte {
+	blob := rs.Stack.GetBlockchainInfoBlob()
+	rs.rec.write(&recordEntry{Kind: recordBlockchainInfoBlob, Data: blob})
+	return blob
+}
+
+func (rs *recordingStack) GetBlockHeadMetadata() ([]byte, error) {
+	meta, err := rs.Stack.GetBlockHeadMetadata()
+	rs.rec.write(&recordEntry{Kind: recordBlockHeadMetadata, Data: meta, Error: errorString(err)})
+	return meta, err
+}
+
+func (rs *recordingStack) GetBlockchainSize() uint64 {
+	size := rs.Stack.GetBlockchainSize()
+	rs.rec.write(&recordEntry{Kind: recordBlockchainSize, Size: size})
+	return size
+}
+
+func (rs *recordingStack) GetBlock(id uint64) (*pb.Block, error) {
+	block, err := rs.Stack.GetBlock(id)
+	entry := &recordEntry{Kind: recordBlock, SeqNo: id, Error: errorString(err)}
+	if block != nil {
+		entry.Data, _ = proto.Marshal(block)
+	}
+	rs.rec.write(entry)
+	return block, err
+}
+
+func (rs *recordingStack) Verify(peerID *pb.PeerID, signature []byte, message []byte) error {
+	err := rs.Stack.Verify(peerID, signature, message)
+	rs.rec.write(&recordEntry{Kind: recordVerify, Sender: peerID.Name, Error: errorString(err)})
+	return err
+}
+
+// HealthChanged passes health changes on to the wrapped stack
+func (rs *recordingStack) HealthChanged(health *pb.ConsensusHealth) {
+	if observer, ok := rs.Stack.(consensus.HealthObserver); ok {
+		observer.HealthChanged(health)
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,326 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/consensus/util/events"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Replay reproduces the run of a replica from a recording made with
+// general.record. A fresh replica is built from config, fed the recorded
+// events one at a time and answered with the recorded ledger and persisted
+// state. Timers never fire on their own, their expiry is part of the
+// recording, so a replay is deterministic. Every message the replica sends
+// and every stack operation it requests is written to out.
+func Replay(recording io.Reader, config *viper.Viper, out io.Writer) error {
+	op, err := replay(recording, config, out)
+	if op != nil {
+		fmt.Fprintf(out, "replica %d finished in view %d (active %v), seqNo %d, lastExec %d, low watermark %d\n",
+			op.pbft.id, op.pbft.view, op.pbft.activeView, op.pbft.seqNo, op.pbft.lastExec, op.pbft.h)
+	}
+	return err
+}
+
+func replay(recording io.Reader, config *viper.Viper, out io.Writer) (*obcBatch, error) {
+	entries, err := readRecording(recording)
+	if err != nil {
+		return nil, err
+	}
+
+	stack := newReplayStack(entries[0].Replica, config.GetInt("general.N"), out)
+	var replayed []events.Event
+	for i, entry := range entries[1:] {
+		event, err := decodeEvent(entry)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding entry %d of the recording: %s", i+1, err)
+		}
+		if event != nil {
+			replayed = append(replayed, event)
+			stack.events = append(stack.events, entry)
+			continue
+		}
+		stack.answers[entry.Kind] = append(stack.answers[entry.Kind], entry)
+	}
+
+	// The manager is never started, timers may be armed but can't deliver
+	op := initObcBatch(entries[0].Replica, config, stack, events.NewManagerImpl())
+	defer op.Close()
+
+	for i, event := range replayed {
+		stack.printf("event %d: %s at %s", i, stack.events[i].Kind, stack.events[i].Time.Format("15:04:05.000"))
+		events.SendEvent(op, event)
+	}
+	op.broadcaster.Close()
+	return op, stack.divergence()
+}
+
+// replayStack answers the replayed replica from a recording and reports what
+// the replica asks of it
+type replayStack struct {
+	id      uint64
+	n       int
+	events  []*recordEntry            // replayed event entries, for reporting
+	answers map[string][]*recordEntry // recorded answers of the stack by kind, in order
+
+	lock    sync.Mutex
+	out     io.Writer
+	missing []string // kinds of answers needed but not recorded
+}
+
+func newReplayStack(id uint64, n int, out io.Writer) *replayStack {
+	return &replayStack{
+		id:      id,
+		n:       n,
+		answers: make(map[string][]*recordEntry),
+		out:     out,
+	}
+}
+
+func (rs *replayStack) printf(format string, args ...interface{}) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	fmt.Fprintf(rs.out, format+"\n", args...)
+}
+
+// answer returns the next recorded answer of the given kind
+func (rs *replayStack) answer(kind string) (*recordEntry, error) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	if len(rs.answers[kind]) == 0 {
+		rs.missing = append(rs.missing, kind)
+		return &recordEntry{}, fmt.Errorf("replay has no recorded %s left", kind)
+	}
+	entry := rs.answers[kind][0]
+	rs.answers[kind] = rs.answers[kind][1:]
+	if entry.Error != "" {
+		return entry, errors.New(entry.Error)
+	}
+	return entry, nil
+}
+
+// divergence returns an error if the replica did not ask the same of its
+// stack as during the recording
+func (rs *replayStack) divergence() error {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	if len(rs.missing) > 0 {
+		return fmt.Errorf("Replay diverged from the recording, the replica requested unrecorded %s", strings.Join(rs.missing, ", "))
+	}
+	return nil
+}
+
+func (rs *replayStack) GetNetworkInfo() (self *pb.PeerEndpoint, network []*pb.PeerEndpoint, err error) {
+	handle, handles, _ := rs.GetNetworkHandles()
+	self = &pb.PeerEndpoint{ID: handle, Type: pb.PeerEndpoint_VALIDATOR}
+	for _, h := range handles {
+		network = append(network, &pb.PeerEndpoint{ID: h, Type: pb.PeerEndpoint_VALIDATOR})
+	}
+	return
+}
+
+func (rs *replayStack) GetNetworkHandles() (self *pb.PeerID, network []*pb.PeerID, err error) {
+	self, _ = getValidatorHandle(rs.id)
+	for i := 0; i < rs.n; i++ {
+		if uint64(i) == rs.id {
+			continue
+		}
+		handle, _ := getValidatorHandle(uint64(i))
+		network = append(network, handle)
+	}
+	return
+}
+
+func (rs *replayStack) Broadcast(msg *pb.Message, peerType pb.PeerEndpoint_Type) error {
+	rs.printf("  broadcast %s", describeMessage(msg))
+	return nil
+}
+
+func (rs *replayStack) Unicast(msg *pb.Message, receiverHandle *pb.PeerID) error {
+	rs.printf("  send %s to %s", describeMessage(msg), receiverHandle.Name)
+	return nil
+}
+
+func (rs *replayStack) Sign(msg []byte) ([]byte, error) {
+	return msg, nil
+}
+
+func (rs *replayStack) Verify(peerID *pb.PeerID, signature []byte, message []byte) error {
+	_, err := rs.answer(recordVerify)
+	return err
+}
+
+func (rs *replayStack) Start() {}
+
+func (rs *replayStack) Halt() {}
+
+func (rs *replayStack) Execute(tag interface{}, txs []*pb.Transaction) {
+	uuids := make([]string, len(txs))
+	for i, tx := range txs {
+		uuids[i] = tx.Uuid
+	}
+	rs.printf("  execute %d transactions [%s]", len(txs), strings.Join(uuids, " "))
+}
+
+func (rs *replayStack) Commit(tag interface{}, metadata []byte) {
+	meta := &Metadata{}
+	proto.Unmarshal(metadata, meta)
+	rs.printf("  commit seqNo %d", meta.SeqNo)
+}
+
+func (rs *replayStack) Rollback(tag interface{}) {
+	rs.printf("  rollback")
+}
+
+func (rs *replayStack) UpdateState(tag interface{}, target *pb.BlockchainInfo, peers []*pb.PeerID) {
+	rs.printf("  state transfer to height %d", target.Height)
+}
+
+func (rs *replayStack) BeginTxBatch(id interface{}) error {
+	return fmt.Errorf("The legacy executor is not available during replay")
+}
+
+func (rs *replayStack) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error) {
+	return nil, fmt.Errorf("The legacy executor is not available during replay")
+}
+
+func (rs *replayStack) CommitTxBatch(id interface{}, metadata []byte) (*pb.Block, error) {
+	return nil, fmt.Errorf("The legacy executor is not available during replay")
+}
+
+func (rs *replayStack) RollbackTxBatch(id interface{}) error {
+	return fmt.Errorf("The legacy executor is not available during replay")
+}
+
+func (rs *replayStack) PreviewCommitTxBatch(id interface{}, metadata []byte) ([]byte, error) {
+	return nil, fmt.Errorf("The legacy executor is not available during replay")
+}
+
+func (rs *replayStack) InvalidateState() {
+	rs.printf("  invalidate state")
+}
+
+func (rs *replayStack) ValidateState() {
+	rs.printf("  validate state")
+}
+
+func (rs *replayStack) GetBlock(id uint64) (*pb.Block, error) {
+	entry, err := rs.answer(recordBlock)
+	if err != nil {
+		return nil, err
+	}
+	block := &pb.Block{}
+	if err := proto.Unmarshal(entry.Data, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (rs *replayStack) GetBlockchainSize() uint64 {
+	entry, _ := rs.answer(recordBlockchainSize)
+	return entry.Size
+}
+
+func (rs *replayStack) GetBlockchainInfo() *pb.BlockchainInfo {
+	info, _ := unmarshalBlockchainInfo(rs.GetBlockchainInfoBlob())
+	return info
+}
+
+func (rs *replayStack) GetBlockchainInfoBlob() []byte {
+	entry, _ := rs.answer(recordBlockchainInfoBlob)
+	return entry.Data
+}
+
+func (rs *replayStack) GetBlockHeadMetadata() ([]byte, error) {
+	entry, err := rs.answer(recordBlockHeadMetadata)
+	return entry.Data, err
+}
+
+func (rs *replayStack) StoreState(key string, value []byte) error {
+	return nil
+}
+
+func (rs *replayStack) ReadState(key string) ([]byte, error) {
+	entry, err := rs.answer(recordReadState)
+	return entry.Data, err
+}
+
+func (rs *replayStack) ReadStateSet(prefix string) (map[string][]byte, error) {
+	entry, err := rs.answer(recordReadStateSet)
+	return entry.Set, err
+}
+
+func (rs *replayStack) DelState(key string) {}
+
+// describeMessage returns a short description of a consensus message for the replay output
+func describeMessage(msg *pb.Message) string {
+	batchMsg := &BatchMessage{}
+	if err := proto.Unmarshal(msg.Payload, batchMsg); err != nil {
+		return fmt.Sprintf("undecodable %s message", msg.Type)
+	}
+	switch payload := batchMsg.Payload.(type) {
+	case *BatchMessage_Request:
+		return "request"
+	case *BatchMessage_RequestBatch:
+		return fmt.Sprintf("request_batch of %d requests", len(payload.RequestBatch.Batch))
+	case *BatchMessage_Complaint:
+		return "complaint"
+	case *BatchMessage_Departure:
+		return fmt.Sprintf("departure from view %d", payload.Departure.View)
+	case *BatchMessage_PbftMessage:
+		pbftMsg := &Message{}
+		if err := proto.Unmarshal(payload.PbftMessage, pbftMsg); err != nil {
+			return "undecodable pbft message"
+		}
+		return messageTypeName(pbftMsg)
+	}
+	return "unknown batch message"
+}
+
+// messageTypeName returns the messages.proto name of the payload type
+func messageTypeName(msg *Message) string {
+	switch msg.Payload.(type) {
+	case *Message_RequestBatch:
+		return "request_batch"
+	case *Message_PrePrepare:
+		return "pre_prepare"
+	case *Message_Prepare:
+		return "prepare"
+	case *Message_Commit:
+		return "commit"
+	case *Message_Checkpoint:
+		return "checkpoint"
+	case *Message_ViewChange:
+		return "view_change"
+	case *Message_NewView:
+		return "new_view"
+	case *Message_FetchRequestBatch:
+		return "fetch_request_batch"
+	case *Message_ReturnRequestBatch:
+		return "return_request_batch"
+	}
+	return "unknown"
+}
@@ -0,0 +1,89 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/consensus"
+)
+
+func TestReplayReproducesRecordedRun(t *testing.T) {
+	recording, err := ioutil.TempFile("", "pbft-recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recording.Close()
+	defer os.Remove(recording.Name())
+
+	validatorCount := 4
+	net := makeConsumerNetwork(validatorCount, func(id uint64, config *viper.Viper, stack consensus.Stack) pbftConsumer {
+		config.Set("general.batchsize", 1)
+		if id == 1 {
+			config.Set("general.record", recording.Name())
+		}
+		return newObcBatch(id, config, stack)
+	})
+
+	broadcaster := net.endpoints[generateBroadcaster(validatorCount)].getHandle()
+	for i := 1; i <= 3; i++ {
+		net.endpoints[1].(*consumerEndpoint).consumer.RecvMsg(createTxMsg(int64(i)), broadcaster)
+		net.process()
+	}
+	recorded := net.endpoints[1].(*consumerEndpoint).consumer.(*obcBatch).pbft
+	view, seqNo, lastExec := recorded.view, recorded.seqNo, recorded.lastExec
+	net.stop()
+
+	if lastExec != 3 {
+		t.Fatalf("Expected the recorded replica to execute 3 batches, executed %d", lastExec)
+	}
+
+	file, err := os.Open(recording.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	config := loadConfig()
+	config.Set("general.batchsize", 1)
+	out := &bytes.Buffer{}
+	op, err := replay(file, config, out)
+	if err != nil {
+		t.Fatalf("Replay failed: %s", err)
+	}
+	if op.pbft.view != view || op.pbft.seqNo != seqNo || op.pbft.lastExec != lastExec {
+		t.Errorf("Replay ended in view %d, seqNo %d, lastExec %d, recorded run in view %d, seqNo %d, lastExec %d",
+			op.pbft.view, op.pbft.seqNo, op.pbft.lastExec, view, seqNo, lastExec)
+	}
+	if executions := strings.Count(out.String(), "execute 1 transactions"); executions != 3 {
+		t.Errorf("Expected the replay to execute 3 batches, output shows %d:\n%s", executions, out.String())
+	}
+}
+
+func TestReplayDetectsDivergence(t *testing.T) {
+	recording := strings.NewReader(`{"kind":"start","replica":1}` + "\n")
+	_, err := replay(recording, loadConfig(), ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), "diverged") {
+		t.Fatalf("Expected the replay to diverge from a recording without ledger reads, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/consensus/util/events"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// shutdownEvent is sent when the validator is about to exit
+type shutdownEvent struct {
+	done chan struct{} // closed once the departure was announced
+}
+
+// Stop prepares the replica to exit without costing the network a view
+// change timeout. The replica stops assigning new batches, finishes the
+// ones in flight and announces its departure, upon which the backups of a
+// departing primary immediately move to the next view. It blocks until the
+// departure was announced or the timeout expired.
+func (op *obcBatch) Stop(timeout time.Duration) error {
+	done := make(chan struct{})
+	op.manager.Queue() <- shutdownEvent{done: done}
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("Replica %d did not finish its in-flight batches within %v", op.pbft.id, timeout)
+	}
+}
+
+func (op *obcBatch) startShutdown(done chan struct{}) events.Event {
+	if op.stopping {
+		close(done)
+		return nil
+	}
+	logger.Infof("Replica %d shutting down, no longer accepting requests for ordering", op.pbft.id)
+	op.stopping = true
+	op.stopDone = done
+
+	if op.isActivePrimary() && len(op.batchStore) > 0 {
+		// Order what we already accepted, departure follows once it executed
+		return op.sendBatch()
+	}
+	return op.maybeDepart()
+}
+
+// isActivePrimary returns whether this replica is assigning sequence numbers
+func (op *obcBatch) isActivePrimary() bool {
+	return op.pbft.primary(op.pbft.view) == op.pbft.id && op.pbft.activeView
+}
+
+// maybeDepart announces the departure of this replica once it is shutting
+// down and all batches it assigned as primary have executed
+func (op *obcBatch) maybeDepart() events.Event {
+	if !op.stopping || op.stopDone == nil {
+		return nil
+	}
+	if op.pbft.currentExec != nil {
+		return nil
+	}
+	if op.isActivePrimary() && (len(op.batchStore) > 0 || op.pbft.lastExec < op.pbft.seqNo) {
+		return nil
+	}
+
+	logger.Infof("Replica %d announcing departure in view %d", op.pbft.id, op.pbft.view)
+	op.broadcastMsg(&BatchMessage{Payload: &BatchMessage_Departure{
+		Departure: &Departure{View: op.pbft.view, ReplicaId: op.pbft.id},
+	}})
+	close(op.stopDone)
+	op.stopDone = nil
+	return nil
+}
+
+// recvDeparture starts a view change when the primary of the current view departs
+func (op *obcBatch) recvDeparture(departure *Departure, senderHandle *pb.PeerID) events.Event {
+	senderID, err := getValidatorID(senderHandle)
+	if err != nil || senderID != departure.ReplicaId {
+		logger.Warningf("Replica %d received departure of replica %d from %s, ignoring", op.pbft.id, departure.ReplicaId, senderHandle)
+		return nil
+	}
+	logger.Infof("Replica %d received departure of replica %d in view %d", op.pbft.id, senderID, departure.View)
+
+	if departure.View != op.pbft.view || !op.pbft.activeView || op.pbft.primary(op.pbft.view) != senderID {
+		return nil
+	}
+	logger.Infof("Replica %d moving to the next view, primary %d departed", op.pbft.id, senderID)
+	return op.pbft.sendViewChange()
+}
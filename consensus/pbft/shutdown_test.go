@@ -0,0 +1,94 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrimaryDepartureHandsOff(t *testing.T) {
+	validatorCount := 4
+	net := makeConsumerNetwork(validatorCount, obcBatchHelper, func(ce *consumerEndpoint) {
+		ce.consumer.(*obcBatch).batchSize = 1
+	})
+	defer net.stop()
+
+	broadcaster := net.endpoints[1].getHandle()
+	net.endpoints[1].(*consumerEndpoint).consumer.RecvMsg(createTxMsg(1), broadcaster)
+	net.process()
+
+	primary := net.endpoints[0].(*consumerEndpoint).consumer.(*obcBatch)
+	if err := primary.Stop(5 * time.Second); err != nil {
+		t.Fatalf("Primary did not stop gracefully: %s", err)
+	}
+
+	// The primary has exited after its departure, the others must not wait for it
+	net.filterFn = func(src int, dst int, payload []byte) []byte {
+		if dst == 0 {
+			return nil
+		}
+		return payload
+	}
+	net.process()
+
+	for _, ep := range net.endpoints[1:] {
+		b := ep.(*consumerEndpoint).consumer.(*obcBatch)
+		if b.pbft.view != 1 || !b.pbft.activeView {
+			t.Errorf("Replica %d expected to be active in view 1, is %v in view %d", b.pbft.id, b.pbft.activeView, b.pbft.view)
+		}
+	}
+
+	net.endpoints[2].(*consumerEndpoint).consumer.RecvMsg(createTxMsg(2), broadcaster)
+	net.process()
+
+	for _, ep := range net.endpoints[1:] {
+		ce := ep.(*consumerEndpoint)
+		if _, err := ce.consumer.(*obcBatch).stack.GetBlock(2); err != nil {
+			t.Errorf("Replica %d expected to execute the request after the hand off: %s", ce.id, err)
+		}
+	}
+}
+
+func TestStoppingPrimaryOrdersPendingBatch(t *testing.T) {
+	validatorCount := 4
+	net := makeConsumerNetwork(validatorCount, obcBatchHelper, func(ce *consumerEndpoint) {
+		ce.consumer.(*obcBatch).batchSize = 10
+	})
+	defer net.stop()
+
+	primary := net.endpoints[0].(*consumerEndpoint).consumer.(*obcBatch)
+	primary.RecvMsg(createTxMsg(1), net.endpoints[0].getHandle())
+	net.process()
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- primary.Stop(5 * time.Second)
+	}()
+	time.Sleep(100 * time.Millisecond) // let the shutdown cut the pending batch
+	net.process()
+
+	if err := <-stopped; err != nil {
+		t.Fatalf("Primary did not stop gracefully: %s", err)
+	}
+	for _, ep := range net.endpoints {
+		ce := ep.(*consumerEndpoint)
+		if _, err := ce.consumer.(*obcBatch).stack.GetBlock(1); err != nil {
+			t.Errorf("Replica %d expected the pending batch to be executed before the primary left: %s", ce.id, err)
+		}
+	}
+}
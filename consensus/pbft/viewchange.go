@@ -128,6 +128,7 @@ func (instance *pbftCore) sendViewChange() events.Event {
 	delete(instance.newViewStore, instance.view)
 	instance.view++
 	instance.activeView = false
+	viewChanges.Inc()
 
 	instance.pset = instance.calcPSet()
 	instance.qset = instance.calcQSet()
@@ -464,6 +465,7 @@ func (instance *pbftCore) processNewView2(nv *NewView) events.Event {
 	instance.nullRequestTimer.Stop()
 
 	instance.activeView = true
+	instance.failedViewChanges = 0
 	delete(instance.newViewStore, instance.view-1)
 
 	instance.seqNo = instance.h
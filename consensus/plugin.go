@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consensus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PluginFactory constructs a consensus plugin on top of the given Stack.
+// The factory is invoked once, when the validating peer starts.
+type PluginFactory func(stack Stack) Consenter
+
+var (
+	pluginsLock sync.RWMutex
+	plugins     = make(map[string]PluginFactory)
+)
+
+// RegisterPlugin makes a consensus plugin available under the given name,
+// which is matched case-insensitively against peer.validator.consensus.plugin.
+// It is meant to be called from the init function of the plugin package, and
+// panics if a plugin with the same name was already registered.
+func RegisterPlugin(name string, factory PluginFactory) {
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+
+	name = strings.ToLower(name)
+	if factory == nil {
+		panic(fmt.Errorf("Consensus plugin %s registered with a nil factory", name))
+	}
+	if _, ok := plugins[name]; ok {
+		panic(fmt.Errorf("Consensus plugin %s registered twice", name))
+	}
+	plugins[name] = factory
+}
+
+// GetPluginFactory returns the factory of the plugin registered under name
+func GetPluginFactory(name string) (PluginFactory, bool) {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+
+	factory, ok := plugins[strings.ToLower(name)]
+	return factory, ok
+}
+
+// RegisteredPlugins returns the sorted names of all registered plugins
+func RegisteredPlugins() []string {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+
+	var names []string
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -80,6 +80,7 @@ type managerImpl struct {
 	threaded
 	receiver Receiver
 	events   chan Event
+	record   func(Event) // When non-nil, observes every event taken from the queue
 }
 
 // NewManagerImpl creates an instance of managerImpl
@@ -90,6 +91,18 @@ func NewManagerImpl() Manager {
 	}
 }
 
+// NewRecordingManagerImpl creates an instance of managerImpl which passes
+// every event taken from its queue to record before delivering it. Events
+// injected by the receiver itself are not passed to record, so the recorded
+// events are exactly the input needed to reproduce the receiver's behavior.
+func NewRecordingManagerImpl(record func(Event)) Manager {
+	return &managerImpl{
+		events:   make(chan Event),
+		threaded: threaded{make(chan struct{})},
+		record:   record,
+	}
+}
+
 // SetReceiver sets the destination for events
 func (em *managerImpl) SetReceiver(receiver Receiver) {
 	em.receiver = receiver
@@ -129,6 +142,9 @@ func (em *managerImpl) eventLoop() {
 	for {
 		select {
 		case next := <-em.events:
+			if em.record != nil {
+				em.record(next)
+			}
 			em.Inject(next)
 		case <-em.exit:
 			logger.Debug("eventLoop told to exit")
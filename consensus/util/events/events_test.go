@@ -170,3 +170,36 @@ func TestEventManagerLoop(t *testing.T) {
 		t.Fatalf("Did not succeed processing second event")
 	}
 }
+
+// Tests that the recording manager observes queued events, but not the ones returned by the receiver
+func TestRecordingManager(t *testing.T) {
+	var recorded []Event
+	m1 := &mockEvent{}
+	m2 := &mockEvent{}
+	done := make(chan struct{})
+	manager := NewRecordingManagerImpl(func(event Event) {
+		recorded = append(recorded, event)
+	})
+	manager.SetReceiver(&mockReceiver{
+		processEventImpl: func(event Event) Event {
+			if event == m1 {
+				return m2
+			}
+			close(done)
+			return nil
+		},
+	})
+	manager.Start()
+	defer manager.Halt()
+
+	manager.Queue() <- m1
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Did not process the returned event")
+	}
+	if len(recorded) != 1 || recorded[0] != m1 {
+		t.Fatalf("Expected only the queued event to be recorded, got %v", recorded)
+	}
+}
@@ -17,28 +17,192 @@ limitations under the License.
 package core
 
 import (
+	"crypto/subtle"
+	"fmt"
 	"os"
 	"runtime"
+	"time"
 
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 
 	"google/protobuf"
 
+	"github.com/hyperledger/fabric/core/audit"
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/core/quorum"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
 var log = logging.MustGetLogger("server")
 
-// NewAdminServer creates and returns a Admin service instance.
-func NewAdminServer() *ServerAdmin {
-	s := new(ServerAdmin)
-	return s
+// AdminTokenKey is the gRPC metadata key of the token authenticating admin
+// calls, see peer.admin.token
+const AdminTokenKey = "admin-token"
+
+// NewAdminServer creates and returns a Admin service instance. The status
+// of the node is taken from coord, which may be nil.
+func NewAdminServer(coord peer.MessageHandlerCoordinator) (*ServerAdmin, error) {
+	access, err := comm.NewAccessList("peer.admin")
+	if err != nil {
+		return nil, err
+	}
+	policy, err := quorum.NewPolicy("peer.admin.quorum")
+	if err != nil {
+		return nil, err
+	}
+	s := &ServerAdmin{
+		coord:   coord,
+		token:   viper.GetString("peer.admin.token"),
+		access:  access,
+		quorum:  policy,
+		started: time.Now(),
+	}
+	if s.token == "" && !access.HasSubjectRules() {
+		log.Warning("Admin calls are not authenticated, set peer.admin.token or peer.admin.allowSubjects")
+	}
+	if coord != nil {
+		s.RegisterHealthCheck("ledger", func() error {
+			_, err := coord.GetCurrentStateHash()
+			return err
+		})
+		s.RegisterReadinessCheck("network", func() error {
+			peers, err := coord.GetPeers()
+			if err != nil {
+				return err
+			}
+			if len(peers.Peers) == 0 && viper.GetString("peer.discovery.rootnode") != "" {
+				return fmt.Errorf("Not connected to any peer")
+			}
+			return nil
+		})
+		if reporter, ok := coord.(peer.ReadinessReporter); ok {
+			s.RegisterReadinessCheck("readiness", reporter.Ready)
+		}
+		if reporter, ok := coord.(peer.CertificateExpiryReporter); ok {
+			s.RegisterReadinessCheck("certificates", reporter.CheckCertificateExpiry)
+		}
+		if reporter, ok := coord.(peer.HealthReporter); ok {
+			s.RegisterReadinessCheck("consensus", func() error {
+				if !peer.ValidatorEnabled() {
+					return nil
+				}
+				health, err := reporter.GetConsensusHealth()
+				if err != nil {
+					return err
+				}
+				if !health.Healthy {
+					return fmt.Errorf("%s: %s", health.Condition, health.Detail)
+				}
+				if health.Saturated {
+					return fmt.Errorf("Saturated with %d outstanding requests", health.Outstanding)
+				}
+				return nil
+			})
+		}
+	}
+	return s, nil
 }
 
 // ServerAdmin implementation of the Admin service for the Peer
 type ServerAdmin struct {
+	shutdown      *Shutdown
+	coord         peer.MessageHandlerCoordinator
+	token         string
+	access        *comm.AccessList
+	quorum        *quorum.Policy
+	started       time.Time
+	healthChecks  []healthCheck
+	statusSources []func(status *pb.NodeStatus)
+}
+
+type healthCheck struct {
+	name  string
+	check func() error
+	// Only tells whether the node is ready to serve, not whether it is alive
+	readiness bool
+}
+
+// SetShutdown registers the shutdown run by StopServer and DrainServer
+// before the process exits
+func (s *ServerAdmin) SetShutdown(shutdown *Shutdown) {
+	s.shutdown = shutdown
+}
+
+// RegisterHealthCheck adds a subsystem to the node status, check returns why
+// the subsystem is unhealthy. The node is not alive while it is unhealthy.
+func (s *ServerAdmin) RegisterHealthCheck(name string, check func() error) {
+	s.healthChecks = append(s.healthChecks, healthCheck{name: name, check: check})
+}
+
+// RegisterReadinessCheck adds a subsystem to the node status which only
+// tells whether the node is ready to serve, e.g. connected to the network,
+// and not whether it is alive
+func (s *ServerAdmin) RegisterReadinessCheck(name string, check func() error) {
+	s.healthChecks = append(s.healthChecks, healthCheck{name: name, check: check, readiness: true})
+}
+
+// CheckHealth runs the health checks of the subsystems telling whether the
+// node is alive, and with readiness also those telling whether it is ready
+// to serve
+func (s *ServerAdmin) CheckHealth(readiness bool) []*pb.SubsystemHealth {
+	var subsystems []*pb.SubsystemHealth
+	for _, hc := range s.healthChecks {
+		if hc.readiness && !readiness {
+			continue
+		}
+		health := &pb.SubsystemHealth{Name: hc.name, Healthy: true}
+		if err := hc.check(); err != nil {
+			health.Healthy = false
+			health.Detail = err.Error()
+		}
+		subsystems = append(subsystems, health)
+	}
+	return subsystems
+}
+
+// authorize returns an error unless the caller presented the admin token, if
+// one is configured, and a client certificate admitted by the admin subjects,
+// if those are configured
+func (s *ServerAdmin) authorize(ctx context.Context) error {
+	cert := comm.ClientCertificate(ctx)
+	if s.token != "" {
+		md, _ := metadata.FromContext(ctx)
+		tokens := md[AdminTokenKey]
+		if len(tokens) == 0 || subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(s.token)) != 1 {
+			audit.Record(pb.SecurityEvent_ACCESS_DENIED, comm.SubjectIdentity(cert), "admin service", "Missing or invalid admin token")
+			return grpc.Errorf(codes.Unauthenticated, "Missing or invalid admin token")
+		}
+	}
+	if err := s.access.AdmitCertificate(cert); err != nil {
+		audit.Record(pb.SecurityEvent_ACCESS_DENIED, comm.SubjectIdentity(cert), "admin service", err.Error())
+		return grpc.Errorf(codes.PermissionDenied, "%s", err)
+	}
+	return nil
+}
+
+// approve returns an error unless approvals carry the approval of operation
+// on payload by the quorum of administrators, if one is configured
+func (s *ServerAdmin) approve(operation string, payload []byte, approvals []*pb.AdminApproval) error {
+	if err := s.quorum.Verify(operation, payload, approvals, true); err != nil {
+		return grpc.Errorf(codes.PermissionDenied, "%s", err)
+	}
+	return nil
+}
+
+// NewAdminContext returns a context authenticating admin calls with the
+// configured admin token
+func NewAdminContext() context.Context {
+	ctx := context.Background()
+	if token := viper.GetString("peer.admin.token"); token != "" {
+		ctx = metadata.NewContext(ctx, metadata.Pairs(AdminTokenKey, token))
+	}
+	return ctx
 }
 
 func worker(id int, die chan struct{}) {
@@ -69,13 +233,201 @@ func (*ServerAdmin) StartServer(context.Context, *google_protobuf.Empty) (*pb.Se
 }
 
 // StopServer stops the server
-func (*ServerAdmin) StopServer(context.Context, *google_protobuf.Empty) (*pb.ServerStatus, error) {
+func (s *ServerAdmin) StopServer(ctx context.Context, _ *google_protobuf.Empty) (*pb.ServerStatus, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return s.stop(viper.GetDuration("peer.shutdown.timeout")), nil
+}
+
+// stop runs the shutdown, giving the in-flight work up to timeout to
+// complete, and exits the process
+func (s *ServerAdmin) stop(timeout time.Duration) *pb.ServerStatus {
 	status := &pb.ServerStatus{Status: pb.ServerStatus_STOPPED}
 	log.Debugf("returning status: %s", status)
 
+	// The process may exit as soon as the servers are stopped
 	pidFile := viper.GetString("peer.fileSystemPath") + "/peer.pid"
 	log.Debugf("Remove pid file  %s", pidFile)
 	os.Remove(pidFile)
+
+	if s.shutdown != nil {
+		s.shutdown.Run(timeout)
+	}
 	defer os.Exit(0)
+	return status
+}
+
+// GetNodeStatus reports the status of the node and the health of its subsystems
+func (s *ServerAdmin) GetNodeStatus(ctx context.Context, _ *google_protobuf.Empty) (*pb.NodeStatus, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	status := &pb.NodeStatus{
+		Status:              pb.ServerStatus_STARTED,
+		UptimeSeconds:       int64(time.Since(s.started) / time.Second),
+		RateLimitedRequests: comm.RateLimitRejections(),
+		SecurityEvents:      audit.Counts(),
+	}
+	if s.coord != nil {
+		if pe, err := s.coord.GetPeerEndpoint(); err == nil {
+			status.PeerID = pe.ID.Name
+			status.Address = pe.Address
+			status.Validator = pe.Type == pb.PeerEndpoint_VALIDATOR
+		}
+		status.BlockchainHeight = s.coord.GetBlockchainSize()
+		if peers, err := s.coord.GetPeers(); err == nil {
+			status.ConnectedPeers = int32(len(peers.Peers))
+		}
+		if standby, ok := s.coord.(peer.Standby); ok {
+			status.StandbyFor = standby.StandbyFor()
+		}
+		if drainer, ok := s.coord.(peer.Drainer); ok && drainer.Draining() {
+			status.Status = pb.ServerStatus_PAUSED
+			status.Draining = true
+		}
+	}
+	status.Subsystems = s.CheckHealth(true)
+	s.aggregateStatus(status)
 	return status, nil
 }
+
+// GetModuleLogLevel reports the log level of a logging module
+func (s *ServerAdmin) GetModuleLogLevel(ctx context.Context, req *pb.LogLevelRequest) (*pb.LogLevelResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return &pb.LogLevelResponse{Module: req.Module, Level: logging.GetLevel(req.Module).String()}, nil
+}
+
+// SetModuleLogLevel changes the log level of a logging module until the
+// peer restarts
+func (s *ServerAdmin) SetModuleLogLevel(ctx context.Context, req *pb.LogLevelRequest) (*pb.LogLevelResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	level, err := logging.LogLevel(req.Level)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "Invalid log level %s", req.Level)
+	}
+	payload := quorum.LogLevelPayload(viper.GetString("peer.id"), req.Module, req.Level)
+	if err := s.approve(quorum.OperationSetLogLevel, payload, req.Approvals); err != nil {
+		return nil, err
+	}
+	logging.SetLevel(level, req.Module)
+	log.Infof("Log level of module '%s' set to %s", req.Module, level)
+	return &pb.LogLevelResponse{Module: req.Module, Level: logging.GetLevel(req.Module).String()}, nil
+}
+
+// RevertLogLevels restores the log levels of the logging specification of
+// the configuration, undoing those set by SetModuleLogLevel, and reports the
+// default level
+func (s *ServerAdmin) RevertLogLevels(ctx context.Context, _ *google_protobuf.Empty) (*pb.LogLevelResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	level := RevertLoggingLevels()
+	log.Infof("Log levels reverted to the logging specification, default %s", level)
+	return &pb.LogLevelResponse{Level: level.String()}, nil
+}
+
+// DrainServer makes the peer refuse new transactions, waits for the pending
+// ones to be ordered and stops the server. The shutdown, if registered, is
+// given the drain timeout.
+func (s *ServerAdmin) DrainServer(ctx context.Context, req *pb.DrainRequest) (*pb.ServerStatus, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	drainer, ok := s.coord.(peer.Drainer)
+	if !ok {
+		return nil, grpc.Errorf(codes.Unimplemented, "The peer cannot be drained")
+	}
+	timeout := time.Duration(req.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = viper.GetDuration("peer.admin.drainTimeout")
+	}
+	drainer.Drain()
+	log.Infof("Draining, waiting up to %s for pending transactions", timeout)
+	if pending := WaitPending(s.coord, time.Now().Add(timeout)); pending > 0 {
+		log.Warningf("Stopping with %d transactions still pending", pending)
+	}
+	return s.stop(timeout), nil
+}
+
+// Takeover makes a standby take over from its primary
+func (s *ServerAdmin) Takeover(ctx context.Context, in *google_protobuf.Empty) (*pb.NodeStatus, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	standby, ok := s.coord.(peer.Standby)
+	if !ok {
+		return nil, grpc.Errorf(codes.Unimplemented, "The peer cannot stand by")
+	}
+	if err := standby.Takeover(); err != nil {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "%s", err)
+	}
+	log.Info("Took over from the primary")
+	return s.GetNodeStatus(ctx, &google_protobuf.Empty{})
+}
+
+// GetConnectionStats reports the connections of the peer with other peers
+// and the traffic on each
+func (s *ServerAdmin) GetConnectionStats(ctx context.Context, in *google_protobuf.Empty) (*pb.NetworkMap, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	mapper, ok := s.coord.(interface {
+		GetNetworkMap() (*pb.NetworkMap, error)
+	})
+	if !ok {
+		return nil, grpc.Errorf(codes.Unimplemented, "The peer does not report its connections")
+	}
+	return mapper.GetNetworkMap()
+}
+
+// RenewCertificates loads the renewed enrollment and TLS certificates of the
+// peer and announces its new identity to the connected peers
+func (s *ServerAdmin) RenewCertificates(ctx context.Context, in *google_protobuf.Empty) (*pb.NodeStatus, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	renewer, ok := s.coord.(peer.CertificateRenewer)
+	if !ok {
+		return nil, grpc.Errorf(codes.Unimplemented, "The peer cannot renew its certificates")
+	}
+	if err := renewer.RenewCertificates(); err != nil {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "%s", err)
+	}
+	log.Info("Renewed certificates")
+	return s.GetNodeStatus(ctx, &google_protobuf.Empty{})
+}
+
+// ReEnroll rotates the enrollment key of the peer through the ECA and
+// announces its new identity to the connected peers
+func (s *ServerAdmin) ReEnroll(ctx context.Context, in *google_protobuf.Empty) (*pb.NodeStatus, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	renewer, ok := s.coord.(peer.CertificateRenewer)
+	if !ok {
+		return nil, grpc.Errorf(codes.Unimplemented, "The peer cannot re-enroll")
+	}
+	if err := renewer.ReEnroll(); err != nil {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "%s", err)
+	}
+	log.Info("Re-enrolled")
+	return s.GetNodeStatus(ctx, &google_protobuf.Empty{})
+}
+
+// ReloadConfig reads the configuration file again and applies the changed
+// settings which may change while the peer runs
+func (s *ServerAdmin) ReloadConfig(ctx context.Context, in *google_protobuf.Empty) (*pb.ConfigReloadReport, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	report, err := ReloadConfig()
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	return report, nil
+}
@@ -16,9 +16,168 @@ limitations under the License.
 
 package core
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"google/protobuf"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
 
 func TestServer_Status(t *testing.T) {
 	t.Skip("TBD")
 	//performHandshake(t, peerClientConn)
 }
+
+func TestAdminTokenIsRequired(t *testing.T) {
+	viper.Set("peer.admin.token", "secret")
+	defer viper.Set("peer.admin.token", "")
+	s, err := NewAdminServer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.GetNodeStatus(context.Background(), &google_protobuf.Empty{})
+	if grpc.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Expected a call without token to be unauthenticated, got %v", err)
+	}
+	ctx := metadata.NewContext(context.Background(), metadata.Pairs(AdminTokenKey, "wrong"))
+	if _, err = s.GetNodeStatus(ctx, &google_protobuf.Empty{}); grpc.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Expected a call with a wrong token to be unauthenticated, got %v", err)
+	}
+	status, err := s.GetNodeStatus(NewAdminContext(), &google_protobuf.Empty{})
+	if err != nil {
+		t.Fatalf("Expected a call with the token to be accepted, got %s", err)
+	}
+	if status.Status != pb.ServerStatus_STARTED || status.Draining {
+		t.Errorf("Expected a started node, got %s", status)
+	}
+}
+
+func TestAdminHealthChecks(t *testing.T) {
+	s, err := NewAdminServer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RegisterHealthCheck("good", func() error { return nil })
+	s.RegisterHealthCheck("bad", func() error { return fmt.Errorf("broken") })
+
+	status, err := s.GetNodeStatus(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Subsystems) != 2 {
+		t.Fatalf("Expected 2 subsystems, got %d", len(status.Subsystems))
+	}
+	if good := status.Subsystems[0]; good.Name != "good" || !good.Healthy {
+		t.Errorf("Expected subsystem good to be healthy, got %s", good)
+	}
+	if bad := status.Subsystems[1]; bad.Name != "bad" || bad.Healthy || bad.Detail != "broken" {
+		t.Errorf("Expected subsystem bad to be unhealthy, got %s", bad)
+	}
+}
+
+func TestAdminCheckHealth(t *testing.T) {
+	s, err := NewAdminServer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RegisterHealthCheck("alive", func() error { return nil })
+	s.RegisterReadinessCheck("ready", func() error { return fmt.Errorf("syncing") })
+
+	if liveness := s.CheckHealth(false); len(liveness) != 1 || liveness[0].Name != "alive" {
+		t.Errorf("Expected only the liveness check, got %v", liveness)
+	}
+	readiness := s.CheckHealth(true)
+	if len(readiness) != 2 {
+		t.Fatalf("Expected both checks for readiness, got %v", readiness)
+	}
+	if ready := readiness[1]; ready.Name != "ready" || ready.Healthy || ready.Detail != "syncing" {
+		t.Errorf("Expected subsystem ready to be unhealthy, got %s", ready)
+	}
+}
+
+func TestAdminStatusSources(t *testing.T) {
+	s, err := NewAdminServer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RegisterStatusSource(func(status *pb.NodeStatus) {
+		status.EventConsumers = 2
+	})
+	s.RegisterStatusSource(func(status *pb.NodeStatus) {
+		status.Chaincodes = []*pb.ChaincodeContainer{{Name: "mycc", Registered: true}}
+	})
+
+	status, err := s.GetNodeStatus(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.EventConsumers != 2 || len(status.Chaincodes) != 1 || status.Chaincodes[0].Name != "mycc" {
+		t.Errorf("Expected the state of the registered subsystems, got %s", status)
+	}
+	if status.Resources == nil || status.Resources.Goroutines == 0 || status.Resources.SysBytes == 0 {
+		t.Errorf("Expected the resource usage of the node, got %s", status.Resources)
+	}
+	if status.Consensus != nil {
+		t.Errorf("Expected no consensus health without a peer, got %s", status.Consensus)
+	}
+}
+
+func TestAdminModuleLogLevel(t *testing.T) {
+	s, err := NewAdminServer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logging.SetLevel(logging.GetLevel("admintest"), "admintest")
+
+	resp, err := s.SetModuleLogLevel(context.Background(), &pb.LogLevelRequest{Module: "admintest", Level: "warning"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Level != "WARNING" {
+		t.Errorf("Expected level WARNING, got %s", resp.Level)
+	}
+	resp, err = s.GetModuleLogLevel(context.Background(), &pb.LogLevelRequest{Module: "admintest"})
+	if err != nil || resp.Level != "WARNING" {
+		t.Errorf("Expected level WARNING to be kept, got %v, %v", resp, err)
+	}
+	if _, err = s.SetModuleLogLevel(context.Background(), &pb.LogLevelRequest{Module: "admintest", Level: "loud"}); grpc.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected an invalid level to be refused, got %v", err)
+	}
+}
+
+func TestAdminRevertLogLevels(t *testing.T) {
+	s, err := NewAdminServer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	viper.Reset()
+	viper.Set("logging_level", "info:admintest=error")
+	defer viper.Reset()
+	LoggingInit("")
+	defer RevertLoggingLevels()
+
+	for _, module := range []string{"admintest", "admintest2"} {
+		if _, err = s.SetModuleLogLevel(context.Background(), &pb.LogLevelRequest{Module: module, Level: "debug"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := s.RevertLogLevels(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Level != "INFO" {
+		t.Errorf("Expected the default level INFO, got %s", resp.Level)
+	}
+	assertModuleLoggingLevel(t, "admintest", logging.ERROR)
+	assertModuleLoggingLevel(t, "admintest2", logging.INFO)
+}
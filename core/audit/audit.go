@@ -0,0 +1,76 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package audit
+
+import (
+	"sync/atomic"
+
+	"github.com/hyperledger/fabric/core/util"
+	"github.com/hyperledger/fabric/events/producer"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+)
+
+var auditLogger = logging.MustGetLogger("security_audit")
+
+// counts holds the number of events recorded by kind
+var counts [pb.SecurityEvent_ACCESS_DENIED + 1]uint64
+
+// Enabled returns whether the peer runs in security audit mode, in which
+// security events are logged and published on the event hub
+func Enabled() bool {
+	return viper.GetBool("security.audit.enabled")
+}
+
+// Record records a security event of the given kind, caused by identity,
+// e.g. the subject of a certificate, from source, e.g. a transaction or the
+// address of a client. Events are always counted, and in security audit mode
+// logged to the security_audit module and published as SECURITY events.
+func Record(kind pb.SecurityEvent_Kind, identity, source, detail string) {
+	if int(kind) < len(counts) {
+		atomic.AddUint64(&counts[kind], 1)
+	}
+	if !Enabled() {
+		return
+	}
+
+	auditLogger.Warningf("kind=%s identity=%q source=%q detail=%q", kind, identity, source, detail)
+
+	event := &pb.SecurityEvent{
+		Kind:      kind,
+		Identity:  identity,
+		Source:    source,
+		Detail:    detail,
+		Timestamp: util.CreateUtcTimestamp(),
+	}
+	if err := producer.Send(producer.CreateSecurityEvent(event)); err != nil {
+		auditLogger.Errorf("Failed publishing the security event: %s", err)
+	}
+}
+
+// Counts returns the number of events recorded since the peer started, for
+// every kind
+func Counts() []*pb.SecurityEventCount {
+	result := make([]*pb.SecurityEventCount, len(counts))
+	for kind := range counts {
+		result[kind] = &pb.SecurityEventCount{
+			Kind:  pb.SecurityEvent_Kind(kind).String(),
+			Count: atomic.LoadUint64(&counts[kind]),
+		}
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package audit
+
+import (
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+func count(kind pb.SecurityEvent_Kind) uint64 {
+	for _, c := range Counts() {
+		if c.Kind == kind.String() {
+			return c.Count
+		}
+	}
+	return 0
+}
+
+func TestRecordCountsEvents(t *testing.T) {
+	if len(Counts()) != len(pb.SecurityEvent_Kind_name) {
+		t.Fatalf("Expected a count for each of the %d kinds, got %v", len(pb.SecurityEvent_Kind_name), Counts())
+	}
+
+	denied, expired := count(pb.SecurityEvent_ACCESS_DENIED), count(pb.SecurityEvent_CERTIFICATE_EXPIRED)
+
+	Record(pb.SecurityEvent_ACCESS_DENIED, "CN=mallory", "192.0.2.1:4242", "denied address")
+	viper.Set("security.audit.enabled", true)
+	defer viper.Set("security.audit.enabled", false)
+	Record(pb.SecurityEvent_ACCESS_DENIED, "CN=mallory", "192.0.2.1:4242", "denied address")
+
+	if count(pb.SecurityEvent_ACCESS_DENIED) != denied+2 {
+		t.Errorf("Expected the events to be counted with or without audit mode, got %d", count(pb.SecurityEvent_ACCESS_DENIED)-denied)
+	}
+	if count(pb.SecurityEvent_CERTIFICATE_EXPIRED) != expired {
+		t.Error("Expected the events to be counted by kind")
+	}
+}
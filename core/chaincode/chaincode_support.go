@@ -20,8 +20,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/protobuf/proto"
@@ -30,10 +33,13 @@ import (
 
 	"strings"
 
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/container"
 	"github.com/hyperledger/fabric/core/container/ccintf"
 	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/quorum"
+	"github.com/hyperledger/fabric/flogging"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -92,6 +98,33 @@ func (chaincodeSupport *ChaincodeSupport) chaincodeHasBeenLaunched(chaincode str
 	return chrte, hasbeenlaunched
 }
 
+// IsRunning returns whether the chaincode has been launched and has registered
+// with this chaincode support.
+func (chaincodeSupport *ChaincodeSupport) IsRunning(chaincode string) bool {
+	chaincodeSupport.runningChaincodes.RLock()
+	defer chaincodeSupport.runningChaincodes.RUnlock()
+	chrte, ok := chaincodeSupport.chaincodeHasBeenLaunched(chaincode)
+	return ok && chrte.handler.registered
+}
+
+// Containers returns the chaincode containers launched by this chaincode
+// support, sorted by name
+func (chaincodeSupport *ChaincodeSupport) Containers() []*pb.ChaincodeContainer {
+	chaincodeSupport.runningChaincodes.RLock()
+	defer chaincodeSupport.runningChaincodes.RUnlock()
+	names := make([]string, 0, len(chaincodeSupport.runningChaincodes.chaincodeMap))
+	for name := range chaincodeSupport.runningChaincodes.chaincodeMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	containers := make([]*pb.ChaincodeContainer, len(names))
+	for i, name := range names {
+		chrte := chaincodeSupport.runningChaincodes.chaincodeMap[name]
+		containers[i] = &pb.ChaincodeContainer{Name: name, Registered: chrte.handler.registered}
+	}
+	return containers
+}
+
 // NewChaincodeSupport creates a new ChaincodeSupport instance
 func NewChaincodeSupport(chainname ChainName, getPeerEndpoint func() (*pb.PeerEndpoint, error), userrunsCC bool, ccstartuptimeout time.Duration, secHelper crypto.Peer) *ChaincodeSupport {
 	pnid := viper.GetString("peer.networkId")
@@ -109,29 +142,37 @@ func NewChaincodeSupport(chainname ChainName, getPeerEndpoint func() (*pb.PeerEn
 	} else {
 		s.peerAddress = peerEndpoint.Address
 	}
-	chaincodeLogger.Infof("Chaincode support using peerAddress: %s\n", s.peerAddress)
 	//peerAddress = viper.GetString("peer.address")
 	if s.peerAddress == "" {
 		s.peerAddress = peerAddressDefault
 	}
+	s.peerAddress = chaincodeSupportAddress(s.peerAddress)
+	chaincodeLogger.Infof("Chaincode support using peerAddress: %s\n", s.peerAddress)
 
 	s.userRunsCC = userrunsCC
 
 	s.ccStartupTimeout = ccstartuptimeout
 
+	if s.quorum, s.quorumErr = quorum.NewPolicy("peer.admin.quorum"); s.quorumErr != nil {
+		chaincodeLogger.Errorf("Rejecting deployments: %s", s.quorumErr)
+	}
+
 	//TODO I'm not sure if this needs to be on a per chain basis... too lowel and just needs to be a global default ?
 	s.chaincodeInstallPath = viper.GetString("chaincode.installpath")
 	if s.chaincodeInstallPath == "" {
 		s.chaincodeInstallPath = chaincodeInstallPathDefault
 	}
 
-	s.peerTLS = viper.GetBool("peer.tls.enabled")
+	serviceTLS := comm.GetServiceTLS("chaincode.tls")
+	s.peerTLS = serviceTLS.Enabled
 	if s.peerTLS {
-		s.peerTLSCertFile = viper.GetString("peer.tls.cert.file")
-		s.peerTLSKeyFile = viper.GetString("peer.tls.key.file")
+		s.peerTLSCertFile = serviceTLS.CertFile
+		s.peerTLSKeyFile = serviceTLS.KeyFile
 		s.peerTLSSvrHostOrd = viper.GetString("peer.tls.serverhostoverride")
 	}
 
+	s.messageSizeLimits = comm.GetMessageSizeLimits("chaincode.messageSize")
+
 	kadef := 0
 	if ka := viper.GetString("chaincode.keepalive"); ka == "" {
 		s.keepalive = time.Duration(kadef) * time.Second
@@ -156,6 +197,29 @@ func NewChaincodeSupport(chainname ChainName, getPeerEndpoint func() (*pb.PeerEn
 // 	Recv() (*pb.ChaincodeMessage, error)
 // }
 
+// chaincodeSupportAddress returns the address chaincodes connect to, given
+// the address of the peer. Chaincode support listening on its own address,
+// chaincode.listenAddress, is reached at chaincode.address, or on the host
+// of the peer at the port it listens on.
+func chaincodeSupportAddress(peerAddress string) string {
+	if address := viper.GetString("chaincode.address"); address != "" {
+		return address
+	}
+	listenAddress := viper.GetString("chaincode.listenAddress")
+	if listenAddress == "" {
+		return peerAddress
+	}
+	host, _, err := net.SplitHostPort(peerAddress)
+	if err != nil {
+		return listenAddress
+	}
+	_, port, err := net.SplitHostPort(listenAddress)
+	if err != nil {
+		return listenAddress
+	}
+	return net.JoinHostPort(host, port)
+}
+
 // ChaincodeSupport responsible for providing interfacing with chaincodes from the Peer.
 type ChaincodeSupport struct {
 	name                 ChainName
@@ -172,6 +236,10 @@ type ChaincodeSupport struct {
 	peerTLSKeyFile       string
 	peerTLSSvrHostOrd    string
 	keepalive            time.Duration
+	messageSizeLimits    comm.MessageSizeLimits
+	executing            int32
+	quorum               *quorum.Policy
+	quorumErr            error
 }
 
 // DuplicateChaincodeHandlerError returned if attempt to register same chaincodeID while a stream already exists.
@@ -255,9 +323,16 @@ func (chaincodeSupport *ChaincodeSupport) sendInitOrReady(context context.Contex
 	}
 	chaincodeSupport.runningChaincodes.Unlock()
 
+	atomic.AddInt32(&chaincodeSupport.executing, 1)
+	defer atomic.AddInt32(&chaincodeSupport.executing, -1)
+	executing.Inc()
+	defer executing.Dec()
+	start := time.Now()
+
 	var notfy chan *pb.ChaincodeMessage
 	var err error
 	if notfy, err = chrte.handler.initOrReady(uuid, f, initArgs, tx, depTx); err != nil {
+		observeExecution(pb.ChaincodeMessage_INIT, start, nil, err)
 		return fmt.Errorf("Error sending %s: %s", pb.ChaincodeMessage_INIT, err)
 	}
 	if notfy != nil {
@@ -274,6 +349,7 @@ func (chaincodeSupport *ChaincodeSupport) sendInitOrReady(context context.Contex
 	//if initOrReady succeeded, our responsibility to delete the context
 	chrte.handler.deleteTxContext(uuid)
 
+	observeExecution(pb.ChaincodeMessage_INIT, start, nil, err)
 	return err
 }
 
@@ -290,6 +366,9 @@ func (chaincodeSupport *ChaincodeSupport) getArgsAndEnv(cID *pb.ChaincodeID, cLa
 	} else {
 		envs = append(envs, "CORE_PEER_TLS_ENABLED=false")
 	}
+	//the chaincode applies the same message size limits as the peer
+	envs = append(envs, fmt.Sprintf("CORE_CHAINCODE_MESSAGESIZE_SEND=%d", chaincodeSupport.messageSizeLimits.Send))
+	envs = append(envs, fmt.Sprintf("CORE_CHAINCODE_MESSAGESIZE_RECV=%d", chaincodeSupport.messageSizeLimits.Recv))
 	switch cLang {
 	case pb.ChaincodeSpec_GOLANG, pb.ChaincodeSpec_CAR:
 		//chaincode executable will be same as the name of the chaincode
@@ -640,14 +719,21 @@ func (chaincodeSupport *ChaincodeSupport) Execute(ctxt context.Context, chaincod
 	chrte, ok := chaincodeSupport.chaincodeHasBeenLaunched(chaincode)
 	if !ok {
 		chaincodeSupport.runningChaincodes.Unlock()
-		chaincodeLogger.Debugf("cannot execute-chaincode is not running: %s", chaincode)
+		flogging.WithTx(chaincodeLogger, msg.Uuid, chaincode).Debugf("cannot execute-chaincode is not running")
 		return nil, fmt.Errorf("Cannot execute transaction or query for %s", chaincode)
 	}
 	chaincodeSupport.runningChaincodes.Unlock()
 
+	atomic.AddInt32(&chaincodeSupport.executing, 1)
+	defer atomic.AddInt32(&chaincodeSupport.executing, -1)
+	executing.Inc()
+	defer executing.Dec()
+	start := time.Now()
+
 	var notfy chan *pb.ChaincodeMessage
 	var err error
 	if notfy, err = chrte.handler.sendExecuteMessage(msg, tx); err != nil {
+		observeExecution(msg.Type, start, nil, err)
 		return nil, fmt.Errorf("Error sending %s: %s", msg.Type.String(), err)
 	}
 	var ccresp *pb.ChaincodeMessage
@@ -657,10 +743,24 @@ func (chaincodeSupport *ChaincodeSupport) Execute(ctxt context.Context, chaincod
 		//are typically treated as error
 	case <-time.After(timeout):
 		err = fmt.Errorf("Timeout expired while executing transaction")
+		flogging.WithTx(chaincodeLogger, msg.Uuid, chaincode).Warningf("Timeout of %s expired while executing %s", timeout, msg.Type)
 	}
 
 	//our responsibility to delete transaction context if sendExecuteMessage succeeded
 	chrte.handler.deleteTxContext(msg.Uuid)
 
+	observeExecution(msg.Type, start, ccresp, err)
 	return ccresp, err
 }
+
+// WaitExecutions waits until no transaction or query is executing in a
+// chaincode or the deadline passes, and returns the number still executing
+func (chaincodeSupport *ChaincodeSupport) WaitExecutions(deadline time.Time) int {
+	for {
+		executing := int(atomic.LoadInt32(&chaincodeSupport.executing))
+		if executing == 0 || !time.Now().Before(deadline) {
+			return executing
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
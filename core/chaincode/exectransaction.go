@@ -25,7 +25,10 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/quorum"
+	"github.com/hyperledger/fabric/core/trace"
 	"github.com/hyperledger/fabric/events/producer"
+	"github.com/hyperledger/fabric/flogging"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -134,9 +137,10 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 
 //ExecuteTransactions - will execute transactions on the array one by one
 //will return an array of errors one for each transaction. If the execution
-//succeeded, array element will be nil. returns []byte of state hash or
+//succeeded, array element will be nil. The results returned by the chaincode
+//are returned in an array of the same length. returns []byte of state hash or
 //error
-func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Transaction) (succeededTXs []*pb.Transaction, stateHash []byte, ccevents []*pb.ChaincodeEvent, txerrs []error, err error) {
+func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Transaction) (succeededTXs []*pb.Transaction, stateHash []byte, results [][]byte, ccevents []*pb.ChaincodeEvent, txerrs []error, err error) {
 	var chain = GetChain(cname)
 	if chain == nil {
 		// TODO: We should never get here, but otherwise a good reminder to better handle
@@ -144,13 +148,33 @@ func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Tran
 	}
 
 	txerrs = make([]error, len(xacts))
+	results = make([][]byte, len(xacts))
 	ccevents = make([]*pb.ChaincodeEvent, len(xacts))
 	var succeededTxs = make([]*pb.Transaction, 0)
+
+	// Verify the certificates and signatures of the whole batch up front,
+	// in parallel, rather than one transaction at a time
+	if secHelper := chain.getSecHelper(); nil != secHelper {
+		copy(txerrs, secHelper.TransactionsPreValidation(xacts))
+	}
+
 	for i, t := range xacts {
-		_, ccevents[i], txerrs[i] = Execute(ctxt, chain, t)
+		span := trace.StartTransactionSpan("execute", t, time.Now())
+		if txerrs[i] == nil && t.Type == pb.Transaction_CHAINCODE_DEPLOY {
+			txerrs[i] = verifyDeployApprovals(chain, t)
+		}
+		if txerrs[i] != nil {
+			span.Finish(txerrs[i])
+			flogging.WithTx(chaincodeLogger, t.Uuid, "").Infof("Transaction rejected: %s", txerrs[i])
+			sendTxRejectedEvent(xacts[i], txerrs[i].Error())
+			continue
+		}
+		results[i], ccevents[i], txerrs[i] = Execute(ctxt, chain, t)
+		span.Finish(txerrs[i])
 		if txerrs[i] == nil {
 			succeededTxs = append(succeededTxs, t)
 		} else {
+			flogging.WithTx(chaincodeLogger, t.Uuid, "").Infof("Transaction failed: %s", txerrs[i])
 			sendTxRejectedEvent(xacts[i], txerrs[i].Error())
 		}
 	}
@@ -161,7 +185,36 @@ func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Tran
 		stateHash, err = lgr.GetTempStateHash()
 	}
 
-	return succeededTxs, stateHash, ccevents, txerrs, err
+	return succeededTxs, stateHash, results, ccevents, txerrs, err
+}
+
+// verifyDeployApprovals returns an error unless the deploy transaction t
+// carries the approval of the quorum of administrators, if one is
+// configured. Deployments of system chaincodes do not go through blocks and
+// are not subject to it.
+func verifyDeployApprovals(chain *ChaincodeSupport, t *pb.Transaction) error {
+	if chain.quorumErr != nil {
+		return chain.quorumErr
+	}
+	if !chain.quorum.Enabled() {
+		return nil
+	}
+
+	if secHelper := chain.getSecHelper(); nil != secHelper && t.ConfidentialityLevel == pb.ConfidentialityLevel_CONFIDENTIAL {
+		var err error
+		if t, err = secHelper.TransactionPreExecution(t); err != nil {
+			return err
+		}
+	}
+	cds := &pb.ChaincodeDeploymentSpec{}
+	if err := proto.Unmarshal(t.Payload, cds); err != nil {
+		return fmt.Errorf("Failed to unmarshal deployment spec (%s)", err)
+	}
+	if cds.ChaincodeSpec == nil || cds.ChaincodeSpec.ChaincodeID == nil {
+		return errors.New("Deployment spec without chaincode ID")
+	}
+	spec := cds.ChaincodeSpec
+	return chain.quorum.Verify(quorum.OperationDeploy, quorum.DeployPayload(spec.ChaincodeID.Name), spec.Approvals, false)
 }
 
 // GetSecureContext returns the security context from the context object or error
@@ -59,8 +59,8 @@ func initMemSrvc() (net.Listener, error) {
 	ca.LogInit(ioutil.Discard, os.Stdout, os.Stdout, os.Stderr, os.Stdout)
 	ca.CacheConfiguration() // Cache configuration
 
-	aca := ca.NewACA()
 	eca := ca.NewECA()
+	aca := ca.NewACA(eca)
 	tca := ca.NewTCA(eca)
 	tlsca := ca.NewTLSCA(eca)
 
@@ -27,6 +27,7 @@ import (
 	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/util"
+	"github.com/hyperledger/fabric/flogging"
 	pb "github.com/hyperledger/fabric/protos"
 	"github.com/looplab/fsm"
 	"github.com/op/go-logging"
@@ -103,6 +104,16 @@ func shortuuid(uuid string) string {
 	return uuid[0:8]
 }
 
+// txLogger returns a logger adding the transaction uuid and the chaincode
+// of handler, once registered, to the records
+func (handler *Handler) txLogger(uuid string) *flogging.Logger {
+	var chaincode string
+	if handler.ChaincodeID != nil {
+		chaincode = handler.ChaincodeID.Name
+	}
+	return flogging.WithTx(chaincodeLogger, uuid, chaincode)
+}
+
 func (handler *Handler) serialSend(msg *pb.ChaincodeMessage) error {
 	handler.serialLock.Lock()
 	defer handler.serialLock.Unlock()
@@ -310,9 +321,9 @@ func (handler *Handler) processStream() error {
 				chaincodeLogger.Debug("Received nil message, ending chaincode support stream")
 				return err
 			}
-			chaincodeLogger.Debugf("[%s]Received message %s from shim", shortuuid(in.Uuid), in.Type.String())
+			handler.txLogger(in.Uuid).Debugf("Received message %s from shim", in.Type.String())
 			if in.Type.String() == pb.ChaincodeMessage_ERROR.String() {
-				chaincodeLogger.Errorf("Got error: %s", string(in.Payload))
+				handler.txLogger(in.Uuid).Errorf("Got error: %s", string(in.Payload))
 			}
 
 			// we can spin off another Recv again
@@ -331,7 +342,7 @@ func (handler *Handler) processStream() error {
 				chaincodeLogger.Debug("Next state nil message, ending chaincode support stream")
 				return err
 			}
-			chaincodeLogger.Debugf("[%s]Move state message %s", shortuuid(in.Uuid), in.Type.String())
+			handler.txLogger(in.Uuid).Debugf("Move state message %s", in.Type.String())
 		case <-handler.waitForKeepaliveTimer():
 			if handler.chaincodeSupport.keepalive <= 0 {
 				chaincodeLogger.Errorf("Invalid select: keepalive not on (keepalive=%d)", handler.chaincodeSupport.keepalive)
@@ -351,14 +362,14 @@ func (handler *Handler) processStream() error {
 
 		err = handler.HandleMessage(in)
 		if err != nil {
-			chaincodeLogger.Errorf("[%s]Error handling message, ending stream: %s", shortuuid(in.Uuid), err)
+			handler.txLogger(in.Uuid).Errorf("Error handling message, ending stream: %s", err)
 			return fmt.Errorf("Error handling message, ending stream: %s", err)
 		}
 
 		if nsInfo != nil && nsInfo.sendToCC {
-			chaincodeLogger.Debugf("[%s]sending state message %s", shortuuid(in.Uuid), in.Type.String())
+			handler.txLogger(in.Uuid).Debugf("sending state message %s", in.Type.String())
 			if err = handler.serialSend(in); err != nil {
-				chaincodeLogger.Errorf("[%s]serial sending received error %s", shortuuid(in.Uuid), err)
+				handler.txLogger(in.Uuid).Errorf("serial sending received error %s", err)
 				return fmt.Errorf("[%s]serial sending received error %s", shortuuid(in.Uuid), err)
 			}
 		}
@@ -0,0 +1,44 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/metrics"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+var (
+	executions = metrics.NewCounter("fabric_chaincode_executions_total",
+		"Messages executed by the chaincodes, by type, i.e. INIT, TRANSACTION or QUERY, and result.", "type", "result")
+	executionDuration = metrics.NewHistogram("fabric_chaincode_execution_duration_seconds",
+		"Time taken by the chaincodes to execute a message, by type.", metrics.DefaultBuckets, "type")
+	executing = metrics.NewGauge("fabric_chaincode_executing",
+		"Messages being executed by the chaincodes.")
+)
+
+// observeExecution measures the execution of a message of type msgType by a
+// chaincode, which started at start and ended with resp or err
+func observeExecution(msgType pb.ChaincodeMessage_Type, start time.Time, resp *pb.ChaincodeMessage, err error) {
+	result := "success"
+	if err != nil || resp != nil && (resp.Type == pb.ChaincodeMessage_ERROR || resp.Type == pb.ChaincodeMessage_QUERY_ERROR) {
+		result = "failure"
+	}
+	executionDuration.ObserveSince(start, msgType.String())
+	executions.Inc(msgType.String(), result)
+}
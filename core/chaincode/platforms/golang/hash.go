@@ -19,9 +19,11 @@ package golang
 import (
 	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -96,6 +98,60 @@ func hashFilesInDir(rootDir string, dir string, hash []byte, tw *tar.Writer) ([]
 	return hash, nil
 }
 
+// HashPackage computes the name of the chaincode of spec from the code in
+// its package, the gzipped tar written by WritePackage, as generateHashcode
+// computes it from the source files. The signers of a package check with it
+// that the name they approve is that of the code they reviewed.
+func HashPackage(spec *pb.ChaincodeSpec, codePackage []byte) (string, error) {
+	if spec == nil || spec.ChaincodeID == nil || spec.ChaincodeID.Path == "" {
+		return "", errors.New("Cannot hash a package without chaincode path")
+	}
+	ctor := spec.CtorMsg
+	if ctor == nil || ctor.Function == "" {
+		return "", errors.New("Cannot hash a package without ctor")
+	}
+
+	path := spec.ChaincodeID.Path
+	if strings.HasPrefix(path, "http://") {
+		path = path[7:]
+	} else if strings.HasPrefix(path, "https://") {
+		path = path[8:]
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(codePackage))
+	if err != nil {
+		return "", fmt.Errorf("Invalid package: %s", err)
+	}
+	tr := tar.NewReader(gr)
+
+	// The files of the chaincode come in the order they were hashed
+	prefix := filepath.Join("src", path) + "/"
+	hash := util.GenerateHashFromSignature(path, ctor.Function, ctor.Args)
+	found := false
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("Invalid package: %s", err)
+		}
+		if !strings.HasPrefix(header.Name, prefix) {
+			continue
+		}
+		buf, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return "", fmt.Errorf("Invalid package: %s", err)
+		}
+		hash = computeHash(buf, hash)
+		found = true
+	}
+	if !found {
+		return "", fmt.Errorf("No code of %s in the package", path)
+	}
+	return hex.EncodeToString(hash), nil
+}
+
 func isCodeExist(tmppath string) error {
 	file, err := os.Open(tmppath)
 	if err != nil {
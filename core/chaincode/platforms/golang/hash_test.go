@@ -17,13 +17,16 @@ limitations under the License.
 package golang
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"encoding/hex"
 	"math/rand"
 	"testing"
 	"time"
 
 	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
 )
 
 // TestHashContentChange changes a random byte in a content and checks for hash change
@@ -157,3 +160,42 @@ func TestHashOverFiles(t *testing.T) {
 		t.Logf("Hash expected to be unchanged")
 	}
 }
+
+func TestHashPackage(t *testing.T) {
+	spec := &pb.ChaincodeSpec{ChaincodeID: &pb.ChaincodeID{Path: "hashtestfiles"},
+		CtorMsg: &pb.ChaincodeInput{Function: "init", Args: []string{"a", "100"}}}
+
+	// The package of the files hashed, with another file before them
+	buf := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	other := []byte("not chaincode")
+	tw.WriteHeader(&tar.Header{Name: "Dockerfile", Size: int64(len(other))})
+	tw.Write(other)
+	hash := util.GenerateHashFromSignature("hashtestfiles", "init", []string{"a", "100"})
+	hash, err := hashFilesInDir(".", "hashtestfiles", hash, tw)
+	if err != nil {
+		t.Fatalf("Error hashing files: %s", err)
+	}
+	tw.Close()
+	gw.Close()
+
+	name, err := HashPackage(spec, buf.Bytes())
+	if err != nil {
+		t.Fatalf("Error hashing the package: %s", err)
+	}
+	if expected := hex.EncodeToString(hash); name != expected {
+		t.Errorf("Expected the name %s computed from the files, got %s", expected, name)
+	}
+
+	// Another constructor makes another chaincode
+	spec.CtorMsg.Args = []string{"a", "200"}
+	if other, _ := HashPackage(spec, buf.Bytes()); other == name {
+		t.Error("Expected another name for another constructor")
+	}
+
+	spec.ChaincodeID.Path = "missing"
+	if _, err = HashPackage(spec, buf.Bytes()); err == nil {
+		t.Error("Expected an error for a package without the code of the chaincode")
+	}
+}
@@ -155,10 +155,11 @@ func getPeerAddress() string {
 
 func newPeerClientConnection() (*grpc.ClientConn, error) {
 	var peerAddress = getPeerAddress()
+	limits := comm.GetMessageSizeLimits("chaincode.messageSize")
 	if comm.TLSEnabled() {
-		return comm.NewClientConnectionWithAddress(peerAddress, true, true, comm.InitTLSForPeer())
+		return comm.NewClientConnectionWithAddress(peerAddress, true, true, comm.InitTLSForPeer(), limits.DialOption())
 	}
-	return comm.NewClientConnectionWithAddress(peerAddress, true, false, nil)
+	return comm.NewClientConnectionWithAddress(peerAddress, true, false, nil, limits.DialOption())
 }
 
 func chatWithPeer(chaincodename string, stream PeerChaincodeStream, cc Chaincode) error {
@@ -635,6 +636,13 @@ func (stub *ChaincodeStub) GetCallerMetadata() ([]byte, error) {
 	return stub.securityContext.Metadata, nil
 }
 
+// GetTransactionMetadata returns the metadata key/values the transaction was
+// submitted with, nil if it has none. It fails if the caller metadata is
+// not key/values.
+func (stub *ChaincodeStub) GetTransactionMetadata() (map[string]string, error) {
+	return pb.DecodeTransactionMetadata(stub.securityContext.Metadata)
+}
+
 // GetBinding returns the transaction binding
 func (stub *ChaincodeStub) GetBinding() ([]byte, error) {
 	return stub.securityContext.Binding, nil
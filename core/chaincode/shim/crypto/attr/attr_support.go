@@ -20,6 +20,8 @@ import (
 	"bytes"
 	"crypto/x509"
 	"errors"
+	"google/protobuf"
+	"time"
 
 	"github.com/hyperledger/fabric/core/crypto/attributes"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
@@ -43,6 +45,14 @@ type chaincodeHolder interface {
 	*/
 }
 
+// txTimestampHolder is implemented by the holders of a transaction, such as
+// ChaincodeStub. The attributes read from their certificate must be valid at
+// the timestamp of the transaction, which all validators agree on.
+type txTimestampHolder interface {
+	// GetTxTimestamp returns the timestamp of the transaction
+	GetTxTimestamp() (*google_protobuf.Timestamp, error)
+}
+
 //AttributesHandler is an entity can be used to both verify and read attributes.
 //		The functions declared can be used to access the attributes stored in the transaction certificates from the application layer. Can be used directly from the ChaincodeStub API but
 //		 if you need multiple access create a hanlder is better:
@@ -81,6 +91,8 @@ type AttributesHandlerImpl struct {
 	keys      map[string][]byte
 	header    map[string]int
 	encrypted bool
+	validity  map[string]time.Time
+	at        time.Time
 }
 
 type chaincodeHolderImpl struct {
@@ -139,8 +151,20 @@ func NewAttributesHandlerImpl(holder chaincodeHolder) (*AttributesHandlerImpl, e
 			}
 		}*/
 
+	// Attributes are checked to be valid at the timestamp of the transaction, if any
+	var at time.Time
+	if txHolder, ok := holder.(txTimestampHolder); ok {
+		ts, err := txHolder.GetTxTimestamp()
+		if err != nil {
+			return nil, err
+		}
+		if ts != nil {
+			at = time.Unix(ts.Seconds, int64(ts.Nanos))
+		}
+	}
+
 	cache := make(map[string][]byte)
-	return &AttributesHandlerImpl{tcert, cache, keys, nil, false}, nil
+	return &AttributesHandlerImpl{tcert, cache, keys, nil, false, nil, at}, nil
 }
 
 func (attributesHandler *AttributesHandlerImpl) readHeader() (map[string]int, bool, error) {
@@ -156,6 +180,22 @@ func (attributesHandler *AttributesHandlerImpl) readHeader() (map[string]int, bo
 	return header, encrypted, nil
 }
 
+// checkValidity returns an error if the attribute has expired at the
+// timestamp of the transaction.
+func (attributesHandler *AttributesHandlerImpl) checkValidity(attributeName string) error {
+	if attributesHandler.at.IsZero() {
+		return nil
+	}
+	if attributesHandler.validity == nil {
+		validity, err := attributes.ReadAttributesValidity(attributesHandler.cert, attributesHandler.keys[attributes.HeaderAttributeName])
+		if err != nil {
+			return err
+		}
+		attributesHandler.validity = validity
+	}
+	return attributes.CheckAttributeValidity(attributesHandler.validity, attributeName, attributesHandler.at)
+}
+
 //GetValue is used to read an specific attribute from the transaction certificate, *attributeName* is passed as input parameter to this function.
 //	Example:
 //  	attrValue,error:=handler.GetValue("position")
@@ -167,6 +207,9 @@ func (attributesHandler *AttributesHandlerImpl) GetValue(attributeName string) (
 	if err != nil {
 		return nil, err
 	}
+	if err = attributesHandler.checkValidity(attributeName); err != nil {
+		return nil, err
+	}
 	value, err := attributes.ReadTCertAttributeByPosition(attributesHandler.cert, header[attributeName])
 	if err != nil {
 		return nil, errors.New("Error reading attribute value '" + err.Error() + "'")
@@ -19,11 +19,15 @@ package attr
 import (
 	"bytes"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/pem"
 	"errors"
+	"google/protobuf"
 	"io/ioutil"
 	"testing"
+	"time"
 
+	"github.com/hyperledger/fabric/core/crypto/attributes"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 )
 
@@ -87,6 +91,57 @@ func (shim *metadataErrorMock) GetCallerMetadata() ([]byte, error) {
 	return nil, errors.New("GetCallerCertificate error")
 }*/
 
+type txStubMock struct {
+	chaincodeStubMock
+	txTimestamp *google_protobuf.Timestamp
+}
+
+// GetTxTimestamp returns the timestamp of the transaction
+func (shim *txStubMock) GetTxTimestamp() (*google_protobuf.Timestamp, error) {
+	return shim.txTimestamp, nil
+}
+
+func TestVerifyAttribute_Expired(t *testing.T) {
+	primitives.SetSecurityLevel("SHA3", 256)
+
+	tcert, err := loadTCertClear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry := time.Unix(1500000000, 0)
+	validity, err := attributes.BuildAttributesValidity(map[string]time.Time{"position": expiry})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		at    time.Time
+		valid bool
+	}{
+		{expiry.Add(-time.Second), true},
+		{expiry, false},
+	} {
+		stub := &txStubMock{chaincodeStubMock{callerCert: tcert.Raw}, &google_protobuf.Timestamp{Seconds: c.at.Unix()}}
+		handler, err := NewAttributesHandlerImpl(stub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler.cert.Extensions = append(handler.cert.Extensions, pkix.Extension{Id: attributes.TCertAttributesValidity, Value: validity})
+
+		isOk, err := handler.VerifyAttribute("position", []byte("Software Engineer"))
+		if c.valid && (err != nil || !isOk) {
+			t.Fatalf("Expected the attribute to be verified at %v, got %v, %v", c.at, isOk, err)
+		}
+		if !c.valid && err == nil {
+			t.Fatalf("Expected the attribute to have expired at %v", c.at)
+		}
+		// Attributes without validity do not expire
+		if isOk, err = handler.VerifyAttribute("company", []byte("ACompany")); err != nil || !isOk {
+			t.Fatalf("Expected the attribute without validity to be verified, got %v, %v", isOk, err)
+		}
+	}
+}
+
 func TestVerifyAttribute(t *testing.T) {
 	primitives.SetSecurityLevel("SHA3", 256)
 
@@ -0,0 +1,238 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/audit"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/credentials"
+)
+
+// AccessList admits or refuses the connections to a gRPC server by the
+// address of the client and the subject of the TLS certificate it presents.
+// Denials take precedence, and if there are allow rules of a kind, a client
+// must match one of them.
+type AccessList struct {
+	allow         []*net.IPNet
+	deny          []*net.IPNet
+	allowSubjects []string
+	denySubjects  []string
+}
+
+// NewAccessList reads the access list configured under key, e.g.
+// peer.accessControl for peer.accessControl.allow, .deny, .allowSubjects
+// and .denySubjects. Addresses are given as IPs or CIDR ranges, subjects as
+// distinguished names like CN=vp1,O=Example.
+func NewAccessList(key string) (*AccessList, error) {
+	al := &AccessList{
+		allowSubjects: viper.GetStringSlice(key + ".allowSubjects"),
+		denySubjects:  viper.GetStringSlice(key + ".denySubjects"),
+	}
+	var err error
+	if al.allow, err = parseNetworks(viper.GetStringSlice(key + ".allow")); err != nil {
+		return nil, fmt.Errorf("Error parsing %s.allow: %s", key, err)
+	}
+	if al.deny, err = parseNetworks(viper.GetStringSlice(key + ".deny")); err != nil {
+		return nil, fmt.Errorf("Error parsing %s.deny: %s", key, err)
+	}
+	return al, nil
+}
+
+func parseNetworks(entries []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %s", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// AdmitAddress returns an error if connections from addr are refused
+func (al *AccessList) AdmitAddress(addr net.Addr) error {
+	if len(al.allow) == 0 && len(al.deny) == 0 {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return fmt.Errorf("Refusing connection from unknown address %s", addr)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("Refusing connection from unknown address %s", addr)
+	}
+	if inNetworks(ip, al.deny) {
+		return fmt.Errorf("Refusing connection from denied address %s", ip)
+	}
+	if len(al.allow) > 0 && !inNetworks(ip, al.allow) {
+		return fmt.Errorf("Refusing connection from address %s, which is not allowed", ip)
+	}
+	return nil
+}
+
+func inNetworks(ip net.IP, networks []*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSubjectRules returns whether clients are admitted by the subject of
+// their certificate, which they only present if client authentication is
+// enabled
+func (al *AccessList) HasSubjectRules() bool {
+	return len(al.allowSubjects) > 0 || len(al.denySubjects) > 0
+}
+
+// AdmitCertificate returns an error if connections presenting cert, nil if
+// none was presented, are refused
+func (al *AccessList) AdmitCertificate(cert *x509.Certificate) error {
+	if !al.HasSubjectRules() {
+		return nil
+	}
+	if cert == nil {
+		if len(al.allowSubjects) > 0 {
+			return fmt.Errorf("Refusing connection without client certificate")
+		}
+		return nil
+	}
+	subject := subjectDN(cert.Subject)
+	if inSubjects(subject, al.denySubjects) {
+		return fmt.Errorf("Refusing connection from denied subject %s", subject)
+	}
+	if len(al.allowSubjects) > 0 && !inSubjects(subject, al.allowSubjects) {
+		return fmt.Errorf("Refusing connection from subject %s, which is not allowed", subject)
+	}
+	return nil
+}
+
+func inSubjects(subject string, subjects []string) bool {
+	for _, s := range subjects {
+		if strings.EqualFold(strings.Replace(s, ", ", ",", -1), subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// SubjectIdentity returns the subject of cert as it is configured in access
+// lists, the empty string if cert is nil
+func SubjectIdentity(cert *x509.Certificate) string {
+	if cert == nil {
+		return ""
+	}
+	return subjectDN(cert.Subject)
+}
+
+// subjectDN formats the distinguished name the way it is configured, most
+// specific attribute first
+func subjectDN(name pkix.Name) string {
+	var parts []string
+	add := func(key string, values ...string) {
+		for _, value := range values {
+			if value != "" {
+				parts = append(parts, key+"="+value)
+			}
+		}
+	}
+	add("CN", name.CommonName)
+	add("OU", name.OrganizationalUnit...)
+	add("O", name.Organization...)
+	add("L", name.Locality...)
+	add("ST", name.Province...)
+	add("C", name.Country...)
+	return strings.Join(parts, ",")
+}
+
+// Listener returns lis, closing the connections from refused addresses
+// as they are accepted
+func (al *AccessList) Listener(lis net.Listener) net.Listener {
+	return &accessListener{Listener: lis, access: al}
+}
+
+type accessListener struct {
+	net.Listener
+	access *AccessList
+}
+
+func (l *accessListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if err := l.access.AdmitAddress(conn.RemoteAddr()); err != nil {
+			commLogger.Warning(err.Error())
+			audit.Record(pb.SecurityEvent_ACCESS_DENIED, "", conn.RemoteAddr().String(), err.Error())
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
+
+// Credentials returns creds, failing the TLS handshakes of clients
+// presenting refused certificates
+func (al *AccessList) Credentials(creds credentials.TransportAuthenticator) credentials.TransportAuthenticator {
+	return &accessCredentials{TransportAuthenticator: creds, access: al}
+}
+
+type accessCredentials struct {
+	credentials.TransportAuthenticator
+	access *AccessList
+}
+
+func (c *accessCredentials) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, authInfo, err := c.TransportAuthenticator.ServerHandshake(rawConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cert *x509.Certificate
+	if tlsInfo, ok := authInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+		cert = tlsInfo.State.PeerCertificates[0]
+	}
+	if err := c.access.AdmitCertificate(cert); err != nil {
+		commLogger.Warningf("%s: %s", rawConn.RemoteAddr(), err)
+		audit.Record(pb.SecurityEvent_ACCESS_DENIED, SubjectIdentity(cert), rawConn.RemoteAddr().String(), err.Error())
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, authInfo, nil
+}
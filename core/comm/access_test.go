@@ -0,0 +1,118 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func newTestAccessList(t *testing.T, config map[string][]string) *AccessList {
+	for _, key := range []string{"allow", "deny", "allowSubjects", "denySubjects"} {
+		viper.Set("test.accessControl."+key, config[key])
+	}
+	al, err := NewAccessList("test.accessControl")
+	if err != nil {
+		t.Fatalf("Error creating access list: %s", err)
+	}
+	return al
+}
+
+func TestAccessListAddresses(t *testing.T) {
+	al := newTestAccessList(t, map[string][]string{
+		"allow": {"10.0.0.0/8", "192.168.1.5"},
+		"deny":  {"10.1.0.0/16"},
+	})
+	for addr, admitted := range map[string]bool{
+		"10.2.3.4:1000":    true,
+		"192.168.1.5:1000": true,
+		"10.1.2.3:1000":    false,
+		"192.168.1.6:1000": false,
+	} {
+		tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+		if err := al.AdmitAddress(tcpAddr); (err == nil) != admitted {
+			t.Errorf("Expected %s to be admitted %t, got %v", addr, admitted, err)
+		}
+	}
+
+	viper.Set("test.accessControl.deny", []string{"10.1.0.0/33"})
+	if _, err := NewAccessList("test.accessControl"); err == nil {
+		t.Errorf("Expected an invalid CIDR range to be rejected")
+	}
+}
+
+func TestAccessListSubjects(t *testing.T) {
+	vp1 := &x509.Certificate{Subject: pkix.Name{CommonName: "vp1", Organization: []string{"Example"}}}
+	vp2 := &x509.Certificate{Subject: pkix.Name{CommonName: "vp2", Organization: []string{"Example"}}}
+
+	al := newTestAccessList(t, map[string][]string{"allowSubjects": {"CN=vp1, O=Example"}})
+	if err := al.AdmitCertificate(vp1); err != nil {
+		t.Errorf("Expected vp1 to be admitted: %s", err)
+	}
+	if err := al.AdmitCertificate(vp2); err == nil {
+		t.Errorf("Expected vp2 to be refused")
+	}
+	if err := al.AdmitCertificate(nil); err == nil {
+		t.Errorf("Expected a client without certificate to be refused")
+	}
+
+	al = newTestAccessList(t, map[string][]string{"denySubjects": {"CN=vp2,O=Example"}})
+	if err := al.AdmitCertificate(vp1); err != nil {
+		t.Errorf("Expected vp1 to be admitted: %s", err)
+	}
+	if err := al.AdmitCertificate(vp2); err == nil {
+		t.Errorf("Expected vp2 to be refused")
+	}
+	if err := al.AdmitCertificate(nil); err != nil {
+		t.Errorf("Expected a client without certificate to be admitted: %s", err)
+	}
+}
+
+func TestAccessListListener(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	al := newTestAccessList(t, map[string][]string{"deny": {"127.0.0.0/8"}})
+	lis = al.Listener(lis)
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := lis.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// The refused connection is closed by the listener
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Expected the connection from a denied address to be closed")
+	}
+	select {
+	case <-accepted:
+		t.Errorf("Expected the connection from a denied address not to be accepted")
+	default:
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/credentials"
+)
+
+// NormalizeAddress returns address in the form used to compare addresses,
+// host:port with IP literals in their canonical form and IPv6 literals in
+// brackets, e.g. [2001:db8::1]:30303
+func NormalizeAddress(address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("Invalid address %s, expected host:port with IPv6 hosts in brackets, e.g. [2001:db8::1]:30303: %s", address, err)
+	}
+	ip, zone := host, ""
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		ip, zone = host[:i], host[i:]
+	}
+	if parsed := net.ParseIP(ip); parsed != nil {
+		host = parsed.String() + zone
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// Listen announces on the local address. If peer.dualStack is set, an
+// address with an unspecified host, e.g. 0.0.0.0:30303 or [::]:30303,
+// accepts both IPv4 and IPv6 connections, otherwise 0.0.0.0 only accepts
+// IPv4 and :: only IPv6 connections. It listens on the network set with
+// SetNetwork instead, if any.
+func Listen(address string) (net.Listener, error) {
+	if n := getNetwork(); n != nil {
+		return n.Listen(address)
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid listen address %s, expected host:port with IPv6 hosts in brackets, e.g. [::]:30303: %s", address, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsUnspecified() {
+		return net.Listen("tcp", address)
+	}
+	if viper.GetBool("peer.dualStack") {
+		return net.Listen("tcp", net.JoinHostPort("", port))
+	}
+	if ip.To4() != nil {
+		return net.Listen("tcp4", address)
+	}
+	return net.Listen("tcp6", address)
+}
+
+// bracketlessCredentials hands the address of the server to the TLS
+// handshake without the brackets around IPv6 hosts, which take the host to
+// verify the server's certificate against to be everything before the last
+// colon
+type bracketlessCredentials struct {
+	credentials.TransportAuthenticator
+}
+
+func (c bracketlessCredentials) ClientHandshake(addr string, rawConn net.Conn, timeout time.Duration) (net.Conn, credentials.AuthInfo, error) {
+	if host, port, err := net.SplitHostPort(addr); err == nil && strings.Contains(host, ":") {
+		addr = host + ":" + port
+	}
+	return c.TransportAuthenticator.ClientHandshake(addr, rawConn, timeout)
+}
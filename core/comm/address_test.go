@@ -0,0 +1,97 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/credentials"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	for address, expected := range map[string]string{
+		"10.0.0.1:30303":              "10.0.0.1:30303",
+		"vp0.example.com:30303":       "vp0.example.com:30303",
+		"[2001:DB8:0:0::1]:30303":     "[2001:db8::1]:30303",
+		"[::ffff:10.0.0.1]:30303":     "10.0.0.1:30303",
+		"[fe80::0:1%eth0]:30303":      "[fe80::1%eth0]:30303",
+		":30303":                      ":30303",
+		"[0:0:0:0:0:0:0:0]:30303":     "[::]:30303",
+		"[2001:db8::1]:30303":         "[2001:db8::1]:30303",
+		"[2001:0db8:0000::0001]:6060": "[2001:db8::1]:6060",
+	} {
+		if normalized, err := NormalizeAddress(address); err != nil || normalized != expected {
+			t.Errorf("Expected %s to be normalized to %s, got %s, %v", address, expected, normalized, err)
+		}
+	}
+	for _, address := range []string{"2001:db8::1:30303", "10.0.0.1", ""} {
+		if _, err := NormalizeAddress(address); err == nil {
+			t.Errorf("Expected %s to be rejected", address)
+		}
+	}
+}
+
+func TestListenUnspecifiedHost(t *testing.T) {
+	defer viper.Set("peer.dualStack", viper.GetBool("peer.dualStack"))
+
+	viper.Set("peer.dualStack", false)
+	lis, err := Listen("0.0.0.0:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	if ip := lis.Addr().(*net.TCPAddr).IP; ip.To4() == nil {
+		t.Errorf("Expected 0.0.0.0 to only accept IPv4 without dual stack, listening on %s", ip)
+	}
+	lis.Close()
+
+	viper.Set("peer.dualStack", true)
+	lis, err = Listen("0.0.0.0:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	lis.Close()
+
+	if _, err := Listen("::1:0"); err == nil {
+		t.Errorf("Expected an IPv6 host without brackets to be rejected")
+	}
+}
+
+type addrRecorder struct {
+	credentials.TransportAuthenticator
+	addr string
+}
+
+func (r *addrRecorder) ClientHandshake(addr string, rawConn net.Conn, timeout time.Duration) (net.Conn, credentials.AuthInfo, error) {
+	r.addr = addr
+	return rawConn, nil, nil
+}
+
+func TestBracketlessCredentials(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"[2001:db8::1]:30303": "2001:db8::1:30303",
+		"vp0:30303":           "vp0:30303",
+	} {
+		recorder := &addrRecorder{}
+		bracketlessCredentials{recorder}.ClientHandshake(addr, nil, 0)
+		if recorder.addr != expected {
+			t.Errorf("Expected the handshake with %s to take %s, got %s", addr, expected, recorder.addr)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// CompressionGzip is the gzip encoding of message payloads. Snappy, cheaper
+// on CPU for a lower ratio, is to be added as another encoding once it is
+// vendored.
+const CompressionGzip = "gzip"
+
+// Compressions returns the payload encodings this peer can decode, in order
+// of preference
+func Compressions() []string {
+	return []string{CompressionGzip}
+}
+
+// Compress returns data encoded with encoding
+func Compress(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("Unsupported payload encoding %q", encoding)
+	}
+}
+
+// Decompress returns data decoded from encoding. It fails rather than
+// decode more than limit bytes, unless limit is 0, so that a small
+// compressed payload cannot exhaust the memory of the peer.
+func Decompress(encoding string, data []byte, limit int) ([]byte, error) {
+	switch encoding {
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding gzip payload: %s", err)
+		}
+		defer r.Close()
+		var reader io.Reader = r
+		if limit > 0 {
+			reader = io.LimitReader(r, int64(limit)+1)
+		}
+		decoded, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding gzip payload: %s", err)
+		}
+		if limit > 0 && len(decoded) > limit {
+			return nil, fmt.Errorf("Decoded payload exceeds the maximum message size to receive of %d bytes", limit)
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("Unsupported payload encoding %q", encoding)
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("block payload "), 1000)
+	compressed, err := Compress(CompressionGzip, data)
+	if err != nil {
+		t.Fatalf("Error compressing: %s", err)
+	}
+	if len(compressed) >= len(data) {
+		t.Errorf("Expected a repetitive payload to compress, got %d bytes from %d", len(compressed), len(data))
+	}
+	decompressed, err := Decompress(CompressionGzip, compressed, 0)
+	if err != nil {
+		t.Fatalf("Error decompressing: %s", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Errorf("Expected the decompressed payload to match the original")
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	data := make([]byte, 1<<20)
+	compressed, err := Compress(CompressionGzip, data)
+	if err != nil {
+		t.Fatalf("Error compressing: %s", err)
+	}
+	if _, err := Decompress(CompressionGzip, compressed, len(data)); err != nil {
+		t.Errorf("Expected a payload of the maximum size to be decoded: %s", err)
+	}
+	if _, err := Decompress(CompressionGzip, compressed, len(data)-1); err == nil {
+		t.Errorf("Expected a payload decoding beyond the limit to be refused")
+	}
+}
+
+func TestUnsupportedCompression(t *testing.T) {
+	if _, err := Compress("lzma", []byte("data")); err == nil {
+		t.Errorf("Expected an unsupported encoding to be refused")
+	}
+	if _, err := Decompress(CompressionGzip, []byte("not gzip"), 0); err == nil {
+		t.Errorf("Expected a corrupt payload to be refused")
+	}
+}
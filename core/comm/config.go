@@ -25,12 +25,14 @@ var configurationCached = false
 
 // Cached values of commonly used configuration constants.
 var tlsEnabled bool
+var tlsClientAuthEnabled bool
 
 // CacheConfiguration computes and caches commonly-used constants and
 // computed constants as package variables. Routines which were previously
 func CacheConfiguration() (err error) {
 
 	tlsEnabled = viper.GetBool("peer.tls.enabled")
+	tlsClientAuthEnabled = tlsEnabled && viper.GetBool("peer.tls.clientAuth.enabled")
 
 	configurationCached = true
 
@@ -51,3 +53,12 @@ func TLSEnabled() bool {
 	}
 	return tlsEnabled
 }
+
+// TLSClientAuthEnabled return cached value for "peer.tls.clientAuth.enabled"
+// configuration value, which only applies if TLS is enabled
+func TLSClientAuthEnabled() bool {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return tlsClientAuthEnabled
+}
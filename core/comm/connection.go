@@ -1,8 +1,15 @@
 package comm
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
 	"time"
 
+	"golang.org/x/net/context"
+
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/grpclog"
@@ -15,18 +22,40 @@ const defaultTimeout = time.Second * 3
 
 var commLogger = logging.MustGetLogger("comm")
 
+// serverCertificate is the TLS certificate the peer's servers present, it
+// can be replaced while they are running
+var serverCertificate struct {
+	sync.RWMutex
+	cert *tls.Certificate
+}
+
 // NewClientConnectionWithAddress Returns a new grpc.ClientConn to the given address.
-func NewClientConnectionWithAddress(peerAddress string, block bool, tslEnabled bool, creds credentials.TransportAuthenticator) (*grpc.ClientConn, error) {
+// Further dial options, e.g. the message size limits of the service, can be appended.
+// The connection goes through the proxy configured under peer.proxy, if any,
+// or through the network set with SetNetwork.
+func NewClientConnectionWithAddress(peerAddress string, block bool, tslEnabled bool, creds credentials.TransportAuthenticator, extraOpts ...grpc.DialOption) (*grpc.ClientConn, error) {
 	var opts []grpc.DialOption
 	if tslEnabled {
-		opts = append(opts, grpc.WithTransportCredentials(creds))
+		opts = append(opts, grpc.WithTransportCredentials(bracketlessCredentials{creds}))
 	} else {
 		opts = append(opts, grpc.WithInsecure())
 	}
+	if n := getNetwork(); n != nil {
+		opts = append(opts, grpc.WithDialer(n.Dial))
+	} else {
+		proxy, err := NewProxy("peer.proxy")
+		if err != nil {
+			return nil, err
+		}
+		if proxy != nil {
+			opts = append(opts, proxy.DialOption())
+		}
+	}
 	opts = append(opts, grpc.WithTimeout(defaultTimeout))
 	if block {
 		opts = append(opts, grpc.WithBlock())
 	}
+	opts = append(opts, extraOpts...)
 	conn, err := grpc.Dial(peerAddress, opts...)
 	if err != nil {
 		return nil, err
@@ -34,21 +63,134 @@ func NewClientConnectionWithAddress(peerAddress string, block bool, tslEnabled b
 	return conn, err
 }
 
-// InitTLSForPeer returns TLS credentials for peer
+// InitTLSForPeer returns TLS credentials for peer, which present the peer's
+// certificate to the other peer if client authentication is enabled
 func InitTLSForPeer() credentials.TransportAuthenticator {
-	var sn string
-	if viper.GetString("peer.tls.serverhostoverride") != "" {
-		sn = viper.GetString("peer.tls.serverhostoverride")
-	}
-	var creds credentials.TransportAuthenticator
-	if viper.GetString("peer.tls.cert.file") != "" {
-		var err error
-		creds, err = credentials.NewClientTLSFromFile(viper.GetString("peer.tls.cert.file"), sn)
+	config := &tls.Config{ServerName: viper.GetString("peer.tls.serverhostoverride")}
+	if rootCert := peerRootCertFile(); rootCert != "" {
+		pool, err := loadCertPool(rootCert)
 		if err != nil {
 			grpclog.Fatalf("Failed to create TLS credentials %v", err)
 		}
-	} else {
-		creds = credentials.NewClientTLSFromCert(nil, sn)
+		config.RootCAs = pool
+	}
+	if TLSClientAuthEnabled() {
+		cert, err := tls.LoadX509KeyPair(viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"))
+		if err != nil {
+			grpclog.Fatalf("Failed to load TLS client certificate %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(config)
+}
+
+// NewServerTLSForPeer returns the TLS credentials of the peer's gRPC server.
+// If client authentication is enabled, certificates presented by clients are
+// verified against peer.tls.clientAuth.rootcert.file. Presenting one stays
+// optional at the TLS level, as the port also serves clients like the CLI,
+// the peer-to-peer Chat rejects callers without one.
+func NewServerTLSForPeer() (credentials.TransportAuthenticator, error) {
+	if err := ReloadServerCertificate(); err != nil {
+		return nil, err
+	}
+	config := &tls.Config{GetCertificate: getServerCertificate}
+	if err := verifyClientCertificates(config); err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// verifyClientCertificates makes a server verify the certificates clients
+// present against peer.tls.clientAuth.rootcert.file, if client
+// authentication is enabled
+func verifyClientCertificates(config *tls.Config) error {
+	if !TLSClientAuthEnabled() {
+		return nil
+	}
+	rootCert := viper.GetString("peer.tls.clientAuth.rootcert.file")
+	if rootCert == "" {
+		rootCert = peerRootCertFile()
+	}
+	pool, err := loadCertPool(rootCert)
+	if err != nil {
+		return err
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	return nil
+}
+
+// ReloadServerCertificate loads peer.tls.cert.file and peer.tls.key.file
+// again, e.g. after they were replaced ahead of the expiry of the
+// certificate. The servers present the new certificate to the clients
+// connecting from then on, the established connections are not affected.
+func ReloadServerCertificate() error {
+	cert, err := tls.LoadX509KeyPair(viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"))
+	if err != nil {
+		return err
+	}
+	serverCertificate.Lock()
+	defer serverCertificate.Unlock()
+	serverCertificate.cert = &cert
+	return nil
+}
+
+// ServerCertificateExpiry returns when the TLS certificate the peer's servers
+// present expires, the zero time if none is loaded
+func ServerCertificateExpiry() (time.Time, error) {
+	serverCertificate.RLock()
+	defer serverCertificate.RUnlock()
+	if serverCertificate.cert == nil {
+		return time.Time{}, nil
+	}
+	leaf := serverCertificate.cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(serverCertificate.cert.Certificate[0]); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return leaf.NotAfter, nil
+}
+
+func getServerCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	serverCertificate.RLock()
+	defer serverCertificate.RUnlock()
+	return serverCertificate.cert, nil
+}
+
+// ClientCertificate returns the verified certificate the client of the call
+// in ctx presented, nil if it presented none
+func ClientCertificate(ctx context.Context) *x509.Certificate {
+	authInfo, ok := credentials.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := authInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return nil
+	}
+	return tlsInfo.State.PeerCertificates[0]
+}
+
+// peerRootCertFile returns the file of the certificates the TLS certificates
+// of the other peers are verified against: peer.tls.rootcert.file, e.g. the
+// TLSCA certificates, or else peer.tls.cert.file, shared by all the peers
+func peerRootCertFile() string {
+	if rootCert := viper.GetString("peer.tls.rootcert.file"); rootCert != "" {
+		return rootCert
+	}
+	return viper.GetString("peer.tls.cert.file")
+}
+
+func loadCertPool(certFile string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("Failed to append certificates from %s", certFile)
 	}
-	return creds
+	return pool, nil
 }
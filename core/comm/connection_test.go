@@ -1,12 +1,21 @@
 package comm
 
 import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/spf13/viper"
 
 	"github.com/hyperledger/fabric/core/config"
+	"github.com/hyperledger/fabric/core/testutil"
 	"google.golang.org/grpc"
 )
 
@@ -42,3 +51,64 @@ func TestConnection_WrongAddress(t *testing.T) {
 		tmpConn.Close()
 	}
 }
+
+// writeTestCertificate writes a self signed certificate and its key to the
+// files the peer loads its TLS certificate from, returning the certificate
+func writeTestCertificate(t *testing.T, dir, commonName string) []byte {
+	key := testutil.NewKey(t)
+	der := testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}, key, nil, nil).Raw
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Error marshalling key: %s", err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Error writing certificate: %s", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("Error writing key: %s", err)
+	}
+	viper.Set("peer.tls.cert.file", certFile)
+	viper.Set("peer.tls.key.file", keyFile)
+	return der
+}
+
+func TestReloadServerCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsreload")
+	if err != nil {
+		t.Fatalf("Error creating directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file")
+	defer func() {
+		viper.Set("peer.tls.cert.file", certFile)
+		viper.Set("peer.tls.key.file", keyFile)
+	}()
+
+	first := writeTestCertificate(t, dir, "vp0")
+	if _, err := NewServerTLSForPeer(); err != nil {
+		t.Fatalf("Error creating server credentials: %s", err)
+	}
+	if cert, _ := getServerCertificate(&tls.ClientHelloInfo{}); !bytes.Equal(cert.Certificate[0], first) {
+		t.Fatalf("Expected the server to present the configured certificate")
+	}
+
+	renewed := writeTestCertificate(t, dir, "vp0")
+	if err := ReloadServerCertificate(); err != nil {
+		t.Fatalf("Error reloading the certificate: %s", err)
+	}
+	if cert, _ := getServerCertificate(&tls.ClientHelloInfo{}); !bytes.Equal(cert.Certificate[0], renewed) {
+		t.Fatalf("Expected the server to present the renewed certificate")
+	}
+
+	// A broken certificate leaves the current one in place
+	if err := ioutil.WriteFile(filepath.Join(dir, "cert.pem"), []byte("garbage"), 0600); err != nil {
+		t.Fatalf("Error writing certificate: %s", err)
+	}
+	if err := ReloadServerCertificate(); err == nil {
+		t.Fatalf("Expected reloading a broken certificate to fail")
+	}
+	if cert, _ := getServerCertificate(&tls.ClientHelloInfo{}); !bytes.Equal(cert.Certificate[0], renewed) {
+		t.Errorf("Expected the server to keep presenting the renewed certificate")
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// The vendored gRPC does not support interceptors. Services registered with
+// RegisterService instead of their generated Register function have their
+// calls pass through the interceptors added with AddUnaryInterceptor and
+// AddStreamInterceptor, for concerns such as authentication, metrics, rate
+// limiting or auditing which apply to all servers of the peer alike.
+
+// UnaryServerInfo describes a unary call to an interceptor
+type UnaryServerInfo struct {
+	// Server is the implementation of the service
+	Server interface{}
+	// FullMethod is the full name of the method, e.g. /protos.Devops/Invoke
+	FullMethod string
+}
+
+// UnaryHandler carries out a unary call with the request
+type UnaryHandler func(ctx context.Context, req interface{}) (interface{}, error)
+
+// UnaryServerInterceptor intercepts a unary call. It invokes handler to
+// carry out the call, or returns an error to refuse it.
+type UnaryServerInterceptor func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error)
+
+// StreamServerInfo describes a streaming call to an interceptor
+type StreamServerInfo struct {
+	// Server is the implementation of the service
+	Server interface{}
+	// FullMethod is the full name of the method, e.g. /protos.Peer/Chat
+	FullMethod     string
+	IsClientStream bool
+	IsServerStream bool
+}
+
+// StreamHandler carries out a streaming call on the stream
+type StreamHandler func(srv interface{}, stream grpc.ServerStream) error
+
+// StreamServerInterceptor intercepts a streaming call. It invokes handler to
+// carry out the call, possibly on a wrapped stream, or returns an error to
+// refuse it.
+type StreamServerInterceptor func(srv interface{}, stream grpc.ServerStream, info *StreamServerInfo, handler StreamHandler) error
+
+var interceptors struct {
+	sync.RWMutex
+	unary  []UnaryServerInterceptor
+	stream []StreamServerInterceptor
+}
+
+// AddUnaryInterceptor adds an interceptor to the unary calls of the services
+// registered with RegisterService. Interceptors are invoked in the order they
+// were added, the first one outermost.
+func AddUnaryInterceptor(interceptor UnaryServerInterceptor) {
+	interceptors.Lock()
+	defer interceptors.Unlock()
+	interceptors.unary = append(interceptors.unary, interceptor)
+}
+
+// AddStreamInterceptor adds an interceptor to the streaming calls of the
+// services registered with RegisterService. Interceptors are invoked in the
+// order they were added, the first one outermost.
+func AddStreamInterceptor(interceptor StreamServerInterceptor) {
+	interceptors.Lock()
+	defer interceptors.Unlock()
+	interceptors.stream = append(interceptors.stream, interceptor)
+}
+
+// RegisterService registers the service described by desc, e.g.
+// pb.DevopsServiceDesc, and implemented by impl on server, with its calls
+// passing through the interceptors
+func RegisterService(server *grpc.Server, desc *grpc.ServiceDesc, impl interface{}) {
+	server.RegisterService(interceptedServiceDesc(desc), impl)
+}
+
+func interceptedServiceDesc(desc *grpc.ServiceDesc) *grpc.ServiceDesc {
+	intercepted := &grpc.ServiceDesc{
+		ServiceName: desc.ServiceName,
+		HandlerType: desc.HandlerType,
+		Methods:     make([]grpc.MethodDesc, len(desc.Methods)),
+		Streams:     make([]grpc.StreamDesc, len(desc.Streams)),
+	}
+	for i, method := range desc.Methods {
+		intercepted.Methods[i] = grpc.MethodDesc{
+			MethodName: method.MethodName,
+			Handler:    interceptUnary("/"+desc.ServiceName+"/"+method.MethodName, method.Handler),
+		}
+	}
+	for i, stream := range desc.Streams {
+		intercepted.Streams[i] = stream
+		intercepted.Streams[i].Handler = interceptStream(&StreamServerInfo{
+			FullMethod:     "/" + desc.ServiceName + "/" + stream.StreamName,
+			IsClientStream: stream.ClientStreams,
+			IsServerStream: stream.ServerStreams,
+		}, stream.Handler)
+	}
+	return intercepted
+}
+
+// errRequestDecoded stops a generated method handler once it decoded the
+// request, before it carries out the call
+var errRequestDecoded = errors.New("request decoded")
+
+// interceptUnary wraps the generated handler of a unary method. The
+// generated handler decodes the request into a message of the method's type
+// and then carries out the call. It is invoked once to obtain the request,
+// stopping once decoded, and once more by the innermost interceptor to carry
+// out the call with the request the interceptors passed on.
+func interceptUnary(fullMethod string, handler func(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error)) func(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+		interceptors.RLock()
+		chain := interceptors.unary
+		interceptors.RUnlock()
+		if len(chain) == 0 {
+			return handler(srv, ctx, dec)
+		}
+
+		var req interface{}
+		_, err := handler(srv, ctx, func(in interface{}) error {
+			if err := dec(in); err != nil {
+				return err
+			}
+			req = in
+			return errRequestDecoded
+		})
+		if err != errRequestDecoded {
+			return nil, err
+		}
+
+		info := &UnaryServerInfo{Server: srv, FullMethod: fullMethod}
+		call := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return handler(srv, ctx, func(in interface{}) error {
+				reflect.ValueOf(in).Elem().Set(reflect.ValueOf(req).Elem())
+				return nil
+			})
+		}
+		for i := len(chain) - 1; i >= 0; i-- {
+			interceptor, next := chain[i], call
+			call = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return call(ctx, req)
+	}
+}
+
+// interceptStream wraps the generated handler of a streaming method
+func interceptStream(info *StreamServerInfo, handler func(srv interface{}, stream grpc.ServerStream) error) func(srv interface{}, stream grpc.ServerStream) error {
+	return func(srv interface{}, stream grpc.ServerStream) error {
+		interceptors.RLock()
+		chain := interceptors.stream
+		interceptors.RUnlock()
+
+		call := StreamHandler(handler)
+		callInfo := *info
+		callInfo.Server = srv
+		for i := len(chain) - 1; i >= 0; i-- {
+			interceptor, next := chain[i], call
+			call = func(srv interface{}, stream grpc.ServerStream) error {
+				return interceptor(srv, stream, &callInfo, next)
+			}
+		}
+		return call(srv, stream)
+	}
+}
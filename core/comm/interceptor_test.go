@@ -0,0 +1,154 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type echoServer interface {
+	Echo(context.Context, *pb.PeerID) (*pb.PeerID, error)
+}
+
+type echoImpl struct {
+	calls int
+}
+
+func (e *echoImpl) Echo(ctx context.Context, in *pb.PeerID) (*pb.PeerID, error) {
+	e.calls++
+	return &pb.PeerID{Name: "echo " + in.Name}, nil
+}
+
+// _Echo_Echo_Handler is shaped like the generated method handlers
+func _Echo_Echo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(pb.PeerID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(echoServer).Echo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var testEchoServiceDesc = &grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*echoServer)(nil),
+	Methods:     []grpc.MethodDesc{{MethodName: "Echo", Handler: _Echo_Echo_Handler}},
+	Streams: []grpc.StreamDesc{{StreamName: "Stream", ServerStreams: true, Handler: func(srv interface{}, stream grpc.ServerStream) error {
+		return nil
+	}}},
+}
+
+// withInterceptors runs f with only the given interceptors added
+func withInterceptors(unary []UnaryServerInterceptor, stream []StreamServerInterceptor, f func()) {
+	interceptors.Lock()
+	savedUnary, savedStream := interceptors.unary, interceptors.stream
+	interceptors.unary, interceptors.stream = nil, nil
+	interceptors.Unlock()
+	defer func() {
+		interceptors.Lock()
+		interceptors.unary, interceptors.stream = savedUnary, savedStream
+		interceptors.Unlock()
+	}()
+	for _, interceptor := range unary {
+		AddUnaryInterceptor(interceptor)
+	}
+	for _, interceptor := range stream {
+		AddStreamInterceptor(interceptor)
+	}
+	f()
+}
+
+func decodePeerID(name string) func(interface{}) error {
+	return func(in interface{}) error {
+		in.(*pb.PeerID).Name = name
+		return nil
+	}
+}
+
+func TestUnaryInterceptorChain(t *testing.T) {
+	var trace []string
+	outer := func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		trace = append(trace, "outer "+info.FullMethod+" "+req.(*pb.PeerID).Name)
+		resp, err := handler(ctx, &pb.PeerID{Name: "rewritten"})
+		trace = append(trace, "outer done")
+		return resp, err
+	}
+	inner := func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		trace = append(trace, "inner "+req.(*pb.PeerID).Name)
+		return handler(ctx, req)
+	}
+
+	withInterceptors([]UnaryServerInterceptor{outer, inner}, nil, func() {
+		impl := &echoImpl{}
+		desc := interceptedServiceDesc(testEchoServiceDesc)
+		resp, err := desc.Methods[0].Handler(impl, context.Background(), decodePeerID("request"))
+		if err != nil {
+			t.Fatalf("Error calling through the interceptors: %s", err)
+		}
+		if resp.(*pb.PeerID).Name != "echo rewritten" || impl.calls != 1 {
+			t.Errorf("Expected the call to be carried out once with the rewritten request, got %v after %d calls", resp, impl.calls)
+		}
+	})
+	expected := []string{"outer /test.Echo/Echo request", "inner rewritten", "outer done"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected the interceptors to be invoked as %v, got %v", expected, trace)
+	}
+}
+
+func TestUnaryInterceptorRefusesCall(t *testing.T) {
+	refuse := func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		return nil, fmt.Errorf("refused")
+	}
+	withInterceptors([]UnaryServerInterceptor{refuse}, nil, func() {
+		impl := &echoImpl{}
+		desc := interceptedServiceDesc(testEchoServiceDesc)
+		if _, err := desc.Methods[0].Handler(impl, context.Background(), decodePeerID("request")); err == nil || impl.calls != 0 {
+			t.Errorf("Expected the refused call not to be carried out")
+		}
+		decodeErr := func(interface{}) error { return fmt.Errorf("malformed") }
+		if _, err := desc.Methods[0].Handler(impl, context.Background(), decodeErr); err == nil || err.Error() != "malformed" {
+			t.Errorf("Expected the decoding error to be returned, got %v", err)
+		}
+	})
+}
+
+func TestStreamInterceptor(t *testing.T) {
+	var intercepted *StreamServerInfo
+	record := func(srv interface{}, stream grpc.ServerStream, info *StreamServerInfo, handler StreamHandler) error {
+		intercepted = info
+		return handler(srv, stream)
+	}
+	withInterceptors(nil, []StreamServerInterceptor{record}, func() {
+		desc := interceptedServiceDesc(testEchoServiceDesc)
+		if err := desc.Streams[0].Handler(&echoImpl{}, nil); err != nil {
+			t.Fatalf("Error calling through the interceptor: %s", err)
+		}
+	})
+	if intercepted == nil || intercepted.FullMethod != "/test.Echo/Stream" || !intercepted.IsServerStream || intercepted.IsClientStream {
+		t.Errorf("Expected the stream call to be intercepted, got %v", intercepted)
+	}
+}
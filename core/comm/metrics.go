@@ -0,0 +1,60 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/hyperledger/fabric/core/metrics"
+)
+
+var (
+	grpcRequests = metrics.NewCounter("fabric_grpc_requests_total",
+		"Unary calls handled by the gRPC services of the peer, by method and status code.", "method", "code")
+	grpcRequestDuration = metrics.NewHistogram("fabric_grpc_request_duration_seconds",
+		"Latency of the unary calls handled by the gRPC services of the peer, by method.", metrics.DefaultBuckets, "method")
+	grpcStreams = metrics.NewCounter("fabric_grpc_streams_total",
+		"Streaming calls ended, by method and status code.", "method", "code")
+	grpcStreamsActive = metrics.NewGauge("fabric_grpc_streams_active",
+		"Streaming calls in progress, by method.", "method")
+)
+
+func init() {
+	metrics.NewCounterFunc("fabric_grpc_rate_limited_total", "Requests refused by the rate limiters.",
+		func() float64 { return float64(RateLimitRejections()) })
+}
+
+// MetricsUnaryInterceptor counts the unary calls and measures their latency
+func MetricsUnaryInterceptor(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	grpcRequestDuration.ObserveSince(start, info.FullMethod)
+	grpcRequests.Inc(info.FullMethod, grpc.Code(err).String())
+	return resp, err
+}
+
+// MetricsStreamInterceptor counts the streaming calls in progress and ended
+func MetricsStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *StreamServerInfo, handler StreamHandler) error {
+	grpcStreamsActive.Inc(info.FullMethod)
+	err := handler(srv, stream)
+	grpcStreamsActive.Dec(info.FullMethod)
+	grpcStreams.Inc(info.FullMethod, grpc.Code(err).String())
+	return err
+}
@@ -0,0 +1,52 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/hyperledger/fabric/core/metrics"
+)
+
+func TestMetricsInterceptors(t *testing.T) {
+	withInterceptors([]UnaryServerInterceptor{MetricsUnaryInterceptor}, []StreamServerInterceptor{MetricsStreamInterceptor}, func() {
+		desc := interceptedServiceDesc(testEchoServiceDesc)
+		if _, err := desc.Methods[0].Handler(&echoImpl{}, context.Background(), decodePeerID("request")); err != nil {
+			t.Fatalf("Error calling through the interceptor: %s", err)
+		}
+		if err := desc.Streams[0].Handler(&echoImpl{}, nil); err != nil {
+			t.Fatalf("Error calling through the interceptor: %s", err)
+		}
+	})
+
+	var buf bytes.Buffer
+	metrics.WriteText(&buf)
+	for _, line := range []string{
+		`fabric_grpc_requests_total{method="/test.Echo/Echo",code="OK"} 1`,
+		`fabric_grpc_request_duration_seconds_count{method="/test.Echo/Echo"} 1`,
+		`fabric_grpc_streams_total{method="/test.Echo/Stream",code="OK"} 1`,
+		`fabric_grpc_streams_active{method="/test.Echo/Stream"} 0`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("Expected line %q in\n%s", line, buf.String())
+		}
+	}
+}
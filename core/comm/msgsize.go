@@ -0,0 +1,96 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// MessageSizeLimits are the maximum sizes in bytes of the gRPC messages a
+// connection sends and receives, 0 for no limit
+type MessageSizeLimits struct {
+	Send int
+	Recv int
+}
+
+// GetMessageSizeLimits returns the limits configured under key, e.g.
+// peer.messageSize for peer.messageSize.send and peer.messageSize.recv
+func GetMessageSizeLimits(key string) MessageSizeLimits {
+	return MessageSizeLimits{
+		Send: viper.GetInt(key + ".send"),
+		Recv: viper.GetInt(key + ".recv"),
+	}
+}
+
+// Max returns the limits admitting the messages admitted by either limits,
+// for a server hosting several services
+func (l MessageSizeLimits) Max(other MessageSizeLimits) MessageSizeLimits {
+	return MessageSizeLimits{Send: maxLimit(l.Send, other.Send), Recv: maxLimit(l.Recv, other.Recv)}
+}
+
+func maxLimit(a, b int) int {
+	if a <= 0 || b <= 0 {
+		return 0
+	}
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ServerOption returns the option applying the limits to a gRPC server
+func (l MessageSizeLimits) ServerOption() grpc.ServerOption {
+	return grpc.CustomCodec(limitingCodec{l})
+}
+
+// DialOption returns the option applying the limits to a gRPC connection
+func (l MessageSizeLimits) DialOption() grpc.DialOption {
+	return grpc.WithCodec(limitingCodec{l})
+}
+
+// limitingCodec is the protobuf codec gRPC uses by default, rejecting
+// messages beyond the limits
+type limitingCodec struct {
+	limits MessageSizeLimits
+}
+
+func (c limitingCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := proto.Marshal(v.(proto.Message))
+	if err != nil {
+		return nil, err
+	}
+	if c.limits.Send > 0 && len(data) > c.limits.Send {
+		return nil, fmt.Errorf("message of %d bytes exceeds the maximum message size to send of %d bytes", len(data), c.limits.Send)
+	}
+	return data, nil
+}
+
+func (c limitingCodec) Unmarshal(data []byte, v interface{}) error {
+	if c.limits.Recv > 0 && len(data) > c.limits.Recv {
+		return grpc.Errorf(codes.ResourceExhausted, "message of %d bytes exceeds the maximum message size to receive of %d bytes", len(data), c.limits.Recv)
+	}
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (c limitingCodec) String() string {
+	return "proto"
+}
@@ -0,0 +1,57 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"bytes"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestLimitingCodec(t *testing.T) {
+	codec := limitingCodec{MessageSizeLimits{Send: 100, Recv: 50}}
+	small := &pb.Message{Payload: bytes.Repeat([]byte{1}, 10)}
+	large := &pb.Message{Payload: bytes.Repeat([]byte{1}, 200)}
+
+	data, err := codec.Marshal(small)
+	if err != nil {
+		t.Fatalf("Expected a small message to be sent: %s", err)
+	}
+	if err := codec.Unmarshal(data, &pb.Message{}); err != nil {
+		t.Fatalf("Expected a small message to be received: %s", err)
+	}
+	if _, err := codec.Marshal(large); err == nil {
+		t.Errorf("Expected a message beyond the send limit to be rejected")
+	}
+
+	data, _ = limitingCodec{}.Marshal(&pb.Message{Payload: bytes.Repeat([]byte{1}, 60)})
+	err = codec.Unmarshal(data, &pb.Message{})
+	if grpc.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected a message beyond the receive limit to be rejected as ResourceExhausted, got %v", err)
+	}
+}
+
+func TestMessageSizeLimitsMax(t *testing.T) {
+	limits := MessageSizeLimits{Send: 10, Recv: 20}.Max(MessageSizeLimits{Send: 30, Recv: 0})
+	if limits.Send != 30 || limits.Recv != 0 {
+		t.Errorf("Expected the larger limits, with 0 for no limit, got %+v", limits)
+	}
+}
@@ -0,0 +1,256 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Network makes the connections of the peer, events and chaincode support
+// servers and of their clients: Listen and NewClientConnectionWithAddress
+// go through it. The peer uses TCP, tests may set an in-memory network with
+// SetNetwork to run servers and clients in one process without ports.
+type Network interface {
+	Listen(address string) (net.Listener, error)
+	Dial(address string, timeout time.Duration) (net.Conn, error)
+}
+
+var network struct {
+	sync.RWMutex
+	n Network // nil for TCP
+}
+
+// SetNetwork makes servers listen and clients dial on n from now on, nil
+// restores TCP
+func SetNetwork(n Network) {
+	network.Lock()
+	defer network.Unlock()
+	network.n = n
+}
+
+func getNetwork() Network {
+	network.RLock()
+	defer network.RUnlock()
+	return network.n
+}
+
+// MemoryNetwork connects the clients and servers of one process through
+// in-memory connections. Addresses are names: a client reaches the listener on the
+// same address, or on the same port of an unspecified host, e.g. :30303 or
+// 0.0.0.0:30303.
+type MemoryNetwork struct {
+	lock      sync.Mutex
+	listeners map[string]*memoryListener
+}
+
+// NewMemoryNetwork returns an empty in-memory network
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{listeners: make(map[string]*memoryListener)}
+}
+
+// Listen announces on address, which no other listener of the network may use
+func (n *MemoryNetwork) Listen(address string) (net.Listener, error) {
+	key, err := memoryKey(address)
+	if err != nil {
+		return nil, err
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if _, ok := n.listeners[key]; ok {
+		return nil, fmt.Errorf("Address %s is already in use", address)
+	}
+	l := &memoryListener{
+		network: n,
+		key:     key,
+		address: memoryAddr(address),
+		conns:   make(chan net.Conn),
+		done:    make(chan struct{}),
+	}
+	n.listeners[key] = l
+	return l, nil
+}
+
+// Dial connects to the listener on address, waiting up to timeout, if not
+// 0, for the listener to accept the connection
+func (n *MemoryNetwork) Dial(address string, timeout time.Duration) (net.Conn, error) {
+	l := n.listener(address)
+	if l == nil {
+		return nil, fmt.Errorf("Error connecting to %s: connection refused", address)
+	}
+	client, server := newMemoryConns(memoryAddr("client"), l.address)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		return nil, fmt.Errorf("Error connecting to %s: connection refused", address)
+	case <-expired:
+		return nil, fmt.Errorf("Error connecting to %s: timed out", address)
+	}
+}
+
+// listener returns the listener reached at address, nil if there is none
+func (n *MemoryNetwork) listener(address string) *memoryListener {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if l, ok := n.listeners[net.JoinHostPort(host, port)]; ok {
+		return l
+	}
+	return n.listeners[net.JoinHostPort("", port)]
+}
+
+// memoryKey returns the key of the listener on address, which has no host
+// for an unspecified host
+func memoryKey(address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("Invalid listen address %s: %s", address, err)
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = ""
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+type memoryListener struct {
+	network *MemoryNetwork
+	key     string
+	address memoryAddr
+	conns   chan net.Conn
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (l *memoryListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, errors.New("Listener closed")
+	}
+}
+
+func (l *memoryListener) Close() error {
+	l.once.Do(func() {
+		l.network.lock.Lock()
+		delete(l.network.listeners, l.key)
+		l.network.lock.Unlock()
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *memoryListener) Addr() net.Addr {
+	return l.address
+}
+
+type memoryAddr string
+
+func (a memoryAddr) Network() string { return "memory" }
+func (a memoryAddr) String() string  { return string(a) }
+
+// memoryConn is one end of an in-memory connection. Unlike with net.Pipe,
+// writes do not wait for the other end to read, as both ends of a gRPC
+// connection write before they read.
+type memoryConn struct {
+	in, out       *memoryBuffer
+	local, remote memoryAddr
+}
+
+// newMemoryConns returns the client and server ends of a connection
+func newMemoryConns(client, server memoryAddr) (net.Conn, net.Conn) {
+	toServer, toClient := newMemoryBuffer(), newMemoryBuffer()
+	return &memoryConn{in: toClient, out: toServer, local: client, remote: server},
+		&memoryConn{in: toServer, out: toClient, local: server, remote: client}
+}
+
+func (c *memoryConn) Read(b []byte) (int, error)  { return c.in.read(b) }
+func (c *memoryConn) Write(b []byte) (int, error) { return c.out.write(b) }
+func (c *memoryConn) LocalAddr() net.Addr         { return c.local }
+func (c *memoryConn) RemoteAddr() net.Addr        { return c.remote }
+
+// Close ends both directions, the other end reads what was written so far
+func (c *memoryConn) Close() error {
+	c.in.close()
+	c.out.close()
+	return nil
+}
+
+// Deadlines are not supported
+func (c *memoryConn) SetDeadline(time.Time) error      { return nil }
+func (c *memoryConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *memoryConn) SetWriteDeadline(time.Time) error { return nil }
+
+// memoryBuffer holds the bytes written to one end of a connection until the
+// other end reads them
+type memoryBuffer struct {
+	lock   sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	closed bool
+}
+
+func newMemoryBuffer() *memoryBuffer {
+	b := &memoryBuffer{}
+	b.cond = sync.NewCond(&b.lock)
+	return b
+}
+
+func (b *memoryBuffer) read(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for b.buf.Len() == 0 && !b.closed {
+		b.cond.Wait()
+	}
+	if b.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return b.buf.Read(p)
+}
+
+func (b *memoryBuffer) write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	b.buf.Write(p)
+	b.cond.Broadcast()
+	return len(p), nil
+}
+
+func (b *memoryBuffer) close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.closed = true
+	b.cond.Broadcast()
+}
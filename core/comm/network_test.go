@@ -0,0 +1,83 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"google/protobuf"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// networkTestAdmin only answers GetStatus
+type networkTestAdmin struct {
+	pb.AdminServer
+}
+
+func (networkTestAdmin) GetStatus(context.Context, *google_protobuf.Empty) (*pb.ServerStatus, error) {
+	return &pb.ServerStatus{Status: pb.ServerStatus_STARTED}, nil
+}
+
+func TestMemoryNetwork(t *testing.T) {
+	SetNetwork(NewMemoryNetwork())
+	defer SetNetwork(nil)
+
+	lis, err := Listen("0.0.0.0:30303")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	if _, err := Listen(":30303"); err == nil {
+		t.Errorf("Expected the address to be in use")
+	}
+	server := grpc.NewServer()
+	pb.RegisterAdminServer(server, networkTestAdmin{})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := NewClientConnectionWithAddress("vp0:30303", true, false, nil)
+	if err != nil {
+		t.Fatalf("Error dialing the server: %s", err)
+	}
+	defer conn.Close()
+	status, err := pb.NewAdminClient(conn).GetStatus(context.Background(), &google_protobuf.Empty{})
+	if err != nil || status.Status != pb.ServerStatus_STARTED {
+		t.Fatalf("Expected the server to answer over the in-memory network, got %v, %v", status, err)
+	}
+}
+
+func TestMemoryNetworkRefusesUnknownAddress(t *testing.T) {
+	n := NewMemoryNetwork()
+	if _, err := n.Dial("vp0:30303", time.Second); err == nil {
+		t.Fatalf("Expected no listener on the address")
+	}
+	lis, err := n.Listen("vp0:30303")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	lis.Close()
+	if _, err := n.Dial("vp0:30303", time.Second); err == nil {
+		t.Errorf("Expected a closed listener to refuse connections")
+	}
+	if _, err := n.Listen("vp0:30303"); err != nil {
+		t.Errorf("Expected the address of a closed listener to be free: %s", err)
+	}
+}
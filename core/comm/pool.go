@@ -0,0 +1,198 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// DialFunc creates a new connection to the given address
+type DialFunc func(address string) (*grpc.ClientConn, error)
+
+// ConnectionPool shares a single grpc.ClientConn per address among all its
+// users, as gRPC multiplexes any number of calls and streams over one
+// connection. Connections are reference counted, and closed once they have
+// not been used for the idle timeout.
+type ConnectionPool struct {
+	lock        sync.Mutex
+	dial        DialFunc
+	idleTimeout time.Duration
+	byAddress   map[string]*pooledConnection           // connections handed out to new users
+	byConn      map[*grpc.ClientConn]*pooledConnection // all open connections, including discarded ones still in use
+	done        chan struct{}
+	now         func() time.Time
+}
+
+type pooledConnection struct {
+	address   string
+	conn      *grpc.ClientConn
+	refs      int
+	idleSince time.Time
+}
+
+// NewConnectionPool creates a pool which dials new connections with dial and
+// closes them after idleTimeout without users, 0 keeps them open until the
+// pool is closed
+func NewConnectionPool(dial DialFunc, idleTimeout time.Duration) *ConnectionPool {
+	cp := &ConnectionPool{
+		dial:        dial,
+		idleTimeout: idleTimeout,
+		byAddress:   make(map[string]*pooledConnection),
+		byConn:      make(map[*grpc.ClientConn]*pooledConnection),
+		done:        make(chan struct{}),
+		now:         time.Now,
+	}
+	if idleTimeout > 0 {
+		go cp.reapIdle()
+	}
+	return cp
+}
+
+// Acquire returns the connection to address, dialing it if there is none,
+// every successful Acquire must be followed by a Release of the connection
+func (cp *ConnectionPool) Acquire(address string) (*grpc.ClientConn, error) {
+	cp.lock.Lock()
+	if pc := cp.usable(address); pc != nil {
+		pc.refs++
+		cp.lock.Unlock()
+		return pc.conn, nil
+	}
+	cp.lock.Unlock()
+
+	// Dial without holding the lock, as it may block until the connection is up
+	conn, err := cp.dial(address)
+	if err != nil {
+		return nil, err
+	}
+
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	if pc := cp.usable(address); pc != nil {
+		// Another user dialed the address meanwhile
+		conn.Close()
+		pc.refs++
+		return pc.conn, nil
+	}
+	pc := &pooledConnection{address: address, conn: conn, refs: 1}
+	cp.byAddress[address] = pc
+	cp.byConn[conn] = pc
+	commLogger.Debugf("Opened pooled connection to %s, %d connections open", address, len(cp.byConn))
+	return conn, nil
+}
+
+// usable returns the connection to hand out for address, if there is one
+func (cp *ConnectionPool) usable(address string) *pooledConnection {
+	pc, ok := cp.byAddress[address]
+	if !ok {
+		return nil
+	}
+	if pc.conn.State() == grpc.Shutdown {
+		cp.discard(pc)
+		return nil
+	}
+	return pc
+}
+
+// Release returns a connection obtained from Acquire to the pool
+func (cp *ConnectionPool) Release(conn *grpc.ClientConn) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	pc, ok := cp.byConn[conn]
+	if !ok {
+		return
+	}
+	pc.refs--
+	if pc.refs > 0 {
+		return
+	}
+	if cp.byAddress[pc.address] != pc {
+		cp.close(pc)
+		return
+	}
+	pc.idleSince = cp.now()
+}
+
+// Discard stops handing out the connection, for instance because calls on it
+// failed, the next Acquire of its address dials a new connection. It is
+// closed once released by all its current users.
+func (cp *ConnectionPool) Discard(conn *grpc.ClientConn) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	if pc, ok := cp.byConn[conn]; ok {
+		cp.discard(pc)
+	}
+}
+
+func (cp *ConnectionPool) discard(pc *pooledConnection) {
+	if cp.byAddress[pc.address] == pc {
+		delete(cp.byAddress, pc.address)
+	}
+	if pc.refs == 0 {
+		cp.close(pc)
+	}
+}
+
+func (cp *ConnectionPool) close(pc *pooledConnection) {
+	delete(cp.byConn, pc.conn)
+	if cp.byAddress[pc.address] == pc {
+		delete(cp.byAddress, pc.address)
+	}
+	pc.conn.Close()
+	commLogger.Debugf("Closed pooled connection to %s, %d connections open", pc.address, len(cp.byConn))
+}
+
+func (cp *ConnectionPool) reapIdle() {
+	ticker := time.NewTicker(cp.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cp.reap()
+		case <-cp.done:
+			return
+		}
+	}
+}
+
+// reap closes the connections which have been idle for the idle timeout
+func (cp *ConnectionPool) reap() {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	now := cp.now()
+	for _, pc := range cp.byConn {
+		if pc.refs == 0 && now.Sub(pc.idleSince) >= cp.idleTimeout {
+			cp.close(pc)
+		}
+	}
+}
+
+// Close closes all connections of the pool, whether in use or not
+func (cp *ConnectionPool) Close() {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	select {
+	case <-cp.done:
+	default:
+		close(cp.done)
+	}
+	for _, pc := range cp.byConn {
+		cp.close(pc)
+	}
+}
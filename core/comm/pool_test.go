@@ -0,0 +1,158 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// newTestPool returns a pool whose connections are dialed without waiting for
+// them to come up, and the number of dials made
+func newTestPool(idleTimeout time.Duration) (*ConnectionPool, *int) {
+	dials := 0
+	cp := NewConnectionPool(func(address string) (*grpc.ClientConn, error) {
+		dials++
+		return NewClientConnectionWithAddress(address, false, false, nil)
+	}, idleTimeout)
+	return cp, &dials
+}
+
+// newTestServer starts an in-process gRPC server and returns its address, so
+// that pooled connections to it don't fail and close on their own
+func newTestServer(t *testing.T) (string, *grpc.Server) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	go server.Serve(lis)
+	return lis.Addr().String(), server
+}
+
+// pooledRefs returns the number of users of conn according to the pool, -1
+// if the pool no longer holds it
+func pooledRefs(cp *ConnectionPool, conn *grpc.ClientConn) int {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	if pc, ok := cp.byConn[conn]; ok {
+		return pc.refs
+	}
+	return -1
+}
+
+// handedOut returns the connection the pool hands out for address, nil if none
+func handedOut(cp *ConnectionPool, address string) *grpc.ClientConn {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	if pc, ok := cp.byAddress[address]; ok {
+		return pc.conn
+	}
+	return nil
+}
+
+func TestPoolSharesConnections(t *testing.T) {
+	address, server := newTestServer(t)
+	defer server.Stop()
+	otherAddress, otherServer := newTestServer(t)
+	defer otherServer.Stop()
+	cp, dials := newTestPool(0)
+	defer cp.Close()
+
+	first, err := cp.Acquire(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := cp.Acquire(address)
+	other, _ := cp.Acquire(otherAddress)
+	if first != second || first == other || *dials != 2 {
+		t.Fatalf("Expected one connection per address, dialed %d", *dials)
+	}
+	if refs := pooledRefs(cp, first); refs != 2 {
+		t.Fatalf("Expected the shared connection to have 2 users, it has %d", refs)
+	}
+
+	cp.Release(first)
+	cp.Release(second)
+	if refs := pooledRefs(cp, first); refs != 0 {
+		t.Fatalf("Expected the released connection to be pooled without users, it has %d", refs)
+	}
+	if third, _ := cp.Acquire(address); third != first {
+		t.Errorf("Expected a released connection to be reused")
+	}
+}
+
+func TestPoolDiscard(t *testing.T) {
+	address, server := newTestServer(t)
+	defer server.Stop()
+	cp, dials := newTestPool(0)
+	defer cp.Close()
+
+	broken, _ := cp.Acquire(address)
+	cp.Discard(broken)
+	if handedOut(cp, address) != nil {
+		t.Fatalf("Expected a discarded connection not to be handed out")
+	}
+	if refs := pooledRefs(cp, broken); refs != 1 {
+		t.Fatalf("Expected a discarded connection to stay pooled while in use, it has %d users", refs)
+	}
+	replacement, _ := cp.Acquire(address)
+	if replacement == broken || *dials != 2 {
+		t.Fatalf("Expected a discarded connection to be replaced")
+	}
+
+	cp.Release(broken)
+	if refs := pooledRefs(cp, broken); refs != -1 {
+		t.Errorf("Expected a discarded connection to leave the pool once released, it has %d users", refs)
+	}
+	if broken.State() != grpc.Shutdown {
+		t.Errorf("Expected a discarded connection to be closed once released")
+	}
+	if handedOut(cp, address) != replacement {
+		t.Errorf("Expected the replacement to be handed out")
+	}
+}
+
+func TestPoolReapsIdleConnections(t *testing.T) {
+	idleAddress, idleServer := newTestServer(t)
+	defer idleServer.Stop()
+	busyAddress, busyServer := newTestServer(t)
+	defer busyServer.Stop()
+	cp, _ := newTestPool(time.Hour)
+	defer cp.Close()
+	now := time.Unix(1000, 0)
+	cp.now = func() time.Time { return now }
+
+	idle, _ := cp.Acquire(idleAddress)
+	busy, _ := cp.Acquire(busyAddress)
+	cp.Release(idle)
+
+	now = now.Add(time.Hour)
+	cp.reap()
+	if refs := pooledRefs(cp, idle); refs != -1 || handedOut(cp, idleAddress) != nil {
+		t.Errorf("Expected the idle connection to leave the pool")
+	}
+	if idle.State() != grpc.Shutdown {
+		t.Errorf("Expected the idle connection to be closed")
+	}
+	if refs := pooledRefs(cp, busy); refs != 1 || handedOut(cp, busyAddress) != busy {
+		t.Errorf("Expected the busy connection to remain in the pool with 1 user, it has %d", refs)
+	}
+}
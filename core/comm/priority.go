@@ -0,0 +1,91 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"sync"
+)
+
+// Priority of a message sent on a stream shared by several subsystems
+type Priority int
+
+// Priorities, in increasing order
+const (
+	// PriorityBulk is for large transfers, e.g. blocks and state sent to a
+	// syncing peer
+	PriorityBulk Priority = iota
+	// PriorityNormal is for everything else
+	PriorityNormal
+	// PriorityHigh is for latency sensitive traffic, e.g. consensus messages
+	PriorityHigh
+
+	numPriorities = iota
+)
+
+// PriorityMutex serializes the senders on a stream. When it is unlocked, it
+// is handed to a waiting sender of the highest priority, so that consensus
+// messages are not queued behind bulk transfers. A message being sent is not
+// interrupted, a sender waits for at most one message of lower priority.
+// Senders of the same priority are served in no particular order. The zero
+// value is an unlocked mutex.
+type PriorityMutex struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	held    bool
+	waiting [numPriorities]int
+}
+
+// Lock locks m for a sender of priority p
+func (m *PriorityMutex) Lock(p Priority) {
+	if p < PriorityBulk || p > PriorityHigh {
+		p = PriorityNormal
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.cond == nil {
+		m.cond = sync.NewCond(&m.lock)
+	}
+	m.waiting[p]++
+	for m.held || m.preempted(p) {
+		m.cond.Wait()
+	}
+	m.waiting[p]--
+	m.held = true
+}
+
+// preempted returns whether a sender of higher priority than p is waiting
+func (m *PriorityMutex) preempted(p Priority) bool {
+	for higher := p + 1; higher <= PriorityHigh; higher++ {
+		if m.waiting[higher] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Unlock unlocks m, handing it to the waiting sender of the highest priority
+func (m *PriorityMutex) Unlock() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.held {
+		panic("comm: unlock of unlocked PriorityMutex")
+	}
+	m.held = false
+	if m.cond != nil {
+		m.cond.Broadcast()
+	}
+}
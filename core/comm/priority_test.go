@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"testing"
+	"time"
+)
+
+// waitForWaiters waits until count senders of priority p wait for m
+func waitForWaiters(t *testing.T, m *PriorityMutex, p Priority, count int) {
+	for i := 0; i < 1000; i++ {
+		m.lock.Lock()
+		waiting := m.waiting[p]
+		m.lock.Unlock()
+		if waiting == count {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d senders of priority %d to wait", count, p)
+}
+
+func TestPriorityMutexServesHighPriorityFirst(t *testing.T) {
+	var m PriorityMutex
+	m.Lock(PriorityBulk)
+
+	order := make(chan Priority, 3)
+	for _, p := range []Priority{PriorityBulk, PriorityNormal, PriorityHigh} {
+		go func(p Priority) {
+			m.Lock(p)
+			order <- p
+			m.Unlock()
+		}(p)
+		waitForWaiters(t, &m, p, 1)
+	}
+	m.Unlock()
+
+	for _, expected := range []Priority{PriorityHigh, PriorityNormal, PriorityBulk} {
+		select {
+		case p := <-order:
+			if p != expected {
+				t.Fatalf("Expected the sender of priority %d to be served, got %d", expected, p)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the sender of priority %d to be served", expected)
+		}
+	}
+}
+
+func TestPriorityMutexUnlockOfUnlocked(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected unlocking an unlocked mutex to panic")
+		}
+	}()
+	var m PriorityMutex
+	m.Unlock()
+}
@@ -0,0 +1,167 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// startEchoServer returns the address of a server echoing what it receives
+func startEchoServer(t *testing.T) (string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(conn, conn)
+		}
+	}()
+	return lis.Addr().String(), func() { lis.Close() }
+}
+
+// startTestProxy returns the address of a proxy handing each accepted
+// connection to handshake, which returns the destination to connect to
+func startTestProxy(t *testing.T, handshake func(net.Conn) string) (string, chan string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	destinations := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				destination := handshake(conn)
+				destinations <- destination
+				target, err := net.Dial("tcp", destination)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+	return lis.Addr().String(), destinations, func() { lis.Close() }
+}
+
+func httpConnectHandshake(conn net.Conn) string {
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil || req.Method != "CONNECT" {
+		conn.Close()
+		return ""
+	}
+	io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	return req.Host
+}
+
+func socks5Handshake(conn net.Conn) string {
+	greeting := make([]byte, 3)
+	io.ReadFull(conn, greeting)
+	conn.Write([]byte{socks5Version, socks5NoAuth})
+	request := make([]byte, 4+net.IPv4len+2)
+	io.ReadFull(conn, request)
+	conn.Write([]byte{socks5Version, 0, 0, socks5IPv4, 0, 0, 0, 0, 0, 0})
+	ip := net.IP(request[4 : 4+net.IPv4len])
+	port := binary.BigEndian.Uint16(request[4+net.IPv4len:])
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))
+}
+
+func newTestProxy(t *testing.T, proxyURL string, bypass []string) *Proxy {
+	viper.Set("test.proxy.url", proxyURL)
+	viper.Set("test.proxy.bypass", bypass)
+	p, err := NewProxy("test.proxy")
+	if err != nil {
+		t.Fatalf("Error creating proxy: %s", err)
+	}
+	return p
+}
+
+func testDialThroughProxy(t *testing.T, scheme string, handshake func(net.Conn) string) {
+	echo, stopEcho := startEchoServer(t)
+	defer stopEcho()
+	proxyAddress, destinations, stopProxy := startTestProxy(t, handshake)
+	defer stopProxy()
+
+	p := newTestProxy(t, scheme+"://"+proxyAddress, nil)
+	conn, err := p.Dial(echo, time.Second)
+	if err != nil {
+		t.Fatalf("Error dialing through the %s proxy: %s", scheme, err)
+	}
+	defer conn.Close()
+	if destination := <-destinations; destination != echo {
+		t.Errorf("Expected the proxy to connect to %s, got %s", echo, destination)
+	}
+	io.WriteString(conn, "ping")
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "ping" {
+		t.Errorf("Expected the tunnel to reach the destination, got %q, %v", reply, err)
+	}
+}
+
+func TestProxyHTTPConnect(t *testing.T) {
+	testDialThroughProxy(t, "http", httpConnectHandshake)
+}
+
+func TestProxySOCKS5(t *testing.T) {
+	testDialThroughProxy(t, "socks5", socks5Handshake)
+}
+
+func TestProxyBypass(t *testing.T) {
+	p := newTestProxy(t, "http://proxy:3128", []string{"localhost", ".internal", "10.0.0.0/8", "2001:db8::1"})
+	for address, bypassed := range map[string]bool{
+		"localhost:30303":        true,
+		"vp0.internal:30303":     true,
+		"internal:30303":         true,
+		"vp0.internal.com:30303": false,
+		"10.1.2.3:30303":         true,
+		"11.1.2.3:30303":         false,
+		"[2001:db8::1]:30303":    true,
+		"[2001:db8::2]:30303":    false,
+		"vp0.example.com:30303":  false,
+	} {
+		if p.Bypassed(address) != bypassed {
+			t.Errorf("Expected %s to be bypassed %t", address, bypassed)
+		}
+	}
+
+	if p := newTestProxy(t, "", nil); p != nil {
+		t.Errorf("Expected no proxy without a URL")
+	}
+	viper.Set("test.proxy.url", "ftp://proxy:21")
+	if _, err := NewProxy("test.proxy"); err == nil {
+		t.Errorf("Expected an unsupported proxy scheme to be rejected")
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/transport"
+)
+
+// maxRateLimitedClients bounds the number of clients a RateLimiter keeps
+// track of, beyond which the clients whose bucket refilled are forgotten
+const maxRateLimitedClients = 10000
+
+// rateLimitRejections counts the requests refused by all rate limiters
+var rateLimitRejections uint64
+
+// RateLimitRejections returns the number of requests refused by the rate
+// limiters since the peer started
+func RateLimitRejections() uint64 {
+	return atomic.LoadUint64(&rateLimitRejections)
+}
+
+// RateLimitedError is returned for a request refused by a RateLimiter
+type RateLimitedError struct {
+	Client     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("Rate limit exceeded for %s, retry in %s", e.Client, e.RetryAfter)
+}
+
+// RateLimiter limits the rate of the requests of each client with a token
+// bucket per client, so that a single client cannot take all the capacity
+// of the peer. Clients are told apart by ClientIdentity.
+type RateLimiter struct {
+	lock    sync.Mutex
+	rate    float64
+	burst   float64
+	clients map[string]*clientBucket
+	now     func() time.Time
+}
+
+type clientBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns the limiter configured under key, e.g.
+// peer.rateLimit.queries for peer.rateLimit.queries.rate, in requests per
+// second, and peer.rateLimit.queries.burst, the requests admitted at once,
+// by default one second worth of requests. It returns nil, which admits all
+// requests, if the rate is not positive.
+func NewRateLimiter(key string) *RateLimiter {
+	rate := viper.GetFloat64(key + ".rate")
+	if rate <= 0 {
+		return nil
+	}
+	burst := float64(viper.GetInt(key + ".burst"))
+	if burst <= 0 {
+		burst = math.Max(1, math.Ceil(rate))
+	}
+	return &RateLimiter{
+		rate:    rate,
+		burst:   burst,
+		clients: make(map[string]*clientBucket),
+		now:     time.Now,
+	}
+}
+
+// Admit takes a token from the bucket of client, and returns a
+// RateLimitedError if it is empty
+func (l *RateLimiter) Admit(client string) error {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	bucket, ok := l.clients[client]
+	if !ok {
+		if len(l.clients) >= maxRateLimitedClients {
+			l.forgetIdle(now)
+		}
+		bucket = &clientBucket{tokens: l.burst, last: now}
+		l.clients[client] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		atomic.AddUint64(&rateLimitRejections, 1)
+		retryAfter := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+		commLogger.Debugf("Rate limit exceeded for %s", client)
+		return &RateLimitedError{Client: client, RetryAfter: retryAfter}
+	}
+	bucket.tokens--
+	return nil
+}
+
+// forgetIdle drops the clients whose bucket refilled, which are admitted
+// as new clients would be
+func (l *RateLimiter) forgetIdle(now time.Time) {
+	for client, bucket := range l.clients {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.clients, client)
+		}
+	}
+}
+
+type clientIdentityKey struct{}
+
+// NewClientContext returns a context carrying the identity of the client of
+// a request which did not arrive over gRPC, e.g. a REST request
+func NewClientContext(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, clientIdentityKey{}, identity)
+}
+
+// ClientIdentity tells the clients of the calls in ctx apart: by the subject
+// of the certificate they presented, or else by their IP address
+func ClientIdentity(ctx context.Context) string {
+	if identity, ok := ctx.Value(clientIdentityKey{}).(string); ok {
+		return identity
+	}
+	if cert := ClientCertificate(ctx); cert != nil {
+		return subjectDN(cert.Subject)
+	}
+	if stream, ok := transport.StreamFromContext(ctx); ok {
+		return addressIdentity(stream.ServerTransport().RemoteAddr().String())
+	}
+	return ""
+}
+
+// HTTPClientIdentity tells the clients of HTTP requests apart, like
+// ClientIdentity
+func HTTPClientIdentity(req *http.Request) string {
+	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		return subjectDN(req.TLS.PeerCertificates[0].Subject)
+	}
+	return addressIdentity(req.RemoteAddr)
+}
+
+func addressIdentity(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
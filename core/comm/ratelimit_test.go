@@ -0,0 +1,108 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+func newTestRateLimiter(t *testing.T, rate float64, burst int) (*RateLimiter, *time.Time) {
+	defer restoreSettings("test.rateLimit.rate", "test.rateLimit.burst")()
+	viper.Set("test.rateLimit.rate", rate)
+	viper.Set("test.rateLimit.burst", burst)
+	limiter := NewRateLimiter("test.rateLimit")
+	if limiter == nil {
+		t.Fatalf("Expected a rate limiter")
+	}
+	now := time.Unix(0, 0)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestRateLimiterLimitsEachClient(t *testing.T) {
+	limiter, now := newTestRateLimiter(t, 2, 3)
+	rejected := RateLimitRejections()
+
+	for i := 0; i < 3; i++ {
+		if err := limiter.Admit("alice"); err != nil {
+			t.Fatalf("Expected the burst to be admitted: %s", err)
+		}
+	}
+	err := limiter.Admit("alice")
+	limited, ok := err.(*RateLimitedError)
+	if !ok || limited.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("Expected the request beyond the burst to be refused for half a second, got %v", err)
+	}
+	if RateLimitRejections() != rejected+1 {
+		t.Errorf("Expected the refusal to be counted")
+	}
+
+	// Other clients have their own bucket
+	if err := limiter.Admit("bob"); err != nil {
+		t.Errorf("Expected another client to be admitted: %s", err)
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if err := limiter.Admit("alice"); err != nil {
+		t.Errorf("Expected the client to be admitted once the bucket refilled: %s", err)
+	}
+}
+
+func TestRateLimiterForgetsIdleClients(t *testing.T) {
+	limiter, now := newTestRateLimiter(t, 1, 1)
+	limiter.Admit("alice")
+	limiter.Admit("bob")
+	*now = now.Add(time.Second)
+	limiter.Admit("bob")
+
+	limiter.forgetIdle(*now)
+	if _, ok := limiter.clients["alice"]; ok {
+		t.Errorf("Expected the client whose bucket refilled to be forgotten")
+	}
+	if _, ok := limiter.clients["bob"]; !ok {
+		t.Errorf("Expected the client whose bucket is empty to be kept")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	defer restoreSettings("test.rateLimit.rate")()
+	viper.Set("test.rateLimit.rate", 0)
+	limiter := NewRateLimiter("test.rateLimit")
+	if limiter != nil {
+		t.Fatalf("Expected no limiter without a rate")
+	}
+	if err := limiter.Admit("alice"); err != nil {
+		t.Errorf("Expected a nil limiter to admit all requests")
+	}
+}
+
+func TestClientIdentity(t *testing.T) {
+	if identity := ClientIdentity(NewClientContext(context.Background(), "10.0.0.1")); identity != "10.0.0.1" {
+		t.Errorf("Expected the identity carried by the context, got %s", identity)
+	}
+	if identity := ClientIdentity(context.Background()); identity != "" {
+		t.Errorf("Expected no identity outside a call, got %s", identity)
+	}
+	if identity := HTTPClientIdentity(&http.Request{RemoteAddr: "[2001:db8::1]:4711"}); identity != "2001:db8::1" {
+		t.Errorf("Expected the IP address of the HTTP client, got %s", identity)
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"crypto/tls"
+
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/credentials"
+)
+
+// ServiceTLS is the TLS configuration of a service of the peer listening on
+// its own address, such as the event hub or the REST service, so that the
+// client-facing services can be exposed on another network than the one
+// carrying the traffic between peers
+type ServiceTLS struct {
+	Enabled  bool
+	CertFile string
+	KeyFile  string
+}
+
+// GetServiceTLS returns the TLS configuration under key, e.g. rest.tls for
+// rest.tls.enabled, rest.tls.cert.file and rest.tls.key.file. Unset values
+// are taken from peer.tls, so that a service presents the certificate of
+// the peer unless configured otherwise.
+func GetServiceTLS(key string) ServiceTLS {
+	config := ServiceTLS{
+		Enabled:  TLSEnabled(),
+		CertFile: viper.GetString("peer.tls.cert.file"),
+		KeyFile:  viper.GetString("peer.tls.key.file"),
+	}
+	if enabled := viper.Get(key + ".enabled"); enabled != nil && enabled != "" {
+		config.Enabled = viper.GetBool(key + ".enabled")
+	}
+	if certFile := viper.GetString(key + ".cert.file"); certFile != "" {
+		config.CertFile = certFile
+		config.KeyFile = viper.GetString(key + ".key.file")
+	}
+	return config
+}
+
+// ServerCredentials returns the TLS credentials of the server of the
+// service. A service presenting the certificate of the peer presents the
+// renewed one after ReloadServerCertificate. Certificates clients present
+// are verified as by the peer's server.
+func (c ServiceTLS) ServerCredentials() (credentials.TransportAuthenticator, error) {
+	if c.CertFile == viper.GetString("peer.tls.cert.file") && c.KeyFile == viper.GetString("peer.tls.key.file") {
+		return NewServerTLSForPeer()
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if err := verifyClientCertificates(config); err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(config), nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// restoreSettings returns a function setting keys back to their current
+// values
+func restoreSettings(keys ...string) func() {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = viper.Get(key)
+	}
+	return func() {
+		for i, key := range keys {
+			viper.Set(key, values[i])
+		}
+		CacheConfiguration()
+	}
+}
+
+func TestGetServiceTLSDefaultsToPeer(t *testing.T) {
+	defer restoreSettings("peer.tls.enabled", "peer.tls.cert.file", "peer.tls.key.file",
+		"rest.tls.enabled", "rest.tls.cert.file", "rest.tls.key.file")()
+	viper.Set("peer.tls.enabled", true)
+	viper.Set("peer.tls.cert.file", "peer.pem")
+	viper.Set("peer.tls.key.file", "peer.key")
+	viper.Set("rest.tls.enabled", nil)
+	viper.Set("rest.tls.cert.file", "")
+	CacheConfiguration()
+
+	if config := GetServiceTLS("rest.tls"); !config.Enabled || config.CertFile != "peer.pem" || config.KeyFile != "peer.key" {
+		t.Errorf("Expected the settings of the peer, got %+v", config)
+	}
+
+	viper.Set("rest.tls.enabled", false)
+	if config := GetServiceTLS("rest.tls"); config.Enabled {
+		t.Errorf("Expected TLS to be disabled for the service")
+	}
+
+	viper.Set("rest.tls.enabled", "")
+	viper.Set("rest.tls.cert.file", "rest.pem")
+	viper.Set("rest.tls.key.file", "rest.key")
+	if config := GetServiceTLS("rest.tls"); !config.Enabled || config.CertFile != "rest.pem" || config.KeyFile != "rest.key" {
+		t.Errorf("Expected the certificate of the service, got %+v", config)
+	}
+}
+
+func TestServiceTLSServerCredentials(t *testing.T) {
+	defer restoreSettings("peer.tls.cert.file", "peer.tls.key.file", "rest.tls.cert.file", "rest.tls.key.file")()
+	dir, err := ioutil.TempDir("", "servicetls")
+	if err != nil {
+		t.Fatalf("Error creating directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestCertificate(t, dir, "vp0")
+	viper.Set("rest.tls.cert.file", "")
+
+	if _, err := GetServiceTLS("rest.tls").ServerCredentials(); err != nil {
+		t.Errorf("Error creating the credentials of a service presenting the peer certificate: %s", err)
+	}
+	viper.Set("rest.tls.cert.file", dir+"/missing.pem")
+	viper.Set("rest.tls.key.file", dir+"/missing.key")
+	if _, err := GetServiceTLS("rest.tls").ServerCredentials(); err == nil {
+		t.Errorf("Expected missing certificate files to be reported")
+	}
+}
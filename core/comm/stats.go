@@ -0,0 +1,104 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnectionStats counts the traffic on a connection with a remote peer, so
+// that network problems affecting the connections with some peers only can
+// be told apart
+type ConnectionStats struct {
+	bytesSent        uint64 // the counters are updated atomically
+	bytesReceived    uint64
+	messagesSent     uint64
+	messagesReceived uint64
+	sendErrors       uint64
+	receiveErrors    uint64
+
+	lock          sync.Mutex
+	roundTrip     time.Duration // smoothed round-trip time
+	lastRoundTrip time.Duration
+}
+
+// ConnectionCounters are the totals of a ConnectionStats at one time
+type ConnectionCounters struct {
+	BytesSent        uint64
+	BytesReceived    uint64
+	MessagesSent     uint64
+	MessagesReceived uint64
+	SendErrors       uint64
+	ReceiveErrors    uint64
+	// RoundTrip is the smoothed round-trip time, 0 until measured
+	RoundTrip     time.Duration
+	LastRoundTrip time.Duration
+}
+
+// Sent counts a message of size bytes sent, or which failed to be sent
+func (s *ConnectionStats) Sent(size int, err error) {
+	if err != nil {
+		atomic.AddUint64(&s.sendErrors, 1)
+		return
+	}
+	atomic.AddUint64(&s.messagesSent, 1)
+	atomic.AddUint64(&s.bytesSent, uint64(size))
+}
+
+// Received counts a message of size bytes received, or a failure to receive
+// or handle one
+func (s *ConnectionStats) Received(size int, err error) {
+	if err != nil {
+		atomic.AddUint64(&s.receiveErrors, 1)
+		return
+	}
+	atomic.AddUint64(&s.messagesReceived, 1)
+	atomic.AddUint64(&s.bytesReceived, uint64(size))
+}
+
+// RoundTrip takes note of a measured round-trip time. Like TCP, it keeps a
+// moving average giving each new measurement a weight of 1/8, so that one
+// slow exchange does not hide the usual latency.
+func (s *ConnectionStats) RoundTrip(rtt time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lastRoundTrip = rtt
+	if s.roundTrip == 0 {
+		s.roundTrip = rtt
+		return
+	}
+	s.roundTrip += (rtt - s.roundTrip) / 8
+}
+
+// Counters returns the current totals
+func (s *ConnectionStats) Counters() ConnectionCounters {
+	s.lock.Lock()
+	roundTrip, lastRoundTrip := s.roundTrip, s.lastRoundTrip
+	s.lock.Unlock()
+	return ConnectionCounters{
+		BytesSent:        atomic.LoadUint64(&s.bytesSent),
+		BytesReceived:    atomic.LoadUint64(&s.bytesReceived),
+		MessagesSent:     atomic.LoadUint64(&s.messagesSent),
+		MessagesReceived: atomic.LoadUint64(&s.messagesReceived),
+		SendErrors:       atomic.LoadUint64(&s.sendErrors),
+		ReceiveErrors:    atomic.LoadUint64(&s.receiveErrors),
+		RoundTrip:        roundTrip,
+		LastRoundTrip:    lastRoundTrip,
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnectionStatsCounts(t *testing.T) {
+	var stats ConnectionStats
+	stats.Sent(100, nil)
+	stats.Sent(50, nil)
+	stats.Sent(10, errors.New("broken"))
+	stats.Received(30, nil)
+	stats.Received(0, errors.New("broken"))
+
+	counters := stats.Counters()
+	if counters.BytesSent != 150 || counters.MessagesSent != 2 || counters.SendErrors != 1 {
+		t.Errorf("Expected 2 messages of 150 bytes sent and 1 error, got %+v", counters)
+	}
+	if counters.BytesReceived != 30 || counters.MessagesReceived != 1 || counters.ReceiveErrors != 1 {
+		t.Errorf("Expected 1 message of 30 bytes received and 1 error, got %+v", counters)
+	}
+}
+
+func TestConnectionStatsSmoothsRoundTrip(t *testing.T) {
+	var stats ConnectionStats
+	if rtt := stats.Counters().RoundTrip; rtt != 0 {
+		t.Fatalf("Expected no round-trip time before it is measured, got %s", rtt)
+	}
+	stats.RoundTrip(80 * time.Millisecond)
+	if rtt := stats.Counters().RoundTrip; rtt != 80*time.Millisecond {
+		t.Fatalf("Expected the first measurement to be taken as is, got %s", rtt)
+	}
+	stats.RoundTrip(880 * time.Millisecond)
+	if rtt := stats.Counters().RoundTrip; rtt != 180*time.Millisecond {
+		t.Errorf("Expected one slow exchange to weigh 1/8, got %s", rtt)
+	}
+	if rtt := stats.Counters().LastRoundTrip; rtt != 880*time.Millisecond {
+		t.Errorf("Expected the last measurement to be reported as is, got %s", rtt)
+	}
+}
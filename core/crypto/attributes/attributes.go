@@ -22,8 +22,10 @@ import (
 	"encoding/asn1"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	pb "github.com/hyperledger/fabric/core/crypto/attributes/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
@@ -40,11 +42,18 @@ var (
 	// TCertAttributesHeaders is the ASN1 object identifier of attributes header.
 	TCertAttributesHeaders = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 9}
 
+	// TCertAttributesValidity is the ASN1 object identifier of the validity
+	// of the attributes of a TCert.
+	TCertAttributesValidity = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 9, 1}
+
 	padding = []byte{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255}
 
 	//headerPrefix is the prefix used in the header exteion of the certificate.
 	headerPrefix = "00HEAD"
 
+	//validityPrefix is the prefix used in the validity extension of the certificate.
+	validityPrefix = "00VALID"
+
 	//HeaderAttributeName is the name used to derivate the K used to encrypt/decrypt the header.
 	HeaderAttributeName = "attributeHeader"
 )
@@ -271,3 +280,72 @@ func BuildAttributesHeader(attributesHeader map[string]int) ([]byte, error) {
 	header = []byte(headerPrefix + headerString)
 	return header, nil
 }
+
+//BuildAttributesValidity builds the validity extension from a map of attribute names and the times they expire at.
+//The times are rounded down to the second.
+func BuildAttributesValidity(validTo map[string]time.Time) ([]byte, error) {
+	var names []string
+	for name := range validTo {
+		if strings.Contains(name, "->") || strings.Contains(name, "#") {
+			return nil, errors.New("Invalid attribute name '" + name + "'")
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	validity := validityPrefix
+	for _, name := range names {
+		validity = validity + name + "->" + strconv.FormatInt(validTo[name].Unix(), 10) + "#"
+	}
+	return []byte(validity), nil
+}
+
+//ParseAttributesValidity parses a string and returns a map with the times the attributes expire at.
+func ParseAttributesValidity(validity string) (map[string]time.Time, error) {
+	if !strings.HasPrefix(validity, validityPrefix) {
+		return nil, errors.New("Invalid attributes validity")
+	}
+	result := make(map[string]time.Time)
+	for _, token := range strings.Split(strings.TrimPrefix(validity, validityPrefix), "#") {
+		pair := strings.Split(token, "->")
+		if len(pair) == 2 {
+			seconds, err := strconv.ParseInt(pair[1], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			result[pair[0]] = time.Unix(seconds, 0)
+		}
+	}
+	return result, nil
+}
+
+//ReadAttributesValidity reads the times the attributes of the TCert expire at. Attributes without validity extension, as
+//those of TCerts issued before attributes expired, do not expire.
+func ReadAttributesValidity(tcert *x509.Certificate, headerKey []byte) (map[string]time.Time, error) {
+	raw, err := primitives.GetCriticalExtension(tcert, TCertAttributesValidity)
+	if err != nil {
+		return map[string]time.Time{}, nil
+	}
+	validity, err := ParseAttributesValidity(string(raw))
+	if err != nil {
+		if headerKey == nil {
+			return nil, errors.New("Is not possible read the attributes validity encrypted without the headerKey")
+		}
+		if raw, err = DecryptAttributeValue(headerKey, raw); err != nil {
+			return nil, errors.New("error decrypting attributes validity '" + err.Error() + "'")
+		}
+		if validity, err = ParseAttributesValidity(string(raw)); err != nil {
+			return nil, err
+		}
+	}
+	return validity, nil
+}
+
+//CheckAttributeValidity returns an error if the attribute "attributeName" has expired at "at", according to the validity read by ReadAttributesValidity.
+func CheckAttributeValidity(validity map[string]time.Time, attributeName string, at time.Time) error {
+	validTo, ok := validity[attributeName]
+	if ok && !at.Before(validTo) {
+		return fmt.Errorf("Attribute '%s' expired at %s", attributeName, validTo.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
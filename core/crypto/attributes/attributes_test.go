@@ -26,6 +26,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	pb "github.com/hyperledger/fabric/core/crypto/attributes/proto"
@@ -210,6 +211,50 @@ func TestBuildAndParseAttributesHeader(t *testing.T) {
 	}
 }
 
+func TestBuildAndParseAttributesValidity(t *testing.T) {
+	expiry := time.Unix(1500000000, 0)
+	validityRaw, err := BuildAttributesValidity(map[string]time.Time{"position": expiry.Add(999 * time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validity, err := ParseAttributesValidity(string(validityRaw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(validity) != 1 || !validity["position"].Equal(expiry) {
+		t.Fatalf("Error parsing validity. Expected position to expire at %v, found %v instead", expiry, validity)
+	}
+
+	if err = CheckAttributeValidity(validity, "position", expiry.Add(-time.Second)); err != nil {
+		t.Errorf("Expected position to be valid before it expires, got %s", err)
+	}
+	if err = CheckAttributeValidity(validity, "position", expiry); err == nil {
+		t.Error("Expected position to have expired")
+	}
+	if err = CheckAttributeValidity(validity, "company", expiry.Add(time.Hour)); err != nil {
+		t.Errorf("Expected an attribute without validity not to expire, got %s", err)
+	}
+
+	if _, err = BuildAttributesValidity(map[string]time.Time{"a->b": expiry}); err == nil {
+		t.Error("Expected an invalid attribute name to be refused")
+	}
+}
+
+func TestReadAttributesValidity_WithoutExtension(t *testing.T) {
+	tcert, _, err := loadTCertAndPreK0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	validity, err := ReadAttributesValidity(tcert, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(validity) != 0 {
+		t.Fatalf("Expected the attributes of a TCert without validity not to expire, got %v", validity)
+	}
+}
+
 func TestReadAttributeHeader(t *testing.T) {
 	tcert, prek0, err := loadTCertAndPreK0()
 	if err != nil {
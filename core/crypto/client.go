@@ -71,6 +71,37 @@ func RegisterClient(name string, pwd []byte, enrollID, enrollPWD string) error {
 	return nil
 }
 
+// ImportClient registers a client named name with password pwd from the
+// PKCS#12 bundle protected by password, as exported by ExportPKCS12, instead
+// of enrolling it with the ECA
+func ImportClient(name string, pwd []byte, bundle []byte, password string) error {
+	clientMutex.Lock()
+	defer clientMutex.Unlock()
+
+	log.Infof("Importing client [%s]...", name)
+
+	if _, ok := clients[name]; ok {
+		log.Errorf("Failed importing client [%s]: already initialized.", name)
+
+		return utils.ErrAlreadyInitialized
+	}
+
+	client := newClient()
+	if err := client.importPKCS12(name, pwd, bundle, password); err != nil {
+		log.Errorf("Failed importing client [%s]: [%s].", name, err)
+
+		return err
+	}
+	if err := client.close(); err != nil {
+		// It is not necessary to report this error to the caller
+		log.Warningf("Importing client [%s]. Failed closing [%s].", name, err)
+	}
+
+	log.Infof("Importing client [%s]...done!", name)
+
+	return nil
+}
+
 // InitClient initializes a client named name with password pwd
 func InitClient(name string, pwd []byte) (Client, error) {
 	clientMutex.Lock()
@@ -129,7 +160,7 @@ func CloseAllClients() (bool, []error) {
 // Private Methods
 
 func newClient() *clientImpl {
-	return &clientImpl{&nodeImpl{}, nil, nil, nil, nil}
+	return &clientImpl{nodeImpl: &nodeImpl{}}
 }
 
 func closeClientInternal(client Client, force bool) error {
@@ -0,0 +1,170 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// DecryptArguments returns the arguments of the chaincode invocation tx,
+// with the arguments encrypted for this client decrypted
+func (client *clientImpl) DecryptArguments(tx *obc.Transaction) ([]string, error) {
+	// Verify that the client is initialized
+	if !client.IsInitialized() {
+		return nil, utils.ErrNotInitialized
+	}
+
+	decrypted, err := client.decryptArguments(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec *obc.ChaincodeSpec
+	switch decrypted.Type {
+	case obc.Transaction_CHAINCODE_DEPLOY:
+		cds := &obc.ChaincodeDeploymentSpec{}
+		if err := proto.Unmarshal(decrypted.Payload, cds); err != nil {
+			return nil, err
+		}
+		spec = cds.ChaincodeSpec
+	case obc.Transaction_CHAINCODE_INVOKE, obc.Transaction_CHAINCODE_QUERY:
+		cis := &obc.ChaincodeInvocationSpec{}
+		if err := proto.Unmarshal(decrypted.Payload, cis); err != nil {
+			return nil, err
+		}
+		spec = cis.ChaincodeSpec
+	default:
+		return nil, utils.ErrInvalidTransactionType
+	}
+
+	if spec.GetCtorMsg() == nil {
+		return nil, nil
+	}
+
+	return spec.CtorMsg.Args, nil
+}
+
+// encryptArguments returns a copy of spec whose arguments designated by its
+// argument audiences are encrypted for them, or spec itself if there are none.
+// Each argument is encrypted with its own key, encrypted to the enrollment
+// key of each certificate of the audience.
+func (client *clientImpl) encryptArguments(spec *obc.ChaincodeSpec, uuid string) (*obc.ChaincodeSpec, error) {
+	if spec == nil || len(spec.ArgumentAudiences) == 0 {
+		return spec, nil
+	}
+
+	raw, err := proto.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	clone := &obc.ChaincodeSpec{}
+	if err = proto.Unmarshal(raw, clone); err != nil {
+		return nil, err
+	}
+
+	aad, err := asn1.Marshal(argumentBinding{chaincodeIDName(clone.ChaincodeID), uuid})
+	if err != nil {
+		return nil, err
+	}
+	for _, audience := range clone.ArgumentAudiences {
+		var members []*ecdsa.PublicKey
+		for _, der := range audience.Certificates {
+			cert, err := primitives.DERToX509Certificate(der)
+			if err != nil {
+				client.Errorf("Failed parsing the certificate of the audience: [%s]", err)
+
+				return nil, err
+			}
+			pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+			if !ok {
+				return nil, fmt.Errorf("The key of [%s] is not an ECDSA key.", cert.Subject.CommonName)
+			}
+			members = append(members, pub)
+		}
+		if len(members) == 0 {
+			return nil, fmt.Errorf("No certificate in the audience of arguments %v.", audience.Args)
+		}
+
+		for _, i := range audience.Args {
+			if clone.CtorMsg == nil || i < 0 || int(i) >= len(clone.CtorMsg.Args) {
+				return nil, fmt.Errorf("Invalid argument index [%d].", i)
+			}
+			arg, err := client.sealArgument([]byte(clone.CtorMsg.Args[i]), aad, members)
+			if err != nil {
+				client.Errorf("Failed encrypting argument [%d]: [%s]", i, err)
+
+				return nil, err
+			}
+			clone.CtorMsg.Args[i] = encryptedArgumentPrefix + base64.StdEncoding.EncodeToString(arg)
+		}
+	}
+	clone.ArgumentAudiences = nil
+
+	return clone, nil
+}
+
+func (client *clientImpl) sealArgument(value, aad []byte, members []*ecdsa.PublicKey) ([]byte, error) {
+	key, err := primitives.GenAESKey()
+	if err != nil {
+		return nil, err
+	}
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(c)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := primitives.GetRandomBytes(gcm.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := argumentEnvelope{Ciphertext: gcm.Seal(nonce, nonce, value, aad)}
+	for _, member := range members {
+		pub, err := client.eciesSPI.NewPublicKey(nil, member)
+		if err != nil {
+			return nil, err
+		}
+		asymCipher, err := client.eciesSPI.NewAsymmetricCipherFromPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		encKey, err := asymCipher.Process(key)
+		if err != nil {
+			return nil, err
+		}
+		keyID, err := enrollmentKeyID(member)
+		if err != nil {
+			return nil, err
+		}
+		envelope.Keys = append(envelope.Keys, chaincodeKeyEnvelope1_3{keyID, encKey})
+	}
+
+	return asn1.Marshal(envelope)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// ExportChaincodeKey returns the public key of the confidential chaincode
+// name, deployed by this client, to hand it to the clients authorized to
+// invoke and query the chaincode
+func (client *clientImpl) ExportChaincodeKey(name string) ([]byte, error) {
+	// Verify that the client is initialized
+	if !client.IsInitialized() {
+		return nil, utils.ErrNotInitialized
+	}
+
+	return client.ks.loadKey(client.conf.getChaincodeKeyFilename(primitives.Hash([]byte(name))))
+}
+
+// ImportChaincodeKey registers the public key of the confidential chaincode
+// name, as exported by its deployer, to invoke and query the chaincode
+func (client *clientImpl) ImportChaincodeKey(name string, key []byte) error {
+	// Verify that the client is initialized
+	if !client.IsInitialized() {
+		return utils.ErrNotInitialized
+	}
+
+	if _, err := client.eciesSPI.DeserializePublicKey(key); err != nil {
+		client.Errorf("Invalid key for chaincode [%s]: [%s]", name, err)
+
+		return utils.ErrInvalidKey
+	}
+
+	return client.ks.storeKey(client.conf.getChaincodeKeyFilename(primitives.Hash([]byte(name))), key)
+}
+
+// loadChaincodeValidators returns the enrollment keys of the validators
+// authorized to execute the confidential chaincodes this client deploys,
+// read from their enrollment certificates
+func (client *clientImpl) loadChaincodeValidators() ([]*ecdsa.PublicKey, error) {
+	path := client.conf.getChaincodeValidatorsPath()
+	if path == "" {
+		return nil, errors.New("No authorized validators, security.confidentiality.validators.file is not set.")
+	}
+
+	raw, err := client.ks.loadExternalCert(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var validators []*ecdsa.PublicKey
+	for {
+		var block *pem.Block
+		if block, raw = pem.Decode(raw); block == nil {
+			break
+		}
+		cert, err := primitives.DERToX509Certificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("The enrollment key of validator [%s] is not an ECDSA key.", cert.Subject.CommonName)
+		}
+		validators = append(validators, pub)
+	}
+	if len(validators) == 0 {
+		return nil, errors.New("No validator certificate found in " + path)
+	}
+
+	return validators, nil
+}
+
+// enrollmentKeyID identifies the enrollment key pub of a validator in the
+// deploy transactions of confidential chaincodes
+func enrollmentKeyID(pub *ecdsa.PublicKey) ([]byte, error) {
+	raw, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return primitives.Hash(raw), nil
+}
+
+// getChaincodeName returns the name of the chaincode of tx, whose
+// chaincodeID has not been encrypted yet, or its path if it is not named
+func getChaincodeName(tx *obc.Transaction) (string, error) {
+	chaincodeID := &obc.ChaincodeID{}
+	if err := proto.Unmarshal(tx.ChaincodeID, chaincodeID); err != nil {
+		return "", err
+	}
+	if name := chaincodeIDName(chaincodeID); name != "" {
+		return name, nil
+	}
+
+	return "", errors.New("Invalid chaincodeID, the name is missing.")
+}
@@ -37,6 +37,9 @@ func (client *clientImpl) encryptTx(tx *obc.Transaction) error {
 	case "1.2":
 		client.Debug("Using confidentiality protocol version 1.2")
 		return client.encryptTxVersion1_2(tx)
+	case "1.3":
+		client.Debug("Using confidentiality protocol version 1.3")
+		return client.encryptTxVersion1_3(tx)
 	}
 
 	return utils.ErrInvalidProtocolVersion
@@ -48,6 +51,36 @@ type chainCodeValidatorMessage1_2 struct {
 	StateKey   []byte
 }
 
+// chaincodeKeys1_3 is the key hierarchy of a confidential chaincode. The
+// chaincode key decrypts the messages to the validators of its transactions,
+// and the state key is the root of the keys encrypting its state.
+type chaincodeKeys1_3 struct {
+	ChaincodeKey []byte
+	StateKey     []byte
+}
+
+// chaincodeKeyEnvelope1_3 holds the keys of a chaincode encrypted to the
+// enrollment key, identified by KeyID, of an authorized validator
+type chaincodeKeyEnvelope1_3 struct {
+	KeyID []byte
+	Keys  []byte
+}
+
+// chaincodeDeployMessage1_3 represents the message to validators of a deploy
+// transaction: the keys of the chaincode, identified by the hash KeyID of the
+// public chaincode key, for each authorized validator
+type chaincodeDeployMessage1_3 struct {
+	KeyID     []byte
+	Envelopes []chaincodeKeyEnvelope1_3
+}
+
+// chaincodeExecuteMessage1_3 represents the message to validators of an
+// invoke or query transaction, encrypted with the chaincode key KeyID
+type chaincodeExecuteMessage1_3 struct {
+	KeyID   []byte
+	Message []byte
+}
+
 func (client *clientImpl) encryptTxVersion1_2(tx *obc.Transaction) error {
 	// Create (PK_C,SK_C) pair
 	ccPrivateKey, err := client.eciesSPI.NewPrivateKey(rand.Reader, primitives.GetDefaultCurve())
@@ -130,9 +163,14 @@ func (client *clientImpl) encryptTxVersion1_2(tx *obc.Transaction) error {
 	tx.ToValidators = encMsgToValidators
 
 	// Encrypt the rest of the fields
+	return client.encryptTxFields(tx, ccPrivateKey.GetPublicKey())
+}
 
+// encryptTxFields encrypts the chaincodeID, payload and metadata of tx with
+// the transaction key pub
+func (client *clientImpl) encryptTxFields(tx *obc.Transaction, pub primitives.PublicKey) error {
 	// Init with chainccode pk
-	cipher, err = client.eciesSPI.NewAsymmetricCipherFromPublicKey(ccPrivateKey.GetPublicKey())
+	cipher, err := client.eciesSPI.NewAsymmetricCipherFromPublicKey(pub)
 	if err != nil {
 		client.Errorf("Failed initiliazing encryption scheme: [%s]", err)
 
@@ -170,3 +208,165 @@ func (client *clientImpl) encryptTxVersion1_2(tx *obc.Transaction) error {
 
 	return nil
 }
+
+func (client *clientImpl) encryptTxVersion1_3(tx *obc.Transaction) error {
+	var (
+		txKey primitives.PrivateKey
+		err   error
+	)
+
+	switch tx.Type {
+	case obc.Transaction_CHAINCODE_DEPLOY:
+		// Every deployment gets a new key hierarchy, so that deploying a new
+		// version of a chaincode rotates its keys
+		txKey, err = client.newChaincodeKeys(tx)
+	case obc.Transaction_CHAINCODE_INVOKE, obc.Transaction_CHAINCODE_QUERY:
+		txKey, err = client.newChaincodeExecuteKey(tx)
+	default:
+		return utils.ErrInvalidTransactionType
+	}
+	if err != nil {
+		return err
+	}
+
+	return client.encryptTxFields(tx, txKey.GetPublicKey())
+}
+
+// newChaincodeKeys creates the keys of the chaincode deployed by tx, and
+// encrypts them to each of the authorized validators. The chaincode key also
+// encrypts the fields of the deploy transaction.
+func (client *clientImpl) newChaincodeKeys(tx *obc.Transaction) (primitives.PrivateKey, error) {
+	validators, err := client.loadChaincodeValidators()
+	if err != nil {
+		client.Errorf("Failed loading the authorized validators: [%s]", err)
+
+		return nil, err
+	}
+
+	ccPrivateKey, err := client.eciesSPI.NewPrivateKey(rand.Reader, primitives.GetDefaultCurve())
+	if err != nil {
+		client.Errorf("Failed generate chaincode keypair: [%s]", err)
+
+		return nil, err
+	}
+	stateKey, err := primitives.GenAESKey()
+	if err != nil {
+		client.Errorf("Failed creating state key: [%s]", err)
+
+		return nil, err
+	}
+	privBytes, err := client.eciesSPI.SerializePrivateKey(ccPrivateKey)
+	if err != nil {
+		client.Errorf("Failed serializing chaincode key: [%s]", err)
+
+		return nil, err
+	}
+	pubBytes, err := client.eciesSPI.SerializePublicKey(ccPrivateKey.GetPublicKey())
+	if err != nil {
+		client.Errorf("Failed serializing chaincode key: [%s]", err)
+
+		return nil, err
+	}
+	keys, err := asn1.Marshal(chaincodeKeys1_3{privBytes, stateKey})
+	if err != nil {
+		return nil, err
+	}
+
+	msgToValidators := chaincodeDeployMessage1_3{KeyID: primitives.Hash(pubBytes)}
+	for _, validator := range validators {
+		pub, err := client.eciesSPI.NewPublicKey(nil, validator)
+		if err != nil {
+			return nil, err
+		}
+		cipher, err := client.eciesSPI.NewAsymmetricCipherFromPublicKey(pub)
+		if err != nil {
+			client.Errorf("Failed creating new encryption scheme: [%s]", err)
+
+			return nil, err
+		}
+		envelope, err := cipher.Process(keys)
+		if err != nil {
+			client.Errorf("Failed encrypting the chaincode keys: [%s]", err)
+
+			return nil, err
+		}
+		keyID, err := enrollmentKeyID(validator)
+		if err != nil {
+			return nil, err
+		}
+		msgToValidators.Envelopes = append(msgToValidators.Envelopes, chaincodeKeyEnvelope1_3{keyID, envelope})
+	}
+	if tx.ToValidators, err = asn1.Marshal(msgToValidators); err != nil {
+		client.Errorf("Failed preparing message to the validators: [%s]", err)
+
+		return nil, err
+	}
+
+	// Keep the public chaincode key to invoke and query the chaincode
+	name, err := getChaincodeName(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err = client.ks.storeKey(client.conf.getChaincodeKeyFilename(primitives.Hash([]byte(name))), pubBytes); err != nil {
+		return nil, err
+	}
+
+	return ccPrivateKey, nil
+}
+
+// newChaincodeExecuteKey creates the transaction key of tx, and encrypts it
+// to the validators with the public key of the chaincode
+func (client *clientImpl) newChaincodeExecuteKey(tx *obc.Transaction) (primitives.PrivateKey, error) {
+	name, err := getChaincodeName(tx)
+	if err != nil {
+		return nil, err
+	}
+	pubBytes, err := client.ks.loadKey(client.conf.getChaincodeKeyFilename(primitives.Hash([]byte(name))))
+	if err != nil {
+		client.Errorf("Missing the key of chaincode [%s], import it from its deployer: [%s]", name, err)
+
+		return nil, err
+	}
+
+	txKey, err := client.eciesSPI.NewPrivateKey(rand.Reader, primitives.GetDefaultCurve())
+	if err != nil {
+		client.Errorf("Failed generate transaction keypair: [%s]", err)
+
+		return nil, err
+	}
+	privBytes, err := client.eciesSPI.SerializePrivateKey(txKey)
+	if err != nil {
+		client.Errorf("Failed serializing transaction key: [%s]", err)
+
+		return nil, err
+	}
+
+	stateKey := make([]byte, 0)
+	if tx.Type == obc.Transaction_CHAINCODE_QUERY {
+		stateKey = primitives.HMACAESTruncated(client.queryStateKey, append([]byte{6}, tx.Nonce...))
+	}
+	msg, err := asn1.Marshal(chainCodeValidatorMessage1_2{privBytes, stateKey})
+	if err != nil {
+		client.Errorf("Failed preparing message to the validators: [%s]", err)
+
+		return nil, err
+	}
+
+	cipher, err := client.eciesSPI.NewAsymmetricCipherFromSerializedPublicKey(pubBytes)
+	if err != nil {
+		client.Errorf("Failed creating new encryption scheme: [%s]", err)
+
+		return nil, err
+	}
+	encMsg, err := cipher.Process(msg)
+	if err != nil {
+		client.Errorf("Failed encrypting message to the validators: [%s]", err)
+
+		return nil, err
+	}
+	if tx.ToValidators, err = asn1.Marshal(chaincodeExecuteMessage1_3{primitives.Hash(pubBytes), encMsg}); err != nil {
+		return nil, err
+	}
+
+	return txKey, nil
+}
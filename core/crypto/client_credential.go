@@ -0,0 +1,180 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/crypto/idemix"
+	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
+	obc "github.com/hyperledger/fabric/protos"
+	"golang.org/x/net/context"
+
+	"google/protobuf"
+)
+
+// getCredential returns the anonymous credential of the client, obtained
+// from the TCA the first time
+func (client *clientImpl) getCredential() (*idemix.IssuerPublicKey, *idemix.Credential, error) {
+	client.credentialMutex.Lock()
+	defer client.credentialMutex.Unlock()
+
+	if client.credential != nil {
+		return client.issuerKey, client.credential, nil
+	}
+
+	ipk, err := client.loadIssuerKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	alias := client.conf.getCredentialFilename()
+	var cred *idemix.Credential
+	if client.ks.isAliasSet(alias) {
+		raw, err := client.ks.loadKey(alias)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cred, err = idemix.ParseCredential(raw); err != nil {
+			return nil, nil, err
+		}
+		// A credential of a former key of the TCA is requested again
+		if err = cred.Verify(ipk); err != nil {
+			client.Warningf("Discarding the stored credential [%s].", err.Error())
+			cred = nil
+		}
+	}
+	if cred == nil {
+		if cred, err = client.requestCredential(ipk); err != nil {
+			client.Errorf("Failed requesting a credential from the TCA [%s].", err.Error())
+
+			return nil, nil, err
+		}
+		raw, err := cred.Bytes()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = client.ks.storeKey(alias, raw); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	client.issuerKey, client.credential = ipk, cred
+	return ipk, cred, nil
+}
+
+// requestCredential requests a credential on a new secret of the client
+// from the TCA
+func (client *clientImpl) requestCredential(ipk *idemix.IssuerPublicKey) (*idemix.Credential, error) {
+	sock, tcaP, err := client.getTCAClient()
+	if err != nil {
+		return nil, err
+	}
+	defer sock.Close()
+
+	req := &membersrvc.CredentialCreateReq{
+		Ts: &google_protobuf.Timestamp{Seconds: time.Now().Unix()},
+		Id: &membersrvc.Identity{Id: client.enrollID},
+	}
+	// The proof of the request is bound to its timestamp and identity
+	nonce, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	credReq, blinding, err := idemix.NewCredentialRequest(ipk, nonce, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if req.Request, err = credReq.Bytes(); err != nil {
+		return nil, err
+	}
+
+	rawReq, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Sig, err = client.signRequestWithEnrollmentKey(rawReq); err != nil {
+		return nil, err
+	}
+
+	resp, err := tcaP.CreateCredential(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := idemix.ParseIssuedSignature(resp.Signature)
+	if err != nil {
+		return nil, err
+	}
+	return idemix.NewCredential(ipk, blinding, sig, resp.Values)
+}
+
+// signWithCredential signs tx with a presentation of the anonymous
+// credential of the client, disclosing the attributes named in attributes
+func (client *clientImpl) signWithCredential(tx *obc.Transaction, attributes []string) (*obc.Transaction, error) {
+	ipk, cred, err := client.getCredential()
+	if err != nil {
+		return nil, err
+	}
+
+	rawTx, err := proto.Marshal(tx)
+	if err != nil {
+		client.Errorf("Failed marshaling tx [%s].", err.Error())
+		return nil, err
+	}
+	presentation, err := cred.Present(ipk, attributes, rawTx, rand.Reader)
+	if err != nil {
+		client.Errorf("Failed presenting the credential [%s].", err.Error())
+		return nil, err
+	}
+	if tx.Credential, err = presentation.Bytes(); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (client *clientImpl) newChaincodeDeployUsingCredential(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, attributes []string) (*obc.Transaction, error) {
+	tx, err := client.createDeployTx(chaincodeDeploymentSpec, uuid, nil, nil, attributes...)
+	if err != nil {
+		client.Errorf("Failed creating new deploy transaction [%s].", err.Error())
+		return nil, err
+	}
+
+	return client.signWithCredential(tx, attributes)
+}
+
+func (client *clientImpl) newChaincodeExecuteUsingCredential(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, attributes []string) (*obc.Transaction, error) {
+	tx, err := client.createExecuteTx(chaincodeInvocation, uuid, nil, nil, attributes...)
+	if err != nil {
+		client.Errorf("Failed creating new execute transaction [%s].", err.Error())
+		return nil, err
+	}
+
+	return client.signWithCredential(tx, attributes)
+}
+
+func (client *clientImpl) newChaincodeQueryUsingCredential(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, attributes []string) (*obc.Transaction, error) {
+	tx, err := client.createQueryTx(chaincodeInvocation, uuid, nil, nil, attributes...)
+	if err != nil {
+		client.Errorf("Failed creating new query transaction [%s].", err.Error())
+		return nil, err
+	}
+
+	return client.signWithCredential(tx, attributes)
+}
@@ -18,7 +18,9 @@ package crypto
 
 import (
 	"errors"
+	"sync"
 
+	"github.com/hyperledger/fabric/core/crypto/idemix"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/utils"
 	obc "github.com/hyperledger/fabric/protos"
@@ -34,6 +36,11 @@ type clientImpl struct {
 	// TCA KDFKey
 	tCertOwnerKDFKey []byte
 	tCertPool        tCertPool
+
+	// Anonymous credential, obtained on first use
+	credentialMutex sync.Mutex
+	issuerKey       *idemix.IssuerPublicKey
+	credential      *idemix.Credential
 }
 
 // NewChaincodeDeployTransaction is used to deploy chaincode.
@@ -43,6 +50,10 @@ func (client *clientImpl) NewChaincodeDeployTransaction(chaincodeDeploymentSpec
 		return nil, utils.ErrNotInitialized
 	}
 
+	if client.conf.isCredentialsEnabled() {
+		return client.newChaincodeDeployUsingCredential(chaincodeDeploymentSpec, uuid, attributes)
+	}
+
 	// Get next available (not yet used) transaction certificate
 	tCerts, err := client.tCertPool.GetNextTCerts(1, attributes...)
 	if err != nil {
@@ -83,6 +94,26 @@ func (client *clientImpl) GetNextTCerts(nCerts int, attributes ...string) (tCert
 	return tCerts, nil
 }
 
+// GetTCertPoolStats returns the counters of the pool of TCerts.
+func (client *clientImpl) GetTCertPoolStats() TCertPoolStats {
+	if client.tCertPool == nil {
+		return TCertPoolStats{}
+	}
+	return client.tCertPool.Stats()
+}
+
+// RefreshTCerts discards the TCerts kept for attributes, so that the next
+// TCerts carry the attributes the client holds at the ACA by then.
+func (client *clientImpl) RefreshTCerts(attributes ...string) error {
+	// Verify that the client is initialized
+	if !client.IsInitialized() {
+		return utils.ErrNotInitialized
+	}
+
+	client.tCertPool.Discard(attributes...)
+	return nil
+}
+
 // NewChaincodeInvokeTransaction is used to invoke chaincode's functions.
 func (client *clientImpl) NewChaincodeExecute(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, attributes ...string) (*obc.Transaction, error) {
 	// Verify that the client is initialized
@@ -90,6 +121,10 @@ func (client *clientImpl) NewChaincodeExecute(chaincodeInvocation *obc.Chaincode
 		return nil, utils.ErrNotInitialized
 	}
 
+	if client.conf.isCredentialsEnabled() {
+		return client.newChaincodeExecuteUsingCredential(chaincodeInvocation, uuid, attributes)
+	}
+
 	// Get next available (not yet used) transaction certificate
 	tBlocks, err := client.tCertPool.GetNextTCerts(1, attributes...)
 	if err != nil {
@@ -113,6 +148,10 @@ func (client *clientImpl) NewChaincodeQuery(chaincodeInvocation *obc.ChaincodeIn
 		return nil, utils.ErrNotInitialized
 	}
 
+	if client.conf.isCredentialsEnabled() {
+		return client.newChaincodeQueryUsingCredential(chaincodeInvocation, uuid, attributes)
+	}
+
 	// Get next available (not yet used) transaction certificate
 	tBlocks, err := client.tCertPool.GetNextTCerts(1, attributes...)
 	if err != nil {
@@ -203,6 +242,19 @@ func (client *clientImpl) GetTCertificateHandlerFromDER(tCertDER []byte) (Certif
 	return handler, nil
 }
 
+// RenewEnrollment replaces the enrollment certificate and key by the ones
+// stored in the keystore. The TCerts derived from the previous enrollment
+// key are dropped.
+func (client *clientImpl) RenewEnrollment() error {
+	return client.renewTCertEngine(client.nodeImpl.RenewEnrollment)
+}
+
+// ReEnroll rotates the enrollment key. The TCerts derived from the previous
+// enrollment key are dropped.
+func (client *clientImpl) ReEnroll() error {
+	return client.renewTCertEngine(client.nodeImpl.ReEnroll)
+}
+
 func (client *clientImpl) register(id string, pwd []byte, enrollID, enrollPWD string) (err error) {
 
 	clentRegFunc := func(eType NodeType, name string, pwd []byte, enrollID, enrollPWD string) error {
@@ -0,0 +1,172 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+)
+
+var (
+	// PKCS12EnrollmentID is the type of the PKCS#12 secret carrying the
+	// enrollment ID of a client
+	PKCS12EnrollmentID = asn1.ObjectIdentifier{2, 1, 3, 4, 5, 6, 8}
+
+	// PKCS12EnrollmentChainKey is the type of the PKCS#12 secret carrying
+	// the enrollment chain key of a client
+	PKCS12EnrollmentChainKey = asn1.ObjectIdentifier{2, 1, 3, 4, 5, 6, 9}
+)
+
+// ExportPKCS12 returns the enrollment key, enrollment certificate and ECA
+// certificates chain of this client as a PKCS#12 bundle protected by
+// password. The bundle also carries the enrollment ID and the enrollment
+// chain key of the client, to import it on another machine.
+func (client *clientImpl) ExportPKCS12(password string) ([]byte, error) {
+	// Verify that the client is initialized
+	if !client.IsInitialized() {
+		return nil, utils.ErrNotInitialized
+	}
+
+	raw, err := client.ks.loadCert(client.conf.getECACertsChainFilename())
+	if err != nil {
+		return nil, err
+	}
+	var chain [][]byte
+	for {
+		var block *pem.Block
+		if block, raw = pem.Decode(raw); block == nil {
+			break
+		}
+		chain = append(chain, block.Bytes)
+	}
+
+	chainKey, err := x509.MarshalPKIXPublicKey(client.enrollChainKey)
+	if err != nil {
+		client.Errorf("Failed marshalling enrollment chain key: [%s]", err)
+
+		return nil, err
+	}
+
+	client.enrollLock.RLock()
+	bundle := &primitives.PKCS12Bundle{
+		PrivateKey:  client.enrollmentKey(),
+		Certificate: client.enrollCert.Raw,
+		CACerts:     chain,
+		Secrets: []primitives.PKCS12Secret{
+			{Type: PKCS12EnrollmentID, Value: []byte(client.enrollID)},
+			{Type: PKCS12EnrollmentChainKey, Value: chainKey},
+		},
+	}
+	client.enrollLock.RUnlock()
+
+	return primitives.EncodePKCS12(bundle, password)
+}
+
+// importPKCS12 registers this client from the enrollment material of the
+// PKCS#12 bundle raw. The material is stored in the keystore first, so that
+// registration only retrieves what the bundle does not carry: the TCA
+// certificates chain and a TLS certificate.
+func (client *clientImpl) importPKCS12(name string, pwd []byte, raw []byte, password string) error {
+	bundle, err := primitives.DecodePKCS12(raw, password)
+	if err != nil {
+		client.Errorf("Failed decoding PKCS#12 bundle: [%s]", err)
+
+		return err
+	}
+
+	cert, err := primitives.DERToX509Certificate(bundle.Certificate)
+	if err != nil {
+		return err
+	}
+	if err := primitives.CheckCertPKAgainstSK(cert, bundle.PrivateKey); err != nil {
+		return err
+	}
+	if len(bundle.CACerts) == 0 {
+		return errors.New("The PKCS#12 bundle does not carry the ECA certificates chain.")
+	}
+
+	enrollID := cert.Subject.CommonName
+	var chainKey interface{}
+	for _, secret := range bundle.Secrets {
+		switch {
+		case secret.Type.Equal(PKCS12EnrollmentID):
+			enrollID = string(secret.Value)
+		case secret.Type.Equal(PKCS12EnrollmentChainKey):
+			if chainKey, err = x509.ParsePKIXPublicKey(secret.Value); err != nil {
+				return err
+			}
+		}
+	}
+	if chainKey == nil {
+		return errors.New("The PKCS#12 bundle does not carry the enrollment chain key.")
+	}
+
+	// Store the enrollment material
+	client.eType = NodeClient
+	if err := client.initConfiguration(name); err != nil {
+		return err
+	}
+	if err := client.nodeImpl.initKeyStore(pwd); err != nil {
+		return err
+	}
+	err = client.storeEnrollmentData(enrollID, bundle, chainKey)
+	if closeErr := client.ks.close(); err == nil {
+		err = closeErr
+	}
+	client.ks = nil
+	if err != nil {
+		return err
+	}
+
+	return client.register(name, pwd, enrollID, "")
+}
+
+func (client *clientImpl) storeEnrollmentData(enrollID string, bundle *primitives.PKCS12Bundle, chainKey interface{}) error {
+	if !client.ks.certMissing(client.conf.getEnrollmentCertFilename()) {
+		client.Errorf("Client [%s] is already enrolled.", client.conf.name)
+
+		return utils.ErrAlreadyRegistered
+	}
+
+	if err := ioutil.WriteFile(client.conf.getEnrollmentIDPath(), []byte(enrollID), 0700); err != nil {
+		client.Errorf("Failed storing enrollment id [%s]: [%s]", enrollID, err)
+		return err
+	}
+	if err := client.ks.storePrivateKey(client.conf.getEnrollmentKeyFilename(), bundle.PrivateKey); err != nil {
+		client.Errorf("Failed storing enrollment key [id=%s]: [%s]", enrollID, err)
+		return err
+	}
+	if err := client.ks.storeCert(client.conf.getEnrollmentCertFilename(), bundle.Certificate); err != nil {
+		client.Errorf("Failed storing enrollment certificate [id=%s]: [%s]", enrollID, err)
+		return err
+	}
+	if err := client.ks.storeCertsChain(client.conf.getECACertsChainFilename(), bundle.CACerts); err != nil {
+		return err
+	}
+	if err := client.ks.storePublicKey(client.conf.getEnrollmentChainKeyFilename(), chainKey); err != nil {
+		client.Errorf("Failed storing enrollment chain key [id=%s]: [%s]", enrollID, err)
+		return err
+	}
+
+	return nil
+}
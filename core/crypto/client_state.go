@@ -35,7 +35,7 @@ func (client *clientImpl) DecryptQueryResult(queryTx *obc.Transaction, ct []byte
 	var queryKey []byte
 
 	switch queryTx.ConfidentialityProtocolVersion {
-	case "1.2":
+	case "1.2", "1.3":
 		queryKey = primitives.HMACAESTruncated(client.queryStateKey, append([]byte{6}, queryTx.Nonce...))
 	}
 
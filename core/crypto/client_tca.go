@@ -41,6 +41,37 @@ func (client *clientImpl) initTCertEngine() (err error) {
 		return
 	}
 
+	return client.startTCertPool()
+}
+
+// renewTCertEngine stops the TCert pool while renew replaces the enrollment
+// key. The TCerts derived from the previous enrollment key are dropped
+// along with their TCertOwnerKDFKey, which depends on the enrollment
+// certificate.
+func (client *clientImpl) renewTCertEngine(renew func() error) error {
+	if err := client.tCertPool.Stop(); err != nil {
+		client.Errorf("Failed stopping TCertPool [%s]", err)
+
+		return err
+	}
+
+	err := renew()
+	if err == nil {
+		client.tCertOwnerKDFKey = nil
+		if client.ks.isAliasSet(client.conf.getTCertOwnerKDFKeyFilename()) {
+			if err = client.ks.deleteKey(client.conf.getTCertOwnerKDFKeyFilename()); err != nil {
+				client.Errorf("Failed deleting TCertOwnerKDFKey [%s].", err)
+			}
+		}
+	}
+
+	if startErr := client.startTCertPool(); startErr != nil {
+		return startErr
+	}
+	return err
+}
+
+func (client *clientImpl) startTCertPool() (err error) {
 	// init TCerPool
 	client.Debugf("Using multithreading [%t]", client.conf.IsMultithreadingEnabled())
 	client.Debugf("TCert batch size [%d]", client.conf.getTCertBatchSize())
@@ -147,7 +178,7 @@ func (client *clientImpl) getTCertFromExternalDER(der []byte) (tCert, error) {
 	// Decrypt ct to TCertIndex (TODO: || EnrollPub_Key || EnrollID ?)
 	TCertOwnerEncryptKey := primitives.HMACAESTruncated(client.tCertOwnerKDFKey, []byte{1})
 	ExpansionKey := primitives.HMAC(client.tCertOwnerKDFKey, []byte{2})
-	pt, err := primitives.CBCPKCS7Decrypt(TCertOwnerEncryptKey, tCertIndexCT)
+	pt, err := primitives.GetProvider().Decrypt(TCertOwnerEncryptKey, tCertIndexCT)
 
 	if err == nil {
 		// Compute ExpansionValue based on TCertIndex
@@ -280,7 +311,7 @@ func (client *clientImpl) getTCertFromDER(certBlk *TCertDBBlock) (certBlock *TCe
 	// Timestamp assigned, RandValue assigned and counter reinitialized to 1 per batch
 
 	// Decrypt ct to TCertIndex (TODO: || EnrollPub_Key || EnrollID ?)
-	pt, err := primitives.CBCPKCS7Decrypt(TCertOwnerEncryptKey, tCertIndexCT)
+	pt, err := primitives.GetProvider().Decrypt(TCertOwnerEncryptKey, tCertIndexCT)
 	if err != nil {
 		client.Errorf("Failed decrypting extension TCERT_ENC_TCERTINDEX [%s].", err.Error())
 
@@ -379,6 +410,13 @@ func (client *clientImpl) getTCertFromDER(certBlk *TCertDBBlock) (certBlock *TCe
 func (client *clientImpl) getTCertsFromTCA(attrhash string, attributes []string, num int) error {
 	client.Debugf("Get [%d] certificates from the TCA...", num)
 
+	// The keys of the TCerts are derived from the ECDSA enrollment key
+	if client.conf.isEnrollmentKeyEd25519() {
+		client.Error("TCerts cannot be derived from an Ed25519 enrollment key.")
+
+		return errors.New("TCerts cannot be derived from an Ed25519 enrollment key.")
+	}
+
 	// Contact the TCA
 	TCertOwnerKDFKey, certDERs, err := client.callTCACreateCertificateSet(num, attributes)
 	if err != nil {
@@ -445,7 +483,7 @@ func (client *clientImpl) getTCertsFromTCA(attrhash string, attributes []string,
 		// Timestamp assigned, RandValue assigned and counter reinitialized to 1 per batch
 
 		// Decrypt ct to TCertIndex (TODO: || EnrollPub_Key || EnrollID ?)
-		pt, err := primitives.CBCPKCS7Decrypt(TCertOwnerEncryptKey, tCertIndexCT)
+		pt, err := primitives.GetProvider().Decrypt(TCertOwnerEncryptKey, tCertIndexCT)
 		if err != nil {
 			client.Errorf("Failed decrypting extension TCERT_ENC_TCERTINDEX [%s].", err.Error())
 
@@ -591,17 +629,14 @@ func (client *clientImpl) callTCACreateCertificateSet(num int, attributes []stri
 	}
 
 	// 2. Sign rawReq
-	r, s, err := client.ecdsaSignWithEnrollmentKey(rawReq)
+	sig, err := client.signRequestWithEnrollmentKey(rawReq)
 	if err != nil {
 		client.Errorf("Failed creating signature for [% x]: [%s].", rawReq, err.Error())
 		return nil, nil, err
 	}
 
-	R, _ := r.MarshalText()
-	S, _ := s.MarshalText()
-
 	// 3. Append the signature
-	req.Sig = &membersrvc.Signature{Type: membersrvc.CryptoType_ECDSA, R: R, S: S}
+	req.Sig = sig
 
 	// 4. Send request
 	certSet, err := tcaP.CreateCertificateSet(context.Background(), req)
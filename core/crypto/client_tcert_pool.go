@@ -16,6 +16,57 @@ limitations under the License.
 
 package crypto
 
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/fabric/core/crypto/attributes"
+)
+
+// TCertPoolStats counts how well the TCert pool of a client keeps up with
+// the transactions of the client
+type TCertPoolStats struct {
+	// Served is the number of TCerts handed out by the pool
+	Served uint64
+	// Exhausted is the number of TCerts which were not in the pool when
+	// requested, so that the client had to wait for the TCA
+	Exhausted uint64
+	// Fetched is the number of TCerts received from the TCA
+	Fetched uint64
+	// FetchErrors is the number of failed requests to the TCA
+	FetchErrors uint64
+	// Discarded is the number of TCerts dropped from the pool, because an
+	// attribute they carry expired or the client refreshed them
+	Discarded uint64
+}
+
+// tCertPoolCounters are updated atomically by the pool implementations
+type tCertPoolCounters struct {
+	served      uint64
+	exhausted   uint64
+	fetched     uint64
+	fetchErrors uint64
+	discarded   uint64
+}
+
+func (counters *tCertPoolCounters) fetch(num int, err error) {
+	if err != nil {
+		atomic.AddUint64(&counters.fetchErrors, 1)
+		return
+	}
+	atomic.AddUint64(&counters.fetched, uint64(num))
+}
+
+func (counters *tCertPoolCounters) stats() TCertPoolStats {
+	return TCertPoolStats{
+		Served:      atomic.LoadUint64(&counters.served),
+		Exhausted:   atomic.LoadUint64(&counters.exhausted),
+		Fetched:     atomic.LoadUint64(&counters.fetched),
+		FetchErrors: atomic.LoadUint64(&counters.fetchErrors),
+		Discarded:   atomic.LoadUint64(&counters.discarded),
+	}
+}
+
 type tCertPool interface {
 	init(client *clientImpl) error
 
@@ -26,4 +77,26 @@ type tCertPool interface {
 	GetNextTCerts(nCerts int, attributes ...string) ([]*TCertBlock, error)
 
 	AddTCert(tCertBlock *TCertBlock) (err error)
+
+	// Discard drops the TCerts kept for attributes
+	Discard(attributes ...string)
+
+	Stats() TCertPoolStats
+}
+
+// hasExpiredAttributes tells whether an attribute carried by the TCert of
+// tCertBlock has expired, so that validators would refuse it
+func (client *clientImpl) hasExpiredAttributes(tCertBlock *TCertBlock) bool {
+	validity, err := attributes.ReadAttributesValidity(tCertBlock.tCert.GetCertificate(), nil)
+	if err != nil {
+		client.Warningf("Failed reading the validity of the attributes of TCert [% x]: [%s]", tCertBlock.tCert.GetCertificate().Raw, err)
+		return false
+	}
+	now := time.Now()
+	for name := range validity {
+		if attributes.CheckAttributeValidity(validity, name, now) != nil {
+			return true
+		}
+	}
+	return false
 }
@@ -21,6 +21,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -30,15 +31,19 @@ type tCertPoolEntry struct {
 	tCertChannelFeedback chan struct{}
 	done                 chan struct{}
 	client               *clientImpl
-	tCertBlock           *TCertBlock
+	counters             *tCertPoolCounters
 }
 
 //NewTCertPoolEntry creates a new tcert pool entry
-func newTCertPoolEntry(client *clientImpl, attributes []string) *tCertPoolEntry {
-	tCertChannel := make(chan *TCertBlock, client.conf.getTCertBatchSize()*2)
-	tCertChannelFeedback := make(chan struct{}, client.conf.getTCertBatchSize()*2)
-	done := make(chan struct{}, 1)
-	return &tCertPoolEntry{attributes, tCertChannel, tCertChannelFeedback, done, client, nil}
+func newTCertPoolEntry(client *clientImpl, attributes []string, counters *tCertPoolCounters) *tCertPoolEntry {
+	return &tCertPoolEntry{
+		attributes:           attributes,
+		tCertChannel:         make(chan *TCertBlock, client.conf.getTCertPoolSize()),
+		tCertChannelFeedback: make(chan struct{}, 1),
+		done:                 make(chan struct{}, 1),
+		client:               client,
+		counters:             counters,
+	}
 }
 
 //Start starts the pool entry filler loop.
@@ -82,28 +87,47 @@ func (tCertPoolEntry *tCertPoolEntry) AddTCert(tCertBlock *TCertBlock) (err erro
 
 //GetNextTCert gets the next tcert of the pool.
 func (tCertPoolEntry *tCertPoolEntry) GetNextTCert(attributes ...string) (tCertBlock *TCertBlock, err error) {
-	for i := 0; i < 3; i++ {
+	select {
+	case tCertBlock = <-tCertPoolEntry.tCertChannel:
+	default:
+	}
+
+	// Drop the TCerts with expired attributes, the filler fetches TCerts
+	// issued with the attributes currently valid
+	for tCertBlock != nil && tCertPoolEntry.client.hasExpiredAttributes(tCertBlock) {
+		tCertPoolEntry.client.Debugf("Discarding TCert [% x] with expired attributes.", tCertBlock.tCert.GetCertificate().Raw)
+		atomic.AddUint64(&tCertPoolEntry.counters.discarded, 1)
+		select {
+		case tCertBlock = <-tCertPoolEntry.tCertChannel:
+		default:
+			tCertBlock = nil
+		}
+	}
+
+	if tCertBlock == nil {
+		tCertPoolEntry.client.Warning("TCert pool exhausted. Waiting for the TCA...")
+		atomic.AddUint64(&tCertPoolEntry.counters.exhausted, 1)
+	}
+
+	for i := 0; tCertBlock == nil && i < 3; i++ {
 		tCertPoolEntry.client.Debugf("Getting next TCert... %d out of 3", i)
+		tCertPoolEntry.feedback()
 		select {
-		case tCertPoolEntry.tCertBlock = <-tCertPoolEntry.tCertChannel:
-			break
+		case tCertBlock = <-tCertPoolEntry.tCertChannel:
 		case <-time.After(30 * time.Second):
 			tCertPoolEntry.client.Error("Failed getting a new TCert. Buffer is empty!")
 		}
-		if tCertPoolEntry.tCertBlock != nil {
-			// Send feedback to the filler
-			tCertPoolEntry.client.Debug("Send feedback")
-			tCertPoolEntry.tCertChannelFeedback <- struct{}{}
-			break
-		}
 	}
 
-	if tCertPoolEntry.tCertBlock == nil {
+	if tCertBlock == nil {
 		// TODO: change error here
 		return nil, errors.New("Failed getting a new TCert. Buffer is empty!")
 	}
 
-	tCertBlock = tCertPoolEntry.tCertBlock
+	// Let the filler check the watermark
+	tCertPoolEntry.feedback()
+	atomic.AddUint64(&tCertPoolEntry.counters.served, 1)
+
 	tCertPoolEntry.client.Debugf("Cert [% x].", tCertBlock.tCert.GetCertificate().Raw)
 
 	// Store the TCert permanently
@@ -114,6 +138,61 @@ func (tCertPoolEntry *tCertPoolEntry) GetNextTCert(attributes ...string) (tCertB
 	return
 }
 
+// Discard drops the TCerts of the pool entry, and wakes up the filler to
+// fetch new ones.
+func (tCertPoolEntry *tCertPoolEntry) Discard() {
+	for {
+		select {
+		case <-tCertPoolEntry.tCertChannel:
+			atomic.AddUint64(&tCertPoolEntry.counters.discarded, 1)
+		default:
+			tCertPoolEntry.feedback()
+			return
+		}
+	}
+}
+
+// feedback wakes up the filler, unless it has already been woken up.
+func (tCertPoolEntry *tCertPoolEntry) feedback() {
+	select {
+	case tCertPoolEntry.tCertChannelFeedback <- struct{}{}:
+	default:
+	}
+}
+
+// refill fetches numTCerts TCerts from the TCA, in batches fetched by up to
+// the configured number of refillers at once.
+func (tCertPoolEntry *tCertPoolEntry) refill(numTCerts int) {
+	attributesHash := calculateAttributesHash(tCertPoolEntry.attributes)
+	batchSize := tCertPoolEntry.client.conf.getTCertBatchSize()
+	refillers := make(chan struct{}, tCertPoolEntry.client.conf.getTCertPoolRefillers())
+
+	var wg sync.WaitGroup
+	for numTCerts > 0 {
+		num := batchSize
+		if num > numTCerts {
+			num = numTCerts
+		}
+		numTCerts -= num
+
+		refillers <- struct{}{}
+		wg.Add(1)
+		go func(num int) {
+			defer func() {
+				<-refillers
+				wg.Done()
+			}()
+
+			err := tCertPoolEntry.client.getTCertsFromTCA(attributesHash, tCertPoolEntry.attributes, num)
+			tCertPoolEntry.counters.fetch(num, err)
+			if err != nil {
+				tCertPoolEntry.client.Errorf("Failed getting TCerts from the TCA: [%s]", err)
+			}
+		}(num)
+	}
+	wg.Wait()
+}
+
 func (tCertPoolEntry *tCertPoolEntry) filler() {
 	// Load unused TCerts
 	stop := false
@@ -194,26 +273,16 @@ func (tCertPoolEntry *tCertPoolEntry) filler() {
 				break
 			}
 
-			if len(tCertPoolEntry.tCertChannel) < tCertPoolEntry.client.conf.getTCertBatchSize() {
+			if len(tCertPoolEntry.tCertChannel) < tCertPoolEntry.client.conf.getTCertPoolLowWatermark() {
 				tCertPoolEntry.client.Debugf("Refill TCert Pool. Current size [%d].",
 					len(tCertPoolEntry.tCertChannel),
 				)
 
-				var numTCerts = cap(tCertPoolEntry.tCertChannel) - len(tCertPoolEntry.tCertChannel)
-				if len(tCertPoolEntry.tCertChannel) == 0 {
-					numTCerts = cap(tCertPoolEntry.tCertChannel) / 10
-					if numTCerts < 1 {
-						numTCerts = 1
-					}
-				}
+				numTCerts := cap(tCertPoolEntry.tCertChannel) - len(tCertPoolEntry.tCertChannel)
 
 				tCertPoolEntry.client.Infof("Refilling [%d] TCerts.", numTCerts)
 
-				err := tCertPoolEntry.client.getTCertsFromTCA(calculateAttributesHash(tCertPoolEntry.attributes), tCertPoolEntry.attributes, numTCerts)
-				if err != nil {
-					tCertPoolEntry.client.Errorf("Failed getting TCerts from the TCA: [%s]", err)
-					break
-				}
+				tCertPoolEntry.refill(numTCerts)
 			}
 		}
 	}
@@ -221,12 +290,14 @@ func (tCertPoolEntry *tCertPoolEntry) filler() {
 	tCertPoolEntry.client.Debug("TCert filler stopped.")
 }
 
-// The Multi-threaded tCertPool is currently not used.
-// It plays only a role in testing.
+// The Multi-threaded tCertPool is used when multithreading is enabled.
+// Each set of attributes gets an entry whose filler refills it in the
+// background once it falls below the low watermark.
 type tCertPoolMultithreadingImpl struct {
 	client       *clientImpl
 	poolEntries  map[string]*tCertPoolEntry
 	entriesMutex *sync.Mutex
+	counters     tCertPoolCounters
 }
 
 //Start starts the pool processing.
@@ -282,7 +353,7 @@ func (tCertPool *tCertPoolMultithreadingImpl) getPoolEntry(attributes []string)
 	if poolEntry == nil {
 		tCertPool.client.Debugf("New pool entry %v \n", attributes)
 
-		poolEntry = newTCertPoolEntry(tCertPool.client, attributes)
+		poolEntry = newTCertPoolEntry(tCertPool.client, attributes, &tCertPool.counters)
 		tCertPool.poolEntries[attributeHash] = poolEntry
 		if err := poolEntry.Start(); err != nil {
 			return nil, err
@@ -327,6 +398,18 @@ func (tCertPool *tCertPoolMultithreadingImpl) AddTCert(tCertBlock *TCertBlock) (
 	return
 }
 
+//Discard drops the TCerts kept for the passed attributes.
+func (tCertPool *tCertPoolMultithreadingImpl) Discard(attributes ...string) {
+	if poolEntry := tCertPool.getPoolEntryFromHash(calculateAttributesHash(attributes)); poolEntry != nil {
+		poolEntry.Discard()
+	}
+}
+
+//Stats returns the counters of the pool.
+func (tCertPool *tCertPoolMultithreadingImpl) Stats() TCertPoolStats {
+	return tCertPool.counters.stats()
+}
+
 func (tCertPool *tCertPoolMultithreadingImpl) init(client *clientImpl) (err error) {
 	tCertPool.client = client
 	tCertPool.poolEntries = make(map[string]*tCertPoolEntry)
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 )
@@ -48,6 +49,8 @@ type tCertPoolSingleThreadImpl struct {
 	tCerts map[string][]*TCertBlock
 
 	m sync.Mutex
+
+	counters tCertPoolCounters
 }
 
 //Start starts the pool processing.
@@ -142,18 +145,33 @@ func (tCertPool *tCertPoolSingleThreadImpl) getNextTCert(attributes ...string) (
 
 	attributesHash := calculateAttributesHash(attributes)
 
-	poolLen := tCertPool.length[attributesHash]
-
-	if poolLen <= 0 {
-		// Reload
-		if err := tCertPool.client.getTCertsFromTCA(attributesHash, attributes, tCertPool.client.conf.getTCertBatchSize()); err != nil {
-			return nil, fmt.Errorf("Failed loading TCerts from TCA")
+	for {
+		reloaded := false
+		if tCertPool.length[attributesHash] <= 0 {
+			// Reload
+			atomic.AddUint64(&tCertPool.counters.exhausted, 1)
+			batchSize := tCertPool.client.conf.getTCertBatchSize()
+			err := tCertPool.client.getTCertsFromTCA(attributesHash, attributes, batchSize)
+			tCertPool.counters.fetch(batchSize, err)
+			if err != nil {
+				return nil, fmt.Errorf("Failed loading TCerts from TCA")
+			}
+			reloaded = true
 		}
-	}
 
-	tCert = tCertPool.tCerts[attributesHash][tCertPool.length[attributesHash]-1]
+		tCert = tCertPool.tCerts[attributesHash][tCertPool.length[attributesHash]-1]
 
-	tCertPool.length[attributesHash] = tCertPool.length[attributesHash] - 1
+		tCertPool.length[attributesHash] = tCertPool.length[attributesHash] - 1
+
+		// Drop the TCerts with expired attributes, but serve those just
+		// issued by the TCA, which left out expired attributes
+		if reloaded || !tCertPool.client.hasExpiredAttributes(tCert) {
+			break
+		}
+		tCertPool.client.Debugf("Discarding TCert [% x] with expired attributes.", tCert.tCert.GetCertificate().Raw)
+		atomic.AddUint64(&tCertPool.counters.discarded, 1)
+	}
+	atomic.AddUint64(&tCertPool.counters.served, 1)
 
 	return tCert, nil
 }
@@ -180,6 +198,23 @@ func (tCertPool *tCertPoolSingleThreadImpl) AddTCert(tCertBlock *TCertBlock) (er
 	return nil
 }
 
+//Discard drops the TCerts kept for the passed attributes.
+func (tCertPool *tCertPoolSingleThreadImpl) Discard(attributes ...string) {
+	tCertPool.m.Lock()
+	defer tCertPool.m.Unlock()
+
+	attributesHash := calculateAttributesHash(attributes)
+	if tCertPool.length[attributesHash] > 0 {
+		atomic.AddUint64(&tCertPool.counters.discarded, uint64(tCertPool.length[attributesHash]))
+	}
+	tCertPool.length[attributesHash] = 0
+}
+
+//Stats returns the counters of the pool.
+func (tCertPool *tCertPoolSingleThreadImpl) Stats() TCertPoolStats {
+	return tCertPool.counters.stats()
+}
+
 func (tCertPool *tCertPoolSingleThreadImpl) init(client *clientImpl) (err error) {
 	tCertPool.client = client
 	tCertPool.client.Debug("Init TCert Pool...")
@@ -0,0 +1,84 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/crypto/attributes"
+)
+
+func TestTCertPoolCounters(t *testing.T) {
+	var counters tCertPoolCounters
+
+	counters.fetch(10, nil)
+	counters.fetch(10, errors.New("TCA unavailable"))
+	counters.fetch(5, nil)
+	counters.served = 12
+	counters.exhausted = 1
+
+	stats := counters.stats()
+	if stats.Fetched != 15 || stats.FetchErrors != 1 {
+		t.Fatalf("Expected 15 TCerts fetched and 1 error, got %d and %d", stats.Fetched, stats.FetchErrors)
+	}
+	if stats.Served != 12 || stats.Exhausted != 1 {
+		t.Fatalf("Expected 12 TCerts served and 1 exhaustion, got %d and %d", stats.Served, stats.Exhausted)
+	}
+}
+
+func newTCertBlockExpiringAt(t *testing.T, attributesHash string, validTo time.Time) *TCertBlock {
+	validity, err := attributes.BuildAttributesValidity(map[string]time.Time{"position": validTo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &x509.Certificate{Extensions: []pkix.Extension{{Id: attributes.TCertAttributesValidity, Value: validity}}}
+	return &TCertBlock{tCert: &tCertImpl{cert: cert}, attributesHash: attributesHash}
+}
+
+func TestTCertPoolDiscardsExpiredAttributes(t *testing.T) {
+	client := &clientImpl{nodeImpl: &nodeImpl{conf: &configuration{tCertBatchSize: 10}}}
+	pool := &tCertPoolSingleThreadImpl{}
+	pool.init(client)
+
+	attributesHash := calculateAttributesHash([]string{"position"})
+	valid := newTCertBlockExpiringAt(t, attributesHash, time.Now().Add(time.Hour))
+	pool.AddTCert(valid)
+	pool.AddTCert(newTCertBlockExpiringAt(t, attributesHash, time.Now().Add(-time.Second)))
+
+	blocks, err := pool.GetNextTCerts(1, "position")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks[0] != valid {
+		t.Fatal("Expected the TCert with expired attributes to be skipped")
+	}
+	if stats := pool.Stats(); stats.Discarded != 1 || stats.Served != 1 {
+		t.Fatalf("Expected 1 TCert discarded and 1 served, got %d and %d", stats.Discarded, stats.Served)
+	}
+
+	// Refreshing drops the remaining TCerts
+	pool.AddTCert(newTCertBlockExpiringAt(t, attributesHash, time.Now().Add(time.Hour)))
+	pool.AddTCert(newTCertBlockExpiringAt(t, attributesHash, time.Now().Add(time.Hour)))
+	pool.Discard("position")
+	if pool.length[attributesHash] != 0 || pool.Stats().Discarded != 3 {
+		t.Fatalf("Expected the pool to be emptied, %d TCerts left", pool.length[attributesHash])
+	}
+}
@@ -36,6 +36,16 @@ func (client *clientImpl) createTransactionNonce() ([]byte, error) {
 }
 
 func (client *clientImpl) createDeployTx(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, nonce []byte, tCert tCert, attrs ...string) (*obc.Transaction, error) {
+	// Encrypt the arguments designated for an audience
+	spec, err := client.encryptArguments(chaincodeDeploymentSpec.ChaincodeSpec, uuid)
+	if err != nil {
+		client.Errorf("Failed encrypting arguments [%s].", err.Error())
+		return nil, err
+	}
+	cds := *chaincodeDeploymentSpec
+	cds.ChaincodeSpec = spec
+	chaincodeDeploymentSpec = &cds
+
 	// Create a new transaction
 	tx, err := obc.NewChaincodeDeployTransaction(chaincodeDeploymentSpec, uuid)
 	if err != nil {
@@ -99,6 +109,16 @@ func getMetadata(chaincodeSpec *obc.ChaincodeSpec, tCert tCert, attrs ...string)
 }
 
 func (client *clientImpl) createExecuteTx(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte, tCert tCert, attrs ...string) (*obc.Transaction, error) {
+	// Encrypt the arguments designated for an audience
+	spec, err := client.encryptArguments(chaincodeInvocation.ChaincodeSpec, uuid)
+	if err != nil {
+		client.Errorf("Failed encrypting arguments [%s].", err.Error())
+		return nil, err
+	}
+	cis := *chaincodeInvocation
+	cis.ChaincodeSpec = spec
+	chaincodeInvocation = &cis
+
 	/// Create a new transaction
 	tx, err := obc.NewChaincodeExecute(chaincodeInvocation, uuid, obc.Transaction_CHAINCODE_INVOKE)
 	if err != nil {
@@ -144,6 +164,16 @@ func (client *clientImpl) createExecuteTx(chaincodeInvocation *obc.ChaincodeInvo
 }
 
 func (client *clientImpl) createQueryTx(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte, tCert tCert, attrs ...string) (*obc.Transaction, error) {
+	// Encrypt the arguments designated for an audience
+	spec, err := client.encryptArguments(chaincodeInvocation.ChaincodeSpec, uuid)
+	if err != nil {
+		client.Errorf("Failed encrypting arguments [%s].", err.Error())
+		return nil, err
+	}
+	cis := *chaincodeInvocation
+	cis.ChaincodeSpec = spec
+	chaincodeInvocation = &cis
+
 	// Create a new transaction
 	tx, err := obc.NewChaincodeExecute(chaincodeInvocation, uuid, obc.Transaction_CHAINCODE_QUERY)
 	if err != nil {
@@ -444,15 +474,15 @@ func (client *clientImpl) checkTransaction(tx *obc.Transaction) error {
 			}
 		}
 
-		// 2. Marshall tx without signature
-		signature := tx.Signature
-		tx.Signature = nil
+		// 2. Marshall tx without signature nor trace context
+		signature, traceContext := tx.Signature, tx.TraceContext
+		tx.Signature, tx.TraceContext = nil, ""
 		rawTx, err := proto.Marshal(tx)
 		if err != nil {
 			client.Errorf("Failed marshaling tx [%s].", err.Error())
 			return err
 		}
-		tx.Signature = signature
+		tx.Signature, tx.TraceContext = signature, traceContext
 
 		// 3. Verify signature
 		ver, err := client.verify(cert.PublicKey, rawTx, tx.Signature)
@@ -17,6 +17,9 @@ limitations under the License.
 package crypto
 
 import (
+	"io"
+	"time"
+
 	obc "github.com/hyperledger/fabric/protos"
 )
 
@@ -71,6 +74,31 @@ type Client interface {
 
 	// GetNextTCert returns a slice of a requested number of (not yet used) transaction certificates
 	GetNextTCerts(nCerts int, attributes ...string) ([]tCert, error)
+
+	// GetTCertPoolStats returns the counters of the pool of TCerts
+	GetTCertPoolStats() TCertPoolStats
+
+	// RefreshTCerts discards the TCerts kept for attributes, e.g. after an
+	// attribute of the client was granted, changed or expired, so that the
+	// next TCerts carry the attributes the client holds at the ACA
+	RefreshTCerts(attributes ...string) error
+
+	// ExportChaincodeKey returns the key of a confidential chaincode deployed
+	// with confidentiality protocol 1.3, to hand it to the clients
+	// authorized to invoke and query the chaincode
+	ExportChaincodeKey(name string) ([]byte, error)
+
+	// ImportChaincodeKey registers the key of a confidential chaincode
+	// exported by its deployer
+	ImportChaincodeKey(name string, key []byte) error
+
+	// DecryptArguments returns the arguments of a chaincode transaction,
+	// with the arguments encrypted for this client decrypted
+	DecryptArguments(tx *obc.Transaction) ([]string, error)
+
+	// ExportPKCS12 returns the enrollment material of this client as a
+	// PKCS#12 bundle protected by password, to import it with ImportClient
+	ExportPKCS12(password string) ([]byte, error)
 }
 
 // Peer is an entity able to verify transactions
@@ -88,6 +116,11 @@ type Peer interface {
 	// prescriptions (i.e. signature verification).
 	TransactionPreValidation(tx *obc.Transaction) (*obc.Transaction, error)
 
+	// TransactionsPreValidation verifies the transactions of a batch as
+	// TransactionPreValidation does, in parallel, and returns the error
+	// of each transaction, nil if it is well formed.
+	TransactionsPreValidation(txs []*obc.Transaction) []error
+
 	// TransactionPreExecution verifies that the transaction is
 	// well formed with the respect to the security layer
 	// prescriptions (i.e. signature verification). If this is the case,
@@ -112,6 +145,36 @@ type Peer interface {
 	GetTransactionBinding(tx *obc.Transaction) ([]byte, error)
 }
 
+// EnrollmentRenewer is implemented by peers and validators whose enrollment
+// certificate can be replaced while they are running
+type EnrollmentRenewer interface {
+
+	// RenewEnrollment replaces the enrollment certificate and key by the ones
+	// stored in the keystore, which must have been issued by the ECA to the
+	// same enrollment ID. The ID returned by GetID changes with the certificate.
+	RenewEnrollment() error
+
+	// ReEnroll rotates the enrollment key: the ECA issues a certificate for a
+	// new key, which replaces the current key and certificate in the keystore
+	// and in use. The ID returned by GetID changes with the certificate.
+	ReEnroll() error
+
+	// GetEnrollmentCertificateExpiry returns when the enrollment certificate
+	// in use expires.
+	GetEnrollmentCertificateExpiry() time.Time
+}
+
+// TLSProvisioner is implemented by peers and validators which obtain the
+// certificate of their TLS servers and clients from the TLSCA
+type TLSProvisioner interface {
+
+	// ProvisionTLSCertificate requests from the TLSCA a certificate for the
+	// TLS servers and clients of hosts, host names or IP addresses, with a
+	// request signed with the enrollment key. It returns the PEM encoded
+	// certificate, its key and the TLSCA certificates chain.
+	ProvisionTLSCertificate(hosts []string) (cert, key, chain []byte, err error)
+}
+
 // StateEncryptor is used to encrypt chaincode's state
 type StateEncryptor interface {
 
@@ -123,6 +186,21 @@ type StateEncryptor interface {
 	Decrypt(ct []byte) ([]byte, error)
 }
 
+// StreamStateEncryptor is a StateEncryptor able to encrypt values too large
+// to be held in memory
+type StreamStateEncryptor interface {
+	StateEncryptor
+
+	// EncryptStream returns a writer encrypting what is written to it,
+	// and writing the ciphertext to w. The ciphertext is complete once the
+	// writer is closed.
+	EncryptStream(w io.Writer) (io.WriteCloser, error)
+
+	// DecryptStream returns a reader of the plaintext of the ciphertext
+	// read from r, obtained from a call of the EncryptStream method.
+	DecryptStream(r io.Reader) (io.Reader, error)
+}
+
 // CertificateHandler exposes methods to deal with an ECert/TCert
 type CertificateHandler interface {
 
@@ -17,6 +17,9 @@ limitations under the License.
 package crypto
 
 import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/crypto/pkcs11"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
@@ -53,5 +56,62 @@ func Init() (err error) {
 		return
 	}
 
+	// Init the crypto provider, keeping the keys on a PKCS#11 token if one
+	// is configured
+	provider := primitives.SoftwareProviderName
+	if viper.GetString("security.pkcs11.library") != "" {
+		provider = pkcs11.ProviderName
+	}
+	if viper.IsSet("security.provider") {
+		ovveride := viper.GetString("security.provider")
+		if ovveride != "" {
+			provider = ovveride
+		}
+	}
+
+	// In FIPS mode, only the providers of FIPS-approved algorithms may be
+	// used. The PKCS#11 token is expected to be FIPS-validated.
+	fips := viper.GetBool("security.fips")
+	if fips && provider != primitives.SoftwareProviderName && provider != pkcs11.ProviderName {
+		err = fmt.Errorf("Crypto provider [%s] not supported in FIPS mode", provider)
+		log.Errorf("Failed initializing crypto provider: [%s]", err)
+
+		return
+	}
+	primitives.SetFIPSMode(fips)
+
+	log.Debugf("Using crypto provider [%s]", provider)
+	if err = primitives.InitProvider(provider, "security"); err != nil {
+		log.Errorf("Failed initializing crypto provider: [%s]", err)
+
+		return
+	}
+
+	// Run the known-answer tests of the primitives before using them,
+	// which FIPS mode requires
+	if fips || viper.GetBool("security.selfTest") {
+		if err = primitives.SelfTest(); err != nil {
+			log.Errorf("Failed self-test of the crypto layer: [%s]", err)
+
+			return
+		}
+		log.Info("Self-test of the crypto layer passed")
+	}
+
 	return
 }
+
+// SetChainSecurityLevel makes the crypto layer work with the hash algorithm
+// and at the security level of the chain, which take precedence over
+// security.hashAlgorithm and security.level. It must be called before any
+// node is initialized.
+func SetChainSecurityLevel(hashAlgorithm string, securityLevel int) error {
+	if err := primitives.CheckSecurityLevel(hashAlgorithm, securityLevel); err != nil {
+		return err
+	}
+	if hashAlgorithm != primitives.GetHashAlgorithm() || securityLevel != primitives.GetSecurityLevel() {
+		log.Warningf("Working with %s at security level [%d] as the chain requires, instead of %s at [%d] as configured",
+			hashAlgorithm, securityLevel, primitives.GetHashAlgorithm(), primitives.GetSecurityLevel())
+	}
+	return primitives.SetSecurityLevel(hashAlgorithm, securityLevel)
+}
@@ -19,6 +19,7 @@ package crypto
 import (
 	"testing"
 
+	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 )
@@ -40,6 +41,29 @@ func TestCryptoInitDoesntOverrideLoggingLevel(t *testing.T) {
 	assertCryptoLoggingLevel(t, logging.WARNING)
 }
 
+func TestCryptoInitFIPSMode(t *testing.T) {
+	defer primitives.SetFIPSMode(false)
+	defer viper.Set("security.fips", false)
+	defer viper.Set("security.provider", "")
+	viper.Set("security.fips", true)
+
+	viper.Set("security.provider", "other")
+	primitives.RegisterProvider("other", func(prefix string) (primitives.Provider, error) {
+		return primitives.NewSoftwareProvider(), nil
+	})
+	if err := Init(); err == nil {
+		t.Fatal("Expected providers other than SW and PKCS11 to be refused in FIPS mode")
+	}
+
+	viper.Set("security.provider", primitives.SoftwareProviderName)
+	if err := Init(); err != nil {
+		t.Fatalf("Failed initializing the crypto layer in FIPS mode [%s]", err)
+	}
+	if !primitives.IsFIPSMode() {
+		t.Fatal("Expected the crypto layer to be in FIPS mode")
+	}
+}
+
 func assertCryptoLoggingLevel(t *testing.T, expected logging.Level) {
 	actual := logging.GetLevel("crypto")
 
@@ -34,6 +34,7 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/attributes"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/utils"
@@ -720,6 +721,46 @@ func TestClientGetEnrollmentCertHandlerSign(t *testing.T) {
 
 }
 
+func TestClientPKCS12(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	bundle, err := invoker.ExportPKCS12("secret")
+	if err != nil {
+		t.Fatalf("Failed exporting PKCS#12 bundle [%s].", err)
+	}
+	if _, err := primitives.DecodePKCS12(bundle, "wrong"); err != primitives.ErrPKCS12Password {
+		t.Fatalf("Opening the bundle with a wrong password should fail [%v].", err)
+	}
+
+	if err := ImportClient("importedUser", []byte("importedUser"), bundle, "secret"); err != nil {
+		t.Fatalf("Failed importing PKCS#12 bundle [%s].", err)
+	}
+	imported, err := InitClient("importedUser", []byte("importedUser"))
+	if err != nil {
+		t.Fatalf("Failed initializing imported client [%s].", err)
+	}
+	defer CloseClient(imported)
+
+	original := invoker.(*clientImpl).nodeImpl
+	node := imported.(*clientImpl).nodeImpl
+	if !bytes.Equal(node.enrollCert.Raw, original.enrollCert.Raw) || node.enrollID != original.enrollID {
+		t.Fatal("The imported client should have the enrollment of the exported one.")
+	}
+
+	// The imported client gets TCerts with its enrollment
+	cis := &obc.ChaincodeInvocationSpec{
+		ChaincodeSpec: &obc.ChaincodeSpec{
+			Type:                 obc.ChaincodeSpec_GOLANG,
+			ChaincodeID:          &obc.ChaincodeID{Path: "Contract001"},
+			ConfidentialityLevel: obc.ConfidentialityLevel_PUBLIC,
+		},
+	}
+	if _, err := imported.NewChaincodeExecute(cis, util.GenerateUUID()); err != nil {
+		t.Fatalf("Failed creating transaction with the imported client [%s].", err)
+	}
+}
+
 func TestPeerID(t *testing.T) {
 	initNodes()
 	defer closeNodes()
@@ -929,6 +970,45 @@ func TestPeerSignVerify(t *testing.T) {
 	}
 }
 
+func TestPeerRenewEnrollment(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	renewer := peer.(EnrollmentRenewer)
+	node := peer.(*peerImpl).nodeImpl
+	id := peer.GetID()
+
+	// The keystore holds the current certificate
+	if err := renewer.RenewEnrollment(); err != nil {
+		t.Fatalf("Failed renewing enrollment [%s].", err)
+	}
+	if !bytes.Equal(id, peer.GetID()) {
+		t.Fatalf("Renewing with the same certificate changed the id.")
+	}
+
+	// A certificate issued to another enrollment ID is refused
+	der := node.enrollCert.Raw
+	if err := node.ks.storeCert(node.conf.getEnrollmentCertFilename(), validator.(*validatorImpl).enrollCert.Raw); err != nil {
+		t.Fatalf("Failed storing certificate [%s].", err)
+	}
+	defer node.ks.storeCert(node.conf.getEnrollmentCertFilename(), der)
+	if err := renewer.RenewEnrollment(); err == nil {
+		t.Fatalf("Renewing with the certificate of another node should fail.")
+	}
+	if !bytes.Equal(id, peer.GetID()) {
+		t.Fatalf("A failed renewal changed the id.")
+	}
+
+	msg := []byte("Hello World!!!")
+	signature, err := peer.Sign(msg)
+	if err != nil {
+		t.Fatalf("TestSign: failed generating signature [%s].", err)
+	}
+	if err := peer.Verify(peer.GetID(), signature, msg); err != nil {
+		t.Fatalf("TestSign: failed validating signature [%s].", err)
+	}
+}
+
 func TestPeerVerify(t *testing.T) {
 	initNodes()
 	defer closeNodes()
@@ -1131,6 +1211,62 @@ func TestValidatorExecuteTransaction(t *testing.T) {
 	}
 }
 
+func TestValidatorTransactionsPreValidation(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	var txs []*obc.Transaction
+	for _, createTx := range executeTxCreators {
+		_, tx, err := createTx(t)
+		if err != nil {
+			t.Fatalf("Failed creating execute transaction [%s].", err)
+		}
+		// The same transaction twice shares its certificate
+		txs = append(txs, tx, tx)
+	}
+
+	tampered := proto.Clone(txs[0]).(*obc.Transaction)
+	tampered.Signature[len(tampered.Signature)-1] ^= 1
+	noCert := proto.Clone(txs[0]).(*obc.Transaction)
+	noCert.Cert = nil
+	noSignature := proto.Clone(txs[0]).(*obc.Transaction)
+	noSignature.Signature = nil
+	txs = append(txs, tampered, noCert, noSignature)
+
+	errs := validator.TransactionsPreValidation(txs)
+	if len(errs) != len(txs) {
+		t.Fatalf("Expected [%d] errors, got [%d].", len(txs), len(errs))
+	}
+	// A batch verifies as its transactions one at a time
+	for i, tx := range txs[:len(txs)-3] {
+		_, err := validator.TransactionPreValidation(tx)
+		if fmt.Sprint(err) != fmt.Sprint(errs[i]) {
+			t.Fatalf("Error of transaction [%d] differs: [%v] instead of [%v].", i, errs[i], err)
+		}
+	}
+	if errs[len(txs)-3] == nil {
+		t.Fatal("Tampered transaction must be rejected.")
+	}
+	if errs[len(txs)-2] != utils.ErrTransactionCertificate {
+		t.Fatalf("Expected [%s], got [%v].", utils.ErrTransactionCertificate, errs[len(txs)-2])
+	}
+	if errs[len(txs)-1] != utils.ErrTransactionSignature {
+		t.Fatalf("Expected [%s], got [%v].", utils.ErrTransactionSignature, errs[len(txs)-1])
+	}
+
+	// The trace context is not signed, peers set it once the transaction is
+	for i, tx := range txs[:len(txs)-3] {
+		if errs[i] != nil {
+			continue
+		}
+		traced := proto.Clone(tx).(*obc.Transaction)
+		traced.TraceContext = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		if _, err := validator.TransactionPreValidation(traced); err != nil {
+			t.Fatalf("Transaction [%d] with a trace context must be accepted [%s].", i, err)
+		}
+	}
+}
+
 func TestValidatorQueryTransaction(t *testing.T) {
 	initNodes()
 	defer closeNodes()
@@ -1348,6 +1484,197 @@ func TestValidatorStateEncryptor(t *testing.T) {
 		t.Fatal("Nil input should decrypt to nil")
 	}
 
+	// Values larger than a segment are encrypted in a stream
+	large := make([]byte, 3*primitives.StreamSegmentSize+42)
+	rand.Read(large)
+	var sCt bytes.Buffer
+	w, err := seOne.(StreamStateEncryptor).EncryptStream(&sCt)
+	if err != nil {
+		t.Fatalf("Failed encrypting state stream [%s].", err)
+	}
+	if _, err = w.Write(large); err != nil {
+		t.Fatalf("Failed encrypting state stream [%s].", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Failed encrypting state stream [%s].", err)
+	}
+	r, err := seTwo.(StreamStateEncryptor).DecryptStream(&sCt)
+	if err != nil {
+		t.Fatalf("Failed decrypting state stream [%s].", err)
+	}
+	sPt, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed decrypting state stream [%s].", err)
+	}
+	if !bytes.Equal(large, sPt) {
+		t.Fatal("Failed decrypting state stream: plaintexts differ")
+	}
+}
+
+func TestValidatorChaincodeKeys(t *testing.T) {
+	defer viper.Set("security.confidentialityProtocolVersion", viper.GetString("security.confidentialityProtocolVersion"))
+	defer viper.Set("security.confidentiality.validators.file", "")
+	viper.Set("security.confidentialityProtocolVersion", "1.3")
+
+	initNodes()
+	defer closeNodes()
+
+	// Only the validator is authorized to execute the chaincode
+	validatorsFile := filepath.Join(os.TempDir(), "validators.pem")
+	defer os.Remove(validatorsFile)
+	writeCertificates := func(node *nodeImpl) {
+		if err := ioutil.WriteFile(validatorsFile, primitives.DERCertToPEM(node.enrollCert.Raw), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCertificates(validator.(*validatorImpl).nodeImpl)
+	viper.Set("security.confidentiality.validators.file", validatorsFile)
+
+	otx, deployTx, err := createConfidentialDeployTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating deploy transaction [%s].", err)
+	}
+	if deployTx.ConfidentialityProtocolVersion != "1.3" {
+		t.Fatalf("Expected confidentiality protocol 1.3, got [%s].", deployTx.ConfidentialityProtocolVersion)
+	}
+	if deployTx, err = validator.TransactionPreExecution(deployTx); err != nil {
+		t.Fatalf("Failed pre-executing deploy transaction [%s].", err)
+	}
+	if err := isEqual(otx, deployTx); err != nil {
+		t.Fatalf("Decrypted transaction differs from the original: [%s]", err)
+	}
+
+	// The invoker needs the key of the chaincode from the deployer
+	if _, _, err := createConfidentialExecuteTransaction(t); err == nil {
+		t.Fatal("Invoking a chaincode without its key should fail.")
+	}
+	key, err := deployer.ExportChaincodeKey("Contract001")
+	if err != nil {
+		t.Fatalf("Failed exporting the chaincode key [%s].", err)
+	}
+	if err = invoker.ImportChaincodeKey("Contract001", key); err != nil {
+		t.Fatalf("Failed importing the chaincode key [%s].", err)
+	}
+
+	otx, invokeTx, err := createConfidentialExecuteTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating invoke transaction [%s].", err)
+	}
+	if invokeTx, err = validator.TransactionPreExecution(invokeTx); err != nil {
+		t.Fatalf("Failed pre-executing invoke transaction [%s].", err)
+	}
+	if err := isEqual(otx, invokeTx); err != nil {
+		t.Fatalf("Decrypted transaction differs from the original: [%s]", err)
+	}
+
+	se, err := validator.GetStateEncryptor(deployTx, invokeTx)
+	if err != nil {
+		t.Fatalf("Failed creating state encryptor [%s].", err)
+	}
+	pt := []byte("Hello World")
+	ct, err := se.Encrypt(pt)
+	if err != nil {
+		t.Fatalf("Failed encrypting state [%s].", err)
+	}
+	if out, err := se.Decrypt(ct); err != nil || !bytes.Equal(pt, out) {
+		t.Fatalf("Failed decrypting state [%v].", err)
+	}
+
+	_, queryTx, err := createConfidentialQueryTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating query transaction [%s].", err)
+	}
+	if queryTx, err = validator.TransactionPreExecution(queryTx); err != nil {
+		t.Fatalf("Failed pre-executing query transaction [%s].", err)
+	}
+	se, err = validator.GetStateEncryptor(deployTx, queryTx)
+	if err != nil {
+		t.Fatalf("Failed creating state encryptor [%s].", err)
+	}
+	if ct, err = se.Encrypt(pt); err != nil {
+		t.Fatalf("Failed encrypting query result [%s].", err)
+	}
+	if out, err := invoker.DecryptQueryResult(queryTx, ct); err != nil || !bytes.Equal(pt, out) {
+		t.Fatalf("Failed decrypting query result [%v].", err)
+	}
+
+	// A validator which is not authorized cannot open the keys of a chaincode
+	writeCertificates(peer.(*peerImpl).nodeImpl)
+	_, deployTx, err = createConfidentialDeployTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating deploy transaction [%s].", err)
+	}
+	if _, err = validator.TransactionPreExecution(deployTx); err == nil {
+		t.Fatal("An unauthorized validator should not decrypt the deploy transaction.")
+	}
+}
+
+func TestValidatorArgumentEncryption(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	invokeWithAudience := func(uuid string, certs ...[]byte) *obc.Transaction {
+		cis := &obc.ChaincodeInvocationSpec{
+			ChaincodeSpec: &obc.ChaincodeSpec{
+				Type:                 obc.ChaincodeSpec_GOLANG,
+				ChaincodeID:          &obc.ChaincodeID{Path: "Contract001"},
+				CtorMsg:              &obc.ChaincodeInput{Function: "transfer", Args: []string{"alice", "secret"}},
+				ConfidentialityLevel: obc.ConfidentialityLevel_PUBLIC,
+				ArgumentAudiences:    []*obc.ArgumentAudience{{Args: []int32{1}, Certificates: certs}},
+			},
+		}
+		tx, err := invoker.NewChaincodeExecute(cis, uuid)
+		if err != nil {
+			t.Fatalf("Failed creating invoke transaction [%s].", err)
+		}
+		return tx
+	}
+	args := func(tx *obc.Transaction) []string {
+		cis := &obc.ChaincodeInvocationSpec{}
+		if err := proto.Unmarshal(tx.Payload, cis); err != nil {
+			t.Fatal(err)
+		}
+		if len(cis.ChaincodeSpec.ArgumentAudiences) != 0 {
+			t.Fatal("The audiences should not be part of the transaction.")
+		}
+		return cis.ChaincodeSpec.CtorMsg.Args
+	}
+
+	validatorCert := validator.(*validatorImpl).nodeImpl.enrollCert.Raw
+	tx := invokeWithAudience(util.GenerateUUID(), validatorCert)
+	if out := args(tx); out[0] != "alice" || out[1] == "secret" {
+		t.Fatalf("Only the designated argument should be encrypted, got %v.", out)
+	}
+	ptx, err := validator.TransactionPreExecution(tx)
+	if err != nil {
+		t.Fatalf("Failed pre-executing transaction [%s].", err)
+	}
+	if out := args(ptx); out[1] != "secret" {
+		t.Fatalf("The validator should decrypt the argument, got %v.", out)
+	}
+
+	// The argument is not decrypted for another audience
+	tx = invokeWithAudience(util.GenerateUUID(), peer.(*peerImpl).nodeImpl.enrollCert.Raw)
+	if ptx, err = validator.TransactionPreExecution(tx); err != nil {
+		t.Fatalf("Failed pre-executing transaction [%s].", err)
+	}
+	if out := args(ptx); out[1] == "secret" {
+		t.Fatal("The argument should stay encrypted for a validator out of the audience.")
+	}
+
+	// nor for another transaction
+	tx = invokeWithAudience(util.GenerateUUID(), validatorCert)
+	tx.Uuid = util.GenerateUUID()
+	if _, err = validator.TransactionPreExecution(tx); err == nil {
+		t.Fatal("The argument should not be decrypted for another transaction.")
+	}
+
+	// Clients of the audience can read it too
+	tx = invokeWithAudience(util.GenerateUUID(), validatorCert, invoker.(*clientImpl).nodeImpl.enrollCert.Raw)
+	out, err := invoker.DecryptArguments(tx)
+	if err != nil || out[1] != "secret" {
+		t.Fatalf("The invoker should decrypt the argument [%v].", err)
+	}
 }
 
 func TestValidatorSignVerify(t *testing.T) {
@@ -1526,8 +1853,8 @@ func setup() {
 func initPKI() {
 	ca.LogInit(ioutil.Discard, os.Stdout, os.Stdout, os.Stderr, os.Stdout)
 	ca.CacheConfiguration() // Need cache the configuration first
-	aca = ca.NewACA()
 	eca = ca.NewECA()
+	aca = ca.NewACA(eca)
 	tca = ca.NewTCA(eca)
 	tlsca = ca.NewTLSCA(eca)
 }
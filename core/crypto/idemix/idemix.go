@@ -0,0 +1,341 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package idemix implements anonymous credentials in the style of Identity
+// Mixer, based on Camenisch-Lysyanskaya signatures over a special RSA
+// modulus. The issuer signs a secret of the user, which it never learns,
+// along with the values of the attributes of the user. The user then signs
+// messages with zero-knowledge proofs of possession of the credential that
+// disclose some of the attributes only. Such proofs cannot be linked to one
+// another, nor to the issuance of the credential.
+package idemix
+
+import (
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Params holds the sizes, in bits, of the values of the scheme
+type Params struct {
+	// Modulus is the size of the RSA modulus of the issuer
+	Modulus int
+	// Attribute is the size of the secret of the user and of the hashes of
+	// the values of the attributes
+	Attribute int
+	// Exponent is the size of the prime exponents of the signatures, which
+	// are chosen in an interval of ExponentInterval bits
+	Exponent         int
+	ExponentInterval int
+	// Statistical is the security parameter of the statistical zero-knowledge
+	// proofs
+	Statistical int
+	// Challenge is the size of the challenges of the proofs
+	Challenge int
+}
+
+// DefaultParams are the parameters of Identity Mixer for a 2048 bits modulus
+var DefaultParams = Params{
+	Modulus:          2048,
+	Attribute:        256,
+	Exponent:         597,
+	ExponentInterval: 120,
+	Statistical:      80,
+	Challenge:        256,
+}
+
+// blinding returns the size of the blinding values v of the signatures
+func (p *Params) blinding() int {
+	return p.Modulus + p.Exponent + 2*p.Statistical
+}
+
+func (p *Params) check() error {
+	if p.Modulus < 512 || p.Attribute < sha256.Size*8 || p.Challenge != sha256.Size*8 ||
+		p.Statistical < 1 || p.ExponentInterval < 2 ||
+		p.Exponent <= p.Statistical+p.Challenge+p.Attribute+4 || p.Exponent <= p.ExponentInterval {
+		return fmt.Errorf("Invalid parameters %+v", *p)
+	}
+	return nil
+}
+
+// IssuerPublicKey is the public key of an issuer of credentials on a
+// secret of the user and on the values of Attributes
+type IssuerPublicKey struct {
+	Params     Params
+	Attributes []string
+	N          *big.Int
+	S          *big.Int
+	Z          *big.Int
+	// R[0] is the base of the secret of the user, R[i] the base of the
+	// attribute Attributes[i-1]
+	R []*big.Int
+}
+
+// IssuerKey is the secret key of an issuer
+type IssuerKey struct {
+	IssuerPublicKey
+	// P and Q are the Sophie Germain primes of the modulus, whose product
+	// is the order of the group of quadratic residues
+	P *big.Int
+	Q *big.Int
+}
+
+// NewIssuerKey generates the key of an issuer of credentials on attributes
+func NewIssuerKey(params Params, attributes []string, rand io.Reader) (*IssuerKey, error) {
+	if err := params.check(); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, name := range attributes {
+		if name == "" || seen[name] {
+			return nil, fmt.Errorf("Invalid or duplicate attribute name [%s]", name)
+		}
+		seen[name] = true
+	}
+
+	isk := &IssuerKey{IssuerPublicKey: IssuerPublicKey{Params: params, Attributes: attributes}}
+	var p, q *big.Int
+	var err error
+	for {
+		if isk.P, p, err = safePrime(rand, params.Modulus/2); err != nil {
+			return nil, err
+		}
+		if isk.Q, q, err = safePrime(rand, params.Modulus-params.Modulus/2); err != nil {
+			return nil, err
+		}
+		if isk.P.Cmp(isk.Q) != 0 {
+			break
+		}
+	}
+	isk.N = new(big.Int).Mul(p, q)
+	order := isk.order()
+
+	// S generates the quadratic residues, the other bases are powers of S
+	for {
+		x, err := randomInt(rand, isk.N)
+		if err != nil {
+			return nil, err
+		}
+		isk.S = new(big.Int).Exp(x, big.NewInt(2), isk.N)
+		if isk.S.Cmp(big.NewInt(1)) > 0 && new(big.Int).GCD(nil, nil, isk.S, isk.N).Cmp(big.NewInt(1)) == 0 {
+			break
+		}
+	}
+	power := func() (*big.Int, error) {
+		x, err := randomInt(rand, new(big.Int).Sub(order, big.NewInt(2)))
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).Exp(isk.S, x.Add(x, big.NewInt(2)), isk.N), nil
+	}
+	if isk.Z, err = power(); err != nil {
+		return nil, err
+	}
+	isk.R = make([]*big.Int, len(attributes)+1)
+	for i := range isk.R {
+		if isk.R[i], err = power(); err != nil {
+			return nil, err
+		}
+	}
+	return isk, nil
+}
+
+// Public returns the public key of the issuer
+func (isk *IssuerKey) Public() *IssuerPublicKey {
+	return &isk.IssuerPublicKey
+}
+
+func (isk *IssuerKey) order() *big.Int {
+	return new(big.Int).Mul(isk.P, isk.Q)
+}
+
+// Bytes returns the DER encoding of the key
+func (isk *IssuerKey) Bytes() ([]byte, error) {
+	return asn1.Marshal(*isk)
+}
+
+// ParseIssuerKey parses the DER encoding of a key
+func ParseIssuerKey(raw []byte) (*IssuerKey, error) {
+	isk := &IssuerKey{}
+	if err := unmarshal(raw, isk); err != nil {
+		return nil, err
+	}
+	if err := isk.IssuerPublicKey.check(); err != nil {
+		return nil, err
+	}
+	return isk, nil
+}
+
+// Bytes returns the DER encoding of the public key
+func (ipk *IssuerPublicKey) Bytes() ([]byte, error) {
+	return asn1.Marshal(*ipk)
+}
+
+// ParseIssuerPublicKey parses the DER encoding of a public key
+func ParseIssuerPublicKey(raw []byte) (*IssuerPublicKey, error) {
+	ipk := &IssuerPublicKey{}
+	if err := unmarshal(raw, ipk); err != nil {
+		return nil, err
+	}
+	if err := ipk.check(); err != nil {
+		return nil, err
+	}
+	return ipk, nil
+}
+
+func (ipk *IssuerPublicKey) check() error {
+	if err := ipk.Params.check(); err != nil {
+		return err
+	}
+	if ipk.N == nil || ipk.N.Sign() <= 0 || len(ipk.R) != len(ipk.Attributes)+1 {
+		return errors.New("Invalid issuer public key")
+	}
+	for _, x := range append([]*big.Int{ipk.S, ipk.Z}, ipk.R...) {
+		if !ipk.isUnit(x) {
+			return errors.New("Invalid issuer public key")
+		}
+	}
+	return nil
+}
+
+// isUnit reports whether x is an invertible element modulo N
+func (ipk *IssuerPublicKey) isUnit(x *big.Int) bool {
+	return x != nil && x.Sign() > 0 && x.Cmp(ipk.N) < 0 && new(big.Int).GCD(nil, nil, x, ipk.N).Cmp(big.NewInt(1)) == 0
+}
+
+// attributeIndex returns the index of the base of the attribute name
+func (ipk *IssuerPublicKey) attributeIndex(name string) int {
+	for i, attribute := range ipk.Attributes {
+		if attribute == name {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// context returns the hash of the public key the challenges are bound to
+func (ipk *IssuerPublicKey) context() []byte {
+	raw, _ := ipk.Bytes()
+	hash := sha256.Sum256(raw)
+	return hash[:]
+}
+
+// AttributeValue returns the integer signed for the value of an attribute
+func AttributeValue(value string) *big.Int {
+	hash := sha256.Sum256([]byte(value))
+	return new(big.Int).SetBytes(hash[:])
+}
+
+// challenge hashes the values a proof commits to
+type challenge struct {
+	data []byte
+}
+
+func newChallenge(context []byte) *challenge {
+	c := &challenge{}
+	c.write(context)
+	return c
+}
+
+func (c *challenge) write(b []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(b)))
+	c.data = append(append(c.data, length[:]...), b...)
+}
+
+func (c *challenge) writeInt(x *big.Int) {
+	c.write(x.Bytes())
+}
+
+func (c *challenge) sum() *big.Int {
+	hash := sha256.Sum256(c.data)
+	return new(big.Int).SetBytes(hash[:])
+}
+
+// safePrime returns a prime p' of bits-1 bits such that p = 2p'+1, of bits
+// bits, is prime too
+func safePrime(rand io.Reader, bits int) (*big.Int, *big.Int, error) {
+	for {
+		q, err := primeOf(rand, bits-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		p := new(big.Int).Lsh(q, 1)
+		p.Add(p, big.NewInt(1))
+		if p.ProbablyPrime(20) {
+			return q, p, nil
+		}
+	}
+}
+
+func primeOf(rand io.Reader, bits int) (*big.Int, error) {
+	for {
+		x, err := randomBits(rand, bits)
+		if err != nil {
+			return nil, err
+		}
+		// The top two bits make the products of two of them of twice the bits
+		x.SetBit(x, bits-1, 1)
+		x.SetBit(x, bits-2, 1)
+		x.SetBit(x, 0, 1)
+		if x.ProbablyPrime(20) {
+			return x, nil
+		}
+	}
+}
+
+// randomBits returns a random integer of at most bits bits
+func randomBits(rand io.Reader, bits int) (*big.Int, error) {
+	return randomInt(rand, new(big.Int).Lsh(big.NewInt(1), uint(bits)))
+}
+
+// randomInt returns a random integer in [0, max)
+func randomInt(rand io.Reader, max *big.Int) (*big.Int, error) {
+	b := make([]byte, (max.BitLen()+7)/8+8)
+	if _, err := io.ReadFull(rand, b); err != nil {
+		return nil, err
+	}
+	return new(big.Int).Mod(new(big.Int).SetBytes(b), max), nil
+}
+
+// exp returns x^y mod n, y possibly negative
+func exp(x, y, n *big.Int) *big.Int {
+	if y.Sign() >= 0 {
+		return new(big.Int).Exp(x, y, n)
+	}
+	inverse := new(big.Int).ModInverse(x, n)
+	return inverse.Exp(inverse, new(big.Int).Neg(y), n)
+}
+
+// inRange reports whether |x| has at most bits bits
+func inRange(x *big.Int, bits int) bool {
+	return x != nil && x.BitLen() <= bits
+}
+
+func unmarshal(raw []byte, v interface{}) error {
+	rest, err := asn1.Unmarshal(raw, v)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("Trailing data")
+	}
+	return nil
+}
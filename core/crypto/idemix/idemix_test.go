@@ -0,0 +1,177 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idemix
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+var testParams = Params{
+	Modulus:          512,
+	Attribute:        256,
+	Exponent:         597,
+	ExponentInterval: 120,
+	Statistical:      80,
+	Challenge:        256,
+}
+
+var testIssuerKey *IssuerKey
+
+func issuerKey(t *testing.T) *IssuerKey {
+	if testIssuerKey == nil {
+		var err error
+		if testIssuerKey, err = NewIssuerKey(testParams, []string{"role", "account"}, rand.Reader); err != nil {
+			t.Fatalf("Failed generating the issuer key: %s", err)
+		}
+	}
+	return testIssuerKey
+}
+
+func issueCredential(t *testing.T, values []string) *Credential {
+	isk := issuerKey(t)
+	nonce := []byte("nonce")
+	req, blinding, err := NewCredentialRequest(isk.Public(), nonce, rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed creating the credential request: %s", err)
+	}
+	sig, err := isk.Issue(req, nonce, values, rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed issuing the credential: %s", err)
+	}
+	cred, err := NewCredential(isk.Public(), blinding, sig, values)
+	if err != nil {
+		t.Fatalf("Failed verifying the credential: %s", err)
+	}
+	return cred
+}
+
+func TestIssue(t *testing.T) {
+	isk := issuerKey(t)
+	cred := issueCredential(t, []string{"client", "12345-56789"})
+
+	cred.Values[0] = "admin"
+	if err := cred.Verify(isk.Public()); err == nil {
+		t.Fatal("Expected a credential with a changed attribute to be invalid")
+	}
+
+	req, _, err := NewCredentialRequest(isk.Public(), []byte("nonce"), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = isk.Issue(req, []byte("another nonce"), []string{"client", ""}, rand.Reader); err == nil {
+		t.Fatal("Expected a request bound to another nonce to be refused")
+	}
+	if _, err = isk.Issue(req, []byte("nonce"), []string{"client"}, rand.Reader); err == nil {
+		t.Fatal("Expected missing attribute values to be refused")
+	}
+}
+
+func TestPresent(t *testing.T) {
+	ipk := issuerKey(t).Public()
+	cred := issueCredential(t, []string{"client", "12345-56789"})
+	msg := []byte("transaction")
+
+	for _, disclose := range [][]string{nil, {"role"}, {"account"}, {"role", "account"}} {
+		p, err := cred.Present(ipk, disclose, msg, rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed presenting the credential: %s", err)
+		}
+		if err = p.Verify(ipk, msg); err != nil {
+			t.Fatalf("Expected the presentation disclosing %v to verify, got %s", disclose, err)
+		}
+		if len(p.Attributes()) != len(disclose) {
+			t.Fatalf("Expected %d disclosed attributes, got %v", len(disclose), p.Attributes())
+		}
+		if err = p.Verify(ipk, []byte("another transaction")); err == nil {
+			t.Fatal("Expected the presentation not to verify another message")
+		}
+	}
+
+	p, err := cred.Present(ipk, []string{"role"}, msg, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Attributes()["role"] != "client" {
+		t.Fatalf("Expected the role to be disclosed, got %v", p.Attributes())
+	}
+	p.Disclosed[0].Value = "admin"
+	if err = p.Verify(ipk, msg); err == nil {
+		t.Fatal("Expected a presentation disclosing a forged attribute not to verify")
+	}
+
+	if _, err = cred.Present(ipk, []string{"unknown"}, msg, rand.Reader); err == nil {
+		t.Fatal("Expected the disclosure of an unknown attribute to be refused")
+	}
+}
+
+func TestPresentationsAreUnlinkable(t *testing.T) {
+	ipk := issuerKey(t).Public()
+	cred := issueCredential(t, []string{"client", "12345-56789"})
+
+	p1, err := cred.Present(ipk, nil, []byte("msg"), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := cred.Present(ipk, nil, []byte("msg"), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p1.A.Cmp(p2.A) == 0 || p1.A.Cmp(cred.A) == 0 {
+		t.Fatal("Expected every presentation to randomize the signature")
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	isk := issuerKey(t)
+	raw, err := isk.Public().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ipk, err := ParseIssuerPublicKey(raw)
+	if err != nil {
+		t.Fatalf("Failed parsing the issuer public key: %s", err)
+	}
+	if raw, err = isk.Bytes(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseIssuerKey(raw); err != nil {
+		t.Fatalf("Failed parsing the issuer key: %s", err)
+	}
+
+	cred := issueCredential(t, []string{"client", "12345-56789"})
+	if raw, err = cred.Bytes(); err != nil {
+		t.Fatal(err)
+	}
+	if cred, err = ParseCredential(raw); err != nil {
+		t.Fatalf("Failed parsing the credential: %s", err)
+	}
+
+	p, err := cred.Present(ipk, []string{"account"}, []byte("msg"), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, err = p.Bytes(); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = ParsePresentation(raw); err != nil {
+		t.Fatalf("Failed parsing the presentation: %s", err)
+	}
+	if err = p.Verify(ipk, []byte("msg")); err != nil {
+		t.Fatalf("Expected the parsed presentation to verify, got %s", err)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idemix
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// CredentialRequest commits to a new secret of the user, with a proof of
+// knowledge of the secret that does not reveal it
+type CredentialRequest struct {
+	U *big.Int
+	C *big.Int
+	// Responses for the blinding and the secret
+	SV *big.Int
+	SM *big.Int
+}
+
+// Blinding holds the values of a request the user needs to complete the
+// signature issued for it
+type Blinding struct {
+	secret *big.Int
+	v      *big.Int
+}
+
+// IssuedSignature is the signature of the issuer on the secret committed
+// in a request and on the values of the attributes of the user
+type IssuedSignature struct {
+	A *big.Int
+	E *big.Int
+	V *big.Int
+}
+
+// NewCredentialRequest requests a credential on a new secret of the user.
+// The proof of the request is bound to nonce, which the issuer must check
+// to be fresh.
+func NewCredentialRequest(ipk *IssuerPublicKey, nonce []byte, rand io.Reader) (*CredentialRequest, *Blinding, error) {
+	params := ipk.Params
+	secret, err := randomBits(rand, params.Attribute)
+	if err != nil {
+		return nil, nil, err
+	}
+	v, err := randomBits(rand, params.Modulus+params.Statistical)
+	if err != nil {
+		return nil, nil, err
+	}
+	rv, err := randomBits(rand, params.Modulus+2*params.Statistical+params.Challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+	rm, err := randomBits(rand, params.Attribute+params.Statistical+params.Challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u := new(big.Int).Mul(exp(ipk.S, v, ipk.N), exp(ipk.R[0], secret, ipk.N))
+	u.Mod(u, ipk.N)
+	t := new(big.Int).Mul(exp(ipk.S, rv, ipk.N), exp(ipk.R[0], rm, ipk.N))
+	t.Mod(t, ipk.N)
+	c := requestChallenge(ipk, u, t, nonce)
+
+	req := &CredentialRequest{
+		U:  u,
+		C:  c,
+		SV: rv.Add(rv, new(big.Int).Mul(c, v)),
+		SM: rm.Add(rm, new(big.Int).Mul(c, secret)),
+	}
+	return req, &Blinding{secret: secret, v: v}, nil
+}
+
+func requestChallenge(ipk *IssuerPublicKey, u, t *big.Int, nonce []byte) *big.Int {
+	c := newChallenge(ipk.context())
+	c.writeInt(u)
+	c.writeInt(t)
+	c.write(nonce)
+	return c.sum()
+}
+
+// Verify checks the proof of the request bound to nonce
+func (req *CredentialRequest) Verify(ipk *IssuerPublicKey, nonce []byte) error {
+	params := ipk.Params
+	if !ipk.isUnit(req.U) || req.C == nil || req.SV == nil ||
+		!inRange(req.SM, params.Attribute+params.Statistical+params.Challenge+1) {
+		return errors.New("Malformed credential request")
+	}
+	t := new(big.Int).Mul(exp(req.U, new(big.Int).Neg(req.C), ipk.N), exp(ipk.S, req.SV, ipk.N))
+	t.Mul(t, exp(ipk.R[0], req.SM, ipk.N))
+	t.Mod(t, ipk.N)
+	if requestChallenge(ipk, req.U, t, nonce).Cmp(req.C) != 0 {
+		return errors.New("The proof of the credential request does not verify")
+	}
+	return nil
+}
+
+// Issue signs the secret committed in the request bound to nonce along
+// with values, the values of the attributes of the issuer in order
+func (isk *IssuerKey) Issue(req *CredentialRequest, nonce []byte, values []string, rand io.Reader) (*IssuedSignature, error) {
+	ipk := isk.Public()
+	if len(values) != len(ipk.Attributes) {
+		return nil, fmt.Errorf("Expected the values of %d attributes, got %d", len(ipk.Attributes), len(values))
+	}
+	if err := req.Verify(ipk, nonce); err != nil {
+		return nil, err
+	}
+
+	params := ipk.Params
+	e, err := randomExponent(params, rand)
+	if err != nil {
+		return nil, err
+	}
+	v, err := randomBits(rand, params.blinding()-1)
+	if err != nil {
+		return nil, err
+	}
+	v.SetBit(v, params.blinding()-1, 1)
+
+	// A = (Z / (U S^v R_1^m_1 ... R_l^m_l))^(1/e)
+	q := new(big.Int).Mul(req.U, exp(ipk.S, v, ipk.N))
+	for i, value := range values {
+		q.Mul(q, exp(ipk.R[i+1], AttributeValue(value), ipk.N))
+		q.Mod(q, ipk.N)
+	}
+	q.ModInverse(q, ipk.N)
+	q.Mul(q, ipk.Z)
+	d := new(big.Int).ModInverse(e, isk.order())
+	if d == nil {
+		return nil, errors.New("Failed inverting the exponent")
+	}
+	return &IssuedSignature{A: q.Exp(q, d, ipk.N), E: e, V: v}, nil
+}
+
+// randomExponent returns a prime in [2^(Exponent-1), 2^(Exponent-1) + 2^(ExponentInterval-1)]
+func randomExponent(params Params, rand io.Reader) (*big.Int, error) {
+	for {
+		e, err := randomBits(rand, params.ExponentInterval-1)
+		if err != nil {
+			return nil, err
+		}
+		e.SetBit(e, params.Exponent-1, 1)
+		if e.ProbablyPrime(20) {
+			return e, nil
+		}
+	}
+}
+
+// Credential is a signature of the issuer on a secret of the user and on
+// the values of its attributes
+type Credential struct {
+	A      *big.Int
+	E      *big.Int
+	V      *big.Int
+	Secret *big.Int
+	Values []string
+}
+
+// NewCredential completes the signature issued for the request of
+// blinding on values into a credential, which it verifies
+func NewCredential(ipk *IssuerPublicKey, blinding *Blinding, sig *IssuedSignature, values []string) (*Credential, error) {
+	cred := &Credential{
+		A:      sig.A,
+		E:      sig.E,
+		V:      new(big.Int).Add(sig.V, blinding.v),
+		Secret: blinding.secret,
+		Values: values,
+	}
+	if err := cred.Verify(ipk); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// Verify checks that the credential is a valid signature of the issuer
+func (cred *Credential) Verify(ipk *IssuerPublicKey) error {
+	params := ipk.Params
+	if len(cred.Values) != len(ipk.Attributes) || !ipk.isUnit(cred.A) || cred.E == nil || cred.V == nil ||
+		cred.E.BitLen() != params.Exponent || !cred.E.ProbablyPrime(20) || !inRange(cred.Secret, params.Attribute) {
+		return errors.New("Malformed credential")
+	}
+	// Z = A^e S^v R_0^secret R_1^m_1 ... R_l^m_l
+	z := new(big.Int).Mul(exp(cred.A, cred.E, ipk.N), exp(ipk.S, cred.V, ipk.N))
+	z.Mul(z, exp(ipk.R[0], cred.Secret, ipk.N))
+	for i, value := range cred.Values {
+		z.Mul(z, exp(ipk.R[i+1], AttributeValue(value), ipk.N))
+		z.Mod(z, ipk.N)
+	}
+	if z.Mod(z, ipk.N).Cmp(ipk.Z) != 0 {
+		return errors.New("The credential is not a valid signature of the issuer")
+	}
+	return nil
+}
+
+// Bytes returns the DER encoding of the credential
+func (cred *Credential) Bytes() ([]byte, error) {
+	return asn1.Marshal(*cred)
+}
+
+// ParseCredential parses the DER encoding of a credential
+func ParseCredential(raw []byte) (*Credential, error) {
+	cred := &Credential{}
+	if err := unmarshal(raw, cred); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// Bytes returns the DER encoding of the request
+func (req *CredentialRequest) Bytes() ([]byte, error) {
+	return asn1.Marshal(*req)
+}
+
+// ParseCredentialRequest parses the DER encoding of a request
+func ParseCredentialRequest(raw []byte) (*CredentialRequest, error) {
+	req := &CredentialRequest{}
+	if err := unmarshal(raw, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Bytes returns the DER encoding of the signature
+func (sig *IssuedSignature) Bytes() ([]byte, error) {
+	return asn1.Marshal(*sig)
+}
+
+// ParseIssuedSignature parses the DER encoding of a signature
+func ParseIssuedSignature(raw []byte) (*IssuedSignature, error) {
+	sig := &IssuedSignature{}
+	if err := unmarshal(raw, sig); err != nil {
+		return nil, err
+	}
+	if sig.A == nil || sig.E == nil || sig.V == nil {
+		return nil, errors.New("Malformed signature")
+	}
+	return sig, nil
+}
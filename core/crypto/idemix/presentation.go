@@ -0,0 +1,208 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idemix
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Attribute is an attribute disclosed by a presentation
+type Attribute struct {
+	Name  string
+	Value string
+}
+
+// Presentation is a signature on a message by the holder of a credential,
+// which proves possession of the credential and discloses some of its
+// attributes only. The presentations of a credential are unlinkable.
+type Presentation struct {
+	// A is the randomized signature of the issuer
+	A *big.Int
+	C *big.Int
+	// Responses for the exponent, the blinding and the undisclosed values,
+	// the secret of the user first, then the attributes in order
+	E         *big.Int
+	V         *big.Int
+	M         []*big.Int
+	Disclosed []Attribute
+}
+
+// Present signs msg with the credential, disclosing the attributes named
+// in disclose
+func (cred *Credential) Present(ipk *IssuerPublicKey, disclose []string, msg []byte, rand io.Reader) (*Presentation, error) {
+	params := ipk.Params
+	disclosed := make(map[int]bool)
+	for _, name := range disclose {
+		i := ipk.attributeIndex(name)
+		if i < 0 {
+			return nil, fmt.Errorf("Unknown attribute [%s]", name)
+		}
+		disclosed[i] = true
+	}
+	values := append([]*big.Int{cred.Secret}, make([]*big.Int, len(cred.Values))...)
+	for i, value := range cred.Values {
+		values[i+1] = AttributeValue(value)
+	}
+
+	// A' = A S^r, v' = v - e r and e' = e - 2^(Exponent-1)
+	r, err := randomBits(rand, params.Modulus+params.Statistical)
+	if err != nil {
+		return nil, err
+	}
+	a := new(big.Int).Mul(cred.A, exp(ipk.S, r, ipk.N))
+	a.Mod(a, ipk.N)
+	v := new(big.Int).Sub(cred.V, new(big.Int).Mul(cred.E, r))
+	e := new(big.Int).SetBit(new(big.Int).Set(cred.E), params.Exponent-1, 0)
+
+	re, err := randomBits(rand, params.ExponentInterval+params.Statistical+params.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := randomBits(rand, params.blinding()+params.Statistical+params.Challenge+1)
+	if err != nil {
+		return nil, err
+	}
+	t := new(big.Int).Mul(exp(a, re, ipk.N), exp(ipk.S, rv, ipk.N))
+	t.Mod(t, ipk.N)
+	var rm []*big.Int
+	for i := range values {
+		if disclosed[i] {
+			continue
+		}
+		r, err := randomBits(rand, params.Attribute+params.Statistical+params.Challenge)
+		if err != nil {
+			return nil, err
+		}
+		t.Mul(t, exp(ipk.R[i], r, ipk.N))
+		t.Mod(t, ipk.N)
+		rm = append(rm, r)
+	}
+
+	p := &Presentation{A: a}
+	for i, name := range ipk.Attributes {
+		if disclosed[i+1] {
+			p.Disclosed = append(p.Disclosed, Attribute{Name: name, Value: cred.Values[i]})
+		}
+	}
+	p.C = p.challenge(ipk, t, msg)
+
+	p.E = re.Add(re, new(big.Int).Mul(p.C, e))
+	p.V = rv.Add(rv, new(big.Int).Mul(p.C, v))
+	j := 0
+	for i, value := range values {
+		if disclosed[i] {
+			continue
+		}
+		p.M = append(p.M, rm[j].Add(rm[j], new(big.Int).Mul(p.C, value)))
+		j++
+	}
+	return p, nil
+}
+
+func (p *Presentation) challenge(ipk *IssuerPublicKey, t *big.Int, msg []byte) *big.Int {
+	c := newChallenge(ipk.context())
+	c.writeInt(p.A)
+	c.writeInt(t)
+	for _, attribute := range p.Disclosed {
+		c.write([]byte(attribute.Name))
+		c.write([]byte(attribute.Value))
+	}
+	c.write(msg)
+	return c.sum()
+}
+
+// Verify checks that the presentation is a signature on msg by the holder
+// of a credential of the issuer with the disclosed attributes
+func (p *Presentation) Verify(ipk *IssuerPublicKey, msg []byte) error {
+	params := ipk.Params
+	if !ipk.isUnit(p.A) || p.C == nil || p.V == nil ||
+		!inRange(p.E, params.ExponentInterval+params.Statistical+params.Challenge+1) {
+		return errors.New("Malformed presentation")
+	}
+
+	// The disclosed attributes, in the order of the issuer
+	disclosed := make(map[int]*big.Int)
+	last := 0
+	for _, attribute := range p.Disclosed {
+		i := ipk.attributeIndex(attribute.Name)
+		if i <= last {
+			return fmt.Errorf("Unknown or misplaced attribute [%s]", attribute.Name)
+		}
+		disclosed[i] = AttributeValue(attribute.Value)
+		last = i
+	}
+	if len(p.M) != len(ipk.R)-len(disclosed) {
+		return errors.New("Malformed presentation")
+	}
+
+	// T = (Z / (A'^(2^(Exponent-1)) R_i^m_i...))^-c A'^e S^v R_j^m_j..., i
+	// disclosed and j not
+	z := exp(p.A, new(big.Int).Lsh(big.NewInt(1), uint(params.Exponent-1)), ipk.N)
+	for i, value := range disclosed {
+		z.Mul(z, exp(ipk.R[i], value, ipk.N))
+		z.Mod(z, ipk.N)
+	}
+	z.ModInverse(z, ipk.N)
+	z.Mul(z, ipk.Z)
+	t := new(big.Int).Mul(exp(z, new(big.Int).Neg(p.C), ipk.N), exp(p.A, p.E, ipk.N))
+	t.Mul(t, exp(ipk.S, p.V, ipk.N))
+	j := 0
+	for i := range ipk.R {
+		if disclosed[i] != nil {
+			continue
+		}
+		if !inRange(p.M[j], params.Attribute+params.Statistical+params.Challenge+1) {
+			return errors.New("Malformed presentation")
+		}
+		t.Mul(t, exp(ipk.R[i], p.M[j], ipk.N))
+		t.Mod(t, ipk.N)
+		j++
+	}
+	t.Mod(t, ipk.N)
+
+	if p.challenge(ipk, t, msg).Cmp(p.C) != 0 {
+		return errors.New("The presentation does not verify")
+	}
+	return nil
+}
+
+// Attributes returns the disclosed attributes by name
+func (p *Presentation) Attributes() map[string]string {
+	attributes := make(map[string]string)
+	for _, attribute := range p.Disclosed {
+		attributes[attribute.Name] = attribute.Value
+	}
+	return attributes
+}
+
+// Bytes returns the DER encoding of the presentation
+func (p *Presentation) Bytes() ([]byte, error) {
+	return asn1.Marshal(*p)
+}
+
+// ParsePresentation parses the DER encoding of a presentation
+func ParsePresentation(raw []byte) (*Presentation, error) {
+	p := &Presentation{}
+	if err := unmarshal(raw, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/asn1"
+	"encoding/base64"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// encryptedArgumentPrefix marks the arguments of a chaincode invocation
+// encrypted for an audience. The rest of the argument is the base64 encoded
+// argumentEnvelope.
+const encryptedArgumentPrefix = "confidential:"
+
+// argumentEnvelope is an argument encrypted with a key which is encrypted to
+// the key of each member of the audience. The ciphertext, nonce followed by
+// AES-GCM output, is bound to the chaincode and transaction of the argument.
+type argumentEnvelope struct {
+	Keys       []chaincodeKeyEnvelope1_3
+	Ciphertext []byte
+}
+
+// argumentBinding is the additional data authenticated with an argument, so
+// that it is only decrypted for the execution it was encrypted for
+type argumentBinding struct {
+	Chaincode string
+	UUID      string
+}
+
+// decryptArguments decrypts the arguments of tx encrypted for this node, and
+// returns a clone of tx with the decrypted arguments in its payload, or tx
+// itself if there are none. The arguments are decrypted with the enrollment
+// key, for the chaincode and transaction they were encrypted for only.
+func (node *nodeImpl) decryptArguments(tx *obc.Transaction) (*obc.Transaction, error) {
+	var (
+		spec   *obc.ChaincodeSpec
+		holder proto.Message
+	)
+
+	switch tx.Type {
+	case obc.Transaction_CHAINCODE_DEPLOY:
+		cds := &obc.ChaincodeDeploymentSpec{}
+		if err := proto.Unmarshal(tx.Payload, cds); err != nil {
+			return tx, nil
+		}
+		spec, holder = cds.ChaincodeSpec, cds
+	case obc.Transaction_CHAINCODE_INVOKE, obc.Transaction_CHAINCODE_QUERY:
+		cis := &obc.ChaincodeInvocationSpec{}
+		if err := proto.Unmarshal(tx.Payload, cis); err != nil {
+			return tx, nil
+		}
+		spec, holder = cis.ChaincodeSpec, cis
+	default:
+		return tx, nil
+	}
+
+	decrypted, err := node.decryptSpecArguments(spec, tx.Uuid)
+	if err != nil || !decrypted {
+		return tx, err
+	}
+
+	payload, err := proto.Marshal(holder)
+	if err != nil {
+		return nil, err
+	}
+	clone := *tx
+	clone.Payload = payload
+
+	return &clone, nil
+}
+
+// decryptSpecArguments replaces the arguments of spec encrypted for this node
+// by their value, and tells whether there were any
+func (node *nodeImpl) decryptSpecArguments(spec *obc.ChaincodeSpec, uuid string) (bool, error) {
+	if spec.GetCtorMsg() == nil || node.enrollPrivKey == nil {
+		return false, nil
+	}
+
+	var (
+		keyID, aad []byte
+		result     bool
+	)
+	for i, arg := range spec.CtorMsg.Args {
+		if !strings.HasPrefix(arg, encryptedArgumentPrefix) {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(arg[len(encryptedArgumentPrefix):])
+		if err != nil {
+			continue
+		}
+		envelope := new(argumentEnvelope)
+		if rest, err := asn1.Unmarshal(raw, envelope); err != nil || len(rest) > 0 {
+			continue
+		}
+
+		if keyID == nil {
+			var err error
+			if keyID, err = enrollmentKeyID(&node.enrollPrivKey.PublicKey); err != nil {
+				return false, err
+			}
+			if aad, err = asn1.Marshal(argumentBinding{chaincodeIDName(spec.ChaincodeID), uuid}); err != nil {
+				return false, err
+			}
+		}
+		for _, key := range envelope.Keys {
+			if !bytes.Equal(key.KeyID, keyID) {
+				continue
+			}
+
+			value, err := node.openArgument(key.Keys, envelope.Ciphertext, aad)
+			if err != nil {
+				node.Errorf("Failed decrypting argument [%d]: [%s]", i, err)
+
+				return false, utils.ErrDecrypt
+			}
+			spec.CtorMsg.Args[i] = string(value)
+			result = true
+			break
+		}
+	}
+
+	return result, nil
+}
+
+func (node *nodeImpl) openArgument(encKey, ct, aad []byte) ([]byte, error) {
+	enrollKey, err := node.eciesSPI.NewPrivateKey(nil, node.enrollPrivKey)
+	if err != nil {
+		return nil, err
+	}
+	asymCipher, err := node.eciesSPI.NewAsymmetricCipherFromPrivateKey(enrollKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := asymCipher.Process(encKey)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(ct) < gcm.NonceSize() {
+		return nil, utils.ErrDecrypt
+	}
+
+	return gcm.Open(nil, ct[:gcm.NonceSize()], ct[gcm.NonceSize():], aad)
+}
+
+// chaincodeIDName returns the name of a chaincode, or its path if it is not
+// named yet
+func chaincodeIDName(chaincodeID *obc.ChaincodeID) string {
+	if chaincodeID == nil {
+		return ""
+	}
+	if chaincodeID.Name != "" {
+		return chaincodeID.Name
+	}
+
+	return chaincodeID.Path
+}
@@ -17,9 +17,15 @@ limitations under the License.
 package crypto
 
 import (
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
+	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/spf13/viper"
 )
 
@@ -63,6 +69,22 @@ type configuration struct {
 
 	multiThreading bool
 	tCertBatchSize int
+
+	tCertPoolSize         int
+	tCertPoolLowWatermark int
+	tCertPoolRefillers    int
+
+	verificationWorkers int
+
+	// The token holding the enrollment key, nil if it is kept in the
+	// keystore, and the label of the key on the token
+	keyLabel string
+
+	// The algorithm of the enrollment key, ECDSA or ED25519
+	signatureAlgorithm string
+
+	// Whether transactions are signed with anonymous credentials
+	credentials bool
 }
 
 func (conf *configuration) init() error {
@@ -146,12 +168,83 @@ func (conf *configuration) init() error {
 		}
 	}
 
+	// Set the TCert pool
+	conf.tCertPoolSize = 2 * conf.tCertBatchSize
+	if viper.IsSet("security.tcert.pool.size") {
+		ovveride := viper.GetInt("security.tcert.pool.size")
+		if ovveride > 0 {
+			conf.tCertPoolSize = ovveride
+		}
+	}
+	conf.tCertPoolLowWatermark = conf.tCertBatchSize
+	if viper.IsSet("security.tcert.pool.lowWatermark") {
+		ovveride := viper.GetInt("security.tcert.pool.lowWatermark")
+		if ovveride > 0 {
+			conf.tCertPoolLowWatermark = ovveride
+		}
+	}
+	if conf.tCertPoolLowWatermark >= conf.tCertPoolSize {
+		conf.tCertPoolLowWatermark = conf.tCertPoolSize - 1
+	}
+	conf.tCertPoolRefillers = 1
+	if viper.IsSet("security.tcert.pool.refillers") {
+		ovveride := viper.GetInt("security.tcert.pool.refillers")
+		if ovveride > 0 {
+			conf.tCertPoolRefillers = ovveride
+		}
+	}
+
+	// Set the number of workers verifying the signatures of a batch of transactions
+	conf.verificationWorkers = runtime.NumCPU()
+	if viper.IsSet("security.verification.workers") {
+		ovveride := viper.GetInt("security.verification.workers")
+		if ovveride > 0 {
+			conf.verificationWorkers = ovveride
+		}
+	}
+
 	// Set multithread
 	conf.multiThreading = false
 	if viper.IsSet("security.multithreading.enabled") {
 		conf.multiThreading = viper.GetBool("security.multithreading.enabled")
 	}
 
+	// Set the anonymous credentials
+	conf.credentials = viper.GetBool("security.credentials.enabled")
+	if conf.credentials && primitives.IsFIPSMode() {
+		return fmt.Errorf("Anonymous credentials are not supported in FIPS mode")
+	}
+
+	// Set the label of the enrollment key kept by the crypto provider
+	conf.keyLabel = conf.name
+	if viper.IsSet("security.pkcs11.label") {
+		ovveride := viper.GetString("security.pkcs11.label")
+		if ovveride != "" {
+			conf.keyLabel = ovveride
+		}
+	}
+
+	conf.signatureAlgorithm = "ECDSA"
+	if viper.IsSet("security.signatureAlgorithm") {
+		ovveride := strings.ToUpper(viper.GetString("security.signatureAlgorithm"))
+		if ovveride != "" {
+			conf.signatureAlgorithm = ovveride
+		}
+	}
+	switch conf.signatureAlgorithm {
+	case "ECDSA":
+	case "ED25519":
+		// Ed25519 keys are generated and kept in software
+		if conf.isEnrollmentKeyExternal() {
+			return fmt.Errorf("Signature algorithm ED25519 is not supported by crypto provider [%s]", primitives.GetProvider().Name())
+		}
+		if primitives.IsFIPSMode() {
+			return fmt.Errorf("Signature algorithm ED25519 is not supported in FIPS mode")
+		}
+	default:
+		return fmt.Errorf("Signature algorithm not supported [%s]", conf.signatureAlgorithm)
+	}
+
 	return nil
 }
 
@@ -211,6 +304,13 @@ func (conf *configuration) getQueryStateKeyFilename() string {
 	return "query.key"
 }
 
+// getChaincodeKeyFilename returns the file of the keys of a confidential
+// chaincode: the public key of the chaincode whose name hashes to id on
+// clients, and the chaincode keys identified by id on validators
+func (conf *configuration) getChaincodeKeyFilename(id []byte) string {
+	return "chaincode." + hex.EncodeToString(id) + ".key"
+}
+
 func (conf *configuration) getEnrollmentKeyFilename() string {
 	return "enrollment.key"
 }
@@ -239,6 +339,14 @@ func (conf *configuration) getTLSCACertsChainFilename() string {
 	return "tlsca.cert.chain"
 }
 
+func (conf *configuration) getTrustedRootsPath() string {
+	return viper.GetString("peer.pki.roots.file")
+}
+
+func (conf *configuration) getChaincodeValidatorsPath() string {
+	return viper.GetString("security.confidentiality.validators.file")
+}
+
 func (conf *configuration) getTLSCACertsExternalPath() string {
 	return viper.GetString("peer.pki.tls.rootcert.file")
 }
@@ -287,10 +395,69 @@ func (conf *configuration) getTCertOwnerKDFKeyFilename() string {
 	return "tca.kdf.key"
 }
 
+func (conf *configuration) getCRLRefreshInterval() time.Duration {
+	return viper.GetDuration("security.crl.refreshInterval")
+}
+
+func (conf *configuration) isCRLWatchEnabled() bool {
+	return viper.GetBool("security.crl.watch")
+}
+
+func (conf *configuration) isCRLEventsEnabled() bool {
+	return viper.GetBool("security.crl.events")
+}
+
+// isEnrollmentKeyExternal tells whether the enrollment key is kept by the
+// crypto provider, e.g. on an HSM, rather than in the keystore
+func (conf *configuration) isEnrollmentKeyExternal() bool {
+	return primitives.GetProvider().Name() != primitives.SoftwareProviderName
+}
+
+func (conf *configuration) getEnrollmentKeyLabel() string {
+	return conf.keyLabel
+}
+
+// isEnrollmentKeyEd25519 tells whether the enrollment key is an Ed25519 key
+// rather than an ECDSA key
+func (conf *configuration) isEnrollmentKeyEd25519() bool {
+	return conf.signatureAlgorithm == "ED25519"
+}
+
+func (conf *configuration) getIssuerKeyFilename() string {
+	return "tca.idemix"
+}
+
+func (conf *configuration) getCredentialFilename() string {
+	return "credential"
+}
+
+// isCredentialsEnabled tells whether clients sign transactions with an
+// anonymous credential of the TCA rather than with TCerts, and whether
+// peers accept such transactions
+func (conf *configuration) isCredentialsEnabled() bool {
+	return conf.credentials
+}
+
 func (conf *configuration) getTCertBatchSize() int {
 	return conf.tCertBatchSize
 }
 
+func (conf *configuration) getTCertPoolSize() int {
+	return conf.tCertPoolSize
+}
+
+func (conf *configuration) getTCertPoolLowWatermark() int {
+	return conf.tCertPoolLowWatermark
+}
+
+func (conf *configuration) getTCertPoolRefillers() int {
+	return conf.tCertPoolRefillers
+}
+
+func (conf *configuration) getVerificationWorkers() int {
+	return conf.verificationWorkers
+}
+
 func (conf *configuration) GetConfidentialityProtocolVersion() string {
 	return conf.confidentialityProtocolVersion
 }
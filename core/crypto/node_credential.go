@@ -0,0 +1,80 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+
+	"github.com/hyperledger/fabric/core/crypto/idemix"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
+	"golang.org/x/net/context"
+)
+
+// loadIssuerKey returns the public key the TCA issues anonymous credentials
+// with, retrieved from the TCA the first time
+func (node *nodeImpl) loadIssuerKey() (*idemix.IssuerPublicKey, error) {
+	path := node.conf.getPathForAlias(node.conf.getIssuerKeyFilename())
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		if raw, err = node.retrieveIssuerKey(); err != nil {
+			node.Errorf("Failed retrieving the issuer key from the TCA [%s].", err.Error())
+
+			return nil, err
+		}
+		err = ioutil.WriteFile(path, raw, 0600)
+	}
+	if err != nil {
+		node.Errorf("Failed loading the issuer key [%s].", err.Error())
+
+		return nil, err
+	}
+
+	return idemix.ParseIssuerPublicKey(raw)
+}
+
+// retrieveIssuerKey reads the issuer key from the TCA, and checks that the
+// TCA signed it
+func (node *nodeImpl) retrieveIssuerKey() ([]byte, error) {
+	sock, tcaP, err := node.getTCAClient()
+	if err != nil {
+		return nil, err
+	}
+	defer sock.Close()
+
+	key, err := tcaP.ReadIssuerKey(context.Background(), &membersrvc.Empty{})
+	if err != nil {
+		return nil, err
+	}
+
+	tcaCert, _, err := node.ks.loadCertX509AndDer(node.conf.getTCACertsChainFilename())
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := tcaCert.PublicKey.(*ecdsa.PublicKey)
+	r, s := new(big.Int), new(big.Int)
+	if !ok || key.Sig == nil || r.UnmarshalText(key.Sig.R) != nil || s.UnmarshalText(key.Sig.S) != nil ||
+		!ecdsa.Verify(pub, primitives.Hash(key.Key), r, s) {
+		return nil, errors.New("The issuer key is not signed by the TCA.")
+	}
+
+	return key.Key, nil
+}
@@ -17,17 +17,20 @@ limitations under the License.
 package crypto
 
 import (
+	"crypto"
 	"crypto/ecdsa"
-	"crypto/rand"
 	"crypto/x509"
 	"google/protobuf"
 	"time"
 
 	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
+	"golang.org/x/crypto/ed25519"
 
 	"encoding/asn1"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"strings"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
@@ -55,8 +58,6 @@ func (node *nodeImpl) retrieveECACertsChain(userID string) error {
 	}
 	node.Debugf("ECA certificate [% x].", ecaCertRaw)
 
-	// TODO: Test ECA cert againt root CA
-	// TODO: check response.Cert against rootCA
 	x509ECACert, err := primitives.DERToX509Certificate(ecaCertRaw)
 	if err != nil {
 		node.Errorf("Failed parsing ECA certificate [%s].", err.Error())
@@ -101,10 +102,12 @@ func (node *nodeImpl) retrieveEnrollmentData(enrollID, enrollPWD string) error {
 		return err
 	}
 
-	// Store enrollment key
-	if err := node.ks.storePrivateKey(node.conf.getEnrollmentKeyFilename(), key); err != nil {
-		node.Errorf("Failed storing enrollment key [id=%s]: [%s]", enrollID, err)
-		return err
+	// Store enrollment key, unless it is held by an HSM
+	if !node.conf.isEnrollmentKeyExternal() {
+		if err := node.ks.storePrivateKey(node.conf.getEnrollmentKeyFilename(), key); err != nil {
+			node.Errorf("Failed storing enrollment key [id=%s]: [%s]", enrollID, err)
+			return err
+		}
 	}
 
 	// Store enrollment cert
@@ -154,6 +157,16 @@ func (node *nodeImpl) retrieveEnrollmentData(enrollID, enrollPWD string) error {
 func (node *nodeImpl) loadEnrollmentKey() error {
 	node.Debug("Loading enrollment key...")
 
+	if node.conf.isEnrollmentKeyExternal() {
+		signer, err := node.newEnrollmentSigner()
+		if err != nil {
+			return err
+		}
+		node.enrollSigner = signer
+
+		return nil
+	}
+
 	enrollPrivKey, err := node.ks.loadPrivateKey(node.conf.getEnrollmentKeyFilename())
 	if err != nil {
 		node.Errorf("Failed loading enrollment private key [%s].", err.Error())
@@ -161,11 +174,81 @@ func (node *nodeImpl) loadEnrollmentKey() error {
 		return err
 	}
 
-	node.enrollPrivKey = enrollPrivKey.(*ecdsa.PrivateKey)
+	switch key := enrollPrivKey.(type) {
+	case *ecdsa.PrivateKey:
+		node.enrollPrivKey = key
+	case ed25519.PrivateKey:
+		node.enrollSigner = key
+	default:
+		node.Error("Enrollment key is neither an ECDSA nor an Ed25519 key.")
+
+		return errors.New("Enrollment key is neither an ECDSA nor an Ed25519 key.")
+	}
 
 	return nil
 }
 
+// newEnrollmentSigner returns the signer with the enrollment key held by the
+// HSM
+func (node *nodeImpl) newEnrollmentSigner() (crypto.Signer, error) {
+	// Clients derive the keys of their TCerts from the enrollment key
+	if node.eType == NodeClient {
+		node.Error("Enrollment keys held by an HSM are not supported for clients.")
+
+		return nil, errors.New("Enrollment keys held by an HSM are not supported for clients.")
+	}
+
+	provider := primitives.GetProvider()
+	signer, err := provider.GetKey(node.conf.getEnrollmentKeyLabel())
+	if err != nil {
+		node.Errorf("Failed opening enrollment key [%s] of crypto provider [%s]: [%s].", node.conf.getEnrollmentKeyLabel(), provider.Name(), err)
+
+		return nil, err
+	}
+	if _, ok := signer.Public().(*ecdsa.PublicKey); !ok {
+		node.Errorf("Enrollment key [%s] is not an ECDSA key.", node.conf.getEnrollmentKeyLabel())
+
+		return nil, errors.New("Enrollment key is not an ECDSA key.")
+	}
+
+	return signer, nil
+}
+
+// newEnrollmentKey generates an enrollment key kept in the keystore: an
+// Ed25519 key or, by default, an ECDSA key generated by the crypto provider
+func (node *nodeImpl) newEnrollmentKey() (interface{}, interface{}, membersrvc.CryptoType, error) {
+	if node.conf.isEnrollmentKeyEd25519() {
+		key, err := primitives.NewEd25519Key()
+		if err != nil {
+			node.Errorf("Failed generating Ed25519 key [%s].", err.Error())
+
+			return nil, nil, 0, err
+		}
+		return key, key.Public(), membersrvc.CryptoType_ED25519, nil
+	}
+
+	signer, err := primitives.GetProvider().GenerateKey("")
+	if err != nil {
+		node.Errorf("Failed generating ECDSA key [%s].", err.Error())
+
+		return nil, nil, 0, err
+	}
+	key, ok := signer.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, nil, 0, errors.New("Enrollment key is not an ECDSA key.")
+	}
+	return key, &key.PublicKey, membersrvc.CryptoType_ECDSA, nil
+}
+
+// enrollmentKey returns the enrollment key to sign with, held either by
+// the node or by an HSM
+func (node *nodeImpl) enrollmentKey() interface{} {
+	if node.enrollSigner != nil {
+		return node.enrollSigner
+	}
+	return node.enrollPrivKey
+}
+
 func (node *nodeImpl) loadEnrollmentCertificate() error {
 	node.Debug("Loading enrollment certificate...")
 
@@ -178,8 +261,7 @@ func (node *nodeImpl) loadEnrollmentCertificate() error {
 	node.enrollCert = cert
 
 	// TODO: move this to retrieve
-	pk := node.enrollCert.PublicKey.(*ecdsa.PublicKey)
-	err = primitives.VerifySignCapability(node.enrollPrivKey, pk)
+	err = primitives.VerifySignCapability(node.enrollmentKey(), node.enrollCert.PublicKey)
 	if err != nil {
 		node.Errorf("Failed checking enrollment certificate against enrollment key [%s].", err.Error())
 
@@ -197,6 +279,188 @@ func (node *nodeImpl) loadEnrollmentCertificate() error {
 	return nil
 }
 
+// GetEnrollmentCertificateExpiry returns when the enrollment certificate in
+// use expires
+func (node *nodeImpl) GetEnrollmentCertificateExpiry() time.Time {
+	node.enrollLock.RLock()
+	defer node.enrollLock.RUnlock()
+	return node.enrollCert.NotAfter
+}
+
+// RenewEnrollment replaces the enrollment certificate and key by the ones
+// stored in the keystore, e.g. after the ECA issued a new certificate to the
+// node ahead of the expiry of the current one
+func (node *nodeImpl) RenewEnrollment() error {
+	node.Debug("Renewing enrollment certificate...")
+
+	// The renewed key held by an HSM has the label of the current one
+	var enrollPrivKey *ecdsa.PrivateKey
+	var enrollSigner crypto.Signer
+	var signKey interface{}
+	if node.conf.isEnrollmentKeyExternal() {
+		signer, err := node.newEnrollmentSigner()
+		if err != nil {
+			return err
+		}
+		enrollSigner, signKey = signer, signer
+	} else {
+		key, err := node.ks.loadPrivateKey(node.conf.getEnrollmentKeyFilename())
+		if err != nil {
+			return err
+		}
+		switch key := key.(type) {
+		case *ecdsa.PrivateKey:
+			enrollPrivKey, signKey = key, key
+		case ed25519.PrivateKey:
+			enrollSigner, signKey = key, key
+		default:
+			node.Error("Renewed enrollment key is neither an ECDSA nor an Ed25519 key.")
+
+			return errors.New("Renewed enrollment key is neither an ECDSA nor an Ed25519 key.")
+		}
+	}
+
+	cert, der, err := node.ks.loadCertX509AndDer(node.conf.getEnrollmentCertFilename())
+	if err != nil {
+		return err
+	}
+	// The common name is the enrollment ID, followed by the affiliation
+	if enrollID := strings.Split(cert.Subject.CommonName, "\\")[0]; enrollID != node.enrollID {
+		node.Errorf("Renewed enrollment certificate is issued to [%s], not to [%s].", enrollID, node.enrollID)
+
+		return fmt.Errorf("Renewed enrollment certificate is issued to [%s], not to [%s].", enrollID, node.enrollID)
+	}
+	if err := primitives.CheckCertPKAgainstSK(cert, signKey); err != nil {
+		node.Errorf("Failed checking renewed enrollment certificate against enrollment key [%s].", err.Error())
+
+		return err
+	}
+
+	// The role extension cannot be checked by x509
+	chainCert := *cert
+	chainCert.UnhandledCriticalExtensions = nil
+	if _, err := primitives.CheckCertAgainRoot(&chainCert, node.ecaCertPool); err != nil {
+		node.Errorf("Failed verifying renewed enrollment certificate against ECA cert pool [%s].", err.Error())
+
+		return err
+	}
+
+	node.enrollLock.Lock()
+	defer node.enrollLock.Unlock()
+	node.enrollPrivKey = enrollPrivKey
+	node.enrollSigner = enrollSigner
+	node.enrollCert = cert
+	node.id = primitives.Hash(der)
+	node.enrollCertHash = primitives.Hash(der)
+	node.Infof("Renewed enrollment certificate, id is now [% x], valid until %s.", node.id, cert.NotAfter)
+
+	return nil
+}
+
+// ReEnroll rotates the enrollment key: the ECA certifies a new key for the
+// node, which proves its identity with the current key, and the new key and
+// certificate replace the current ones in the keystore and in use. The ECA
+// revokes the current certificate once its re-enrollment overlap period is
+// over, until when peers accept the signatures of the node with either key.
+func (node *nodeImpl) ReEnroll() error {
+	if err := node.reEnroll(); err != nil {
+		return err
+	}
+	return node.RenewEnrollment()
+}
+
+// reEnroll stores the new enrollment key and the certificate issued by the
+// ECA for it in the keystore
+func (node *nodeImpl) reEnroll() error {
+	node.Debug("Re-enrolling...")
+
+	if node.conf.isEnrollmentKeyExternal() {
+		node.Error("Enrollment keys held by an HSM cannot be rotated.")
+
+		return errors.New("Enrollment keys held by an HSM cannot be rotated.")
+	}
+
+	signPriv, signPublic, signType, err := node.newEnrollmentKey()
+	if err != nil {
+		return err
+	}
+	signPub, err := x509.MarshalPKIXPublicKey(signPublic)
+	if err != nil {
+		node.Errorf("Failed mashalling signing key [%s].", err.Error())
+
+		return err
+	}
+	encPriv, err := primitives.NewECDSAKey()
+	if err != nil {
+		node.Errorf("Failed generating Encryption key [%s].", err.Error())
+
+		return err
+	}
+	encPub, err := x509.MarshalPKIXPublicKey(&encPriv.PublicKey)
+	if err != nil {
+		node.Errorf("Failed marshalling Encryption key [%s].", err.Error())
+
+		return err
+	}
+
+	req := &membersrvc.ECertReEnrollReq{
+		Ts:   &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0},
+		Id:   &membersrvc.Identity{Id: node.enrollID},
+		Sign: &membersrvc.PublicKey{Type: signType, Key: signPub},
+		Enc:  &membersrvc.PublicKey{Type: membersrvc.CryptoType_ECDSA, Key: encPub},
+	}
+
+	// The new key signs the request, then the current one
+	raw, _ := proto.Marshal(req)
+	if req.NewSig, err = signRequest(signPriv, raw); err != nil {
+		node.Errorf("Failed signing with the new enrollment key [%s].", err.Error())
+
+		return err
+	}
+	raw, _ = proto.Marshal(req)
+	if req.Sig, err = node.signRequestWithEnrollmentKey(raw); err != nil {
+		node.Errorf("Failed signing with the enrollment key [%s].", err.Error())
+
+		return err
+	}
+
+	sock, ecaP, err := node.getECAClient()
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+
+	resp, err := ecaP.ReEnrollCertificatePair(context.Background(), req)
+	if err != nil {
+		node.Errorf("Failed invoking ReEnrollCertificatePair [%s].", err.Error())
+
+		return err
+	}
+
+	cert, err := primitives.DERToX509Certificate(resp.Certs.Sign)
+	if err != nil {
+		node.Errorf("Failed parsing enrollment certificate [%s].", err)
+
+		return err
+	}
+	if err := primitives.CheckCertPKAgainstSK(cert, signPriv); err != nil {
+		node.Errorf("Failed checking enrollment certificate against the new enrollment key [%s].", err)
+
+		return err
+	}
+
+	if err := node.ks.storePrivateKey(node.conf.getEnrollmentKeyFilename(), signPriv); err != nil {
+		return err
+	}
+	if err := node.ks.storeCert(node.conf.getEnrollmentCertFilename(), resp.Certs.Sign); err != nil {
+		return err
+	}
+
+	node.Debug("Re-enrolling...done")
+
+	return nil
+}
+
 func (node *nodeImpl) loadEnrollmentID() error {
 	node.Debugf("Loading enrollment id at [%s]...", node.conf.getEnrollmentIDPath())
 
@@ -271,7 +535,7 @@ func (node *nodeImpl) getECAClient() (*grpc.ClientConn, membersrvc.ECAPClient, e
 
 	node.Debug("Getting ECA client...done")
 
-	return conn, client, nil
+	return conn, client, err
 }
 
 func (node *nodeImpl) callECAReadCACertificate(ctx context.Context, opts ...grpc.CallOption) (*membersrvc.Cert, error) {
@@ -329,15 +593,29 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 
 	// Run the protocol
 
-	signPriv, err := primitives.NewECDSAKey()
-	if err != nil {
-		node.Errorf("Failed generating ECDSA key [%s].", err.Error())
+	// The enrollment key is generated by the crypto provider. A provider
+	// keeping its keys, e.g. on an HSM, may already have one.
+	var signPriv interface{}
+	var signPublic interface{}
+	signType := membersrvc.CryptoType_ECDSA
+	if node.conf.isEnrollmentKeyExternal() {
+		signer, err := node.newEnrollmentSigner()
+		if err != nil {
+			node.Infof("Generating enrollment key [%s]...", node.conf.getEnrollmentKeyLabel())
 
+			if signer, err = primitives.GetProvider().GenerateKey(node.conf.getEnrollmentKeyLabel()); err != nil {
+				node.Errorf("Failed generating enrollment key [%s].", err.Error())
+
+				return nil, nil, nil, err
+			}
+		}
+		signPriv, signPublic = signer, signer.Public()
+	} else if signPriv, signPublic, signType, err = node.newEnrollmentKey(); err != nil {
 		return nil, nil, nil, err
 	}
-	signPub, err := x509.MarshalPKIXPublicKey(&signPriv.PublicKey)
+	signPub, err := x509.MarshalPKIXPublicKey(signPublic)
 	if err != nil {
-		node.Errorf("Failed mashalling ECDSA key [%s].", err.Error())
+		node.Errorf("Failed mashalling signing key [%s].", err.Error())
 
 		return nil, nil, nil, err
 	}
@@ -359,7 +637,7 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 		Ts:   &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0},
 		Id:   &membersrvc.Identity{Id: id},
 		Tok:  &membersrvc.Token{Tok: []byte(pw)},
-		Sign: &membersrvc.PublicKey{Type: membersrvc.CryptoType_ECDSA, Key: signPub},
+		Sign: &membersrvc.PublicKey{Type: signType, Key: signPub},
 		Enc:  &membersrvc.PublicKey{Type: membersrvc.CryptoType_ECDSA, Key: encPub},
 		Sig:  nil}
 
@@ -399,19 +677,14 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 	req.Tok.Tok = out
 	req.Sig = nil
 
-	hash := primitives.NewHash()
 	raw, _ := proto.Marshal(req)
-	hash.Write(raw)
 
-	r, s, err := ecdsa.Sign(rand.Reader, signPriv, hash.Sum(nil))
+	req.Sig, err = signRequest(signPriv, raw)
 	if err != nil {
 		node.Errorf("Failed signing [%s].", err.Error())
 
 		return nil, nil, nil, err
 	}
-	R, _ := r.MarshalText()
-	S, _ := s.MarshalText()
-	req.Sig = &membersrvc.Signature{Type: membersrvc.CryptoType_ECDSA, R: R, S: S}
 
 	resp, err = ecaP.CreateCertificatePair(context.Background(), req)
 	if err != nil {
@@ -481,5 +754,11 @@ func (node *nodeImpl) getECACertificate() ([]byte, error) {
 		return nil, err
 	}
 
+	if err := node.checkCACertificate("ECA", responce); err != nil {
+		node.Errorf("Failed verifying ECA certificate [%s].", err.Error())
+
+		return nil, err
+	}
+
 	return responce.Cert, nil
 }
@@ -17,8 +17,10 @@ limitations under the License.
 package crypto
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/x509"
+	"sync"
 
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/utils"
@@ -48,10 +50,13 @@ type nodeImpl struct {
 	// 48-bytes identifier
 	id []byte
 
-	// Enrollment Certificate and private key
+	// Enrollment Certificate and private key, enrollLock guards their
+	// renewal
+	enrollLock     sync.RWMutex
 	enrollID       string
 	enrollCert     *x509.Certificate
 	enrollPrivKey  *ecdsa.PrivateKey
+	enrollSigner   crypto.Signer // Signs with an Ed25519 key or a key held by an HSM instead of enrollPrivKey
 	enrollCertHash []byte
 
 	// Enrollment Chain
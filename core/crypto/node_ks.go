@@ -227,6 +227,10 @@ func (ks *keyStore) storeKey(alias string, key []byte) error {
 	return nil
 }
 
+func (ks *keyStore) deleteKey(alias string) error {
+	return os.Remove(ks.node.conf.getPathForAlias(alias))
+}
+
 func (ks *keyStore) loadKey(alias string) ([]byte, error) {
 	path := ks.node.conf.getPathForAlias(alias)
 	ks.node.Debugf("Loading key [%s] at [%s]...", alias, path)
@@ -258,6 +262,21 @@ func (ks *keyStore) storeCert(alias string, der []byte) error {
 	return nil
 }
 
+func (ks *keyStore) storeCertsChain(alias string, chain [][]byte) error {
+	var pem []byte
+	for _, der := range chain {
+		pem = append(pem, primitives.DERCertToPEM(der)...)
+	}
+
+	err := ioutil.WriteFile(ks.node.conf.getPathForAlias(alias), pem, 0700)
+	if err != nil {
+		ks.node.Errorf("Failed storing certificates chain [%s]: [%s]", alias, err)
+		return err
+	}
+
+	return nil
+}
+
 func (ks *keyStore) certMissing(alias string) bool {
 	return !ks.isAliasSet(alias)
 }
@@ -0,0 +1,79 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
+)
+
+// loadTrustedRoots loads the root CAs listed in peer.pki.roots.file, which
+// may hold the roots of several organizations. It returns nil if none is
+// configured.
+func (node *nodeImpl) loadTrustedRoots() (*x509.CertPool, error) {
+	path := node.conf.getTrustedRootsPath()
+	if path == "" {
+		return nil, nil
+	}
+
+	pem, err := node.ks.loadExternalCert(path)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, errors.New("No trusted root certificate found in " + path)
+	}
+	return roots, nil
+}
+
+// checkCACertificate verifies that the certificate of a CA chains, through
+// the certificates of its issuers presented by the CA, to one of the trusted
+// root CAs. Any CA certificate is accepted when no root is configured.
+func (node *nodeImpl) checkCACertificate(name string, cert *membersrvc.Cert) error {
+	roots, err := node.loadTrustedRoots()
+	if err != nil || roots == nil {
+		return err
+	}
+
+	x509Cert, err := primitives.DERToX509Certificate(cert.Cert)
+	if err != nil {
+		return err
+	}
+	intermediates := x509.NewCertPool()
+	for _, raw := range cert.Chain {
+		issuer, err := primitives.DERToX509Certificate(raw)
+		if err != nil {
+			return err
+		}
+		intermediates.AddCert(issuer)
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := x509Cert.Verify(opts); err != nil {
+		return fmt.Errorf("The %s certificate does not chain to a trusted root: %s", name, err)
+	}
+	return nil
+}
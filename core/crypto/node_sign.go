@@ -17,27 +17,51 @@ limitations under the License.
 package crypto
 
 import (
-	"math/big"
-
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
 )
 
 func (node *nodeImpl) sign(signKey interface{}, msg []byte) ([]byte, error) {
-	return primitives.ECDSASign(signKey, msg)
+	return primitives.GetProvider().Sign(signKey, msg)
 }
 
 func (node *nodeImpl) signWithEnrollmentKey(msg []byte) ([]byte, error) {
-	return primitives.ECDSASign(node.enrollPrivKey, msg)
+	node.enrollLock.RLock()
+	defer node.enrollLock.RUnlock()
+	return primitives.GetProvider().Sign(node.enrollmentKey(), msg)
+}
+
+func (node *nodeImpl) signRequestWithEnrollmentKey(msg []byte) (*membersrvc.Signature, error) {
+	node.enrollLock.RLock()
+	defer node.enrollLock.RUnlock()
+	return signRequest(node.enrollmentKey(), msg)
 }
 
-func (node *nodeImpl) ecdsaSignWithEnrollmentKey(msg []byte) (*big.Int, *big.Int, error) {
-	return primitives.ECDSASignDirect(node.enrollPrivKey, msg)
+// signRequest signs msg, a request to the membership services, with the
+// ECDSA or Ed25519 key signKey
+func signRequest(signKey interface{}, msg []byte) (*membersrvc.Signature, error) {
+	sigType := membersrvc.CryptoType_ECDSA
+	signDirect := primitives.ECDSASignDirect
+	if primitives.IsEd25519Key(signKey) {
+		sigType = membersrvc.CryptoType_ED25519
+		signDirect = primitives.Ed25519SignDirect
+	}
+
+	r, s, err := signDirect(signKey, msg)
+	if err != nil {
+		return nil, err
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	return &membersrvc.Signature{Type: sigType, R: R, S: S}, nil
 }
 
 func (node *nodeImpl) verify(verKey interface{}, msg, signature []byte) (bool, error) {
-	return primitives.ECDSAVerify(verKey, msg, signature)
+	return primitives.GetProvider().Verify(verKey, msg, signature)
 }
 
 func (node *nodeImpl) verifyWithEnrollmentCert(msg, signature []byte) (bool, error) {
-	return primitives.ECDSAVerify(node.enrollCert.PublicKey, msg, signature)
+	node.enrollLock.RLock()
+	defer node.enrollLock.RUnlock()
+	return primitives.GetProvider().Verify(node.enrollCert.PublicKey, msg, signature)
 }
@@ -40,7 +40,6 @@ func (node *nodeImpl) retrieveTCACertsChain(userID string) error {
 	}
 	node.Debugf("TCA certificate [% x]", tcaCertRaw)
 
-	// TODO: Test TCA cert againt root CA
 	_, err = primitives.DERToX509Certificate(tcaCertRaw)
 	if err != nil {
 		node.Errorf("Failed parsing TCA certificate [%s].", err.Error())
@@ -93,7 +92,7 @@ func (node *nodeImpl) getTCAClient() (*grpc.ClientConn, membersrvc.TCAPClient, e
 
 	node.Debug("Getting TCA client...done")
 
-	return conn, client, nil
+	return conn, client, err
 }
 
 func (node *nodeImpl) callTCAReadCACertificate(ctx context.Context, opts ...grpc.CallOption) (*membersrvc.Cert, error) {
@@ -120,7 +119,11 @@ func (node *nodeImpl) getTCACertificate() ([]byte, error) {
 		return nil, err
 	}
 
-	// TODO: check response.Cert against rootCA
+	if err := node.checkCACertificate("TCA", response); err != nil {
+		node.Errorf("Failed verifying TCA certificate [%s].", err.Error())
+
+		return nil, err
+	}
 
 	return response.Cert, nil
 }
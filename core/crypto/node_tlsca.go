@@ -22,6 +22,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"google/protobuf"
 	"time"
@@ -171,6 +172,74 @@ func (node *nodeImpl) getTLSCertificateFromTLSCA(id, affiliation string) (interf
 	return priv, pbCert.Cert.Cert, nil
 }
 
+// ProvisionTLSCertificate requests from the TLSCA a certificate for the TLS
+// servers and clients of hosts, with a request signed with the enrollment
+// key, and returns the PEM encoded certificate, its key and the TLSCA
+// certificates chain
+func (node *nodeImpl) ProvisionTLSCertificate(hosts []string) ([]byte, []byte, []byte, error) {
+	priv, err := primitives.NewECDSAKey()
+	if err != nil {
+		node.Errorf("Failed generating key: %s", err)
+
+		return nil, nil, nil, err
+	}
+	pubraw, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	now := time.Now()
+	req := &membersrvc.TLSCertCreateReq{
+		Ts:    &google_protobuf.Timestamp{Seconds: now.Unix(), Nanos: int32(now.Nanosecond())},
+		Id:    &membersrvc.Identity{Id: node.enrollID},
+		Pub:   &membersrvc.PublicKey{Type: membersrvc.CryptoType_ECDSA, Key: pubraw},
+		Hosts: hosts,
+	}
+	node.enrollLock.RLock()
+	req.EnrollCert = node.enrollCert.Raw
+	node.enrollLock.RUnlock()
+
+	rawreq, _ := proto.Marshal(req)
+	if req.EnrollSig, err = node.signRequestWithEnrollmentKey(rawreq); err != nil {
+		node.Errorf("Failed signing tls certificate request: %s", err)
+
+		return nil, nil, nil, err
+	}
+	rawreq, _ = proto.Marshal(req)
+	if req.Sig, err = signRequest(priv, rawreq); err != nil {
+		return nil, nil, nil, err
+	}
+
+	resp, err := node.callTLSCACreateCertificate(context.Background(), req)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tlsCert, err := primitives.DERToX509Certificate(resp.Cert.Cert)
+	if err != nil {
+		node.Errorf("Failed parsing tls certificate: %s", err)
+
+		return nil, nil, nil, err
+	}
+	if err = primitives.CheckCertPKAgainstSK(tlsCert, priv); err != nil {
+		return nil, nil, nil, err
+	}
+
+	rawKey, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var chain []byte
+	if resp.RootCert != nil {
+		for _, der := range append([][]byte{resp.RootCert.Cert}, resp.RootCert.Chain...) {
+			chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+		}
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: resp.Cert.Cert}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: rawKey}),
+		chain, nil
+}
+
 func (node *nodeImpl) getTLSCAClient() (*grpc.ClientConn, membersrvc.TLSCAPClient, error) {
 	node.Debug("Getting TLSCA client...")
 
@@ -0,0 +1,134 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/x509"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// TransactionsPreValidation verifies the transactions of a batch as
+// TransactionPreValidation does, and returns the error of each transaction,
+// nil if it is well formed. A certificate shared by several transactions of
+// the batch is verified once, then the certificates and the signatures, or
+// the credential presentations, are verified in parallel by a pool of
+// workers.
+func (peer *peerImpl) TransactionsPreValidation(txs []*obc.Transaction) []error {
+	errs := make([]error, len(txs))
+	if !peer.IsInitialized() {
+		for i := range errs {
+			errs[i] = utils.ErrNotInitialized
+		}
+		return errs
+	}
+
+	// Collect the distinct certificates of the batch
+	var ders [][]byte
+	certIndex := make(map[string]int)
+	txCert := make([]int, len(txs))
+	for i, tx := range txs {
+		switch {
+		case tx.Credential != nil:
+			// Signed with an anonymous credential
+			continue
+		case tx.Cert == nil:
+			errs[i] = utils.ErrTransactionCertificate
+			continue
+		case tx.Signature == nil:
+			errs[i] = utils.ErrTransactionSignature
+			continue
+		}
+
+		j, ok := certIndex[string(tx.Cert)]
+		if !ok {
+			j = len(ders)
+			certIndex[string(tx.Cert)] = j
+			ders = append(ders, tx.Cert)
+		}
+		txCert[i] = j
+	}
+
+	// Verify the certificates
+	certs := make([]*x509.Certificate, len(ders))
+	certErrs := make([]error, len(ders))
+	peer.parallelize(len(ders), func(j int) {
+		certs[j], certErrs[j] = peer.verifyTransactionCertificate(ders[j])
+	})
+
+	// Verify the signatures
+	peer.parallelize(len(txs), func(i int) {
+		if errs[i] != nil {
+			return
+		}
+		if txs[i].Credential != nil {
+			errs[i] = peer.verifyTransactionCredential(txs[i])
+			return
+		}
+		if errs[i] = certErrs[txCert[i]]; errs[i] != nil {
+			return
+		}
+		if errs[i] = peer.verifyTransactionSignature(txs[i], certs[txCert[i]]); errs[i] != nil {
+			return
+		}
+		if txs[i].ConfidentialityLevel == obc.ConfidentialityLevel_PUBLIC {
+			errs[i] = verifyTransactionChaincode(txs[i], certs[txCert[i]])
+		}
+	})
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		var cert *x509.Certificate
+		if j, ok := certIndex[string(txs[i].Cert)]; ok && txs[i].Credential == nil {
+			cert = certs[j]
+		}
+		auditTransaction(txs[i], cert, err)
+	}
+
+	return errs
+}
+
+// parallelize calls f for each index in [0, n), splitting the indexes in
+// consecutive ranges among at most the configured number of workers
+func (peer *peerImpl) parallelize(n int, f func(i int)) {
+	workers := peer.conf.getVerificationWorkers()
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+			for i := from; i < to; i++ {
+				f(i)
+			}
+		}(w*n/workers, (w+1)*n/workers)
+	}
+	wg.Wait()
+}
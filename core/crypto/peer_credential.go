@@ -0,0 +1,71 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/crypto/idemix"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// initIssuerKey loads the key of the anonymous credentials the peer accepts
+// transactions signed with, if enabled
+func (peer *peerImpl) initIssuerKey() (err error) {
+	if !peer.conf.isCredentialsEnabled() {
+		return nil
+	}
+
+	peer.issuerKey, err = peer.loadIssuerKey()
+	return
+}
+
+// verifyTransactionCredential verifies the presentation of the anonymous
+// credential signing tx. tx is left untouched.
+func (peer *peerImpl) verifyTransactionCredential(tx *obc.Transaction) error {
+	if peer.issuerKey == nil {
+		return utils.ErrCredentialsDisabled
+	}
+	if tx.Cert != nil || tx.Signature != nil {
+		return utils.ErrInvalidTransactionSignature
+	}
+
+	presentation, err := idemix.ParsePresentation(tx.Credential)
+	if err != nil {
+		peer.Debugf("Failed parsing the credential presentation [%s].", err.Error())
+
+		return utils.ErrInvalidTransactionSignature
+	}
+
+	// Marshall tx without the presentation nor trace context
+	unsigned := *tx
+	unsigned.Credential = nil
+	unsigned.TraceContext = ""
+	rawTx, err := proto.Marshal(&unsigned)
+	if err != nil {
+		peer.Errorf("TransactionPreValidation: failed marshaling tx [%s].", err.Error())
+		return err
+	}
+
+	if err := presentation.Verify(peer.issuerKey, rawTx); err != nil {
+		peer.Debugf("Failed verifying the credential presentation [%s].", err.Error())
+
+		return utils.ErrInvalidTransactionSignature
+	}
+
+	return nil
+}
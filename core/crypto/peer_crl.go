@@ -0,0 +1,310 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/events/consumer"
+	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
+	obc "github.com/hyperledger/fabric/protos"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var (
+	// Time to wait before reopening a CRL stream to a CA
+	crlRetryInterval = 10 * time.Second
+)
+
+// crlStore holds the latest certificate revocation list issued by a CA.
+type crlStore struct {
+	sync.RWMutex
+
+	issuer     *x509.Certificate
+	thisUpdate time.Time
+	revoked    map[string]bool
+}
+
+// crlStream receives the CRLs pushed by a CA.
+type crlStream interface {
+	Recv() (*membersrvc.CRL, error)
+}
+
+func newCRLStore(issuer *x509.Certificate) *crlStore {
+	return &crlStore{issuer: issuer, revoked: make(map[string]bool)}
+}
+
+// update replaces the revocation list by raw, provided that raw is a
+// list signed by the issuer and more recent than the current one.
+func (store *crlStore) update(raw []byte) error {
+	crl, err := x509.ParseCRL(raw)
+	if err != nil {
+		return err
+	}
+	if err = store.issuer.CheckCRLSignature(crl); err != nil {
+		return err
+	}
+	if crl.HasExpired(time.Now()) {
+		return errors.New("Expired certificate revocation list.")
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	thisUpdate := crl.TBSCertList.ThisUpdate
+	if !thisUpdate.After(store.thisUpdate) {
+		return nil
+	}
+
+	revoked := make(map[string]bool)
+	for _, cert := range crl.TBSCertList.RevokedCertificates {
+		revoked[cert.SerialNumber.String()] = true
+	}
+	store.thisUpdate, store.revoked = thisUpdate, revoked
+
+	return nil
+}
+
+// isRevoked tells whether the certificate with the given serial number is
+// on the revocation list.
+func (store *crlStore) isRevoked(serial *big.Int) bool {
+	if store == nil {
+		return false
+	}
+
+	store.RLock()
+	defer store.RUnlock()
+	return store.revoked[serial.String()]
+}
+
+func (peer *peerImpl) initCRLs() error {
+	peer.Debug("Initializing certificate revocation lists...")
+
+	ecaCert, _, err := peer.ks.loadCertX509AndDer(peer.conf.getECACertsChainFilename())
+	if err != nil {
+		return err
+	}
+	tcaCert, _, err := peer.ks.loadCertX509AndDer(peer.conf.getTCACertsChainFilename())
+	if err != nil {
+		return err
+	}
+	peer.ecaCRL = newCRLStore(ecaCert)
+	peer.tcaCRL = newCRLStore(tcaCert)
+
+	var ctx context.Context
+	ctx, peer.stopCRLs = context.WithCancel(context.Background())
+
+	if interval := peer.conf.getCRLRefreshInterval(); interval > 0 {
+		peer.crlWait.Add(1)
+		go peer.pullCRLs(ctx, interval)
+	}
+
+	if peer.conf.isCRLWatchEnabled() {
+		peer.crlWait.Add(2)
+		go peer.watchCRL(ctx, "ECA", peer.ecaCRL, func(ctx context.Context) (*grpc.ClientConn, crlStream, error) {
+			sock, ecaP, err := peer.getECAClient()
+			if err != nil {
+				return nil, nil, err
+			}
+			stream, err := ecaP.WatchCRL(ctx, &membersrvc.Empty{})
+			return sock, stream, err
+		})
+		go peer.watchCRL(ctx, "TCA", peer.tcaCRL, func(ctx context.Context) (*grpc.ClientConn, crlStream, error) {
+			sock, tcaP, err := peer.getTCAClient()
+			if err != nil {
+				return nil, nil, err
+			}
+			stream, err := tcaP.WatchCRL(ctx, &membersrvc.Empty{})
+			return sock, stream, err
+		})
+	}
+
+	if peer.conf.isCRLEventsEnabled() {
+		peer.crlWait.Add(1)
+		go peer.listenRevocations(ctx)
+	}
+
+	peer.Debug("Initializing certificate revocation lists...done.")
+
+	return nil
+}
+
+// pullCRLs reads the revocation lists of the CAs every interval.
+func (peer *peerImpl) pullCRLs(ctx context.Context, interval time.Duration) {
+	defer peer.crlWait.Done()
+
+	for {
+		peer.readCRL(ctx, "ECA", peer.ecaCRL, func() (*membersrvc.CRL, error) {
+			sock, ecaP, err := peer.getECAClient()
+			if err != nil {
+				return nil, err
+			}
+			defer sock.Close()
+			return ecaP.ReadCRL(ctx, &membersrvc.Empty{})
+		})
+		peer.readCRL(ctx, "TCA", peer.tcaCRL, func() (*membersrvc.CRL, error) {
+			sock, tcaP, err := peer.getTCAClient()
+			if err != nil {
+				return nil, err
+			}
+			defer sock.Close()
+			return tcaP.ReadCRL(ctx, &membersrvc.Empty{})
+		})
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (peer *peerImpl) readCRL(ctx context.Context, ca string, store *crlStore, read func() (*membersrvc.CRL, error)) {
+	crl, err := read()
+	if err != nil {
+		if ctx.Err() == nil {
+			peer.Warningf("Failed reading the CRL of the %s [%s].", ca, err)
+		}
+		return
+	}
+	if err = store.update(crl.Crl); err != nil {
+		peer.Warningf("Rejected the CRL of the %s [%s].", ca, err)
+	}
+}
+
+// watchCRL keeps a stream open to a CA, which pushes its revocation list
+// whenever it changes.
+func (peer *peerImpl) watchCRL(ctx context.Context, ca string, store *crlStore, watch func(context.Context) (*grpc.ClientConn, crlStream, error)) {
+	defer peer.crlWait.Done()
+
+	for {
+		sock, stream, err := watch(ctx)
+		if err == nil {
+			peer.Debugf("Watching the CRL of the %s...", ca)
+			for {
+				var crl *membersrvc.CRL
+				if crl, err = stream.Recv(); err != nil {
+					break
+				}
+				if err := store.update(crl.Crl); err != nil {
+					peer.Warningf("Rejected the CRL of the %s [%s].", ca, err)
+				}
+			}
+		}
+		if sock != nil {
+			sock.Close()
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		peer.Warningf("Lost the CRL stream of the %s [%s]. Retrying in %v.", ca, err, crlRetryInterval)
+
+		select {
+		case <-time.After(crlRetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// revocationAdapter passes the REVOCATION events of the event hub of the
+// membership services to the CRL stores of a peer.
+type revocationAdapter struct {
+	peer         *peerImpl
+	disconnected chan error
+}
+
+func (adapter *revocationAdapter) GetInterestedEvents() ([]*obc.Interest, error) {
+	return []*obc.Interest{{EventType: obc.EventType_REVOCATION}}, nil
+}
+
+func (adapter *revocationAdapter) Recv(msg *obc.Event) (bool, error) {
+	revocation := msg.GetRevocation()
+	if revocation == nil {
+		return true, nil
+	}
+
+	var store *crlStore
+	switch revocation.Issuer {
+	case "ECA":
+		store = adapter.peer.ecaCRL
+	case "TCA":
+		store = adapter.peer.tcaCRL
+	default:
+		adapter.peer.Warningf("Ignored the revocation event of unknown issuer %s.", revocation.Issuer)
+		return true, nil
+	}
+	// the list is only accepted if it is signed by the issuer
+	if err := store.update(revocation.Crl); err != nil {
+		adapter.peer.Warningf("Rejected the CRL of the %s [%s].", revocation.Issuer, err)
+	}
+	return true, nil
+}
+
+func (adapter *revocationAdapter) Disconnected(err error) {
+	adapter.disconnected <- err
+}
+
+// listenRevocations keeps an events client registered with the event hub of
+// the membership services, which sends the revocation lists of the CAs
+// whenever they change.
+func (peer *peerImpl) listenRevocations(ctx context.Context) {
+	defer peer.crlWait.Done()
+
+	for {
+		sock, _, err := peer.getECAClient()
+		if err == nil {
+			adapter := &revocationAdapter{peer: peer, disconnected: make(chan error, 1)}
+			client := consumer.NewEventsClient(peer.conf.getECAPAddr(), adapter)
+			if err = client.StartWithConnection(sock); err == nil {
+				peer.Debug("Listening for revocation events...")
+				select {
+				case err = <-adapter.disconnected:
+				case <-ctx.Done():
+					client.Stop()
+				}
+			}
+		}
+		if sock != nil {
+			sock.Close()
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		peer.Warningf("Lost the revocation events of the membership services [%v]. Retrying in %v.", err, crlRetryInterval)
+
+		select {
+		case <-time.After(crlRetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (peer *peerImpl) closeCRLs() {
+	if peer.stopCRLs != nil {
+		peer.stopCRLs()
+		peer.crlWait.Wait()
+	}
+}
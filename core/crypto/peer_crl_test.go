@@ -0,0 +1,120 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/testutil"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// newTestCertificate returns a new key and a certificate of it following
+// tmpl, issued by issuer or self-signed if issuer is nil
+func newTestCertificate(t *testing.T, tmpl *x509.Certificate, issuer *x509.Certificate, issuerPriv *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	priv := testutil.NewKey(t)
+	return testutil.NewCertificate(t, tmpl, priv, issuer, issuerPriv), priv
+}
+
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	return newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+}
+
+func TestCRLStore(t *testing.T) {
+	issuer, priv := newTestCA(t)
+	other, otherPriv := newTestCA(t)
+
+	now := time.Now()
+	newCRL := func(cert *x509.Certificate, priv *ecdsa.PrivateKey, thisUpdate time.Time, serials ...int64) []byte {
+		var revoked []pkix.RevokedCertificate
+		for _, serial := range serials {
+			revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: big.NewInt(serial), RevocationTime: thisUpdate})
+		}
+		raw, err := cert.CreateCRL(rand.Reader, priv, revoked, thisUpdate, thisUpdate.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	var nilStore *crlStore
+	if nilStore.isRevoked(big.NewInt(2)) {
+		t.Fatal("Without a CRL no certificate should be revoked")
+	}
+
+	store := newCRLStore(issuer)
+	if err := store.update(newCRL(issuer, priv, now.Add(-time.Minute), 2)); err != nil {
+		t.Fatalf("Failed updating the CRL [%s]", err)
+	}
+	if !store.isRevoked(big.NewInt(2)) || store.isRevoked(big.NewInt(3)) {
+		t.Fatal("Only certificate 2 should be revoked")
+	}
+
+	if err := store.update(newCRL(other, otherPriv, now, 3)); err == nil {
+		t.Fatal("A CRL signed by another CA should be rejected")
+	}
+	if err := store.update(newCRL(issuer, priv, now.Add(-2*time.Hour), 3)); err == nil {
+		t.Fatal("An expired CRL should be rejected")
+	}
+
+	// An older list never replaces a newer one
+	if err := store.update(newCRL(issuer, priv, now.Add(-2*time.Minute), 3)); err != nil {
+		t.Fatalf("Failed updating the CRL [%s]", err)
+	}
+	if store.isRevoked(big.NewInt(3)) {
+		t.Fatal("An older CRL should be ignored")
+	}
+
+	if err := store.update(newCRL(issuer, priv, now, 2, 3)); err != nil {
+		t.Fatalf("Failed updating the CRL [%s]", err)
+	}
+	if !store.isRevoked(big.NewInt(2)) || !store.isRevoked(big.NewInt(3)) {
+		t.Fatal("Certificates 2 and 3 should be revoked")
+	}
+}
+
+func TestRevocationEvents(t *testing.T) {
+	issuer, priv := newTestCA(t)
+	peer := &peerImpl{ecaCRL: newCRLStore(issuer)}
+	adapter := &revocationAdapter{peer: peer, disconnected: make(chan error, 1)}
+
+	now := time.Now()
+	raw, err := issuer.CreateCRL(rand.Reader, priv, []pkix.RevokedCertificate{{SerialNumber: big.NewInt(2), RevocationTime: now}}, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := &obc.Event{Event: &obc.Event_Revocation{Revocation: &obc.Revocation{Issuer: "ECA", Crl: raw}}}
+	if cont, err := adapter.Recv(event); !cont || err != nil {
+		t.Fatalf("Revocation events should not stop the events client [%v]", err)
+	}
+	if !peer.ecaCRL.isRevoked(big.NewInt(2)) {
+		t.Fatal("Certificate 2 should be revoked by the revocation event")
+	}
+}
@@ -17,15 +17,18 @@ limitations under the License.
 package crypto
 
 import (
-	"crypto/ecdsa"
 	"crypto/x509"
+	"encoding/asn1"
 	"fmt"
 	"sync"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/audit"
+	"github.com/hyperledger/fabric/core/crypto/idemix"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/utils"
 	obc "github.com/hyperledger/fabric/protos"
+	"golang.org/x/net/context"
 )
 
 type peerImpl struct {
@@ -33,12 +36,22 @@ type peerImpl struct {
 
 	nodeEnrollmentCertificatesMutex sync.RWMutex
 	nodeEnrollmentCertificates      map[string]*x509.Certificate
+
+	// Certificate revocation lists of the ECA and TCA
+	ecaCRL, tcaCRL *crlStore
+	stopCRLs       context.CancelFunc
+	crlWait        sync.WaitGroup
+
+	// The key of the anonymous credentials, nil unless enabled
+	issuerKey *idemix.IssuerPublicKey
 }
 
 // Public methods
 
 // GetID returns this peer's identifier
 func (peer *peerImpl) GetID() []byte {
+	peer.enrollLock.RLock()
+	defer peer.enrollLock.RUnlock()
 	return utils.Clone(peer.id)
 }
 
@@ -58,68 +71,175 @@ func (peer *peerImpl) TransactionPreValidation(tx *obc.Transaction) (*obc.Transa
 	//	peer.debug("Pre validating [%s].", tx.String())
 	peer.Debugf("Tx confdential level [%s].", tx.ConfidentialityLevel.String())
 
-	if tx.Cert != nil && tx.Signature != nil {
-		// Verify the transaction
-		// 1. Unmarshal cert
-		cert, err := primitives.DERToX509Certificate(tx.Cert)
-		if err != nil {
-			peer.Errorf("TransactionPreExecution: failed unmarshalling cert [%s].", err.Error())
+	if tx.Credential != nil {
+		err := peer.verifyTransactionCredential(tx)
+		auditTransaction(tx, nil, err)
+		return tx, err
+	}
+	if tx.Cert == nil {
+		return tx, utils.ErrTransactionCertificate
+	}
+	if tx.Signature == nil {
+		return tx, utils.ErrTransactionSignature
+	}
+
+	cert, err := peer.verifyTransactionCertificate(tx.Cert)
+	if err != nil {
+		auditTransaction(tx, nil, err)
+		return tx, err
+	}
+	if err := peer.verifyTransactionSignature(tx, cert); err != nil {
+		auditTransaction(tx, cert, err)
+		return tx, err
+	}
+	if tx.ConfidentialityLevel == obc.ConfidentialityLevel_PUBLIC {
+		if err := verifyTransactionChaincode(tx, cert); err != nil {
+			auditTransaction(tx, cert, err)
 			return tx, err
 		}
+	}
 
-		// Verify transaction certificate against root
-		// DER to x509
-		x509Cert, err := primitives.DERToX509Certificate(tx.Cert)
-		if err != nil {
-			peer.Debugf("Failed parsing certificate [% x]: [%s].", tx.Cert, err)
+	return tx, nil
+}
 
-			return tx, err
+// verifyTransactionChaincode returns an error if cert, the certificate of
+// tx, restricts the chaincodes it may invoke, as the TCA does for the members
+// of an affiliation, and the chaincode of tx is not one of them. The
+// chaincodeID of tx must not be encrypted. Deployments are not restricted.
+func verifyTransactionChaincode(tx *obc.Transaction, cert *x509.Certificate) error {
+	if tx.Type != obc.Transaction_CHAINCODE_INVOKE && tx.Type != obc.Transaction_CHAINCODE_QUERY {
+		return nil
+	}
+	raw, err := primitives.GetCriticalExtension(cert, primitives.TCertChaincodes)
+	if err != nil {
+		// Not restricted
+		return nil
+	}
+	var chaincodes []string
+	if _, err = asn1.Unmarshal(raw, &chaincodes); err != nil {
+		return err
+	}
+	name, err := getChaincodeName(tx)
+	if err != nil {
+		return err
+	}
+	for _, chaincode := range chaincodes {
+		if chaincode == name {
+			return nil
 		}
+	}
+	return utils.ErrChaincodeNotAllowed
+}
 
-		// 1. Get rid of the extensions that cannot be checked now
-		x509Cert.UnhandledCriticalExtensions = nil
-		// 2. Check against TCA certPool
-		if _, err = primitives.CheckCertAgainRoot(x509Cert, peer.tcaCertPool); err != nil {
-			peer.Warningf("Failed verifing certificate against TCA cert pool [%s].", err.Error())
-			// 3. Check against ECA certPool, if this check also fails then return an error
-			if _, err = primitives.CheckCertAgainRoot(x509Cert, peer.ecaCertPool); err != nil {
-				peer.Warningf("Failed verifing certificate against ECA cert pool [%s].", err.Error())
+// verifyTransactionCertificate checks the transaction certificate der
+// against the TCA and ECA roots and their revocation lists
+func (peer *peerImpl) verifyTransactionCertificate(der []byte) (*x509.Certificate, error) {
+	x509Cert, err := primitives.DERToX509Certificate(der)
+	if err != nil {
+		peer.Debugf("Failed parsing certificate [% x]: [%s].", der, err)
+
+		return nil, err
+	}
 
-				return tx, fmt.Errorf("Certificate has not been signed by a trusted authority. [%s]", err)
+	// 1. Get rid of the extensions that cannot be checked now
+	x509Cert.UnhandledCriticalExtensions = nil
+	// 2. Check against TCA certPool
+	crl := peer.tcaCRL
+	if _, err = primitives.CheckCertAgainRoot(x509Cert, peer.tcaCertPool); err != nil {
+		peer.Warningf("Failed verifing certificate against TCA cert pool [%s].", err.Error())
+		// 3. Check against ECA certPool, if this check also fails then return an error
+		if _, err = primitives.CheckCertAgainRoot(x509Cert, peer.ecaCertPool); err != nil {
+			peer.Warningf("Failed verifing certificate against ECA cert pool [%s].", err.Error())
+
+			if invalid, ok := err.(x509.CertificateInvalidError); ok && invalid.Reason == x509.Expired {
+				return nil, utils.ErrCertificateExpired
 			}
+			return nil, fmt.Errorf("Certificate has not been signed by a trusted authority. [%s]", err)
 		}
+		crl = peer.ecaCRL
+	}
+	// 4. Check that the certificate has not been revoked by its authority
+	if crl.isRevoked(x509Cert.SerialNumber) {
+		peer.Warningf("Certificate [%s] has been revoked.", x509Cert.SerialNumber)
 
-		// 3. Marshall tx without signature
-		signature := tx.Signature
-		tx.Signature = nil
-		rawTx, err := proto.Marshal(tx)
-		if err != nil {
-			peer.Errorf("TransactionPreExecution: failed marshaling tx [%s].", err.Error())
-			return tx, err
-		}
-		tx.Signature = signature
+		return nil, utils.ErrCertificateRevoked
+	}
 
-		// 2. Verify signature
-		ok, err := peer.verify(cert.PublicKey, rawTx, tx.Signature)
-		if err != nil {
-			peer.Errorf("TransactionPreExecution: failed marshaling tx [%s].", err.Error())
-			return tx, err
-		}
+	return x509Cert, nil
+}
 
-		if !ok {
-			return tx, utils.ErrInvalidTransactionSignature
-		}
-	} else {
-		if tx.Cert == nil {
-			return tx, utils.ErrTransactionCertificate
+// auditTransaction records the security event of the failed verification of
+// tx, if err is one. cert is the certificate of tx, nil if it could not be
+// verified.
+func auditTransaction(tx *obc.Transaction, cert *x509.Certificate, err error) {
+	var kind obc.SecurityEvent_Kind
+	switch err {
+	case utils.ErrInvalidTransactionSignature:
+		kind = obc.SecurityEvent_SIGNATURE_INVALID
+	case utils.ErrCertificateExpired:
+		kind = obc.SecurityEvent_CERTIFICATE_EXPIRED
+	case utils.ErrCertificateRevoked:
+		kind = obc.SecurityEvent_CERTIFICATE_REVOKED
+	case utils.ErrChaincodeNotAllowed:
+		kind = obc.SecurityEvent_ACCESS_DENIED
+	case nil, utils.ErrNotInitialized, utils.ErrTransactionCertificate, utils.ErrTransactionSignature, utils.ErrCredentialsDisabled:
+		return
+	default:
+		if cert != nil || tx.Cert == nil {
+			// Not a verification failure
+			return
 		}
+		kind = obc.SecurityEvent_CERTIFICATE_UNTRUSTED
+	}
 
-		if tx.Signature == nil {
-			return tx, utils.ErrTransactionSignature
-		}
+	identity := "anonymous credential"
+	if cert == nil && tx.Cert != nil {
+		cert, _ = primitives.DERToX509Certificate(tx.Cert)
+	}
+	if cert != nil {
+		identity = certificateIdentity(cert)
+	}
+	audit.Record(kind, identity, "transaction "+tx.Uuid, err.Error())
+}
+
+// auditMessage records the security event of the failed verification of the
+// signature of a message of the peer vkID, which signed it with cert.
+func auditMessage(vkID []byte, cert *x509.Certificate, err error) {
+	kind := obc.SecurityEvent_SIGNATURE_INVALID
+	if err == utils.ErrCertificateRevoked {
+		kind = obc.SecurityEvent_CERTIFICATE_REVOKED
 	}
+	audit.Record(kind, certificateIdentity(cert), fmt.Sprintf("message of peer %x", vkID), err.Error())
+}
 
-	return tx, nil
+// certificateIdentity describes the holder of cert in security events
+func certificateIdentity(cert *x509.Certificate) string {
+	return fmt.Sprintf("%s (serial %s)", cert.Subject.CommonName, cert.SerialNumber)
+}
+
+// verifyTransactionSignature verifies the signature of tx under the
+// verification key of cert. tx is left untouched.
+func (peer *peerImpl) verifyTransactionSignature(tx *obc.Transaction, cert *x509.Certificate) error {
+	// Marshall tx without signature nor trace context
+	unsigned := *tx
+	unsigned.Signature = nil
+	unsigned.TraceContext = ""
+	rawTx, err := proto.Marshal(&unsigned)
+	if err != nil {
+		peer.Errorf("TransactionPreExecution: failed marshaling tx [%s].", err.Error())
+		return err
+	}
+
+	ok, err := peer.verify(cert.PublicKey, rawTx, tx.Signature)
+	if err != nil {
+		peer.Errorf("TransactionPreExecution: failed verifying signature [%s].", err.Error())
+		return err
+	}
+	if !ok {
+		return utils.ErrInvalidTransactionSignature
+	}
+
+	return nil
 }
 
 // TransactionPreValidation verifies that the transaction is
@@ -157,7 +277,14 @@ func (peer *peerImpl) Verify(vkID, signature, message []byte) error {
 		return err
 	}
 
-	vk := cert.PublicKey.(*ecdsa.PublicKey)
+	if peer.ecaCRL.isRevoked(cert.SerialNumber) {
+		peer.Errorf("Enrollment certificate for [% x] has been revoked", vkID)
+		auditMessage(vkID, cert, utils.ErrCertificateRevoked)
+
+		return utils.ErrCertificateRevoked
+	}
+
+	vk := cert.PublicKey
 
 	ok, err := peer.verify(vk, message, signature)
 	if err != nil {
@@ -168,6 +295,7 @@ func (peer *peerImpl) Verify(vkID, signature, message []byte) error {
 
 	if !ok {
 		peer.Errorf("Failed invalid signature for [% x]", vkID)
+		auditMessage(vkID, cert, utils.ErrInvalidSignature)
 
 		return utils.ErrInvalidSignature
 	}
@@ -216,10 +344,18 @@ func (peer *peerImpl) init(eType NodeType, id string, pwd []byte, initFunc inita
 		peer.nodeEnrollmentCertificates = make(map[string]*x509.Certificate)
 
 		if initFunc != nil {
-			return initFunc(eType, id, pwd)
+			if err := initFunc(eType, id, pwd); err != nil {
+				return err
+			}
 		}
 
-		return nil
+		// Certificate revocation lists
+		if err := peer.initCRLs(); err != nil {
+			return err
+		}
+
+		// Key of the anonymous credentials
+		return peer.initIssuerKey()
 	}
 
 	if err := peer.nodeImpl.init(eType, id, pwd, peerInitFunc); err != nil {
@@ -230,5 +366,7 @@ func (peer *peerImpl) init(eType NodeType, id string, pwd []byte, initFunc inita
 }
 
 func (peer *peerImpl) close() error {
+	peer.closeCRLs()
+
 	return peer.nodeImpl.close()
 }
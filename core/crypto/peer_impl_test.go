@@ -0,0 +1,113 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/audit"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+func TestVerifyTransactionChaincode(t *testing.T) {
+	issuer, issuerPriv := newTestCA(t)
+	newCert := func(exts ...pkix.Extension) *x509.Certificate {
+		cert, _ := newTestCertificate(t, &x509.Certificate{
+			SerialNumber:    big.NewInt(2),
+			Subject:         pkix.Name{CommonName: "Transaction Certificate"},
+			ExtraExtensions: exts,
+		}, issuer, issuerPriv)
+		return cert
+	}
+	newTx := func(txType obc.Transaction_Type, name string) *obc.Transaction {
+		chaincodeID, err := proto.Marshal(&obc.ChaincodeID{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &obc.Transaction{Type: txType, ChaincodeID: chaincodeID}
+	}
+
+	value, err := asn1.Marshal([]string{"mycc", "othercc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restricted := newCert(pkix.Extension{Id: primitives.TCertChaincodes, Value: value})
+	unrestricted := newCert()
+
+	for _, txType := range []obc.Transaction_Type{obc.Transaction_CHAINCODE_INVOKE, obc.Transaction_CHAINCODE_QUERY} {
+		if err := verifyTransactionChaincode(newTx(txType, "mycc"), restricted); err != nil {
+			t.Fatalf("Expected %s of an allowed chaincode to be accepted, got [%s]", txType, err)
+		}
+		if err := verifyTransactionChaincode(newTx(txType, "evilcc"), restricted); err != utils.ErrChaincodeNotAllowed {
+			t.Fatalf("Expected %s of another chaincode to be refused, got [%v]", txType, err)
+		}
+		if err := verifyTransactionChaincode(newTx(txType, "evilcc"), unrestricted); err != nil {
+			t.Fatalf("Expected %s with an unrestricted certificate to be accepted, got [%s]", txType, err)
+		}
+	}
+	if err := verifyTransactionChaincode(newTx(obc.Transaction_CHAINCODE_DEPLOY, "evilcc"), restricted); err != nil {
+		t.Fatalf("Expected deployments not to be restricted, got [%s]", err)
+	}
+}
+
+func TestAuditTransaction(t *testing.T) {
+	issuer, issuerPriv := newTestCA(t)
+	cert, _ := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "alice"},
+		NotBefore:    time.Now().Add(-2 * time.Hour),
+		NotAfter:     time.Now().Add(-time.Hour),
+	}, issuer, issuerPriv)
+	raw := cert.Raw
+
+	pool := x509.NewCertPool()
+	pool.AddCert(issuer)
+	peer := &peerImpl{nodeImpl: &nodeImpl{conf: &configuration{}, tcaCertPool: pool, ecaCertPool: pool}}
+	if _, err := peer.verifyTransactionCertificate(raw); err != utils.ErrCertificateExpired {
+		t.Fatalf("Expected the expired certificate to be refused as such, got [%v]", err)
+	}
+
+	count := func(kind obc.SecurityEvent_Kind) uint64 {
+		for _, c := range audit.Counts() {
+			if c.Kind == kind.String() {
+				return c.Count
+			}
+		}
+		return 0
+	}
+	expired, invalid := count(obc.SecurityEvent_CERTIFICATE_EXPIRED), count(obc.SecurityEvent_SIGNATURE_INVALID)
+
+	tx := &obc.Transaction{Uuid: "tx1", Cert: raw}
+	auditTransaction(tx, nil, utils.ErrCertificateExpired)
+	auditTransaction(tx, nil, utils.ErrTransactionSignature)
+	auditTransaction(&obc.Transaction{Uuid: "tx2", Credential: []byte{1}}, nil, utils.ErrInvalidTransactionSignature)
+
+	if count(obc.SecurityEvent_CERTIFICATE_EXPIRED) != expired+1 {
+		t.Error("Expected the expired certificate to be recorded")
+	}
+	if count(obc.SecurityEvent_SIGNATURE_INVALID) != invalid+1 {
+		t.Error("Expected the invalid credential presentation, and only it, to be recorded as an invalid signature")
+	}
+}
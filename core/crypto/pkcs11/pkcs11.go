@@ -0,0 +1,110 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkcs11
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// Config identifies the token holding the keys and how to log in to it
+type Config struct {
+	// Library is the path of the PKCS#11 module of the HSM, e.g.
+	// /usr/lib/softhsm/libsofthsm2.so
+	Library string
+	// Token is the label of the token holding the keys
+	Token string
+	// PIN is the PIN of the user of the token
+	PIN string
+}
+
+// GetConfig returns the configuration under prefix, e.g. security.pkcs11,
+// or nil if no library is configured, keys then being kept in files
+func GetConfig(prefix string) (*Config, error) {
+	library := viper.GetString(prefix + ".library")
+	if library == "" {
+		return nil, nil
+	}
+	conf := &Config{
+		Library: library,
+		Token:   viper.GetString(prefix + ".token"),
+		PIN:     viper.GetString(prefix + ".pin"),
+	}
+	if conf.Token == "" {
+		return nil, fmt.Errorf("%s.token is required with %s.library", prefix, prefix)
+	}
+	return conf, nil
+}
+
+// Attributes of the EC keys on the token, DER encoded
+const (
+	attributeECParams = 0x180
+	attributeECPoint  = 0x181
+)
+
+var namedCurves = []struct {
+	oid   asn1.ObjectIdentifier
+	curve elliptic.Curve
+}{
+	{asn1.ObjectIdentifier{1, 3, 132, 0, 33}, elliptic.P224()},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}, elliptic.P256()},
+	{asn1.ObjectIdentifier{1, 3, 132, 0, 34}, elliptic.P384()},
+	{asn1.ObjectIdentifier{1, 3, 132, 0, 35}, elliptic.P521()},
+}
+
+// marshalCurve returns the CKA_EC_PARAMS attribute naming curve
+func marshalCurve(curve elliptic.Curve) ([]byte, error) {
+	for _, named := range namedCurves {
+		if named.curve == curve {
+			return asn1.Marshal(named.oid)
+		}
+	}
+	return nil, fmt.Errorf("Unsupported curve %s", curve.Params().Name)
+}
+
+// parsePublicKey returns the ECDSA public key with the CKA_EC_PARAMS and
+// CKA_EC_POINT attributes params and point
+func parsePublicKey(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return nil, fmt.Errorf("Error parsing the curve of the key: %s", err)
+	}
+	var curve elliptic.Curve
+	for _, named := range namedCurves {
+		if named.oid.Equal(oid) {
+			curve = named.curve
+		}
+	}
+	if curve == nil {
+		return nil, fmt.Errorf("Unsupported curve %s", oid)
+	}
+	// The point is wrapped in an octet string, though some tokens omit it
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) != 0 {
+		raw = point
+	}
+	x, y := elliptic.Unmarshal(curve, raw)
+	if x == nil {
+		return nil, errors.New("Error parsing the point of the key")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkcs11
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/spf13/viper"
+)
+
+func TestGetConfig(t *testing.T) {
+	defer viper.Reset()
+	if conf, err := GetConfig("security.pkcs11"); conf != nil || err != nil {
+		t.Fatalf("Expected no token without a library, got %v, %v", conf, err)
+	}
+
+	viper.Set("security.pkcs11.library", "/usr/lib/softhsm/libsofthsm2.so")
+	if _, err := GetConfig("security.pkcs11"); err == nil {
+		t.Fatal("Expected the token to be required")
+	}
+	viper.Set("security.pkcs11.token", "fabric")
+	viper.Set("security.pkcs11.pin", "98765432")
+	conf, err := GetConfig("security.pkcs11")
+	if err != nil || conf.Token != "fabric" || conf.PIN != "98765432" {
+		t.Errorf("Unexpected configuration %v, %v", conf, err)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 34})
+	raw := elliptic.Marshal(key.Curve, key.X, key.Y)
+	point, _ := asn1.Marshal(raw)
+
+	// Tokens return the point wrapped in an octet string, or not
+	for _, p := range [][]byte{point, raw} {
+		public, err := parsePublicKey(params, p)
+		if err != nil {
+			t.Fatalf("Error parsing the public key: %s", err)
+		}
+		if public.Curve != elliptic.P384() || public.X.Cmp(key.X) != 0 || public.Y.Cmp(key.Y) != 0 {
+			t.Errorf("Expected the public key to be parsed as generated")
+		}
+	}
+
+	unknown, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 10})
+	if _, err := parsePublicKey(unknown, point); err == nil {
+		t.Errorf("Expected an unsupported curve to be rejected")
+	}
+}
+
+func TestMarshalCurve(t *testing.T) {
+	params, err := marshalCurve(elliptic.P384())
+	if err != nil {
+		t.Fatalf("Error marshalling the curve: %s", err)
+	}
+	expected, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 34})
+	if string(params) != string(expected) {
+		t.Fatalf("Unexpected curve parameters %x", params)
+	}
+	custom := &elliptic.CurveParams{Name: "custom", BitSize: 256}
+	if _, err := marshalCurve(custom); err == nil {
+		t.Fatal("Expected an unnamed curve to be unsupported")
+	}
+}
+
+func TestProviderRequiresLibrary(t *testing.T) {
+	defer viper.Reset()
+	if err := primitives.InitProvider(ProviderName, "security"); err == nil {
+		t.Fatal("Expected the provider to require a library")
+	}
+
+	viper.Set("security.pkcs11.library", "/usr/lib/softhsm/libsofthsm2.so")
+	viper.Set("security.pkcs11.token", "fabric")
+	if err := primitives.InitProvider(ProviderName, "security"); err != nil {
+		t.Fatalf("Failed initializing the provider [%s]", err)
+	}
+	defer primitives.InitProvider(primitives.SoftwareProviderName, "security")
+	if primitives.GetProvider().Name() != ProviderName {
+		t.Fatalf("Expected the %s provider", ProviderName)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkcs11
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+)
+
+// ProviderName is the name of the crypto provider keeping the signing keys
+// on a PKCS#11 token
+const ProviderName = "PKCS11"
+
+func init() {
+	primitives.RegisterProvider(ProviderName, func(prefix string) (primitives.Provider, error) {
+		conf, err := GetConfig(prefix + ".pkcs11")
+		if err != nil {
+			return nil, err
+		}
+		if conf == nil {
+			return nil, fmt.Errorf("%s.pkcs11.library is required by the %s crypto provider", prefix, ProviderName)
+		}
+		return NewProvider(conf), nil
+	})
+}
+
+// provider generates the signing keys on the token of conf and signs with
+// them there. The operations with public and secret keys are performed in
+// software.
+type provider struct {
+	*primitives.SoftwareProvider
+	conf *Config
+}
+
+// NewProvider returns a crypto provider keeping the signing keys on the
+// token of conf
+func NewProvider(conf *Config) primitives.Provider {
+	return &provider{primitives.NewSoftwareProvider(), conf}
+}
+
+// Name returns PKCS11
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+// GenerateKey generates an ECDSA key pair on the default curve, labeled
+// label on the token
+func (p *provider) GenerateKey(label string) (crypto.Signer, error) {
+	return GenerateSigner(p.conf, label, primitives.GetDefaultCurve())
+}
+
+// GetKey returns the ECDSA key labeled label on the token
+func (p *provider) GetKey(label string) (crypto.Signer, error) {
+	return NewSigner(p.conf, label)
+}
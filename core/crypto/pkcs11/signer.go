@@ -0,0 +1,407 @@
+//go:build pkcs11 && cgo
+// +build pkcs11,cgo
+
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkcs11
+
+/*
+#cgo LDFLAGS: -ldl
+
+#include <dlfcn.h>
+#include <stdlib.h>
+
+typedef unsigned long CK_ULONG;
+typedef CK_ULONG CK_RV;
+typedef CK_ULONG CK_SLOT_ID;
+typedef CK_ULONG CK_SESSION_HANDLE;
+typedef CK_ULONG CK_OBJECT_HANDLE;
+
+typedef struct { unsigned char major, minor; } CK_VERSION;
+typedef struct { CK_ULONG type; void *pValue; CK_ULONG ulValueLen; } CK_ATTRIBUTE;
+typedef struct { CK_ULONG mechanism; void *pParameter; CK_ULONG ulParameterLen; } CK_MECHANISM;
+
+typedef struct {
+	void *CreateMutex, *DestroyMutex, *LockMutex, *UnlockMutex;
+	CK_ULONG flags;
+	void *pReserved;
+} CK_C_INITIALIZE_ARGS;
+
+typedef struct {
+	unsigned char label[32], manufacturerID[32], model[16], serialNumber[16];
+	CK_ULONG flags;
+	CK_ULONG ulMaxSessionCount, ulSessionCount, ulMaxRwSessionCount, ulRwSessionCount;
+	CK_ULONG ulMaxPinLen, ulMinPinLen;
+	CK_ULONG ulTotalPublicMemory, ulFreePublicMemory, ulTotalPrivateMemory, ulFreePrivateMemory;
+	CK_VERSION hardwareVersion, firmwareVersion;
+	unsigned char utcTime[16];
+} CK_TOKEN_INFO;
+
+// The beginning of CK_FUNCTION_LIST, up to the last function used
+typedef struct {
+	CK_VERSION version;
+	CK_RV (*C_Initialize)(CK_C_INITIALIZE_ARGS *);
+	void *C_Finalize, *C_GetInfo, *C_GetFunctionList;
+	CK_RV (*C_GetSlotList)(unsigned char, CK_SLOT_ID *, CK_ULONG *);
+	void *C_GetSlotInfo;
+	CK_RV (*C_GetTokenInfo)(CK_SLOT_ID, CK_TOKEN_INFO *);
+	void *C_GetMechanismList, *C_GetMechanismInfo, *C_InitToken, *C_InitPIN, *C_SetPIN;
+	CK_RV (*C_OpenSession)(CK_SLOT_ID, CK_ULONG, void *, void *, CK_SESSION_HANDLE *);
+	void *C_CloseSession, *C_CloseAllSessions, *C_GetSessionInfo, *C_GetOperationState, *C_SetOperationState;
+	CK_RV (*C_Login)(CK_SESSION_HANDLE, CK_ULONG, unsigned char *, CK_ULONG);
+	void *C_Logout, *C_CreateObject, *C_CopyObject, *C_DestroyObject, *C_GetObjectSize;
+	CK_RV (*C_GetAttributeValue)(CK_SESSION_HANDLE, CK_OBJECT_HANDLE, CK_ATTRIBUTE *, CK_ULONG);
+	void *C_SetAttributeValue;
+	CK_RV (*C_FindObjectsInit)(CK_SESSION_HANDLE, CK_ATTRIBUTE *, CK_ULONG);
+	CK_RV (*C_FindObjects)(CK_SESSION_HANDLE, CK_OBJECT_HANDLE *, CK_ULONG, CK_ULONG *);
+	CK_RV (*C_FindObjectsFinal)(CK_SESSION_HANDLE);
+	void *C_EncryptInit, *C_Encrypt, *C_EncryptUpdate, *C_EncryptFinal;
+	void *C_DecryptInit, *C_Decrypt, *C_DecryptUpdate, *C_DecryptFinal;
+	void *C_DigestInit, *C_Digest, *C_DigestUpdate, *C_DigestKey, *C_DigestFinal;
+	CK_RV (*C_SignInit)(CK_SESSION_HANDLE, CK_MECHANISM *, CK_OBJECT_HANDLE);
+	CK_RV (*C_Sign)(CK_SESSION_HANDLE, unsigned char *, CK_ULONG, unsigned char *, CK_ULONG *);
+	void *C_SignUpdate, *C_SignFinal, *C_SignRecoverInit, *C_SignRecover;
+	void *C_VerifyInit, *C_Verify, *C_VerifyUpdate, *C_VerifyFinal, *C_VerifyRecoverInit, *C_VerifyRecover;
+	void *C_DigestEncryptUpdate, *C_DecryptDigestUpdate, *C_SignEncryptUpdate, *C_DecryptVerifyUpdate;
+	void *C_GenerateKey;
+	CK_RV (*C_GenerateKeyPair)(CK_SESSION_HANDLE, CK_MECHANISM *, CK_ATTRIBUTE *, CK_ULONG, CK_ATTRIBUTE *, CK_ULONG, CK_OBJECT_HANDLE *, CK_OBJECT_HANDLE *);
+} CK_FUNCTION_LIST;
+
+#define CKF_OS_LOCKING_OK 0x2
+#define CKF_SERIAL_SESSION 0x4
+#define CKU_USER 1
+#define CKA_CLASS 0x0
+#define CKA_TOKEN 0x1
+#define CKA_PRIVATE 0x2
+#define CKA_LABEL 0x3
+#define CKA_SENSITIVE 0x103
+#define CKA_SIGN 0x108
+#define CKA_VERIFY 0x10A
+#define CKA_EXTRACTABLE 0x162
+#define CKA_EC_PARAMS 0x180
+#define CKM_EC_KEY_PAIR_GEN 0x1040
+#define CKM_ECDSA 0x1041
+#define CKR_USER_ALREADY_LOGGED_IN 0x100
+#define CKR_CRYPTOKI_ALREADY_INITIALIZED 0x191
+
+static CK_RV load(const char *library, CK_FUNCTION_LIST **functions) {
+	void *handle = dlopen(library, RTLD_NOW);
+	if (handle == NULL) {
+		return -1;
+	}
+	CK_RV (*getFunctionList)(CK_FUNCTION_LIST **) = dlsym(handle, "C_GetFunctionList");
+	if (getFunctionList == NULL) {
+		return -1;
+	}
+	CK_RV rv = getFunctionList(functions);
+	if (rv != 0) {
+		return rv;
+	}
+	CK_C_INITIALIZE_ARGS args = {NULL, NULL, NULL, NULL, CKF_OS_LOCKING_OK, NULL};
+	rv = (*functions)->C_Initialize(&args);
+	return rv == CKR_CRYPTOKI_ALREADY_INITIALIZED ? 0 : rv;
+}
+
+static CK_RV getSlotList(CK_FUNCTION_LIST *f, CK_SLOT_ID *slots, CK_ULONG *count) {
+	return f->C_GetSlotList(1, slots, count);
+}
+
+static CK_RV getTokenLabel(CK_FUNCTION_LIST *f, CK_SLOT_ID slot, unsigned char *label) {
+	CK_TOKEN_INFO info;
+	CK_RV rv = f->C_GetTokenInfo(slot, &info);
+	if (rv == 0) {
+		for (int i = 0; i < 32; i++) {
+			label[i] = info.label[i];
+		}
+	}
+	return rv;
+}
+
+static CK_RV openSession(CK_FUNCTION_LIST *f, CK_SLOT_ID slot, unsigned char *pin, CK_ULONG pinLen, CK_SESSION_HANDLE *session) {
+	CK_RV rv = f->C_OpenSession(slot, CKF_SERIAL_SESSION, NULL, NULL, session);
+	if (rv != 0) {
+		return rv;
+	}
+	rv = f->C_Login(*session, CKU_USER, pin, pinLen);
+	return rv == CKR_USER_ALREADY_LOGGED_IN ? 0 : rv;
+}
+
+static CK_RV findObject(CK_FUNCTION_LIST *f, CK_SESSION_HANDLE session, CK_ULONG class, unsigned char *label, CK_ULONG labelLen, CK_OBJECT_HANDLE *object, CK_ULONG *count) {
+	CK_ATTRIBUTE template[2] = {{CKA_CLASS, &class, sizeof(class)}, {CKA_LABEL, label, labelLen}};
+	CK_RV rv = f->C_FindObjectsInit(session, template, 2);
+	if (rv != 0) {
+		return rv;
+	}
+	rv = f->C_FindObjects(session, object, 1, count);
+	CK_RV final = f->C_FindObjectsFinal(session);
+	return rv != 0 ? rv : final;
+}
+
+static CK_RV getAttribute(CK_FUNCTION_LIST *f, CK_SESSION_HANDLE session, CK_OBJECT_HANDLE object, CK_ULONG type, unsigned char *value, CK_ULONG *valueLen) {
+	CK_ATTRIBUTE attribute = {type, value, *valueLen};
+	CK_RV rv = f->C_GetAttributeValue(session, object, &attribute, 1);
+	*valueLen = attribute.ulValueLen;
+	return rv;
+}
+
+static CK_RV sign(CK_FUNCTION_LIST *f, CK_SESSION_HANDLE session, CK_OBJECT_HANDLE key, unsigned char *digest, CK_ULONG digestLen, unsigned char *signature, CK_ULONG *signatureLen) {
+	CK_MECHANISM mechanism = {CKM_ECDSA, NULL, 0};
+	CK_RV rv = f->C_SignInit(session, &mechanism, key);
+	if (rv != 0) {
+		return rv;
+	}
+	return f->C_Sign(session, digest, digestLen, signature, signatureLen);
+}
+
+static CK_RV generateKeyPair(CK_FUNCTION_LIST *f, CK_SESSION_HANDLE session, unsigned char *params, CK_ULONG paramsLen, unsigned char *label, CK_ULONG labelLen, CK_OBJECT_HANDLE *public, CK_OBJECT_HANDLE *private) {
+	unsigned char yes = 1, no = 0;
+	CK_MECHANISM mechanism = {CKM_EC_KEY_PAIR_GEN, NULL, 0};
+	CK_ATTRIBUTE publicTemplate[4] = {
+		{CKA_TOKEN, &yes, 1}, {CKA_VERIFY, &yes, 1}, {CKA_EC_PARAMS, params, paramsLen}, {CKA_LABEL, label, labelLen}};
+	CK_ATTRIBUTE privateTemplate[6] = {
+		{CKA_TOKEN, &yes, 1}, {CKA_PRIVATE, &yes, 1}, {CKA_SENSITIVE, &yes, 1}, {CKA_EXTRACTABLE, &no, 1}, {CKA_SIGN, &yes, 1}, {CKA_LABEL, label, labelLen}};
+	return f->C_GenerateKeyPair(session, &mechanism, publicTemplate, 4, privateTemplate, 6, public, private);
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"unsafe"
+)
+
+// Classes of the objects on the token
+const (
+	classPublicKey  = 2
+	classPrivateKey = 3
+)
+
+// pkcs11Error reports the return value of a failed PKCS#11 call
+type pkcs11Error struct {
+	call string
+	rv   C.CK_RV
+}
+
+func (e *pkcs11Error) Error() string {
+	return fmt.Sprintf("PKCS#11 %s failed with 0x%x", e.call, uint64(e.rv))
+}
+
+var (
+	modulesLock sync.Mutex
+	modules     = make(map[string]*C.CK_FUNCTION_LIST)
+)
+
+// loadModule loads and initializes the PKCS#11 module library once
+func loadModule(library string) (*C.CK_FUNCTION_LIST, error) {
+	modulesLock.Lock()
+	defer modulesLock.Unlock()
+	if functions, ok := modules[library]; ok {
+		return functions, nil
+	}
+	path := C.CString(library)
+	defer C.free(unsafe.Pointer(path))
+	var functions *C.CK_FUNCTION_LIST
+	if rv := C.load(path, &functions); rv != 0 {
+		if rv == ^C.CK_RV(0) {
+			return nil, fmt.Errorf("Error loading the PKCS#11 module %s", library)
+		}
+		return nil, &pkcs11Error{"C_Initialize", rv}
+	}
+	modules[library] = functions
+	return functions, nil
+}
+
+// findSlot returns the slot holding the token labeled token
+func findSlot(functions *C.CK_FUNCTION_LIST, token string) (C.CK_SLOT_ID, error) {
+	var count C.CK_ULONG
+	if rv := C.getSlotList(functions, nil, &count); rv != 0 {
+		return 0, &pkcs11Error{"C_GetSlotList", rv}
+	}
+	if count == 0 {
+		return 0, errors.New("No PKCS#11 token is present")
+	}
+	slots := make([]C.CK_SLOT_ID, count)
+	if rv := C.getSlotList(functions, &slots[0], &count); rv != 0 {
+		return 0, &pkcs11Error{"C_GetSlotList", rv}
+	}
+	label := make([]byte, 32)
+	for _, slot := range slots[:count] {
+		if rv := C.getTokenLabel(functions, slot, (*C.uchar)(&label[0])); rv != 0 {
+			return 0, &pkcs11Error{"C_GetTokenInfo", rv}
+		}
+		// Labels are padded with blanks
+		if string(bytes.TrimRight(label, " ")) == token {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("No PKCS#11 token is labeled %s", token)
+}
+
+// signer signs with a private key which does not leave the token
+type signer struct {
+	// Operations on a session are serialized
+	lock      sync.Mutex
+	functions *C.CK_FUNCTION_LIST
+	session   C.CK_SESSION_HANDLE
+	key       C.CK_OBJECT_HANDLE
+	public    *ecdsa.PublicKey
+}
+
+// openSigner opens a session with the token of conf, logged in as its user
+func openSigner(conf *Config) (*signer, error) {
+	functions, err := loadModule(conf.Library)
+	if err != nil {
+		return nil, err
+	}
+	slot, err := findSlot(functions, conf.Token)
+	if err != nil {
+		return nil, err
+	}
+	s := &signer{functions: functions}
+	pin := []byte(conf.PIN)
+	if len(pin) == 0 {
+		pin = []byte{0}
+	}
+	if rv := C.openSession(functions, slot, (*C.uchar)(&pin[0]), C.CK_ULONG(len(conf.PIN)), &s.session); rv != 0 {
+		return nil, &pkcs11Error{"C_Login", rv}
+	}
+	return s, nil
+}
+
+// NewSigner returns a signer with the ECDSA private key labeled label on
+// the token of conf, the public key of the same label being its public key
+func NewSigner(conf *Config, label string) (crypto.Signer, error) {
+	s, err := openSigner(conf)
+	if err != nil {
+		return nil, err
+	}
+	if s.key, err = s.findObject(classPrivateKey, label); err != nil {
+		return nil, err
+	}
+	public, err := s.findObject(classPublicKey, label)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.readPublicKey(public, label); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GenerateSigner generates on the token of conf an ECDSA key pair on curve,
+// labeled label, and returns a signer with its private key, which never
+// leaves the token
+func GenerateSigner(conf *Config, label string, curve elliptic.Curve) (crypto.Signer, error) {
+	if label == "" {
+		return nil, errors.New("The label of the PKCS#11 key is empty")
+	}
+	params, err := marshalCurve(curve)
+	if err != nil {
+		return nil, err
+	}
+	s, err := openSigner(conf)
+	if err != nil {
+		return nil, err
+	}
+	raw := []byte(label)
+	var public C.CK_OBJECT_HANDLE
+	if rv := C.generateKeyPair(s.functions, s.session, (*C.uchar)(&params[0]), C.CK_ULONG(len(params)), (*C.uchar)(&raw[0]), C.CK_ULONG(len(raw)), &public, &s.key); rv != 0 {
+		return nil, &pkcs11Error{"C_GenerateKeyPair", rv}
+	}
+	if err = s.readPublicKey(public, label); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// readPublicKey reads the public key of the signer from the object public
+func (s *signer) readPublicKey(public C.CK_OBJECT_HANDLE, label string) error {
+	params, err := s.getAttribute(public, attributeECParams)
+	if err != nil {
+		return err
+	}
+	point, err := s.getAttribute(public, attributeECPoint)
+	if err != nil {
+		return err
+	}
+	if s.public, err = parsePublicKey(params, point); err != nil {
+		return fmt.Errorf("Error reading the public key labeled %s: %s", label, err)
+	}
+	return nil
+}
+
+func (s *signer) findObject(class C.CK_ULONG, label string) (C.CK_OBJECT_HANDLE, error) {
+	if label == "" {
+		return 0, errors.New("The label of the PKCS#11 key is empty")
+	}
+	raw := []byte(label)
+	var object C.CK_OBJECT_HANDLE
+	var count C.CK_ULONG
+	if rv := C.findObject(s.functions, s.session, class, (*C.uchar)(&raw[0]), C.CK_ULONG(len(raw)), &object, &count); rv != 0 {
+		return 0, &pkcs11Error{"C_FindObjects", rv}
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("No PKCS#11 key of class %d is labeled %s", class, label)
+	}
+	return object, nil
+}
+
+func (s *signer) getAttribute(object C.CK_OBJECT_HANDLE, attribute C.CK_ULONG) ([]byte, error) {
+	value := make([]byte, 256)
+	length := C.CK_ULONG(len(value))
+	if rv := C.getAttribute(s.functions, s.session, object, attribute, (*C.uchar)(&value[0]), &length); rv != 0 {
+		return nil, &pkcs11Error{"C_GetAttributeValue", rv}
+	}
+	return value[:length], nil
+}
+
+// Public returns the public key of the signer
+func (s *signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign signs digest, returning the ASN.1 encoded signature as
+// ecdsa.PrivateKey does
+func (s *signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if len(digest) == 0 {
+		return nil, errors.New("The digest to sign is empty")
+	}
+	// The token returns r and s concatenated
+	signature := make([]byte, 2*((s.public.Params().BitSize+7)/8))
+	length := C.CK_ULONG(len(signature))
+
+	s.lock.Lock()
+	rv := C.sign(s.functions, s.session, s.key, (*C.uchar)(&digest[0]), C.CK_ULONG(len(digest)), (*C.uchar)(&signature[0]), &length)
+	s.lock.Unlock()
+	if rv != 0 {
+		return nil, &pkcs11Error{"C_Sign", rv}
+	}
+
+	half := int(length) / 2
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{new(big.Int).SetBytes(signature[:half]), new(big.Int).SetBytes(signature[half:length])})
+}
@@ -0,0 +1,38 @@
+//go:build !pkcs11 || !cgo
+// +build !pkcs11 !cgo
+
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkcs11
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"errors"
+)
+
+// NewSigner returns an error as this binary was built without the pkcs11
+// build tag
+func NewSigner(conf *Config, label string) (crypto.Signer, error) {
+	return nil, errors.New("PKCS#11 is not supported by this build, build with -tags pkcs11")
+}
+
+// GenerateSigner returns an error as this binary was built without the
+// pkcs11 build tag
+func GenerateSigner(conf *Config, label string, curve elliptic.Curve) (crypto.Signer, error) {
+	return nil, errors.New("PKCS#11 is not supported by this build, build with -tags pkcs11")
+}
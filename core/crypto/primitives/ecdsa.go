@@ -17,6 +17,7 @@ limitations under the License.
 package primitives
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"encoding/asn1"
@@ -35,7 +36,19 @@ func NewECDSAKey() (*ecdsa.PrivateKey, error) {
 
 // ECDSASignDirect signs
 func ECDSASignDirect(signKey interface{}, msg []byte) (*big.Int, *big.Int, error) {
-	temp := signKey.(*ecdsa.PrivateKey)
+	temp, ok := signKey.(*ecdsa.PrivateKey)
+	if !ok {
+		// The key is held elsewhere, e.g. by an HSM
+		raw, err := signKey.(crypto.Signer).Sign(rand.Reader, Hash(msg), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		signature := new(ECDSASignature)
+		if _, err := asn1.Unmarshal(raw, signature); err != nil {
+			return nil, nil, err
+		}
+		return signature.R, signature.S, nil
+	}
 	h := Hash(msg)
 	r, s, err := ecdsa.Sign(rand.Reader, temp, h)
 	if err != nil {
@@ -47,7 +60,11 @@ func ECDSASignDirect(signKey interface{}, msg []byte) (*big.Int, *big.Int, error
 
 // ECDSASign signs
 func ECDSASign(signKey interface{}, msg []byte) ([]byte, error) {
-	temp := signKey.(*ecdsa.PrivateKey)
+	temp, ok := signKey.(*ecdsa.PrivateKey)
+	if !ok {
+		// The key is held elsewhere, e.g. by an HSM
+		return signKey.(crypto.Signer).Sign(rand.Reader, Hash(msg), nil)
+	}
 	h := Hash(msg)
 	r, s, err := ecdsa.Sign(rand.Reader, temp, h)
 	if err != nil {
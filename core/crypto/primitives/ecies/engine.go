@@ -33,22 +33,22 @@ import (
 	"golang.org/x/crypto/hkdf"
 )
 
-func aesEncrypt(key, plain []byte) ([]byte, error) {
+// aesEncrypt encrypts plain into text, of length aes.BlockSize+len(plain)
+func aesEncrypt(text, key, plain []byte) error {
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	text := make([]byte, aes.BlockSize+len(plain))
 	iv := text[:aes.BlockSize]
 	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return nil, err
+		return err
 	}
 
 	cfb := cipher.NewCFBEncrypter(block, iv)
 	cfb.XORKeyStream(text[aes.BlockSize:], plain)
 
-	return text, nil
+	return nil
 }
 
 func aesDecrypt(key, text []byte) ([]byte, error) {
@@ -105,9 +105,18 @@ func eciesEncrypt(rand io.Reader, pub *ecdsa.PublicKey, s1, s2 []byte, plain []b
 		return nil, err
 	}
 
+	// Output R,EM,D. EM is written in place, so that large messages are
+	// not copied
+	hLen := primitives.GetDefaultHash()().Size()
+	ciphertext := make([]byte, len(Rb)+aes.BlockSize+len(plain)+hLen)
+	copy(ciphertext, Rb)
+	EM := ciphertext[len(Rb) : len(ciphertext)-hLen]
+
 	// Use the encryption operation of the symmetric encryption scheme
 	// to encrypt m under EK as ciphertext EM
-	EM, err := aesEncrypt(kE, plain)
+	if err = aesEncrypt(EM, kE, plain); err != nil {
+		return nil, err
+	}
 
 	// Use the tagging operation of the MAC scheme to compute
 	// the tag D on EM || s2
@@ -116,16 +125,7 @@ func eciesEncrypt(rand io.Reader, pub *ecdsa.PublicKey, s1, s2 []byte, plain []b
 	if len(s2) > 0 {
 		mac.Write(s2)
 	}
-	D := mac.Sum(nil)
-
-	// Output R,EM,D
-	ciphertext := make([]byte, len(Rb)+len(EM)+len(D))
-	//fmt.Printf("Rb %s\n", utils.EncodeBase64(Rb))
-	//fmt.Printf("EM %s\n", utils.EncodeBase64(EM))
-	//fmt.Printf("D %s\n", utils.EncodeBase64(D))
-	copy(ciphertext, Rb)
-	copy(ciphertext[len(Rb):], EM)
-	copy(ciphertext[len(Rb)+len(EM):], D)
+	mac.Sum(ciphertext[len(ciphertext)-hLen : len(ciphertext)-hLen])
 
 	return ciphertext, nil
 }
@@ -0,0 +1,185 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives
+
+import (
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Ed25519 signatures are the concatenation of the point R and the scalar S,
+// of ed25519.SignatureSize/2 bytes each
+const ed25519HalfSize = ed25519.SignatureSize / 2
+
+// oidEd25519 identifies Ed25519 keys in PKCS#8 and SubjectPublicKeyInfo
+// structures, and Ed25519 signatures, see RFC 8410
+var oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+// pkixPublicKey is the SubjectPublicKeyInfo structure of a public key, see
+// RFC 5280
+type pkixPublicKey struct {
+	Algo      pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// NewEd25519Key generates a new Ed25519 key
+func NewEd25519Key() (ed25519.PrivateKey, error) {
+	if err := checkFIPSApproved(); err != nil {
+		return nil, err
+	}
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	return priv, err
+}
+
+// IsEd25519Key returns true if key is an Ed25519 private or public key
+func IsEd25519Key(key interface{}) bool {
+	switch key.(type) {
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return true
+	}
+	return false
+}
+
+// Ed25519Sign signs msg with the Ed25519 key signKey. Unlike ECDSA, the
+// message is signed as is, without being hashed first.
+func Ed25519Sign(signKey interface{}, msg []byte) ([]byte, error) {
+	if err := checkFIPSApproved(); err != nil {
+		return nil, err
+	}
+	temp, ok := signKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, utils.ErrInvalidKey
+	}
+
+	return ed25519.Sign(temp, msg), nil
+}
+
+// Ed25519SignDirect signs msg with the Ed25519 key signKey, returning the
+// two halves R and S of the signature as integers, like ECDSASignDirect
+func Ed25519SignDirect(signKey interface{}, msg []byte) (*big.Int, *big.Int, error) {
+	sigma, err := Ed25519Sign(signKey, msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := new(big.Int).SetBytes(sigma[:ed25519HalfSize])
+	s := new(big.Int).SetBytes(sigma[ed25519HalfSize:])
+	return r, s, nil
+}
+
+// Ed25519Verify verifies the Ed25519 signature of msg
+func Ed25519Verify(verKey interface{}, msg, signature []byte) (bool, error) {
+	if err := checkFIPSApproved(); err != nil {
+		return false, err
+	}
+	temp, ok := verKey.(ed25519.PublicKey)
+	if !ok {
+		return false, utils.ErrInvalidKey
+	}
+	if len(signature) != ed25519.SignatureSize {
+		return false, errors.New("Invalid Ed25519 signature length.")
+	}
+
+	return ed25519.Verify(temp, msg, signature), nil
+}
+
+// Ed25519VerifyDirect verifies the signature of msg with the halves r and s
+// returned by Ed25519SignDirect
+func Ed25519VerifyDirect(verKey interface{}, msg []byte, r, s *big.Int) (bool, error) {
+	if r.Sign() < 0 || s.Sign() < 0 || r.BitLen() > 8*ed25519HalfSize || s.BitLen() > 8*ed25519HalfSize {
+		return false, errors.New("Invalid Ed25519 signature.")
+	}
+
+	sigma := make([]byte, ed25519.SignatureSize)
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+	copy(sigma[ed25519HalfSize-len(rBytes):ed25519HalfSize], rBytes)
+	copy(sigma[ed25519.SignatureSize-len(sBytes):], sBytes)
+	return Ed25519Verify(verKey, msg, sigma)
+}
+
+// marshalEd25519PrivateKey encodes key as a PKCS#8 structure holding its
+// seed, as described by RFC 8410
+func marshalEd25519PrivateKey(key ed25519.PrivateKey) ([]byte, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, utils.ErrInvalidKey
+	}
+	seed, err := asn1.Marshal(key[:ed25519.SeedSize])
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkcs8PrivateKey{
+		Algo:       pkix.AlgorithmIdentifier{Algorithm: oidEd25519},
+		PrivateKey: seed,
+	})
+}
+
+// parseEd25519PrivateKey decodes a PKCS#8 structure written by
+// marshalEd25519PrivateKey
+func parseEd25519PrivateKey(der []byte) (ed25519.PrivateKey, error) {
+	var pkcs8 pkcs8PrivateKey
+	if rest, err := asn1.Unmarshal(der, &pkcs8); err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, errors.New("Trailing data after the Ed25519 private key.")
+	}
+	if !pkcs8.Algo.Algorithm.Equal(oidEd25519) {
+		return nil, errors.New("Not an Ed25519 private key.")
+	}
+
+	var seed []byte
+	if _, err := asn1.Unmarshal(pkcs8.PrivateKey, &seed); err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New("Invalid Ed25519 private key length.")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// parseEd25519PublicKey decodes the SubjectPublicKeyInfo structure of an
+// Ed25519 key, as described by RFC 8410. crypto/x509 only parses these from
+// Go 1.13 on.
+func parseEd25519PublicKey(der []byte) (ed25519.PublicKey, error) {
+	var spki pkixPublicKey
+	if rest, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, errors.New("Trailing data after the Ed25519 public key.")
+	}
+	if !spki.Algo.Algorithm.Equal(oidEd25519) {
+		return nil, errors.New("Not an Ed25519 public key.")
+	}
+
+	key := spki.PublicKey.RightAlign()
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("Invalid Ed25519 public key length.")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// IsEd25519SignatureAlgorithm returns true if algo identifies Ed25519
+// signatures, which crypto/x509 only knows of from Go 1.13 on
+func IsEd25519SignatureAlgorithm(algo pkix.AlgorithmIdentifier) bool {
+	return algo.Algorithm.Equal(oidEd25519)
+}
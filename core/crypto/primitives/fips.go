@@ -0,0 +1,55 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNotFIPSApproved the algorithm is not approved in FIPS mode
+var ErrNotFIPSApproved = errors.New("Algorithm not FIPS-approved.")
+
+var (
+	fipsLock sync.RWMutex
+	fipsMode bool
+)
+
+// SetFIPSMode restricts the crypto layer to FIPS-approved algorithms: the
+// SHA2 and SHA3 hash families, ECDSA on the NIST curves, AES, HMAC and
+// HKDF. Ed25519 keys cannot be generated nor used in FIPS mode.
+func SetFIPSMode(enabled bool) {
+	fipsLock.Lock()
+	defer fipsLock.Unlock()
+	fipsMode = enabled
+}
+
+// IsFIPSMode returns true if the crypto layer is restricted to FIPS-approved
+// algorithms
+func IsFIPSMode() bool {
+	fipsLock.RLock()
+	defer fipsLock.RUnlock()
+	return fipsMode
+}
+
+// checkFIPSApproved returns ErrNotFIPSApproved in FIPS mode
+func checkFIPSApproved() error {
+	if IsFIPSMode() {
+		return ErrNotFIPSApproved
+	}
+	return nil
+}
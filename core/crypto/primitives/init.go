@@ -28,6 +28,8 @@ import (
 
 var (
 	initOnce sync.Once
+
+	securityLevel int
 )
 
 // Init SHA2
@@ -73,11 +75,33 @@ func SetSecurityLevel(algorithm string, level int) (err error) {
 	if err == nil {
 		// TODO: what's this
 		defaultHashAlgorithm = algorithm
+		securityLevel = level
 		//hashLength = level
 	}
 	return
 }
 
+// CheckSecurityLevel returns an error if the hash algorithm or the security
+// level is not supported
+func CheckSecurityLevel(algorithm string, level int) error {
+	switch algorithm {
+	case "SHA2", "SHA3":
+	default:
+		return fmt.Errorf("Algorithm not supported [%s]", algorithm)
+	}
+	switch level {
+	case 256, 384:
+	default:
+		return fmt.Errorf("Security level not supported [%d]", level)
+	}
+	return nil
+}
+
+// GetSecurityLevel returns the security level of the crypto layer
+func GetSecurityLevel() int {
+	return securityLevel
+}
+
 // InitSecurityLevel initialize the crypto layer at the given security level
 func InitSecurityLevel(algorithm string, level int) (err error) {
 	initOnce.Do(func() {
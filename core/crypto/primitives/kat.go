@@ -0,0 +1,209 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/sha3"
+)
+
+// knownAnswerTest checks that a primitive computes the expected answer
+type knownAnswerTest struct {
+	name string
+	// fips tells whether the primitive is FIPS-approved
+	fips bool
+	test func() error
+}
+
+var knownAnswerTests = []knownAnswerTest{
+	{"SHA2-256", true, func() error {
+		return checkHash(sha256.New, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad")
+	}},
+	{"SHA2-384", true, func() error {
+		return checkHash(sha512.New384, "cb00753f45a35e8bb5a03d699ac65007272c32ab0eded1631a8b605a43ff5bed8086072ba1e7cc2358baeca134c825a7")
+	}},
+	{"SHA3-256", true, func() error {
+		return checkHash(sha3.New256, "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532")
+	}},
+	{"SHA3-384", true, func() error {
+		return checkHash(sha3.New384, "ec01498288516fc926459f58e2c6ad8df9b473cb0fc08c2596da7cf0e49be4b298d88cea927ac7f539f1edf228376d25")
+	}},
+	// RFC 4231, test case 2
+	{"HMAC-SHA2-256", true, func() error {
+		mac := hmac.New(sha256.New, []byte("Jefe"))
+		mac.Write([]byte("what do ya want for nothing?"))
+		return checkAnswer(mac.Sum(nil), "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843")
+	}},
+	// RFC 5869, test case 1
+	{"HKDF-SHA2-256", true, func() error {
+		okm := make([]byte, 42)
+		kdf := hkdf.New(sha256.New, fromHex("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b"), fromHex("000102030405060708090a0b0c"), fromHex("f0f1f2f3f4f5f6f7f8f9"))
+		if _, err := io.ReadFull(kdf, okm); err != nil {
+			return err
+		}
+		return checkAnswer(okm, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865")
+	}},
+	// NIST SP 800-38A, F.2.5
+	{"AES-256-CBC", true, func() error {
+		block, err := aes.NewCipher(fromHex("603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4"))
+		if err != nil {
+			return err
+		}
+		iv := fromHex("000102030405060708090a0b0c0d0e0f")
+		ct := fromHex("6bc1bee22e409f96e93d7e117393172a")
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(ct, ct)
+		if err = checkAnswer(ct, "f58c4c04d6e5f1ba779eabfb5f7bfbd6"); err != nil {
+			return err
+		}
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(ct, ct)
+		return checkAnswer(ct, "6bc1bee22e409f96e93d7e117393172a")
+	}},
+	// The Galois/Counter Mode of Operation, test case 14
+	{"AES-256-GCM", true, func() error {
+		aead, err := NewGCM(make([]byte, 32))
+		if err != nil {
+			return err
+		}
+		ct := aead.Seal(nil, make([]byte, aead.NonceSize()), make([]byte, 16), nil)
+		if err = checkAnswer(ct, "cea7403d4d606b6e074ec5d3baf39d18d0d1c8a799996bf0265b98b5d48ab919"); err != nil {
+			return err
+		}
+		_, err = aead.Open(nil, make([]byte, aead.NonceSize()), ct, nil)
+		return err
+	}},
+	// RFC 6979, A.2.5, with SHA-256 and message "sample"
+	{"ECDSA-P256", true, func() error {
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(fromHex("60fed4ba255a9d31c961eb74c6356d68c049b8923b61fa6ce669622e60f29fb6")),
+			Y:     new(big.Int).SetBytes(fromHex("7903fe1008b8bc99a41ae9e95628bc64f2f1b20c2d7e9f5177a3c294d4462299")),
+		}
+		r := new(big.Int).SetBytes(fromHex("efd48b2aacb6a8fd1140dd9cd45e81d69d2c877b56aaf991c34d0ea84eaf3716"))
+		s := new(big.Int).SetBytes(fromHex("f7cb1c942d657c41d436c7a1b6e29f65f3e900dbb9aff4064dc4ab2f843acda8"))
+		digest := sha256.Sum256([]byte("sample"))
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return fmt.Errorf("valid signature refused")
+		}
+		digest[0] ^= 1
+		if ecdsa.Verify(pub, digest[:], r, s) {
+			return fmt.Errorf("invalid signature accepted")
+		}
+		return nil
+	}},
+	// Pairwise consistency of the signatures with the default curve and hash
+	{"ECDSA", true, func() error {
+		key, err := NewECDSAKey()
+		if err != nil {
+			return err
+		}
+		return checkSignature(key, &key.PublicKey, ECDSASign, ECDSAVerify)
+	}},
+	// RFC 8032, 7.1, test 1
+	{"Ed25519", false, func() error {
+		key := ed25519.NewKeyFromSeed(fromHex("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"))
+		if err := checkAnswer(key.Public().(ed25519.PublicKey), "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"); err != nil {
+			return err
+		}
+		if err := checkAnswer(ed25519.Sign(key, nil), "e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b"); err != nil {
+			return err
+		}
+		return checkSignature(key, key.Public(), Ed25519Sign, Ed25519Verify)
+	}},
+	{"AES-256-CBC-HMAC", true, func() error {
+		aead, err := NewCBCHMAC(fromHex("603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4"))
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		msg := []byte("known answer test")
+		pt, err := aead.Open(nil, nonce, aead.Seal(nil, nonce, msg, nil), nil)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(pt, msg) {
+			return fmt.Errorf("decryption differs")
+		}
+		return nil
+	}},
+}
+
+// SelfTest runs the known-answer tests of the primitives of the crypto
+// layer, only of the FIPS-approved ones in FIPS mode, and returns an error
+// if one of them does not compute the expected answer
+func SelfTest() error {
+	fips := IsFIPSMode()
+	for _, kat := range knownAnswerTests {
+		if fips && !kat.fips {
+			continue
+		}
+		if err := kat.test(); err != nil {
+			return fmt.Errorf("Self-test of %s failed [%s]", kat.name, err)
+		}
+	}
+	return nil
+}
+
+func checkHash(h func() hash.Hash, answer string) error {
+	d := h()
+	d.Write([]byte("abc"))
+	return checkAnswer(d.Sum(nil), answer)
+}
+
+func checkAnswer(result []byte, answer string) error {
+	if hex.EncodeToString(result) != answer {
+		return fmt.Errorf("expected %s, got %x", answer, result)
+	}
+	return nil
+}
+
+func checkSignature(key, pub interface{}, sign func(interface{}, []byte) ([]byte, error), verify func(interface{}, []byte, []byte) (bool, error)) error {
+	msg := []byte("known answer test")
+	sigma, err := sign(key, msg)
+	if err != nil {
+		return err
+	}
+	if ok, err := verify(pub, msg, sigma); err != nil || !ok {
+		return fmt.Errorf("valid signature refused [%v]", err)
+	}
+	if ok, _ := verify(pub, append(msg, 0), sigma); ok {
+		return fmt.Errorf("invalid signature accepted")
+	}
+	return nil
+}
+
+func fromHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -21,11 +21,13 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/pem"
 	"errors"
 	"fmt"
 
 	"github.com/hyperledger/fabric/core/crypto/utils"
+	"golang.org/x/crypto/ed25519"
 )
 
 // PrivateKeyToDER marshals a private key to der
@@ -57,6 +59,19 @@ func PrivateKeyToPEM(privateKey interface{}, pwd []byte) ([]byte, error) {
 				Bytes: raw,
 			},
 		), nil
+	case ed25519.PrivateKey:
+		raw, err := marshalEd25519PrivateKey(x)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return pem.EncodeToMemory(
+			&pem.Block{
+				Type:  "ED25519 PRIVATE KEY",
+				Bytes: raw,
+			},
+		), nil
 	default:
 		return nil, utils.ErrInvalidKey
 	}
@@ -85,11 +100,39 @@ func PrivateKeyToEncryptedPEM(privateKey interface{}, pwd []byte) ([]byte, error
 
 		return pem.EncodeToMemory(block), nil
 
+	case ed25519.PrivateKey:
+		raw, err := marshalEd25519PrivateKey(x)
+
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := x509.EncryptPEMBlock(
+			rand.Reader,
+			"ED25519 PRIVATE KEY",
+			raw,
+			pwd,
+			x509.PEMCipherAES256)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return pem.EncodeToMemory(block), nil
+
 	default:
 		return nil, utils.ErrInvalidKey
 	}
 }
 
+// pkcs8PrivateKey is the PKCS#8 structure of an unencrypted private key, see
+// RFC 5208
+type pkcs8PrivateKey struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
 // DERToPrivateKey unmarshals a der to private key
 func DERToPrivateKey(der []byte) (key interface{}, err error) {
 	//fmt.Printf("DER [%s]\n", EncodeBase64(der))
@@ -100,12 +143,15 @@ func DERToPrivateKey(der []byte) (key interface{}, err error) {
 	//fmt.Printf("DERToPrivateKey Err [%s]\n", err)
 	if key, err = x509.ParsePKCS8PrivateKey(der); err == nil {
 		switch key.(type) {
-		case *rsa.PrivateKey, *ecdsa.PrivateKey:
+		case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
 			return
 		default:
 			return nil, errors.New("Found unknown private key type in PKCS#8 wrapping")
 		}
 	}
+	if key, err = parseEd25519PrivateKey(der); err == nil {
+		return
+	}
 	//fmt.Printf("DERToPrivateKey Err [%s]\n", err)
 	if key, err = x509.ParseECPrivateKey(der); err == nil {
 		return
@@ -304,6 +350,11 @@ func PEMtoPublicKey(raw []byte, pwd []byte) (interface{}, error) {
 // DERToPublicKey unmarshals a der to public key
 func DERToPublicKey(derBytes []byte) (pub interface{}, err error) {
 	key, err := x509.ParsePKIXPublicKey(derBytes)
+	if err != nil {
+		if edKey, edErr := parseEd25519PublicKey(derBytes); edErr == nil {
+			return edKey, nil
+		}
+	}
 
 	return key, err
 }
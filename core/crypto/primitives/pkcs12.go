@@ -0,0 +1,656 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"unicode/utf16"
+
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PKCS#12 bundles written by EncodePKCS12 protect the key and the integrity
+// of the bundle with the algorithms every PKCS#12 implementation supports
+const pkcs12Iterations = 2048
+
+// pkcs12MaxIterations bounds the iteration counts read from an imported
+// bundle, so that a crafted bundle cannot keep the key derivation busy
+const pkcs12MaxIterations = 1 << 20
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidSecretBag           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 5}
+	oidCertTypeX509        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidLocalKeyID          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+
+	oidPBEWithSHAAnd3KeyTripleDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBES2                         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256                = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC                     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC                     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC                     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA1                          = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256                        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+
+	oidECPublicKey = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidNamedCurves = map[elliptic.Curve]asn1.ObjectIdentifier{
+		elliptic.P224(): {1, 3, 132, 0, 33},
+		elliptic.P256(): {1, 2, 840, 10045, 3, 1, 7},
+		elliptic.P384(): {1, 3, 132, 0, 34},
+		elliptic.P521(): {1, 3, 132, 0, 35},
+	}
+
+	// asn1NullRawValue is the ASN.1 NULL parameter of algorithm identifiers
+	asn1NullRawValue = asn1.RawValue{Tag: 5}
+)
+
+// ErrPKCS12Password is returned when a PKCS#12 bundle cannot be opened with
+// the given password
+var ErrPKCS12Password = errors.New("Invalid password for the PKCS#12 bundle.")
+
+// PKCS12Secret is a secret carried by a PKCS#12 bundle, identified by its type
+type PKCS12Secret struct {
+	Type  asn1.ObjectIdentifier
+	Value []byte
+}
+
+// PKCS12Bundle is the content of a PKCS#12 bundle: a private key, its
+// certificate and the chain of the certificate, all DER encoded, and secrets
+type PKCS12Bundle struct {
+	PrivateKey  interface{}
+	Certificate []byte
+	CACerts     [][]byte
+	Secrets     []PKCS12Secret
+}
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe pkcs12ContentInfo
+	MacData  pkcs12MacData `asn1:"optional"`
+}
+
+// pkcs12ContentInfo is a PKCS#7 ContentInfo. Content is the explicitly
+// tagged [0] content, tagged by hand as encoding/asn1 does not tag RawValues.
+type pkcs12ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional"`
+}
+
+type pkcs12EncryptedData struct {
+	Version              int
+	EncryptedContentInfo struct {
+		ContentType                asn1.ObjectIdentifier
+		ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+		EncryptedContent           asn1.RawValue
+	}
+}
+
+type pkcs12SafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// pkcs12TypedBag is the shape of both the CertBag and the SecretBag
+type pkcs12TypedBag struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type pkcs12EncryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pkcs12PBEParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pkcs12PBES2Params struct {
+	KDF              pkix.AlgorithmIdentifier
+	EncryptionScheme pkix.AlgorithmIdentifier
+}
+
+type pkcs12PBKDF2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type pkcs12MacData struct {
+	Mac struct {
+		Algorithm pkix.AlgorithmIdentifier
+		Digest    []byte
+	}
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+// EncodePKCS12 encodes bundle as a PKCS#12 bundle protected by password. The
+// private key is encrypted with pbeWithSHAAnd3-KeyTripleDES-CBC and the bundle
+// is authenticated with HMAC-SHA1.
+func EncodePKCS12(bundle *PKCS12Bundle, password string) ([]byte, error) {
+	if bundle == nil || bundle.PrivateKey == nil || len(bundle.Certificate) == 0 {
+		return nil, errors.New("A PKCS#12 bundle needs a private key and its certificate.")
+	}
+	pwd := pkcs12Password(password)
+
+	localKeyID := sha1.Sum(bundle.Certificate)
+	attr, err := pkcs12LocalKeyID(localKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// The private key, shrouded
+	pkcs8, err := pkcs12MarshalPrivateKey(bundle.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := GetRandomBytes(8)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pkcs12PBEParams{salt, pkcs12Iterations})
+	if err != nil {
+		return nil, err
+	}
+	algorithm := pkix.AlgorithmIdentifier{Algorithm: oidPBEWithSHAAnd3KeyTripleDESCBC, Parameters: asn1.RawValue{FullBytes: params}}
+	encryptedKey, err := pkcs12Encrypt(algorithm, pwd, pkcs8)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo, err := asn1.Marshal(pkcs12EncryptedPrivateKeyInfo{algorithm, encryptedKey})
+	if err != nil {
+		return nil, err
+	}
+	bags := []pkcs12SafeBag{{oidPKCS8ShroudedKeyBag, pkcs12Explicit(keyInfo), []pkcs12Attribute{attr}}}
+
+	// The certificates
+	for i, cert := range append([][]byte{bundle.Certificate}, bundle.CACerts...) {
+		bag, err := pkcs12NewTypedBag(oidCertTypeX509, cert)
+		if err != nil {
+			return nil, err
+		}
+		safeBag := pkcs12SafeBag{ID: oidCertBag, Value: pkcs12Explicit(bag)}
+		if i == 0 {
+			safeBag.Attributes = []pkcs12Attribute{attr}
+		}
+		bags = append(bags, safeBag)
+	}
+
+	// The secrets
+	for _, secret := range bundle.Secrets {
+		bag, err := pkcs12NewTypedBag(secret.Type, secret.Value)
+		if err != nil {
+			return nil, err
+		}
+		bags = append(bags, pkcs12SafeBag{ID: oidSecretBag, Value: pkcs12Explicit(bag)})
+	}
+
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return nil, err
+	}
+	data, err := asn1.Marshal(safeContents)
+	if err != nil {
+		return nil, err
+	}
+	authSafe, err := asn1.Marshal([]pkcs12ContentInfo{{oidDataContentType, pkcs12Explicit(data)}})
+	if err != nil {
+		return nil, err
+	}
+	authSafeData, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	pfx := pfxPdu{Version: 3, AuthSafe: pkcs12ContentInfo{oidDataContentType, pkcs12Explicit(authSafeData)}}
+	if pfx.MacData.MacSalt, err = GetRandomBytes(8); err != nil {
+		return nil, err
+	}
+	pfx.MacData.Iterations = pkcs12Iterations
+	pfx.MacData.Mac.Algorithm = pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1NullRawValue}
+	pfx.MacData.Mac.Digest = pkcs12MAC(sha1.New, pwd, pfx.MacData.MacSalt, pkcs12Iterations, authSafe)
+
+	return asn1.Marshal(pfx)
+}
+
+// DecodePKCS12 decodes the PKCS#12 bundle pfx protected by password. Besides
+// the algorithms of EncodePKCS12, it supports the PBES2 encryption with AES
+// and the HMAC-SHA256 integrity used by recent tools.
+func DecodePKCS12(pfx []byte, password string) (*PKCS12Bundle, error) {
+	pwd := pkcs12Password(password)
+
+	var pdu pfxPdu
+	if rest, err := asn1.Unmarshal(pfx, &pdu); err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, errors.New("Trailing data after the PKCS#12 bundle.")
+	}
+	if pdu.Version != 3 {
+		return nil, fmt.Errorf("Unsupported PKCS#12 version [%d].", pdu.Version)
+	}
+	if !pdu.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, errors.New("Only PKCS#12 bundles protected by a password are supported.")
+	}
+	authSafe, err := pkcs12OctetString(pdu.AuthSafe.Content.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pdu.MacData.Mac.Digest) != 0 {
+		var h func() hash.Hash
+		switch alg := pdu.MacData.Mac.Algorithm.Algorithm; {
+		case alg.Equal(oidSHA1):
+			h = sha1.New
+		case alg.Equal(oidSHA256):
+			h = sha256.New
+		default:
+			return nil, fmt.Errorf("Unsupported PKCS#12 MAC algorithm [%s].", alg)
+		}
+		if err := pkcs12CheckIterations(pdu.MacData.Iterations); err != nil {
+			return nil, err
+		}
+		mac := pkcs12MAC(h, pwd, pdu.MacData.MacSalt, pdu.MacData.Iterations, authSafe)
+		if !hmac.Equal(mac, pdu.MacData.Mac.Digest) {
+			return nil, ErrPKCS12Password
+		}
+	}
+
+	var contents []pkcs12ContentInfo
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, err
+	}
+
+	var (
+		bundle    = new(PKCS12Bundle)
+		keyID     []byte
+		certs     [][]byte
+		certIDs   [][]byte
+		safeBags  []pkcs12SafeBag
+		plaintext []byte
+	)
+	for _, content := range contents {
+		switch {
+		case content.ContentType.Equal(oidDataContentType):
+			if plaintext, err = pkcs12OctetString(content.Content.Bytes); err != nil {
+				return nil, err
+			}
+		case content.ContentType.Equal(oidEncryptedDataContentType):
+			var encrypted pkcs12EncryptedData
+			if _, err := asn1.Unmarshal(content.Content.Bytes, &encrypted); err != nil {
+				return nil, err
+			}
+			info := encrypted.EncryptedContentInfo
+			if plaintext, err = pkcs12Decrypt(info.ContentEncryptionAlgorithm, password, info.EncryptedContent.Bytes); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("Unsupported PKCS#12 content [%s].", content.ContentType)
+		}
+
+		safeBags = safeBags[:0]
+		if _, err := asn1.Unmarshal(plaintext, &safeBags); err != nil {
+			return nil, err
+		}
+		for _, bag := range safeBags {
+			switch {
+			case bag.ID.Equal(oidKeyBag), bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+				pkcs8 := bag.Value.Bytes
+				if bag.ID.Equal(oidPKCS8ShroudedKeyBag) {
+					var info pkcs12EncryptedPrivateKeyInfo
+					if _, err := asn1.Unmarshal(bag.Value.Bytes, &info); err != nil {
+						return nil, err
+					}
+					if pkcs8, err = pkcs12Decrypt(info.Algorithm, password, info.EncryptedData); err != nil {
+						return nil, err
+					}
+				}
+				// x509.ParsePKCS8PrivateKey only parses Ed25519 keys from Go 1.13 on
+				if bundle.PrivateKey, err = DERToPrivateKey(pkcs8); err != nil {
+					return nil, err
+				}
+				keyID = pkcs12FindLocalKeyID(bag.Attributes)
+			case bag.ID.Equal(oidCertBag), bag.ID.Equal(oidSecretBag):
+				var typed pkcs12TypedBag
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &typed); err != nil {
+					return nil, err
+				}
+				value, err := pkcs12OctetString(typed.Value.Bytes)
+				if err != nil {
+					return nil, err
+				}
+				if bag.ID.Equal(oidSecretBag) {
+					bundle.Secrets = append(bundle.Secrets, PKCS12Secret{typed.ID, value})
+				} else if typed.ID.Equal(oidCertTypeX509) {
+					certs = append(certs, value)
+					certIDs = append(certIDs, pkcs12FindLocalKeyID(bag.Attributes))
+				}
+			}
+		}
+	}
+	if bundle.PrivateKey == nil {
+		return nil, errors.New("No private key in the PKCS#12 bundle.")
+	}
+
+	// The certificate of the key is the one with the same local key ID, or
+	// the one with its public key
+	leaf := -1
+	for i, cert := range certs {
+		if keyID != nil && bytes.Equal(certIDs[i], keyID) {
+			leaf = i
+			break
+		}
+		if x509Cert, err := x509.ParseCertificate(cert); err == nil && leaf < 0 && CheckCertPKAgainstSK(x509Cert, bundle.PrivateKey) == nil {
+			leaf = i
+		}
+	}
+	if leaf < 0 {
+		return nil, errors.New("No certificate for the private key in the PKCS#12 bundle.")
+	}
+	bundle.Certificate = certs[leaf]
+	for i, cert := range certs {
+		if i != leaf {
+			bundle.CACerts = append(bundle.CACerts, cert)
+		}
+	}
+
+	return bundle, nil
+}
+
+// pkcs12Password encodes password as a null terminated BMPString
+// pkcs12MarshalPrivateKey encodes an ECDSA or Ed25519 key as a PKCS#8
+// structure, as x509.MarshalPKCS8PrivateKey does on recent Go releases
+func pkcs12MarshalPrivateKey(key interface{}) ([]byte, error) {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		oid, ok := oidNamedCurves[k.Curve]
+		if !ok {
+			return nil, errors.New("Unsupported elliptic curve for the PKCS#12 bundle.")
+		}
+		params, err := asn1.Marshal(oid)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(pkcs8PrivateKey{
+			Algo:       pkix.AlgorithmIdentifier{Algorithm: oidECPublicKey, Parameters: asn1.RawValue{FullBytes: params}},
+			PrivateKey: raw,
+		})
+	case ed25519.PrivateKey:
+		return marshalEd25519PrivateKey(k)
+	default:
+		return nil, utils.ErrInvalidKey
+	}
+}
+
+func pkcs12Password(password string) []byte {
+	encoded := utf16.Encode([]rune(password))
+	pwd := make([]byte, 2*len(encoded)+2)
+	for i, c := range encoded {
+		pwd[2*i], pwd[2*i+1] = byte(c>>8), byte(c)
+	}
+	return pwd
+}
+
+func pkcs12Explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func pkcs12OctetString(der []byte) ([]byte, error) {
+	var value []byte
+	if _, err := asn1.Unmarshal(der, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func pkcs12NewTypedBag(id asn1.ObjectIdentifier, value []byte) ([]byte, error) {
+	octets, err := asn1.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pkcs12TypedBag{id, pkcs12Explicit(octets)})
+}
+
+func pkcs12LocalKeyID(id []byte) (pkcs12Attribute, error) {
+	octets, err := asn1.Marshal(id)
+	if err != nil {
+		return pkcs12Attribute{}, err
+	}
+	return pkcs12Attribute{oidLocalKeyID, asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: octets}}, nil
+}
+
+func pkcs12FindLocalKeyID(attributes []pkcs12Attribute) []byte {
+	for _, attr := range attributes {
+		if attr.ID.Equal(oidLocalKeyID) {
+			if id, err := pkcs12OctetString(attr.Value.Bytes); err == nil {
+				return id
+			}
+		}
+	}
+	return nil
+}
+
+func pkcs12CheckIterations(iterations int) error {
+	if iterations < 1 || iterations > pkcs12MaxIterations {
+		return fmt.Errorf("Unsupported PKCS#12 iteration count [%d], expected at most %d.", iterations, pkcs12MaxIterations)
+	}
+	return nil
+}
+
+func pkcs12MAC(h func() hash.Hash, pwd, salt []byte, iterations int, message []byte) []byte {
+	key := pkcs12KDF(h, pwd, salt, 3, iterations, h().Size())
+	mac := hmac.New(h, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// pkcs12KDF derives size bytes of key material of the given purpose id from
+// a password, as specified in appendix B of RFC 7292
+func pkcs12KDF(h func() hash.Hash, pwd, salt []byte, id byte, iterations, size int) []byte {
+	const v = 64
+
+	fill := func(in []byte, n int) []byte {
+		out := make([]byte, n)
+		for i := range out {
+			out[i] = in[i%len(in)]
+		}
+		return out
+	}
+	var I []byte
+	if len(salt) > 0 {
+		I = append(I, fill(salt, v*((len(salt)+v-1)/v))...)
+	}
+	if len(pwd) > 0 {
+		I = append(I, fill(pwd, v*((len(pwd)+v-1)/v))...)
+	}
+	D := bytes.Repeat([]byte{id}, v)
+
+	var out []byte
+	for {
+		digest := h()
+		digest.Write(D)
+		digest.Write(I)
+		A := digest.Sum(nil)
+		for j := 1; j < iterations; j++ {
+			digest.Reset()
+			digest.Write(A)
+			A = digest.Sum(nil)
+		}
+		out = append(out, A...)
+		if len(out) >= size {
+			return out[:size]
+		}
+
+		B := new(big.Int).SetBytes(fill(A, v))
+		B.Add(B, big.NewInt(1))
+		for k := 0; k < len(I); k += v {
+			Ij := new(big.Int).SetBytes(I[k : k+v])
+			raw := Ij.Add(Ij, B).Bytes()
+			if len(raw) > v {
+				raw = raw[len(raw)-v:]
+			}
+			block := I[k : k+v]
+			for i := range block {
+				block[i] = 0
+			}
+			copy(block[v-len(raw):], raw)
+		}
+	}
+}
+
+func pkcs12Encrypt(algorithm pkix.AlgorithmIdentifier, pwd, plaintext []byte) ([]byte, error) {
+	block, iv, err := pkcs12TripleDES(algorithm, pwd)
+	if err != nil {
+		return nil, err
+	}
+	padding := block.BlockSize() - len(plaintext)%block.BlockSize()
+	ciphertext := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+	return ciphertext, nil
+}
+
+func pkcs12Decrypt(algorithm pkix.AlgorithmIdentifier, password string, ciphertext []byte) ([]byte, error) {
+	var (
+		block cipher.Block
+		iv    []byte
+		err   error
+	)
+	switch {
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd3KeyTripleDESCBC):
+		block, iv, err = pkcs12TripleDES(algorithm, pkcs12Password(password))
+	case algorithm.Algorithm.Equal(oidPBES2):
+		block, iv, err = pkcs12PBES2(algorithm, password)
+	default:
+		err = fmt.Errorf("Unsupported PKCS#12 encryption algorithm [%s].", algorithm.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, errors.New("Invalid PKCS#12 ciphertext length.")
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > block.BlockSize() || !bytes.Equal(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, ErrPKCS12Password
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+func pkcs12TripleDES(algorithm pkix.AlgorithmIdentifier, pwd []byte) (cipher.Block, []byte, error) {
+	var params pkcs12PBEParams
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, nil, err
+	}
+	if err := pkcs12CheckIterations(params.Iterations); err != nil {
+		return nil, nil, err
+	}
+	key := pkcs12KDF(sha1.New, pwd, params.Salt, 1, params.Iterations, 24)
+	iv := pkcs12KDF(sha1.New, pwd, params.Salt, 2, params.Iterations, 8)
+	block, err := des.NewTripleDESCipher(key)
+	return block, iv, err
+}
+
+func pkcs12PBES2(algorithm pkix.AlgorithmIdentifier, password string) (cipher.Block, []byte, error) {
+	var params pkcs12PBES2Params
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, nil, err
+	}
+	if !params.KDF.Algorithm.Equal(oidPBKDF2) {
+		return nil, nil, fmt.Errorf("Unsupported PBES2 key derivation [%s].", params.KDF.Algorithm)
+	}
+	var kdf pkcs12PBKDF2Params
+	if _, err := asn1.Unmarshal(params.KDF.Parameters.FullBytes, &kdf); err != nil {
+		return nil, nil, err
+	}
+
+	h := sha1.New
+	switch prf := kdf.PRF.Algorithm; {
+	case len(prf) == 0, prf.Equal(oidHMACWithSHA1):
+	case prf.Equal(oidHMACWithSHA256):
+		h = sha256.New
+	default:
+		return nil, nil, fmt.Errorf("Unsupported PBKDF2 function [%s].", prf)
+	}
+
+	var size int
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		size = 16
+	case scheme.Equal(oidAES192CBC):
+		size = 24
+	case scheme.Equal(oidAES256CBC):
+		size = 32
+	default:
+		return nil, nil, fmt.Errorf("Unsupported PBES2 encryption [%s].", scheme)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, nil, err
+	}
+
+	if err := pkcs12CheckIterations(kdf.Iterations); err != nil {
+		return nil, nil, err
+	}
+	key := pbkdf2.Key([]byte(password), kdf.Salt, kdf.Iterations, size, h)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, nil, errors.New("Invalid PBES2 initialization vector.")
+	}
+	return block, iv, nil
+}
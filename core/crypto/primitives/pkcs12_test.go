@@ -0,0 +1,165 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"golang.org/x/crypto/ed25519"
+)
+
+// opensslPKCS12 is a bundle exported by OpenSSL 3 with its defaults: PBES2
+// with AES-256-CBC and an HMAC-SHA256 MAC, protected by "fabric"
+const opensslPKCS12 = "" +
+	"MIID/AIBAzCCA7IGCSqGSIb3DQEHAaCCA6MEggOfMIIDmzCCAlIGCSqGSIb3DQEHBqCCAkMwggI/" +
+	"AgEAMIICOAYJKoZIhvcNAQcBMFcGCSqGSIb3DQEFDTBKMCkGCSqGSIb3DQEFDDAcBAgwAWrvzhSV" +
+	"ogICA+gwDAYIKoZIhvcNAgkFADAdBglghkgBZQMEASoEENwzx63jyVYow1aKKsA3YROAggHQaI62" +
+	"kKf/YrpVQbOleyG439Sj6qvsvPaS/Fb+mKlDGeWI5SWIXv+PmZ3eTLAVRxlGlRb0IM+cFO1iBX+g" +
+	"d04dQD+dNu02FTuJdLgm7LlwmU0IzsaQbEBC13vQ3HKpYVFFbUZQZIqsGvWxMcGDzI0czYUhZrBr" +
+	"YOowtTesHFw/oWR8XdmnA2jhXwuXXVIDTwWq56SYzrkWe4DfEUH68Gz0i3sEFXZTccXq02ll9b0k" +
+	"oHSjUxAhprZm1upUESPI6C78huVtNsmujng6w/moeLxbRHIeCvq1BS1ITb8IojRGzc7Hw1aDHXyo" +
+	"0UZXTvdMh4p+nh29ewwDu6PEtPNwHcbQLEJ9te7KuQm56BjaHzFSGk8ShjW0bf7YsA4LBSOJEk7h" +
+	"CDen0hsvwWHkhM+/PPZ98RKR9u8QeWLwMncSeOwqjMkizrlN583srryxXNX1UEIJfsQZV/chFiw2" +
+	"18CQ9NtOrKY6BQI4oX7NIjQimdC/7RadhwWG/AEZH9jTODQAojqpGFbjaB33Xhm6XHADT7x0zROz" +
+	"W783lYmgQoLH+2WYxbW/ZcAfbptfrVKvR/EfnIqB4olvJP3WjP8b995ALjkqEgOR22Wwh14li5nq" +
+	"gO8aOYowggFBBgkqhkiG9w0BBwGgggEyBIIBLjCCASowggEmBgsqhkiG9w0BDAoBAqCB7zCB7DBX" +
+	"BgkqhkiG9w0BBQ0wSjApBgkqhkiG9w0BBQwwHAQIviC5TE/vKF8CAgPoMAwGCCqGSIb3DQIJBQAw" +
+	"HQYJYIZIAWUDBAEqBBAe3uYia1MeVCaYtQCiUhsaBIGQmZpySGtetoduQIcJpbmvP/ukZm+XmHVE" +
+	"Iduo/eQjhqqXsfBQHVeudE2Lxysa5H1vtkGN1QnozKjBP6vmYZAI1GhlJ7sr14XQWonxoK+HRxgT" +
+	"XFjLO0jiRafJ7NPA+QFLleBxb29RM8uXrB159S+SgOveyjIyz5v89/VH8+0wbFCCqvWi8BvPoYXm" +
+	"pc8fLLNRMSUwIwYJKoZIhvcNAQkVMRYEFGFLrqanp6Enx1CZO8P//f770DT3MEEwMTANBglghkgB" +
+	"ZQMEAgEFAAQgtED8rgypyZlmT/vhKd9kdO3mXwHRN2gVSedFaIsXT84ECO4AO/woaTuPAgID6A=="
+
+func TestPKCS12EncodeDecode(t *testing.T) {
+	der, key, err := primitives.NewSelfSignedCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := primitives.PKCS12Secret{Type: asn1.ObjectIdentifier{1, 2, 3}, Value: []byte("secret")}
+
+	pfx, err := primitives.EncodePKCS12(&primitives.PKCS12Bundle{PrivateKey: key, Certificate: der, CACerts: [][]byte{der}, Secrets: []primitives.PKCS12Secret{secret}}, "pässword")
+	if err != nil {
+		t.Fatalf("Failed encoding PKCS#12 bundle: %s", err)
+	}
+	bundle, err := primitives.DecodePKCS12(pfx, "pässword")
+	if err != nil {
+		t.Fatalf("Failed decoding PKCS#12 bundle: %s", err)
+	}
+	if !bytes.Equal(bundle.Certificate, der) || len(bundle.CACerts) != 1 {
+		t.Fatal("The certificates of the bundle differ from the encoded ones")
+	}
+	if bundle.PrivateKey.(*ecdsa.PrivateKey).D.Cmp(key.(*ecdsa.PrivateKey).D) != 0 {
+		t.Fatal("The key of the bundle differs from the encoded one")
+	}
+	if len(bundle.Secrets) != 1 || !bundle.Secrets[0].Type.Equal(secret.Type) || !bytes.Equal(bundle.Secrets[0].Value, secret.Value) {
+		t.Fatal("The secrets of the bundle differ from the encoded ones")
+	}
+
+	if _, err := primitives.DecodePKCS12(pfx, "password"); err != primitives.ErrPKCS12Password {
+		t.Fatalf("Decoding with a wrong password should fail, got [%v]", err)
+	}
+	pfx[len(pfx)/2] ^= 1
+	if _, err := primitives.DecodePKCS12(pfx, "pässword"); err == nil {
+		t.Fatal("Decoding a tampered bundle should fail")
+	}
+}
+
+func TestPKCS12EncodeDecodeEd25519(t *testing.T) {
+	key, err := primitives.NewEd25519Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// crypto/x509 only creates Ed25519 certificates from Go 1.13 on, the
+	// bundle pairs the key with its certificate by their local key ID
+	der, _, err := primitives.NewSelfSignedCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pfx, err := primitives.EncodePKCS12(&primitives.PKCS12Bundle{PrivateKey: key, Certificate: der}, "password")
+	if err != nil {
+		t.Fatalf("Failed encoding PKCS#12 bundle: %s", err)
+	}
+	bundle, err := primitives.DecodePKCS12(pfx, "password")
+	if err != nil {
+		t.Fatalf("Failed decoding PKCS#12 bundle: %s", err)
+	}
+	decoded, ok := bundle.PrivateKey.(ed25519.PrivateKey)
+	if !ok || !bytes.Equal(decoded, key) {
+		t.Fatalf("The key of the bundle differs from the encoded one, got %T", bundle.PrivateKey)
+	}
+	if !bytes.Equal(bundle.Certificate, der) {
+		t.Fatal("The certificate of the bundle differs from the encoded one")
+	}
+}
+
+func TestPKCS12DecodeOpenSSL(t *testing.T) {
+	pfx, err := base64.StdEncoding.DecodeString(opensslPKCS12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := primitives.DecodePKCS12(pfx, "fabric")
+	if err != nil {
+		t.Fatalf("Failed decoding PKCS#12 bundle: %s", err)
+	}
+	cert, err := x509.ParseCertificate(bundle.Certificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := primitives.CheckCertPKAgainstSK(cert, bundle.PrivateKey); err != nil {
+		t.Fatalf("The certificate does not match the key: %s", err)
+	}
+}
+
+func TestPKCS12DecodeIterationLimit(t *testing.T) {
+	der, key, err := primitives.NewSelfSignedCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pfx, err := primitives.EncodePKCS12(&primitives.PKCS12Bundle{PrivateKey: key, Certificate: der}, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pdu struct {
+		Version  int
+		AuthSafe asn1.RawValue
+		MacData  struct {
+			Mac        asn1.RawValue
+			MacSalt    []byte
+			Iterations int
+		}
+	}
+	if _, err := asn1.Unmarshal(pfx, &pdu); err != nil {
+		t.Fatal(err)
+	}
+	pdu.MacData.Iterations = 1 << 30
+	if pfx, err = asn1.Marshal(pdu); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = primitives.DecodePKCS12(pfx, "password")
+	if err == nil || err == primitives.ErrPKCS12Password {
+		t.Fatalf("Decoding a bundle with an excessive iteration count should be rejected, got [%v]", err)
+	}
+}
@@ -1,6 +1,8 @@
 package primitives
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"encoding/asn1"
@@ -130,6 +132,165 @@ func TestECDSA(t *testing.T) {
 	}
 }
 
+func TestECDSAWithSigner(t *testing.T) {
+	key, err := NewECDSAKey()
+	if err != nil {
+		t.Fatalf("Failed generating ECDSA key [%s]", err)
+	}
+	// Hide the private key as an HSM does
+	signer := struct{ crypto.Signer }{key}
+	msg := []byte("Hello World")
+
+	sigma, err := ECDSASign(signer, msg)
+	if err != nil {
+		t.Fatalf("Failed signing [%s]", err)
+	}
+	if ok, err := ECDSAVerify(key.Public(), msg, sigma); err != nil || !ok {
+		t.Fatalf("Failed verification [%v].", err)
+	}
+
+	R, S, err := ECDSASignDirect(signer, msg)
+	if err != nil {
+		t.Fatalf("Failed signing (direct) [%s]", err)
+	}
+	if sigma, err = asn1.Marshal(ECDSASignature{R, S}); err != nil {
+		t.Fatalf("Failed marshalling (R,S) [%s]", err)
+	}
+	if ok, err := ECDSAVerify(key.Public(), msg, sigma); err != nil || !ok {
+		t.Fatalf("Failed verification (direct) [%v].", err)
+	}
+}
+
+func TestSoftwareProvider(t *testing.T) {
+	if err := InitProvider("unknown", "security"); err == nil {
+		t.Fatal("An unknown provider should be rejected")
+	}
+	if err := InitProvider(SoftwareProviderName, "security"); err != nil {
+		t.Fatalf("Failed initializing the software provider [%s]", err)
+	}
+	provider := GetProvider()
+	if provider.Name() != SoftwareProviderName {
+		t.Fatalf("Expected the software provider, got [%s]", provider.Name())
+	}
+
+	key, err := provider.GenerateKey("")
+	if err != nil {
+		t.Fatalf("Failed generating key [%s]", err)
+	}
+	msg := []byte("Hello World")
+	sigma, err := provider.Sign(key, msg)
+	if err != nil {
+		t.Fatalf("Failed signing [%s]", err)
+	}
+	if ok, err := provider.Verify(key.Public(), msg, sigma); err != nil || !ok {
+		t.Fatalf("Failed verification [%v].", err)
+	}
+	if _, err := provider.GetKey("key"); err == nil {
+		t.Fatal("The software provider should keep no keys")
+	}
+
+	secret, err := GenAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := provider.Encrypt(secret, msg)
+	if err != nil {
+		t.Fatalf("Failed encrypting [%s]", err)
+	}
+	if pt, err := provider.Decrypt(secret, ct); err != nil || !bytes.Equal(pt, msg) {
+		t.Fatalf("Failed decrypting [%v]", err)
+	}
+	if !bytes.Equal(provider.Hash(msg), Hash(msg)) {
+		t.Fatal("The provider should hash with the default hash function")
+	}
+}
+
+func TestEd25519(t *testing.T) {
+	key, err := NewEd25519Key()
+	if err != nil {
+		t.Fatalf("Failed generating Ed25519 key [%s]", err)
+	}
+	msg := []byte("Hello World")
+
+	sigma, err := GetProvider().Sign(key, msg)
+	if err != nil {
+		t.Fatalf("Failed signing [%s]", err)
+	}
+	if ok, err := GetProvider().Verify(key.Public(), msg, sigma); err != nil || !ok {
+		t.Fatalf("Failed verification [%v]", err)
+	}
+	if ok, _ := Ed25519Verify(key.Public(), []byte("Hello Mars"), sigma); ok {
+		t.Fatal("The signature of another message should not verify")
+	}
+
+	r, s, err := Ed25519SignDirect(key, msg)
+	if err != nil {
+		t.Fatalf("Failed signing [%s]", err)
+	}
+	if ok, err := Ed25519VerifyDirect(key.Public(), msg, r, s); err != nil || !ok {
+		t.Fatalf("Failed verification of R and S [%v]", err)
+	}
+
+	for _, pwd := range [][]byte{nil, []byte("passwd")} {
+		raw, err := PrivateKeyToPEM(key, pwd)
+		if err != nil {
+			t.Fatalf("Failed converting key to PEM [%s]", err)
+		}
+		decoded, err := PEMtoPrivateKey(raw, pwd)
+		if err != nil {
+			t.Fatalf("Failed converting PEM to key [%s]", err)
+		}
+		if !reflect.DeepEqual(decoded, key) {
+			t.Fatal("The decoded key does not match the original one")
+		}
+	}
+
+	der, err := marshalEd25519PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed marshalling key [%s]", err)
+	}
+	decoded, err := parseEd25519PrivateKey(der)
+	if err != nil {
+		t.Fatalf("Failed parsing key [%s]", err)
+	}
+	if !reflect.DeepEqual(decoded, key) {
+		t.Fatal("The parsed key does not match the original one")
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	if err := SelfTest(); err != nil {
+		t.Fatalf("Self-test failed [%s]", err)
+	}
+}
+
+func TestFIPSMode(t *testing.T) {
+	key, err := NewEd25519Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigma, err := Ed25519Sign(key, []byte("msg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetFIPSMode(true)
+	defer SetFIPSMode(false)
+
+	if _, err := NewEd25519Key(); err != ErrNotFIPSApproved {
+		t.Fatalf("Ed25519 keys should not be generated in FIPS mode, got [%v]", err)
+	}
+	if _, err := Ed25519Sign(key, []byte("msg")); err != ErrNotFIPSApproved {
+		t.Fatalf("Ed25519 signatures should not be created in FIPS mode, got [%v]", err)
+	}
+	if _, err := Ed25519Verify(key.Public(), []byte("msg"), sigma); err != ErrNotFIPSApproved {
+		t.Fatalf("Ed25519 signatures should not be verified in FIPS mode, got [%v]", err)
+	}
+	if err := SelfTest(); err != nil {
+		t.Fatalf("Self-test failed in FIPS mode [%s]", err)
+	}
+}
+
 func TestECDSAKeys(t *testing.T) {
 	key, err := NewECDSAKey()
 	if err != nil {
@@ -0,0 +1,185 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+)
+
+// Provider performs the cryptographic operations of the crypto layer. The
+// provider in use is chosen by configuration, which decides both the
+// algorithms and where the signing keys are kept.
+type Provider interface {
+	// Name returns the name the provider is registered with
+	Name() string
+
+	// GenerateKey generates a new signing key pair. Providers keeping their
+	// keys outside the process, e.g. on an HSM, keep it under label.
+	GenerateKey(label string) (crypto.Signer, error)
+
+	// GetKey returns the signing key kept under label by the provider
+	GetKey(label string) (crypto.Signer, error)
+
+	// Sign signs msg with key, returning an ASN.1 encoded signature
+	Sign(key interface{}, msg []byte) ([]byte, error)
+
+	// Verify verifies the signature of msg with the public key key
+	Verify(key interface{}, msg, signature []byte) (bool, error)
+
+	// Encrypt encrypts msg with the secret key key
+	Encrypt(key, msg []byte) ([]byte, error)
+
+	// Decrypt decrypts ciphertext with the secret key key
+	Decrypt(key, ciphertext []byte) ([]byte, error)
+
+	// Hash hashes msg
+	Hash(msg []byte) []byte
+
+	// NewHash returns a new hash function
+	NewHash() hash.Hash
+}
+
+// ProviderFactory creates a provider, reading its configuration in the
+// section prefix of the configuration of the crypto layer, e.g. security
+type ProviderFactory func(prefix string) (Provider, error)
+
+// SoftwareProviderName is the name of the provider implemented in software,
+// which keeps the keys in the keystore of the node
+const SoftwareProviderName = "SW"
+
+var (
+	providersLock sync.RWMutex
+	providers     = map[string]ProviderFactory{
+		SoftwareProviderName: func(prefix string) (Provider, error) {
+			return NewSoftwareProvider(), nil
+		},
+	}
+	defaultProvider Provider = NewSoftwareProvider()
+)
+
+// RegisterProvider makes a provider available under name
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	providers[name] = factory
+}
+
+// GetProviderNames returns the names of the providers available
+func GetProviderNames() []string {
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	var names []string
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InitProvider makes the provider registered under name the one used by the
+// crypto layer
+func InitProvider(name, prefix string) error {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	factory, ok := providers[name]
+	if !ok {
+		return fmt.Errorf("Crypto provider not supported [%s]", name)
+	}
+	provider, err := factory(prefix)
+	if err != nil {
+		return err
+	}
+	defaultProvider = provider
+	return nil
+}
+
+// GetProvider returns the provider used by the crypto layer
+func GetProvider() Provider {
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	return defaultProvider
+}
+
+// SoftwareProvider implements the operations of the crypto layer in
+// software, with ECDSA on the default curve or Ed25519, AES in CBC mode with PKCS#7
+// padding and the default hash function
+type SoftwareProvider struct{}
+
+// NewSoftwareProvider returns the provider implemented in software
+func NewSoftwareProvider() *SoftwareProvider {
+	return &SoftwareProvider{}
+}
+
+// Name returns SW
+func (*SoftwareProvider) Name() string {
+	return SoftwareProviderName
+}
+
+// GenerateKey generates a new ECDSA key, the label being ignored
+func (*SoftwareProvider) GenerateKey(label string) (crypto.Signer, error) {
+	key, err := NewECDSAKey()
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GetKey returns an error as the keys are kept in the keystore of the node
+func (*SoftwareProvider) GetKey(label string) (crypto.Signer, error) {
+	return nil, errors.New("The software crypto provider keeps no keys.")
+}
+
+// Sign signs msg with the ECDSA or Ed25519 key key
+func (*SoftwareProvider) Sign(key interface{}, msg []byte) ([]byte, error) {
+	if IsEd25519Key(key) {
+		return Ed25519Sign(key, msg)
+	}
+	return ECDSASign(key, msg)
+}
+
+// Verify verifies the ECDSA or Ed25519 signature of msg
+func (*SoftwareProvider) Verify(key interface{}, msg, signature []byte) (bool, error) {
+	if IsEd25519Key(key) {
+		return Ed25519Verify(key, msg, signature)
+	}
+	return ECDSAVerify(key, msg, signature)
+}
+
+// Encrypt encrypts msg with AES in CBC mode
+func (*SoftwareProvider) Encrypt(key, msg []byte) ([]byte, error) {
+	return CBCPKCS7Encrypt(key, msg)
+}
+
+// Decrypt decrypts ciphertext with AES in CBC mode
+func (*SoftwareProvider) Decrypt(key, ciphertext []byte) ([]byte, error) {
+	return CBCPKCS7Decrypt(key, ciphertext)
+}
+
+// Hash hashes msg with the default hash function
+func (*SoftwareProvider) Hash(msg []byte) []byte {
+	return Hash(msg)
+}
+
+// NewHash returns a new default hash function
+func (*SoftwareProvider) NewHash() hash.Hash {
+	return NewHash()
+}
@@ -0,0 +1,369 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// StreamSegmentSize is the size of the plaintext segments sealed one at a
+// time by the stream encrypters
+const StreamSegmentSize = 64 * 1024
+
+// streamNonceSize is the size of the nonce of the ciphers of the streams: a
+// random prefix, the index of the segment and a flag set on the last segment
+const streamNonceSize = 12
+
+// StreamPrefixSize is the size of the nonce prefix of a stream
+const StreamPrefixSize = streamNonceSize - 5
+
+var (
+	// ErrStreamTooLong the stream has more segments than nonces
+	ErrStreamTooLong = errors.New("Stream too long.")
+
+	// ErrStreamClosed the stream encrypter is closed
+	ErrStreamClosed = errors.New("Stream closed.")
+)
+
+// NewGCM returns AES in GCM mode with key
+func NewGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// NewCBCHMAC returns AES in CBC mode with PKCS7 padding, authenticated by
+// HMAC-SHA256 in an encrypt-then-mac construction. The encryption and the
+// mac keys are derived from key, and the IV from the nonce, which must be
+// unique for key.
+func NewCBCHMAC(key []byte) (cipher.AEAD, error) {
+	encKey := HMACTruncated(key, []byte{1}, AESKeyLength)
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	return &cbcHMAC{block: block, macKey: HMAC(key, []byte{2})}, nil
+}
+
+type cbcHMAC struct {
+	block  cipher.Block
+	macKey []byte
+}
+
+func (c *cbcHMAC) NonceSize() int {
+	return streamNonceSize
+}
+
+// Overhead is the size of a full padding block and of the tag
+func (c *cbcHMAC) Overhead() int {
+	return aes.BlockSize + sha256.Size
+}
+
+func (c *cbcHMAC) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	size := len(plaintext) + padding
+	ret, out := sliceForAppend(dst, size+sha256.Size)
+
+	ct := out[:size]
+	copy(ct, plaintext)
+	for i := len(plaintext); i < size; i++ {
+		ct[i] = byte(padding)
+	}
+	cipher.NewCBCEncrypter(c.block, c.iv(nonce)).CryptBlocks(ct, ct)
+
+	c.tag(out[size:size], nonce, additionalData, ct)
+	return ret
+}
+
+func (c *cbcHMAC) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	size := len(ciphertext) - sha256.Size
+	if size < aes.BlockSize || size%aes.BlockSize != 0 {
+		return nil, ErrDecryption
+	}
+	tag := c.tag(make([]byte, 0, sha256.Size), nonce, additionalData, ciphertext[:size])
+	if subtle.ConstantTimeCompare(tag, ciphertext[size:]) != 1 {
+		return nil, ErrDecryption
+	}
+
+	ret, out := sliceForAppend(dst, size)
+	cipher.NewCBCDecrypter(c.block, c.iv(nonce)).CryptBlocks(out, ciphertext[:size])
+	padding := int(out[size-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, ErrDecryption
+	}
+	return ret[:len(ret)-padding], nil
+}
+
+// iv returns the encryption of nonce, which is unpredictable as long as
+// nonces are unique
+func (c *cbcHMAC) iv(nonce []byte) []byte {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, nonce)
+	c.block.Encrypt(iv, iv)
+	return iv
+}
+
+// tag appends to dst the mac of nonce, additionalData and ciphertext
+func (c *cbcHMAC) tag(dst, nonce, additionalData, ciphertext []byte) []byte {
+	mac := hmac.New(sha256.New, c.macKey)
+	mac.Write(nonce)
+	binary.Write(mac, binary.BigEndian, uint64(len(additionalData)))
+	mac.Write(additionalData)
+	mac.Write(ciphertext)
+	return mac.Sum(dst)
+}
+
+// sliceForAppend extends in by n bytes, and returns the extended slice and
+// its last n bytes
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
+
+// NewGCMStreamEncrypter returns a writer encrypting with AES-GCM under key
+// what is written to it, and writing the ciphertext to w. The ciphertext is
+// complete once the writer is closed.
+func NewGCMStreamEncrypter(key []byte, w io.Writer, additionalData []byte) (io.WriteCloser, error) {
+	aead, err := NewGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return newRandomStreamEncrypter(aead, w, additionalData)
+}
+
+// NewGCMStreamDecrypter returns a reader of the plaintext of the ciphertext
+// read from r, written by a NewGCMStreamEncrypter writer
+func NewGCMStreamDecrypter(key []byte, r io.Reader, additionalData []byte) (io.Reader, error) {
+	aead, err := NewGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewStreamDecrypter(aead, r, additionalData)
+}
+
+// NewCBCHMACStreamEncrypter is NewGCMStreamEncrypter with NewCBCHMAC
+func NewCBCHMACStreamEncrypter(key []byte, w io.Writer, additionalData []byte) (io.WriteCloser, error) {
+	aead, err := NewCBCHMAC(key)
+	if err != nil {
+		return nil, err
+	}
+	return newRandomStreamEncrypter(aead, w, additionalData)
+}
+
+// NewCBCHMACStreamDecrypter is NewGCMStreamDecrypter with NewCBCHMAC
+func NewCBCHMACStreamDecrypter(key []byte, r io.Reader, additionalData []byte) (io.Reader, error) {
+	aead, err := NewCBCHMAC(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewStreamDecrypter(aead, r, additionalData)
+}
+
+func newRandomStreamEncrypter(aead cipher.AEAD, w io.Writer, additionalData []byte) (io.WriteCloser, error) {
+	prefix, err := GetRandomBytes(StreamPrefixSize)
+	if err != nil {
+		return nil, err
+	}
+	return NewStreamEncrypter(aead, prefix, w, additionalData)
+}
+
+// NewStreamEncrypter returns a writer encrypting with aead what is written to
+// it, and writing the ciphertext to w, so that payloads of any size are
+// encrypted in constant memory. The plaintext is split in segments of
+// StreamSegmentSize bytes, each sealed with a nonce made of prefix, written
+// first to w, of its index, and of a flag set on the last one, so that
+// reordered, duplicated or truncated segments are detected. The prefix, of
+// StreamPrefixSize bytes, must not be used twice with the same key.
+func NewStreamEncrypter(aead cipher.AEAD, prefix []byte, w io.Writer, additionalData []byte) (io.WriteCloser, error) {
+	if aead.NonceSize() != streamNonceSize || len(prefix) != StreamPrefixSize {
+		return nil, ErrInvalidKeyParameter
+	}
+	s := &streamWriter{
+		aead:  aead,
+		w:     w,
+		ad:    additionalData,
+		nonce: make([]byte, streamNonceSize),
+		plain: make([]byte, 0, StreamSegmentSize),
+		out:   make([]byte, 0, StreamSegmentSize+aead.Overhead()),
+	}
+	copy(s.nonce, prefix)
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type streamWriter struct {
+	aead    cipher.AEAD
+	w       io.Writer
+	ad      []byte
+	nonce   []byte
+	counter uint32
+	plain   []byte
+	out     []byte
+	err     error
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if s.err != nil {
+			return n, s.err
+		}
+		// A full segment is sealed only once more data follows, as the
+		// last one is flagged
+		if len(s.plain) == StreamSegmentSize {
+			s.err = s.seal(false)
+			continue
+		}
+		m := copy(s.plain[len(s.plain):StreamSegmentSize], p)
+		s.plain = s.plain[:len(s.plain)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+// Close seals the last segment
+func (s *streamWriter) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.err = s.seal(true); s.err == nil {
+		s.err = ErrStreamClosed
+		return nil
+	}
+	return s.err
+}
+
+func (s *streamWriter) seal(last bool) error {
+	if s.counter == ^uint32(0) && !last {
+		return ErrStreamTooLong
+	}
+	streamNonce(s.nonce, s.counter, last)
+	s.out = s.aead.Seal(s.out[:0], s.nonce, s.plain, s.ad)
+	s.plain = s.plain[:0]
+	s.counter++
+	_, err := s.w.Write(s.out)
+	return err
+}
+
+// NewStreamDecrypter returns a reader of the plaintext of the ciphertext read
+// from r, written by a NewStreamEncrypter writer with aead. Read returns
+// ErrDecryption if the ciphertext is not authentic, and only returns
+// plaintext of segments already authenticated.
+func NewStreamDecrypter(aead cipher.AEAD, r io.Reader, additionalData []byte) (io.Reader, error) {
+	if aead.NonceSize() != streamNonceSize {
+		return nil, ErrInvalidKeyParameter
+	}
+	s := &streamReader{
+		aead:  aead,
+		r:     r,
+		ad:    additionalData,
+		nonce: make([]byte, streamNonceSize),
+		// One more byte than a segment tells whether it is the last one
+		in:    make([]byte, StreamSegmentSize+aead.Overhead()+1),
+		plain: make([]byte, 0, StreamSegmentSize),
+	}
+	if _, err := io.ReadFull(r, s.nonce[:StreamPrefixSize]); err != nil {
+		return nil, ErrDecryption
+	}
+	return s, nil
+}
+
+type streamReader struct {
+	aead    cipher.AEAD
+	r       io.Reader
+	ad      []byte
+	nonce   []byte
+	counter uint32
+	in      []byte
+	n       int
+	plain   []byte
+	out     []byte
+	err     error
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.out) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.err = s.open()
+	}
+	n := copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+// open authenticates and decrypts the next segment
+func (s *streamReader) open() error {
+	m, err := io.ReadFull(s.r, s.in[s.n:])
+	s.n += m
+	last := false
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+
+	size := s.n
+	if !last {
+		if s.counter == ^uint32(0) {
+			return ErrStreamTooLong
+		}
+		size--
+	}
+	streamNonce(s.nonce, s.counter, last)
+	s.plain, err = s.aead.Open(s.plain[:0], s.nonce, s.in[:size], s.ad)
+	if err != nil {
+		return ErrDecryption
+	}
+	s.out = s.plain
+	s.n = copy(s.in, s.in[size:s.n])
+	s.counter++
+	if last {
+		return io.EOF
+	}
+	return nil
+}
+
+// streamNonce sets the index and the last segment flag of nonce
+func streamNonce(nonce []byte, counter uint32, last bool) {
+	binary.BigEndian.PutUint32(nonce[StreamPrefixSize:], counter)
+	nonce[streamNonceSize-1] = 0
+	if last {
+		nonce[streamNonceSize-1] = 1
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+)
+
+type streamCipher struct {
+	name    string
+	encrypt func(key []byte, w io.Writer, ad []byte) (io.WriteCloser, error)
+	decrypt func(key []byte, r io.Reader, ad []byte) (io.Reader, error)
+}
+
+var streamCiphers = []streamCipher{
+	{"GCM", primitives.NewGCMStreamEncrypter, primitives.NewGCMStreamDecrypter},
+	{"CBC-HMAC", primitives.NewCBCHMACStreamEncrypter, primitives.NewCBCHMACStreamDecrypter},
+}
+
+func streamEncrypt(t *testing.T, c streamCipher, key, pt, ad []byte) []byte {
+	var ct bytes.Buffer
+	w, err := c.encrypt(key, &ct, ad)
+	if err != nil {
+		t.Fatalf("%s: failed creating the encrypter: %s", c.name, err)
+	}
+	// Write in pieces not aligned with the segments
+	for len(pt) > 0 {
+		n := 1000
+		if n > len(pt) {
+			n = len(pt)
+		}
+		if _, err = w.Write(pt[:n]); err != nil {
+			t.Fatalf("%s: failed encrypting: %s", c.name, err)
+		}
+		pt = pt[n:]
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("%s: failed encrypting: %s", c.name, err)
+	}
+	return ct.Bytes()
+}
+
+func streamDecrypt(c streamCipher, key, ct, ad []byte) ([]byte, error) {
+	r, err := c.decrypt(key, bytes.NewReader(ct), ad)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// TestStreamEncryptDecrypt encrypts and decrypts payloads of sizes around
+// the segment boundaries
+func TestStreamEncryptDecrypt(t *testing.T) {
+	key := make([]byte, primitives.AESKeyLength)
+	rand.Read(key)
+	ad := []byte("additional data")
+
+	for _, c := range streamCiphers {
+		for _, size := range []int{0, 1, 15, 16, primitives.StreamSegmentSize - 1, primitives.StreamSegmentSize,
+			primitives.StreamSegmentSize + 1, 2 * primitives.StreamSegmentSize, 3*primitives.StreamSegmentSize + 100} {
+			pt := make([]byte, size)
+			rand.Read(pt)
+
+			ct := streamEncrypt(t, c, key, pt, ad)
+			out, err := streamDecrypt(c, key, ct, ad)
+			if err != nil {
+				t.Fatalf("%s: failed decrypting %d bytes: %s", c.name, size, err)
+			}
+			if !bytes.Equal(pt, out) {
+				t.Fatalf("%s: Decrypt( Encrypt( ptext ) ) != ptext for %d bytes", c.name, size)
+			}
+		}
+	}
+}
+
+// TestStreamDecryptTampered verifies that modified, truncated or extended
+// ciphertexts are refused
+func TestStreamDecryptTampered(t *testing.T) {
+	key := make([]byte, primitives.AESKeyLength)
+	rand.Read(key)
+	pt := make([]byte, 2*primitives.StreamSegmentSize+10)
+	rand.Read(pt)
+
+	for _, c := range streamCiphers {
+		ct := streamEncrypt(t, c, key, pt, nil)
+
+		if _, err := streamDecrypt(c, key, ct, []byte("other")); err == nil {
+			t.Fatalf("%s: decrypting with other additional data should fail", c.name)
+		}
+
+		flipped := append([]byte(nil), ct...)
+		flipped[len(flipped)/2] ^= 1
+		if _, err := streamDecrypt(c, key, flipped, nil); err == nil {
+			t.Fatalf("%s: decrypting a modified ciphertext should fail", c.name)
+		}
+
+		// Drop the last segment
+		segment := len(ct) - primitives.StreamPrefixSize
+		segment -= len(streamEncrypt(t, c, key, pt[2*primitives.StreamSegmentSize:], nil)) - primitives.StreamPrefixSize
+		segment /= 2
+		if _, err := streamDecrypt(c, key, ct[:primitives.StreamPrefixSize+2*segment], nil); err == nil {
+			t.Fatalf("%s: decrypting a truncated ciphertext should fail", c.name)
+		}
+
+		if _, err := streamDecrypt(c, key, append(ct, 0), nil); err == nil {
+			t.Fatalf("%s: decrypting an extended ciphertext should fail", c.name)
+		}
+
+		// Swap the first two segments
+		swapped := append([]byte(nil), ct[:primitives.StreamPrefixSize]...)
+		swapped = append(swapped, ct[primitives.StreamPrefixSize+segment:primitives.StreamPrefixSize+2*segment]...)
+		swapped = append(swapped, ct[primitives.StreamPrefixSize:primitives.StreamPrefixSize+segment]...)
+		swapped = append(swapped, ct[primitives.StreamPrefixSize+2*segment:]...)
+		if _, err := streamDecrypt(c, key, swapped, nil); err == nil {
+			t.Fatalf("%s: decrypting reordered segments should fail", c.name)
+		}
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package primitives
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/rsa"
@@ -30,6 +32,7 @@ import (
 	"time"
 
 	"github.com/hyperledger/fabric/core/crypto/utils"
+	"golang.org/x/crypto/ed25519"
 )
 
 var (
@@ -46,6 +49,10 @@ var (
 
 	// TCertAttributesHeaders is the ASN1 object identifier of attributes header.
 	TCertAttributesHeaders = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 9}
+
+	// TCertChaincodes is the ASN1 object identifier of the names of the
+	// chaincodes a TCert may invoke, when the TCA restricts them.
+	TCertChaincodes = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 9, 2}
 )
 
 // DERToX509Certificate converts der to x509
@@ -218,14 +225,29 @@ func CheckCertPKAgainstSK(x509Cert *x509.Certificate, privateKey interface{}) er
 			return errors.New("Private key does not match public key")
 		}
 	case *ecdsa.PublicKey:
-		priv, ok := privateKey.(*ecdsa.PrivateKey)
-		if !ok {
+		var priv *ecdsa.PublicKey
+		switch sk := privateKey.(type) {
+		case *ecdsa.PrivateKey:
+			priv = &sk.PublicKey
+		case crypto.Signer:
+			// The private key is held elsewhere, e.g. by an HSM
+			priv, _ = sk.Public().(*ecdsa.PublicKey)
+		}
+		if priv == nil {
 			return errors.New("Private key type does not match public key type")
 
 		}
 		if pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
 			return errors.New("Private key does not match public key")
 		}
+	case ed25519.PublicKey:
+		priv, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return errors.New("Private key type does not match public key type")
+		}
+		if !bytes.Equal(pub, priv.Public().(ed25519.PublicKey)) {
+			return errors.New("Private key does not match public key")
+		}
 	default:
 		return errors.New("Unknown public key algorithm")
 	}
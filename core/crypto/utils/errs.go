@@ -84,6 +84,18 @@ var (
 
 	// ErrInvalidProtocolVersion Invalid protocol version
 	ErrInvalidProtocolVersion = errors.New("Invalid protocol version")
+
+	// ErrCertificateExpired Certificate used outside of its validity period
+	ErrCertificateExpired = errors.New("Certificate expired or not yet valid.")
+
+	// ErrCertificateRevoked Certificate revoked by its CA
+	ErrCertificateRevoked = errors.New("Certificate revoked.")
+
+	// ErrCredentialsDisabled Anonymous credentials not enabled
+	ErrCredentialsDisabled = errors.New("Anonymous credentials not enabled.")
+
+	// ErrChaincodeNotAllowed Transaction certificate restricted to other chaincodes
+	ErrChaincodeNotAllowed = errors.New("Chaincode not allowed by the transaction certificate.")
 )
 
 // ErrToString converts and error to a string. If the error is nil, it returns the string "<clean>"
@@ -0,0 +1,121 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// openChaincodeKeys returns the keys of a confidential chaincode which the
+// deploy message encrypted to this validator, and keeps them to decrypt the
+// transactions of the chaincode
+func (validator *validatorImpl) openChaincodeKeys(msg *chaincodeDeployMessage1_3) (*chaincodeKeys1_3, error) {
+	if validator.enrollPrivKey == nil {
+		return nil, errors.New("Opening chaincode keys requires an ECDSA enrollment key.")
+	}
+	keyID, err := enrollmentKeyID(&validator.enrollPrivKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, envelope := range msg.Envelopes {
+		if !bytes.Equal(envelope.KeyID, keyID) {
+			continue
+		}
+
+		enrollKey, err := validator.eciesSPI.NewPrivateKey(nil, validator.enrollPrivKey)
+		if err != nil {
+			return nil, err
+		}
+		cipher, err := validator.eciesSPI.NewAsymmetricCipherFromPrivateKey(enrollKey)
+		if err != nil {
+			validator.Errorf("Failed init decryption engine [%s].", err.Error())
+			return nil, err
+		}
+		raw, err := cipher.Process(envelope.Keys)
+		if err != nil {
+			validator.Errorf("Failed decrypting the chaincode keys: [%s].", err.Error())
+			return nil, err
+		}
+
+		keys := new(chaincodeKeys1_3)
+		if _, err = asn1.Unmarshal(raw, keys); err != nil {
+			validator.Errorf("Failed unmarshalling the chaincode keys: [%s].", err.Error())
+			return nil, err
+		}
+
+		if err = validator.storeChaincodeKeys(msg.KeyID, keys, raw); err != nil {
+			return nil, err
+		}
+		return keys, nil
+	}
+
+	return nil, errors.New("This validator is not authorized to execute the chaincode.")
+}
+
+// storeChaincodeKeys keeps the keys identified by keyID in the keystore
+func (validator *validatorImpl) storeChaincodeKeys(keyID []byte, keys *chaincodeKeys1_3, raw []byte) error {
+	validator.chaincodeKeysMutex.Lock()
+	defer validator.chaincodeKeysMutex.Unlock()
+
+	if _, ok := validator.chaincodeKeys[string(keyID)]; ok {
+		return nil
+	}
+	if err := validator.ks.storeKey(validator.conf.getChaincodeKeyFilename(keyID), raw); err != nil {
+		return err
+	}
+	if validator.chaincodeKeys == nil {
+		validator.chaincodeKeys = make(map[string]*chaincodeKeys1_3)
+	}
+	validator.chaincodeKeys[string(keyID)] = keys
+
+	return nil
+}
+
+// getChaincodeKeys returns the keys identified by keyID, which this validator
+// opened when executing the deployment of the chaincode
+func (validator *validatorImpl) getChaincodeKeys(keyID []byte) (*chaincodeKeys1_3, error) {
+	validator.chaincodeKeysMutex.Lock()
+	defer validator.chaincodeKeysMutex.Unlock()
+
+	if keys, ok := validator.chaincodeKeys[string(keyID)]; ok {
+		return keys, nil
+	}
+
+	alias := validator.conf.getChaincodeKeyFilename(keyID)
+	if !validator.ks.isAliasSet(alias) {
+		return nil, fmt.Errorf("Unknown chaincode key [% x], the validator is not authorized to execute the chaincode or did not execute its deployment.", keyID)
+	}
+	raw, err := validator.ks.loadKey(alias)
+	if err != nil {
+		return nil, err
+	}
+	keys := new(chaincodeKeys1_3)
+	if _, err = asn1.Unmarshal(raw, keys); err != nil {
+		return nil, err
+	}
+
+	if validator.chaincodeKeys == nil {
+		validator.chaincodeKeys = make(map[string]*chaincodeKeys1_3)
+	}
+	validator.chaincodeKeys[string(keyID)] = keys
+
+	return keys, nil
+}
@@ -47,7 +47,8 @@ func (validator *validatorImpl) deepCloneTransaction(tx *obc.Transaction) (*obc.
 
 func (validator *validatorImpl) deepCloneAndDecryptTx(tx *obc.Transaction) (*obc.Transaction, error) {
 	switch tx.ConfidentialityProtocolVersion {
-	case "1.2":
+	case "1.2", "1.3":
+		// 1.3 only differs in the distribution of the keys
 		return validator.deepCloneAndDecryptTx1_2(tx)
 	}
 	return nil, utils.ErrInvalidProtocolVersion
@@ -72,22 +73,8 @@ func (validator *validatorImpl) deepCloneAndDecryptTx1_2(tx *obc.Transaction) (*
 	validator.Debug("Extract transaction key...")
 
 	// Derive transaction key
-	cipher, err := validator.eciesSPI.NewAsymmetricCipherFromPrivateKey(validator.chainPrivateKey)
-	if err != nil {
-		validator.Errorf("Failed init decryption engine [%s].", err.Error())
-		return nil, err
-	}
-
-	msgToValidatorsRaw, err := cipher.Process(tx.ToValidators)
-	if err != nil {
-		validator.Errorf("Failed decrypting message to validators [% x]: [%s].", tx.ToValidators, err.Error())
-		return nil, err
-	}
-
-	msgToValidators := new(chainCodeValidatorMessage1_2)
-	_, err = asn1.Unmarshal(msgToValidatorsRaw, msgToValidators)
+	msgToValidators, err := validator.getMessageToValidators(tx)
 	if err != nil {
-		validator.Errorf("Failed unmarshalling message to validators [%s].", err.Error())
 		return nil, err
 	}
 
@@ -100,7 +87,7 @@ func (validator *validatorImpl) deepCloneAndDecryptTx1_2(tx *obc.Transaction) (*
 
 	validator.Debug("Extract transaction key...done")
 
-	cipher, err = validator.eciesSPI.NewAsymmetricCipherFromPrivateKey(ccPrivateKey)
+	cipher, err := validator.eciesSPI.NewAsymmetricCipherFromPrivateKey(ccPrivateKey)
 	if err != nil {
 		validator.Errorf("Failed init transaction decryption engine [%s].", err.Error())
 		return nil, err
@@ -133,3 +120,74 @@ func (validator *validatorImpl) deepCloneAndDecryptTx1_2(tx *obc.Transaction) (*
 
 	return clone, nil
 }
+
+// getMessageToValidators decrypts the transaction and state keys of tx. With
+// confidentiality protocol 1.2 they are encrypted with the chain key. With
+// 1.3, a deploy transaction holds the keys of the chaincode encrypted to each
+// authorized validator, and the other transactions are encrypted with the
+// chaincode key.
+func (validator *validatorImpl) getMessageToValidators(tx *obc.Transaction) (*chainCodeValidatorMessage1_2, error) {
+	var (
+		cipher primitives.AsymmetricCipher
+		encMsg = tx.ToValidators
+		err    error
+	)
+
+	switch tx.ConfidentialityProtocolVersion {
+	case "1.2":
+		cipher, err = validator.eciesSPI.NewAsymmetricCipherFromPrivateKey(validator.chainPrivateKey)
+		if err != nil {
+			validator.Errorf("Failed init decryption engine [%s].", err.Error())
+			return nil, err
+		}
+	case "1.3":
+		if tx.Type == obc.Transaction_CHAINCODE_DEPLOY {
+			deployMsg := new(chaincodeDeployMessage1_3)
+			if _, err = asn1.Unmarshal(tx.ToValidators, deployMsg); err != nil {
+				validator.Errorf("Failed unmarshalling message to validators [%s].", err.Error())
+				return nil, err
+			}
+			keys, err := validator.openChaincodeKeys(deployMsg)
+			if err != nil {
+				validator.Errorf("Failed opening the chaincode keys [%s].", err.Error())
+				return nil, err
+			}
+
+			return &chainCodeValidatorMessage1_2{PrivateKey: keys.ChaincodeKey, StateKey: keys.StateKey}, nil
+		}
+
+		executeMsg := new(chaincodeExecuteMessage1_3)
+		if _, err = asn1.Unmarshal(tx.ToValidators, executeMsg); err != nil {
+			validator.Errorf("Failed unmarshalling message to validators [%s].", err.Error())
+			return nil, err
+		}
+		keys, err := validator.getChaincodeKeys(executeMsg.KeyID)
+		if err != nil {
+			validator.Errorf("Failed getting the chaincode keys [%s].", err.Error())
+			return nil, err
+		}
+		cipher, err = validator.eciesSPI.NewAsymmetricCipherFromSerializedPrivateKey(keys.ChaincodeKey)
+		if err != nil {
+			validator.Errorf("Failed init decryption engine [%s].", err.Error())
+			return nil, err
+		}
+		encMsg = executeMsg.Message
+	default:
+		return nil, utils.ErrInvalidProtocolVersion
+	}
+
+	msgToValidatorsRaw, err := cipher.Process(encMsg)
+	if err != nil {
+		validator.Errorf("Failed decrypting message to validators [% x]: [%s].", encMsg, err.Error())
+		return nil, err
+	}
+
+	msgToValidators := new(chainCodeValidatorMessage1_2)
+	_, err = asn1.Unmarshal(msgToValidatorsRaw, msgToValidators)
+	if err != nil {
+		validator.Errorf("Failed unmarshalling message to validators [%s].", err.Error())
+		return nil, err
+	}
+
+	return msgToValidators, nil
+}
@@ -18,6 +18,7 @@ package crypto
 
 import (
 	"crypto/ecdsa"
+	"sync"
 
 	"fmt"
 
@@ -33,6 +34,11 @@ type validatorImpl struct {
 
 	// Chain
 	chainPrivateKey primitives.PrivateKey
+
+	// The keys of the confidential chaincodes this validator is authorized
+	// to execute, by identifier
+	chaincodeKeysMutex sync.Mutex
+	chaincodeKeys      map[string]*chaincodeKeys1_3
 }
 
 // TransactionPreValidation verifies that the transaction is
@@ -46,6 +52,21 @@ func (validator *validatorImpl) TransactionPreValidation(tx *obc.Transaction) (*
 	return validator.peerImpl.TransactionPreValidation(tx)
 }
 
+// TransactionsPreValidation verifies the transactions of a batch as
+// TransactionPreValidation does, in parallel, and returns the error
+// of each transaction, nil if it is well formed.
+func (validator *validatorImpl) TransactionsPreValidation(txs []*obc.Transaction) []error {
+	if !validator.isInitialized {
+		errs := make([]error, len(txs))
+		for i := range errs {
+			errs[i] = utils.ErrNotInitialized
+		}
+		return errs
+	}
+
+	return validator.peerImpl.TransactionsPreValidation(txs)
+}
+
 // TransactionPreValidation verifies that the transaction is
 // well formed with the respect to the security layer
 // prescriptions (i.e. signature verification). If this is the case,
@@ -60,9 +81,9 @@ func (validator *validatorImpl) TransactionPreExecution(tx *obc.Transaction) (*o
 
 	switch tx.ConfidentialityLevel {
 	case obc.ConfidentialityLevel_PUBLIC:
-		// Nothing to do here!
+		// Decrypt the arguments encrypted for this validator, if any
 
-		return tx, nil
+		return validator.decryptArguments(tx)
 	case obc.ConfidentialityLevel_CONFIDENTIAL:
 		validator.Debug("Clone and Decrypt.")
 
@@ -74,7 +95,20 @@ func (validator *validatorImpl) TransactionPreExecution(tx *obc.Transaction) (*o
 			return nil, err
 		}
 
-		return newTx, nil
+		// The chaincode could not be checked against the certificate
+		// before decryption
+		if newTx.Cert != nil {
+			cert, err := primitives.DERToX509Certificate(newTx.Cert)
+			if err != nil {
+				return nil, err
+			}
+			if err = verifyTransactionChaincode(newTx, cert); err != nil {
+				auditTransaction(newTx, cert, err)
+				return nil, err
+			}
+		}
+
+		return validator.decryptArguments(newTx)
 	default:
 		return nil, utils.ErrInvalidConfidentialityLevel
 	}
@@ -107,7 +141,7 @@ func (validator *validatorImpl) Verify(vkID, signature, message []byte) error {
 		return err
 	}
 
-	vk := cert.PublicKey.(*ecdsa.PublicKey)
+	vk := cert.PublicKey
 
 	ok, err := validator.verify(vk, message, signature)
 	if err != nil {
@@ -118,6 +152,7 @@ func (validator *validatorImpl) Verify(vkID, signature, message []byte) error {
 
 	if !ok {
 		validator.Errorf("Failed invalid signature for [% x]", vkID)
+		auditMessage(vkID, cert, utils.ErrInvalidSignature)
 
 		return utils.ErrInvalidSignature
 	}
@@ -18,11 +18,11 @@ package crypto
 
 import (
 	"errors"
+	"io"
 	"reflect"
 
 	"crypto/aes"
 	"crypto/cipher"
-	"encoding/asn1"
 	"encoding/binary"
 
 	"github.com/hyperledger/fabric/core/crypto/primitives"
@@ -32,7 +32,8 @@ import (
 
 func (validator *validatorImpl) GetStateEncryptor(deployTx, executeTx *obc.Transaction) (StateEncryptor, error) {
 	switch executeTx.ConfidentialityProtocolVersion {
-	case "1.2":
+	case "1.2", "1.3":
+		// 1.3 only differs in the distribution of the keys
 		return validator.getStateEncryptor1_2(deployTx, executeTx)
 	}
 
@@ -111,22 +112,8 @@ func (validator *validatorImpl) getStateEncryptor1_2(deployTx, executeTx *obc.Tr
 }
 
 func (validator *validatorImpl) getStateKeyFromTransaction(tx *obc.Transaction) ([]byte, error) {
-	cipher, err := validator.eciesSPI.NewAsymmetricCipherFromPrivateKey(validator.chainPrivateKey)
+	msgToValidators, err := validator.getMessageToValidators(tx)
 	if err != nil {
-		validator.Errorf("Failed init decryption engine [%s].", err.Error())
-		return nil, err
-	}
-
-	msgToValidatorsRaw, err := cipher.Process(tx.ToValidators)
-	if err != nil {
-		validator.Errorf("Failed decrypting message to validators [% x]: [%s].", tx.ToValidators, err.Error())
-		return nil, err
-	}
-
-	msgToValidators := new(chainCodeValidatorMessage1_2)
-	_, err = asn1.Unmarshal(msgToValidatorsRaw, msgToValidators)
-	if err != nil {
-		validator.Errorf("Failed unmarshalling message to validators [% x]: [%s].", msgToValidators, err.Error())
 		return nil, err
 	}
 
@@ -232,6 +219,43 @@ func (se *stateEncryptorImpl) Decrypt(raw []byte) ([]byte, error) {
 	return out, nil
 }
 
+// EncryptStream encrypts in segments what is written to the returned writer,
+// so that large values are encrypted in constant memory. As with Encrypt, the
+// ciphertext only depends on the transaction and on the values encrypted
+// before, so that all validators write the same state.
+func (se *stateEncryptorImpl) EncryptStream(w io.Writer) (io.WriteCloser, error) {
+	var b = make([]byte, 8)
+	binary.BigEndian.PutUint64(b, se.counter)
+
+	se.node.Debugf("Encrypting stream with counter [% x].", b)
+
+	prefix := primitives.HMACTruncated(se.nonceStateKey, append([]byte{5}, b...), primitives.StreamPrefixSize)
+
+	se.counter++
+
+	if _, err := w.Write(se.invokeTxNonce); err != nil {
+		return nil, err
+	}
+	return primitives.NewStreamEncrypter(se.gcmEnc, prefix, w, se.invokeTxNonce)
+}
+
+// DecryptStream decrypts the ciphertext read from r in segments
+func (se *stateEncryptorImpl) DecryptStream(r io.Reader) (io.Reader, error) {
+	// The ciphertext consists of (txNonce, stream)
+	txNonce := make([]byte, primitives.NonceSize)
+	if _, err := io.ReadFull(r, txNonce); err != nil {
+		return nil, utils.ErrDecrypt
+	}
+
+	key := primitives.HMACTruncated(se.deployTxKey, append([]byte{3}, txNonce...), primitives.AESKeyLength)
+	gcm, err := primitives.NewGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return primitives.NewStreamDecrypter(gcm, r, txNonce)
+}
+
 type queryStateEncryptor struct {
 	node *nodeImpl
 
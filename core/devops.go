@@ -24,6 +24,8 @@ import (
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 
 	"encoding/asn1"
 	"encoding/base64"
@@ -32,10 +34,16 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/chaincode"
 	"github.com/hyperledger/fabric/core/chaincode/platforms"
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/container"
 	crypto "github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/core/quorum"
+	"github.com/hyperledger/fabric/core/trace"
+	"github.com/hyperledger/fabric/core/txstatus"
 	"github.com/hyperledger/fabric/core/util"
+	"github.com/hyperledger/fabric/flogging"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -47,6 +55,11 @@ func NewDevopsServer(coord peer.MessageHandlerCoordinator) *Devops {
 	d.coord = coord
 	d.isSecurityEnabled = viper.GetBool("security.enabled")
 	d.bindingMap = &bindingMap{m: make(map[string]crypto.TransactionHandler)}
+	d.transactionLimiter = comm.NewRateLimiter("peer.rateLimit.transactions")
+	d.queryLimiter = comm.NewRateLimiter("peer.rateLimit.queries")
+	if d.quorum, d.quorumErr = quorum.NewPolicy("peer.admin.quorum"); d.quorumErr != nil {
+		devopsLogger.Errorf("Refusing deployments: %s", d.quorumErr)
+	}
 	return d
 }
 
@@ -58,9 +71,24 @@ type bindingMap struct {
 
 // Devops implementation of Devops services
 type Devops struct {
-	coord             peer.MessageHandlerCoordinator
-	isSecurityEnabled bool
-	bindingMap        *bindingMap
+	coord              peer.MessageHandlerCoordinator
+	isSecurityEnabled  bool
+	bindingMap         *bindingMap
+	transactionLimiter *comm.RateLimiter
+	queryLimiter       *comm.RateLimiter
+	quorum             *quorum.Policy
+	quorumErr          error
+	// batchLock keeps the transactions of a batch consecutive
+	batchLock sync.Mutex
+}
+
+// admit refuses the request in ctx with codes.ResourceExhausted if its
+// client exceeded the rate of limiter
+func admit(ctx context.Context, limiter *comm.RateLimiter) error {
+	if err := limiter.Admit(comm.ClientIdentity(ctx)); err != nil {
+		return grpc.Errorf(codes.ResourceExhausted, "%s", err)
+	}
+	return nil
 }
 
 func (b *bindingMap) getKeyFromBinding(binding []byte) string {
@@ -86,6 +114,41 @@ func (b *bindingMap) getTxHandlerForBinding(binding []byte) (crypto.TransactionH
 	return txHandler, nil
 }
 
+// trackSubmission records the transaction as queued for consensus, or as
+// rejected if the network refused it, for the clients polling its status
+func trackSubmission(tx *pb.Transaction, resp *pb.Response) {
+	if resp.Status == pb.Response_SUCCESS {
+		txstatus.Default().Queued(tx.Uuid)
+	} else {
+		txstatus.Default().Rejected(tx.Uuid, string(resp.Msg))
+	}
+}
+
+// executeTransaction hands tx to the validators. Its submission is traced
+// as part of the trace of ctx or else of tx, if any, and its context passed
+// on with tx for the validators to trace the following stages.
+func (d *Devops) executeTransaction(ctx context.Context, tx *pb.Transaction) *pb.Response {
+	parent := trace.FromContext(ctx)
+	if !parent.IsValid() {
+		parent = trace.FromTransaction(tx)
+	}
+	span := trace.StartSpan("submit", trace.KindServer, parent)
+	span.SetTransaction(tx)
+	if c := span.Context(); c.IsValid() {
+		tx.TraceContext = c.String()
+	} else if parent.IsValid() {
+		tx.TraceContext = parent.String()
+	}
+
+	resp := d.coord.ExecuteTransaction(tx)
+	if resp.Status != pb.Response_SUCCESS {
+		span.Finish(errors.New(string(resp.Msg)))
+	} else {
+		span.Finish(nil)
+	}
+	return resp
+}
+
 // Login establishes the security context with the Devops service
 func (d *Devops) Login(ctx context.Context, secret *pb.Secret) (*pb.Response, error) {
 	if err := crypto.RegisterClient(secret.EnrollId, nil, secret.EnrollId, secret.EnrollSecret); nil != err {
@@ -146,11 +209,11 @@ func (*Devops) getChaincodeBytes(context context.Context, spec *pb.ChaincodeSpec
 
 // Deploy deploys the supplied chaincode image to the validators through a transaction
 func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
-	// get the deployment spec
-	chaincodeDeploymentSpec, err := d.getChaincodeBytes(ctx, spec)
-
+	if err := admit(ctx, d.transactionLimiter); err != nil {
+		return nil, err
+	}
+	chaincodeDeploymentSpec, err := d.newDeploymentSpec(ctx, spec)
 	if err != nil {
-		devopsLogger.Error(fmt.Sprintf("Error deploying chaincode spec: %v\n\n error: %s", spec, err))
 		return nil, err
 	}
 
@@ -192,10 +255,9 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 		}
 	}
 
-	if devopsLogger.IsEnabledFor(logging.DEBUG) {
-		devopsLogger.Debugf("Sending deploy transaction (%s) to validator", tx.Uuid)
-	}
-	resp := d.coord.ExecuteTransaction(tx)
+	flogging.WithTx(devopsLogger, tx.Uuid, chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name).Debugf("Sending deploy transaction to validator")
+	resp := d.executeTransaction(ctx, tx)
+	trackSubmission(tx, resp)
 	if resp.Status == pb.Response_FAILURE {
 		err = fmt.Errorf(string(resp.Msg))
 	}
@@ -203,26 +265,80 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 	return chaincodeDeploymentSpec, err
 }
 
+// newDeploymentSpec returns the deployment spec of spec, refusing the
+// deployments the validators would reject for lack of approvals
+func (d *Devops) newDeploymentSpec(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	if err := setMetadataEntries(spec); err != nil {
+		return nil, err
+	}
+	// get the deployment spec
+	chaincodeDeploymentSpec, err := d.getChaincodeBytes(ctx, spec)
+
+	if err != nil {
+		devopsLogger.Error(fmt.Sprintf("Error deploying chaincode spec: %v\n\n error: %s", spec, err))
+		return nil, err
+	}
+
+	name := chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name
+	if d.quorumErr != nil {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "%s", d.quorumErr)
+	}
+	if err = d.quorum.Verify(quorum.OperationDeploy, quorum.DeployPayload(name), spec.Approvals, false); err != nil {
+		return nil, grpc.Errorf(codes.PermissionDenied, "Chaincode %s: %s", name, err)
+	}
+	return chaincodeDeploymentSpec, nil
+}
+
 func (d *Devops) invokeOrQuery(ctx context.Context, chaincodeInvocationSpec *pb.ChaincodeInvocationSpec, attributes []string, invoke bool) (*pb.Response, error) {
 
 	if chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name == "" {
 		return nil, fmt.Errorf("name not given for invoke/query")
 	}
 
-	// Now create the Transactions message and send to Peer.
+	transaction, sec, err := d.newExecTx(chaincodeInvocationSpec, attributes, invoke)
+	if nil != sec {
+		defer crypto.CloseClient(sec)
+	}
+	if err != nil {
+		return nil, err
+	}
+	flogging.WithTx(devopsLogger, transaction.Uuid, chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name).Debugf("Sending invocation transaction to validator")
+	resp := d.executeTransaction(ctx, transaction)
+	if invoke {
+		trackSubmission(transaction, resp)
+	}
+	if resp.Status == pb.Response_FAILURE || resp.Status == pb.Response_SATURATED {
+		err = fmt.Errorf(string(resp.Msg))
+	} else {
+		if !invoke && nil != sec && viper.GetBool("security.privacy") {
+			if resp.Msg, err = sec.DecryptQueryResult(transaction, resp.Msg); nil != err {
+				devopsLogger.Errorf("Failed decrypting query transaction result %s", string(resp.Msg[:]))
+				//resp = &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}
+			}
+		}
+	}
+	return resp, err
+}
+
+// newExecTx creates the invocation or query transaction of
+// chaincodeInvocationSpec, together with the security client which signed it
+// if security is enabled. The caller must close that client.
+func (d *Devops) newExecTx(chaincodeInvocationSpec *pb.ChaincodeInvocationSpec, attributes []string, invoke bool) (*pb.Transaction, crypto.Client, error) {
+	if err := setMetadataEntries(chaincodeInvocationSpec.ChaincodeSpec); err != nil {
+		return nil, nil, err
+	}
 	var customIDgenAlg = strings.ToLower(chaincodeInvocationSpec.IdGenerationAlg)
 	var id string
 	var generr error
 	if customIDgenAlg != "" {
 		id, generr = util.GenerateIDWithAlg(customIDgenAlg, chaincodeInvocationSpec.ChaincodeSpec.CtorMsg.Args[0])
 		if generr != nil {
-			return nil, generr
+			return nil, nil, generr
 		}
 	} else {
 		id = util.GenerateUUID()
 	}
 	devopsLogger.Infof("Transaction ID: %v", id)
-	var transaction *pb.Transaction
 	var err error
 	var sec crypto.Client
 	if peer.SecurityEnabled() {
@@ -230,33 +346,32 @@ func (d *Devops) invokeOrQuery(ctx context.Context, chaincodeInvocationSpec *pb.
 			devopsLogger.Debugf("Initializing secure devops using context %s", chaincodeInvocationSpec.ChaincodeSpec.SecureContext)
 		}
 		sec, err = crypto.InitClient(chaincodeInvocationSpec.ChaincodeSpec.SecureContext, nil)
-		defer crypto.CloseClient(sec)
 		// remove the security context since we are no longer need it down stream
 		chaincodeInvocationSpec.ChaincodeSpec.SecureContext = ""
 		if nil != err {
-			return nil, err
+			return nil, sec, err
 		}
 	}
 
-	transaction, err = d.createExecTx(chaincodeInvocationSpec, attributes, id, invoke, sec)
-	if err != nil {
-		return nil, err
+	transaction, err := d.createExecTx(chaincodeInvocationSpec, attributes, id, invoke, sec)
+	return transaction, sec, err
+}
+
+// setMetadataEntries encodes the metadata entries of spec as the metadata of
+// its transaction, in place of the entries
+func setMetadataEntries(spec *pb.ChaincodeSpec) error {
+	if len(spec.MetadataEntries) == 0 {
+		return nil
 	}
-	if devopsLogger.IsEnabledFor(logging.DEBUG) {
-		devopsLogger.Debugf("Sending invocation transaction (%s) to validator", transaction.Uuid)
+	if len(spec.Metadata) != 0 {
+		return fmt.Errorf("Chaincode spec cannot have both metadata and metadataEntries")
 	}
-	resp := d.coord.ExecuteTransaction(transaction)
-	if resp.Status == pb.Response_FAILURE {
-		err = fmt.Errorf(string(resp.Msg))
-	} else {
-		if !invoke && nil != sec && viper.GetBool("security.privacy") {
-			if resp.Msg, err = sec.DecryptQueryResult(transaction, resp.Msg); nil != err {
-				devopsLogger.Errorf("Failed decrypting query transaction result %s", string(resp.Msg[:]))
-				//resp = &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}
-			}
-		}
+	metadata, err := pb.NewTransactionMetadata(spec.MetadataEntries)
+	if err != nil {
+		return err
 	}
-	return resp, err
+	spec.Metadata, spec.MetadataEntries = metadata, nil
+	return nil
 }
 
 func (d *Devops) createExecTx(spec *pb.ChaincodeInvocationSpec, attributes []string, uuid string, invokeTx bool, sec crypto.Client) (*pb.Transaction, error) {
@@ -296,14 +411,173 @@ func (d *Devops) createExecTx(spec *pb.ChaincodeInvocationSpec, attributes []str
 
 // Invoke performs the supplied invocation on the specified chaincode through a transaction
 func (d *Devops) Invoke(ctx context.Context, chaincodeInvocationSpec *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+	if err := admit(ctx, d.transactionLimiter); err != nil {
+		return nil, err
+	}
 	return d.invokeOrQuery(ctx, chaincodeInvocationSpec, chaincodeInvocationSpec.ChaincodeSpec.Attributes, true)
 }
 
+// InvokeBatch performs the invocations of batch through one transaction each,
+// returning the response of each in the order of the batch. The transactions
+// are all created before any is submitted, so an invalid invocation fails the
+// whole batch, and are then submitted consecutively so that consensus may
+// order them into the same block. Should one be refused, the ones after it
+// are not submitted and fail too.
+func (d *Devops) InvokeBatch(ctx context.Context, batch *pb.ChaincodeInvocationBatch) (*pb.BatchResponse, error) {
+	if len(batch.Invocations) == 0 {
+		return nil, errors.New("Empty batch")
+	}
+	if max := viper.GetInt("peer.devops.maxBatchSize"); max > 0 && len(batch.Invocations) > max {
+		return nil, fmt.Errorf("Batch of %d invocations exceeds the maximum of %d", len(batch.Invocations), max)
+	}
+	for i, invocation := range batch.Invocations {
+		if invocation.ChaincodeSpec == nil || invocation.ChaincodeSpec.ChaincodeID == nil || invocation.ChaincodeSpec.ChaincodeID.Name == "" {
+			return nil, fmt.Errorf("Invocation %d: name not given for invoke", i)
+		}
+	}
+	for range batch.Invocations {
+		if err := admit(ctx, d.transactionLimiter); err != nil {
+			return nil, err
+		}
+	}
+
+	transactions := make([]*pb.Transaction, len(batch.Invocations))
+	for i, invocation := range batch.Invocations {
+		transaction, sec, err := d.newExecTx(invocation, invocation.ChaincodeSpec.Attributes, true)
+		if nil != sec {
+			crypto.CloseClient(sec)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invocation %d: %s", i, err)
+		}
+		transactions[i] = transaction
+	}
+
+	d.batchLock.Lock()
+	defer d.batchLock.Unlock()
+	responses := make([]*pb.Response, len(transactions))
+	failed := -1
+	for i, transaction := range transactions {
+		if failed >= 0 {
+			responses[i] = &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Not submitted as invocation %d failed", failed))}
+			continue
+		}
+		flogging.WithTx(devopsLogger, transaction.Uuid, batch.Invocations[i].ChaincodeSpec.ChaincodeID.Name).Debugf("Sending invocation %d of batch to validator", i)
+		responses[i] = d.executeTransaction(ctx, transaction)
+		trackSubmission(transaction, responses[i])
+		if responses[i].Status != pb.Response_SUCCESS {
+			failed = i
+		}
+	}
+	return &pb.BatchResponse{Responses: responses}, nil
+}
+
 // Query performs the supplied query on the specified chaincode through a transaction
 func (d *Devops) Query(ctx context.Context, chaincodeInvocationSpec *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+	if err := admit(ctx, d.queryLimiter); err != nil {
+		return nil, err
+	}
 	return d.invokeOrQuery(ctx, chaincodeInvocationSpec, chaincodeInvocationSpec.ChaincodeSpec.Attributes, false)
 }
 
+// BuildTransaction creates the unsigned transaction of the deployment,
+// invocation or query of request, for the holder of the certificate of the
+// request to sign it away from the peer and submit it with
+// SubmitTransaction. The peer thus never holds the key of the signer. The
+// response carries the digest to sign, the hash of the transaction under the
+// hash algorithm of the peer. Confidential transactions are not supported, as
+// their encryption requires the keys of the signer.
+func (d *Devops) BuildTransaction(ctx context.Context, request *pb.UnsignedTransactionRequest) (*pb.UnsignedTransaction, error) {
+	spec := request.GetInvocationSpec().GetChaincodeSpec()
+	if spec == nil || spec.ChaincodeID == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "Chaincode spec not given")
+	}
+	if spec.ConfidentialityLevel == pb.ConfidentialityLevel_CONFIDENTIAL {
+		return nil, grpc.Errorf(codes.InvalidArgument, "Confidential transactions cannot be signed away from the peer")
+	}
+	if peer.SecurityEnabled() && len(request.Cert) == 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "Certificate of the signer not given")
+	}
+	// The signer authenticates the transaction, not a user logged in here
+	spec.SecureContext = ""
+
+	var tx *pb.Transaction
+	var err error
+	switch request.Type {
+	case pb.Transaction_CHAINCODE_DEPLOY:
+		var chaincodeDeploymentSpec *pb.ChaincodeDeploymentSpec
+		if chaincodeDeploymentSpec, err = d.newDeploymentSpec(ctx, spec); err != nil {
+			return nil, err
+		}
+		tx, err = pb.NewChaincodeDeployTransaction(chaincodeDeploymentSpec, chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name)
+	case pb.Transaction_CHAINCODE_INVOKE, pb.Transaction_CHAINCODE_QUERY:
+		if spec.ChaincodeID.Name == "" {
+			return nil, grpc.Errorf(codes.InvalidArgument, "name not given for invoke/query")
+		}
+		if err = setMetadataEntries(spec); err != nil {
+			return nil, err
+		}
+		tx, err = pb.NewChaincodeExecute(request.InvocationSpec, util.GenerateUUID(), request.Type)
+	default:
+		return nil, grpc.Errorf(codes.InvalidArgument, "Unsupported transaction type %s", request.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tx.Nonce, err = primitives.GetRandomNonce(); err != nil {
+		return nil, err
+	}
+	tx.Cert = request.Cert
+
+	raw, err := proto.Marshal(tx)
+	if err != nil {
+		return nil, err
+	}
+	devopsLogger.Debugf("Built unsigned transaction %s", tx.Uuid)
+	return &pb.UnsignedTransaction{Transaction: tx, Digest: primitives.Hash(raw)}, nil
+}
+
+// SubmitTransaction submits tx, a transaction built by BuildTransaction and
+// signed away from the peer. With security enabled, the signature of tx is
+// verified before it is submitted.
+func (d *Devops) SubmitTransaction(ctx context.Context, tx *pb.Transaction) (*pb.Response, error) {
+	limiter := d.transactionLimiter
+	switch tx.Type {
+	case pb.Transaction_CHAINCODE_QUERY:
+		limiter = d.queryLimiter
+	case pb.Transaction_CHAINCODE_DEPLOY, pb.Transaction_CHAINCODE_INVOKE:
+	default:
+		return nil, grpc.Errorf(codes.InvalidArgument, "Unsupported transaction type %s", tx.Type)
+	}
+	if err := admit(ctx, limiter); err != nil {
+		return nil, err
+	}
+	if tx.Uuid == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "Transaction ID not given")
+	}
+	if peer.SecurityEnabled() {
+		if len(tx.Signature) == 0 {
+			return nil, grpc.Errorf(codes.Unauthenticated, "Transaction %s is not signed", tx.Uuid)
+		}
+		if sec := d.coord.GetSecHelper(); sec != nil {
+			if _, err := sec.TransactionPreValidation(tx); err != nil {
+				return nil, grpc.Errorf(codes.PermissionDenied, "Transaction %s: %s", tx.Uuid, err)
+			}
+		}
+	}
+
+	flogging.WithTx(devopsLogger, tx.Uuid, "").Debugf("Sending signed transaction to validator")
+	resp := d.executeTransaction(ctx, tx)
+	if tx.Type != pb.Transaction_CHAINCODE_QUERY {
+		trackSubmission(tx, resp)
+	}
+	var err error
+	if resp.Status == pb.Response_FAILURE || resp.Status == pb.Response_SATURATED {
+		err = errors.New(string(resp.Msg))
+	}
+	return resp, err
+}
+
 // CheckSpec to see if chaincode resides within current package capture for language.
 func CheckSpec(spec *pb.ChaincodeSpec) error {
 	// Don't allow nil value
@@ -455,7 +729,7 @@ func (d *Devops) EXP_ExecuteWithBinding(ctx context.Context, executeWithBinding
 			return nil, fmt.Errorf("Error creating executing with binding:  %s", err)
 		}
 
-		return d.coord.ExecuteTransaction(tx), nil
+		return d.executeTransaction(ctx, tx), nil
 		//return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte("NOT IMPLEMENTED")}, nil
 
 		//return &pb.Response{Status: pb.Response_SUCCESS, Msg: sigmaOutputBytes}, nil
@@ -17,10 +17,16 @@ limitations under the License.
 package core
 
 import (
+	"bytes"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/core/trace"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -92,3 +98,202 @@ func TestDevops_Spec_NoVersion(t *testing.T) {
 	t.Logf("Deploy result = %s, err = %s", buildResult, err)
 	//performHandshake(t, peerClientConn)
 }
+
+// batchCoordinator records the transactions executed, failing the one whose
+// function is "fail"
+type batchCoordinator struct {
+	peer.MessageHandlerCoordinator
+	executed []*pb.Transaction
+}
+
+func (c *batchCoordinator) ExecuteTransaction(tx *pb.Transaction) *pb.Response {
+	c.executed = append(c.executed, tx)
+	cis := &pb.ChaincodeInvocationSpec{}
+	if err := proto.Unmarshal(tx.Payload, cis); err == nil && cis.ChaincodeSpec.CtorMsg.Function == "fail" {
+		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte("failed")}
+	}
+	return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(tx.Uuid)}
+}
+
+func batchInvocation(name, function string) *pb.ChaincodeInvocationSpec {
+	return &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{
+		Type:        pb.ChaincodeSpec_GOLANG,
+		ChaincodeID: &pb.ChaincodeID{Name: name},
+		CtorMsg:     &pb.ChaincodeInput{Function: function},
+	}}
+}
+
+func TestDevops_InvokeBatch(t *testing.T) {
+	coord := &batchCoordinator{}
+	devopsServer := NewDevopsServer(coord)
+
+	if _, err := devopsServer.InvokeBatch(context.Background(), &pb.ChaincodeInvocationBatch{}); err == nil {
+		t.Error("Expected an error for an empty batch")
+	}
+
+	// An invalid invocation fails the whole batch before any is submitted
+	batch := &pb.ChaincodeInvocationBatch{Invocations: []*pb.ChaincodeInvocationSpec{
+		batchInvocation("mycc", "invoke"),
+		batchInvocation("", "invoke"),
+	}}
+	if _, err := devopsServer.InvokeBatch(context.Background(), batch); err == nil {
+		t.Error("Expected an error for an invocation without chaincode name")
+	}
+	if len(coord.executed) != 0 {
+		t.Fatalf("Expected no transaction submitted but got %d", len(coord.executed))
+	}
+
+	// Invocations after a failed one are not submitted
+	batch = &pb.ChaincodeInvocationBatch{Invocations: []*pb.ChaincodeInvocationSpec{
+		batchInvocation("mycc", "invoke"),
+		batchInvocation("mycc", "fail"),
+		batchInvocation("mycc", "invoke"),
+	}}
+	resp, err := devopsServer.InvokeBatch(context.Background(), batch)
+	if err != nil {
+		t.Fatalf("Error invoking batch: %s", err)
+	}
+	if len(coord.executed) != 2 {
+		t.Errorf("Expected 2 transactions submitted but got %d", len(coord.executed))
+	}
+	if len(resp.Responses) != 3 {
+		t.Fatalf("Expected 3 responses but got %d", len(resp.Responses))
+	}
+	if resp.Responses[0].Status != pb.Response_SUCCESS || string(resp.Responses[0].Msg) != coord.executed[0].Uuid {
+		t.Errorf("Expected the first invocation to succeed but got %v", resp.Responses[0])
+	}
+	if resp.Responses[1].Status != pb.Response_FAILURE || resp.Responses[2].Status != pb.Response_FAILURE {
+		t.Errorf("Expected the last invocations to fail but got %v", resp.Responses[1:])
+	}
+}
+
+func TestDevops_Invoke_MetadataEntries(t *testing.T) {
+	coord := &batchCoordinator{}
+	devopsServer := NewDevopsServer(coord)
+
+	invocation := batchInvocation("mycc", "invoke")
+	invocation.ChaincodeSpec.MetadataEntries = map[string]string{"order": "42"}
+	if _, err := devopsServer.Invoke(context.Background(), invocation); err != nil {
+		t.Fatalf("Error invoking: %s", err)
+	}
+	if len(coord.executed) != 1 {
+		t.Fatalf("Expected 1 transaction submitted but got %d", len(coord.executed))
+	}
+	entries, err := coord.executed[0].GetMetadataEntries()
+	if err != nil {
+		t.Fatalf("Error decoding the metadata of the transaction: %s", err)
+	}
+	if entries["order"] != "42" {
+		t.Errorf("Expected the metadata entries of the invocation but got %v", entries)
+	}
+
+	// Raw metadata and entries are exclusive
+	invocation = batchInvocation("mycc", "invoke")
+	invocation.ChaincodeSpec.Metadata = []byte("raw")
+	invocation.ChaincodeSpec.MetadataEntries = map[string]string{"order": "42"}
+	if _, err := devopsServer.Invoke(context.Background(), invocation); err == nil {
+		t.Error("Expected an error for both metadata and metadataEntries")
+	}
+}
+
+func TestDevops_BuildSubmitTransaction(t *testing.T) {
+	if err := primitives.SetSecurityLevel("SHA3", 256); err != nil {
+		t.Fatalf("Error setting the security level: %s", err)
+	}
+	coord := &batchCoordinator{}
+	devopsServer := NewDevopsServer(coord)
+
+	invocation := batchInvocation("mycc", "invoke")
+	invocation.ChaincodeSpec.MetadataEntries = map[string]string{"order": "42"}
+	request := &pb.UnsignedTransactionRequest{Type: pb.Transaction_CHAINCODE_INVOKE, InvocationSpec: invocation, Cert: []byte("cert")}
+	unsigned, err := devopsServer.BuildTransaction(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Error building the transaction: %s", err)
+	}
+	tx := unsigned.Transaction
+	if tx.Type != pb.Transaction_CHAINCODE_INVOKE || tx.Uuid == "" || string(tx.Cert) != "cert" || len(tx.Nonce) == 0 {
+		t.Errorf("Expected an invocation carrying the certificate but got %v", tx)
+	}
+	if entries, err := tx.GetMetadataEntries(); err != nil || entries["order"] != "42" {
+		t.Errorf("Expected the metadata entries of the invocation but got %v (%v)", entries, err)
+	}
+	raw, err := proto.Marshal(tx)
+	if err != nil {
+		t.Fatalf("Error marshalling the transaction: %s", err)
+	}
+	if !bytes.Equal(unsigned.Digest, primitives.Hash(raw)) {
+		t.Error("Expected the digest to be the hash of the transaction")
+	}
+	if len(coord.executed) != 0 {
+		t.Fatalf("Expected no transaction submitted on build but got %d", len(coord.executed))
+	}
+
+	tx.Signature = []byte("signature")
+	resp, err := devopsServer.SubmitTransaction(context.Background(), tx)
+	if err != nil {
+		t.Fatalf("Error submitting the transaction: %s", err)
+	}
+	if resp.Status != pb.Response_SUCCESS || len(coord.executed) != 1 || coord.executed[0].Uuid != tx.Uuid {
+		t.Errorf("Expected the transaction to be submitted but got %v", resp)
+	}
+
+	// Failures of the transaction are reported
+	payload, err := proto.Marshal(batchInvocation("mycc", "fail"))
+	if err != nil {
+		t.Fatalf("Error marshalling the invocation: %s", err)
+	}
+	if _, err = devopsServer.SubmitTransaction(context.Background(), &pb.Transaction{Type: pb.Transaction_CHAINCODE_INVOKE, Uuid: "1",
+		Payload: payload}); err == nil {
+		t.Error("Expected an error for a failed transaction")
+	}
+
+	for _, request := range []*pb.UnsignedTransactionRequest{
+		{Type: pb.Transaction_CHAINCODE_INVOKE},
+		{Type: pb.Transaction_CHAINCODE_QUERY, InvocationSpec: batchInvocation("", "query")},
+		{Type: pb.Transaction_CHAINCODE_TERMINATE, InvocationSpec: batchInvocation("mycc", "invoke")},
+		{Type: pb.Transaction_CHAINCODE_INVOKE, InvocationSpec: &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{
+			ChaincodeID: &pb.ChaincodeID{Name: "mycc"}, ConfidentialityLevel: pb.ConfidentialityLevel_CONFIDENTIAL}}},
+	} {
+		if _, err = devopsServer.BuildTransaction(context.Background(), request); err == nil {
+			t.Errorf("Expected an error building %v", request)
+		}
+	}
+	if _, err = devopsServer.SubmitTransaction(context.Background(), &pb.Transaction{Type: pb.Transaction_CHAINCODE_TERMINATE, Uuid: "1"}); err == nil {
+		t.Error("Expected an error for an unsupported transaction type")
+	}
+}
+
+func TestDevops_Invoke_TraceContext(t *testing.T) {
+	coord := &batchCoordinator{}
+	devopsServer := NewDevopsServer(coord)
+	client, _ := trace.ParseSpanContext("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := trace.NewContext(context.Background(), client)
+
+	// Without tracing, the trace context of the client is passed on as is
+	viper.Set("peer.tracing.enabled", false)
+	if _, err := devopsServer.Invoke(ctx, batchInvocation("mycc", "invoke")); err != nil {
+		t.Fatalf("Error invoking: %s", err)
+	}
+	if traceContext := coord.executed[0].TraceContext; traceContext != client.String() {
+		t.Errorf("Expected the trace context of the client, got %q", traceContext)
+	}
+
+	// With tracing, the submission is traced as a child of the client span
+	viper.Set("peer.tracing.enabled", true)
+	viper.Set("peer.tracing.batchTimeout", "1h")
+	defer viper.Set("peer.tracing.enabled", false)
+	if _, err := devopsServer.Invoke(ctx, batchInvocation("mycc", "invoke")); err != nil {
+		t.Fatalf("Error invoking: %s", err)
+	}
+	submitted := trace.FromTransaction(coord.executed[1])
+	if submitted.TraceID != client.TraceID || submitted.SpanID == client.SpanID {
+		t.Errorf("Expected the context of a submission span in the trace of the client, got %s", submitted)
+	}
+
+	if _, err := devopsServer.Invoke(context.Background(), batchInvocation("mycc", "invoke")); err != nil {
+		t.Fatalf("Error invoking: %s", err)
+	}
+	if submitted = trace.FromTransaction(coord.executed[2]); !submitted.IsValid() || submitted.TraceID == client.TraceID {
+		t.Errorf("Expected the context of a submission span in a new trace, got %s", submitted)
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+
+	"github.com/hyperledger/fabric/core/comm"
+)
+
+var logger = logging.MustGetLogger("discovery")
+
+// SRVPrefix marks a root node entry as the name of DNS SRV records, e.g.
+// srv:_fabric._tcp.example.com, whose targets are the bootstrap peers
+const SRVPrefix = "srv:"
+
+// maxBackoffFactor caps the backoff of a failing endpoint, which doubles
+// with every failure
+const maxBackoffFactor = 8
+
+// Bootstrap is the list of peers a peer contacts to join the network. The
+// list is configured as root nodes, either addresses or DNS SRV names which
+// are resolved again periodically, so the network can be re-homed by
+// updating DNS. Endpoints which could not be contacted are skipped for a
+// growing backoff, so that the healthy ones are tried first.
+type Bootstrap struct {
+	sync.Mutex
+	entries []string            // configured root nodes
+	count   int                 // endpoints returned by Next, 0 for all
+	backoff time.Duration       // how long to skip an endpoint after its first failure
+	refresh time.Duration       // how often to resolve SRV names again
+	srv     map[string][]string // resolved addresses of the SRV entries
+	health  map[string]*endpointHealth
+
+	resolvedAt time.Time
+	now        func() time.Time
+	lookupSRV  func(service, proto, name string) (string, []*net.SRV, error)
+}
+
+type endpointHealth struct {
+	failures int
+	retryAt  time.Time
+}
+
+// NewBootstrap creates the bootstrap list from the comma separated root
+// nodes, Next returns count endpoints at a time, or all of them for 0
+func NewBootstrap(rootNodes string, count int, backoff, refresh time.Duration) *Bootstrap {
+	b := &Bootstrap{
+		count:     count,
+		backoff:   backoff,
+		refresh:   refresh,
+		srv:       make(map[string][]string),
+		health:    make(map[string]*endpointHealth),
+		now:       time.Now,
+		lookupSRV: net.LookupSRV,
+	}
+	for _, entry := range strings.Split(rootNodes, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		if !strings.HasPrefix(entry, SRVPrefix) {
+			// Written the way peers advertise their address, so the root
+			// nodes are recognized in the discovery list
+			if address, err := comm.NormalizeAddress(entry); err != nil {
+				logger.Warningf("Root node %s: %s", entry, err)
+			} else {
+				entry = address
+			}
+		}
+		b.entries = append(b.entries, entry)
+	}
+	return b
+}
+
+// Endpoints returns the addresses of all bootstrap peers, in configured
+// order, resolving the SRV names if they are due
+func (b *Bootstrap) Endpoints() []string {
+	b.Lock()
+	defer b.Unlock()
+	return b.endpoints()
+}
+
+func (b *Bootstrap) endpoints() []string {
+	if b.resolvedAt.IsZero() || (b.refresh > 0 && b.now().Sub(b.resolvedAt) >= b.refresh) {
+		b.resolve()
+	}
+	var addresses []string
+	for _, entry := range b.entries {
+		if strings.HasPrefix(entry, SRVPrefix) {
+			addresses = append(addresses, b.srv[entry]...)
+		} else {
+			addresses = append(addresses, entry)
+		}
+	}
+	return addresses
+}
+
+// resolve looks up the SRV entries, an entry which fails to resolve keeps
+// the addresses it last resolved to
+func (b *Bootstrap) resolve() {
+	b.resolvedAt = b.now()
+	for _, entry := range b.entries {
+		if !strings.HasPrefix(entry, SRVPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(entry, SRVPrefix)
+		_, records, err := b.lookupSRV("", "", name)
+		if err != nil {
+			logger.Warningf("Could not resolve bootstrap SRV name %s, using %v: %s", name, b.srv[entry], err)
+			continue
+		}
+		// Records are sorted by priority and randomized by weight
+		addresses := make([]string, len(records))
+		for i, record := range records {
+			addresses[i] = net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+		}
+		logger.Debugf("Resolved bootstrap SRV name %s to %v", name, addresses)
+		b.srv[entry] = addresses
+	}
+}
+
+// Next returns the bootstrap peers to contact, the ones which failed least
+// recently first. Endpoints backing off are skipped, unless all are, in
+// which case the one due first is returned so joining is never given up.
+func (b *Bootstrap) Next() []string {
+	b.Lock()
+	defer b.Unlock()
+
+	now := b.now()
+	var ready, waiting []string
+	for _, address := range b.endpoints() {
+		if h, ok := b.health[address]; ok && now.Before(h.retryAt) {
+			waiting = append(waiting, address)
+		} else {
+			ready = append(ready, address)
+		}
+	}
+	sort.Stable(byFailures{addresses: ready, health: b.health})
+
+	if len(ready) == 0 && len(waiting) > 0 {
+		first := waiting[0]
+		for _, address := range waiting[1:] {
+			if b.health[address].retryAt.Before(b.health[first].retryAt) {
+				first = address
+			}
+		}
+		ready = []string{first}
+	}
+	if b.count > 0 && len(ready) > b.count {
+		ready = ready[:b.count]
+	}
+	return ready
+}
+
+// byFailures orders addresses by how often they failed in a row
+type byFailures struct {
+	addresses []string
+	health    map[string]*endpointHealth
+}
+
+func (bf byFailures) Len() int { return len(bf.addresses) }
+func (bf byFailures) Swap(i, j int) {
+	bf.addresses[i], bf.addresses[j] = bf.addresses[j], bf.addresses[i]
+}
+func (bf byFailures) Less(i, j int) bool {
+	return bf.failures(bf.addresses[i]) < bf.failures(bf.addresses[j])
+}
+
+func (bf byFailures) failures(address string) int {
+	if h, ok := bf.health[address]; ok {
+		return h.failures
+	}
+	return 0
+}
+
+// Failed records that the bootstrap peer at address could not be contacted
+func (b *Bootstrap) Failed(address string) {
+	b.Lock()
+	defer b.Unlock()
+	if !inArray(address, b.endpoints()) {
+		return
+	}
+	h, ok := b.health[address]
+	if !ok {
+		h = &endpointHealth{}
+		b.health[address] = h
+	}
+	h.failures++
+	factor := maxBackoffFactor
+	if h.failures <= 3 {
+		factor = 1 << uint(h.failures-1)
+	}
+	h.retryAt = b.now().Add(time.Duration(factor) * b.backoff)
+	logger.Debugf("Bootstrap peer %s failed %d times, skipping it until %v", address, h.failures, h.retryAt)
+}
+
+// Succeeded records that the bootstrap peer at address was contacted
+func (b *Bootstrap) Succeeded(address string) {
+	b.Lock()
+	defer b.Unlock()
+	delete(b.health, address)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestBootstrap(rootNodes string, count int) (*Bootstrap, *time.Time) {
+	now := time.Unix(1000, 0)
+	b := NewBootstrap(rootNodes, count, 10*time.Second, time.Minute)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBootstrapStaticRootNodes(t *testing.T) {
+	b, _ := newTestBootstrap(" a:1, b:2 ,,c:3", 0)
+	expected := []string{"a:1", "b:2", "c:3"}
+	if endpoints := b.Next(); !reflect.DeepEqual(endpoints, expected) {
+		t.Fatalf("Expected %v, got %v", expected, endpoints)
+	}
+	b, _ = newTestBootstrap("[2001:DB8::0:1]:30303", 0)
+	if endpoints := b.Next(); !reflect.DeepEqual(endpoints, []string{"[2001:db8::1]:30303"}) {
+		t.Fatalf("Expected the IPv6 root node in canonical form, got %v", endpoints)
+	}
+	if endpoints := NewBootstrap("", 0, 0, 0).Next(); len(endpoints) != 0 {
+		t.Fatalf("Expected no root nodes, got %v", endpoints)
+	}
+}
+
+func TestBootstrapSRV(t *testing.T) {
+	b, now := newTestBootstrap("a:1,srv:_fabric._tcp.example.com", 0)
+	targets := []*net.SRV{{Target: "vp0.example.com.", Port: 30303}, {Target: "vp1.example.com.", Port: 30304}}
+	lookups := 0
+	b.lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		lookups++
+		if name != "_fabric._tcp.example.com" {
+			return "", nil, fmt.Errorf("unexpected name %s", name)
+		}
+		if targets == nil {
+			return "", nil, fmt.Errorf("lookup failed")
+		}
+		return "", targets, nil
+	}
+
+	expected := []string{"a:1", "vp0.example.com:30303", "vp1.example.com:30304"}
+	if endpoints := b.Endpoints(); !reflect.DeepEqual(endpoints, expected) {
+		t.Fatalf("Expected %v, got %v", expected, endpoints)
+	}
+
+	// Re-homed in DNS, but not resolved again before the refresh period
+	targets = []*net.SRV{{Target: "vp2.example.com.", Port: 30303}}
+	b.Endpoints()
+	if lookups != 1 {
+		t.Fatalf("Expected a single lookup within the refresh period, got %d", lookups)
+	}
+	*now = now.Add(time.Minute)
+	expected = []string{"a:1", "vp2.example.com:30303"}
+	if endpoints := b.Endpoints(); !reflect.DeepEqual(endpoints, expected) {
+		t.Fatalf("Expected %v after the refresh, got %v", expected, endpoints)
+	}
+
+	// A failed lookup keeps the last resolved addresses
+	targets = nil
+	*now = now.Add(time.Minute)
+	if endpoints := b.Endpoints(); !reflect.DeepEqual(endpoints, expected) {
+		t.Fatalf("Expected %v after a failed lookup, got %v", expected, endpoints)
+	}
+}
+
+func TestBootstrapRotation(t *testing.T) {
+	b, now := newTestBootstrap("a:1,b:2,c:3", 1)
+
+	if endpoints := b.Next(); !reflect.DeepEqual(endpoints, []string{"a:1"}) {
+		t.Fatalf("Expected the first root node, got %v", endpoints)
+	}
+	b.Failed("a:1")
+	if endpoints := b.Next(); !reflect.DeepEqual(endpoints, []string{"b:2"}) {
+		t.Fatalf("Expected to rotate to the second root node, got %v", endpoints)
+	}
+	b.Failed("b:2")
+	b.Failed("c:3")
+	if endpoints := b.Next(); !reflect.DeepEqual(endpoints, []string{"a:1"}) {
+		t.Fatalf("Expected the root node due first while all back off, got %v", endpoints)
+	}
+
+	// After the backoff, the root nodes which failed least often come first
+	*now = now.Add(10 * time.Second)
+	b.Failed("a:1")
+	if endpoints := b.Next(); !reflect.DeepEqual(endpoints, []string{"b:2"}) {
+		t.Fatalf("Expected the root node which failed least, got %v", endpoints)
+	}
+	b.Succeeded("b:2")
+	b.Failed("b:2")
+	if h := b.health["b:2"]; h.failures != 1 {
+		t.Errorf("Expected success to reset the failures, got %d", h.failures)
+	}
+	if h := b.health["a:1"]; h.retryAt != now.Add(20*time.Second) {
+		t.Errorf("Expected the backoff to double, retry at %v", h.retryAt)
+	}
+
+	b.Failed("unknown:1")
+	if _, ok := b.health["unknown:1"]; ok {
+		t.Errorf("Expected failures of other peers not to be tracked")
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genesis
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/ledger"
+)
+
+// The state of the genesis block records the crypto configuration of the
+// chain under a namespace no chaincode can be deployed with
+const (
+	stateNamespace  = "genesis:"
+	cryptoConfigKey = "crypto"
+	genesisTxID     = "genesis"
+)
+
+// CryptoConfig holds the hash family and the security level, which selects
+// the curve and the hash length, the peers of a chain work with
+type CryptoConfig struct {
+	HashAlgorithm string `json:"hashAlgorithm"`
+	Level         int    `json:"level"`
+}
+
+// configuredCryptoConfig returns ledger.blockchain.genesisBlock.crypto, which
+// defaults to security.hashAlgorithm and security.level
+func configuredCryptoConfig() (*CryptoConfig, error) {
+	config := &CryptoConfig{
+		HashAlgorithm: viper.GetString("ledger.blockchain.genesisBlock.crypto.hashAlgorithm"),
+		Level:         viper.GetInt("ledger.blockchain.genesisBlock.crypto.level"),
+	}
+	if config.HashAlgorithm == "" {
+		config.HashAlgorithm = viper.GetString("security.hashAlgorithm")
+	}
+	if config.HashAlgorithm == "" {
+		config.HashAlgorithm = "SHA3"
+	}
+	if config.Level == 0 {
+		config.Level = viper.GetInt("security.level")
+	}
+	if config.Level == 0 {
+		config.Level = 256
+	}
+	if err := primitives.CheckSecurityLevel(config.HashAlgorithm, config.Level); err != nil {
+		return nil, fmt.Errorf("Invalid ledger.blockchain.genesisBlock.crypto: %s", err)
+	}
+	return config, nil
+}
+
+// ChainCryptoConfig returns the crypto configuration recorded in the genesis
+// block of the chain. A peer without a chain yet, or with a chain created
+// before the configuration was recorded, gets the configured one.
+func ChainCryptoConfig() (*CryptoConfig, error) {
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	if ledger.GetBlockchainSize() == 0 {
+		return configuredCryptoConfig()
+	}
+	raw, err := ledger.GetState(stateNamespace, cryptoConfigKey, true)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		genesisLogger.Info("The genesis block does not record the crypto configuration of the chain, using the configured one")
+		return configuredCryptoConfig()
+	}
+	config := &CryptoConfig{}
+	if err = json.Unmarshal(raw, config); err != nil {
+		return nil, fmt.Errorf("Error reading the crypto configuration of the chain: %s", err)
+	}
+	if err = primitives.CheckSecurityLevel(config.HashAlgorithm, config.Level); err != nil {
+		return nil, fmt.Errorf("Unsupported crypto configuration of the chain: %s", err)
+	}
+	return config, nil
+}
+
+// recordCryptoConfig records the configured crypto configuration in the
+// state of the genesis block, so that its hash binds the chain to it. The
+// genesis block of a peer recording it thus differs from the one of a peer
+// predating it, and such peers can't form a network together.
+func recordCryptoConfig(ledger *ledger.Ledger) error {
+	config, err := configuredCryptoConfig()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	ledger.TxBegin(genesisTxID)
+	err = ledger.SetState(stateNamespace, cryptoConfigKey, raw)
+	ledger.TxFinished(genesisTxID, err == nil)
+	if err != nil {
+		return err
+	}
+	genesisLogger.Infof("The chain works with %s at security level [%d]", config.HashAlgorithm, config.Level)
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genesis
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/ledger"
+)
+
+// setGenesisCrypto sets ledger.blockchain.genesisBlock.crypto
+func setGenesisCrypto(hashAlgorithm string, level int) {
+	viper.Set("ledger.blockchain.genesisBlock.crypto.hashAlgorithm", hashAlgorithm)
+	viper.Set("ledger.blockchain.genesisBlock.crypto.level", level)
+}
+
+// makeGenesisWithoutCrypto creates the genesis block as peers did before it
+// recorded the crypto configuration of the chain
+func makeGenesisWithoutCrypto(t *testing.T, l *ledger.Ledger) {
+	if err := l.BeginTxBatch(0); err != nil {
+		t.Fatalf("Error beginning the genesis batch, %s", err)
+	}
+	if err := l.CommitTxBatch(0, nil, nil, nil); err != nil {
+		t.Fatalf("Error committing the genesis batch, %s", err)
+	}
+}
+
+func TestChainCryptoConfigOverridesLocalLevel(t *testing.T) {
+	defer setGenesisCrypto("", 0)
+	defer primitives.SetSecurityLevel(primitives.GetHashAlgorithm(), primitives.GetSecurityLevel())
+
+	l := ledger.InitTestLedger(t)
+	setGenesisCrypto("SHA2", 384)
+	if err := makeGenesis(l); err != nil {
+		t.Fatalf("Error creating genesis block, %s", err)
+	}
+
+	// The peer is then configured differently, as peer/main.go finds it
+	setGenesisCrypto("SHA3", 256)
+	if err := primitives.SetSecurityLevel("SHA3", 256); err != nil {
+		t.Fatal(err)
+	}
+	config, err := ChainCryptoConfig()
+	if err != nil {
+		t.Fatalf("Error reading the crypto configuration of the chain, %s", err)
+	}
+	if err = crypto.SetChainSecurityLevel(config.HashAlgorithm, config.Level); err != nil {
+		t.Fatalf("Error setting the security level of the chain, %s", err)
+	}
+	if primitives.GetHashAlgorithm() != "SHA2" || primitives.GetSecurityLevel() != 384 {
+		t.Fatalf("Expected the crypto layer to work with SHA2 at security level 384, but it works with %s at %d",
+			primitives.GetHashAlgorithm(), primitives.GetSecurityLevel())
+	}
+}
+
+func TestChainCryptoConfigWithoutRecord(t *testing.T) {
+	defer setGenesisCrypto("", 0)
+
+	// A peer without a chain works with its configuration
+	l := ledger.InitTestLedger(t)
+	setGenesisCrypto("SHA2", 256)
+	config, err := ChainCryptoConfig()
+	if err != nil {
+		t.Fatalf("Error reading the crypto configuration without a chain, %s", err)
+	}
+	if config.HashAlgorithm != "SHA2" || config.Level != 256 {
+		t.Fatalf("Expected SHA2 at security level 256, but got %s at %d", config.HashAlgorithm, config.Level)
+	}
+
+	// So does a peer with a chain whose genesis block has no genesis:crypto
+	makeGenesisWithoutCrypto(t, l)
+	if raw, _ := l.GetState(stateNamespace, cryptoConfigKey, true); raw != nil {
+		t.Fatalf("Expected no crypto configuration in the genesis block, but got %s", raw)
+	}
+	setGenesisCrypto("SHA3", 384)
+	config, err = ChainCryptoConfig()
+	if err != nil {
+		t.Fatalf("Error reading the crypto configuration of a chain without one, %s", err)
+	}
+	if config.HashAlgorithm != "SHA3" || config.Level != 384 {
+		t.Fatalf("Expected SHA3 at security level 384, but got %s at %d", config.HashAlgorithm, config.Level)
+	}
+
+	// Whose configuration must still be supported
+	setGenesisCrypto("MD5", 128)
+	if _, err = ChainCryptoConfig(); err == nil {
+		t.Fatalf("Expected an unsupported configuration to be refused")
+	}
+}
+
+func TestCryptoConfigChangesGenesisStateHash(t *testing.T) {
+	defer setGenesisCrypto("", 0)
+	setGenesisCrypto("SHA3", 256)
+
+	l := ledger.InitTestLedger(t)
+	makeGenesisWithoutCrypto(t, l)
+	before, err := l.GetBlockByNumber(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l = ledger.InitTestLedger(t)
+	if err = makeGenesis(l); err != nil {
+		t.Fatalf("Error creating genesis block, %s", err)
+	}
+	after, err := l.GetBlockByNumber(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Peers recording the crypto configuration can't share a chain with the
+	// ones predating it
+	if bytes.Equal(before.StateHash, after.StateHash) {
+		t.Fatalf("Expected the crypto configuration to change the state hash of the genesis block")
+	}
+}
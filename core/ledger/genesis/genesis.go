@@ -29,7 +29,8 @@ var makeGenesisError error
 var once sync.Once
 
 // MakeGenesis creates the genesis block based on configuration in core.yaml
-// and adds it to the blockchain.
+// and adds it to the blockchain. The state of the genesis block records the
+// crypto configuration of the chain.
 func MakeGenesis() error {
 	once.Do(func() {
 		ledger, err := ledger.GetLedger()
@@ -37,13 +38,22 @@ func MakeGenesis() error {
 			makeGenesisError = err
 			return
 		}
-
-		if ledger.GetBlockchainSize() == 0 {
-			genesisLogger.Info("Creating genesis block.")
-			if makeGenesisError = ledger.BeginTxBatch(0); makeGenesisError == nil {
-				makeGenesisError = ledger.CommitTxBatch(0, nil, nil, nil)
-			}
-		}
+		makeGenesisError = makeGenesis(ledger)
 	})
 	return makeGenesisError
 }
+
+func makeGenesis(ledger *ledger.Ledger) error {
+	if ledger.GetBlockchainSize() != 0 {
+		return nil
+	}
+	genesisLogger.Info("Creating genesis block.")
+	if err := ledger.BeginTxBatch(0); err != nil {
+		return err
+	}
+	if err := recordCryptoConfig(ledger); err != nil {
+		ledger.RollbackTxBatch(0)
+		return err
+	}
+	return ledger.CommitTxBatch(0, nil, nil, nil)
+}
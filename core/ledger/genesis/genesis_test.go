@@ -71,6 +71,8 @@ func TestGenesis(t *testing.T) {
 	go grpcServer.Serve(lis)
 
 	ledger := ledger.InitTestLedger(t)
+	viper.Set("ledger.blockchain.genesisBlock.crypto.hashAlgorithm", "SHA2")
+	viper.Set("ledger.blockchain.genesisBlock.crypto.level", 384)
 
 	if ledger.GetBlockchainSize() != 0 {
 		t.Fatalf("Expected blockchain size of 0, but got %d", ledger.GetBlockchainSize())
@@ -83,6 +85,17 @@ func TestGenesis(t *testing.T) {
 	if ledger.GetBlockchainSize() != 1 {
 		t.Fatalf("Expected blockchain size of 1, but got %d", ledger.GetBlockchainSize())
 	}
+
+	// The chain keeps the crypto configuration of its genesis block
+	viper.Set("ledger.blockchain.genesisBlock.crypto.hashAlgorithm", "SHA3")
+	viper.Set("ledger.blockchain.genesisBlock.crypto.level", 256)
+	config, err := ChainCryptoConfig()
+	if err != nil {
+		t.Fatalf("Error reading the crypto configuration of the chain, %s", err)
+	}
+	if config.HashAlgorithm != "SHA2" || config.Level != 384 {
+		t.Fatalf("Expected SHA2 at security level 384, but got %s at %d", config.HashAlgorithm, config.Level)
+	}
 }
 
 func setupTestConfig() {
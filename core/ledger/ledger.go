@@ -20,13 +20,18 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+	"github.com/hyperledger/fabric/core/trace"
+	"github.com/hyperledger/fabric/core/txstatus"
 	"github.com/hyperledger/fabric/events/producer"
+	"github.com/hyperledger/fabric/flogging"
 	"github.com/op/go-logging"
 	"github.com/tecbot/gorocksdb"
 
@@ -104,6 +109,7 @@ func GetNewLedger() (*Ledger, error) {
 	}
 
 	state := state.NewState()
+	blockchainHeight.Set(float64(blockchain.getSize()))
 	return &Ledger{blockchain, state, nil}, nil
 }
 
@@ -148,9 +154,11 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 	if err != nil {
 		return err
 	}
+	start := time.Now()
 
 	stateHash, err := ledger.state.GetHash()
 	if err != nil {
+		commitFailures.Inc()
 		ledger.resetForNextTxGroup(false)
 		ledger.blockchain.blockPersistenceStatus(false)
 		return err
@@ -162,6 +170,7 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 	block.NonHashData = &protos.NonHashData{}
 	newBlockNumber, err := ledger.blockchain.addPersistenceChangesForNewBlock(context.TODO(), block, stateHash, writeBatch)
 	if err != nil {
+		commitFailures.Inc()
 		ledger.resetForNextTxGroup(false)
 		ledger.blockchain.blockPersistenceStatus(false)
 		return err
@@ -171,6 +180,7 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 	defer opt.Destroy()
 	dbErr := db.GetDBHandle().DB.Write(opt, writeBatch)
 	if dbErr != nil {
+		commitFailures.Inc()
 		ledger.resetForNextTxGroup(false)
 		ledger.blockchain.blockPersistenceStatus(false)
 		return dbErr
@@ -178,7 +188,17 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 
 	ledger.resetForNextTxGroup(true)
 	ledger.blockchain.blockPersistenceStatus(true)
+	for _, tx := range transactions {
+		span := trace.StartTransactionSpan("commit", tx, start)
+		span.SetAttribute("fabric.block.number", strconv.FormatUint(newBlockNumber, 10))
+		span.Finish(nil)
+	}
+	commitDuration.ObserveSince(start)
+	blocksCommitted.Inc()
+	transactionsCommitted.Add(float64(len(transactions)))
+	blockchainHeight.Set(float64(newBlockNumber + 1))
 
+	markCommitted(block, newBlockNumber)
 	sendProducerBlockEvent(block)
 	if len(transactionResults) != 0 {
 		ledgerLogger.Debug("There were some erroneous transactions. We need to send a 'TX rejected' message here.")
@@ -373,6 +393,12 @@ func (ledger *Ledger) GetBlockByNumber(blockNumber uint64) (*protos.Block, error
 	return ledger.blockchain.getBlock(blockNumber)
 }
 
+// GetBlockByHash returns the block with the given hash, an Error of type
+// ErrorTypeBlockNotFound if no block has the hash
+func (ledger *Ledger) GetBlockByHash(blockHash []byte) (*protos.Block, error) {
+	return ledger.blockchain.getBlockByHash(blockHash)
+}
+
 // GetBlockchainSize returns number of blocks in blockchain
 func (ledger *Ledger) GetBlockchainSize() uint64 {
 	return ledger.blockchain.getSize()
@@ -383,6 +409,13 @@ func (ledger *Ledger) GetTransactionByUUID(txUUID string) (*protos.Transaction,
 	return ledger.blockchain.getTransactionByUUID(txUUID)
 }
 
+// GetTransactionBlockNumber returns the number of the block holding the
+// transaction with the given uuid
+func (ledger *Ledger) GetTransactionBlockNumber(txUUID string) (uint64, error) {
+	blockNumber, _, err := ledger.blockchain.indexer.fetchTransactionIndexByUUID(txUUID)
+	return blockNumber, err
+}
+
 // PutRawBlock puts a raw block on the chain. This function should only be
 // used for synchronization between peers.
 func (ledger *Ledger) PutRawBlock(block *protos.Block, blockNumber uint64) error {
@@ -390,10 +423,20 @@ func (ledger *Ledger) PutRawBlock(block *protos.Block, blockNumber uint64) error
 	if err != nil {
 		return err
 	}
+	blockchainHeight.Set(float64(ledger.blockchain.getSize()))
+	markCommitted(block, blockNumber)
 	sendProducerBlockEvent(block)
 	return nil
 }
 
+// markCommitted records the transactions of the block as committed for the
+// clients polling their status
+func markCommitted(block *protos.Block, blockNumber uint64) {
+	for _, tx := range block.Transactions {
+		txstatus.Default().Committed(tx.Uuid, blockNumber)
+	}
+}
+
 // VerifyChain will verify the integrity of the blockchain. This is accomplished
 // by ensuring that the previous block hash stored in each block matches
 // the actual hash of the previous block in the chain. The return value is the
@@ -466,6 +509,7 @@ func (ledger *Ledger) resetForNextTxGroup(txCommited bool) {
 }
 
 func sendProducerBlockEvent(block *protos.Block) {
+	start := time.Now()
 
 	// Remove payload from deploy transactions. This is done to make block
 	// events more lightweight as the payload for these types of transactions
@@ -476,18 +520,21 @@ func sendProducerBlockEvent(block *protos.Block) {
 			deploymentSpec := &protos.ChaincodeDeploymentSpec{}
 			err := proto.Unmarshal(transaction.Payload, deploymentSpec)
 			if err != nil {
-				ledgerLogger.Errorf("Error unmarshalling deployment transaction for block event: %s", err)
+				flogging.WithTx(ledgerLogger, transaction.Uuid, "").Errorf("Error unmarshalling deployment transaction for block event: %s", err)
 				continue
 			}
 			deploymentSpec.CodePackage = nil
 			deploymentSpecBytes, err := proto.Marshal(deploymentSpec)
 			if err != nil {
-				ledgerLogger.Errorf("Error marshalling deployment transaction for block event: %s", err)
+				flogging.WithTx(ledgerLogger, transaction.Uuid, "").Errorf("Error marshalling deployment transaction for block event: %s", err)
 				continue
 			}
 			transaction.Payload = deploymentSpecBytes
 		}
 	}
 
-	producer.Send(producer.CreateBlockEvent(block))
+	err := producer.Send(producer.CreateBlockEvent(block))
+	for _, transaction := range blockTransactions {
+		trace.StartTransactionSpan("emit", transaction, start).Finish(err)
+	}
 }
@@ -0,0 +1,34 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"github.com/hyperledger/fabric/core/metrics"
+)
+
+var (
+	blocksCommitted = metrics.NewCounter("fabric_ledger_blocks_committed_total",
+		"Blocks committed to the ledger by the consensus.")
+	transactionsCommitted = metrics.NewCounter("fabric_ledger_transactions_committed_total",
+		"Transactions of the blocks committed to the ledger by the consensus.")
+	commitFailures = metrics.NewCounter("fabric_ledger_commit_failures_total",
+		"Transaction batches which failed to be committed to the ledger.")
+	commitDuration = metrics.NewHistogram("fabric_ledger_commit_duration_seconds",
+		"Time taken to commit a transaction batch to the ledger.", metrics.DefaultBuckets)
+	blockchainHeight = metrics.NewGauge("fabric_ledger_height",
+		"Number of blocks of the blockchain.")
+)
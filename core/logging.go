@@ -22,6 +22,8 @@ import (
 
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/flogging"
 )
 
 // A logger to log logging logs!
@@ -31,14 +33,30 @@ var loggingLogger = logging.MustGetLogger("logging")
 // case of configuration errors.
 var loggingDefaultLevel = logging.INFO
 
+// Formats of the log records, selected by logging.format
+const (
+	loggingFormatText = "text"
+	loggingFormatJSON = "json"
+)
+
+// The format of the log records written to stderr
+var loggingFormat = loggingFormatText
+
+// The command LoggingInit was last called for, whose logging specification
+// is applied again when the configuration is reloaded
+var loggingCommand string
+
 // LoggingInit is a 'hook' called at the beginning of command processing to
 // parse logging-related options specified either on the command-line or in
 // config files.  Command-line options take precedence over config file
 // options, and can also be passed as suitably-named environment variables. To
 // change module logging levels at runtime call `logging.SetLevel(level,
 // module)`.  To debug this routine include logging=debug as the first
-// term of the logging specification.
+// term of the logging specification. The records are written in the format
+// of logging.format, the text format unless it is json.
 func LoggingInit(command string) {
+	loggingCommand = command
+	setLoggingFormat(viper.GetString("logging.format"))
 	// Parse the logging specification in the form
 	//     [<module>[,<module>...]=]<level>[:[<module>[,<module>...]=]<level>...]
 	defaultLevel := loggingDefaultLevel
@@ -82,19 +100,53 @@ func LoggingInit(command string) {
 	loggingLogger.Debugf("Setting default logging level to %s for command '%s'", defaultLevel, command)
 }
 
-// DefaultLoggingLevel returns the fallback value for loggers to use if parsing fails
-func DefaultLoggingLevel() logging.Level {
-	return loggingDefaultLevel
+// setLoggingFormat writes the log records in format from now on. The levels
+// of the modules are reset when the format changes.
+func setLoggingFormat(format string) {
+	if format != loggingFormatJSON {
+		format = loggingFormatText
+	}
+	if format == loggingFormat {
+		return
+	}
+	setLoggingBackend(loggingFormatter(format))
+	loggingFormat = format
 }
 
-// Initiate 'leveled' logging to stderr.
-func init() {
+// RevertLoggingLevels restores the levels of the logging specification of
+// the command LoggingInit was last called for, undoing the levels set since,
+// e.g. by the administrators, and returns the default level
+func RevertLoggingLevels() logging.Level {
+	setLoggingBackend(loggingFormatter(loggingFormat))
+	LoggingInit(loggingCommand)
+	return logging.GetLevel("")
+}
 
-	format := logging.MustStringFormatter(
+func loggingFormatter(format string) logging.Formatter {
+	if format == loggingFormatJSON {
+		return flogging.NewJSONFormatter(viper.GetString("peer.id"))
+	}
+	return loggingTextFormatter()
+}
+
+func loggingTextFormatter() logging.Formatter {
+	return logging.MustStringFormatter(
 		"%{color}%{time:15:04:05.000} [%{module}] %{shortfunc} -> %{level:.4s} %{id:03x}%{color:reset} %{message}",
 	)
+}
 
+func setLoggingBackend(formatter logging.Formatter) {
 	backend := logging.NewLogBackend(os.Stderr, "", 0)
-	backendFormatter := logging.NewBackendFormatter(backend, format)
+	backendFormatter := logging.NewBackendFormatter(backend, formatter)
 	logging.SetBackend(backendFormatter).SetLevel(loggingDefaultLevel, "")
 }
+
+// DefaultLoggingLevel returns the fallback value for loggers to use if parsing fails
+func DefaultLoggingLevel() logging.Level {
+	return loggingDefaultLevel
+}
+
+// Initiate 'leveled' logging to stderr.
+func init() {
+	setLoggingBackend(loggingTextFormatter())
+}
@@ -98,6 +98,25 @@ func TestLoggingLevelForSpecificCommand(t *testing.T) {
 	assertDefaultLoggingLevel(t, logging.ERROR)
 }
 
+func TestLoggingFormat(t *testing.T) {
+	viper.Reset()
+	viper.Set("logging.format", "json")
+	defer setLoggingFormat(loggingFormatText)
+
+	LoggingInit("")
+
+	if loggingFormat != loggingFormatJSON {
+		t.Fatalf("Expected the %s format, got %s", loggingFormatJSON, loggingFormat)
+	}
+
+	viper.Set("logging.format", "")
+	LoggingInit("")
+
+	if loggingFormat != loggingFormatText {
+		t.Fatalf("Expected the %s format, got %s", loggingFormatText, loggingFormat)
+	}
+}
+
 func TestLoggingLevelForUnknownCommandGoesToDefault(t *testing.T) {
 	viper.Reset()
 
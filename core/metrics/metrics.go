@@ -0,0 +1,365 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics holds the metrics of the peer, e.g. the number of blocks
+// committed or the latency of chaincode executions, and exposes them in the
+// Prometheus text format. Metrics are created once, as package variables of
+// the packages they measure, and registered by their constructor.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of the
+// histograms measuring latencies
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metric is a metric of the registry
+type metric interface {
+	write(w io.Writer)
+}
+
+var registry = struct {
+	sync.RWMutex
+	metrics map[string]metric
+}{metrics: make(map[string]metric)}
+
+// register adds the metric to the registry, it panics if a metric with the
+// same name exists as metrics are created once
+func register(name string, m metric) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.metrics[name]; ok {
+		panic(fmt.Sprintf("metrics: duplicate metric %s", name))
+	}
+	registry.metrics[name] = m
+}
+
+// desc describes a metric and its labels
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d *desc) writeHeader(w io.Writer) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, help, d.name, d.kind)
+}
+
+// key returns the key of the series with the label values, it panics if
+// their number differs from the number of labels
+func (d *desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", d.name, len(d.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// series formats the name and labels of a series, with extra labels, e.g.
+// the bound of a histogram bucket, given as name and value pairs
+func (d *desc) series(suffix string, labelValues []string, extra ...string) string {
+	pairs := make([]string, 0, len(labelValues)+len(extra)/2)
+	escape := strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	for i, value := range labelValues {
+		pairs = append(pairs, d.labels[i]+`="`+escape.Replace(value)+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escape.Replace(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return d.name + suffix
+	}
+	return d.name + suffix + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sample is the value of a series of a counter or gauge
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+// values holds the series of a counter or gauge by label values
+type values struct {
+	desc
+	lock    sync.Mutex
+	samples map[string]*sample
+}
+
+func (v *values) add(delta float64, labelValues []string) {
+	key := v.key(labelValues)
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{labelValues: append([]string(nil), labelValues...)}
+		v.samples[key] = s
+	}
+	s.value += delta
+}
+
+func (v *values) set(value float64, labelValues []string) {
+	key := v.key(labelValues)
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.samples[key] = &sample{labelValues: append([]string(nil), labelValues...), value: value}
+}
+
+func (v *values) write(w io.Writer) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if len(v.samples) == 0 && len(v.labels) != 0 {
+		return
+	}
+	v.writeHeader(w)
+	if len(v.samples) == 0 {
+		fmt.Fprintf(w, "%s 0\n", v.name)
+		return
+	}
+	keys := make([]string, 0, len(v.samples))
+	for key := range v.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := v.samples[key]
+		fmt.Fprintf(w, "%s %s\n", v.series("", s.labelValues), formatValue(s.value))
+	}
+}
+
+// Counter is a metric which only increases, e.g. the number of transactions
+// committed. It has a series for each combination of the values of its
+// labels.
+type Counter struct {
+	values
+}
+
+// NewCounter creates and registers a counter with the given labels
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{values{desc: desc{name, help, "counter", labels}, samples: make(map[string]*sample)}}
+	register(name, c)
+	return c
+}
+
+// Inc increments the counter of the series with the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+// Add adds delta, which must not be negative, to the counter of the series
+// with the label values
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.name))
+	}
+	c.add(delta, labelValues)
+}
+
+// Gauge is a metric which goes up and down, e.g. the number of requests
+// queued
+type Gauge struct {
+	values
+}
+
+// NewGauge creates and registers a gauge with the given labels
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{values{desc: desc{name, help, "gauge", labels}, samples: make(map[string]*sample)}}
+	register(name, g)
+	return g
+}
+
+// Set sets the gauge of the series with the label values
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.set(value, labelValues)
+}
+
+// Add adds delta, possibly negative, to the gauge of the series with the
+// label values
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.add(delta, labelValues)
+}
+
+// Inc increments the gauge of the series with the label values
+func (g *Gauge) Inc(labelValues ...string) {
+	g.add(1, labelValues)
+}
+
+// Dec decrements the gauge of the series with the label values
+func (g *Gauge) Dec(labelValues ...string) {
+	g.add(-1, labelValues)
+}
+
+// funcMetric is a metric without labels whose value is obtained when
+// exposed, for the values already kept by the package measured
+type funcMetric struct {
+	desc
+	value func() float64
+}
+
+func (f *funcMetric) write(w io.Writer) {
+	f.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", f.name, formatValue(f.value()))
+}
+
+// NewGaugeFunc registers a gauge whose value is returned by value, which
+// must be safe to call concurrently
+func NewGaugeFunc(name, help string, value func() float64) {
+	register(name, &funcMetric{desc{name: name, help: help, kind: "gauge"}, value})
+}
+
+// NewCounterFunc registers a counter whose value is returned by value, which
+// must be safe to call concurrently
+func NewCounterFunc(name, help string, value func() float64) {
+	register(name, &funcMetric{desc{name: name, help: help, kind: "counter"}, value})
+}
+
+// observations are the observations of a series of a histogram
+type observations struct {
+	labelValues []string
+	counts      []uint64 // by bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+// Histogram is a metric counting observations, e.g. latencies, in buckets
+// of values, which gives their distribution
+type Histogram struct {
+	desc
+	buckets []float64
+
+	lock     sync.Mutex
+	observed map[string]*observations
+}
+
+// NewHistogram creates and registers a histogram with the given labels and
+// the upper bounds of its buckets, in increasing order, e.g. DefaultBuckets
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: buckets of %s not in increasing order", name))
+	}
+	h := &Histogram{
+		desc:     desc{name, help, "histogram", labels},
+		buckets:  buckets,
+		observed: make(map[string]*observations),
+	}
+	register(name, h)
+	return h
+}
+
+// Observe adds an observation to the series with the label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	bucket := sort.SearchFloat64s(h.buckets, value)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	o, ok := h.observed[key]
+	if !ok {
+		o = &observations{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.observed[key] = o
+	}
+	if bucket < len(h.buckets) {
+		o.counts[bucket]++
+	}
+	o.count++
+	o.sum += value
+}
+
+// ObserveSince adds the time elapsed since start, in seconds, to the series
+// with the label values
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if len(h.observed) == 0 && len(h.labels) != 0 {
+		return
+	}
+	h.writeHeader(w)
+	keys := make([]string, 0, len(h.observed))
+	for key := range h.observed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		// Without labels the histogram is exposed before any observation
+		keys = append(keys, "")
+		h.observed[""] = &observations{counts: make([]uint64, len(h.buckets))}
+	}
+	for _, key := range keys {
+		o := h.observed[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += o.counts[i]
+			fmt.Fprintf(w, "%s %d\n", h.series("_bucket", o.labelValues, "le", formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s %d\n", h.series("_bucket", o.labelValues, "le", "+Inf"), o.count)
+		fmt.Fprintf(w, "%s %s\n", h.series("_sum", o.labelValues), formatValue(o.sum))
+		fmt.Fprintf(w, "%s %d\n", h.series("_count", o.labelValues), o.count)
+	}
+}
+
+// WriteText writes the registered metrics, sorted by name, in the Prometheus
+// text format
+func WriteText(w io.Writer) {
+	registry.RLock()
+	names := make([]string, 0, len(registry.metrics))
+	for name := range registry.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		metrics[i] = registry.metrics[name]
+	}
+	registry.RUnlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler returns the handler exposing the registered metrics, to be
+// scraped by Prometheus
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteText(w)
+	})
+}
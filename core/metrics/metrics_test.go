@@ -0,0 +1,113 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func exposed() string {
+	var buf bytes.Buffer
+	WriteText(&buf)
+	return buf.String()
+}
+
+func expectLines(t *testing.T, text string, lines ...string) {
+	for _, line := range lines {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Expected line %q in\n%s", line, text)
+		}
+	}
+}
+
+func TestCounterAndGauge(t *testing.T) {
+	requests := NewCounter("test_requests_total", "Requests handled.", "method", "code")
+	requests.Inc("Invoke", "OK")
+	requests.Add(2, "Invoke", "OK")
+	requests.Inc("Query", `Not "found"`)
+	queued := NewGauge("test_queued", "Requests queued.")
+	queued.Set(5)
+	queued.Dec()
+	NewGaugeFunc("test_height", "Height.", func() float64 { return 42 })
+
+	expectLines(t, exposed(),
+		"# HELP test_requests_total Requests handled.",
+		"# TYPE test_requests_total counter",
+		`test_requests_total{method="Invoke",code="OK"} 3`,
+		`test_requests_total{method="Query",code="Not \"found\""} 1`,
+		"# TYPE test_queued gauge",
+		"test_queued 4",
+		"test_height 42")
+}
+
+func TestUnusedMetricsExposure(t *testing.T) {
+	NewCounter("test_unused_total", "Unused.")
+	NewCounter("test_unused_labeled_total", "Unused with labels.", "type")
+	text := exposed()
+	expectLines(t, text, "test_unused_total 0")
+	if strings.Contains(text, "test_unused_labeled_total") {
+		t.Errorf("Expected a metric with labels and no series not to be exposed, got\n%s", text)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	latency := NewHistogram("test_latency_seconds", "Latency.", []float64{0.1, 1}, "type")
+	latency.Observe(0.05, "query")
+	latency.Observe(0.1, "query")
+	latency.Observe(0.5, "query")
+	latency.Observe(3, "query")
+
+	expectLines(t, exposed(),
+		"# TYPE test_latency_seconds histogram",
+		`test_latency_seconds_bucket{type="query",le="0.1"} 2`,
+		`test_latency_seconds_bucket{type="query",le="1"} 3`,
+		`test_latency_seconds_bucket{type="query",le="+Inf"} 4`,
+		`test_latency_seconds_sum{type="query"} 3.65`,
+		`test_latency_seconds_count{type="query"} 4`)
+}
+
+func TestLabelValuesMismatch(t *testing.T) {
+	counter := NewCounter("test_mismatch_total", "Mismatch.", "type")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic on a missing label value")
+		}
+	}()
+	counter.Inc()
+}
+
+func TestHandler(t *testing.T) {
+	NewCounter("test_handler_total", "Handler.").Inc()
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Error scraping the metrics: %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("Expected the text format, got %s", contentType)
+	}
+	expectLines(t, string(body), "test_handler_total 1")
+}
@@ -32,9 +32,11 @@ package peer
 import (
 	"fmt"
 	"net"
+	"sync"
 
 	"github.com/spf13/viper"
 
+	"github.com/hyperledger/fabric/core/comm"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -54,6 +56,9 @@ var syncStateDeltasChannelSize int
 var syncBlocksChannelSize int
 var validatorEnabled bool
 
+// roleLock guards the values which change when the peer is promoted at runtime
+var roleLock sync.RWMutex
+
 // Note: There is some kind of circular import issue that prevents us from
 // importing the "core" package into the "peer" package. The
 // 'peer.SecurityEnabled' bit is a duplicate of the 'core.SecurityEnabled'
@@ -76,13 +81,14 @@ func CacheConfiguration() (err error) {
 			}
 			peerAddress = net.JoinHostPort(GetLocalIP(), port)
 			peerLogger.Infof("Auto detected peer address: %s", peerAddress)
-		} else {
-			peerAddress = viper.GetString("peer.address")
+		} else if peerAddress, err = comm.NormalizeAddress(viper.GetString("peer.address")); err != nil {
+			err = fmt.Errorf("Error parsing the peer's address: %s", err)
 		}
 		return
 	}
 
 	// getPeerEndpoint returns the PeerEndpoint for this Peer instance.  Affected by env:peer.addressAutoDetect
+	// and env:peer.externalAddress
 	getPeerEndpoint := func() (*pb.PeerEndpoint, error) {
 		var peerAddress string
 		var peerType pb.PeerEndpoint_Type
@@ -90,7 +96,14 @@ func CacheConfiguration() (err error) {
 		if err != nil {
 			return nil, err
 		}
-		if viper.GetBool("peer.validator.enabled") {
+		// Peers behind NAT or a load balancer advertise the address other peers reach them at
+		if externalAddress := viper.GetString("peer.externalAddress"); externalAddress != "" {
+			if peerAddress, err = comm.NormalizeAddress(externalAddress); err != nil {
+				return nil, fmt.Errorf("Error parsing the peer's external address: %s", err)
+			}
+		}
+		// A standby runs as a non-validator until it takes over
+		if viper.GetBool("peer.validator.enabled") && !StandbyEnabled() {
 			peerType = pb.PeerEndpoint_VALIDATOR
 		} else {
 			peerType = pb.PeerEndpoint_NON_VALIDATOR
@@ -104,7 +117,7 @@ func CacheConfiguration() (err error) {
 	syncStateSnapshotChannelSize = viper.GetInt("peer.sync.state.snapshot.channelSize")
 	syncStateDeltasChannelSize = viper.GetInt("peer.sync.state.deltas.channelSize")
 	syncBlocksChannelSize = viper.GetInt("peer.sync.blocks.channelSize")
-	validatorEnabled = viper.GetBool("peer.validator.enabled")
+	validatorEnabled = viper.GetBool("peer.validator.enabled") && !StandbyEnabled()
 
 	securityEnabled = viper.GetBool("security.enabled")
 
@@ -139,6 +152,8 @@ func GetPeerEndpoint() (*pb.PeerEndpoint, error) {
 	if !configurationCached {
 		cacheConfiguration()
 	}
+	roleLock.RLock()
+	defer roleLock.RUnlock()
 	return peerEndpoint, peerEndpointError
 }
 
@@ -171,9 +186,33 @@ func ValidatorEnabled() bool {
 	if !configurationCached {
 		cacheConfiguration()
 	}
+	roleLock.RLock()
+	defer roleLock.RUnlock()
 	return validatorEnabled
 }
 
+// setValidatorEnabled changes the peer.validator.enabled property and the
+// type of the PeerEndpoint once the peer is promoted at runtime
+func setValidatorEnabled(enabled bool) {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	roleLock.Lock()
+	defer roleLock.Unlock()
+	validatorEnabled = enabled
+	viper.Set("peer.validator.enabled", enabled)
+	if peerEndpoint != nil {
+		// Callers may hold on to the previous PeerEndpoint
+		endpoint := *peerEndpoint
+		if enabled {
+			endpoint.Type = pb.PeerEndpoint_VALIDATOR
+		} else {
+			endpoint.Type = pb.PeerEndpoint_NON_VALIDATOR
+		}
+		peerEndpoint = &endpoint
+	}
+}
+
 func SecurityEnabled() bool {
 	if !configurationCached {
 		cacheConfiguration()
@@ -0,0 +1,47 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestExternalAddressIsAdvertised(t *testing.T) {
+	defer func() {
+		viper.Set("peer.externalAddress", "")
+		CacheConfiguration()
+	}()
+
+	viper.Set("peer.externalAddress", "203.0.113.7:30303")
+	if err := CacheConfiguration(); err != nil {
+		t.Fatalf("Error caching configuration: %s", err)
+	}
+	pe, _ := GetPeerEndpoint()
+	if pe.Address != "203.0.113.7:30303" {
+		t.Errorf("Expected the external address to be advertised, got %s", pe.Address)
+	}
+	if local, _ := GetLocalAddress(); local != viper.GetString("peer.address") {
+		t.Errorf("Expected the local address to remain %s, got %s", viper.GetString("peer.address"), local)
+	}
+
+	viper.Set("peer.externalAddress", "203.0.113.7")
+	if err := CacheConfiguration(); err == nil {
+		t.Errorf("Expected an external address without port to be rejected")
+	}
+}
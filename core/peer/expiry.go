@@ -0,0 +1,126 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/crypto"
+)
+
+// certificateExpiry returns when the enrollment certificate, with security
+// enabled, and the TLS certificate, with TLS enabled, of the peer expire
+func (p *PeerImpl) certificateExpiry() map[string]time.Time {
+	expiry := make(map[string]time.Time)
+	if SecurityEnabled() {
+		if renewer, ok := p.secHelper.(crypto.EnrollmentRenewer); ok {
+			expiry["enrollment"] = renewer.GetEnrollmentCertificateExpiry()
+		}
+	}
+	if comm.TLSEnabled() {
+		notAfter, err := comm.ServerCertificateExpiry()
+		if err != nil {
+			peerLogger.Warningf("Error reading the expiry of the TLS certificate: %s", err)
+		} else if !notAfter.IsZero() {
+			expiry["TLS"] = notAfter
+		}
+	}
+	return expiry
+}
+
+// expiringCertificates lists the certificates of expiry which expire within
+// the period starting at now, in the order of their names
+func expiringCertificates(expiry map[string]time.Time, now time.Time, within time.Duration) []string {
+	var expiring []string
+	for name, notAfter := range expiry {
+		if notAfter.Before(now.Add(within)) {
+			expiring = append(expiring, name)
+		}
+	}
+	sort.Strings(expiring)
+	return expiring
+}
+
+// CheckCertificateExpiry returns an error naming the certificates of the
+// peer which expire within security.expiry.warnBefore
+func (p *PeerImpl) CheckCertificateExpiry() error {
+	expiry := p.certificateExpiry()
+	expiring := expiringCertificates(expiry, time.Now(), viper.GetDuration("security.expiry.warnBefore"))
+	if len(expiring) == 0 {
+		return nil
+	}
+	var details []string
+	for _, name := range expiring {
+		details = append(details, fmt.Sprintf("%s certificate expires at %s", name, expiry[name].Format(time.RFC3339)))
+	}
+	return fmt.Errorf("%s", strings.Join(details, ", "))
+}
+
+// monitorCertificateExpiry checks the expiry of the certificates of the peer
+// every security.expiry.checkInterval. It warns of the certificates expiring
+// within security.expiry.warnBefore, and re-enrolls with the ECA when the
+// enrollment certificate expires within security.expiry.reEnrollBefore, so
+// that the peer does not drop off the network. Likewise it renews the TLS
+// certificate from the TLSCA within peer.tls.provision.renewBefore of its
+// expiry when peer.tls.provision.enabled is set.
+func (p *PeerImpl) monitorCertificateExpiry() {
+	interval := viper.GetDuration("security.expiry.checkInterval")
+	if interval <= 0 || (!SecurityEnabled() && !comm.TLSEnabled()) {
+		return
+	}
+	for {
+		p.checkCertificateExpiry(time.Now())
+		time.Sleep(interval)
+	}
+}
+
+func (p *PeerImpl) checkCertificateExpiry(now time.Time) {
+	expiry := p.certificateExpiry()
+	for _, name := range expiringCertificates(expiry, now, viper.GetDuration("security.expiry.warnBefore")) {
+		if expiry[name].Before(now) {
+			peerLogger.Errorf("The %s certificate of the peer expired at %s", name, expiry[name].Format(time.RFC3339))
+		} else {
+			peerLogger.Warningf("The %s certificate of the peer expires at %s", name, expiry[name].Format(time.RFC3339))
+		}
+	}
+
+	retry := viper.GetDuration("security.expiry.checkInterval")
+	reEnrollBefore := viper.GetDuration("security.expiry.reEnrollBefore")
+	if notAfter, ok := expiry["enrollment"]; ok && reEnrollBefore > 0 && notAfter.Before(now.Add(reEnrollBefore)) {
+		peerLogger.Infof("Re-enrolling, the enrollment certificate expires at %s", notAfter.Format(time.RFC3339))
+		if err := p.ReEnroll(); err != nil {
+			peerLogger.Errorf("Automatic re-enrollment failed, retrying in %s: %s", retry, err)
+		}
+	}
+
+	renewBefore := viper.GetDuration("peer.tls.provision.renewBefore")
+	if !viper.GetBool("peer.tls.provision.enabled") || renewBefore <= 0 {
+		return
+	}
+	if notAfter, ok := expiry["TLS"]; ok && notAfter.Before(now.Add(renewBefore)) {
+		peerLogger.Infof("Renewing the TLS certificate, it expires at %s", notAfter.Format(time.RFC3339))
+		if err := p.RenewTLSCertificate(); err != nil {
+			peerLogger.Errorf("Automatic renewal of the TLS certificate failed, retrying in %s: %s", retry, err)
+		}
+	}
+}
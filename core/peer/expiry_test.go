@@ -0,0 +1,41 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpiringCertificates(t *testing.T) {
+	now := time.Now()
+	expiry := map[string]time.Time{
+		"enrollment": now.Add(48 * time.Hour),
+		"TLS":        now.Add(-time.Hour),
+	}
+
+	if expiring := expiringCertificates(expiry, now, time.Hour); !reflect.DeepEqual(expiring, []string{"TLS"}) {
+		t.Errorf("Expected the expired TLS certificate only, got %v", expiring)
+	}
+	if expiring := expiringCertificates(expiry, now, 72*time.Hour); !reflect.DeepEqual(expiring, []string{"TLS", "enrollment"}) {
+		t.Errorf("Expected both certificates, got %v", expiring)
+	}
+	if expiring := expiringCertificates(map[string]time.Time{}, now, 72*time.Hour); len(expiring) != 0 {
+		t.Errorf("Expected no certificate, got %v", expiring)
+	}
+}
@@ -0,0 +1,384 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// BlockGossiper interface for disseminating committed blocks among peers
+type BlockGossiper interface {
+	BlockAdded(blockState *pb.BlockState, sender MessageHandler) error
+	DigestReceived(info *pb.BlockchainInfo, sender MessageHandler) error
+}
+
+// gossipStack is the functionality of the peer the block gossip relies on
+type gossipStack interface {
+	BlockChainAccessor
+	BlockChainModifier
+	BlockChainUtil
+	StateAccessor
+	gossipTargets(count int) []MessageHandler
+}
+
+// blockGossip spreads committed blocks epidemic-style. Whenever the local
+// blockchain grows, the new blocks are pushed with their state delta to a few
+// random peers, which apply them and push them on in turn. As pushes may be
+// lost, every peer also periodically exchanges a digest of its blockchain with
+// a random peer, and whichever of the two is behind is sent the blocks it
+// missed (anti-entropy). Only non-validating peers apply gossiped blocks,
+// validators obtain theirs through consensus and merely spread them.
+type blockGossip struct {
+	stack       gossipStack
+	apply       bool          // whether gossiped blocks are applied to the local ledger, guarded by lock
+	fanout      int           // number of peers each new block is pushed to
+	period      time.Duration // how often to check for new blocks to push
+	antiEntropy time.Duration // how often to exchange digests, 0 disables anti-entropy
+	maxBlocks   int           // most blocks pushed or held back at once
+
+	lock     sync.Mutex
+	gossiped uint64                    // blockchain height up to which blocks were pushed
+	pending  map[uint64]*pb.BlockState // received blocks waiting for their predecessors
+	doneChan chan struct{}
+}
+
+func newBlockGossip(stack gossipStack, apply bool) *blockGossip {
+	g := &blockGossip{
+		stack:       stack,
+		apply:       apply,
+		fanout:      viper.GetInt("peer.gossip.fanout"),
+		period:      viper.GetDuration("peer.gossip.period"),
+		antiEntropy: viper.GetDuration("peer.gossip.antiEntropy"),
+		maxBlocks:   viper.GetInt("peer.gossip.maxBlocks"),
+		pending:     make(map[uint64]*pb.BlockState),
+		doneChan:    make(chan struct{}),
+	}
+	if g.fanout <= 0 {
+		g.fanout = 3
+	}
+	if g.period <= 0 {
+		g.period = time.Second
+	}
+	if g.maxBlocks <= 0 {
+		g.maxBlocks = 10
+	}
+	return g
+}
+
+// start begins pushing new blocks and exchanging digests until stop is called
+func (g *blockGossip) start() {
+	g.lock.Lock()
+	g.gossiped = g.stack.GetBlockchainSize()
+	g.lock.Unlock()
+
+	peerLogger.Infof("Starting block gossip with fanout %d, period %v, anti-entropy period %v", g.fanout, g.period, g.antiEntropy)
+	go g.run()
+}
+
+func (g *blockGossip) stop() {
+	close(g.doneChan)
+}
+
+func (g *blockGossip) run() {
+	pushTicker := time.NewTicker(g.period)
+	defer pushTicker.Stop()
+	var antiEntropyChan <-chan time.Time
+	if g.antiEntropy > 0 {
+		antiEntropyTicker := time.NewTicker(g.antiEntropy)
+		defer antiEntropyTicker.Stop()
+		antiEntropyChan = antiEntropyTicker.C
+	}
+	for {
+		select {
+		case <-pushTicker.C:
+			g.pushNewBlocks()
+		case <-antiEntropyChan:
+			g.sendDigest()
+		case <-g.doneChan:
+			peerLogger.Debug("Stopping block gossip")
+			return
+		}
+	}
+}
+
+// pushNewBlocks pushes the blocks added to the blockchain since the last push
+func (g *blockGossip) pushNewBlocks() {
+	g.lock.Lock()
+	height := g.stack.GetBlockchainSize()
+	from := g.gossiped
+	g.gossiped = height
+	g.lock.Unlock()
+
+	if height <= from {
+		return
+	}
+	if height-from > uint64(g.maxBlocks) {
+		// Peers which missed that many blocks catch up through anti-entropy
+		from = height - uint64(g.maxBlocks)
+	}
+	targets := g.stack.gossipTargets(g.fanout)
+	if len(targets) == 0 {
+		return
+	}
+	for blockNumber := from; blockNumber < height; blockNumber++ {
+		msg, err := g.newBlockAddedMessage(blockNumber)
+		if err != nil {
+			peerLogger.Warningf("Not gossiping block %d: %s", blockNumber, err)
+			continue
+		}
+		for _, target := range targets {
+			if err := target.SendMessage(msg); err != nil {
+				peerLogger.Debugf("Error gossiping block %d: %s", blockNumber, err)
+			}
+		}
+	}
+}
+
+// sendDigest sends the state of the local blockchain to a random peer
+func (g *blockGossip) sendDigest() {
+	targets := g.stack.gossipTargets(1)
+	if len(targets) == 0 {
+		return
+	}
+	msg, err := g.newDigestMessage()
+	if err != nil {
+		peerLogger.Errorf("Error creating gossip digest: %s", err)
+		return
+	}
+	if err := targets[0].SendMessage(msg); err != nil {
+		peerLogger.Debugf("Error sending gossip digest: %s", err)
+	}
+}
+
+// DigestReceived answers the digest of a peer with the blocks it is missing,
+// or with the local digest if the local blockchain is the one behind
+func (g *blockGossip) DigestReceived(info *pb.BlockchainInfo, sender MessageHandler) error {
+	height := g.stack.GetBlockchainSize()
+	switch {
+	case info.Height < height:
+		end := height
+		if end-info.Height > uint64(g.maxBlocks) {
+			end = info.Height + uint64(g.maxBlocks)
+		}
+		for blockNumber := info.Height; blockNumber < end; blockNumber++ {
+			msg, err := g.newBlockAddedMessage(blockNumber)
+			if err != nil {
+				return fmt.Errorf("Error sending block %d for anti-entropy: %s", blockNumber, err)
+			}
+			if err := sender.SendMessage(msg); err != nil {
+				return fmt.Errorf("Error sending block %d for anti-entropy: %s", blockNumber, err)
+			}
+		}
+	case info.Height > height && g.applying():
+		msg, err := g.newDigestMessage()
+		if err != nil {
+			return err
+		}
+		return sender.SendMessage(msg)
+	}
+	return nil
+}
+
+// applying returns whether gossiped blocks are applied to the local ledger
+func (g *blockGossip) applying() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.apply
+}
+
+// setApply changes whether gossiped blocks are applied, as the peer is promoted
+func (g *blockGossip) setApply(apply bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.apply = apply
+	g.pending = make(map[uint64]*pb.BlockState)
+}
+
+// BlockAdded applies a gossiped block once all blocks preceding it were applied
+func (g *blockGossip) BlockAdded(blockState *pb.BlockState, sender MessageHandler) error {
+	if !g.applying() {
+		return nil
+	}
+	if blockState.Block == nil {
+		return fmt.Errorf("Gossiped block %d is empty", blockState.BlockNumber)
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	height := g.stack.GetBlockchainSize()
+	if blockState.BlockNumber < height || blockState.BlockNumber >= height+uint64(g.maxBlocks) {
+		return nil
+	}
+	g.pending[blockState.BlockNumber] = blockState
+
+	for {
+		next, ok := g.pending[height]
+		if !ok {
+			break
+		}
+		delete(g.pending, height)
+		if err := g.applyBlock(height, next); err != nil {
+			return err
+		}
+		peerLogger.Debugf("Applied gossiped block %d", height)
+		height++
+	}
+	for blockNumber := range g.pending {
+		if blockNumber < height {
+			delete(g.pending, blockNumber)
+		}
+	}
+	return nil
+}
+
+// applyBlock appends the block to the blockchain if it extends it, and brings
+// the state forward by its delta if the resulting state hash matches the block
+func (g *blockGossip) applyBlock(blockNumber uint64, blockState *pb.BlockState) error {
+	block := blockState.Block
+	if blockNumber > 0 {
+		head, err := g.stack.GetBlockByNumber(blockNumber - 1)
+		if err != nil {
+			return fmt.Errorf("Error retrieving block %d: %s", blockNumber-1, err)
+		}
+		headHash, err := g.stack.HashBlock(head)
+		if err != nil {
+			return fmt.Errorf("Error hashing block %d: %s", blockNumber-1, err)
+		}
+		if !bytes.Equal(headHash, block.PreviousBlockHash) {
+			return fmt.Errorf("Gossiped block %d does not extend the local blockchain", blockNumber)
+		}
+	}
+
+	delta := statemgmt.NewStateDelta()
+	if err := delta.Unmarshal(blockState.StateDelta); err != nil {
+		return fmt.Errorf("Gossiped block %d has a corrupt state delta: %s", blockNumber, err)
+	}
+	if err := g.stack.ApplyStateDelta(blockState, delta); err != nil {
+		return fmt.Errorf("Error applying the state delta of block %d: %s", blockNumber, err)
+	}
+	stateHash, err := g.stack.GetCurrentStateHash()
+	if err != nil || !bytes.Equal(stateHash, block.StateHash) {
+		if rbErr := g.stack.RollbackStateDelta(blockState); rbErr != nil {
+			return fmt.Errorf("Error rolling back the state delta of block %d: %s", blockNumber, rbErr)
+		}
+		return fmt.Errorf("The state delta of gossiped block %d does not produce its state hash", blockNumber)
+	}
+	if err := g.stack.CommitStateDelta(blockState); err != nil {
+		return fmt.Errorf("Error committing the state delta of block %d: %s", blockNumber, err)
+	}
+	return g.stack.PutBlock(blockNumber, block)
+}
+
+func (g *blockGossip) newBlockAddedMessage(blockNumber uint64) (*pb.Message, error) {
+	block, err := g.stack.GetBlockByNumber(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	delta, err := g.stack.GetStateDelta(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if delta == nil {
+		return nil, fmt.Errorf("the state delta of block %d has been discarded", blockNumber)
+	}
+	data, err := proto.Marshal(&pb.BlockState{Block: block, StateDelta: delta.Marshal(), BlockNumber: blockNumber})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Message{Type: pb.Message_SYNC_BLOCK_ADDED, Payload: data, Timestamp: util.CreateUtcTimestamp()}, nil
+}
+
+func (g *blockGossip) newDigestMessage() (*pb.Message, error) {
+	height := g.stack.GetBlockchainSize()
+	info := &pb.BlockchainInfo{Height: height}
+	if height > 0 {
+		head, err := g.stack.GetBlockByNumber(height - 1)
+		if err != nil {
+			return nil, err
+		}
+		if info.CurrentBlockHash, err = g.stack.HashBlock(head); err != nil {
+			return nil, err
+		}
+		info.PreviousBlockHash = head.PreviousBlockHash
+	}
+	data, err := proto.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Message{Type: pb.Message_SYNC_DIGEST, Payload: data, Timestamp: util.CreateUtcTimestamp()}, nil
+}
+
+// gossipTargets returns up to count randomly chosen connected peers
+func (p *PeerImpl) gossipTargets(count int) []MessageHandler {
+	cloneMap := p.cloneHandlerMap(pb.PeerEndpoint_UNDEFINED)
+	handlers := make([]MessageHandler, 0, len(cloneMap))
+	for _, msgHandler := range cloneMap {
+		// Peers which do not take part in gossip would not understand its messages
+		if negotiated, ok := msgHandler.(interface {
+			Protocol() *Protocol
+		}); ok && !negotiated.Protocol().Supports(CapabilityGossip) {
+			continue
+		}
+		handlers = append(handlers, msgHandler)
+	}
+	var targets []MessageHandler
+	for _, i := range rand.Perm(len(handlers)) {
+		if len(targets) == count {
+			break
+		}
+		targets = append(targets, handlers[i])
+	}
+	return targets
+}
+
+// BlockAdded hands a block gossiped by another peer to the block gossip
+func (p *PeerImpl) BlockAdded(blockState *pb.BlockState, sender MessageHandler) error {
+	if p.gossip == nil {
+		return nil
+	}
+	return p.gossip.BlockAdded(blockState, sender)
+}
+
+// DigestReceived hands the blockchain digest of another peer to the block gossip
+func (p *PeerImpl) DigestReceived(info *pb.BlockchainInfo, sender MessageHandler) error {
+	if p.gossip == nil {
+		return nil
+	}
+	return p.gossip.DigestReceived(info, sender)
+}
+
+// initGossip starts the block gossip unless it is disabled
+func (p *PeerImpl) initGossip() {
+	if !viper.GetBool("peer.gossip.enabled") {
+		peerLogger.Info("Block gossip is disabled")
+		return
+	}
+	p.gossip = newBlockGossip(p, !p.isValidator)
+	p.gossip.start()
+}
@@ -0,0 +1,280 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// gossipTestStack keeps a blockchain in memory, its state hash is the hash of
+// all state deltas applied so far
+type gossipTestStack struct {
+	blocks    []*pb.Block
+	deltas    []*statemgmt.StateDelta
+	state     *statemgmt.StateDelta
+	tentative *statemgmt.StateDelta
+	targets   []MessageHandler
+}
+
+func newGossipTestStack(blocks []*pb.Block, deltas []*statemgmt.StateDelta) *gossipTestStack {
+	stack := &gossipTestStack{state: statemgmt.NewStateDelta()}
+	for i := range blocks {
+		stack.blocks = append(stack.blocks, blocks[i])
+		stack.deltas = append(stack.deltas, deltas[i])
+		stack.state.ApplyChanges(deltas[i])
+	}
+	return stack
+}
+
+func (s *gossipTestStack) GetBlockByNumber(blockNumber uint64) (*pb.Block, error) {
+	if blockNumber >= uint64(len(s.blocks)) {
+		return nil, fmt.Errorf("no block %d", blockNumber)
+	}
+	return s.blocks[blockNumber], nil
+}
+
+func (s *gossipTestStack) GetBlockchainSize() uint64 {
+	return uint64(len(s.blocks))
+}
+
+func (s *gossipTestStack) GetCurrentStateHash() ([]byte, error) {
+	current := statemgmt.NewStateDelta()
+	current.ApplyChanges(s.state)
+	if s.tentative != nil {
+		current.ApplyChanges(s.tentative)
+	}
+	return current.ComputeCryptoHash(), nil
+}
+
+func (s *gossipTestStack) ApplyStateDelta(id interface{}, delta *statemgmt.StateDelta) error {
+	s.tentative = delta
+	return nil
+}
+
+func (s *gossipTestStack) RollbackStateDelta(id interface{}) error {
+	s.tentative = nil
+	return nil
+}
+
+func (s *gossipTestStack) CommitStateDelta(id interface{}) error {
+	s.state.ApplyChanges(s.tentative)
+	s.deltas = append(s.deltas, s.tentative)
+	s.tentative = nil
+	return nil
+}
+
+func (s *gossipTestStack) EmptyState() error {
+	return nil
+}
+
+func (s *gossipTestStack) PutBlock(blockNumber uint64, block *pb.Block) error {
+	if blockNumber != uint64(len(s.blocks)) {
+		return fmt.Errorf("block %d does not extend a blockchain of height %d", blockNumber, len(s.blocks))
+	}
+	s.blocks = append(s.blocks, block)
+	return nil
+}
+
+func (s *gossipTestStack) HashBlock(block *pb.Block) ([]byte, error) {
+	return block.GetHash()
+}
+
+func (s *gossipTestStack) VerifyBlockchain(start, finish uint64) (uint64, error) {
+	return 0, nil
+}
+
+func (s *gossipTestStack) GetStateSnapshot() (*state.StateSnapshot, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (s *gossipTestStack) GetStateDelta(blockNumber uint64) (*statemgmt.StateDelta, error) {
+	if blockNumber >= uint64(len(s.deltas)) {
+		return nil, nil
+	}
+	return s.deltas[blockNumber], nil
+}
+
+func (s *gossipTestStack) gossipTargets(count int) []MessageHandler {
+	if count < len(s.targets) {
+		return s.targets[:count]
+	}
+	return s.targets
+}
+
+// gossipTestHandler collects the messages sent to a peer
+type gossipTestHandler struct {
+	MessageHandler
+	sent []*pb.Message
+}
+
+func (h *gossipTestHandler) SendMessage(msg *pb.Message) error {
+	h.sent = append(h.sent, msg)
+	return nil
+}
+
+// deliver hands the collected messages to the gossip of the peer
+func (h *gossipTestHandler) deliver(t *testing.T, g *blockGossip, from MessageHandler) {
+	sent := h.sent
+	h.sent = nil
+	for _, msg := range sent {
+		switch msg.Type {
+		case pb.Message_SYNC_BLOCK_ADDED:
+			blockState := &pb.BlockState{}
+			if err := proto.Unmarshal(msg.Payload, blockState); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.BlockAdded(blockState, from); err != nil {
+				t.Fatalf("Error applying gossiped block %d: %s", blockState.BlockNumber, err)
+			}
+		case pb.Message_SYNC_DIGEST:
+			info := &pb.BlockchainInfo{}
+			if err := proto.Unmarshal(msg.Payload, info); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.DigestReceived(info, from); err != nil {
+				t.Fatalf("Error answering digest: %s", err)
+			}
+		default:
+			t.Fatalf("Unexpected gossip message %s", msg.Type)
+		}
+	}
+}
+
+// makeGossipChain builds a blockchain of the given height, each block
+// setting one key
+func makeGossipChain(height int) ([]*pb.Block, []*statemgmt.StateDelta) {
+	var blocks []*pb.Block
+	var deltas []*statemgmt.StateDelta
+	state := statemgmt.NewStateDelta()
+	var previousHash []byte
+	for i := 0; i < height; i++ {
+		delta := statemgmt.NewStateDelta()
+		delta.Set("chaincode", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), nil)
+		state.ApplyChanges(delta)
+		block := &pb.Block{
+			Transactions:      []*pb.Transaction{{Uuid: fmt.Sprintf("tx%d", i)}},
+			StateHash:         state.ComputeCryptoHash(),
+			PreviousBlockHash: previousHash,
+		}
+		previousHash, _ = block.GetHash()
+		blocks = append(blocks, block)
+		deltas = append(deltas, delta)
+	}
+	return blocks, deltas
+}
+
+func TestGossipPushesNewBlocks(t *testing.T) {
+	blocks, deltas := makeGossipChain(4)
+	validator := newGossipTestStack(blocks[:1], deltas[:1])
+	nvp := newGossipTestStack(blocks[:1], deltas[:1])
+	toNVP, toValidator := &gossipTestHandler{}, &gossipTestHandler{}
+	validator.targets = []MessageHandler{toNVP}
+
+	validatorGossip := newBlockGossip(validator, false)
+	validatorGossip.gossiped = validator.GetBlockchainSize()
+	nvpGossip := newBlockGossip(nvp, true)
+
+	validator.blocks, validator.deltas = blocks, deltas
+	validatorGossip.pushNewBlocks()
+	if len(toNVP.sent) != 3 {
+		t.Fatalf("Expected 3 new blocks to be pushed, %d messages were sent", len(toNVP.sent))
+	}
+
+	// Deliver out of order, the later blocks wait for the earlier ones
+	toNVP.sent[0], toNVP.sent[2] = toNVP.sent[2], toNVP.sent[0]
+	toNVP.deliver(t, nvpGossip, toValidator)
+
+	if nvp.GetBlockchainSize() != 4 {
+		t.Fatalf("Expected the non-validating peer to reach height 4, got %d", nvp.GetBlockchainSize())
+	}
+	if len(nvpGossip.pending) != 0 {
+		t.Errorf("Expected no blocks to remain pending, %d do", len(nvpGossip.pending))
+	}
+
+	validatorGossip.pushNewBlocks()
+	if len(toNVP.sent) != 0 {
+		t.Errorf("Expected no blocks to be pushed again, %d messages were sent", len(toNVP.sent))
+	}
+}
+
+func TestGossipValidatorDoesNotApplyBlocks(t *testing.T) {
+	blocks, deltas := makeGossipChain(2)
+	validator := newGossipTestStack(blocks[:1], deltas[:1])
+	g := newBlockGossip(validator, false)
+
+	g.BlockAdded(&pb.BlockState{Block: blocks[1], StateDelta: deltas[1].Marshal(), BlockNumber: 1}, &gossipTestHandler{})
+	if validator.GetBlockchainSize() != 1 {
+		t.Errorf("Expected a validator to ignore gossiped blocks")
+	}
+}
+
+func TestGossipRejectsForgedBlock(t *testing.T) {
+	blocks, deltas := makeGossipChain(2)
+	nvp := newGossipTestStack(blocks[:1], deltas[:1])
+	g := newBlockGossip(nvp, true)
+
+	forged := statemgmt.NewStateDelta()
+	forged.Set("chaincode", "key1", []byte("forged"), nil)
+	err := g.BlockAdded(&pb.BlockState{Block: blocks[1], StateDelta: forged.Marshal(), BlockNumber: 1}, &gossipTestHandler{})
+	if err == nil {
+		t.Fatalf("Expected a block whose state delta does not match its state hash to be rejected")
+	}
+	if nvp.GetBlockchainSize() != 1 || nvp.tentative != nil {
+		t.Errorf("Expected the rejected block to leave the ledger untouched")
+	}
+
+	other, _ := makeGossipChain(3)
+	other[1].PreviousBlockHash = []byte("unknown")
+	if err := g.BlockAdded(&pb.BlockState{Block: other[1], StateDelta: deltas[1].Marshal(), BlockNumber: 1}, &gossipTestHandler{}); err == nil {
+		t.Errorf("Expected a block not extending the blockchain to be rejected")
+	}
+}
+
+func TestGossipAntiEntropy(t *testing.T) {
+	blocks, deltas := makeGossipChain(5)
+	validator := newGossipTestStack(blocks, deltas)
+	nvp := newGossipTestStack(blocks[:2], deltas[:2])
+	toNVP, toValidator := &gossipTestHandler{}, &gossipTestHandler{}
+	validator.targets = []MessageHandler{toNVP}
+
+	validatorGossip := newBlockGossip(validator, false)
+	nvpGossip := newBlockGossip(nvp, true)
+
+	// The validator's digest shows the peer it is behind, it answers with its
+	// own digest, which the validator answers with the missing blocks
+	validatorGossip.sendDigest()
+	toNVP.deliver(t, nvpGossip, toValidator)
+	if len(toValidator.sent) != 1 || toValidator.sent[0].Type != pb.Message_SYNC_DIGEST {
+		t.Fatalf("Expected the peer to answer with its digest, sent %v", toValidator.sent)
+	}
+	toValidator.deliver(t, validatorGossip, toNVP)
+	toNVP.deliver(t, nvpGossip, toValidator)
+
+	if nvp.GetBlockchainSize() != 5 {
+		t.Fatalf("Expected anti-entropy to bring the peer to height 5, got %d", nvp.GetBlockchainSize())
+	}
+	if len(toValidator.sent) != 0 {
+		t.Errorf("Expected no further messages, %d were sent", len(toValidator.sent))
+	}
+}
@@ -17,21 +17,24 @@ limitations under the License.
 package peer
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/looplab/fsm"
 	"github.com/spf13/viper"
 
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
 // Handler peer handler implementation.
 type Handler struct {
-	chatMutex                     sync.Mutex
+	chatMutex                     comm.PriorityMutex // Serializes sends, consensus messages first
 	ToPeerEndpoint                *pb.PeerEndpoint
 	Coordinator                   MessageHandlerCoordinator
 	ChatStream                    ChatStream
@@ -43,6 +46,16 @@ type Handler struct {
 	snapshotRequestHandler        *syncStateSnapshotRequestHandler
 	syncStateDeltasRequestHandler *syncStateDeltasHandler
 	syncBlocksRequestHandler      *syncBlocksRequestHandler
+	syncThrottle                  *syncThrottle // Limits the blocks and state sent to the remote peer
+	syncCompression               string        // Encoding of the blocks and state sent, if the remote peer decodes it
+	protocol                      *Protocol     // Agreed on with the remote peer in the handshake
+	connectedAt                   time.Time
+	peersRequested                int64         // UnixNano of the pending DISC_GET_PEERS, set atomically
+	pingInterval                  time.Duration // 0 does not ping the remote peer
+	nonce                         []byte        // Sent in the hello, the remote peer signs for it
+	remoteNonce                   []byte        // Received in the hello, this peer signs for it
+	sentSequence                  uint64        // Guarded by chatMutex
+	receivedSequence              uint64
 }
 
 // NewPeerHandler returns a new Peer handler
@@ -53,18 +66,30 @@ func NewPeerHandler(coord MessageHandlerCoordinator, stream ChatStream, initiate
 		ChatStream:      stream,
 		initiatedStream: initiatedStream,
 		Coordinator:     coord,
+		connectedAt:     time.Now(),
 	}
 	d.doneChan = make(chan struct{})
+	nonce, err := newStreamNonce()
+	if err != nil {
+		return nil, err
+	}
+	d.nonce = nonce
 
 	d.snapshotRequestHandler = newSyncStateSnapshotRequestHandler()
 	d.syncStateDeltasRequestHandler = newSyncStateDeltasHandler()
 	d.syncBlocksRequestHandler = newSyncBlocksRequestHandler()
+	d.syncThrottle = newSyncThrottle(viper.GetInt("peer.sync.rateLimit"), viper.GetInt("peer.sync.burst"))
+	d.syncCompression = syncCompression()
+	d.pingInterval = viper.GetDuration("peer.ping.interval")
 	d.FSM = fsm.NewFSM(
 		"created",
 		fsm.Events{
 			{Name: pb.Message_DISC_HELLO.String(), Src: []string{"created"}, Dst: "established"},
+			{Name: pb.Message_DISC_HELLO.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.Message_DISC_GET_PEERS.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.Message_DISC_PEERS.String(), Src: []string{"established"}, Dst: "established"},
+			{Name: pb.Message_DISC_PING.String(), Src: []string{"established"}, Dst: "established"},
+			{Name: pb.Message_DISC_PONG.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.Message_SYNC_BLOCK_ADDED.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.Message_SYNC_GET_BLOCKS.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.Message_SYNC_BLOCKS.String(), Src: []string{"established"}, Dst: "established"},
@@ -72,12 +97,15 @@ func NewPeerHandler(coord MessageHandlerCoordinator, stream ChatStream, initiate
 			{Name: pb.Message_SYNC_STATE_SNAPSHOT.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.Message_SYNC_STATE_GET_DELTAS.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.Message_SYNC_STATE_DELTAS.String(), Src: []string{"established"}, Dst: "established"},
+			{Name: pb.Message_SYNC_DIGEST.String(), Src: []string{"established"}, Dst: "established"},
 		},
 		fsm.Callbacks{
 			"enter_state":                                           func(e *fsm.Event) { d.enterState(e) },
 			"before_" + pb.Message_DISC_HELLO.String():              func(e *fsm.Event) { d.beforeHello(e) },
 			"before_" + pb.Message_DISC_GET_PEERS.String():          func(e *fsm.Event) { d.beforeGetPeers(e) },
 			"before_" + pb.Message_DISC_PEERS.String():              func(e *fsm.Event) { d.beforePeers(e) },
+			"before_" + pb.Message_DISC_PING.String():               func(e *fsm.Event) { d.beforePing(e) },
+			"before_" + pb.Message_DISC_PONG.String():               func(e *fsm.Event) { d.beforePong(e) },
 			"before_" + pb.Message_SYNC_BLOCK_ADDED.String():        func(e *fsm.Event) { d.beforeBlockAdded(e) },
 			"before_" + pb.Message_SYNC_GET_BLOCKS.String():         func(e *fsm.Event) { d.beforeSyncGetBlocks(e) },
 			"before_" + pb.Message_SYNC_BLOCKS.String():             func(e *fsm.Event) { d.beforeSyncBlocks(e) },
@@ -85,13 +113,14 @@ func NewPeerHandler(coord MessageHandlerCoordinator, stream ChatStream, initiate
 			"before_" + pb.Message_SYNC_STATE_SNAPSHOT.String():     func(e *fsm.Event) { d.beforeSyncStateSnapshot(e) },
 			"before_" + pb.Message_SYNC_STATE_GET_DELTAS.String():   func(e *fsm.Event) { d.beforeSyncStateGetDeltas(e) },
 			"before_" + pb.Message_SYNC_STATE_DELTAS.String():       func(e *fsm.Event) { d.beforeSyncStateDeltas(e) },
+			"before_" + pb.Message_SYNC_DIGEST.String():             func(e *fsm.Event) { d.beforeSyncDigest(e) },
 		},
 	)
 
 	// If the stream was initiated from this Peer, send an Initial HELLO message
 	if d.initiatedStream {
 		// Send intiial Hello
-		helloMessage, err := d.Coordinator.NewOpenchainDiscoveryHello()
+		helloMessage, err := d.Coordinator.NewOpenchainDiscoveryHello(d.nonce)
 		if err != nil {
 			return nil, fmt.Errorf("Error getting new HelloMessage: %s", err)
 		}
@@ -126,6 +155,11 @@ func (d *Handler) To() (pb.PeerEndpoint, error) {
 	return *(d.ToPeerEndpoint), nil
 }
 
+// Protocol returns the protocol agreed on with the remote peer, nil before the handshake
+func (d *Handler) Protocol() *Protocol {
+	return d.protocol
+}
+
 // Stop stops this handler, which will trigger the Deregister from the MessageHandlerCoordinator.
 func (d *Handler) Stop() error {
 	// Deregister the handler
@@ -151,10 +185,26 @@ func (d *Handler) beforeHello(e *fsm.Event) {
 		e.Cancel(fmt.Errorf("Error unmarshalling HelloMessage: %s", err))
 		return
 	}
+	if e.Src == "established" {
+		d.endpointChanged(e, msg, helloMessage)
+		return
+	}
 	// Store the PeerEndpoint
 	d.ToPeerEndpoint = helloMessage.PeerEndpoint
 	peerLogger.Debugf("Received %s from endpoint=%s", e.Event, helloMessage)
 
+	d.protocol, err = d.Coordinator.NegotiateProtocol(helloMessage)
+	if err != nil {
+		e.Cancel(err)
+		return
+	}
+	peerLogger.Debugf("Speaking protocol version %d with capabilities %v to %s", d.protocol.Version, d.protocol.Capabilities, d.ToPeerEndpoint.ID)
+	d.remoteNonce = helloMessage.Nonce
+	if d.sequenced() && SecurityEnabled() && len(d.remoteNonce) == 0 {
+		e.Cancel(fmt.Errorf("Received %s without a nonce from %s", e.Event, d.ToPeerEndpoint.ID))
+		return
+	}
+
 	// If security enabled, need to verify the signature on the hello message
 	if SecurityEnabled() {
 		if err := d.Coordinator.GetSecHelper().Verify(helloMessage.PeerEndpoint.PkiID, msg.Signature, msg.Payload); err != nil {
@@ -168,7 +218,7 @@ func (d *Handler) beforeHello(e *fsm.Event) {
 		// Did NOT intitiate the stream, need to send back HELLO
 		peerLogger.Debugf("Received %s, sending back %s", e.Event, pb.Message_DISC_HELLO.String())
 		// Send back out PeerID information in a Hello
-		helloMessage, err := d.Coordinator.NewOpenchainDiscoveryHello()
+		helloMessage, err := d.Coordinator.NewOpenchainDiscoveryHello(d.nonce)
 		if err != nil {
 			e.Cancel(fmt.Errorf("Error getting new HelloMessage: %s", err))
 			return
@@ -185,6 +235,9 @@ func (d *Handler) beforeHello(e *fsm.Event) {
 	} else {
 		// Registered successfully
 		d.registered = true
+		if helloMessage.BlockchainInfo != nil {
+			d.Coordinator.HeightReported(helloMessage.BlockchainInfo.Height)
+		}
 		otherPeer := d.ToPeerEndpoint.Address
 		if !d.Coordinator.GetDiscHelper().FindNode(otherPeer) {
 			if ok := d.Coordinator.GetDiscHelper().AddNode(otherPeer); !ok {
@@ -199,6 +252,42 @@ func (d *Handler) beforeHello(e *fsm.Event) {
 	}
 }
 
+// endpointChanged takes note of the new role or enrollment certificate of the
+// remote peer, which sends a new hello once connected when it is promoted
+// or renews its certificate
+func (d *Handler) endpointChanged(e *fsm.Event, msg *pb.Message, helloMessage *pb.HelloMessage) {
+	endpoint := helloMessage.PeerEndpoint
+	if endpoint == nil || endpoint.ID == nil || endpoint.ID.Name != d.ToPeerEndpoint.ID.Name {
+		e.Cancel(fmt.Errorf("Received a hello from %s announcing a different peer", d.ToPeerEndpoint.ID))
+		return
+	}
+	if !bytes.Equal(helloMessage.Nonce, d.nonce) {
+		// The hello was sent on another stream, as when it was captured
+		// and replayed
+		e.Cancel(fmt.Errorf("Received a hello from %s without the nonce of the stream, it may be replayed", d.ToPeerEndpoint.ID))
+		return
+	}
+	if SecurityEnabled() {
+		if err := d.Coordinator.GetSecHelper().Verify(endpoint.PkiID, msg.Signature, msg.Payload); err != nil {
+			e.Cancel(fmt.Errorf("Error Verifying signature for received HelloMessage: %s", err))
+			return
+		}
+	}
+	// Other goroutines may hold on to the previous PeerEndpoint
+	toPeerEndpoint := *d.ToPeerEndpoint
+	if toPeerEndpoint.Type != endpoint.Type {
+		toPeerEndpoint.Type = endpoint.Type
+		peerLogger.Infof("Peer %s switched role to %s", toPeerEndpoint.ID, toPeerEndpoint.Type)
+	}
+	if !bytes.Equal(toPeerEndpoint.PkiID, endpoint.PkiID) {
+		// Consensus verifies the messages of the peer against the
+		// enrollment certificate identified by its PkiID
+		toPeerEndpoint.PkiID = endpoint.PkiID
+		peerLogger.Infof("Peer %s renewed its enrollment certificate", toPeerEndpoint.ID)
+	}
+	d.ToPeerEndpoint = &toPeerEndpoint
+}
+
 func (d *Handler) beforeGetPeers(e *fsm.Event) {
 	peersMessage, err := d.Coordinator.GetPeers()
 	if err != nil {
@@ -224,6 +313,12 @@ func (d *Handler) beforePeers(e *fsm.Event) {
 		return
 	}
 	msg := e.Args[0].(*pb.Message)
+	// Without pings, the discovery exchange measures the round-trip time
+	if requested := atomic.SwapInt64(&d.peersRequested, 0); requested != 0 {
+		if stats := chatStats(d.ChatStream); stats != nil {
+			stats.RoundTrip(time.Since(time.Unix(0, requested)))
+		}
+	}
 
 	peersMessage := &pb.PeersMessage{}
 	err := proto.Unmarshal(msg.Payload, peersMessage)
@@ -250,7 +345,36 @@ func (d *Handler) beforeBlockAdded(e *fsm.Event) {
 		return
 	}
 	// Add the block and any delta state to the ledger
-	_ = msg
+	blockState := &pb.BlockState{}
+	if err := proto.Unmarshal(msg.Payload, blockState); err != nil {
+		e.Cancel(fmt.Errorf("Error unmarshalling BlockState in beforeBlockAdded: %s", err))
+		return
+	}
+	d.Coordinator.HeightReported(blockState.BlockNumber + 1)
+	if err := d.Coordinator.BlockAdded(blockState, d); err != nil {
+		peerLogger.Warningf("Discarding block %d gossiped by %s: %s", blockState.BlockNumber, d.ToPeerEndpoint.ID, err)
+	}
+}
+
+func (d *Handler) beforeSyncDigest(e *fsm.Event) {
+	peerLogger.Debugf("Received message: %s", e.Event)
+	msg, ok := e.Args[0].(*pb.Message)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	blockchainInfo := &pb.BlockchainInfo{}
+	if err := proto.Unmarshal(msg.Payload, blockchainInfo); err != nil {
+		e.Cancel(fmt.Errorf("Error unmarshalling BlockchainInfo in beforeSyncDigest: %s", err))
+		return
+	}
+	d.Coordinator.HeightReported(blockchainInfo.Height)
+	// Answering may involve sending several blocks, do not hold up the stream
+	go func() {
+		if err := d.Coordinator.DigestReceived(blockchainInfo, d); err != nil {
+			peerLogger.Warningf("Error answering gossip digest of %s: %s", d.ToPeerEndpoint.ID, err)
+		}
+	}()
 }
 
 func (d *Handler) when(stateToCheck string) bool {
@@ -260,6 +384,16 @@ func (d *Handler) when(stateToCheck string) bool {
 // HandleMessage handles the Openchain messages for the Peer.
 func (d *Handler) HandleMessage(msg *pb.Message) error {
 	peerLogger.Debugf("Handling Message of type: %s ", msg.Type)
+	if err := d.checkReplay(msg); err != nil {
+		return err
+	}
+	if msg.Encoding != "" {
+		payload, err := comm.Decompress(msg.Encoding, msg.Payload, comm.GetMessageSizeLimits("peer.messageSize").Recv)
+		if err != nil {
+			return fmt.Errorf("Error decoding message (%s): %s", msg.Type.String(), err)
+		}
+		msg.Payload, msg.Encoding = payload, ""
+	}
 	if d.FSM.Cannot(msg.Type.String()) {
 		return fmt.Errorf("Peer FSM cannot handle message (%s) with payload size (%d) while in state: %s", msg.Type.String(), len(msg.Payload), d.FSM.Current())
 	}
@@ -278,24 +412,151 @@ func (d *Handler) HandleMessage(msg *pb.Message) error {
 func (d *Handler) SendMessage(msg *pb.Message) error {
 	//make sure Sends are serialized. Also make sure everyone uses SendMessage
 	//instead of calling Send directly on the grpc stream
-	d.chatMutex.Lock()
+	d.chatMutex.Lock(messagePriority(msg))
 	defer d.chatMutex.Unlock()
 	peerLogger.Debugf("Sending message to stream of type: %s ", msg.Type)
-	err := d.ChatStream.Send(msg)
+	msg, err := d.seal(msg)
+	if err != nil {
+		return err
+	}
+	err = d.ChatStream.Send(msg)
 	if err != nil {
 		return fmt.Errorf("Error Sending message through ChatStream: %s", err)
 	}
 	return nil
 }
 
+// messagePriority returns the priority of msg on the stream, consensus
+// messages are sent ahead of the blocks and state sent to syncing peers
+func messagePriority(msg *pb.Message) comm.Priority {
+	switch msg.Type {
+	case pb.Message_CONSENSUS, pb.Message_DISC_PING, pb.Message_DISC_PONG:
+		// Pings measure the network rather than the queue of bulk messages
+		return comm.PriorityHigh
+	case pb.Message_SYNC_BLOCKS, pb.Message_SYNC_STATE_SNAPSHOT, pb.Message_SYNC_STATE_DELTAS, pb.Message_SYNC_BLOCK_ADDED:
+		return comm.PriorityBulk
+	default:
+		return comm.PriorityNormal
+	}
+}
+
+// minCompressedPayloadSize is the size in bytes under which payloads are
+// sent uncompressed, compressing them saving little if anything
+const minCompressedPayloadSize = 512
+
+// syncCompression returns the encoding configured in peer.sync.compression,
+// empty if the blocks and state are to be sent uncompressed
+func syncCompression() string {
+	encoding := viper.GetString("peer.sync.compression")
+	if encoding == "" || encoding == "none" {
+		return ""
+	}
+	for _, supported := range comm.Compressions() {
+		if encoding == supported {
+			return encoding
+		}
+	}
+	peerLogger.Warningf("Unsupported peer.sync.compression %q, sending blocks and state uncompressed", encoding)
+	return ""
+}
+
+// sendSyncMessage sends blocks or state requested by the remote peer, at the
+// rate peer.sync.rateLimit allows. Other messages are not held up meanwhile.
+// The payload is compressed if the remote peer decodes peer.sync.compression.
+func (d *Handler) sendSyncMessage(msg *pb.Message) error {
+	if err := d.compress(msg); err != nil {
+		return err
+	}
+	d.syncThrottle.wait(len(msg.Payload))
+	return d.SendMessage(msg)
+}
+
+// compress encodes the payload of msg with the sync compression agreed on
+// with the remote peer, unless that does not make it smaller
+func (d *Handler) compress(msg *pb.Message) error {
+	if d.syncCompression == "" || len(msg.Payload) < minCompressedPayloadSize || !d.protocol.Supports(CapabilityCompression(d.syncCompression)) {
+		return nil
+	}
+	payload, err := comm.Compress(d.syncCompression, msg.Payload)
+	if err != nil {
+		return fmt.Errorf("Error compressing %s: %s", msg.Type, err)
+	}
+	if len(payload) < len(msg.Payload) {
+		peerLogger.Debugf("Compressed %s from %d to %d bytes", msg.Type, len(msg.Payload), len(payload))
+		msg.Payload, msg.Encoding = payload, d.syncCompression
+	}
+	return nil
+}
+
+// pinging returns whether the remote peer is pinged to measure the
+// round-trip time
+func (d *Handler) pinging() bool {
+	return d.pingInterval > 0 && d.Protocol().Supports(CapabilityPing)
+}
+
+// ping sends the remote peer a DISC_PING carrying the time it is sent
+func (d *Handler) ping() {
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, uint64(time.Now().UnixNano()))
+	if err := d.SendMessage(&pb.Message{Type: pb.Message_DISC_PING, Payload: payload}); err != nil {
+		peerLogger.Errorf("Error sending %s: %s", pb.Message_DISC_PING, err)
+	}
+}
+
+func (d *Handler) beforePing(e *fsm.Event) {
+	msg, ok := e.Args[0].(*pb.Message)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	if err := d.SendMessage(&pb.Message{Type: pb.Message_DISC_PONG, Payload: msg.Payload}); err != nil {
+		e.Cancel(err)
+	}
+}
+
+// beforePong takes note of the round-trip time of the ping it answers
+func (d *Handler) beforePong(e *fsm.Event) {
+	msg, ok := e.Args[0].(*pb.Message)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	if len(msg.Payload) != 8 {
+		e.Cancel(fmt.Errorf("Received %s with a payload of %d bytes, expected 8", e.Event, len(msg.Payload)))
+		return
+	}
+	sent := time.Unix(0, int64(binary.BigEndian.Uint64(msg.Payload)))
+	rtt := time.Since(sent)
+	if rtt < 0 {
+		return
+	}
+	peerLogger.Debugf("Round-trip time to %s: %s", d.ToPeerEndpoint.ID, rtt)
+	if stats := chatStats(d.ChatStream); stats != nil {
+		stats.RoundTrip(rtt)
+	}
+}
+
 // start starts the Peer server function
 func (d *Handler) start() error {
 	discPeriod := viper.GetDuration("peer.discovery.period")
 	tickChan := time.NewTicker(discPeriod).C
+	var pingChan <-chan time.Time
+	if d.pingInterval > 0 {
+		pingTicker := time.NewTicker(d.pingInterval)
+		defer pingTicker.Stop()
+		pingChan = pingTicker.C
+	}
 	peerLogger.Debug("Starting Peer discovery service")
 	for {
 		select {
+		case <-pingChan:
+			if d.pinging() {
+				d.ping()
+			}
 		case <-tickChan:
+			if !d.pinging() {
+				atomic.StoreInt64(&d.peersRequested, time.Now().UnixNano())
+			}
 			if err := d.SendMessage(&pb.Message{Type: pb.Message_DISC_GET_PEERS}); err != nil {
 				peerLogger.Errorf("Error sending %s during handler discovery tick: %s", pb.Message_DISC_GET_PEERS, err)
 			}
@@ -416,7 +677,7 @@ func (d *Handler) sendBlocks(syncBlockRange *pb.SyncBlockRange) {
 			peerLogger.Errorf("Error marshalling syncBlocks for BlockNum = %d: %s", currBlockNum, err)
 			break
 		}
-		if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_BLOCKS, Payload: syncBlocksBytes}); err != nil {
+		if err := d.sendSyncMessage(&pb.Message{Type: pb.Message_SYNC_BLOCKS, Payload: syncBlocksBytes}); err != nil {
 			peerLogger.Errorf("Error sending blockNum %d: %s", currBlockNum, err)
 			break
 		}
@@ -544,7 +805,7 @@ func (d *Handler) sendStateSnapshot(syncStateSnapshotRequest *pb.SyncStateSnapsh
 			peerLogger.Errorf("Error marshalling syncStateSnapsot for BlockNum = %d: %s", currBlockNumber, err)
 			break
 		}
-		if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_STATE_SNAPSHOT, Payload: syncStateSnapshotBytes}); err != nil {
+		if err := d.sendSyncMessage(&pb.Message{Type: pb.Message_SYNC_STATE_SNAPSHOT, Payload: syncStateSnapshotBytes}); err != nil {
 			peerLogger.Errorf("Error sending syncStateSnapsot for BlockNum = %d: %s", currBlockNumber, err)
 			break
 		}
@@ -557,7 +818,7 @@ func (d *Handler) sendStateSnapshot(syncStateSnapshotRequest *pb.SyncStateSnapsh
 		peerLogger.Errorf("Error marshalling terminating syncStateSnapsot message for correlationId = %d, BlockNum = %d: %s", syncStateSnapshotRequest.CorrelationId, currBlockNumber, err)
 		return
 	}
-	if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_STATE_SNAPSHOT, Payload: syncStateSnapshotBytes}); err != nil {
+	if err := d.sendSyncMessage(&pb.Message{Type: pb.Message_SYNC_STATE_SNAPSHOT, Payload: syncStateSnapshotBytes}); err != nil {
 		peerLogger.Errorf("Error sending terminating syncStateSnapsot for correlationId = %d, BlockNum = %d: %s", syncStateSnapshotRequest.CorrelationId, currBlockNumber, err)
 		return
 	}
@@ -650,7 +911,7 @@ func (d *Handler) sendStateDeltas(syncStateDeltasRequest *pb.SyncStateDeltasRequ
 			peerLogger.Errorf("Error marshalling syncStateDeltas for BlockNum = %d: %s", currBlockNum, err)
 			break
 		}
-		if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_STATE_DELTAS, Payload: syncStateDeltasBytes}); err != nil {
+		if err := d.sendSyncMessage(&pb.Message{Type: pb.Message_SYNC_STATE_DELTAS, Payload: syncStateDeltasBytes}); err != nil {
 			peerLogger.Errorf("Error sending stateDeltas for blockNum %d: %s", currBlockNum, err)
 			break
 		}
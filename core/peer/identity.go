@@ -0,0 +1,81 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// certificateFingerprint returns the hex encoded SHA-256 hash of the
+// certificate, the form its pins are configured in
+func certificateFingerprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(hash[:])
+}
+
+// verifyPeerIdentity checks that the client certificate of an incoming Chat
+// belongs to the peer announced in its first message, which must be its
+// hello. If peer.tls.clientAuth.pins maps peer IDs to certificate
+// fingerprints, the certificate must be one of the comma separated ones
+// pinned for the peer ID, and peers without a pin are rejected. Otherwise the
+// certificate's common name must be the peer ID.
+func verifyPeerIdentity(cert *x509.Certificate, msg *pb.Message) error {
+	if msg.Type != pb.Message_DISC_HELLO {
+		return fmt.Errorf("Expected %s as first message of a Chat, received %s", pb.Message_DISC_HELLO, msg.Type)
+	}
+	helloMessage := &pb.HelloMessage{}
+	if err := proto.Unmarshal(msg.Payload, helloMessage); err != nil {
+		return fmt.Errorf("Error unmarshalling HelloMessage: %s", err)
+	}
+	if helloMessage.PeerEndpoint == nil || helloMessage.PeerEndpoint.ID == nil {
+		return fmt.Errorf("HelloMessage does not identify the peer")
+	}
+	peerID := helloMessage.PeerEndpoint.ID.Name
+
+	pins := viper.GetStringMapString("peer.tls.clientAuth.pins")
+	if len(pins) == 0 {
+		if cert.Subject.CommonName != peerID {
+			return fmt.Errorf("Client certificate of %s is issued to %s", peerID, cert.Subject.CommonName)
+		}
+		return nil
+	}
+	pin, ok := pins[peerID]
+	if !ok {
+		// Viper lower cases the keys of maps read from the configuration file
+		pin, ok = pins[strings.ToLower(peerID)]
+	}
+	if !ok {
+		return fmt.Errorf("No client certificate is pinned for peer %s", peerID)
+	}
+	fingerprint := certificateFingerprint(cert)
+	for _, candidate := range strings.Split(pin, ",") {
+		// Several pins let a peer renew its certificate
+		if strings.ToLower(strings.Replace(strings.TrimSpace(candidate), ":", "", -1)) == fingerprint {
+			return nil
+		}
+	}
+	return fmt.Errorf("Client certificate %s of %s does not match its pins", fingerprint, peerID)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/testutil"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func newIdentityTestCertificate(t *testing.T, commonName string) *x509.Certificate {
+	return testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}, testutil.NewKey(t), nil, nil)
+}
+
+func newIdentityTestHello(t *testing.T, peerID string) *pb.Message {
+	payload, err := proto.Marshal(&pb.HelloMessage{PeerEndpoint: &pb.PeerEndpoint{ID: &pb.PeerID{Name: peerID}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pb.Message{Type: pb.Message_DISC_HELLO, Payload: payload}
+}
+
+func TestVerifyPeerIdentityByCommonName(t *testing.T) {
+	cert := newIdentityTestCertificate(t, "vp1")
+	if err := verifyPeerIdentity(cert, newIdentityTestHello(t, "vp1")); err != nil {
+		t.Errorf("Expected the certificate of vp1 to be accepted: %s", err)
+	}
+	if err := verifyPeerIdentity(cert, newIdentityTestHello(t, "vp2")); err == nil {
+		t.Errorf("Expected the certificate of vp1 to be rejected for vp2")
+	}
+	if err := verifyPeerIdentity(cert, &pb.Message{Type: pb.Message_DISC_GET_PEERS}); err == nil {
+		t.Errorf("Expected a Chat not starting with a hello to be rejected")
+	}
+}
+
+func TestVerifyPeerIdentityByPin(t *testing.T) {
+	pinned := newIdentityTestCertificate(t, "vp1")
+	other := newIdentityTestCertificate(t, "vp1")
+	viper.Set("peer.tls.clientAuth.pins", map[string]string{"vp1": certificateFingerprint(pinned)})
+	defer viper.Set("peer.tls.clientAuth.pins", map[string]string{})
+
+	if err := verifyPeerIdentity(pinned, newIdentityTestHello(t, "vp1")); err != nil {
+		t.Errorf("Expected the pinned certificate to be accepted: %s", err)
+	}
+	if err := verifyPeerIdentity(other, newIdentityTestHello(t, "vp1")); err == nil {
+		t.Errorf("Expected a certificate not matching the pin to be rejected")
+	}
+	if err := verifyPeerIdentity(newIdentityTestCertificate(t, "vp2"), newIdentityTestHello(t, "vp2")); err == nil {
+		t.Errorf("Expected a peer without pin to be rejected")
+	}
+
+	// Both certificates are pinned while the peer renews its certificate
+	viper.Set("peer.tls.clientAuth.pins", map[string]string{"vp1": certificateFingerprint(pinned) + ", " + certificateFingerprint(other)})
+	if err := verifyPeerIdentity(other, newIdentityTestHello(t, "vp1")); err != nil {
+		t.Errorf("Expected the renewed certificate to be accepted: %s", err)
+	}
+}
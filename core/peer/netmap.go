@@ -0,0 +1,113 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// extendingHandler is implemented by the handlers extending the handler of
+// a connection, such as the consensus handler
+type extendingHandler interface {
+	Extended() MessageHandler
+}
+
+// connectionHandler returns the handler of the connection msgHandler
+// extends, nil if it extends none
+func connectionHandler(msgHandler MessageHandler) *Handler {
+	for {
+		extending, ok := msgHandler.(extendingHandler)
+		if !ok {
+			break
+		}
+		msgHandler = extending.Extended()
+	}
+	d, _ := msgHandler.(*Handler)
+	return d
+}
+
+// connectionsByID sorts the connections of the network map by peer ID
+type connectionsByID []*pb.PeerConnection
+
+func (c connectionsByID) Len() int      { return len(c) }
+func (c connectionsByID) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c connectionsByID) Less(i, j int) bool {
+	return c[i].Endpoint.ID.Name < c[j].Endpoint.ID.Name
+}
+
+// GetNetworkMap returns the peer's view of the network, the connected peers
+// with the details of their connection and the peers discovery knows of which
+// are not connected
+func (p *PeerImpl) GetNetworkMap() (*pb.NetworkMap, error) {
+	self, err := p.GetPeerEndpoint()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting the endpoint of this peer: %s", err)
+	}
+	networkMap := &pb.NetworkMap{Self: self}
+
+	var drops map[string]int
+	if p.connectionHealth != nil {
+		drops = p.connectionHealth.Drops()
+	}
+	connected := make(map[string]bool)
+	for _, msgHandler := range p.cloneHandlerMap(pb.PeerEndpoint_UNDEFINED) {
+		endpoint, err := msgHandler.To()
+		if err != nil {
+			return nil, fmt.Errorf("Error getting peers: %s", err)
+		}
+		connected[endpoint.Address] = true
+		connection := &pb.PeerConnection{Endpoint: &endpoint, Drops: int32(drops[endpoint.Address])}
+		if d := connectionHandler(msgHandler); d != nil {
+			p.describeConnection(d, connection)
+		}
+		networkMap.Connections = append(networkMap.Connections, connection)
+	}
+	sort.Sort(connectionsByID(networkMap.Connections))
+
+	if p.discHelper != nil {
+		for _, address := range p.discHelper.GetAllNodes() {
+			if !connected[address] {
+				networkMap.Disconnected = append(networkMap.Disconnected, address)
+			}
+		}
+		sort.Strings(networkMap.Disconnected)
+	}
+	return networkMap, nil
+}
+
+// describeConnection fills in the details of the connection handled by d
+func (p *PeerImpl) describeConnection(d *Handler, connection *pb.PeerConnection) {
+	if protocol := d.Protocol(); protocol != nil {
+		connection.ProtocolVersion = protocol.Version
+		connection.Capabilities = protocol.Capabilities
+	}
+	connection.Initiated = d.initiatedStream
+	connection.Stats = connectionStats(d.ChatStream)
+	if !d.connectedAt.IsZero() {
+		connection.ConnectedSeconds = int64(time.Since(d.connectedAt).Seconds())
+	}
+	if p.connectionHealth == nil {
+		return
+	}
+	if at, ok := p.connectionHealth.lastReceived(d.ChatStream); ok {
+		connection.IdleSeconds = int64(time.Since(at).Seconds())
+	}
+}
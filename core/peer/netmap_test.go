@@ -0,0 +1,110 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/discovery"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// netmapTestHandler stands in for the consensus handler extending a peer handler
+type netmapTestHandler struct {
+	MessageHandler
+}
+
+func (h *netmapTestHandler) Extended() MessageHandler {
+	return h.MessageHandler
+}
+
+func TestGetNetworkMap(t *testing.T) {
+	if SecurityEnabled() {
+		t.Skip("Getting the endpoint of this peer requires its enrollment")
+	}
+	stream := &silentStream{}
+	vp1 := &Handler{
+		ToPeerEndpoint:  &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp1"}, Address: "10.0.0.1:30303", Type: pb.PeerEndpoint_VALIDATOR},
+		ChatStream:      stream,
+		initiatedStream: true,
+		protocol:        &Protocol{Version: ProtocolVersion, Capabilities: []string{CapabilityGossip}},
+		connectedAt:     time.Now().Add(-time.Minute),
+	}
+	vp2 := &Handler{
+		ToPeerEndpoint: &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp2"}, Address: "10.0.0.2:30303", Type: pb.PeerEndpoint_VALIDATOR},
+	}
+	disc := discovery.NewDiscoveryImpl()
+	disc.AddNode("10.0.0.1:30303")
+	disc.AddNode("10.0.0.3:30303")
+	p := &PeerImpl{
+		handlerMap: &handlerMap{m: map[pb.PeerID]MessageHandler{
+			pb.PeerID{Name: "vp2"}: vp2,
+			pb.PeerID{Name: "vp1"}: &netmapTestHandler{MessageHandler: vp1},
+		}},
+		discHelper:       disc,
+		connectionHealth: &connectionHealth{drops: map[string]int{"10.0.0.1:30303": 2}},
+	}
+	p.connectionHealth.receivedOn(stream, time.Now().Add(-10*time.Second))
+
+	networkMap, err := p.GetNetworkMap()
+	if err != nil {
+		t.Fatalf("Error getting the network map: %s", err)
+	}
+	if len(networkMap.Connections) != 2 {
+		t.Fatalf("Expected two connections, got %v", networkMap.Connections)
+	}
+	connection := networkMap.Connections[0]
+	if connection.Endpoint.ID.Name != "vp1" {
+		t.Fatalf("Expected the connections to be sorted by peer ID, got %s first", connection.Endpoint.ID.Name)
+	}
+	if connection.ProtocolVersion != ProtocolVersion || len(connection.Capabilities) != 1 || !connection.Initiated {
+		t.Errorf("Expected the protocol and direction of the extended handler, got %v", connection)
+	}
+	if connection.ConnectedSeconds < 60 || connection.IdleSeconds < 10 || connection.IdleSeconds > 60 || connection.Drops != 2 {
+		t.Errorf("Expected the age, idle time and drops of the connection, got %v", connection)
+	}
+	if len(networkMap.Disconnected) != 1 || networkMap.Disconnected[0] != "10.0.0.3:30303" {
+		t.Errorf("Expected only the known peer which is not connected to be reported disconnected, got %v", networkMap.Disconnected)
+	}
+
+	p.connectionHealth.closed(stream)
+	if _, ok := p.connectionHealth.lastReceived(stream); ok {
+		t.Errorf("Expected a closed chat to be forgotten")
+	}
+}
+
+func TestNetworkMapReportsTraffic(t *testing.T) {
+	stream := newCountedStream(&silentStream{})
+	msg := &pb.Message{Type: pb.Message_DISC_GET_PEERS, Payload: []byte("payload")}
+	if err := stream.Send(msg); err != nil {
+		t.Fatalf("Error sending: %s", err)
+	}
+	chatStats(stream).RoundTrip(2 * time.Millisecond)
+
+	d := &Handler{ChatStream: stream}
+	connection := &pb.PeerConnection{}
+	(&PeerImpl{}).describeConnection(d, connection)
+	stats := connection.Stats
+	if stats == nil || stats.MessagesSent != 1 || stats.BytesSent == 0 || stats.RoundTripMicros != 2000 {
+		t.Errorf("Expected the traffic of the connection, got %v", stats)
+	}
+
+	if connectionStats(&silentStream{}) != nil {
+		t.Errorf("Expected no statistics for a stream which is not counted")
+	}
+}
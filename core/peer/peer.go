@@ -17,12 +17,13 @@ limitations under the License.
 package peer
 
 import (
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
 	"net"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/context"
@@ -47,7 +48,7 @@ import (
 // Peer provides interface for a peer
 type Peer interface {
 	GetPeerEndpoint() (*pb.PeerEndpoint, error)
-	NewOpenchainDiscoveryHello() (*pb.Message, error)
+	NewOpenchainDiscoveryHello(nonce []byte) (*pb.Message, error)
 }
 
 // BlocksRetriever interface for retrieving blocks .
@@ -112,6 +113,8 @@ type MessageHandlerCoordinator interface {
 	BlockChainModifier
 	BlockChainUtil
 	StateAccessor
+	BlockGossiper
+	HeightTracker
 	RegisterHandler(messageHandler MessageHandler) error
 	DeregisterHandler(messageHandler MessageHandler) error
 	Broadcast(*pb.Message, pb.PeerEndpoint_Type) []error
@@ -120,6 +123,7 @@ type MessageHandlerCoordinator interface {
 	GetRemoteLedger(receiver *pb.PeerID) (RemoteLedger, error)
 	PeersDiscovered(*pb.PeersMessage) error
 	ExecuteTransaction(transaction *pb.Transaction) *pb.Response
+	NegotiateProtocol(remote *pb.HelloMessage) (*Protocol, error)
 	Discoverer
 }
 
@@ -138,32 +142,55 @@ var peerLogger = logging.MustGetLogger("peer")
 
 // NewPeerClientConnection Returns a new grpc.ClientConn to the configured local PEER.
 func NewPeerClientConnection() (*grpc.ClientConn, error) {
-	return NewPeerClientConnectionWithAddress(viper.GetString("peer.address"))
+	return newPeerClientConnection(viper.GetString("peer.address"), comm.GetMessageSizeLimits("peer.devops.messageSize"))
 }
 
-// GetLocalIP returns the non loopback local IP of the host
+// GetLocalIP returns the non loopback local IP of the host, an IPv4 address
+// if the host has one, a global IPv6 address otherwise
 func GetLocalIP() string {
 	addrs, err := net.InterfaceAddrs()
 	if err != nil {
 		return ""
 	}
+	ipv6 := ""
 	for _, address := range addrs {
 		// check the address type and if it is not a loopback then display it
 		if ipnet, ok := address.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
 			if ipnet.IP.To4() != nil {
 				return ipnet.IP.String()
 			}
+			if ipv6 == "" && ipnet.IP.IsGlobalUnicast() {
+				ipv6 = ipnet.IP.String()
+			}
 		}
 	}
-	return ""
+	return ipv6
+}
+
+var peerConnections struct {
+	sync.Once
+	pool *comm.ConnectionPool
+}
+
+// peerConnectionPool returns the pool of connections to other peers, shared
+// by all subsystems talking to them
+func peerConnectionPool() *comm.ConnectionPool {
+	peerConnections.Do(func() {
+		peerConnections.pool = comm.NewConnectionPool(NewPeerClientConnectionWithAddress, viper.GetDuration("peer.connections.idleTimeout"))
+	})
+	return peerConnections.pool
 }
 
 // NewPeerClientConnectionWithAddress Returns a new grpc.ClientConn to the configured local PEER.
 func NewPeerClientConnectionWithAddress(peerAddress string) (*grpc.ClientConn, error) {
+	return newPeerClientConnection(peerAddress, comm.GetMessageSizeLimits("peer.messageSize"))
+}
+
+func newPeerClientConnection(peerAddress string, limits comm.MessageSizeLimits) (*grpc.ClientConn, error) {
 	if comm.TLSEnabled() {
-		return comm.NewClientConnectionWithAddress(peerAddress, true, true, comm.InitTLSForPeer())
+		return comm.NewClientConnectionWithAddress(peerAddress, true, true, comm.InitTLSForPeer(), limits.DialOption())
 	}
-	return comm.NewClientConnectionWithAddress(peerAddress, true, false, nil)
+	return comm.NewClientConnectionWithAddress(peerAddress, true, false, nil, limits.DialOption())
 }
 
 type ledgerWrapper struct {
@@ -189,10 +216,20 @@ type PeerImpl struct {
 	ledgerWrapper  *ledgerWrapper
 	secHelper      crypto.Peer
 	engine         Engine
+	engineFactory  EngineFactory // Creates the engine when a non-validating peer is promoted
 	isValidator    bool
+	roleLock       sync.RWMutex // Guards the handler factory, engine and role, which change when the peer is promoted
+	roleGeneration uint64       // Incremented whenever the peer is promoted
 	reconnectOnce  sync.Once
 	discHelper     discovery.Discovery
 	discPersist    bool
+	bootstrap      *discovery.Bootstrap
+	gossip         *blockGossip
+	standby        *standby // Set while the peer stands by for a primary
+
+	connectionHealth *connectionHealth
+	readiness        *readiness
+	draining         int32 // set atomically once Drain was called
 }
 
 // TransactionProccesor responsible for processing of Transactions
@@ -208,6 +245,30 @@ type Engine interface {
 	//GetInputChannel() (chan<- *pb.Transaction, error)
 }
 
+// Drainer is implemented by a Peer able to refuse new transactions ahead of a planned stop
+type Drainer interface {
+	Drain()
+	Draining() bool
+}
+
+// CertificateRenewer is implemented by a Peer able to load its renewed
+// certificates at runtime
+type CertificateRenewer interface {
+	RenewCertificates() error
+	ReEnroll() error
+}
+
+// CertificateExpiryReporter is implemented by a Peer which tracks the expiry
+// of its certificates, CheckCertificateExpiry names those expiring soon
+type CertificateExpiryReporter interface {
+	CheckCertificateExpiry() error
+}
+
+// HealthReporter may be implemented by an Engine able to tell whether the validating network is healthy
+type HealthReporter interface {
+	GetConsensusHealth() (*pb.ConsensusHealth, error)
+}
+
 // NewPeerWithHandler returns a Peer which uses the supplied handler factory function for creating new handlers on new Chat service invocations.
 func NewPeerWithHandler(secHelperFunc func() crypto.Peer, handlerFact HandlerFactory) (*PeerImpl, error) {
 	peer := new(PeerImpl)
@@ -218,6 +279,7 @@ func NewPeerWithHandler(secHelperFunc func() crypto.Peer, handlerFact HandlerFac
 	}
 	peer.handlerFactory = handlerFact
 	peer.handlerMap = &handlerMap{m: make(map[pb.PeerID]MessageHandler)}
+	peer.connectionHealth = newConnectionHealth()
 
 	peer.secHelper = secHelperFunc()
 
@@ -233,8 +295,14 @@ func NewPeerWithHandler(secHelperFunc func() crypto.Peer, handlerFact HandlerFac
 		return nil, fmt.Errorf("Error constructing NewPeerWithHandler: %s", err)
 	}
 	peer.ledgerWrapper = &ledgerWrapper{ledger: ledgerPtr}
+	peer.readiness = newReadiness(peer.GetBlockchainSize)
+	peer.standby = newStandby()
 
+	peer.initGossip()
 	peer.chatWithSomePeers(peerNodes)
+	if peer.standby != nil {
+		go peer.standBy()
+	}
 	return peer, nil
 }
 
@@ -244,6 +312,7 @@ func NewPeerWithEngine(secHelperFunc func() crypto.Peer, engFactory EngineFactor
 	peerNodes := peer.initDiscovery()
 
 	peer.handlerMap = &handlerMap{m: make(map[pb.PeerID]MessageHandler)}
+	peer.connectionHealth = newConnectionHealth()
 
 	peer.isValidator = ValidatorEnabled()
 	peer.secHelper = secHelperFunc()
@@ -261,7 +330,9 @@ func NewPeerWithEngine(secHelperFunc func() crypto.Peer, engFactory EngineFactor
 		return nil, fmt.Errorf("Error constructing NewPeerWithHandler: %s", err)
 	}
 	peer.ledgerWrapper = &ledgerWrapper{ledger: ledgerPtr}
+	peer.readiness = newReadiness(peer.GetBlockchainSize)
 
+	peer.engineFactory = engFactory
 	peer.engine, err = engFactory(peer)
 	if err != nil {
 		return nil, err
@@ -271,7 +342,9 @@ func NewPeerWithEngine(secHelperFunc func() crypto.Peer, engFactory EngineFactor
 		return nil, errors.New("Cannot supply nil handler factory")
 	}
 
+	peer.initGossip()
 	peer.chatWithSomePeers(peerNodes)
+	go peer.monitorCertificateExpiry()
 	return peer, nil
 
 }
@@ -285,7 +358,7 @@ func (p *PeerImpl) Chat(stream pb.Peer_ChatServer) error {
 func (p *PeerImpl) ProcessTransaction(ctx context.Context, tx *pb.Transaction) (response *pb.Response, err error) {
 	peerLogger.Debugf("ProcessTransaction processing transaction uuid = %s", tx.Uuid)
 	// Need to validate the Tx's signature if we are a validator.
-	if p.isValidator {
+	if p.validating() {
 		// Verify transaction signature if security is enabled
 		secHelper := p.secHelper
 		if nil != secHelper {
@@ -485,15 +558,17 @@ func (p *PeerImpl) Unicast(msg *pb.Message, receiverHandle *pb.PeerID) error {
 
 // SendTransactionsToPeer forwards transactions to the specified peer address.
 func (p *PeerImpl) SendTransactionsToPeer(peerAddress string, transaction *pb.Transaction) (response *pb.Response) {
-	conn, err := NewPeerClientConnectionWithAddress(peerAddress)
+	pool := peerConnectionPool()
+	conn, err := pool.Acquire(peerAddress)
 	if err != nil {
 		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Error creating client to peer address=%s:  %s", peerAddress, err))}
 	}
-	defer conn.Close()
+	defer pool.Release(conn)
 	serverClient := pb.NewPeerClient(conn)
 	peerLogger.Debugf("Sending TX to Peer: %s", peerAddress)
 	response, err = serverClient.ProcessTransaction(context.Background(), transaction)
 	if err != nil {
+		pool.Discard(conn)
 		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Error calling ProcessTransaction on remote peer at address=%s:  %s", peerAddress, err))}
 	}
 	return response
@@ -511,7 +586,7 @@ func (p *PeerImpl) sendTransactionsToLocalEngine(transaction *pb.Transaction) *p
 	var response *pb.Response
 	msg := &pb.Message{Type: pb.Message_CHAIN_TRANSACTION, Payload: data, Timestamp: util.CreateUtcTimestamp()}
 	peerLogger.Debugf("Sending message %s with timestamp %v to local engine", msg.Type, msg.Timestamp)
-	response = p.engine.ProcessTransactionMsg(msg, transaction)
+	response = p.getEngine().ProcessTransactionMsg(msg, transaction)
 
 	return response
 }
@@ -528,6 +603,14 @@ func (p *PeerImpl) ensureConnected() {
 		if err != nil {
 			peerLogger.Errorf("Error in touch service: %s", err.Error())
 		}
+		if len(peersMsg.Peers) == 0 {
+			// Rotate through the bootstrap peers until the network is joined
+			if bootstrap := p.bootstrap.Next(); len(bootstrap) > 0 {
+				peerLogger.Warningf("Touch service indicates no connections, contacting bootstrap peers %v", bootstrap)
+				p.chatWithSomePeers(bootstrap)
+				continue
+			}
+		}
 		allNodes := p.discHelper.GetAllNodes() // these will always be returned in random order
 		if len(peersMsg.Peers) < len(allNodes) {
 			peerLogger.Warning("Touch service indicates dropped connections, attempting to reconnect...")
@@ -551,13 +634,14 @@ func (p *PeerImpl) chatWithSomePeers(addresses []string) {
 	p.reconnectOnce.Do(func() {
 		go p.ensureConnected()
 	})
+	addresses = p.standby.filter(addresses)
 	if len(addresses) == 0 {
 		peerLogger.Debug("Starting up the first peer of a new network")
 		return // nothing to do
 	}
 	for _, address := range addresses {
 		if pe, err := GetPeerEndpoint(); err == nil {
-			if address == pe.Address {
+			if address == pe.Address || address == localAddress {
 				peerLogger.Debugf("Skipping own address: %v", address)
 				continue
 			}
@@ -569,41 +653,108 @@ func (p *PeerImpl) chatWithSomePeers(addresses []string) {
 	}
 }
 
-func (p *PeerImpl) chatWithPeer(address string) error {
+// chatOnce chats with the peer at address until the chat ends, and returns
+// whether it was established
+func (p *PeerImpl) chatOnce(address string) (bool, error) {
 	peerLogger.Debugf("Initiating Chat with peer address: %s", address)
-	conn, err := NewPeerClientConnectionWithAddress(address)
+	pool := peerConnectionPool()
+	conn, err := pool.Acquire(address)
 	if err != nil {
 		peerLogger.Errorf("Error creating connection to peer address %s: %s", address, err)
-		return err
+		p.bootstrap.Failed(address)
+		return false, err
 	}
+	defer pool.Release(conn)
 	serverClient := pb.NewPeerClient(conn)
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	stream, err := serverClient.Chat(ctx)
 	if err != nil {
 		peerLogger.Errorf("Error establishing chat with peer address %s: %s", address, err)
-		return err
+		pool.Discard(conn)
+		p.bootstrap.Failed(address)
+		return false, err
 	}
 	peerLogger.Debugf("Established Chat with peer address: %s", address)
+	p.bootstrap.Succeeded(address)
 	err = p.handleChat(ctx, stream, true)
 	stream.CloseSend()
 	if err != nil {
 		peerLogger.Errorf("Ending Chat with peer address %s due to error: %s", address, err)
-		return err
+		pool.Discard(conn)
+		return true, err
 	}
-	return nil
+	return true, nil
 }
 
 // Chat implementation of the the Chat bidi streaming RPC function
 func (p *PeerImpl) handleChat(ctx context.Context, stream ChatStream, initiatedStream bool) error {
 	deadline, ok := ctx.Deadline()
 	peerLogger.Debugf("Current context deadline = %s, ok = %v", deadline, ok)
-	handler, err := p.handlerFactory(p, stream, initiatedStream, nil)
+	// With client authentication, the peer calling in must present the
+	// certificate of the identity it announces in its hello
+	var clientCert *x509.Certificate
+	if !initiatedStream && comm.TLSClientAuthEnabled() {
+		if clientCert = comm.ClientCertificate(ctx); clientCert == nil {
+			peerLogger.Warning("Rejecting Chat from a peer without client certificate")
+			return fmt.Errorf("A client certificate is required to Chat")
+		}
+	}
+	// Count the traffic for the network map
+	stream = newCountedStream(stream)
+	handlerFactory, generation := p.roleHandlerFactory()
+	handler, err := handlerFactory(p, stream, initiatedStream, nil)
 	if err != nil {
 		return fmt.Errorf("Error creating handler during handleChat initiation: %s", err)
 	}
-	defer handler.Stop()
+	defer func() { handler.Stop() }()
+
+	// Receive in the background, so that a stream gone silent can be ended
+	// although Recv blocks
+	type received struct {
+		msg *pb.Message
+		err error
+	}
+	messages := make(chan received)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			in, err := stream.Recv()
+			select {
+			case messages <- received{in, err}:
+			case <-stop:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	var healthCheck <-chan time.Time
+	if timeout := p.connectionHealth.timeout; timeout > 0 {
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		healthCheck = ticker.C
+	}
+	lastReceived := time.Now()
+	var lastRecorded time.Time
+	defer p.connectionHealth.closed(stream)
+
+	first := true
 	for {
-		in, err := stream.Recv()
+		var in *pb.Message
+		select {
+		case r := <-messages:
+			in, err = r.msg, r.err
+		case <-healthCheck:
+			if silence := time.Since(lastReceived); silence > p.connectionHealth.timeout {
+				e := fmt.Errorf("Nothing received for %s, closing Chat", silence)
+				peerLogger.Warning(e.Error())
+				return e
+			}
+			continue
+		}
 		if err == io.EOF {
 			peerLogger.Debug("Received EOF, ending Chat")
 			return nil
@@ -613,6 +764,20 @@ func (p *PeerImpl) handleChat(ctx context.Context, stream ChatStream, initiatedS
 			peerLogger.Error(e.Error())
 			return e
 		}
+		lastReceived = time.Now()
+		if lastReceived.Sub(lastRecorded) >= time.Second {
+			// Recorded for the network map at most once a second
+			p.connectionHealth.receivedOn(stream, lastReceived)
+			lastRecorded = lastReceived
+		}
+		if first {
+			first = false
+			if err := p.admitPeer(in, clientCert); err != nil {
+				peerLogger.Warningf("Rejecting Chat: %s", err)
+				return err
+			}
+		}
+		handler, generation = p.adaptHandler(handler, stream, initiatedStream, generation)
 		err = handler.HandleMessage(in)
 		if err != nil {
 			peerLogger.Errorf("Error handling message: %s", err)
@@ -623,7 +788,14 @@ func (p *PeerImpl) handleChat(ctx context.Context, stream ChatStream, initiatedS
 
 //ExecuteTransaction executes transactions decides to do execute in dev or prod mode
 func (p *PeerImpl) ExecuteTransaction(transaction *pb.Transaction) (response *pb.Response) {
-	if p.isValidator {
+	if p.Draining() {
+		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte("Peer is draining ahead of a stop, submit the transaction to another peer")}
+	}
+	if err := p.Ready(); err != nil {
+		// Retriable, like a saturated network
+		return &pb.Response{Status: pb.Response_SATURATED, Msg: []byte(err.Error())}
+	}
+	if p.validating() {
 		response = p.sendTransactionsToLocalEngine(transaction)
 	} else {
 		peerAddresses := p.discHelper.GetRandomNodes(1)
@@ -632,6 +804,16 @@ func (p *PeerImpl) ExecuteTransaction(transaction *pb.Transaction) (response *pb
 	return response
 }
 
+// Drain makes the peer refuse new transactions ahead of a planned stop
+func (p *PeerImpl) Drain() {
+	atomic.StoreInt32(&p.draining, 1)
+}
+
+// Draining returns whether the peer refuses new transactions
+func (p *PeerImpl) Draining() bool {
+	return atomic.LoadInt32(&p.draining) == 1
+}
+
 // GetPeerEndpoint returns the endpoint for this peer
 func (p *PeerImpl) GetPeerEndpoint() (*pb.PeerEndpoint, error) {
 	ep, err := GetPeerEndpoint()
@@ -642,6 +824,15 @@ func (p *PeerImpl) GetPeerEndpoint() (*pb.PeerEndpoint, error) {
 	return ep, err
 }
 
+// GetConsensusHealth returns the health of the validating network, only validators are able to assess it
+func (p *PeerImpl) GetConsensusHealth() (*pb.ConsensusHealth, error) {
+	reporter, ok := p.getEngine().(HealthReporter)
+	if !ok {
+		return nil, fmt.Errorf("Consensus health is only available on validating peers")
+	}
+	return reporter.GetConsensusHealth()
+}
+
 func (p *PeerImpl) newHelloMessage() (*pb.HelloMessage, error) {
 	endpoint, err := p.GetPeerEndpoint()
 	if err != nil {
@@ -654,7 +845,13 @@ func (p *PeerImpl) newHelloMessage() (*pb.HelloMessage, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Error creating hello message, error getting block chain info: %s", err)
 	}
-	return &pb.HelloMessage{PeerEndpoint: endpoint, BlockchainInfo: blockChainInfo}, nil
+	return &pb.HelloMessage{
+		PeerEndpoint:       endpoint,
+		BlockchainInfo:     blockChainInfo,
+		ProtocolVersion:    ProtocolVersion,
+		MinProtocolVersion: MinProtocolVersion,
+		Capabilities:       p.capabilities(),
+	}, nil
 }
 
 // GetBlockByNumber return a block by block number
@@ -745,12 +942,14 @@ func (p *PeerImpl) PutBlock(blockNumber uint64, block *pb.Block) error {
 	return p.ledgerWrapper.ledger.PutRawBlock(block, blockNumber)
 }
 
-// NewOpenchainDiscoveryHello constructs a new HelloMessage for sending
-func (p *PeerImpl) NewOpenchainDiscoveryHello() (*pb.Message, error) {
+// NewOpenchainDiscoveryHello constructs a new HelloMessage for sending,
+// carrying the nonce of the stream it is sent on if not nil
+func (p *PeerImpl) NewOpenchainDiscoveryHello(nonce []byte) (*pb.Message, error) {
 	helloMessage, err := p.newHelloMessage()
 	if err != nil {
 		return nil, fmt.Errorf("Error getting new HelloMessage: %s", err)
 	}
+	helloMessage.Nonce = nonce
 	data, err := proto.Marshal(helloMessage)
 	if err != nil {
 		return nil, fmt.Errorf("Error marshalling HelloMessage: %s", err)
@@ -798,8 +997,11 @@ func (p *PeerImpl) initDiscovery() []string {
 	}
 	peerLogger.Debugf("Retrieved discovery list from disk: %v", addresses)
 	// parse the config file, ENV flags, etc.
-	rootNodes := strings.Split(viper.GetString("peer.discovery.rootnode"), ",")
-	if !(len(rootNodes) == 1 && strings.Compare(rootNodes[0], "") == 0) {
+	p.bootstrap = discovery.NewBootstrap(viper.GetString("peer.discovery.rootnode"),
+		viper.GetInt("peer.discovery.bootstrap.count"),
+		viper.GetDuration("peer.discovery.bootstrap.backoff"),
+		viper.GetDuration("peer.discovery.bootstrap.refresh"))
+	if rootNodes := p.bootstrap.Next(); len(rootNodes) > 0 {
 		addresses = append(rootNodes, p.discHelper.GetAllNodes()...)
 	}
 	return addresses
@@ -0,0 +1,170 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/comm"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+const (
+	// ProtocolVersion is the newest version of the peer-to-peer protocol this peer speaks
+	ProtocolVersion = 1
+	// MinProtocolVersion is the oldest version of the peer-to-peer protocol this peer speaks
+	MinProtocolVersion = 1
+)
+
+// CapabilityGossip is advertised by peers taking part in block gossip
+const CapabilityGossip = "gossip"
+
+// CapabilityRoles is advertised by peers which accept a new hello announcing
+// that a connected peer was promoted
+const CapabilityRoles = "roles"
+
+// CapabilityRenewal is advertised by peers which accept a new hello announcing
+// that a connected peer renewed its enrollment certificate
+const CapabilityRenewal = "renewal"
+
+// CapabilityPing is advertised by peers which answer DISC_PING with DISC_PONG
+const CapabilityPing = "ping"
+
+// CapabilitySequence is advertised by peers which number the messages they
+// send on the stream, and sign them for the receiving stream, so that they
+// cannot be replayed
+const CapabilitySequence = "sequence"
+
+// CapabilityCompression returns the capability advertised by peers which
+// decode message payloads compressed with encoding, e.g. gzip
+func CapabilityCompression(encoding string) string {
+	return "compression/" + encoding
+}
+
+// Protocol is what two peers agreed on in their handshake
+type Protocol struct {
+	Version      uint32
+	Capabilities []string
+}
+
+// Supports returns whether both peers support the capability
+func (pr *Protocol) Supports(capability string) bool {
+	if pr == nil {
+		return false
+	}
+	return inCapabilities(capability, pr.Capabilities)
+}
+
+// IncompatibleProtocolError is returned when two peers have no protocol version in common
+type IncompatibleProtocolError struct {
+	PeerID               string
+	LocalMin, LocalMax   uint32
+	RemoteMin, RemoteMax uint32
+}
+
+func (err *IncompatibleProtocolError) Error() string {
+	return fmt.Sprintf("Peer %s speaks protocol versions %d to %d, this peer speaks versions %d to %d", err.PeerID, err.RemoteMin, err.RemoteMax, err.LocalMin, err.LocalMax)
+}
+
+// negotiateProtocol returns the newest protocol version and the capabilities
+// both hello messages advertise
+func negotiateProtocol(local, remote *pb.HelloMessage) (*Protocol, error) {
+	localMin, localMax := protocolVersions(local)
+	remoteMin, remoteMax := protocolVersions(remote)
+	version := localMax
+	if remoteMax < version {
+		version = remoteMax
+	}
+	if version < localMin || version < remoteMin {
+		var peerID string
+		if remote.PeerEndpoint != nil && remote.PeerEndpoint.ID != nil {
+			peerID = remote.PeerEndpoint.ID.Name
+		}
+		return nil, &IncompatibleProtocolError{PeerID: peerID, LocalMin: localMin, LocalMax: localMax, RemoteMin: remoteMin, RemoteMax: remoteMax}
+	}
+	protocol := &Protocol{Version: version}
+	for _, capability := range local.Capabilities {
+		if inCapabilities(capability, remote.Capabilities) {
+			protocol.Capabilities = append(protocol.Capabilities, capability)
+		}
+	}
+	return protocol, nil
+}
+
+// protocolVersions returns the range of versions a hello message advertises,
+// peers predating protocol negotiation speak version 1
+func protocolVersions(hello *pb.HelloMessage) (uint32, uint32) {
+	if hello.ProtocolVersion == 0 {
+		return 1, 1
+	}
+	if hello.MinProtocolVersion == 0 || hello.MinProtocolVersion > hello.ProtocolVersion {
+		return hello.ProtocolVersion, hello.ProtocolVersion
+	}
+	return hello.MinProtocolVersion, hello.ProtocolVersion
+}
+
+func inCapabilities(capability string, capabilities []string) bool {
+	for _, c := range capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// capabilities returns the optional features this peer supports
+func (p *PeerImpl) capabilities() []string {
+	capabilities := []string{CapabilityRoles, CapabilityRenewal, CapabilityPing, CapabilitySequence}
+	for _, encoding := range comm.Compressions() {
+		capabilities = append(capabilities, CapabilityCompression(encoding))
+	}
+	if p.gossip != nil {
+		capabilities = append(capabilities, CapabilityGossip)
+	}
+	return capabilities
+}
+
+// NegotiateProtocol returns the protocol to speak with the peer which sent
+// the hello message, or an IncompatibleProtocolError if there is none
+func (p *PeerImpl) NegotiateProtocol(remote *pb.HelloMessage) (*Protocol, error) {
+	local := &pb.HelloMessage{ProtocolVersion: ProtocolVersion, MinProtocolVersion: MinProtocolVersion, Capabilities: p.capabilities()}
+	return negotiateProtocol(local, remote)
+}
+
+// admitPeer checks the first message received on a Chat, the hello of the
+// other peer. It rejects peers speaking no common protocol version and, if
+// clientCert is not nil, peers not holding the certificate of the identity
+// they announce.
+func (p *PeerImpl) admitPeer(msg *pb.Message, clientCert *x509.Certificate) error {
+	if clientCert != nil {
+		if err := verifyPeerIdentity(clientCert, msg); err != nil {
+			return err
+		}
+	}
+	if msg.Type != pb.Message_DISC_HELLO {
+		return nil
+	}
+	helloMessage := &pb.HelloMessage{}
+	if err := proto.Unmarshal(msg.Payload, helloMessage); err != nil {
+		return fmt.Errorf("Error unmarshalling HelloMessage: %s", err)
+	}
+	_, err := p.NegotiateProtocol(helloMessage)
+	return err
+}
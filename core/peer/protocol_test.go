@@ -0,0 +1,105 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/looplab/fsm"
+
+	"github.com/hyperledger/fabric/core/comm"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	local := &pb.HelloMessage{ProtocolVersion: 3, MinProtocolVersion: 2, Capabilities: []string{CapabilityGossip, "compression"}}
+
+	protocol, err := negotiateProtocol(local, &pb.HelloMessage{ProtocolVersion: 4, MinProtocolVersion: 1, Capabilities: []string{"compression"}})
+	if err != nil {
+		t.Fatalf("Expected overlapping versions to be compatible: %s", err)
+	}
+	if protocol.Version != 3 {
+		t.Errorf("Expected the newest common version 3, got %d", protocol.Version)
+	}
+	if !protocol.Supports("compression") || protocol.Supports(CapabilityGossip) {
+		t.Errorf("Expected only the common capabilities, got %v", protocol.Capabilities)
+	}
+
+	if _, err := negotiateProtocol(local, &pb.HelloMessage{ProtocolVersion: 6, MinProtocolVersion: 4}); err == nil {
+		t.Errorf("Expected a peer speaking only newer versions to be rejected")
+	}
+	_, err = negotiateProtocol(local, &pb.HelloMessage{PeerEndpoint: &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp1"}}})
+	if _, ok := err.(*IncompatibleProtocolError); !ok {
+		t.Errorf("Expected a peer predating negotiation, which speaks version 1, to be rejected, got %v", err)
+	}
+}
+
+func TestNegotiateProtocolWithLegacyPeer(t *testing.T) {
+	local := &pb.HelloMessage{ProtocolVersion: ProtocolVersion, MinProtocolVersion: MinProtocolVersion, Capabilities: []string{CapabilityGossip}}
+	protocol, err := negotiateProtocol(local, &pb.HelloMessage{})
+	if err != nil {
+		t.Fatalf("Expected a peer predating negotiation to be compatible: %s", err)
+	}
+	if protocol.Supports(CapabilityGossip) {
+		t.Errorf("Expected no gossip with a peer predating negotiation")
+	}
+}
+
+func TestHandlerCompressesSyncPayloads(t *testing.T) {
+	payload := bytes.Repeat([]byte("state delta "), 1000)
+	d := &Handler{syncCompression: comm.CompressionGzip, protocol: &Protocol{Capabilities: []string{CapabilityCompression(comm.CompressionGzip)}}}
+
+	msg := &pb.Message{Type: pb.Message_SYNC_STATE_DELTAS, Payload: payload}
+	if err := d.compress(msg); err != nil {
+		t.Fatalf("Error compressing: %s", err)
+	}
+	if msg.Encoding != comm.CompressionGzip || len(msg.Payload) >= len(payload) {
+		t.Fatalf("Expected the payload to be compressed, got %d bytes encoded %q", len(msg.Payload), msg.Encoding)
+	}
+	decoded, err := comm.Decompress(msg.Encoding, msg.Payload, 0)
+	if err != nil || !bytes.Equal(decoded, payload) {
+		t.Errorf("Expected the payload to decode to the original: %v", err)
+	}
+
+	// A peer which does not decode gzip is sent the payload as is
+	d.protocol = &Protocol{}
+	msg = &pb.Message{Type: pb.Message_SYNC_STATE_DELTAS, Payload: payload}
+	if err := d.compress(msg); err != nil || msg.Encoding != "" || !bytes.Equal(msg.Payload, payload) {
+		t.Errorf("Expected the payload to be sent uncompressed")
+	}
+}
+
+func TestHandlerMeasuresRoundTripWithPings(t *testing.T) {
+	stream := newCountedStream(nil)
+	d := &Handler{ChatStream: stream, ToPeerEndpoint: &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp1"}}}
+
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, uint64(time.Now().Add(-20*time.Millisecond).UnixNano()))
+	d.beforePong(&fsm.Event{Event: pb.Message_DISC_PONG.String(), Args: []interface{}{&pb.Message{Type: pb.Message_DISC_PONG, Payload: payload}}})
+	if rtt := stream.stats.Counters().LastRoundTrip; rtt < 20*time.Millisecond {
+		t.Fatalf("Expected the pong to measure at least 20ms, got %s", rtt)
+	}
+
+	e := &fsm.Event{Event: pb.Message_DISC_PONG.String(), Args: []interface{}{&pb.Message{Type: pb.Message_DISC_PONG, Payload: []byte("pong")}}}
+	d.beforePong(e)
+	if e.Err == nil {
+		t.Errorf("Expected a pong without a timestamp to be rejected")
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// HeightTracker is told of the blockchain heights other peers report, in
+// their hello, gossip digests and gossiped blocks
+type HeightTracker interface {
+	HeightReported(height uint64)
+}
+
+// ReadinessReporter is implemented by a Peer which refuses transactions until
+// it caught up with the network
+type ReadinessReporter interface {
+	Ready() error
+}
+
+// readiness keeps a freshly started peer from serving transactions, and thus
+// from answering queries from stale state, until its blockchain is within
+// maxLag blocks of the highest blockchain reported by other peers. A peer
+// configured with a root node waits until it heard of the blockchain of at
+// least one peer, the first peer of a network is ready unless it learns it is
+// behind. Once ready, the peer stays ready, falling behind later is left to
+// state transfer and gossip.
+type readiness struct {
+	lock          sync.Mutex
+	maxLag        uint64
+	deadline      time.Time // the peer gives up waiting at the deadline, zero waits forever
+	needReport    bool      // whether the peer waits to hear of another blockchain
+	networkHeight uint64    // highest blockchain height reported
+	ready         bool
+	height        func() uint64
+	now           func() time.Time
+}
+
+// newReadiness returns the readiness of a peer whose blockchain height is
+// returned by height, nil if readiness gating is disabled
+func newReadiness(height func() uint64) *readiness {
+	if !viper.GetBool("peer.readiness.enabled") {
+		return nil
+	}
+	r := &readiness{
+		maxLag:     uint64(viper.GetInt("peer.readiness.maxLag")),
+		needReport: viper.GetString("peer.discovery.rootnode") != "",
+		height:     height,
+		now:        time.Now,
+	}
+	if timeout := viper.GetDuration("peer.readiness.timeout"); timeout > 0 {
+		r.deadline = r.now().Add(timeout)
+	}
+	return r
+}
+
+// reported takes note of the blockchain height of another peer
+func (r *readiness) reported(height uint64) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.needReport = false
+	if height > r.networkHeight {
+		r.networkHeight = height
+	}
+	r.check()
+}
+
+// Ready returns why the peer is not ready yet, nil once it is
+func (r *readiness) Ready() error {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.check()
+}
+
+func (r *readiness) check() error {
+	if r.ready {
+		return nil
+	}
+	height := r.height()
+	switch {
+	case !r.needReport && height+r.maxLag >= r.networkHeight:
+		peerLogger.Infof("Ready, caught up with the network at blockchain height %d", height)
+	case !r.deadline.IsZero() && !r.now().Before(r.deadline):
+		peerLogger.Warningf("Ready without having caught up with the network, at blockchain height %d of %d", height, r.networkHeight)
+	case r.needReport:
+		return fmt.Errorf("Not ready, waiting to hear the blockchain height of other peers")
+	default:
+		return fmt.Errorf("Not ready, catching up with the network at blockchain height %d of %d", height, r.networkHeight)
+	}
+	r.ready = true
+	return nil
+}
+
+// HeightReported takes note of the blockchain height reported by another peer
+func (p *PeerImpl) HeightReported(height uint64) {
+	p.readiness.reported(height)
+}
+
+// Ready returns why the peer does not serve transactions yet, as it has not
+// caught up with the network since it started, nil once it does
+func (p *PeerImpl) Ready() error {
+	return p.readiness.Ready()
+}
@@ -0,0 +1,90 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func newTestReadiness(height *uint64, needReport bool) (*readiness, *time.Time) {
+	now := time.Unix(0, 0)
+	r := &readiness{
+		maxLag:     2,
+		needReport: needReport,
+		height:     func() uint64 { return *height },
+		now:        func() time.Time { return now },
+	}
+	return r, &now
+}
+
+func TestReadinessWaitsToCatchUp(t *testing.T) {
+	height := uint64(3)
+	r, _ := newTestReadiness(&height, true)
+
+	if r.Ready() == nil {
+		t.Fatalf("Expected a peer with a root node to wait to hear of other blockchains")
+	}
+	r.reported(10)
+	if r.Ready() == nil {
+		t.Fatalf("Expected a peer 7 blocks behind not to be ready")
+	}
+	height = 8
+	if err := r.Ready(); err != nil {
+		t.Fatalf("Expected a peer within 2 blocks of the network to be ready: %s", err)
+	}
+	// Ready peers stay ready
+	r.reported(100)
+	if err := r.Ready(); err != nil {
+		t.Errorf("Expected the peer to stay ready: %s", err)
+	}
+}
+
+func TestReadinessFirstPeer(t *testing.T) {
+	height := uint64(1)
+	r, _ := newTestReadiness(&height, false)
+	if err := r.Ready(); err != nil {
+		t.Errorf("Expected the first peer of a network to be ready: %s", err)
+	}
+}
+
+func TestReadinessTimeout(t *testing.T) {
+	height := uint64(1)
+	r, now := newTestReadiness(&height, true)
+	r.deadline = now.Add(time.Minute)
+
+	if r.Ready() == nil {
+		t.Fatalf("Expected the peer not to be ready before the deadline")
+	}
+	*now = now.Add(time.Minute)
+	if err := r.Ready(); err != nil {
+		t.Errorf("Expected the peer to stop waiting at the deadline: %s", err)
+	}
+}
+
+func TestExecuteTransactionRefusedUntilReady(t *testing.T) {
+	height := uint64(0)
+	r, _ := newTestReadiness(&height, true)
+	p := &PeerImpl{readiness: r}
+
+	response := p.ExecuteTransaction(&pb.Transaction{})
+	if response.Status != pb.Response_SATURATED {
+		t.Errorf("Expected a retriable refusal while catching up, got %v", response)
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// connectionHealth keeps the chat streams to other peers alive. A stream on
+// which nothing arrived for the health timeout is closed, peers exchange
+// discovery messages every discovery period so a healthy stream never goes
+// silent that long. The peer redials a stream it initiated once it ended,
+// with a growing backoff, until the other peer is connected again or the
+// redial attempts are exhausted, after which the touch service of discovery
+// takes over.
+type connectionHealth struct {
+	sync.Mutex
+	timeout        time.Duration // 0 disables the health check
+	attempts       int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	dialing        map[string]bool          // addresses chatWithPeer is dialing or redialing
+	drops          map[string]int           // times an established chat with an address dropped
+	received       map[ChatStream]time.Time // when a message last arrived on each chat, roughly
+	sleep          func(time.Duration)
+}
+
+func newConnectionHealth() *connectionHealth {
+	ch := &connectionHealth{
+		timeout:        viper.GetDuration("peer.connections.health.timeout"),
+		attempts:       viper.GetInt("peer.connections.redial.attempts"),
+		initialBackoff: viper.GetDuration("peer.connections.redial.initialBackoff"),
+		maxBackoff:     viper.GetDuration("peer.connections.redial.maxBackoff"),
+		dialing:        make(map[string]bool),
+		drops:          make(map[string]int),
+		received:       make(map[ChatStream]time.Time),
+		sleep:          time.Sleep,
+	}
+	if discPeriod := viper.GetDuration("peer.discovery.period"); ch.timeout > 0 && ch.timeout < 2*discPeriod {
+		peerLogger.Warningf("peer.connections.health.timeout %s is too short for peer.discovery.period %s, using %s", ch.timeout, discPeriod, 3*discPeriod)
+		ch.timeout = 3 * discPeriod
+	}
+	if ch.initialBackoff <= 0 {
+		ch.initialBackoff = time.Second
+	}
+	if ch.maxBackoff < ch.initialBackoff {
+		ch.maxBackoff = ch.initialBackoff
+	}
+	return ch
+}
+
+// startDialing returns false if address is dialed already
+func (ch *connectionHealth) startDialing(address string) bool {
+	ch.Lock()
+	defer ch.Unlock()
+	if ch.dialing[address] {
+		return false
+	}
+	ch.dialing[address] = true
+	return true
+}
+
+func (ch *connectionHealth) stopDialing(address string) {
+	ch.Lock()
+	defer ch.Unlock()
+	delete(ch.dialing, address)
+}
+
+// dropped records that an established chat with address ended after lasting
+// for duration
+func (ch *connectionHealth) dropped(address string, duration time.Duration, err error) {
+	ch.Lock()
+	ch.drops[address]++
+	drops := ch.drops[address]
+	ch.Unlock()
+	if err != nil {
+		peerLogger.Warningf("Chat with peer address %s dropped after %s, %d drops so far: %s", address, duration, drops, err)
+	} else {
+		peerLogger.Warningf("Chat with peer address %s ended after %s, %d drops so far", address, duration, drops)
+	}
+}
+
+// Drops returns how often the chats with each peer address dropped
+func (ch *connectionHealth) Drops() map[string]int {
+	ch.Lock()
+	defer ch.Unlock()
+	drops := make(map[string]int, len(ch.drops))
+	for address, count := range ch.drops {
+		drops[address] = count
+	}
+	return drops
+}
+
+// receivedOn records that a message arrived on stream at time at
+func (ch *connectionHealth) receivedOn(stream ChatStream, at time.Time) {
+	ch.Lock()
+	defer ch.Unlock()
+	if ch.received == nil {
+		ch.received = make(map[ChatStream]time.Time)
+	}
+	ch.received[stream] = at
+}
+
+// closed forgets about a chat which ended
+func (ch *connectionHealth) closed(stream ChatStream) {
+	ch.Lock()
+	defer ch.Unlock()
+	delete(ch.received, stream)
+}
+
+// lastReceived returns when a message last arrived on stream, if one did
+func (ch *connectionHealth) lastReceived(stream ChatStream) (time.Time, bool) {
+	ch.Lock()
+	defer ch.Unlock()
+	at, ok := ch.received[stream]
+	return at, ok
+}
+
+// chatWithPeer chats with the peer at address and redials it when the chat
+// ends, see connectionHealth
+func (p *PeerImpl) chatWithPeer(address string) error {
+	ch := p.connectionHealth
+	if !ch.startDialing(address) {
+		peerLogger.Debugf("Already dialing peer address %s", address)
+		return nil
+	}
+	defer ch.stopDialing(address)
+
+	backoff := ch.initialBackoff
+	failures := 0
+	for {
+		started := time.Now()
+		established, err := p.chatOnce(address)
+		if established {
+			lasted := time.Since(started)
+			ch.dropped(address, lasted, err)
+			// Only a chat which lasted resets the backoff, a peer which keeps
+			// rejecting the chat right away is redialed ever more slowly
+			if lasted >= ch.maxBackoff {
+				failures, backoff = 0, ch.initialBackoff
+			}
+		}
+		failures++
+		if failures > ch.attempts {
+			peerLogger.Warningf("Giving up redialing peer address %s after %d attempts, leaving it to discovery", address, ch.attempts)
+			return err
+		}
+		ch.sleep(backoff)
+		if p.connectedTo(address) {
+			peerLogger.Debugf("Peer address %s is connected again, no need to redial", address)
+			return nil
+		}
+		peerLogger.Infof("Redialing peer address %s, attempt %d of %d", address, failures, ch.attempts)
+		if backoff *= 2; backoff > ch.maxBackoff {
+			backoff = ch.maxBackoff
+		}
+	}
+}
+
+// connectedTo returns whether a chat with the peer at address is registered
+func (p *PeerImpl) connectedTo(address string) bool {
+	for _, msgHandler := range p.cloneHandlerMap(pb.PeerEndpoint_UNDEFINED) {
+		if pe, err := msgHandler.To(); err == nil && pe.Address == address {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// silentStream is a chat stream on which nothing arrives
+type silentStream struct {
+	closed chan struct{}
+}
+
+func (s *silentStream) Send(*pb.Message) error { return nil }
+
+func (s *silentStream) Recv() (*pb.Message, error) {
+	<-s.closed
+	return nil, fmt.Errorf("stream closed")
+}
+
+type stubHandler struct {
+	MessageHandler
+	stopped bool
+}
+
+func (h *stubHandler) Stop() error {
+	h.stopped = true
+	return nil
+}
+
+func TestHandleChatClosesSilentStream(t *testing.T) {
+	handler := &stubHandler{}
+	p := &PeerImpl{
+		handlerFactory: func(MessageHandlerCoordinator, ChatStream, bool, MessageHandler) (MessageHandler, error) {
+			return handler, nil
+		},
+		connectionHealth: &connectionHealth{timeout: 50 * time.Millisecond},
+	}
+	stream := &silentStream{closed: make(chan struct{})}
+	defer close(stream.closed)
+
+	done := make(chan error)
+	go func() {
+		done <- p.handleChat(context.Background(), stream, true)
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("Expected the silent stream to be closed with an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the silent stream to be closed after the health timeout")
+	}
+	if !handler.stopped {
+		t.Errorf("Expected the handler of the closed stream to be stopped")
+	}
+}
+
+func TestConnectionHealthDialsOnce(t *testing.T) {
+	ch := &connectionHealth{dialing: make(map[string]bool), drops: make(map[string]int)}
+	if !ch.startDialing("10.0.0.1:30303") {
+		t.Fatalf("Expected the first dial to start")
+	}
+	if ch.startDialing("10.0.0.1:30303") {
+		t.Errorf("Expected an address to be dialed only once at a time")
+	}
+	ch.stopDialing("10.0.0.1:30303")
+	if !ch.startDialing("10.0.0.1:30303") {
+		t.Errorf("Expected the address to be dialed again once the previous dial stopped")
+	}
+
+	ch.dropped("10.0.0.1:30303", time.Second, nil)
+	ch.dropped("10.0.0.1:30303", time.Second, fmt.Errorf("reset"))
+	if drops := ch.Drops()["10.0.0.1:30303"]; drops != 2 {
+		t.Errorf("Expected 2 drops to be counted, got %d", drops)
+	}
+}
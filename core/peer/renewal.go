@@ -0,0 +1,84 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/crypto"
+)
+
+// RenewCertificates loads the renewed TLS certificate of the peer's servers
+// and the renewed enrollment certificate, e.g. ahead of the expiry of the
+// current ones, without a restart. The established connections are kept,
+// the connected peers are sent a new hello signed with the renewed
+// enrollment key one at a time, so that they verify the messages of this
+// peer, consensus messages included, against the renewed certificate.
+func (p *PeerImpl) RenewCertificates() error {
+	if comm.TLSEnabled() {
+		if err := comm.ReloadServerCertificate(); err != nil {
+			return fmt.Errorf("Error loading the renewed TLS certificate: %s", err)
+		}
+		peerLogger.Info("Loaded the renewed TLS certificate")
+	}
+	if SecurityEnabled() {
+		renewer, ok := p.secHelper.(crypto.EnrollmentRenewer)
+		if !ok {
+			return fmt.Errorf("The security layer cannot renew the enrollment certificate")
+		}
+		if err := renewer.RenewEnrollment(); err != nil {
+			return fmt.Errorf("Error loading the renewed enrollment certificate: %s", err)
+		}
+		peerLogger.Info("Loaded the renewed enrollment certificate")
+	}
+	go p.announceRenewal()
+	return nil
+}
+
+// ReEnroll rotates the enrollment key of the peer: the ECA issues a new
+// enrollment certificate pair for new keys against a request signed with the
+// current key, and the connected peers are sent a new hello signed with the
+// new key. The ECA keeps the previous certificate valid for its configured
+// overlap period, so that the peers which have not taken note of the new
+// identity yet still accept the messages of this peer.
+func (p *PeerImpl) ReEnroll() error {
+	if !SecurityEnabled() {
+		return fmt.Errorf("Re-enrollment requires security to be enabled")
+	}
+	renewer, ok := p.secHelper.(crypto.EnrollmentRenewer)
+	if !ok {
+		return fmt.Errorf("The security layer cannot rotate the enrollment key")
+	}
+	if err := renewer.ReEnroll(); err != nil {
+		return fmt.Errorf("Error re-enrolling: %s", err)
+	}
+	peerLogger.Info("Rotated the enrollment key")
+	go p.announceRenewal()
+	return nil
+}
+
+// announceRenewal sends the renewed identity of the peer to the connected
+// peers
+func (p *PeerImpl) announceRenewal() {
+	unsupported := p.announceEndpoint(CapabilityRenewal, viper.GetDuration("peer.renewal.announceInterval"))
+	if len(unsupported) > 0 && SecurityEnabled() {
+		peerLogger.Warningf("Peers %v cannot take note of the renewed enrollment certificate until they reconnect", unsupported)
+	}
+}
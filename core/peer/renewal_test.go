@@ -0,0 +1,93 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/looplab/fsm"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func newRenewalTestHello(t *testing.T, endpoint *pb.PeerEndpoint, nonce []byte) (*pb.Message, *pb.HelloMessage) {
+	hello := &pb.HelloMessage{PeerEndpoint: endpoint, Nonce: nonce}
+	payload, err := proto.Marshal(hello)
+	if err != nil {
+		t.Fatalf("Error marshalling hello: %s", err)
+	}
+	return &pb.Message{Type: pb.Message_DISC_HELLO, Payload: payload}, hello
+}
+
+func TestEndpointChangedTakesNoteOfRenewal(t *testing.T) {
+	if SecurityEnabled() {
+		t.Skip("The hello would have to be signed with an enrollment certificate")
+	}
+	previous := &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp1"}, Type: pb.PeerEndpoint_VALIDATOR, PkiID: []byte("current")}
+	d := &Handler{ToPeerEndpoint: previous, nonce: []byte("stream")}
+
+	msg, hello := newRenewalTestHello(t, &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp1"}, Type: pb.PeerEndpoint_VALIDATOR, PkiID: []byte("renewed")}, d.nonce)
+	e := &fsm.Event{}
+	d.endpointChanged(e, msg, hello)
+	if e.Err != nil {
+		t.Fatalf("Error taking note of the renewal: %s", e.Err)
+	}
+	if !bytes.Equal(d.ToPeerEndpoint.PkiID, []byte("renewed")) || d.ToPeerEndpoint.Type != pb.PeerEndpoint_VALIDATOR {
+		t.Errorf("Expected the renewed PkiID to be taken note of, got %v", d.ToPeerEndpoint)
+	}
+	if !bytes.Equal(previous.PkiID, []byte("current")) {
+		t.Errorf("Expected the previous PeerEndpoint to be left unchanged")
+	}
+
+	msg, hello = newRenewalTestHello(t, &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp2"}, PkiID: []byte("other")}, d.nonce)
+	e = &fsm.Event{}
+	d.endpointChanged(e, msg, hello)
+	if e.Err == nil {
+		t.Errorf("Expected a hello announcing another peer to be refused")
+	}
+	if !bytes.Equal(d.ToPeerEndpoint.PkiID, []byte("renewed")) {
+		t.Errorf("Expected the refused hello to be ignored")
+	}
+}
+
+func TestEndpointChangedRefusesReplayedHello(t *testing.T) {
+	if SecurityEnabled() {
+		t.Skip("The hello would have to be signed with an enrollment certificate")
+	}
+	previous := &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp1"}, Type: pb.PeerEndpoint_VALIDATOR, PkiID: []byte("current")}
+	d := &Handler{ToPeerEndpoint: previous, nonce: []byte("stream")}
+
+	// An announcement captured on an earlier stream to the peer
+	msg, hello := newRenewalTestHello(t, &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp1"}, Type: pb.PeerEndpoint_VALIDATOR, PkiID: []byte("stale")}, []byte("earlier stream"))
+	e := &fsm.Event{}
+	d.endpointChanged(e, msg, hello)
+	if e.Err == nil {
+		t.Fatalf("Expected a hello sent on another stream to be refused")
+	}
+	if d.ToPeerEndpoint != previous {
+		t.Fatalf("Expected the replayed hello to be ignored, got %v", d.ToPeerEndpoint)
+	}
+
+	msg, hello = newRenewalTestHello(t, &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp1"}, Type: pb.PeerEndpoint_VALIDATOR, PkiID: []byte("stale")}, nil)
+	e = &fsm.Event{}
+	d.endpointChanged(e, msg, hello)
+	if e.Err == nil || d.ToPeerEndpoint != previous {
+		t.Errorf("Expected a hello without a nonce to be refused")
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// unsignedMessage returns whether messages of type t are numbered but not
+// signed on the stream: hellos sign their payload, consensus messages are
+// signed by the consensus plugin and pings carry nothing worth forging
+func unsignedMessage(t pb.Message_Type) bool {
+	switch t {
+	case pb.Message_DISC_HELLO, pb.Message_CONSENSUS, pb.Message_DISC_PING, pb.Message_DISC_PONG:
+		return true
+	default:
+		return false
+	}
+}
+
+// messageDigest returns what the signature of msg covers when it is sent to
+// the peer which sent nonce in its hello
+func messageDigest(nonce []byte, msg *pb.Message) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(len(nonce)))
+	buf.Write(nonce)
+	binary.Write(&buf, binary.BigEndian, msg.Sequence)
+	binary.Write(&buf, binary.BigEndian, int32(msg.Type))
+	binary.Write(&buf, binary.BigEndian, uint32(len(msg.Encoding)))
+	buf.WriteString(msg.Encoding)
+	buf.Write(msg.Payload)
+	return buf.Bytes()
+}
+
+// newStreamNonce returns the nonce this peer sends in its hello on a new stream
+func newStreamNonce() ([]byte, error) {
+	nonce, err := primitives.GetRandomNonce()
+	if err != nil {
+		return nil, fmt.Errorf("Error generating the nonce of the stream: %s", err)
+	}
+	return nonce, nil
+}
+
+// sequenced returns whether the messages on the stream are numbered
+func (d *Handler) sequenced() bool {
+	return d.Protocol().Supports(CapabilitySequence)
+}
+
+// seal returns msg numbered, and signed if security is enabled, to be sent
+// next on the stream. msg itself is left alone as it may be broadcast to
+// other peers. Called with the chatMutex held.
+func (d *Handler) seal(msg *pb.Message) (*pb.Message, error) {
+	if !d.sequenced() {
+		return msg, nil
+	}
+	sealed := *msg
+	d.sentSequence++
+	sealed.Sequence = d.sentSequence
+	if SecurityEnabled() && !unsignedMessage(sealed.Type) {
+		signature, err := d.Coordinator.GetSecHelper().Sign(messageDigest(d.remoteNonce, &sealed))
+		if err != nil {
+			return nil, fmt.Errorf("Error signing %s: %s", sealed.Type, err)
+		}
+		sealed.Signature = signature
+	}
+	return &sealed, nil
+}
+
+// checkReplay returns an error if msg was already received on the stream or
+// was not signed for this stream, as when it was captured and replayed
+func (d *Handler) checkReplay(msg *pb.Message) error {
+	if !d.sequenced() {
+		return nil
+	}
+	if msg.Sequence <= d.receivedSequence {
+		return fmt.Errorf("Received %s numbered %d after %d, it may be replayed", msg.Type, msg.Sequence, d.receivedSequence)
+	}
+	if SecurityEnabled() && !unsignedMessage(msg.Type) {
+		if err := d.Coordinator.GetSecHelper().Verify(d.ToPeerEndpoint.PkiID, msg.Signature, messageDigest(d.nonce, msg)); err != nil {
+			return fmt.Errorf("Error verifying the signature of %s numbered %d: %s", msg.Type, msg.Sequence, err)
+		}
+	}
+	d.receivedSequence = msg.Sequence
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestHandlerRejectsReplayedMessages(t *testing.T) {
+	d := &Handler{protocol: &Protocol{Capabilities: []string{CapabilitySequence}}}
+
+	// A broadcast message is numbered for each stream without being modified
+	msg := &pb.Message{Type: pb.Message_DISC_GET_PEERS}
+	for i := uint64(1); i <= 2; i++ {
+		sealed, err := d.seal(msg)
+		if err != nil {
+			t.Fatalf("Error sealing: %s", err)
+		}
+		if sealed.Sequence != i {
+			t.Fatalf("Expected message %d to be numbered %d", i, sealed.Sequence)
+		}
+	}
+	if msg.Sequence != 0 {
+		t.Fatalf("Expected the message passed in to be left alone, got sequence %d", msg.Sequence)
+	}
+
+	for _, c := range []struct {
+		sequence uint64
+		accepted bool
+	}{{1, true}, {1, false}, {3, true}, {2, false}, {0, false}} {
+		err := d.checkReplay(&pb.Message{Type: pb.Message_DISC_PEERS, Sequence: c.sequence})
+		if c.accepted != (err == nil) {
+			t.Errorf("Expected message numbered %d accepted %v, got %v", c.sequence, c.accepted, err)
+		}
+	}
+
+	// Peers which do not number their messages are not checked
+	d = &Handler{protocol: &Protocol{}}
+	if sealed, _ := d.seal(msg); sealed.Sequence != 0 {
+		t.Errorf("Expected messages to legacy peers not to be numbered")
+	}
+	if err := d.checkReplay(msg); err != nil {
+		t.Errorf("Expected messages from legacy peers to be accepted: %s", err)
+	}
+}
+
+func TestMessageDigestCoversStream(t *testing.T) {
+	msg := &pb.Message{Type: pb.Message_SYNC_BLOCKS, Payload: []byte("gzipblocks"), Sequence: 7}
+	digest := messageDigest([]byte("stream"), msg)
+
+	for name, other := range map[string][]byte{
+		"nonce":    messageDigest([]byte("other"), msg),
+		"sequence": messageDigest([]byte("stream"), &pb.Message{Type: msg.Type, Payload: msg.Payload, Sequence: 8}),
+		"type":     messageDigest([]byte("stream"), &pb.Message{Type: pb.Message_SYNC_STATE_DELTAS, Payload: msg.Payload, Sequence: 7}),
+		"encoding": messageDigest([]byte("stream"), &pb.Message{Type: msg.Type, Encoding: "gzip", Payload: []byte("blocks"), Sequence: 7}),
+	} {
+		if bytes.Equal(digest, other) {
+			t.Errorf("Expected the digest to cover the %s", name)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// SetEngineFactory sets the factory creating the consensus engine when a
+// peer started as non-validating is promoted to validator
+func (p *PeerImpl) SetEngineFactory(engFactory EngineFactory) {
+	p.roleLock.Lock()
+	defer p.roleLock.Unlock()
+	p.engineFactory = engFactory
+}
+
+// validating returns whether the peer currently is a validator
+func (p *PeerImpl) validating() bool {
+	p.roleLock.RLock()
+	defer p.roleLock.RUnlock()
+	return p.isValidator
+}
+
+func (p *PeerImpl) getEngine() Engine {
+	p.roleLock.RLock()
+	defer p.roleLock.RUnlock()
+	return p.engine
+}
+
+// roleHandlerFactory returns the handler factory for the current role, and
+// the generation of the role
+func (p *PeerImpl) roleHandlerFactory() (HandlerFactory, uint64) {
+	p.roleLock.RLock()
+	defer p.roleLock.RUnlock()
+	return p.handlerFactory, p.roleGeneration
+}
+
+// becomeValidator promotes the peer to validator without a restart. The
+// consensus engine is created, the handlers of the existing connections are
+// extended for the validating role as their next message arrives, and the
+// connected peers are told of the new role with a new hello. A validator is
+// never demoted at runtime, the membership of the validating network is
+// fixed by its configuration.
+func (p *PeerImpl) becomeValidator() error {
+	p.roleLock.Lock()
+	if p.isValidator {
+		p.roleLock.Unlock()
+		return nil
+	}
+
+	// The engine asks for the PeerEndpoint, which must show the new role
+	setValidatorEnabled(true)
+	if err := p.promote(); err != nil {
+		setValidatorEnabled(false)
+		p.roleLock.Unlock()
+		return err
+	}
+	p.isValidator = true
+	p.roleGeneration++
+	p.roleLock.Unlock()
+
+	if p.gossip != nil {
+		p.gossip.setApply(false)
+	}
+	peerLogger.Info("Promoted to validator")
+	p.announceEndpoint(CapabilityRoles, 0)
+	return nil
+}
+
+// promote creates the engine of the validator, with roleLock held
+func (p *PeerImpl) promote() error {
+	if SecurityEnabled() && p.secHelper.GetType() != crypto.NodeValidator {
+		// The enrollment certificate states the role, a standby is
+		// enrolled as the validator it stands by for
+		return fmt.Errorf("The peer is enrolled as non-validator, enroll it as validator and restart it to promote it")
+	}
+	if p.engineFactory == nil {
+		return fmt.Errorf("No consensus engine is available to promote the peer")
+	}
+	engine, err := p.engineFactory(p)
+	if err != nil {
+		return fmt.Errorf("Error creating the consensus engine: %s", err)
+	}
+	handlerFactory := engine.GetHandlerFactory()
+	if handlerFactory == nil {
+		return fmt.Errorf("Cannot supply nil handler factory")
+	}
+	p.engine = engine
+	p.handlerFactory = handlerFactory
+	return nil
+}
+
+// adaptHandler extends the handler of a Chat for the validating role if the
+// peer was promoted since the handler was created, keeping the handler of
+// the connection so the connection state is kept
+func (p *PeerImpl) adaptHandler(handler MessageHandler, stream ChatStream, initiatedStream bool, generation uint64) (MessageHandler, uint64) {
+	p.roleLock.RLock()
+	handlerFactory, current := p.handlerFactory, p.roleGeneration
+	p.roleLock.RUnlock()
+	if current == generation {
+		return handler, generation
+	}
+	extended, err := handlerFactory(p, stream, initiatedStream, handler)
+	if err != nil {
+		peerLogger.Errorf("Error extending handler for the validator role: %s", err)
+		return handler, current
+	}
+	return extended, current
+}
+
+// announceEndpoint sends a new hello to the connected peers supporting
+// capability, pausing between peers, and returns the peers which do not
+// support it. Each hello carries the nonce the peer sent on the stream, so
+// it cannot be replayed on another stream.
+func (p *PeerImpl) announceEndpoint(capability string, pause time.Duration) []pb.PeerID {
+	var unsupported []pb.PeerID
+	sent := false
+	for peerID, msgHandler := range p.cloneHandlerMap(pb.PeerEndpoint_UNDEFINED) {
+		d := connectionHandler(msgHandler)
+		if d == nil || !d.Protocol().Supports(capability) {
+			unsupported = append(unsupported, peerID)
+			continue
+		}
+		hello, err := p.NewOpenchainDiscoveryHello(d.remoteNonce)
+		if err != nil {
+			peerLogger.Errorf("Error announcing the new endpoint: %s", err)
+			return unsupported
+		}
+		if sent && pause > 0 {
+			time.Sleep(pause)
+		}
+		sent = true
+		if err := msgHandler.SendMessage(hello); err != nil {
+			peerLogger.Warningf("Error announcing the new endpoint to %s: %s", peerID, err)
+		}
+	}
+	return unsupported
+}
@@ -0,0 +1,118 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type roleTestEngine struct{}
+
+func (e *roleTestEngine) ProcessTransactionMsg(*pb.Message, *pb.Transaction) *pb.Response {
+	return nil
+}
+
+func (e *roleTestEngine) GetHandlerFactory() HandlerFactory {
+	return func(coord MessageHandlerCoordinator, stream ChatStream, initiatedStream bool, next MessageHandler) (MessageHandler, error) {
+		return &roleTestHandler{MessageHandler: next}, nil
+	}
+}
+
+// roleTestHandler stands in for the consensus handler extending a peer handler
+type roleTestHandler struct {
+	MessageHandler
+}
+
+func newRoleTestPeer(validator bool, engine Engine) *PeerImpl {
+	p := &PeerImpl{
+		handlerMap:  &handlerMap{m: make(map[pb.PeerID]MessageHandler)},
+		isValidator: validator,
+		engine:      engine,
+	}
+	p.handlerFactory = NewPeerHandler
+	if engine != nil && validator {
+		p.handlerFactory = engine.GetHandlerFactory()
+	}
+	setValidatorEnabled(validator)
+	return p
+}
+
+func TestBecomeValidatorCreatesEngine(t *testing.T) {
+	defer setValidatorEnabled(ValidatorEnabled())
+	if SecurityEnabled() {
+		t.Skip("Promoting a non-validator enrolled with security requires enrolling it again")
+	}
+	p := newRoleTestPeer(false, nil)
+	if err := p.becomeValidator(); err == nil {
+		t.Fatalf("Expected the promotion to fail without an engine factory")
+	}
+	if p.validating() || ValidatorEnabled() {
+		t.Fatalf("Expected the peer to stay non-validating")
+	}
+
+	engine := &roleTestEngine{}
+	p.SetEngineFactory(func(MessageHandlerCoordinator) (Engine, error) {
+		return engine, nil
+	})
+	if err := p.becomeValidator(); err != nil {
+		t.Fatalf("Error promoting the peer: %s", err)
+	}
+	if p.getEngine() != engine || !p.validating() || !ValidatorEnabled() {
+		t.Fatalf("Expected the engine to be created and the peer to be validating")
+	}
+	if endpoint, err := GetPeerEndpoint(); err == nil && endpoint.Type != pb.PeerEndpoint_VALIDATOR {
+		t.Errorf("Expected the peer endpoint to show the validator role, got %v", endpoint.Type)
+	}
+	if _, generation := p.roleHandlerFactory(); generation != 1 {
+		t.Errorf("Expected one promotion, got %d", generation)
+	}
+
+	// Promoting a validator is a no-op
+	if err := p.becomeValidator(); err != nil {
+		t.Errorf("Error promoting a validator: %s", err)
+	}
+	if _, generation := p.roleHandlerFactory(); generation != 1 {
+		t.Errorf("Expected promoting a validator to do nothing, got generation %d", generation)
+	}
+}
+
+func TestAdaptHandlerFollowsPromotion(t *testing.T) {
+	defer setValidatorEnabled(ValidatorEnabled())
+	p := newRoleTestPeer(false, &roleTestEngine{})
+	var handler MessageHandler = &stubHandler{}
+	_, generation := p.roleHandlerFactory()
+
+	if adapted, current := p.adaptHandler(handler, nil, true, generation); adapted != handler || current != generation {
+		t.Fatalf("Expected the handler to be kept while the role is unchanged")
+	}
+
+	p.roleLock.Lock()
+	p.isValidator = true
+	p.handlerFactory = p.engine.GetHandlerFactory()
+	p.roleGeneration++
+	p.roleLock.Unlock()
+	adapted, current := p.adaptHandler(handler, nil, true, generation)
+	extended, ok := adapted.(*roleTestHandler)
+	if !ok || extended.MessageHandler != handler {
+		t.Fatalf("Expected the handler to be extended for the validator role")
+	}
+	if again, _ := p.adaptHandler(adapted, nil, true, current); again != adapted {
+		t.Errorf("Expected the extended handler to be kept once adapted")
+	}
+}
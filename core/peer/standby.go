@@ -0,0 +1,223 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Standby is implemented by a Peer able to stand by for a primary validator
+type Standby interface {
+	// StandbyFor returns the address of the primary, empty unless the peer
+	// stands by for one
+	StandbyFor() string
+	// Takeover makes the peer take over from its primary
+	Takeover() error
+}
+
+// StandbyEnabled returns whether peer.standby.primary configures the peer as
+// the standby of a primary validator
+func StandbyEnabled() bool {
+	return viper.GetString("peer.standby.primary") != ""
+}
+
+// standby keeps a peer standing by for a primary validator. The standby
+// shares the identity of the primary, its peer ID and enrollment, runs as a
+// non-validator chatting only with the primary, and thus replicates the
+// blockchain of the primary through gossip. The addresses of the network the
+// primary tells of are kept for the takeover. The standby checks every
+// interval that it is connected to the primary, and takes over after
+// threshold failed checks if automatic, or else when told to.
+type standby struct {
+	lock      sync.Mutex
+	primary   string
+	active    bool // standing by, until the peer takes over
+	interval  time.Duration
+	threshold int
+	automatic bool
+	failures  int             // consecutive failed checks
+	network   map[string]bool // addresses learned while standing by
+}
+
+// newStandby returns the standby configured in peer.standby, nil unless the
+// peer is a standby
+func newStandby() *standby {
+	if !StandbyEnabled() {
+		return nil
+	}
+	s := &standby{
+		primary:   viper.GetString("peer.standby.primary"),
+		active:    true,
+		interval:  viper.GetDuration("peer.standby.checkInterval"),
+		threshold: viper.GetInt("peer.standby.failureThreshold"),
+		automatic: viper.GetBool("peer.standby.autoFailover"),
+		network:   make(map[string]bool),
+	}
+	if s.interval <= 0 {
+		s.interval = 5 * time.Second
+	}
+	if s.threshold <= 0 {
+		s.threshold = 1
+	}
+	return s
+}
+
+// standingBy returns whether the peer still stands by
+func (s *standby) standingBy() bool {
+	if s == nil {
+		return false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.active
+}
+
+// filter returns the addresses to chat with among addresses, none while
+// standing by, and keeps the others for the takeover. The standby dials the
+// primary itself.
+func (s *standby) filter(addresses []string) []string {
+	if s == nil {
+		return addresses
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.active {
+		return addresses
+	}
+	for _, address := range addresses {
+		if address != s.primary {
+			s.network[address] = true
+		}
+	}
+	return nil
+}
+
+// checked takes note of the outcome of a check of the primary, and returns
+// the number of consecutive failed checks
+func (s *standby) checked(connected bool) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if connected {
+		s.failures = 0
+	} else {
+		s.failures++
+	}
+	return s.failures
+}
+
+// stop ends standing by and returns the addresses of the network
+func (s *standby) stop() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.active = false
+	addresses := make([]string, 0, len(s.network))
+	for address := range s.network {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+func (s *standby) resume() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.active = true
+}
+
+// StandbyFor returns the address of the primary the peer stands by for,
+// empty unless it is a standby which did not take over yet
+func (p *PeerImpl) StandbyFor() string {
+	if !p.standby.standingBy() {
+		return ""
+	}
+	return p.standby.primary
+}
+
+// primaryConnected returns whether the standby chats with its primary, the
+// peer sharing its ID
+func (p *PeerImpl) primaryConnected() bool {
+	pe, err := GetPeerEndpoint()
+	if err != nil {
+		return false
+	}
+	p.handlerMap.RLock()
+	defer p.handlerMap.RUnlock()
+	_, ok := p.handlerMap.m[*pe.ID]
+	return ok
+}
+
+// standBy connects to the primary and checks on it until the peer takes over
+func (p *PeerImpl) standBy() {
+	s := p.standby
+	peerLogger.Infof("Standing by for primary %s", s.primary)
+	go p.chatWithPeer(s.primary)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !s.standingBy() {
+			return
+		}
+		connected := p.primaryConnected()
+		failures := s.checked(connected)
+		if connected {
+			continue
+		}
+		// chatWithPeer does nothing while it is still redialing
+		go p.chatWithPeer(s.primary)
+		if failures < s.threshold {
+			peerLogger.Warningf("Primary %s is not connected, %d failed checks of %d", s.primary, failures, s.threshold)
+			continue
+		}
+		if !s.automatic {
+			if failures == s.threshold {
+				peerLogger.Errorf("Primary %s is not connected after %d checks, take over with 'peer node takeover' once it is down", s.primary, failures)
+			}
+			continue
+		}
+		peerLogger.Warningf("Primary %s is not connected after %d checks, taking over", s.primary, failures)
+		if err := p.Takeover(); err != nil {
+			peerLogger.Errorf("Error taking over from primary %s: %s", s.primary, err)
+			continue
+		}
+		return
+	}
+}
+
+// Takeover makes a standby take over from its primary: it stops standing by,
+// becomes the validator the primary was and connects to the network the
+// primary was connected to. The primary must be down, the network would
+// otherwise know two validators by the same ID.
+func (p *PeerImpl) Takeover() error {
+	if !p.standby.standingBy() {
+		return fmt.Errorf("The peer does not stand by for a primary")
+	}
+	if p.primaryConnected() {
+		return fmt.Errorf("The peer is still connected to primary %s, stop the primary before taking over", p.standby.primary)
+	}
+	network := p.standby.stop()
+	if err := p.becomeValidator(); err != nil {
+		p.standby.resume()
+		return err
+	}
+	peerLogger.Infof("Took over from primary %s, connecting to %v", p.standby.primary, network)
+	p.chatWithSomePeers(network)
+	return nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func newStandbyTestPeer(engine Engine) *PeerImpl {
+	p := newRoleTestPeer(false, nil)
+	p.SetEngineFactory(func(MessageHandlerCoordinator) (Engine, error) {
+		return engine, nil
+	})
+	p.standby = &standby{primary: "primary:30303", active: true, threshold: 2, network: make(map[string]bool)}
+	// Keep the touch service from starting
+	p.reconnectOnce.Do(func() {})
+	return p
+}
+
+func TestStandbyChatsOnlyWithPrimary(t *testing.T) {
+	s := &standby{primary: "primary:30303", active: true, network: make(map[string]bool)}
+	if addresses := s.filter([]string{"primary:30303", "vp2:30303"}); len(addresses) != 0 {
+		t.Fatalf("Expected a standby not to chat with the network, got %v", addresses)
+	}
+	if network := s.stop(); len(network) != 1 || network[0] != "vp2:30303" {
+		t.Fatalf("Expected the network to be kept for the takeover, got %v", network)
+	}
+	if addresses := s.filter([]string{"vp3:30303"}); len(addresses) != 1 {
+		t.Errorf("Expected the peer to chat with the network once it took over")
+	}
+
+	var none *standby
+	if addresses := none.filter([]string{"vp2:30303"}); len(addresses) != 1 || none.standingBy() {
+		t.Errorf("Expected a peer which is not a standby to chat with the network")
+	}
+}
+
+func TestStandbyCountsFailedChecks(t *testing.T) {
+	s := &standby{}
+	s.checked(false)
+	if failures := s.checked(false); failures != 2 {
+		t.Fatalf("Expected two failed checks, got %d", failures)
+	}
+	if failures := s.checked(true); failures != 0 {
+		t.Errorf("Expected a successful check to reset the failures, got %d", failures)
+	}
+}
+
+func TestTakeover(t *testing.T) {
+	defer setValidatorEnabled(ValidatorEnabled())
+	if SecurityEnabled() {
+		t.Skip("Promoting requires a validator enrollment")
+	}
+	engine := &roleTestEngine{}
+	p := newStandbyTestPeer(engine)
+
+	pe, err := GetPeerEndpoint()
+	if err != nil {
+		t.Fatalf("Error getting the peer endpoint: %s", err)
+	}
+	// The primary shares the ID of the standby
+	p.handlerMap.m[*pe.ID] = &stubHandler{}
+	if err := p.Takeover(); err == nil || !p.standby.standingBy() {
+		t.Fatalf("Expected the standby to refuse taking over while connected to the primary")
+	}
+
+	delete(p.handlerMap.m, *pe.ID)
+	if err := p.Takeover(); err != nil {
+		t.Fatalf("Error taking over: %s", err)
+	}
+	if p.standby.standingBy() || p.StandbyFor() != "" || !p.validating() || p.getEngine() != engine {
+		t.Fatalf("Expected the standby to become a validator")
+	}
+	if endpoint, err := GetPeerEndpoint(); err == nil && endpoint.Type != pb.PeerEndpoint_VALIDATOR {
+		t.Errorf("Expected the peer endpoint to show the validator role, got %v", endpoint.Type)
+	}
+	if err := p.Takeover(); err == nil {
+		t.Errorf("Expected a second takeover to be refused")
+	}
+}
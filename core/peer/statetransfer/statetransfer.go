@@ -87,6 +87,7 @@ type coordinatorImpl struct {
 	StateSnapshotRequestTimeout time.Duration // How long to wait for a peer to respond to a state snapshot request
 
 	maxStateDeltas     int    // The maximum number of state deltas to attempt to retrieve before giving up and performing a full state snapshot retrieval
+	maxSyncSources     int    // The maximum number of peers to retrieve blocks from concurrently
 	maxBlockRange      uint64 // The maximum number blocks to attempt to retrieve at once, to prevent from overflowing the peer's buffer
 	maxStateDeltaRange uint64 // The maximum number of state deltas to attempt to retrieve at once, to prevent from overflowing the peer's buffer
 
@@ -172,6 +173,11 @@ func NewCoordinatorImpl(stack PartialStack) Coordinator {
 		panic(fmt.Errorf("sts.maxdeltas must be greater than 0"))
 	}
 
+	sts.maxSyncSources = viper.GetInt("statetransfer.maxsources")
+	if sts.maxSyncSources <= 0 {
+		sts.maxSyncSources = 1
+	}
+
 	tmp := viper.GetInt("peer.sync.blocks.channelSize")
 	if tmp <= 0 {
 		panic(fmt.Errorf("peer.sync.blocks.channelSize must be greater than 0"))
@@ -225,9 +231,9 @@ func (a blockRangeSlice) Less(i, j int) bool {
 // helper functions for state transfer
 // =============================================================================
 
-// Executes a func trying each peer included in peerIDs until successful
-// Attempts to execute over all peers if peerIDs is nil
-func (sts *coordinatorImpl) tryOverPeers(passedPeerIDs []*pb.PeerID, do func(peerID *pb.PeerID) error) (err error) {
+// syncSources returns the peers to transfer from, the passed peerIDs or, if
+// nil, all other validating peers
+func (sts *coordinatorImpl) syncSources(passedPeerIDs []*pb.PeerID) ([]*pb.PeerID, error) {
 
 	peerIDs := passedPeerIDs
 
@@ -236,15 +242,15 @@ func (sts *coordinatorImpl) tryOverPeers(passedPeerIDs []*pb.PeerID, do func(pee
 	if err != nil {
 		// Unless we throttle here, this condition will likely cause a tight loop which will adversely affect the rest of the system
 		time.Sleep(sts.DiscoveryThrottleTime)
-		return fmt.Errorf("Error resolving our own PeerID, this shouldn't happen")
+		return nil, fmt.Errorf("Error resolving our own PeerID, this shouldn't happen")
 	}
 
 	if nil == passedPeerIDs {
-		logger.Debugf("tryOverPeers: no peerIDs given, discovering")
+		logger.Debugf("syncSources: no peerIDs given, discovering")
 
 		peersMsg, err := sts.stack.GetPeers()
 		if err != nil {
-			return fmt.Errorf("Couldn't retrieve list of peers: %v", err)
+			return nil, fmt.Errorf("Couldn't retrieve list of peers: %v", err)
 		}
 		peers := peersMsg.GetPeers()
 		for _, endpoint := range peers {
@@ -259,25 +265,37 @@ func (sts *coordinatorImpl) tryOverPeers(passedPeerIDs []*pb.PeerID, do func(pee
 		logger.Debugf("Discovered %d peerIDs", len(peerIDs))
 	}
 
-	logger.Debugf("tryOverPeers: using peerIDs: %v", peerIDs)
-
 	if 0 == len(peerIDs) {
-		logger.Errorf("Invoked tryOverPeers with no peers specified, throttling thread")
+		logger.Errorf("No peers specified to transfer from, throttling thread")
 		// Unless we throttle here, this condition will likely cause a tight loop which will adversely affect the rest of the system
 		time.Sleep(sts.DiscoveryThrottleTime)
-		return fmt.Errorf("No peers available to try over")
+		return nil, fmt.Errorf("No peers available to try over")
+	}
+
+	// Start at a random peer, so that the load is spread over the network
+	startIndex := rand.Int() % len(peerIDs)
+	sources := make([]*pb.PeerID, 0, len(peerIDs))
+	sources = append(sources, peerIDs[startIndex:]...)
+	return append(sources, peerIDs[:startIndex]...), nil
+}
+
+// Executes a func trying each peer included in peerIDs until successful
+// Attempts to execute over all peers if peerIDs is nil
+func (sts *coordinatorImpl) tryOverPeers(passedPeerIDs []*pb.PeerID, do func(peerID *pb.PeerID) error) (err error) {
+
+	peerIDs, err := sts.syncSources(passedPeerIDs)
+	if err != nil {
+		return err
 	}
 
-	numReplicas := len(peerIDs)
-	startIndex := rand.Int() % numReplicas
+	logger.Debugf("tryOverPeers: using peerIDs: %v", peerIDs)
 
-	for i := 0; i < numReplicas; i++ {
-		index := (i + startIndex) % numReplicas
-		err = do(peerIDs[index])
+	for _, peerID := range peerIDs {
+		err = do(peerID)
 		if err == nil {
 			break
 		} else {
-			logger.Warningf("tryOverPeers: loop error from %v : %s", peerIDs[index], err)
+			logger.Warningf("tryOverPeers: loop error from %v : %s", peerID, err)
 		}
 	}
 
@@ -285,7 +303,21 @@ func (sts *coordinatorImpl) tryOverPeers(passedPeerIDs []*pb.PeerID, do func(pee
 
 }
 
+// blockChunk is a range of blocks, from high down to low, retrieved from a single peer
+type blockChunk struct {
+	high   uint64
+	low    uint64
+	source *pb.PeerID
+	blocks []*pb.Block // blocks[i] is block high-i
+	err    error
+}
+
 // Attempts to complete a blockSyncReq using the supplied peers
+// The range is split into chunks which are retrieved from several peers concurrently, each chunk
+// is checked to chain internally as it arrives, and to chain to the blocks above it before it is
+// put, so that blocks are only ever put once the hash chain down from highHash vouches for them.
+// A peer which fails to deliver a valid chunk is not asked again, and its chunk is retried with
+// the remaining peers.
 // Will return the last block number attempted to sync, and the last block successfully synced (or nil) and error on failure
 // This means on failure, the returned block corresponds to 1 higher than the returned block number
 func (sts *coordinatorImpl) syncBlocks(highBlock, lowBlock uint64, highHash []byte, peerIDs []*pb.PeerID) (uint64, *pb.Block, error) {
@@ -295,103 +327,111 @@ func (sts *coordinatorImpl) syncBlocks(highBlock, lowBlock uint64, highHash []by
 	var block *pb.Block
 	var goodRange *blockRange
 
-	err := sts.tryOverPeers(peerIDs, func(peerID *pb.PeerID) error {
-		for {
-			intermediateBlock := blockCursor + 1
-			var blockChan <-chan *pb.SyncBlocks
-			var err error
-			for {
-
-				if intermediateBlock == blockCursor+1 {
-					if sts.maxBlockRange > blockCursor {
-						// Don't underflow
-						intermediateBlock = 0
-					} else {
-						intermediateBlock = blockCursor - sts.maxBlockRange
-					}
-					if intermediateBlock < lowBlock {
-						intermediateBlock = lowBlock
-					}
-					logger.Debugf("Requesting block range from %d to %d", blockCursor, intermediateBlock)
-					blockChan, err = sts.GetRemoteBlocks(peerID, blockCursor, intermediateBlock)
-				}
-
-				if nil != err {
-					logger.Warningf("Failed to get blocks from %d to %d from %v: %s", blockCursor, lowBlock, peerID, err)
-					return err
-				}
+	sources, err := sts.syncSources(peerIDs)
 
-				select {
-				case syncBlockMessage, ok := <-blockChan:
-
-					if !ok {
-						return fmt.Errorf("Channel closed before we could finish reading")
-					}
-
-					if syncBlockMessage.Range.Start < syncBlockMessage.Range.End {
-						// If the message is not replying with blocks backwards, we did not ask for it
-						return fmt.Errorf("Received a block with wrong (increasing) order from %v, aborting", peerID)
-					}
+	// Chunks waiting to be retrieved, highest first
+	var queue []*blockChunk
+	for high := highBlock; err == nil; {
+		low := lowBlock
+		if high-lowBlock > sts.maxBlockRange {
+			low = high - sts.maxBlockRange
+		}
+		queue = append(queue, &blockChunk{high: high, low: low})
+		if low == lowBlock {
+			break
+		}
+		high = low - 1
+	}
 
-					var i int
-					for i, block = range syncBlockMessage.Blocks {
-						// It no longer correct to get duplication or out of range blocks, so we treat this as an error
-						if syncBlockMessage.Range.Start-uint64(i) != blockCursor {
-							return fmt.Errorf("Received a block out of order, indicating a buffer overflow or other corruption: start=%d, end=%d, wanted %d", syncBlockMessage.Range.Start, syncBlockMessage.Range.End, blockCursor)
-						}
+	parallel := sts.maxSyncSources
+	if parallel > len(sources) {
+		parallel = len(sources)
+	}
+	window := 2 * parallel // Bounds the chunks held in memory while waiting for the ones above them
 
-						testHash, err := sts.stack.HashBlock(block)
-						if nil != err {
-							return fmt.Errorf("Got a block %d which could not hash from %v: %s", blockCursor, peerID, err)
-						}
+	busy := make(map[pb.PeerID]bool)
+	failed := make(map[pb.PeerID]bool)
+	retrieved := make(map[uint64]*blockChunk) // By high block
+	results := make(chan *blockChunk, len(sources))
+	inFlight := 0
 
-						if !bytes.Equal(testHash, validBlockHash) {
-							return fmt.Errorf("Got block %d from %v with hash %x, was expecting hash %x", blockCursor, peerID, testHash, validBlockHash)
-						}
+	for err == nil {
+		// Hand out chunks to the idle peers
+		for len(queue) > 0 && inFlight < parallel && inFlight+len(retrieved) < window {
+			var source *pb.PeerID
+			for _, peerID := range sources {
+				if !busy[*peerID] && !failed[*peerID] {
+					source = peerID
+					break
+				}
+			}
+			if source == nil {
+				break
+			}
+			chunk := queue[0]
+			queue = queue[1:]
+			chunk.source = source
+			busy[*source] = true
+			inFlight++
+			logger.Debugf("Requesting block range from %d to %d from %v", chunk.high, chunk.low, source)
+			go func(chunk *blockChunk) {
+				chunk.blocks, chunk.err = sts.fetchBlockChunk(chunk.source, chunk.high, chunk.low)
+				results <- chunk
+			}(chunk)
+		}
 
-						logger.Debugf("Putting block %d to with PreviousBlockHash %x and StateHash %x", blockCursor, block.PreviousBlockHash, block.StateHash)
-						if !sts.RecoverDamage {
-
-							// If we are not supposed to be destructive in our recovery, check to make sure this block doesn't already exist
-							if oldBlock, err := sts.stack.GetBlockByNumber(blockCursor); err == nil && oldBlock != nil {
-								oldBlockHash, err := sts.stack.HashBlock(oldBlock)
-								if nil == err {
-									if !bytes.Equal(oldBlockHash, validBlockHash) {
-										panic("The blockchain is corrupt and the configuration has specified that bad blocks should not be deleted/overridden")
-									}
-								} else {
-									logger.Errorf("Could not compute the hash of block %d", blockCursor)
-									panic("The blockchain is corrupt and the configuration has specified that bad blocks should not be deleted/overridden")
-								}
-								logger.Debugf("Not actually putting block %d to with PreviousBlockHash %x and StateHash %x, as it already exists", blockCursor, block.PreviousBlockHash, block.StateHash)
-							} else {
-								sts.stack.PutBlock(blockCursor, block)
-							}
-						} else {
-							sts.stack.PutBlock(blockCursor, block)
-						}
+		if inFlight == 0 {
+			err = fmt.Errorf("Failed to get blocks from %d to %d, no peer left to try", blockCursor, lowBlock)
+			break
+		}
 
-						goodRange = &blockRange{
-							highBlock:   highBlock,
-							lowBlock:    blockCursor,
-							lowNextHash: block.PreviousBlockHash,
-						}
+		chunk := <-results
+		inFlight--
+		delete(busy, *chunk.source)
+		if chunk.err != nil {
+			logger.Warningf("Failed to get blocks from %d to %d from %v, failing over: %s", chunk.high, chunk.low, chunk.source, chunk.err)
+			failed[*chunk.source] = true
+			queue = requeueBlockChunk(queue, chunk)
+			continue
+		}
+		retrieved[chunk.high] = chunk
+
+		// Put the chunks which chain to the blocks already put
+		for chunk, ok := retrieved[blockCursor]; ok; chunk, ok = retrieved[blockCursor] {
+			delete(retrieved, blockCursor)
+
+			testHash, hashErr := sts.stack.HashBlock(chunk.blocks[0])
+			if nil != hashErr || !bytes.Equal(testHash, validBlockHash) {
+				logger.Warningf("Got block %d from %v with hash %x, was expecting hash %x, failing over", blockCursor, chunk.source, testHash, validBlockHash)
+				failed[*chunk.source] = true
+				queue = requeueBlockChunk(queue, chunk)
+				break
+			}
 
-						validBlockHash = block.PreviousBlockHash
+			for i, chunkBlock := range chunk.blocks {
+				blockCursor = chunk.high - uint64(i)
+				block = chunkBlock
+				sts.putBlock(blockCursor, block, validBlockHash)
+				validBlockHash = block.PreviousBlockHash
+			}
 
-						if blockCursor == lowBlock {
-							logger.Debugf("Successfully synced from block %d to block %d", highBlock, lowBlock)
-							return nil
-						}
-						blockCursor--
+			goodRange = &blockRange{
+				highBlock:   highBlock,
+				lowBlock:    blockCursor,
+				lowNextHash: block.PreviousBlockHash,
+			}
 
-					}
-				case <-time.After(sts.BlockRequestTimeout):
-					return fmt.Errorf("Had block sync request to %v time out", peerID)
-				}
+			if blockCursor == lowBlock {
+				logger.Debugf("Successfully synced from block %d to block %d", highBlock, lowBlock)
+				break
 			}
+			blockCursor--
 		}
-	})
+
+		if goodRange != nil && goodRange.lowBlock == lowBlock {
+			break
+		}
+	}
 
 	if nil != block {
 		logger.Debugf("Returned from sync with block %d and state hash %x", blockCursor, block.StateHash)
@@ -400,7 +440,6 @@ func (sts *coordinatorImpl) syncBlocks(highBlock, lowBlock uint64, highHash []by
 	}
 
 	if goodRange != nil {
-		goodRange.lowNextHash = block.PreviousBlockHash
 		sts.validBlockRanges = append(sts.validBlockRanges, goodRange)
 	}
 
@@ -408,6 +447,97 @@ func (sts *coordinatorImpl) syncBlocks(highBlock, lowBlock uint64, highHash []by
 
 }
 
+// requeueBlockChunk puts a chunk which could not be retrieved back in the queue, keeping it highest first
+func requeueBlockChunk(queue []*blockChunk, chunk *blockChunk) []*blockChunk {
+	chunk.source, chunk.blocks, chunk.err = nil, nil, nil
+	i := 0
+	for i < len(queue) && queue[i].high > chunk.high {
+		i++
+	}
+	queue = append(queue, nil)
+	copy(queue[i+1:], queue[i:])
+	queue[i] = chunk
+	return queue
+}
+
+// fetchBlockChunk retrieves the blocks from high down to low from a peer, checking that each
+// block is the predecessor of the one retrieved before it
+func (sts *coordinatorImpl) fetchBlockChunk(peerID *pb.PeerID, high, low uint64) ([]*pb.Block, error) {
+	blockChan, err := sts.GetRemoteBlocks(peerID, high, low)
+	if nil != err {
+		return nil, err
+	}
+
+	blocks := make([]*pb.Block, 0, high-low+1)
+	var nextHash []byte
+	blockCursor := high
+	for {
+		select {
+		case syncBlockMessage, ok := <-blockChan:
+
+			if !ok {
+				return nil, fmt.Errorf("Channel closed before we could finish reading")
+			}
+
+			if syncBlockMessage.Range.Start < syncBlockMessage.Range.End {
+				// If the message is not replying with blocks backwards, we did not ask for it
+				return nil, fmt.Errorf("Received a block with wrong (increasing) order from %v, aborting", peerID)
+			}
+
+			for i, block := range syncBlockMessage.Blocks {
+				// It no longer correct to get duplication or out of range blocks, so we treat this as an error
+				if syncBlockMessage.Range.Start-uint64(i) != blockCursor {
+					return nil, fmt.Errorf("Received a block out of order, indicating a buffer overflow or other corruption: start=%d, end=%d, wanted %d", syncBlockMessage.Range.Start, syncBlockMessage.Range.End, blockCursor)
+				}
+
+				if nextHash != nil {
+					testHash, err := sts.stack.HashBlock(block)
+					if nil != err {
+						return nil, fmt.Errorf("Got a block %d which could not hash from %v: %s", blockCursor, peerID, err)
+					}
+
+					if !bytes.Equal(testHash, nextHash) {
+						return nil, fmt.Errorf("Got block %d from %v with hash %x, was expecting hash %x", blockCursor, peerID, testHash, nextHash)
+					}
+				}
+
+				blocks = append(blocks, block)
+				nextHash = block.PreviousBlockHash
+
+				if blockCursor == low {
+					return blocks, nil
+				}
+				blockCursor--
+			}
+		case <-time.After(sts.BlockRequestTimeout):
+			return nil, fmt.Errorf("Had block sync request to %v time out", peerID)
+		}
+	}
+}
+
+// putBlock puts a block whose hash has been verified, unless damage may not be recovered and the block is already present
+func (sts *coordinatorImpl) putBlock(blockNumber uint64, block *pb.Block, blockHash []byte) {
+	logger.Debugf("Putting block %d to with PreviousBlockHash %x and StateHash %x", blockNumber, block.PreviousBlockHash, block.StateHash)
+	if !sts.RecoverDamage {
+
+		// If we are not supposed to be destructive in our recovery, check to make sure this block doesn't already exist
+		if oldBlock, err := sts.stack.GetBlockByNumber(blockNumber); err == nil && oldBlock != nil {
+			oldBlockHash, err := sts.stack.HashBlock(oldBlock)
+			if nil == err {
+				if !bytes.Equal(oldBlockHash, blockHash) {
+					panic("The blockchain is corrupt and the configuration has specified that bad blocks should not be deleted/overridden")
+				}
+			} else {
+				logger.Errorf("Could not compute the hash of block %d", blockNumber)
+				panic("The blockchain is corrupt and the configuration has specified that bad blocks should not be deleted/overridden")
+			}
+			logger.Debugf("Not actually putting block %d to with PreviousBlockHash %x and StateHash %x, as it already exists", blockNumber, block.PreviousBlockHash, block.StateHash)
+			return
+		}
+	}
+	sts.stack.PutBlock(blockNumber, block)
+}
+
 func (sts *coordinatorImpl) syncBlockchainToTarget(blockSyncReq *blockSyncReq) {
 
 	logger.Debugf("Processing a blockSyncReq to block %d", blockSyncReq.blockNumber)
@@ -618,3 +618,124 @@ func TestBlockRangeOrdering(t *testing.T) {
 		t.Fatalf("Low range should come third")
 	}
 }
+
+// forkedRemoteLedger serves a blockchain which chains internally, but forks from the real one
+type forkedRemoteLedger struct {
+	*MockRemoteLedger
+}
+
+func forkedBlock(blockNumber uint64) *protos.Block {
+	block := SimpleGetBlock(blockNumber)
+	block.ConsensusMetadata = []byte(fmt.Sprintf("Forked:%d", blockNumber))
+	if blockNumber > 0 {
+		block.PreviousBlockHash = SimpleHashBlock(forkedBlock(blockNumber - 1))
+	}
+	return block
+}
+
+func (fork *forkedRemoteLedger) GetBlockByNumber(blockNumber uint64) (*protos.Block, error) {
+	if _, err := fork.MockRemoteLedger.GetBlockByNumber(blockNumber); err != nil {
+		return nil, err
+	}
+	return forkedBlock(blockNumber), nil
+}
+
+// makeRecordingFilter records the peers blocks were requested from
+func makeRecordingFilter() (func(mockRequest, *protos.PeerID) mockResponse, func() map[protos.PeerID]bool) {
+	var mutex sync.Mutex
+	requested := make(map[protos.PeerID]bool)
+	return func(request mockRequest, peerID *protos.PeerID) mockResponse {
+			if request == SyncBlocks {
+				mutex.Lock()
+				requested[*peerID] = true
+				mutex.Unlock()
+			}
+			return Normal
+		}, func() map[protos.PeerID]bool {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return requested
+		}
+}
+
+func checkSyncedBlocks(t *testing.T, ml *MockLedger, low, high uint64) {
+	for i := low; i <= high; i++ {
+		block, err := ml.GetBlockByNumber(i)
+		if err != nil {
+			t.Fatalf("Expected block %d but got error %s", i, err)
+		}
+		if !bytes.Equal(SimpleHashBlock(block), SimpleGetBlockHash(i)) {
+			t.Fatalf("Block %d does not belong to the blockchain", i)
+		}
+	}
+}
+
+func TestSyncBlocksFromSeveralPeers(t *testing.T) {
+	mrls := createRemoteLedgers(1, 3)
+	for peerID := range mrls.remoteLedgers {
+		mrls.GetMockRemoteLedgerByPeerID(&peerID).blockHeight = 11
+	}
+
+	filter, requested := makeRecordingFilter()
+	ml := NewMockLedger(mrls, filter, t)
+	ml.PutBlock(0, SimpleGetBlock(0))
+	sts := newTestThreadlessStateTransfer(ml, mrls)
+	sts.maxBlockRange = 2
+	sts.maxSyncSources = 3
+
+	if _, _, err := sts.syncBlocks(10, 1, SimpleGetBlockHash(10), nil); err != nil {
+		t.Fatalf("Error syncing blocks: %s", err)
+	}
+	checkSyncedBlocks(t, ml, 1, 10)
+
+	if len(requested()) != 3 {
+		t.Errorf("Expected blocks to be requested from all 3 peers, were requested from %d", len(requested()))
+	}
+}
+
+func TestSyncBlocksFailsOverForkedPeer(t *testing.T) {
+	mrls := createRemoteLedgers(1, 3)
+	for peerID := range mrls.remoteLedgers {
+		mrls.GetMockRemoteLedgerByPeerID(&peerID).blockHeight = 11
+	}
+	forkedID := protos.PeerID{Name: "Peer 2"}
+	mrls.remoteLedgers[forkedID] = &forkedRemoteLedger{mrls.GetMockRemoteLedgerByPeerID(&forkedID)}
+
+	filter, requested := makeRecordingFilter()
+	ml := NewMockLedger(mrls, filter, t)
+	ml.PutBlock(0, SimpleGetBlock(0))
+	sts := newTestThreadlessStateTransfer(ml, mrls)
+	sts.maxBlockRange = 2
+	sts.maxSyncSources = 3
+
+	if _, _, err := sts.syncBlocks(10, 1, SimpleGetBlockHash(10), nil); err != nil {
+		t.Fatalf("Error syncing blocks: %s", err)
+	}
+	checkSyncedBlocks(t, ml, 1, 10)
+
+	if !requested()[forkedID] {
+		t.Errorf("Expected blocks to be requested from the forked peer")
+	}
+}
+
+func TestSyncBlocksFailsWithoutHonestPeers(t *testing.T) {
+	mrls := createRemoteLedgers(1, 2)
+	for peerID := range mrls.remoteLedgers {
+		mrls.GetMockRemoteLedgerByPeerID(&peerID).blockHeight = 11
+	}
+	for peerID := range mrls.remoteLedgers {
+		mrls.remoteLedgers[peerID] = &forkedRemoteLedger{mrls.GetMockRemoteLedgerByPeerID(&peerID)}
+	}
+
+	ml := NewMockLedger(mrls, nil, t)
+	ml.PutBlock(0, SimpleGetBlock(0))
+	sts := newTestThreadlessStateTransfer(ml, mrls)
+	sts.maxBlockRange = 2
+
+	if _, block, err := sts.syncBlocks(10, 1, SimpleGetBlockHash(10), nil); err == nil || block != nil {
+		t.Fatalf("Expected no block of a forked blockchain to be synced, got block %v, err %v", block, err)
+	}
+	if size := ml.GetBlockchainSize(); size != 1 {
+		t.Errorf("Expected the blockchain to be untouched, it is %d tall", size)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"io"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/comm"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// countedStream counts the traffic of a chat stream
+type countedStream struct {
+	ChatStream
+	stats comm.ConnectionStats
+}
+
+func newCountedStream(stream ChatStream) *countedStream {
+	return &countedStream{ChatStream: stream}
+}
+
+func (s *countedStream) Send(msg *pb.Message) error {
+	err := s.ChatStream.Send(msg)
+	s.stats.Sent(proto.Size(msg), err)
+	return err
+}
+
+func (s *countedStream) Recv() (*pb.Message, error) {
+	msg, err := s.ChatStream.Recv()
+	if err == io.EOF {
+		return msg, err
+	}
+	if err != nil {
+		s.stats.Received(0, err)
+		return msg, err
+	}
+	s.stats.Received(proto.Size(msg), nil)
+	return msg, nil
+}
+
+// chatStats returns the statistics of a chat stream, nil if it is not counted
+func chatStats(stream ChatStream) *comm.ConnectionStats {
+	if counted, ok := stream.(*countedStream); ok {
+		return &counted.stats
+	}
+	return nil
+}
+
+// connectionStats returns the statistics of stream for the network map
+func connectionStats(stream ChatStream) *pb.ConnectionStats {
+	stats := chatStats(stream)
+	if stats == nil {
+		return nil
+	}
+	counters := stats.Counters()
+	return &pb.ConnectionStats{
+		BytesSent:           counters.BytesSent,
+		BytesReceived:       counters.BytesReceived,
+		MessagesSent:        counters.MessagesSent,
+		MessagesReceived:    counters.MessagesReceived,
+		SendErrors:          counters.SendErrors,
+		ReceiveErrors:       counters.ReceiveErrors,
+		RoundTripMicros:     int64(counters.RoundTrip / time.Microsecond),
+		LastRoundTripMicros: int64(counters.LastRoundTrip / time.Microsecond),
+	}
+}
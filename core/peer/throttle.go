@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"sync"
+	"time"
+)
+
+// syncThrottle limits the rate at which the blocks and state requested by a
+// syncing peer are sent over its connection, so that a peer catching up does
+// not starve the consensus traffic sharing the link. It is a token bucket
+// holding up to burst bytes, refilled at rate bytes per second. A message is
+// sent as soon as the bucket is not in debt, and may put it in debt, so that
+// messages larger than the burst are sent too.
+type syncThrottle struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(time.Duration)
+}
+
+// newSyncThrottle returns a throttle sending rate bytes per second, with
+// bursts of up to burst bytes, one second worth of traffic if burst is not
+// positive. It returns nil, which does not throttle, if rate is not positive.
+func newSyncThrottle(rate, burst int) *syncThrottle {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+	return &syncThrottle{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// wait blocks until a message of size bytes may be sent. Senders on the same
+// connection wait in turn.
+func (t *syncThrottle) wait(size int) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.now()
+	t.tokens += now.Sub(t.last).Seconds() * t.rate
+	if t.tokens > t.burst {
+		t.tokens = t.burst
+	}
+	t.last = now
+	if t.tokens < 0 {
+		// Pay off the debt of the previous message first
+		t.pause(-t.tokens)
+	}
+	t.tokens -= float64(size)
+}
+
+// pause sleeps for the time it takes to refill the bucket with bytes, which
+// is accounted for by the next wait
+func (t *syncThrottle) pause(bytes float64) {
+	delay := time.Duration(bytes / t.rate * float64(time.Second))
+	peerLogger.Debugf("Throttling sync traffic for %s", delay)
+	t.sleep(delay)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestSyncThrottle returns a throttle on a fake clock, which advances
+// when the throttle sleeps, and the total time slept
+func newTestSyncThrottle(rate, burst int) (*syncThrottle, *time.Time, *time.Duration) {
+	t := newSyncThrottle(rate, burst)
+	now := time.Unix(0, 0)
+	var slept time.Duration
+	t.last = now
+	t.now = func() time.Time { return now }
+	t.sleep = func(d time.Duration) {
+		slept += d
+		now = now.Add(d)
+	}
+	return t, &now, &slept
+}
+
+func TestSyncThrottleLimitsRate(t *testing.T) {
+	throttle, _, slept := newTestSyncThrottle(1000, 500)
+
+	// The burst is sent at once
+	throttle.wait(500)
+	if *slept != 0 {
+		t.Fatalf("Expected the burst not to be throttled, slept %s", *slept)
+	}
+	// Ten more kilobytes take ten seconds
+	for i := 0; i < 10; i++ {
+		throttle.wait(1000)
+	}
+	if *slept < 9*time.Second || *slept > 10*time.Second {
+		t.Errorf("Expected sending 10500 bytes at 1000 bytes per second to take about ten seconds, slept %s", *slept)
+	}
+}
+
+func TestSyncThrottleRefillsUpToBurst(t *testing.T) {
+	throttle, now, slept := newTestSyncThrottle(1000, 500)
+	throttle.wait(500)
+
+	// A long pause only refills the burst
+	*now = now.Add(time.Minute)
+	throttle.wait(500)
+	throttle.wait(500)
+	if *slept != 0 {
+		t.Fatalf("Expected no throttling while the bucket is not in debt, slept %s", *slept)
+	}
+	throttle.wait(1)
+	if *slept != 500*time.Millisecond {
+		t.Errorf("Expected to wait for the bucket to refill, slept %s", *slept)
+	}
+}
+
+func TestSyncThrottleDisabled(t *testing.T) {
+	if throttle := newSyncThrottle(0, 100); throttle != nil {
+		t.Fatalf("Expected no throttle without a rate")
+	}
+	// A nil throttle does not block
+	var throttle *syncThrottle
+	throttle.wait(1 << 30)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/crypto"
+)
+
+// ProvisionTLSCertificate obtains the TLS certificate of the peer from the
+// TLSCA if peer.tls.provision.enabled is set and peer.tls.cert.file does not
+// exist yet, e.g. on the first start of a peer given only its enrollment
+// credentials. It must run before the servers load the certificate.
+func ProvisionTLSCertificate(secHelper crypto.Peer) error {
+	if !comm.TLSEnabled() || !viper.GetBool("peer.tls.provision.enabled") {
+		return nil
+	}
+	if _, err := os.Stat(viper.GetString("peer.tls.cert.file")); !os.IsNotExist(err) {
+		return err
+	}
+	return provisionTLSCertificate(secHelper)
+}
+
+// RenewTLSCertificate obtains a new TLS certificate of the peer from the
+// TLSCA and loads it, as RenewCertificates does
+func (p *PeerImpl) RenewTLSCertificate() error {
+	if err := provisionTLSCertificate(p.secHelper); err != nil {
+		return err
+	}
+	if err := comm.ReloadServerCertificate(); err != nil {
+		return fmt.Errorf("Error loading the renewed TLS certificate: %s", err)
+	}
+	peerLogger.Info("Loaded the renewed TLS certificate")
+	return nil
+}
+
+// provisionTLSCertificate requests a TLS certificate for the hosts of the
+// peer from the TLSCA, and writes it to peer.tls.cert.file, its key to
+// peer.tls.key.file and the TLSCA certificates to peer.tls.rootcert.file,
+// if set
+func provisionTLSCertificate(secHelper crypto.Peer) error {
+	provisioner, ok := secHelper.(crypto.TLSProvisioner)
+	if !ok {
+		return fmt.Errorf("Provisioning the TLS certificate requires security to be enabled")
+	}
+	hosts, err := provisionHosts()
+	if err != nil {
+		return err
+	}
+	cert, key, chain, err := provisioner.ProvisionTLSCertificate(hosts)
+	if err != nil {
+		return fmt.Errorf("Error requesting the TLS certificate from the TLSCA: %s", err)
+	}
+
+	// The key goes first, so that a certificate never comes with a stale key
+	if err = writeFileAtomic(viper.GetString("peer.tls.key.file"), key, 0600); err != nil {
+		return err
+	}
+	if err = writeFileAtomic(viper.GetString("peer.tls.cert.file"), cert, 0644); err != nil {
+		return err
+	}
+	if rootCert := viper.GetString("peer.tls.rootcert.file"); rootCert != "" && len(chain) > 0 {
+		if err = writeFileAtomic(rootCert, chain, 0644); err != nil {
+			return err
+		}
+	}
+	peerLogger.Infof("Obtained the TLS certificate for %v from the TLSCA", hosts)
+	return nil
+}
+
+// provisionHosts returns peer.tls.provision.hosts, or else the host of the
+// address of the peer and peer.tls.serverhostoverride
+func provisionHosts() ([]string, error) {
+	if hosts := viper.GetStringSlice("peer.tls.provision.hosts"); len(hosts) > 0 {
+		return hosts, nil
+	}
+	endpoint, err := GetPeerEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	host, _, err := net.SplitHostPort(endpoint.Address)
+	if err != nil {
+		return nil, err
+	}
+	hosts := []string{host}
+	if override := viper.GetString("peer.tls.serverhostoverride"); override != "" && override != host {
+		hosts = append(hosts, override)
+	}
+	return hosts, nil
+}
+
+// writeFileAtomic replaces file by data, so that readers see either the
+// former or the new content
+func writeFileAtomic(file string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
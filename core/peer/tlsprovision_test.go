@@ -0,0 +1,51 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestProvisionHosts(t *testing.T) {
+	defer func() {
+		viper.Set("peer.externalAddress", "")
+		viper.Set("peer.tls.serverhostoverride", "")
+		viper.Set("peer.tls.provision.hosts", []string{})
+		CacheConfiguration()
+	}()
+
+	viper.Set("peer.externalAddress", "vp0.example.com:30303")
+	viper.Set("peer.tls.serverhostoverride", "peer")
+	if err := CacheConfiguration(); err != nil {
+		t.Fatalf("Error caching configuration: %s", err)
+	}
+	hosts, err := provisionHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hosts, []string{"vp0.example.com", "peer"}) {
+		t.Errorf("Expected the host of the peer address and the server host override, got %v", hosts)
+	}
+
+	viper.Set("peer.tls.provision.hosts", []string{"vp0", "10.0.0.1"})
+	if hosts, _ = provisionHosts(); !reflect.DeepEqual(hosts, []string{"vp0", "10.0.0.1"}) {
+		t.Errorf("Expected the configured hosts, got %v", hosts)
+	}
+}
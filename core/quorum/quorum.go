@@ -0,0 +1,189 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package quorum requires sensitive operations to be approved by m of the n
+// administrators of the network, each signing the operation with its own key,
+// so that no single administrator key controls the network.
+package quorum
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ed25519"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+var logger = logging.MustGetLogger("quorum")
+
+// The operations requiring the approval of a quorum of administrators
+const (
+	OperationDeploy      = "deploy"
+	OperationSetLogLevel = "setModuleLogLevel"
+)
+
+// Policy holds the administrators of the network and how many of them must
+// approve an operation
+type Policy struct {
+	threshold int
+	maxAge    time.Duration
+	// The keys of the administrators by DER encoded certificate
+	admins map[string]crypto.PublicKey
+}
+
+// NewPolicy creates the policy configured under key: the PEM files of the
+// certificates of the administrators in key.certificates, the number of
+// approvals required in key.threshold, 0 to not require any, and how long
+// an approval of a peer operation is valid in key.maxAge
+func NewPolicy(key string) (*Policy, error) {
+	p := &Policy{
+		threshold: viper.GetInt(key + ".threshold"),
+		maxAge:    viper.GetDuration(key + ".maxAge"),
+		admins:    make(map[string]crypto.PublicKey),
+	}
+	if p.threshold <= 0 {
+		return p, nil
+	}
+	if p.maxAge <= 0 {
+		p.maxAge = 5 * time.Minute
+	}
+	for _, file := range viper.GetStringSlice(key + ".certificates") {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s.certificates: %s", key, err)
+		}
+		for block, rest := pem.Decode(raw); block != nil; block, rest = pem.Decode(rest) {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing certificate in %s: %s", file, err)
+			}
+			p.admins[string(cert.Raw)] = cert.PublicKey
+		}
+	}
+	if p.threshold > len(p.admins) {
+		return nil, fmt.Errorf("%s.threshold is %d but only %d administrator certificates are configured", key, p.threshold, len(p.admins))
+	}
+	return p, nil
+}
+
+// Enabled reports whether operations require approvals
+func (p *Policy) Enabled() bool {
+	return p != nil && p.threshold > 0
+}
+
+// Verify returns an error unless approvals carry valid signatures of
+// operation on payload by at least the threshold of distinct administrators.
+// Approvals older than the configured maximum age are ignored if fresh is
+// set, which peer operations do but validators cannot, as they must agree
+// on the outcome whenever they execute a transaction.
+func (p *Policy) Verify(operation string, payload []byte, approvals []*pb.AdminApproval, fresh bool) error {
+	if !p.Enabled() {
+		return nil
+	}
+	approved := make(map[string]bool)
+	for _, approval := range approvals {
+		key, ok := p.admins[string(approval.Certificate)]
+		if !ok || approved[string(approval.Certificate)] {
+			continue
+		}
+		if fresh {
+			if age := time.Since(time.Unix(0, approval.Timestamp)); age > p.maxAge || age < -p.maxAge {
+				logger.Warningf("Ignoring approval of %s from %s ago", operation, age)
+				continue
+			}
+		}
+		if err := verify(key, Digest(operation, payload, approval.Timestamp), approval.Signature); err != nil {
+			logger.Warningf("Ignoring approval of %s: %s", operation, err)
+			continue
+		}
+		approved[string(approval.Certificate)] = true
+	}
+	if len(approved) < p.threshold {
+		return fmt.Errorf("%s requires the approval of %d of %d administrators, got %d", operation, p.threshold, len(p.admins), len(approved))
+	}
+	return nil
+}
+
+// Digest returns the digest of operation on payload approved at timestamp
+func Digest(operation string, payload []byte, timestamp int64) []byte {
+	h := sha256.New()
+	h.Write([]byte(operation))
+	h.Write([]byte{0})
+	h.Write(payload)
+	binary.Write(h, binary.BigEndian, timestamp)
+	return h.Sum(nil)
+}
+
+// Approve signs operation on payload with the key of the administrator
+// holding cert, a DER encoded certificate
+func Approve(key crypto.Signer, cert []byte, operation string, payload []byte) (*pb.AdminApproval, error) {
+	approval := &pb.AdminApproval{Certificate: cert, Timestamp: time.Now().UnixNano()}
+	digest := Digest(operation, payload, approval.Timestamp)
+	var opts crypto.SignerOpts = crypto.SHA256
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		opts = crypto.Hash(0)
+	}
+	var err error
+	if approval.Signature, err = key.Sign(rand.Reader, digest, opts); err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+func verify(key crypto.PublicKey, digest, signature []byte) error {
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		var sig struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(signature, &sig); err != nil || len(rest) != 0 {
+			return errors.New("invalid signature")
+		}
+		if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || !ecdsa.Verify(pub, digest, sig.R, sig.S) {
+			return errors.New("invalid signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, digest, signature) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
+
+// DeployPayload returns the payload approved to deploy the chaincode name
+func DeployPayload(name string) []byte {
+	return []byte(name)
+}
+
+// LogLevelPayload returns the payload approved to set the log level of
+// module to level on the peer peerID
+func LogLevelPayload(peerID, module, level string) []byte {
+	return []byte(peerID + "\x00" + module + "\x00" + level)
+}
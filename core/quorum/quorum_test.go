@@ -0,0 +1,196 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quorum
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/hyperledger/fabric/core/testutil"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type admin struct {
+	key  crypto.Signer
+	cert []byte
+}
+
+func newAdmin(t *testing.T, dir string, name string, key crypto.Signer) *admin {
+	der := testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: name}}, key, nil, nil).Raw
+	file := filepath.Join(dir, name+".pem")
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("quorumtest.certificates", append(viper.GetStringSlice("quorumtest.certificates"), file))
+	return &admin{key: key, cert: der}
+}
+
+func newAdmins(t *testing.T, threshold int) (*Policy, []*admin) {
+	dir, err := ioutil.TempDir("", "quorum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	viper.Set("quorumtest.certificates", []string{})
+	viper.Set("quorumtest.threshold", threshold)
+	viper.Set("quorumtest.maxAge", time.Minute)
+	var admins []*admin
+	for _, name := range []string{"alice", "bob"} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		admins = append(admins, newAdmin(t, dir, name, key))
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admins = append(admins, newAdmin(t, dir, "carol", key))
+
+	policy, err := NewPolicy("quorumtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy, admins
+}
+
+func approve(t *testing.T, a *admin, operation string, payload []byte) *pb.AdminApproval {
+	approval, err := Approve(a.key, a.cert, operation, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return approval
+}
+
+func TestPolicyDisabled(t *testing.T) {
+	viper.Set("quorumtest.threshold", 0)
+	policy, err := NewPolicy("quorumtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Enabled() {
+		t.Fatal("Expected the policy to be disabled")
+	}
+	if err = policy.Verify(OperationDeploy, DeployPayload("mycc"), nil, false); err != nil {
+		t.Fatalf("Expected operations to need no approval, got %s", err)
+	}
+}
+
+func TestPolicyThresholdExceedsAdministrators(t *testing.T) {
+	viper.Set("quorumtest.certificates", []string{})
+	viper.Set("quorumtest.threshold", 1)
+	if _, err := NewPolicy("quorumtest"); err == nil {
+		t.Fatal("Expected a threshold above the number of administrators to be refused")
+	}
+}
+
+func TestPolicyVerify(t *testing.T) {
+	policy, admins := newAdmins(t, 2)
+	payload := LogLevelPayload("vp0", "peer", "debug")
+
+	one := []*pb.AdminApproval{approve(t, admins[0], OperationSetLogLevel, payload)}
+	if err := policy.Verify(OperationSetLogLevel, payload, one, true); err == nil {
+		t.Fatal("Expected a single approval to be refused")
+	}
+	twice := append(one, approve(t, admins[0], OperationSetLogLevel, payload))
+	if err := policy.Verify(OperationSetLogLevel, payload, twice, true); err == nil {
+		t.Fatal("Expected two approvals of the same administrator to be refused")
+	}
+	for _, other := range admins[1:] {
+		approvals := append(one, approve(t, other, OperationSetLogLevel, payload))
+		if err := policy.Verify(OperationSetLogLevel, payload, approvals, true); err != nil {
+			t.Fatalf("Expected two approvals to be accepted, got %s", err)
+		}
+	}
+
+	approvals := append(one, approve(t, admins[1], OperationSetLogLevel, payload))
+	if err := policy.Verify(OperationSetLogLevel, LogLevelPayload("vp1", "peer", "debug"), approvals, true); err == nil {
+		t.Fatal("Expected approvals for another peer to be refused")
+	}
+	if err := policy.Verify(OperationDeploy, payload, approvals, true); err == nil {
+		t.Fatal("Expected approvals of another operation to be refused")
+	}
+}
+
+func TestPolicyVerifyIgnoresUnknownAdministrators(t *testing.T) {
+	policy, admins := newAdmins(t, 2)
+	payload := DeployPayload("mycc")
+
+	dir, err := ioutil.TempDir("", "quorum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mallory := newAdmin(t, dir, "mallory", key)
+
+	approvals := []*pb.AdminApproval{
+		approve(t, admins[0], OperationDeploy, payload),
+		approve(t, mallory, OperationDeploy, payload),
+	}
+	if err := policy.Verify(OperationDeploy, payload, approvals, false); err == nil {
+		t.Fatal("Expected the approval of an unknown administrator to be ignored")
+	}
+
+	// A known certificate with the signature of another key
+	forged := approve(t, mallory, OperationDeploy, payload)
+	forged.Certificate = admins[1].cert
+	if err := policy.Verify(OperationDeploy, payload, append(approvals, forged), false); err == nil {
+		t.Fatal("Expected a forged approval to be ignored")
+	}
+}
+
+func TestPolicyVerifyFreshness(t *testing.T) {
+	policy, admins := newAdmins(t, 2)
+	payload := LogLevelPayload("vp0", "peer", "debug")
+
+	var approvals []*pb.AdminApproval
+	for _, a := range admins[:2] {
+		approval := &pb.AdminApproval{Certificate: a.cert, Timestamp: time.Now().Add(-time.Hour).UnixNano()}
+		digest := Digest(OperationSetLogLevel, payload, approval.Timestamp)
+		var err error
+		if approval.Signature, err = a.key.Sign(rand.Reader, digest, crypto.SHA256); err != nil {
+			t.Fatal(err)
+		}
+		approvals = append(approvals, approval)
+	}
+
+	if err := policy.Verify(OperationSetLogLevel, payload, approvals, true); err == nil {
+		t.Fatal("Expected stale approvals to be refused")
+	}
+	if err := policy.Verify(OperationSetLogLevel, payload, approvals, false); err != nil {
+		t.Fatalf("Expected approvals to be accepted regardless of their age, got %s", err)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/comm"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// configEnvPrefix is the prefix of the environment variables overriding
+// the settings of the configuration file
+const configEnvPrefix = "CORE_"
+
+// reloadableSetting is a setting a running peer takes into account when its
+// configuration is reloaded, with the check of its new value. A key ending
+// with a dot stands for all the settings below it.
+type reloadableSetting struct {
+	key   string
+	check func(value interface{}) error
+}
+
+// reloadableSettings are read by the peer each time they are used. The sync
+// rate limit applies to the connections established from then on, the TLS
+// certificate to the clients connecting from then on.
+var reloadableSettings = []reloadableSetting{
+	{"logging.format", checkOneOf(loggingFormatText, loggingFormatJSON)},
+	{"logging.", checkLoggingSpec},
+	{"peer.admin.draintimeout", checkDuration},
+	{"peer.shutdown.timeout", checkDuration},
+	{"peer.validator.consensus.stoptimeout", checkDuration},
+	{"peer.renewal.announceinterval", checkDuration},
+	{"chaincode.deploytimeout", checkInt},
+	{"peer.sync.ratelimit", checkInt},
+	{"peer.sync.burst", checkInt},
+	{"peer.tls.cert.file", checkString},
+	{"peer.tls.key.file", checkString},
+}
+
+// ReloadConfig reads the configuration file again and applies the changed
+// settings listed in reloadableSettings. It reports the changed settings it
+// applied and those taking effect once the peer restarts, and applies none
+// if one of them is invalid. The server certificate is loaded again when TLS
+// is enabled, so that certificate files replaced in place are taken into
+// account. Settings overridden by the environment are left alone.
+func ReloadConfig() (*pb.ConfigReloadReport, error) {
+	file := viper.ConfigFileUsed()
+	config := viper.New()
+	config.SetConfigFile(file)
+	if err := config.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", file, err)
+	}
+	settings := make(map[string]interface{})
+	flattenSettings("", config.AllSettings(), settings)
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	report := &pb.ConfigReloadReport{}
+	for _, key := range keys {
+		value := settings[key]
+		if reflect.DeepEqual(value, viper.Get(key)) || overriddenByEnv(key) {
+			continue
+		}
+		setting := findReloadableSetting(key)
+		if setting == nil {
+			report.RestartRequired = append(report.RestartRequired, key)
+			continue
+		}
+		if err := setting.check(value); err != nil {
+			return nil, fmt.Errorf("Invalid value '%v' of %s: %s", value, key, err)
+		}
+		report.Applied = append(report.Applied, key)
+	}
+
+	tlsEnabled := comm.TLSEnabled()
+	if tlsEnabled {
+		certFile, keyFile := viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file")
+		for _, key := range report.Applied {
+			switch key {
+			case "peer.tls.cert.file":
+				certFile = cast.ToString(settings[key])
+			case "peer.tls.key.file":
+				keyFile = cast.ToString(settings[key])
+			}
+		}
+		if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return nil, fmt.Errorf("Error loading the TLS certificate: %s", err)
+		}
+	}
+
+	loggingChanged := false
+	for _, key := range report.Applied {
+		viper.Set(key, settings[key])
+		loggingChanged = loggingChanged || strings.HasPrefix(key, "logging.")
+	}
+	if loggingChanged {
+		LoggingInit(loggingCommand)
+	}
+	if tlsEnabled {
+		if err := comm.ReloadServerCertificate(); err != nil {
+			return nil, fmt.Errorf("Error loading the TLS certificate: %s", err)
+		}
+	}
+	log.Infof("Reloaded %s, applied %v, restart required for %v", file, report.Applied, report.RestartRequired)
+	return report, nil
+}
+
+// flattenSettings adds the leaves of the nested settings to flat, by their
+// dotted key
+func flattenSettings(prefix string, settings map[string]interface{}, flat map[string]interface{}) {
+	for key, value := range settings {
+		key = prefix + strings.ToLower(key)
+		switch value.(type) {
+		case map[string]interface{}, map[interface{}]interface{}:
+			flattenSettings(key+".", cast.ToStringMap(value), flat)
+		default:
+			flat[key] = value
+		}
+	}
+}
+
+func findReloadableSetting(key string) *reloadableSetting {
+	for i, setting := range reloadableSettings {
+		if key == setting.key || (strings.HasSuffix(setting.key, ".") && strings.HasPrefix(key, setting.key)) {
+			return &reloadableSettings[i]
+		}
+	}
+	return nil
+}
+
+func overriddenByEnv(key string) bool {
+	return os.Getenv(configEnvName(key)) != ""
+}
+
+// configEnvName returns the name of the environment variable overriding the
+// setting key
+func configEnvName(key string) string {
+	return configEnvPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+func checkDuration(value interface{}) error {
+	_, err := cast.ToDurationE(value)
+	return err
+}
+
+func checkInt(value interface{}) error {
+	_, err := cast.ToIntE(value)
+	return err
+}
+
+func checkString(value interface{}) error {
+	_, err := cast.ToStringE(value)
+	return err
+}
+
+// checkLoggingSpec returns an error unless value is a logging specification
+// as parsed by LoggingInit
+func checkLoggingSpec(value interface{}) error {
+	spec, err := cast.ToStringE(value)
+	if err != nil || spec == "" {
+		return err
+	}
+	for _, field := range strings.Split(spec, ":") {
+		split := strings.Split(field, "=")
+		switch len(split) {
+		case 1:
+			_, err = logging.LogLevel(field)
+		case 2:
+			if split[0] == "" {
+				return fmt.Errorf("No module specified in '%s'", field)
+			}
+			_, err = logging.LogLevel(split[1])
+		default:
+			return fmt.Errorf("Invalid logging override '%s'", field)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
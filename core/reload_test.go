@@ -0,0 +1,142 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+)
+
+const reloadTestConfig = `
+logging:
+    node: info
+peer:
+    listenAddress: 0.0.0.0:30303
+    shutdown:
+        timeout: 30s
+    sync:
+        rateLimit: 0
+`
+
+// setupReloadTest loads config from a temporary configuration file, and
+// returns a function replacing the file with updated
+func setupReloadTest(t *testing.T, config string) (func(updated string), func()) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("Error creating directory: %s", err)
+	}
+	file := filepath.Join(dir, "core.yaml")
+	write := func(content string) {
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatalf("Error writing %s: %s", file, err)
+		}
+	}
+	write(config)
+	viper.Reset()
+	viper.SetConfigFile(file)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("Error reading %s: %s", file, err)
+	}
+	LoggingInit("node")
+	return write, func() {
+		viper.Reset()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestReloadConfigAppliesReloadableSettings(t *testing.T) {
+	update, cleanup := setupReloadTest(t, reloadTestConfig)
+	defer cleanup()
+	defer logging.SetLevel(logging.INFO, "")
+
+	update(`
+logging:
+    node: warning:reloadtest=debug
+peer:
+    listenAddress: 0.0.0.0:30304
+    shutdown:
+        timeout: 1m
+    sync:
+        rateLimit: 1000
+`)
+	report, err := ReloadConfig()
+	if err != nil {
+		t.Fatalf("Error reloading: %s", err)
+	}
+	applied := []string{"logging.node", "peer.shutdown.timeout", "peer.sync.ratelimit"}
+	if !reflect.DeepEqual(report.Applied, applied) {
+		t.Errorf("Expected %v to be applied, got %v", applied, report.Applied)
+	}
+	if !reflect.DeepEqual(report.RestartRequired, []string{"peer.listenaddress"}) {
+		t.Errorf("Expected the listen address to require a restart, got %v", report.RestartRequired)
+	}
+	if viper.GetDuration("peer.shutdown.timeout").String() != "1m0s" || viper.GetInt("peer.sync.rateLimit") != 1000 {
+		t.Errorf("Expected the new values to be in force")
+	}
+	if viper.GetString("peer.listenAddress") != "0.0.0.0:30303" {
+		t.Errorf("Expected the listen address to be left alone")
+	}
+	if logging.GetLevel("reloadtest") != logging.DEBUG {
+		t.Errorf("Expected the logging specification to be applied")
+	}
+
+	// Reloading an unchanged file changes nothing
+	if report, err = ReloadConfig(); err != nil || len(report.Applied) != 0 {
+		t.Errorf("Expected nothing to be applied again, got %v, %v", report, err)
+	}
+}
+
+func TestReloadConfigRefusesInvalidSettings(t *testing.T) {
+	update, cleanup := setupReloadTest(t, reloadTestConfig)
+	defer cleanup()
+
+	update(`
+logging:
+    node: info
+peer:
+    listenAddress: 0.0.0.0:30303
+    shutdown:
+        timeout: 1m
+    sync:
+        rateLimit: fast
+`)
+	if _, err := ReloadConfig(); err == nil {
+		t.Fatalf("Expected an invalid rate limit to be refused")
+	}
+	if viper.GetDuration("peer.shutdown.timeout").String() != "30s" {
+		t.Errorf("Expected no setting to be applied, timeout is %s", viper.GetDuration("peer.shutdown.timeout"))
+	}
+}
+
+func TestCheckLoggingSpec(t *testing.T) {
+	for _, spec := range []string{"", "info", "peer,core=debug:warning"} {
+		if err := checkLoggingSpec(spec); err != nil {
+			t.Errorf("Expected '%s' to be valid: %s", spec, err)
+		}
+	}
+	for _, spec := range []string{"chatty", "=debug", "a=b=c", "peer=loud"} {
+		if err := checkLoggingSpec(spec); err == nil {
+			t.Errorf("Expected '%s' to be refused", spec)
+		}
+	}
+}
@@ -24,7 +24,9 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode"
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/txstatus"
 	pb "github.com/hyperledger/fabric/protos"
 	"github.com/spf13/viper"
 )
@@ -40,6 +42,18 @@ type PeerInfo interface {
 	GetPeerEndpoint() (*pb.PeerEndpoint, error)
 }
 
+// HealthInfo may be implemented by a PeerInfo able to assess the health of
+// the validating network
+type HealthInfo interface {
+	GetConsensusHealth() (*pb.ConsensusHealth, error)
+}
+
+// NetworkMapper may be implemented by a PeerInfo able to report its view of
+// the network
+type NetworkMapper interface {
+	GetNetworkMap() (*pb.NetworkMap, error)
+}
+
 // ServerOpenchain defines the Openchain server object, which holds the
 // Ledger data structure and the pointer to the peerServer.
 type ServerOpenchain struct {
@@ -95,7 +109,24 @@ func (s *ServerOpenchain) GetBlockByNumber(ctx context.Context, num *pb.BlockNum
 			return nil, fmt.Errorf("Error retrieving block from blockchain: %s", err)
 		}
 	}
+	return stripCodePackages(block)
+}
+
+// GetBlockByHash returns the block with the given hash.
+func (s *ServerOpenchain) GetBlockByHash(ctx context.Context, hash *pb.BlockHash) (*pb.Block, error) {
+	block, err := s.ledger.GetBlockByHash(hash.Hash)
+	if err != nil {
+		if ledgerErr, ok := err.(*ledger.Error); ok && ledgerErr.Type() == ledger.ErrorTypeBlockNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("Error retrieving block from blockchain: %s", err)
+	}
+	return stripCodePackages(block)
+}
 
+// stripCodePackages removes the code package from the deploy transactions of
+// block
+func stripCodePackages(block *pb.Block) (*pb.Block, error) {
 	// Remove payload from deploy transactions. This is done to make rest api
 	// calls more lightweight as the payload for these types of transactions
 	// can be very large. If the payload is needed, the caller should fetch the
@@ -162,6 +193,30 @@ func (s *ServerOpenchain) GetTransactionByUUID(ctx context.Context, txUUID strin
 	return transaction, nil
 }
 
+// GetTransactionStatus returns the status of the transaction with the given
+// UUID, as tracked from its submission until it was committed or rejected.
+// Transactions no longer tracked are reported committed if on the ledger.
+func (s *ServerOpenchain) GetTransactionStatus(ctx context.Context, txUUID string) (txstatus.Status, error) {
+	if status, ok := txstatus.Default().Get(txUUID); ok {
+		return status, nil
+	}
+	blockNumber, err := s.ledger.GetTransactionBlockNumber(txUUID)
+	if err != nil {
+		switch err {
+		case ledger.ErrResourceNotFound:
+			return txstatus.Status{}, ErrNotFound
+		default:
+			return txstatus.Status{}, fmt.Errorf("Error retrieving transaction from blockchain: %s", err)
+		}
+	}
+	return txstatus.Status{UUID: txUUID, State: txstatus.Committed, Block: &blockNumber}, nil
+}
+
+// GetTransaction returns the transaction with the given UUID.
+func (s *ServerOpenchain) GetTransaction(ctx context.Context, txUUID *pb.TransactionUUID) (*pb.Transaction, error) {
+	return s.GetTransactionByUUID(ctx, txUUID.Uuid)
+}
+
 // GetPeers returns a list of all peer nodes currently connected to the target peer.
 func (s *ServerOpenchain) GetPeers(ctx context.Context, e *google_protobuf.Empty) (*pb.PeersMessage, error) {
 	return s.peerInfo.GetPeers()
@@ -178,3 +233,107 @@ func (s *ServerOpenchain) GetPeerEndpoint(ctx context.Context, e *google_protobu
 	peersMessage := &pb.PeersMessage{Peers: peers}
 	return peersMessage, nil
 }
+
+// GetConsensusHealth returns the health of the validating network as assessed by the target peer.
+func (s *ServerOpenchain) GetConsensusHealth(ctx context.Context, e *google_protobuf.Empty) (*pb.ConsensusHealth, error) {
+	healthInfo, ok := s.peerInfo.(HealthInfo)
+	if !ok {
+		return nil, fmt.Errorf("Target peer does not report the health of the network")
+	}
+	return healthInfo.GetConsensusHealth()
+}
+
+// GetNetworkMap returns the target peer's view of the network.
+func (s *ServerOpenchain) GetNetworkMap(ctx context.Context, e *google_protobuf.Empty) (*pb.NetworkMap, error) {
+	mapper, ok := s.peerInfo.(NetworkMapper)
+	if !ok {
+		return nil, fmt.Errorf("Target peer does not report its view of the network")
+	}
+	return mapper.GetNetworkMap()
+}
+
+// GetChaincodes returns the chaincodes deployed on the blockchain, in the order
+// they were deployed. There is no index of deployed chaincodes, so the whole
+// blockchain is scanned.
+func (s *ServerOpenchain) GetChaincodes(ctx context.Context, e *google_protobuf.Empty) (*pb.ChaincodesMessage, error) {
+	chaincodes := []*pb.ChaincodeInfo{}
+	err := s.scanDeployments(func(info *pb.ChaincodeInfo) bool {
+		chaincodes = append(chaincodes, info)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ChaincodesMessage{Chaincodes: chaincodes}, nil
+}
+
+// GetChaincode returns the deployment of the chaincode with the name in the
+// ChaincodeID.
+func (s *ServerOpenchain) GetChaincode(ctx context.Context, chaincodeID *pb.ChaincodeID) (*pb.ChaincodeInfo, error) {
+	if chaincodeID.Name == "" {
+		return nil, fmt.Errorf("Chaincode name is required")
+	}
+	var found *pb.ChaincodeInfo
+	err := s.scanDeployments(func(info *pb.ChaincodeInfo) bool {
+		if info.ChaincodeID.Name == chaincodeID.Name {
+			found = info
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
+
+// scanDeployments calls visit with each chaincode deployment on the
+// blockchain until visit returns false.
+func (s *ServerOpenchain) scanDeployments(visit func(*pb.ChaincodeInfo) bool) error {
+	size := s.ledger.GetBlockchainSize()
+	for number := uint64(0); number < size; number++ {
+		block, err := s.ledger.GetBlockByNumber(number)
+		if err != nil {
+			return fmt.Errorf("Error retrieving block from blockchain: %s", err)
+		}
+		for _, transaction := range block.GetTransactions() {
+			if transaction.Type != pb.Transaction_CHAINCODE_DEPLOY {
+				continue
+			}
+			deploymentSpec := &pb.ChaincodeDeploymentSpec{}
+			if err := proto.Unmarshal(transaction.Payload, deploymentSpec); err != nil {
+				restLogger.Warningf("Skipping deploy transaction %s, its payload does not unmarshal: %s", transaction.Uuid, err)
+				continue
+			}
+			info := &pb.ChaincodeInfo{
+				ChaincodeID:       &pb.ChaincodeID{},
+				DeployTransaction: transaction.Uuid,
+				BlockNumber:       number,
+				Timestamp:         transaction.Timestamp,
+				CodePackageSize:   uint64(len(deploymentSpec.CodePackage)),
+			}
+			if spec := deploymentSpec.ChaincodeSpec; spec != nil {
+				info.Type = spec.Type
+				if spec.ChaincodeID != nil {
+					info.ChaincodeID.Path = spec.ChaincodeID.Path
+					info.ChaincodeID.Name = spec.ChaincodeID.Name
+				}
+			}
+			// Chaincodes are launched by the UUID of their deploy transaction,
+			// which devops sets to the name of the chaincode
+			if info.ChaincodeID.Name == "" {
+				info.ChaincodeID.Name = transaction.Uuid
+			}
+			if chain := chaincode.GetChain(chaincode.DefaultChain); chain != nil {
+				info.Running = chain.IsRunning(transaction.Uuid)
+			}
+			if !visit(info) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
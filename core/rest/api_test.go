@@ -175,6 +175,46 @@ func TestServerOpenchain_API_GetBlockByNumber(t *testing.T) {
 	}
 }
 
+func TestServerOpenchain_API_GetBlockByHash(t *testing.T) {
+	ledger1 := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger1, t)
+	server, err := NewOpenchainServerWithPeerInfo(new(peerInfo))
+	if err != nil {
+		t.Fatalf("Error creating OpenchainServer: %s", err)
+	}
+
+	expected, err := ledger1.GetBlockByNumber(2)
+	if err != nil {
+		t.Fatalf("Error retrieving block 2: %s", err)
+	}
+	hash, err := expected.GetHash()
+	if err != nil {
+		t.Fatalf("Error hashing block 2: %s", err)
+	}
+	block, err := server.GetBlockByHash(context.Background(), &protos.BlockHash{Hash: hash})
+	if err != nil {
+		t.Fatalf("Error retrieving block by hash: %s", err)
+	}
+	if len(block.Transactions) != 2 || block.Transactions[0].Uuid != expected.Transactions[0].Uuid {
+		t.Errorf("Expected block 2, got %v", block)
+	}
+
+	if _, err = server.GetBlockByHash(context.Background(), &protos.BlockHash{Hash: []byte("unknown")}); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown hash, got %v", err)
+	}
+
+	tx, err := server.GetTransaction(context.Background(), &protos.TransactionUUID{Uuid: expected.Transactions[1].Uuid})
+	if err != nil {
+		t.Fatalf("Error retrieving transaction: %s", err)
+	}
+	if tx.Uuid != expected.Transactions[1].Uuid {
+		t.Errorf("Expected transaction %s, got %v", expected.Transactions[1].Uuid, tx)
+	}
+	if _, err = server.GetTransaction(context.Background(), &protos.TransactionUUID{Uuid: "unknown"}); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown transaction, got %v", err)
+	}
+}
+
 func TestServerOpenchain_API_GetBlockCount(t *testing.T) {
 	// Must initialize the ledger singleton before initializing the
 	// OpenchainServer, as it needs that pointer.
@@ -253,6 +293,61 @@ func TestServerOpenchain_API_GetState(t *testing.T) {
 }
 
 // buildTestLedger1 builds a simple ledger data structure that contains a blockchain with 3 blocks.
+func TestServerOpenchain_API_GetChaincodes(t *testing.T) {
+	// Construct a ledger with a genesis block and a block deploying a chaincode.
+	ledger1 := ledger.InitTestLedger(t)
+	ledger1.BeginTxBatch(0)
+	if err := ledger1.CommitTxBatch(0, []*protos.Transaction{}, nil, []byte("dummy-proof")); err != nil {
+		t.Fatalf("Error in commit: %s", err)
+	}
+	spec := &protos.ChaincodeSpec{Type: protos.ChaincodeSpec_GOLANG,
+		ChaincodeID: &protos.ChaincodeID{Path: "github.com/example/chaincode", Name: "mycc"}}
+	deployment, err := protos.NewChaincodeDeployTransaction(&protos.ChaincodeDeploymentSpec{ChaincodeSpec: spec, CodePackage: []byte("code")}, "mycc")
+	if err != nil {
+		t.Fatalf("Error creating deploy transaction: %s", err)
+	}
+	invocation, err := protos.NewTransaction(protos.ChaincodeID{Name: "mycc"}, generateUUID(t), "invoke", []string{"a"})
+	if err != nil {
+		t.Fatalf("Error creating transaction: %s", err)
+	}
+	ledger1.BeginTxBatch(1)
+	if err := ledger1.CommitTxBatch(1, []*protos.Transaction{deployment, invocation}, nil, []byte("dummy-proof")); err != nil {
+		t.Fatalf("Error in commit: %s", err)
+	}
+
+	server, err := NewOpenchainServerWithPeerInfo(new(peerInfo))
+	if err != nil {
+		t.Fatalf("Error creating OpenchainServer: %s", err)
+	}
+
+	chaincodes, err := server.GetChaincodes(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		t.Fatalf("Error listing chaincodes: %s", err)
+	}
+	if len(chaincodes.Chaincodes) != 1 {
+		t.Fatalf("Expected 1 chaincode, got %d", len(chaincodes.Chaincodes))
+	}
+	info := chaincodes.Chaincodes[0]
+	if info.ChaincodeID.Name != "mycc" || info.ChaincodeID.Path != spec.ChaincodeID.Path || info.Type != protos.ChaincodeSpec_GOLANG {
+		t.Errorf("Unexpected chaincode: %v", info)
+	}
+	if info.DeployTransaction != "mycc" || info.BlockNumber != 1 || info.CodePackageSize != 4 || info.Running {
+		t.Errorf("Unexpected deployment: %v", info)
+	}
+
+	described, err := server.GetChaincode(context.Background(), &protos.ChaincodeID{Name: "mycc"})
+	if err != nil {
+		t.Fatalf("Error describing chaincode: %s", err)
+	}
+	if described.DeployTransaction != "mycc" {
+		t.Errorf("Unexpected chaincode: %v", described)
+	}
+
+	if _, err := server.GetChaincode(context.Background(), &protos.ChaincodeID{Name: "unknown"}); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown chaincode, got %v", err)
+	}
+}
+
 func buildTestLedger1(ledger1 *ledger.Ledger, t *testing.T) {
 	// -----------------------------<Block #0>---------------------
 	// Add the 0th (genesis block)
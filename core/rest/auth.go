@@ -0,0 +1,416 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gocraft/web"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/audit"
+	"github.com/hyperledger/fabric/core/comm"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// tokenVerifier authenticates the clients of the REST API by their bearer
+// tokens, JSON Web Tokens signed by a trusted issuer such as an OAuth2
+// authorization server, and maps them to enrollment IDs.
+type tokenVerifier struct {
+	issuer   string
+	audience string
+	// The claim holding the identity of the client
+	claim  string
+	leeway time.Duration
+	// The public key verifying RS* and ES* tokens
+	key crypto.PublicKey
+	// The shared secret verifying HS256 tokens
+	secret []byte
+	// Enrollment IDs of the identities named differently by the issuer
+	identities map[string]string
+}
+
+// restTokenVerifier verifies the bearer tokens of the REST requests, nil if
+// they are not authenticated by token
+var restTokenVerifier *tokenVerifier
+
+// newTokenVerifier returns the verifier configured by rest.auth, nil if token
+// authentication is disabled
+func newTokenVerifier() (*tokenVerifier, error) {
+	if !viper.GetBool("rest.auth.enabled") {
+		return nil, nil
+	}
+
+	v := &tokenVerifier{
+		issuer:     viper.GetString("rest.auth.issuer"),
+		audience:   viper.GetString("rest.auth.audience"),
+		claim:      viper.GetString("rest.auth.claim"),
+		leeway:     viper.GetDuration("rest.auth.leeway"),
+		identities: viper.GetStringMapString("rest.auth.identities"),
+	}
+	if v.issuer == "" {
+		return nil, errors.New("rest.auth.issuer must be set")
+	}
+	if v.claim == "" {
+		v.claim = "sub"
+	}
+
+	if file := viper.GetString("rest.auth.key.file"); file != "" {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Failed reading the token issuer key: %s", err)
+		}
+		if v.key, err = parsePublicKey(raw); err != nil {
+			return nil, fmt.Errorf("Invalid token issuer key %s: %s", file, err)
+		}
+	} else if secret := viper.GetString("rest.auth.secret"); secret != "" {
+		v.secret = []byte(secret)
+	} else {
+		return nil, errors.New("rest.auth.key.file or rest.auth.secret must be set")
+	}
+
+	return v, nil
+}
+
+// certAuthenticator authenticates the clients of the REST API by the TLS
+// certificates they present, and maps them to enrollment IDs
+type certAuthenticator struct {
+	// The root certificates of the client certificates
+	roots *x509.CertPool
+	// The field of the certificate naming the client, commonName or email
+	field string
+	// Enrollment IDs of the clients named differently by their certificates,
+	// or by the SHA-256 fingerprint of their certificate
+	identities map[string]string
+}
+
+// restCertAuthenticator authenticates the REST requests by client
+// certificate, nil if they are not
+var restCertAuthenticator *certAuthenticator
+
+// newCertAuthenticator returns the authenticator configured by
+// rest.tls.clientAuth, nil if client certificate authentication is disabled
+func newCertAuthenticator(tlsEnabled bool) (*certAuthenticator, error) {
+	if !viper.GetBool("rest.tls.clientAuth.enabled") {
+		return nil, nil
+	}
+	if !tlsEnabled {
+		return nil, errors.New("rest.tls.clientAuth.enabled requires TLS")
+	}
+
+	a := &certAuthenticator{
+		field:      viper.GetString("rest.tls.clientAuth.identity"),
+		identities: viper.GetStringMapString("rest.tls.clientAuth.identities"),
+	}
+	switch a.field {
+	case "":
+		a.field = "commonName"
+	case "commonName", "email":
+	default:
+		return nil, fmt.Errorf("Unknown rest.tls.clientAuth.identity %s, must be commonName or email", a.field)
+	}
+
+	file := viper.GetString("rest.tls.clientAuth.rootcert.file")
+	if file == "" {
+		return nil, errors.New("rest.tls.clientAuth.rootcert.file must be set")
+	}
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading the root certificate of the REST clients: %s", err)
+	}
+	a.roots = x509.NewCertPool()
+	if !a.roots.AppendCertsFromPEM(raw) {
+		return nil, fmt.Errorf("No certificate found in %s", file)
+	}
+	return a, nil
+}
+
+// tlsConfig makes the REST server verify the certificates the clients
+// present. Presenting one stays optional at the TLS level, so that clients
+// with bearer tokens are served and the others refused with an error.
+func (a *certAuthenticator) tlsConfig() *tls.Config {
+	return &tls.Config{ClientCAs: a.roots, ClientAuth: tls.VerifyClientCertIfGiven}
+}
+
+// identity returns the enrollment ID of the client presenting cert, which
+// the TLS handshake verified
+func (a *certAuthenticator) identity(cert *x509.Certificate) (string, error) {
+	fingerprint := sha256.Sum256(cert.Raw)
+	if enrollmentID, ok := a.identities[hex.EncodeToString(fingerprint[:])]; ok {
+		return enrollmentID, nil
+	}
+
+	var identity string
+	switch a.field {
+	case "email":
+		if len(cert.EmailAddresses) > 0 {
+			identity = cert.EmailAddresses[0]
+		}
+	default:
+		identity = cert.Subject.CommonName
+	}
+	if identity == "" {
+		return "", fmt.Errorf("certificate %s without %s", comm.SubjectIdentity(cert), a.field)
+	}
+	// The keys of the configuration maps are lower case
+	if enrollmentID, ok := a.identities[strings.ToLower(identity)]; ok {
+		return enrollmentID, nil
+	}
+	return identity, nil
+}
+
+// parsePublicKey returns the public key of a PEM encoded certificate or
+// public key
+func parsePublicKey(raw []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// hashOf returns the hash function of the JWS algorithm alg, e.g. SHA-256 for
+// RS256
+func hashOf(alg string) func() hash.Hash {
+	switch alg[2:] {
+	case "256":
+		return sha256.New
+	case "384":
+		return sha512.New384
+	case "512":
+		return sha512.New
+	}
+	return nil
+}
+
+// verify returns the enrollment ID of the client presenting token, or an
+// error if token is not a valid JWT of the trusted issuer
+func (v *tokenVerifier) verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeTokenPart(parts[0], &header); err != nil {
+		return "", err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed token signature: %s", err)
+	}
+	if err = v.verifySignature(header.Alg, parts[0]+"."+parts[1], signature); err != nil {
+		return "", err
+	}
+
+	var claims map[string]interface{}
+	if err = decodeTokenPart(parts[1], &claims); err != nil {
+		return "", err
+	}
+	return v.identity(claims, time.Now())
+}
+
+func decodeTokenPart(part string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("malformed token: %s", err)
+	}
+	if err = json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("malformed token: %s", err)
+	}
+	return nil
+}
+
+func (v *tokenVerifier) verifySignature(alg, signed string, signature []byte) error {
+	var h func() hash.Hash
+	if len(alg) == 5 {
+		h = hashOf(alg)
+	}
+	if h == nil {
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+	digest := h()
+	digest.Write([]byte(signed))
+
+	switch alg[:2] {
+	case "HS":
+		if v.secret == nil {
+			return fmt.Errorf("unexpected token algorithm %s", alg)
+		}
+		mac := hmac.New(h, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errors.New("invalid token signature")
+		}
+	case "RS":
+		key, ok := v.key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("unexpected token algorithm %s", alg)
+		}
+		hashes := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+		if err := rsa.VerifyPKCS1v15(key, hashes[alg[2:]], digest.Sum(nil), signature); err != nil {
+			return errors.New("invalid token signature")
+		}
+	case "ES":
+		key, ok := v.key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("unexpected token algorithm %s", alg)
+		}
+		// The signature is the concatenation of r and s
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest.Sum(nil), r, s) {
+			return errors.New("invalid token signature")
+		}
+	default:
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+	return nil
+}
+
+// identity checks the claims of a token at time now and returns the
+// enrollment ID of its subject
+func (v *tokenVerifier) identity(claims map[string]interface{}, now time.Time) (string, error) {
+	if iss, _ := claims["iss"].(string); iss != v.issuer {
+		return "", fmt.Errorf("untrusted token issuer %q", iss)
+	}
+
+	if v.audience != "" {
+		found := false
+		switch aud := claims["aud"].(type) {
+		case string:
+			found = aud == v.audience
+		case []interface{}:
+			for _, a := range aud {
+				found = found || a == v.audience
+			}
+		}
+		if !found {
+			return "", errors.New("token not issued for this audience")
+		}
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return "", errors.New("token without expiration time")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.leeway)) {
+		return "", errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0).Add(-v.leeway)) {
+		return "", errors.New("token not valid yet")
+	}
+
+	identity, _ := claims[v.claim].(string)
+	if identity == "" {
+		return "", fmt.Errorf("token without %s claim", v.claim)
+	}
+	// The keys of the configuration maps are lower case
+	if enrollmentID, ok := v.identities[strings.ToLower(identity)]; ok {
+		return enrollmentID, nil
+	}
+	return identity, nil
+}
+
+// authenticated wraps the handler of route, which then only serves the
+// requests of clients presenting a valid certificate or bearing a valid
+// token. If set, the owner path parameter of route must be the enrollment ID
+// of the client.
+func authenticated(route restRoute) func(*ServerOpenchainREST, web.ResponseWriter, *web.Request) {
+	return func(s *ServerOpenchainREST, rw web.ResponseWriter, req *web.Request) {
+		var identity string
+		var err error
+		authorization := req.Header.Get("Authorization")
+		switch {
+		case restCertAuthenticator != nil && req.TLS != nil && len(req.TLS.PeerCertificates) > 0:
+			if identity, err = restCertAuthenticator.identity(req.TLS.PeerCertificates[0]); err != nil {
+				denyRequest(rw, req, http.StatusUnauthorized, "", fmt.Sprintf("Invalid client certificate: %s.", err))
+				return
+			}
+		case restTokenVerifier == nil:
+			denyRequest(rw, req, http.StatusUnauthorized, "", "Missing client certificate.")
+			return
+		case !strings.HasPrefix(authorization, "Bearer "):
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="fabric"`)
+			denyRequest(rw, req, http.StatusUnauthorized, "", "Missing bearer token.")
+			return
+		default:
+			if identity, err = restTokenVerifier.verify(strings.TrimSpace(authorization[len("Bearer "):])); err != nil {
+				rw.Header().Set("WWW-Authenticate", `Bearer realm="fabric", error="invalid_token"`)
+				denyRequest(rw, req, http.StatusUnauthorized, "", fmt.Sprintf("Invalid bearer token: %s.", err))
+				return
+			}
+		}
+
+		if route.owner != "" && req.PathParams[route.owner] != identity {
+			denyRequest(rw, req, http.StatusForbidden, identity, fmt.Sprintf("User %s may not act as %s.", identity, req.PathParams[route.owner]))
+			return
+		}
+
+		s.identity = identity
+		route.handler(s, rw, req)
+	}
+}
+
+func denyRequest(rw web.ResponseWriter, req *web.Request, status int, identity, message string) {
+	audit.Record(pb.SecurityEvent_ACCESS_DENIED, identity, req.RemoteAddr, message)
+	restLogger.Warningf("REST request %s %s denied: %s", req.Method, req.URL.Path, message)
+
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(restResult{Error: message})
+}
+
+// secureContextUser returns the user a chaincode request for user acts as,
+// the authenticated client if any. It returns false if the request names a
+// user other than the authenticated client.
+func (s *ServerOpenchainREST) secureContextUser(user string) (string, bool) {
+	if s.identity == "" {
+		return user, true
+	}
+	return s.identity, user == "" || user == s.identity
+}
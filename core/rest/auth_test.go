@@ -0,0 +1,175 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/testutil"
+)
+
+// signToken returns the JWT of claims signed with alg by sign
+func signToken(t *testing.T, alg string, claims map[string]interface{}, sign func(signed []byte) []byte) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("Error encoding the claims: %s", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func hmacToken(t *testing.T, secret string, claims map[string]interface{}) string {
+	return signToken(t, "HS256", claims, func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(signed)
+		return mac.Sum(nil)
+	})
+}
+
+func TestTokenVerifier(t *testing.T) {
+	v := &tokenVerifier{issuer: "https://issuer", audience: "fabric", claim: "sub", secret: []byte("secret"),
+		identities: map[string]string{"jane@example.com": "jim"}}
+	exp := float64(time.Now().Add(time.Hour).Unix())
+
+	identity, err := v.verify(hmacToken(t, "secret", map[string]interface{}{"iss": "https://issuer", "aud": []string{"other", "fabric"}, "sub": "Jane@example.com", "exp": exp}))
+	if err != nil || identity != "jim" {
+		t.Fatalf("Expected the token of jim to be valid, got %s, %v", identity, err)
+	}
+
+	for reason, claims := range map[string]map[string]interface{}{
+		"issuer":     {"iss": "https://other", "aud": "fabric", "sub": "jim", "exp": exp},
+		"audience":   {"iss": "https://issuer", "aud": "other", "sub": "jim", "exp": exp},
+		"expired":    {"iss": "https://issuer", "aud": "fabric", "sub": "jim", "exp": float64(time.Now().Add(-time.Hour).Unix())},
+		"expiration": {"iss": "https://issuer", "aud": "fabric", "sub": "jim"},
+		"not before": {"iss": "https://issuer", "aud": "fabric", "sub": "jim", "exp": exp, "nbf": exp},
+		"subject":    {"iss": "https://issuer", "aud": "fabric", "exp": exp},
+	} {
+		if _, err = v.verify(hmacToken(t, "secret", claims)); err == nil {
+			t.Errorf("Expected an invalid token because of its %s", reason)
+		}
+	}
+
+	claims := map[string]interface{}{"iss": "https://issuer", "aud": "fabric", "sub": "jim", "exp": exp}
+	if _, err = v.verify(hmacToken(t, "other secret", claims)); err == nil {
+		t.Error("Expected an invalid token signature")
+	}
+	if _, err = v.verify(signToken(t, "none", claims, func([]byte) []byte { return nil })); err == nil {
+		t.Error("Expected unsigned tokens to be refused")
+	}
+}
+
+func TestTokenVerifierECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating a key: %s", err)
+	}
+	v := &tokenVerifier{issuer: "https://issuer", claim: "email", key: &key.PublicKey}
+
+	sign := func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatalf("Error signing the token: %s", err)
+		}
+		signature := make([]byte, 64)
+		rBytes, sBytes := r.Bytes(), s.Bytes()
+		copy(signature[32-len(rBytes):], rBytes)
+		copy(signature[64-len(sBytes):], sBytes)
+		return signature
+	}
+	token := signToken(t, "ES256", map[string]interface{}{"iss": "https://issuer", "email": "jim", "exp": float64(time.Now().Add(time.Minute).Unix())}, sign)
+
+	if identity, err := v.verify(token); err != nil || identity != "jim" {
+		t.Fatalf("Expected the token of jim to be valid, got %s, %v", identity, err)
+	}
+
+	// Tampered claims
+	parts := strings.Split(token, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"https://issuer","email":"admin","exp":4102444800}`))
+	if _, err = v.verify(strings.Join(parts, ".")); err == nil {
+		t.Error("Expected the tampered token to be invalid")
+	}
+
+	// HMAC tokens are refused when the issuer signs with a key
+	if _, err = v.verify(hmacToken(t, "", map[string]interface{}{"iss": "https://issuer", "email": "jim"})); err == nil {
+		t.Error("Expected the HS256 token to be refused")
+	}
+}
+
+// issueCert returns a certificate for cn and email signed by parent, or
+// self-signed if parent is nil
+func issueCert(t *testing.T, parent *tls.Certificate, cn, email string) tls.Certificate {
+	key := testutil.NewKey(t)
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: cn},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if email != "" {
+		template.EmailAddresses = []string{email}
+	}
+	var cert *x509.Certificate
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		cert = testutil.NewCertificate(t, template, key, nil, nil)
+	} else {
+		cert = testutil.NewCertificate(t, template, key, parent.Leaf, parent.PrivateKey.(crypto.Signer))
+	}
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+}
+
+func TestCertAuthenticator(t *testing.T) {
+	ca := issueCert(t, nil, "ca", "")
+	pinned := issueCert(t, &ca, "integration", "")
+	fingerprint := sha256.Sum256(pinned.Leaf.Raw)
+	a := &certAuthenticator{field: "commonName", identities: map[string]string{
+		"jane.example.com":                 "jim",
+		hex.EncodeToString(fingerprint[:]): "batch",
+	}}
+
+	for cert, expected := range map[*x509.Certificate]string{
+		issueCert(t, &ca, "myuser", "").Leaf:           "myuser",
+		issueCert(t, &ca, "Jane.example.com", "").Leaf: "jim",
+		pinned.Leaf: "batch",
+	} {
+		if identity, err := a.identity(cert); err != nil || identity != expected {
+			t.Errorf("Expected the certificate of %s to be %s, got %s, %v", cert.Subject.CommonName, expected, identity, err)
+		}
+	}
+	if _, err := a.identity(issueCert(t, &ca, "", "").Leaf); err == nil {
+		t.Error("Expected a certificate without common name to be refused")
+	}
+
+	a.field = "email"
+	if identity, err := a.identity(issueCert(t, &ca, "server", "jim@example.com").Leaf); err != nil || identity != "jim@example.com" {
+		t.Errorf("Expected the identity of the email address, got %s, %v", identity, err)
+	}
+}
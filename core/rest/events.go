@@ -0,0 +1,93 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gocraft/web"
+	"github.com/hyperledger/fabric/events/producer"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Number of chaincode events buffered for a Server-Sent Events client before
+// they are dropped, and interval of the comments keeping its connection alive
+const (
+	eventsBufferSize = 100
+	eventsKeepAlive  = 15 * time.Second
+)
+
+// eventFieldCleaner removes the line breaks from the fields of Server-Sent
+// Events, as they would end the fields
+var eventFieldCleaner = strings.NewReplacer("\r", "", "\n", "")
+
+// GetChaincodeEvents streams the events of a chaincode as Server-Sent Events,
+// all of them or only those named by the event query parameter. Each event
+// has the UUID of its transaction as id, its name as type and the JSON
+// encoding of the chaincode event as data. The stream lasts until the client
+// disconnects. It requires the event hub, which only runs on validating peers.
+func (s *ServerOpenchainREST) GetChaincodeEvents(rw web.ResponseWriter, req *web.Request) {
+	chaincodeID := req.PathParams["id"]
+	eventName := req.URL.Query().Get("event")
+
+	interest := &pb.Interest{EventType: pb.EventType_CHAINCODE,
+		RegInfo: &pb.Interest_ChaincodeRegInfo{ChaincodeRegInfo: &pb.ChaincodeReg{ChaincodeID: chaincodeID, EventName: eventName}}}
+	events, cancel, err := producer.Subscribe([]*pb.Interest{interest}, eventsBufferSize)
+	if err != nil {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(rw).Encode(restResult{Error: fmt.Sprintf("Chaincode events are not available on this peer: %s.", err)})
+		restLogger.Errorf("Error subscribing to the events of chaincode %s: %s", chaincodeID, err)
+		return
+	}
+	defer cancel()
+
+	restLogger.Debugf("Streaming the events of chaincode %s", chaincodeID)
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	rw.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	closed := rw.CloseNotify()
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			event := e.GetChaincodeEvent()
+			data, err := json.Marshal(event)
+			if err != nil {
+				restLogger.Errorf("Error encoding the chaincode event %v: %s", event, err)
+				continue
+			}
+			fmt.Fprintf(rw, "id: %s\nevent: %s\ndata: %s\n\n", eventFieldCleaner.Replace(event.TxID), eventFieldCleaner.Replace(event.EventName), data)
+		case <-keepAlive.C:
+			fmt.Fprint(rw, ": keep-alive\n\n")
+		case <-closed:
+			restLogger.Debugf("Client of the events of chaincode %s disconnected", chaincodeID)
+			return
+		}
+		rw.Flush()
+	}
+}
@@ -0,0 +1,303 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"google/protobuf"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gocraft/web"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// The REST gateway serves every RPC of the gRPC services below at
+// POST /v1/{service}/{method}, taking the JSON encoding of the request
+// message as body and answering with the JSON encoding of the response
+// message. RPCs taking no argument are served at GET as well. The routes are
+// derived from the service interfaces, so an RPC added to a service is
+// served without a REST handler of its own, and the gateway can't drift from
+// gRPC.
+//
+// The gateway is the REST API of these services. The hand-written routes
+// predating it which serve the same RPCs are deprecated: they name the RPC
+// superseding them in their gateway field, call it through callGateway and
+// only keep their former encoding for the clients yet to move.
+
+// gatewayPrefix is the path prefix of the routes of the REST gateway
+const gatewayPrefix = "/v1/"
+
+// gatewayService is a gRPC service served by the REST gateway
+type gatewayService struct {
+	name string
+	// The server interface of the service, e.g. pb.DevopsServer
+	iface reflect.Type
+	// The implementation of the service serving a request, nil if none
+	server func(*ServerOpenchainREST) interface{}
+}
+
+var gatewayServices = []gatewayService{
+	{"Openchain", reflect.TypeOf((*pb.OpenchainServer)(nil)).Elem(), func(s *ServerOpenchainREST) interface{} {
+		if s.server == nil {
+			return nil
+		}
+		return s.server
+	}},
+	{"Devops", reflect.TypeOf((*pb.DevopsServer)(nil)).Elem(), func(s *ServerOpenchainREST) interface{} {
+		return s.devops
+	}},
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// gatewayError is the body of the failed responses of the REST gateway,
+// carrying the gRPC status code the RPC failed with
+type gatewayError struct {
+	Error string `json:"error"`
+	Code  uint32 `json:"code"`
+}
+
+func init() {
+	for _, service := range gatewayServices {
+		restRoutes = append(restRoutes, gatewayRoutes(service)...)
+	}
+}
+
+// gatewayRoutes returns the routes of the unary RPCs of service
+func gatewayRoutes(service gatewayService) []restRoute {
+	var routes []restRoute
+	for i := 0; i < service.iface.NumMethod(); i++ {
+		method := service.iface.Method(i)
+		if method.Type.NumIn() != 2 || method.Type.In(0) != contextType ||
+			method.Type.NumOut() != 2 || method.Type.Out(1) != errorType {
+			// Streaming RPCs are not served
+			continue
+		}
+		in, out := method.Type.In(1).Elem(), method.Type.Out(0).Elem()
+		route := restRoute{method: "POST", path: gatewayPrefix + service.name + "/" + method.Name,
+			handler:     gatewayHandler(service, method.Name, in),
+			operationID: service.name + "_" + method.Name, tag: "Gateway",
+			summary:  fmt.Sprintf("The %s RPC of the %s gRPC service", method.Name, service.name),
+			response: reflect.Zero(out).Interface(), failure: gatewayError{}}
+		if in.NumField() == 0 {
+			routes = append(routes, route)
+			route.method = "GET"
+		} else {
+			route.request = reflect.Zero(in).Interface()
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// gatewayHandler returns the handler calling the RPC named method of service
+// with the request message of type in decoded from the body
+func gatewayHandler(service gatewayService, method string, in reflect.Type) func(*ServerOpenchainREST, web.ResponseWriter, *web.Request) {
+	return func(s *ServerOpenchainREST, rw web.ResponseWriter, req *web.Request) {
+		if service.server(s) == nil {
+			writeGatewayError(rw, grpc.Errorf(codes.Unavailable, "The %s service is not available", service.name))
+			return
+		}
+
+		request := reflect.New(in)
+		if req.Method == "POST" {
+			if err := jsonpb.Unmarshal(req.Body, request.Interface().(proto.Message)); err != nil && err != io.EOF {
+				writeGatewayError(rw, grpc.Errorf(codes.InvalidArgument, "Invalid %s: %s", in.Name(), err))
+				return
+			}
+		}
+		if err := s.authorizeGatewayRequest(request.Interface()); err != nil {
+			denyRequest(rw, req, http.StatusForbidden, s.identity, err.Error())
+			return
+		}
+
+		response, err := s.callGateway(clientContext(req), service.name+"."+method, request.Interface().(proto.Message))
+		if err != nil {
+			if resp, ok := response.(*pb.Response); ok && resp != nil && resp.Status == pb.Response_SATURATED {
+				err = grpc.Errorf(codes.Unavailable, "%s", err)
+			}
+			writeGatewayError(rw, err)
+			restLogger.Infof("REST gateway %s.%s failed: %s", service.name, method, err)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+		if err := (&jsonpb.Marshaler{}).Marshal(rw, response); err != nil {
+			restLogger.Errorf("Error encoding the response of %s.%s: %s", service.name, method, err)
+		}
+	}
+}
+
+// callGateway calls the RPC named rpc, as Service.Method, with request. It
+// serves the requests of the gateway routes and of the deprecated routes
+// they supersede, so both reach the same implementation. The response is
+// nil if the RPC returned none.
+func (s *ServerOpenchainREST) callGateway(ctx context.Context, rpc string, request proto.Message) (proto.Message, error) {
+	name := strings.SplitN(rpc, ".", 2)
+	for _, service := range gatewayServices {
+		if service.name != name[0] || len(name) != 2 {
+			continue
+		}
+		if _, ok := service.iface.MethodByName(name[1]); !ok {
+			break
+		}
+		server := service.server(s)
+		if server == nil {
+			return nil, grpc.Errorf(codes.Unavailable, "The %s service is not available", service.name)
+		}
+
+		results := reflect.ValueOf(server).MethodByName(name[1]).Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(request)})
+		err, _ := results[1].Interface().(error)
+		if results[0].IsNil() {
+			return nil, err
+		}
+		return results[0].Interface().(proto.Message), err
+	}
+	return nil, grpc.Errorf(codes.Unimplemented, "Unknown RPC %s", rpc)
+}
+
+// gatewayPath returns the path of the gateway route of rpc, as Service.Method
+func gatewayPath(rpc string) string {
+	return gatewayPrefix + strings.Replace(rpc, ".", "/", 1)
+}
+
+// deprecatedByGateway returns the handler of route, a deprecated route
+// superseded by the gateway route of route.gateway, warning its clients to
+// move to the gateway route
+func deprecatedByGateway(route restRoute, handler func(*ServerOpenchainREST, web.ResponseWriter, *web.Request)) func(*ServerOpenchainREST, web.ResponseWriter, *web.Request) {
+	warning := fmt.Sprintf("299 - %s endpoint has been deprecated. Use %s instead.", route.path, gatewayPath(route.gateway))
+	return func(s *ServerOpenchainREST, rw web.ResponseWriter, req *web.Request) {
+		rw.Header().Add("Warning", warning)
+		handler(s, rw, req)
+	}
+}
+
+// authorizeGatewayRequest refuses requests of a client authenticated by its
+// token which act as another user. Requests of a type not known to name
+// their user, or to name none, are refused, so an RPC added to a service is
+// only served to such clients once its request is checked here.
+func (s *ServerOpenchainREST) authorizeGatewayRequest(request interface{}) error {
+	if s.identity == "" {
+		return nil
+	}
+	checkSpec := func(spec *pb.ChaincodeSpec) error {
+		if spec == nil {
+			return nil
+		}
+		user, authorized := s.secureContextUser(spec.SecureContext)
+		if !authorized {
+			return fmt.Errorf("User %s may not act as %s.", s.identity, spec.SecureContext)
+		}
+		spec.SecureContext = user
+		return nil
+	}
+	checkSecret := func(secret *pb.Secret) error {
+		if secret != nil && secret.EnrollId != s.identity {
+			return fmt.Errorf("User %s may not act as %s.", s.identity, secret.EnrollId)
+		}
+		return nil
+	}
+
+	switch request := request.(type) {
+	case *pb.ChaincodeSpec:
+		return checkSpec(request)
+	case *pb.ChaincodeInvocationSpec:
+		return checkSpec(request.ChaincodeSpec)
+	case *pb.ChaincodeInvocationBatch:
+		for _, invocation := range request.Invocations {
+			if err := checkSpec(invocation.ChaincodeSpec); err != nil {
+				return err
+			}
+		}
+	case *pb.ExecuteWithBinding:
+		if request.ChaincodeInvocationSpec != nil {
+			return checkSpec(request.ChaincodeInvocationSpec.ChaincodeSpec)
+		}
+	case *pb.Secret:
+		return checkSecret(request)
+	case *pb.SigmaInput:
+		return checkSecret(request.Secret)
+	case *google_protobuf.Empty, *pb.BlockNumber, *pb.BlockHash, *pb.TransactionUUID, *pb.ChaincodeID:
+		// Reading the chain acts as no user
+	case *pb.UnsignedTransactionRequest, *pb.Transaction:
+		// The transaction is signed by its submitter, not by the user of the
+		// peer, and BuildTransaction ignores the secure context
+	default:
+		return fmt.Errorf("User %s may not send a %T.", s.identity, request)
+	}
+	return nil
+}
+
+// writeGatewayError writes the HTTP status of the gRPC status code of err,
+// with a Retry-After header if the request may be retried later
+func writeGatewayError(rw web.ResponseWriter, err error) {
+	code := grpc.Code(err)
+	if err == ErrNotFound {
+		code = codes.NotFound
+	}
+	switch code {
+	case codes.ResourceExhausted:
+		rw.Header().Set("Retry-After", rateLimitedRetryAfter)
+	case codes.Unavailable:
+		rw.Header().Set("Retry-After", saturatedRetryAfter)
+	}
+	rw.WriteHeader(httpStatusFromCode(code))
+	json.NewEncoder(rw).Encode(gatewayError{Error: grpc.ErrorDesc(err), Code: uint32(code)})
+}
+
+// httpStatusFromCode maps gRPC status codes to HTTP statuses
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return http.StatusRequestTimeout
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return rateLimitedStatus
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,190 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rest
+
+import (
+	"encoding/json"
+	"google/protobuf"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/protos"
+)
+
+func TestServerOpenchainREST_Gateway(t *testing.T) {
+	// Construct a ledger with 3 blocks.
+	ledger := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger, t)
+
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	// An RPC without argument is served at GET
+	body := performHTTPGet(t, httpServer.URL+"/v1/Openchain/GetBlockchainInfo")
+	var info protos.BlockchainInfo
+	if err := jsonpb.UnmarshalString(string(body), &info); err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if info.Height != 3 {
+		t.Errorf("Expected a blockchain height of 3 but got %d", info.Height)
+	}
+
+	// The deprecated route superseded by the RPC is served through it
+	response, err := http.Get(httpServer.URL + "/chain")
+	if err != nil {
+		t.Fatalf("Error attempt to GET: %v", err)
+	}
+	response.Body.Close()
+	if warning := response.Header.Get("Warning"); !strings.Contains(warning, "/v1/Openchain/GetBlockchainInfo") {
+		t.Errorf("Expected a deprecation warning naming the gateway route but got %q", warning)
+	}
+
+	// Errors map to the HTTP status of their gRPC code
+	httpResponse, body := performHTTPPost(t, httpServer.URL+"/v1/Openchain/GetBlockByNumber", []byte(`{"number":99}`))
+	if httpResponse.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusNotFound, httpResponse.StatusCode)
+	}
+	var failure gatewayError
+	if err := json.Unmarshal(body, &failure); err != nil || failure.Code != uint32(codes.NotFound) {
+		t.Errorf("Expected a NotFound error but got %s", body)
+	}
+	httpResponse, _ = performHTTPPost(t, httpServer.URL+"/v1/Openchain/GetBlockByNumber", []byte(`{"number":`))
+	if httpResponse.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusBadRequest, httpResponse.StatusCode)
+	}
+
+	// Devops RPCs
+	httpResponse, body = performHTTPPost(t, httpServer.URL+"/v1/Devops/Invoke", []byte(`{"chaincodeSpec":{"type":"GOLANG","chaincodeID":{"name":"dummy"},"ctorMsg":{"function":"change_owner"}}}`))
+	if httpResponse.StatusCode != http.StatusOK {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusOK, httpResponse.StatusCode)
+	}
+	var resp protos.Response
+	if err := jsonpb.UnmarshalString(string(body), &resp); err != nil || string(resp.Msg) != "change_owner_invoke_result" {
+		t.Errorf("Expected the invoke result but got %s", body)
+	}
+	httpResponse, _ = performHTTPPost(t, httpServer.URL+"/v1/Devops/Invoke", []byte(`{"chaincodeSpec":{"type":"GOLANG","chaincodeID":{"name":"dummy"},"ctorMsg":{"function":"fail"}}}`))
+	if httpResponse.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusInternalServerError, httpResponse.StatusCode)
+	}
+
+	// A client authenticated by its token may only act as itself
+	restTokenVerifier = &tokenVerifier{issuer: "https://issuer", claim: "sub", secret: []byte("secret")}
+	defer func() { restTokenVerifier = nil }()
+	authServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer authServer.Close()
+
+	token := hmacToken(t, "secret", map[string]interface{}{"iss": "https://issuer", "sub": "myuser", "exp": float64(time.Now().Add(time.Hour).Unix())})
+	for body, expected := range map[string]int{
+		`{"chaincodeSpec":{"chaincodeID":{"name":"dummy"},"ctorMsg":{"function":"change_owner"},"secureContext":"myuser"}}`: http.StatusOK,
+		`{"chaincodeSpec":{"chaincodeID":{"name":"dummy"},"ctorMsg":{"function":"change_owner"},"secureContext":"other"}}`:  http.StatusForbidden,
+	} {
+		req, _ := http.NewRequest("POST", authServer.URL+"/v1/Devops/Invoke", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		response, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error attempt to POST: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != expected {
+			t.Errorf("Expected an HTTP status code %#v for %s but got %#v", expected, body, response.StatusCode)
+		}
+	}
+}
+
+func TestGatewaySupersedesDeprecatedRoutes(t *testing.T) {
+	served := make(map[string]bool)
+	for _, route := range restRoutes {
+		served[route.path] = true
+	}
+
+	superseded := 0
+	for _, route := range restRoutes {
+		if route.gateway == "" {
+			continue
+		}
+		superseded++
+		if !route.deprecated {
+			t.Errorf("Route %s %s superseded by %s is not deprecated", route.method, route.path, route.gateway)
+		}
+		if !served[gatewayPath(route.gateway)] {
+			t.Errorf("Route %s %s is superseded by %s, which the gateway does not serve", route.method, route.path, route.gateway)
+		}
+	}
+	if superseded == 0 {
+		t.Error("Expected the gateway to supersede the hand-written routes serving its RPCs")
+	}
+
+	s := &ServerOpenchainREST{}
+	if _, err := s.callGateway(context.Background(), "Openchain.NoSuchRPC", &google_protobuf.Empty{}); grpc.Code(err) != codes.Unimplemented {
+		t.Errorf("Expected an unknown RPC to be unimplemented but got %v", err)
+	}
+	if _, err := s.callGateway(context.Background(), "Openchain.GetBlockchainInfo", &google_protobuf.Empty{}); grpc.Code(err) != codes.Unavailable {
+		t.Errorf("Expected the RPC of a missing service to be unavailable but got %v", err)
+	}
+}
+
+func TestAuthorizeGatewayRequest(t *testing.T) {
+	s := &ServerOpenchainREST{identity: "jim"}
+
+	spec := &protos.ChaincodeSpec{}
+	if err := s.authorizeGatewayRequest(spec); err != nil || spec.SecureContext != "jim" {
+		t.Errorf("Expected a chaincode spec without user to act as jim, got %s, %v", spec.SecureContext, err)
+	}
+	if err := s.authorizeGatewayRequest(&protos.ChaincodeSpec{SecureContext: "bob"}); err == nil {
+		t.Error("Expected a chaincode spec acting as another user to be refused")
+	}
+	if err := s.authorizeGatewayRequest(&protos.Secret{EnrollId: "bob"}); err == nil {
+		t.Error("Expected a secret of another user to be refused")
+	}
+	if err := s.authorizeGatewayRequest(&google_protobuf.Empty{}); err != nil {
+		t.Errorf("Expected a request without user to be accepted, got %v", err)
+	}
+
+	// Requests not known to carry their user, or none, are refused
+	if err := s.authorizeGatewayRequest(&protos.Block{}); err == nil {
+		t.Error("Expected a request of an unknown type to be refused")
+	}
+	if err := (&ServerOpenchainREST{}).authorizeGatewayRequest(&protos.Block{}); err != nil {
+		t.Errorf("Expected the requests of a client without token to be accepted, got %v", err)
+	}
+
+	// Which none of the RPCs served takes
+	for _, service := range gatewayServices {
+		for i := 0; i < service.iface.NumMethod(); i++ {
+			method := service.iface.Method(i)
+			if method.Type.NumIn() != 2 {
+				continue
+			}
+			request := reflect.New(method.Type.In(1).Elem()).Interface()
+			if err := s.authorizeGatewayRequest(request); err != nil && strings.Contains(err.Error(), "may not send") {
+				t.Errorf("Expected the request of %s.%s to be checked, got %v", service.name, method.Name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gocraft/web"
+	"github.com/spf13/viper"
+)
+
+// headerPolicy sets the CORS headers of the REST responses, letting the
+// pages of the allowed origins call the API from a browser, and the security
+// headers hardening the responses
+type headerPolicy struct {
+	// Origins allowed to call the API, any of them if anyOrigin
+	origins   map[string]bool
+	anyOrigin bool
+	// Methods allowed by preflight responses, those of the path if empty
+	methods     string
+	headers     string
+	exposed     string
+	credentials bool
+	// Seconds browsers may cache preflight responses, unset if empty
+	maxAge string
+	// Headers set on every response
+	security map[string]string
+}
+
+// restHeaders is the header policy of the REST responses, allowing any
+// origin until configured by rest.cors and rest.securityHeaders
+var restHeaders = &headerPolicy{anyOrigin: true, headers: "accept, authorization, content-type"}
+
+// newHeaderPolicy returns the policy configured by rest.cors and
+// rest.securityHeaders. Strict-Transport-Security is only sent over TLS.
+func newHeaderPolicy(tlsEnabled bool) (*headerPolicy, error) {
+	p := &headerPolicy{
+		origins:     make(map[string]bool),
+		methods:     strings.Join(viper.GetStringSlice("rest.cors.allowedMethods"), ", "),
+		headers:     strings.Join(viper.GetStringSlice("rest.cors.allowedHeaders"), ", "),
+		exposed:     strings.Join(viper.GetStringSlice("rest.cors.exposedHeaders"), ", "),
+		credentials: viper.GetBool("rest.cors.allowCredentials"),
+		security:    make(map[string]string),
+	}
+	for _, origin := range viper.GetStringSlice("rest.cors.allowedOrigins") {
+		if origin == "*" {
+			p.anyOrigin = true
+		} else {
+			p.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+		}
+	}
+	if p.anyOrigin && p.credentials {
+		return nil, errors.New("rest.cors.allowCredentials requires explicit rest.cors.allowedOrigins")
+	}
+	if maxAge := viper.GetDuration("rest.cors.maxAge"); maxAge > 0 {
+		p.maxAge = fmt.Sprintf("%d", int64(maxAge.Seconds()))
+	}
+
+	for name, key := range map[string]string{
+		"X-Content-Type-Options":  "rest.securityHeaders.contentTypeOptions",
+		"X-Frame-Options":         "rest.securityHeaders.frameOptions",
+		"Content-Security-Policy": "rest.securityHeaders.contentSecurityPolicy",
+		"Referrer-Policy":         "rest.securityHeaders.referrerPolicy",
+		"Cache-Control":           "rest.securityHeaders.cacheControl",
+	} {
+		if value := viper.GetString(key); value != "" {
+			p.security[name] = value
+		}
+	}
+	if hsts := viper.GetDuration("rest.securityHeaders.hstsMaxAge"); tlsEnabled && hsts > 0 {
+		p.security["Strict-Transport-Security"] = fmt.Sprintf("max-age=%d; includeSubDomains", int64(hsts.Seconds()))
+	}
+	for name, value := range viper.GetStringMapString("rest.securityHeaders.custom") {
+		p.security[http.CanonicalHeaderKey(name)] = value
+	}
+
+	return p, nil
+}
+
+// allows tells whether pages of origin may call the API
+func (p *headerPolicy) allows(origin string) bool {
+	return p.anyOrigin || p.origins[strings.ToLower(origin)]
+}
+
+// apply sets the security headers, and the CORS headers of a request from an
+// allowed origin
+func (p *headerPolicy) apply(rw web.ResponseWriter, req *web.Request) {
+	for name, value := range p.security {
+		rw.Header().Set(name, value)
+	}
+
+	if p.anyOrigin && !p.credentials {
+		rw.Header().Set("Access-Control-Allow-Origin", "*")
+	} else if origin := req.Header.Get("Origin"); origin != "" && p.allows(origin) {
+		rw.Header().Set("Access-Control-Allow-Origin", origin)
+		rw.Header().Add("Vary", "Origin")
+		if p.credentials {
+			rw.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+	} else {
+		return
+	}
+	if p.headers != "" {
+		rw.Header().Set("Access-Control-Allow-Headers", p.headers)
+	}
+	if p.exposed != "" {
+		rw.Header().Set("Access-Control-Expose-Headers", p.exposed)
+	}
+}
+
+// Preflight answers the CORS preflight requests, the OPTIONS requests of
+// browsers checking whether they may call the API. The methods are those of
+// the routes of the requested path.
+func (s *ServerOpenchainREST) Preflight(rw web.ResponseWriter, req *web.Request, methods []string) {
+	if rw.Header().Get("Access-Control-Allow-Origin") != "" {
+		if restHeaders.methods != "" {
+			rw.Header().Set("Access-Control-Allow-Methods", restHeaders.methods)
+		} else {
+			rw.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		}
+		if restHeaders.maxAge != "" {
+			rw.Header().Set("Access-Control-Max-Age", restHeaders.maxAge)
+		}
+	}
+	rw.WriteHeader(http.StatusOK)
+}
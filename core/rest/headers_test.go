@@ -0,0 +1,103 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func performHTTPRequest(t *testing.T, method, url, origin string) *http.Response {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("Error building a %s request: %s", method, err)
+	}
+	req.Header.Set("Origin", origin)
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error attempt to %s %s: %v", method, url, err)
+	}
+	response.Body.Close()
+	return response
+}
+
+func TestHeaderPolicy(t *testing.T) {
+	viper.Set("rest.cors.allowedOrigins", []string{"https://explorer.example.com/"})
+	viper.Set("rest.cors.allowedHeaders", []string{"authorization", "content-type"})
+	viper.Set("rest.cors.allowCredentials", true)
+	viper.Set("rest.cors.maxAge", "10m")
+	viper.Set("rest.securityHeaders.frameOptions", "DENY")
+	viper.Set("rest.securityHeaders.hstsMaxAge", "24h")
+	viper.Set("rest.securityHeaders.custom", map[string]string{"x-powered-by": "fabric"})
+	defer func() {
+		for _, key := range []string{"rest.cors.allowedOrigins", "rest.cors.allowedHeaders", "rest.cors.allowCredentials",
+			"rest.cors.maxAge", "rest.securityHeaders.frameOptions", "rest.securityHeaders.hstsMaxAge", "rest.securityHeaders.custom"} {
+			viper.Set(key, nil)
+		}
+	}()
+
+	policy, err := newHeaderPolicy(false)
+	if err != nil {
+		t.Fatalf("Error configuring the header policy: %s", err)
+	}
+	if _, ok := policy.security["Strict-Transport-Security"]; ok {
+		t.Error("Expected no Strict-Transport-Security header without TLS")
+	}
+
+	initGlobalServerOpenchain(t)
+	restHeaders = policy
+	defer func() { restHeaders = &headerPolicy{anyOrigin: true, headers: "accept, authorization, content-type"} }()
+
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	// Preflight of an allowed origin
+	response := performHTTPRequest(t, "OPTIONS", httpServer.URL+"/chaincode", "https://Explorer.example.com")
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusOK, response.StatusCode)
+	}
+	for name, expected := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://Explorer.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "POST",
+		"Access-Control-Allow-Headers":     "authorization, content-type",
+		"Access-Control-Max-Age":           "600",
+		"X-Frame-Options":                  "DENY",
+		"X-Powered-By":                     "fabric",
+	} {
+		if value := response.Header.Get(name); value != expected {
+			t.Errorf("Expected %s to be '%s' but got '%s'", name, expected, value)
+		}
+	}
+
+	// Request of another origin
+	response = performHTTPRequest(t, "GET", httpServer.URL+"/chain", "https://evil.example.com")
+	if value := response.Header.Get("Access-Control-Allow-Origin"); value != "" {
+		t.Errorf("Expected no CORS headers for a disallowed origin but got '%s'", value)
+	}
+	if value := response.Header.Get("X-Frame-Options"); value != "DENY" {
+		t.Errorf("Expected the security headers for any origin but got '%s'", value)
+	}
+
+	// Credentials can't be allowed for any origin
+	viper.Set("rest.cors.allowedOrigins", []string{"*"})
+	if _, err := newHeaderPolicy(true); err == nil {
+		t.Error("Expected an error allowing credentials for any origin")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gocraft/web"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// HealthChecker reports the health of the subsystems of the peer: with
+// readiness false those telling whether it is alive, and with readiness true
+// also those telling whether it is ready to serve
+type HealthChecker interface {
+	CheckHealth(readiness bool) []*pb.SubsystemHealth
+}
+
+// restHealth checks the health reported by /healthz and /readyz, nil until
+// the REST service starts
+var restHealth HealthChecker
+
+// healthReport is the body of the /healthz and /readyz responses
+type healthReport struct {
+	// ok or unavailable
+	Status string        `json:"status"`
+	Checks []healthCheck `json:"checks"`
+}
+
+// healthCheck is the health of a subsystem of the peer
+type healthCheck struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+func init() {
+	// Served to the probes of orchestrators and load balancers, which neither
+	// authenticate nor should be rate limited
+	restRoutes = append(restRoutes,
+		restRoute{method: "GET", path: "/healthz", handler: (*ServerOpenchainREST).GetLiveness,
+			operationID: "getLiveness", tag: "Health", summary: "Whether the peer is alive, for liveness probes",
+			response: healthReport{}, failure: healthReport{}, public: true, unlimited: true},
+		restRoute{method: "GET", path: "/readyz", handler: (*ServerOpenchainREST).GetReadiness,
+			operationID: "getReadiness", tag: "Health", summary: "Whether the peer is ready to serve, for readiness probes",
+			response: healthReport{}, failure: healthReport{}, public: true, unlimited: true})
+}
+
+// GetLiveness reports whether the peer is alive, with the health of the
+// subsystems checked. It fails with 503 if any is unhealthy, in which case
+// restarting the peer may help.
+func (s *ServerOpenchainREST) GetLiveness(rw web.ResponseWriter, req *web.Request) {
+	writeHealth(rw, false)
+}
+
+// GetReadiness reports whether the peer is ready to serve, e.g. connected to
+// the network and with consensus healthy, with the health of the subsystems
+// checked. It fails with 503 if any is unhealthy, in which case requests
+// should be sent to other peers.
+func (s *ServerOpenchainREST) GetReadiness(rw web.ResponseWriter, req *web.Request) {
+	writeHealth(rw, true)
+}
+
+func writeHealth(rw web.ResponseWriter, readiness bool) {
+	report := healthReport{Status: "ok", Checks: []healthCheck{}}
+	if restHealth != nil {
+		for _, subsystem := range restHealth.CheckHealth(readiness) {
+			report.Checks = append(report.Checks, healthCheck{Name: subsystem.Name, Healthy: subsystem.Healthy, Detail: subsystem.Detail})
+			if !subsystem.Healthy {
+				report.Status = "unavailable"
+			}
+		}
+	}
+
+	if report.Status == "ok" {
+		rw.WriteHeader(http.StatusOK)
+	} else {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(rw).Encode(report)
+}
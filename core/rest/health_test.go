@@ -0,0 +1,82 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// mockHealth reports a healthy ledger and, for readiness, the network
+type mockHealth struct {
+	networkErr string
+}
+
+func (m *mockHealth) CheckHealth(readiness bool) []*pb.SubsystemHealth {
+	subsystems := []*pb.SubsystemHealth{{Name: "ledger", Healthy: true}}
+	if readiness {
+		subsystems = append(subsystems, &pb.SubsystemHealth{Name: "network", Healthy: m.networkErr == "", Detail: m.networkErr})
+	}
+	return subsystems
+}
+
+func getHealth(t *testing.T, url string) (int, healthReport) {
+	response, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Error attempt to GET %s: %v", url, err)
+	}
+	defer response.Body.Close()
+	var report healthReport
+	if err = json.NewDecoder(response.Body).Decode(&report); err != nil {
+		t.Fatalf("Invalid health report: %s", err)
+	}
+	return response.StatusCode, report
+}
+
+func TestServerOpenchainREST_API_Health(t *testing.T) {
+	health := &mockHealth{networkErr: "Not connected to any peer"}
+	restHealth = health
+	defer func() { restHealth = nil }()
+	// Probes are neither authenticated nor limited
+	restTokenVerifier = &tokenVerifier{}
+	restLimits = &limitPolicy{maxBodySize: 1}
+	defer func() { restTokenVerifier, restLimits = nil, nil }()
+
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	status, report := getHealth(t, httpServer.URL+"/healthz")
+	if status != http.StatusOK || report.Status != "ok" || len(report.Checks) != 1 || report.Checks[0].Name != "ledger" {
+		t.Errorf("Expected the peer to be alive, got %d %+v", status, report)
+	}
+
+	status, report = getHealth(t, httpServer.URL+"/readyz")
+	if status != http.StatusServiceUnavailable || report.Status != "unavailable" || len(report.Checks) != 2 {
+		t.Fatalf("Expected the peer not to be ready, got %d %+v", status, report)
+	}
+	if network := report.Checks[1]; network.Healthy || network.Detail != "Not connected to any peer" {
+		t.Errorf("Expected the network check to fail, got %+v", network)
+	}
+
+	health.networkErr = ""
+	if status, report = getHealth(t, httpServer.URL+"/readyz"); status != http.StatusOK || report.Status != "ok" {
+		t.Errorf("Expected the peer to be ready, got %d %+v", status, report)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gocraft/web"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/comm"
+)
+
+// requestEntityTooLargeStatus is the HTTP status of a request refused
+// because its body exceeds the limit of its endpoint
+const requestEntityTooLargeStatus = http.StatusRequestEntityTooLarge
+
+// limitPolicy bounds the size of the request bodies of each endpoint and the
+// rate of the requests of each client, so that an oversized payload or a
+// polling loop cannot degrade the whole peer
+type limitPolicy struct {
+	// Largest body in bytes of the endpoints not in maxBodySizes, unlimited
+	// if 0
+	maxBodySize int64
+	// Largest body in bytes by operation ID of the endpoint, in lower case
+	maxBodySizes map[string]int64
+	// Rate limit of the requests of each client, nil if unlimited
+	limiter *comm.RateLimiter
+}
+
+// restLimits is the limit policy of the REST requests, nil until
+// configured by rest.limits
+var restLimits *limitPolicy
+
+// newLimitPolicy returns the policy configured by rest.limits, nil if it
+// limits nothing
+func newLimitPolicy() (*limitPolicy, error) {
+	p := &limitPolicy{
+		maxBodySize:  int64(viper.GetInt("rest.limits.maxBodySize")),
+		maxBodySizes: make(map[string]int64),
+		limiter:      comm.NewRateLimiter("rest.limits"),
+	}
+	if p.maxBodySize < 0 {
+		return nil, fmt.Errorf("Invalid rest.limits.maxBodySize %d", p.maxBodySize)
+	}
+
+	operations := make(map[string]bool)
+	for _, route := range restRoutes {
+		operations[strings.ToLower(route.operationID)] = true
+	}
+	for operation, value := range viper.GetStringMap("rest.limits.maxBodySizes") {
+		size, err := cast.ToIntE(value)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("Invalid rest.limits.maxBodySizes value %v of %s", value, operation)
+		}
+		operation = strings.ToLower(operation)
+		if !operations[operation] {
+			return nil, fmt.Errorf("Unknown operation %s in rest.limits.maxBodySizes", operation)
+		}
+		p.maxBodySizes[operation] = int64(size)
+	}
+
+	if p.maxBodySize == 0 && len(p.maxBodySizes) == 0 && p.limiter == nil {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// bodySizeLimit returns the largest body of the requests of route, 0 if
+// unlimited
+func (p *limitPolicy) bodySizeLimit(route restRoute) int64 {
+	if size, ok := p.maxBodySizes[strings.ToLower(route.operationID)]; ok {
+		return size
+	}
+	return p.maxBodySize
+}
+
+// limited wraps the handler of route, refusing with 429 and a Retry-After
+// header the requests of clients exceeding their rate limit, and with 413
+// the requests whose body exceeds the limit of route. The body is read
+// before calling handler, which reads it from memory.
+func limited(route restRoute, handler func(*ServerOpenchainREST, web.ResponseWriter, *web.Request)) func(*ServerOpenchainREST, web.ResponseWriter, *web.Request) {
+	maxBodySize := restLimits.bodySizeLimit(route)
+	return func(s *ServerOpenchainREST, rw web.ResponseWriter, req *web.Request) {
+		client := comm.HTTPClientIdentity(req.Request)
+		if err := restLimits.limiter.Admit(client); err != nil {
+			rw.Header().Set("Retry-After", retryAfterSeconds(err))
+			denyRequest(rw, req, rateLimitedStatus, client, "Rate limit exceeded.")
+			return
+		}
+
+		if maxBodySize > 0 && req.Body != nil {
+			if req.ContentLength > maxBodySize {
+				denyRequest(rw, req, requestEntityTooLargeStatus, client, fmt.Sprintf("Request body exceeds %d bytes.", maxBodySize))
+				return
+			}
+			body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+			req.Body.Close()
+			if err != nil {
+				rw.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(rw).Encode(restResult{Error: fmt.Sprintf("Failed reading the request body: %s.", err)})
+				return
+			}
+			if int64(len(body)) > maxBodySize {
+				denyRequest(rw, req, requestEntityTooLargeStatus, client, fmt.Sprintf("Request body exceeds %d bytes.", maxBodySize))
+				return
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		handler(s, rw, req)
+	}
+}
+
+// retryAfterSeconds returns the Retry-After header of a request refused by
+// a rate limiter, the seconds until the client is admitted again
+func retryAfterSeconds(err error) string {
+	if rateLimited, ok := err.(*comm.RateLimitedError); ok && rateLimited.RetryAfter > 0 {
+		return fmt.Sprintf("%d", int64(math.Ceil(rateLimited.RetryAfter.Seconds())))
+	}
+	return rateLimitedRetryAfter
+}
@@ -0,0 +1,129 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/comm"
+)
+
+func TestLimitPolicy(t *testing.T) {
+	defer func() {
+		for _, key := range []string{"rest.limits.maxBodySize", "rest.limits.maxBodySizes", "rest.limits.rate", "rest.limits.burst"} {
+			viper.Set(key, nil)
+		}
+	}()
+
+	viper.Set("rest.limits.maxBodySize", 0)
+	viper.Set("rest.limits.rate", 0)
+	if policy, err := newLimitPolicy(); err != nil || policy != nil {
+		t.Errorf("Expected no limit policy when nothing is limited, got %v, %v", policy, err)
+	}
+
+	viper.Set("rest.limits.maxBodySize", 1024)
+	viper.Set("rest.limits.maxBodySizes", map[string]interface{}{"chaincodeOp": 4096, "ChaincodeDeploy": "0"})
+	viper.Set("rest.limits.rate", 10)
+	policy, err := newLimitPolicy()
+	if err != nil {
+		t.Fatalf("Error configuring the limit policy: %s", err)
+	}
+	if policy.limiter == nil {
+		t.Error("Expected a rate limiter")
+	}
+	for operationID, expected := range map[string]int64{"chaincodeOp": 4096, "chaincodeDeploy": 0, "getChain": 1024} {
+		if size := policy.bodySizeLimit(restRoute{operationID: operationID}); size != expected {
+			t.Errorf("Expected a body size limit of %d for %s, got %d", expected, operationID, size)
+		}
+	}
+
+	viper.Set("rest.limits.maxBodySizes", map[string]interface{}{"chaincodeOps": 4096})
+	if _, err := newLimitPolicy(); err == nil {
+		t.Error("Expected an error for an unknown operation")
+	}
+	viper.Set("rest.limits.maxBodySizes", map[string]interface{}{"chaincodeOp": -1})
+	if _, err := newLimitPolicy(); err == nil {
+		t.Error("Expected an error for a negative body size limit")
+	}
+}
+
+func TestServerOpenchainREST_API_Limits(t *testing.T) {
+	initGlobalServerOpenchain(t)
+
+	viper.Set("rest.limits.rate", 1)
+	viper.Set("rest.limits.burst", 2)
+	defer func() {
+		viper.Set("rest.limits.rate", nil)
+		viper.Set("rest.limits.burst", nil)
+	}()
+	restLimits = &limitPolicy{maxBodySize: 64, maxBodySizes: make(map[string]int64)}
+	defer func() { restLimits = nil }()
+
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	// A body over the limit, announced by its Content-Length or not
+	query := `{"jsonrpc":"2.0","ID":123,"method":"query","params":{"type":1,"chaincodeID":{"name":"dummy"},"ctorMsg":{"function":"get_owner","args":[]}}}`
+	for _, body := range []io.Reader{strings.NewReader(query), io.MultiReader(strings.NewReader(query))} {
+		response, err := http.Post(httpServer.URL+"/chaincode", "application/json", body)
+		if err != nil {
+			t.Fatalf("Error attempt to POST /chaincode: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusRequestEntityTooLarge, response.StatusCode)
+		}
+	}
+
+	// A body within the limit of its endpoint
+	restLimits.maxBodySizes["chaincodeop"] = 4096
+	httpServer.Config.Handler = buildOpenchainRESTRouter()
+	response, body := performHTTPPost(t, httpServer.URL+"/chaincode", []byte(query))
+	if response.StatusCode == http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected the query to be within the limit, got %s", body)
+	}
+
+	// Clients exceeding their rate limit
+	restLimits = &limitPolicy{limiter: comm.NewRateLimiter("rest.limits")}
+	httpServer.Config.Handler = buildOpenchainRESTRouter()
+	for i := 0; i < 2; i++ {
+		response, err := http.Get(httpServer.URL + "/chain")
+		if err != nil {
+			t.Fatalf("Error attempt to GET /chain: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode == rateLimitedStatus {
+			t.Errorf("Expected request %d to be within the burst", i+1)
+		}
+	}
+	response, err := http.Get(httpServer.URL + "/chain")
+	if err != nil {
+		t.Fatalf("Error attempt to GET /chain: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != rateLimitedStatus {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", rateLimitedStatus, response.StatusCode)
+	}
+	if retryAfter := response.Header.Get("Retry-After"); retryAfter != "1" {
+		t.Errorf("Expected a Retry-After header of 1, got %q", retryAfter)
+	}
+}
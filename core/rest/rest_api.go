@@ -31,9 +31,12 @@ import (
 	"strings"
 
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 
 	"github.com/gocraft/web"
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 
@@ -42,6 +45,8 @@ import (
 	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/trace"
+	"github.com/hyperledger/fabric/core/txstatus"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -60,6 +65,8 @@ var serverDevops pb.DevopsServer
 type ServerOpenchainREST struct {
 	server *ServerOpenchain
 	devops pb.DevopsServer
+	// The enrollment ID of the client authenticated by its token, if any
+	identity string
 }
 
 // restResult defines the response payload for a general REST interface request.
@@ -154,8 +161,57 @@ var (
 	ChaincodeDeployError     = &rpcError{Code: -32001, Message: "Deployment failure", Data: "Chaincode deployment has failed."}
 	ChaincodeInvokeError     = &rpcError{Code: -32002, Message: "Invocation failure", Data: "Chaincode invocation has failed."}
 	ChaincodeQueryError      = &rpcError{Code: -32003, Message: "Query failure", Data: "Chaincode query has failed."}
+	NetworkSaturatedError    = &rpcError{Code: -32004, Message: "Network saturated", Data: "The validating network is not accepting transactions for now, retry later."}
+	RateLimitedError         = &rpcError{Code: -32005, Message: "Rate limit exceeded", Data: "The client sent too many requests, retry later."}
+	UnauthorizedError        = &rpcError{Code: -32006, Message: "Unauthorized", Data: "The authenticated client may not act as the user of the request."}
 )
 
+// saturatedRetryAfter is the number of seconds a client is asked to wait
+// before resubmitting a transaction rejected by a saturated network
+const saturatedRetryAfter = "5"
+
+// rateLimitedStatus is the HTTP status of a request refused because its
+// client exceeded its rate limit
+const rateLimitedStatus = 429
+
+// writeErrorStatus writes the status of a failed devops request, which is
+// 503 with a Retry-After header if the network was saturated, and 429 with
+// a Retry-After header if the client exceeded its rate limit
+func writeErrorStatus(rw web.ResponseWriter, resp *pb.Response, err error) {
+	if resp != nil && resp.Status == pb.Response_SATURATED {
+		rw.Header().Set("Retry-After", saturatedRetryAfter)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if isRateLimited(err) {
+		rw.Header().Set("Retry-After", rateLimitedRetryAfter)
+		rw.WriteHeader(rateLimitedStatus)
+		return
+	}
+	rw.WriteHeader(http.StatusBadRequest)
+}
+
+// rateLimitedRetryAfter is the number of seconds a client exceeding its rate
+// limit is asked to wait
+const rateLimitedRetryAfter = "1"
+
+// isRateLimited tells whether the devops service refused a request because
+// its client exceeded its rate limit
+func isRateLimited(err error) bool {
+	return err != nil && grpc.Code(err) == codes.ResourceExhausted
+}
+
+// clientContext returns the context of the devops request made for req,
+// identifying its client for the rate limits and carrying the trace context
+// of its traceparent header, if any
+func clientContext(req *web.Request) context.Context {
+	ctx := comm.NewClientContext(context.Background(), comm.HTTPClientIdentity(req.Request))
+	if c, ok := trace.ParseSpanContext(req.Header.Get(trace.Header)); ok {
+		ctx = trace.NewContext(ctx, c)
+	}
+	return ctx
+}
+
 // SetOpenchainServer is a middleware function that sets the pointer to the
 // underlying ServerOpenchain object and the undeflying Devops object.
 func (s *ServerOpenchainREST) SetOpenchainServer(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
@@ -167,13 +223,11 @@ func (s *ServerOpenchainREST) SetOpenchainServer(rw web.ResponseWriter, req *web
 
 // SetResponseType is a middleware function that sets the appropriate response
 // headers. Currently, it is setting the "Content-Type" to "application/json" as
-// well as the necessary headers in order to enable CORS for Swagger usage.
+// well as the CORS and security headers of the configured policy.
 func (s *ServerOpenchainREST) SetResponseType(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
 	rw.Header().Set("Content-Type", "application/json")
 
-	// Enable CORS
-	rw.Header().Set("Access-Control-Allow-Origin", "*")
-	rw.Header().Set("Access-Control-Allow-Headers", "accept, content-type")
+	restHeaders.apply(rw, req)
 
 	next(rw, req)
 }
@@ -259,6 +313,12 @@ func (s *ServerOpenchainREST) Register(rw web.ResponseWriter, req *web.Request)
 		return
 	}
 
+	// A client authenticated by its token may only log itself in
+	if s.identity != "" && loginSpec.EnrollId != s.identity {
+		denyRequest(rw, req, http.StatusForbidden, s.identity, fmt.Sprintf("User %s may not act as %s.", s.identity, loginSpec.EnrollId))
+		return
+	}
+
 	// Retrieve the REST data storage path
 	// Returns /var/hyperledger/production/client/
 	localStore := getRESTFilePath()
@@ -636,8 +696,10 @@ func (s *ServerOpenchainREST) GetTransactionCert(rw web.ResponseWriter, req *web
 
 // GetBlockchainInfo returns information about the blockchain ledger such as
 // height, current block hash, and previous block hash.
+//
+// Deprecated: use the /v1/Openchain/GetBlockchainInfo endpoint instead
 func (s *ServerOpenchainREST) GetBlockchainInfo(rw web.ResponseWriter, req *web.Request) {
-	info, err := s.server.GetBlockchainInfo(context.Background(), &google_protobuf.Empty{})
+	info, err := s.callGateway(clientContext(req), "Openchain.GetBlockchainInfo", &google_protobuf.Empty{})
 
 	encoder := json.NewEncoder(rw)
 
@@ -655,6 +717,8 @@ func (s *ServerOpenchainREST) GetBlockchainInfo(rw web.ResponseWriter, req *web.
 
 // GetBlockByNumber returns the data contained within a specific block in the
 // blockchain. The genesis block is block zero.
+//
+// Deprecated: use the /v1/Openchain/GetBlockByNumber endpoint instead
 func (s *ServerOpenchainREST) GetBlockByNumber(rw web.ResponseWriter, req *web.Request) {
 	// Parse out the Block id
 	blockNumber, err := strconv.ParseUint(req.PathParams["id"], 10, 64)
@@ -670,7 +734,8 @@ func (s *ServerOpenchainREST) GetBlockByNumber(rw web.ResponseWriter, req *web.R
 	}
 
 	// Retrieve Block from blockchain
-	block, err := s.server.GetBlockByNumber(context.Background(), &pb.BlockNumber{Number: blockNumber})
+	response, err := s.callGateway(clientContext(req), "Openchain.GetBlockByNumber", &pb.BlockNumber{Number: blockNumber})
+	block, _ := response.(*pb.Block)
 
 	if (err == ErrNotFound) || (err == nil && block == nil) {
 		rw.WriteHeader(http.StatusNotFound)
@@ -684,18 +749,302 @@ func (s *ServerOpenchainREST) GetBlockByNumber(rw web.ResponseWriter, req *web.R
 		return
 	}
 
+	// Keep only the requested fields, if any
+	fields := parseFields(req)
+	if fields == nil {
+		rw.WriteHeader(http.StatusOK)
+		encoder.Encode(block)
+		return
+	}
+	selected, err := selectFields(block, fields)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		encoder.Encode(restResult{Error: err.Error()})
+		return
+	}
+
 	// Success
 	rw.WriteHeader(http.StatusOK)
-	encoder.Encode(block)
+	encoder.Encode(selected)
+}
+
+// Default and maximum number of entries of a page of blocks or transactions
+const (
+	defaultPageLimit = 10
+	maxPageLimit     = 100
+)
+
+// blocksPage is a page of the blocks of the blockchain. Next is the offset
+// of the following page, absent on the last page.
+type blocksPage struct {
+	Height uint64                   `json:"height"`
+	Blocks []map[string]interface{} `json:"blocks"`
+	Next   *uint64                  `json:"next,omitempty"`
+}
+
+// transactionsPage is a page of the transactions of a block matching the
+// filters of the query. Next is the offset of the following page, absent on
+// the last page.
+type transactionsPage struct {
+	Block        uint64                   `json:"block"`
+	Transactions []map[string]interface{} `json:"transactions"`
+	Next         *uint64                  `json:"next,omitempty"`
+}
+
+// parseFields returns the fields requested by the comma separated fields
+// query parameter of req, nil if there is none
+func parseFields(req *web.Request) []string {
+	var fields []string
+	for _, field := range strings.Split(req.URL.Query().Get("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// selectFields returns the JSON object encoding v with only fields kept, or
+// all of its fields if fields is nil
+func selectFields(v interface{}, fields []string) (map[string]interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err = json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return object, nil
+	}
+
+	selected := make(map[string]interface{})
+	for _, field := range fields {
+		if value, ok := object[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected, nil
+}
+
+// parsePageParam returns the value of the non-negative integer query
+// parameter name of req, or def if it is absent
+func parsePageParam(req *web.Request, name string, def uint64) (uint64, error) {
+	param := req.URL.Query().Get(name)
+	if param == "" {
+		return def, nil
+	}
+	value, err := strconv.ParseUint(param, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s query parameter must be a non-negative integer.", strings.Title(name))
+	}
+	return value, nil
+}
+
+// parsePageLimit returns the limit query parameter of req, defaultPageLimit
+// if it is absent and at most maxPageLimit
+func parsePageLimit(req *web.Request) (uint64, error) {
+	limit, err := parsePageParam(req, "limit", defaultPageLimit)
+	if err != nil {
+		return 0, err
+	}
+	if limit == 0 {
+		return 0, fmt.Errorf("Limit query parameter must be a positive integer.")
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return limit, nil
+}
+
+// GetBlocks returns a page of the blocks of the blockchain, starting at the
+// block number given by the offset query parameter and listed in ascending
+// order, or in descending order from the latest block with order=desc. The
+// limit query parameter caps the number of blocks of the page and the fields
+// query parameter selects the fields of the blocks returned, the block
+// number being always included.
+func (s *ServerOpenchainREST) GetBlocks(rw web.ResponseWriter, req *web.Request) {
+	encoder := json.NewEncoder(rw)
+
+	count, err := s.server.GetBlockCount(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		encoder.Encode(restResult{Error: err.Error()})
+		return
+	}
+	height := count.Count
+
+	descending := false
+	switch req.URL.Query().Get("order") {
+	case "", "asc":
+	case "desc":
+		descending = true
+	default:
+		rw.WriteHeader(http.StatusBadRequest)
+		encoder.Encode(restResult{Error: "Order query parameter must be either asc or desc."})
+		return
+	}
+
+	start := uint64(0)
+	if descending {
+		start = height - 1
+	}
+	offset, err := parsePageParam(req, "offset", start)
+	var limit uint64
+	if err == nil {
+		limit, err = parsePageLimit(req)
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		encoder.Encode(restResult{Error: err.Error()})
+		return
+	}
+
+	page, err := s.blocksPage(height, offset, limit, descending, parseFields(req))
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		encoder.Encode(restResult{Error: err.Error()})
+		restLogger.Errorf("Error retrieving blocks: %s", err)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	encoder.Encode(page)
+}
+
+// blocksPage returns the page of at most limit blocks starting at offset in
+// a blockchain of the given height. The blocks missing from the blockchain
+// are skipped.
+func (s *ServerOpenchainREST) blocksPage(height, offset, limit uint64, descending bool, fields []string) (*blocksPage, error) {
+	page := &blocksPage{Height: height, Blocks: []map[string]interface{}{}}
+
+	number := offset
+	if descending && number >= height {
+		number = height - 1
+	}
+	for number < height && uint64(len(page.Blocks)) < limit {
+		block, err := s.server.GetBlockByNumber(context.Background(), &pb.BlockNumber{Number: number})
+		if err != nil && err != ErrNotFound {
+			return nil, err
+		}
+		if err == nil && block != nil {
+			entry, err := selectFields(block, fields)
+			if err != nil {
+				return nil, err
+			}
+			entry["number"] = number
+			page.Blocks = append(page.Blocks, entry)
+		}
+
+		if descending {
+			if number == 0 {
+				return page, nil
+			}
+			number--
+		} else {
+			number++
+		}
+	}
+
+	if number < height {
+		page.Next = &number
+	}
+	return page, nil
+}
+
+// GetBlockTransactions returns a page of the transactions of a block,
+// starting at the index given by the offset query parameter. The type and
+// chaincodeID query parameters keep only the transactions of that type, e.g.
+// CHAINCODE_INVOKE, and of the chaincode of that name or path. The limit and
+// fields query parameters are those of GetBlocks.
+func (s *ServerOpenchainREST) GetBlockTransactions(rw web.ResponseWriter, req *web.Request) {
+	encoder := json.NewEncoder(rw)
+
+	blockNumber, err := strconv.ParseUint(req.PathParams["id"], 10, 64)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		encoder.Encode(restResult{Error: "Block id must be an integer (uint64)."})
+		return
+	}
+
+	txType := req.URL.Query().Get("type")
+	if _, ok := pb.Transaction_Type_value[txType]; txType != "" && !ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		encoder.Encode(restResult{Error: fmt.Sprintf("Unknown transaction type %s.", txType)})
+		return
+	}
+	chaincodeID := req.URL.Query().Get("chaincodeID")
+
+	offset, err := parsePageParam(req, "offset", 0)
+	var limit uint64
+	if err == nil {
+		limit, err = parsePageLimit(req)
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		encoder.Encode(restResult{Error: err.Error()})
+		return
+	}
+
+	block, err := s.server.GetBlockByNumber(context.Background(), &pb.BlockNumber{Number: blockNumber})
+	if (err == ErrNotFound) || (err == nil && block == nil) {
+		rw.WriteHeader(http.StatusNotFound)
+		encoder.Encode(restResult{Error: ErrNotFound.Error()})
+		return
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		encoder.Encode(restResult{Error: err.Error()})
+		return
+	}
+
+	fields := parseFields(req)
+	page := &transactionsPage{Block: blockNumber, Transactions: []map[string]interface{}{}}
+	var index uint64
+	for _, tx := range block.Transactions {
+		if (txType != "" && tx.Type.String() != txType) || (chaincodeID != "" && !isTransactionOf(tx, chaincodeID)) {
+			continue
+		}
+		if index >= offset {
+			if uint64(len(page.Transactions)) == limit {
+				page.Next = &index
+				break
+			}
+			entry, err := selectFields(tx, fields)
+			if err != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+				encoder.Encode(restResult{Error: err.Error()})
+				return
+			}
+			page.Transactions = append(page.Transactions, entry)
+		}
+		index++
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	encoder.Encode(page)
+}
+
+// isTransactionOf tells whether tx is a transaction of the chaincode of name
+// or path id. The chaincode ID of confidential transactions is encrypted and
+// never matches.
+func isTransactionOf(tx *pb.Transaction, id string) bool {
+	chaincodeID := &pb.ChaincodeID{}
+	if err := proto.Unmarshal(tx.ChaincodeID, chaincodeID); err != nil {
+		return false
+	}
+	return chaincodeID.Name == id || chaincodeID.Path == id
 }
 
 // GetTransactionByUUID returns a transaction matching the specified UUID
+//
+// Deprecated: use the /v1/Openchain/GetTransaction endpoint instead
 func (s *ServerOpenchainREST) GetTransactionByUUID(rw web.ResponseWriter, req *web.Request) {
 	// Parse out the transaction UUID
 	txUUID := req.PathParams["uuid"]
 
 	// Retrieve the transaction matching the UUID
-	tx, err := s.server.GetTransactionByUUID(context.Background(), txUUID)
+	tx, err := s.callGateway(clientContext(req), "Openchain.GetTransaction", &pb.TransactionUUID{Uuid: txUUID})
 
 	encoder := json.NewEncoder(rw)
 
@@ -718,15 +1067,44 @@ func (s *ServerOpenchainREST) GetTransactionByUUID(rw web.ResponseWriter, req *w
 	}
 }
 
+// GetTransactionStatus returns the status of a transaction, queued, ordered,
+// committed or rejected with its reason, for clients polling the outcome of
+// the transactions they submitted.
+func (s *ServerOpenchainREST) GetTransactionStatus(rw web.ResponseWriter, req *web.Request) {
+	// Parse out the transaction UUID
+	txUUID := req.PathParams["uuid"]
+
+	status, err := s.server.GetTransactionStatus(context.Background(), txUUID)
+
+	encoder := json.NewEncoder(rw)
+
+	if err != nil {
+		switch err {
+		case ErrNotFound:
+			rw.WriteHeader(http.StatusNotFound)
+			encoder.Encode(restResult{Error: fmt.Sprintf("Transaction %s is not found.", txUUID)})
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			encoder.Encode(restResult{Error: fmt.Sprintf("Error retrieving the status of transaction %s: %s.", txUUID, err)})
+			restLogger.Errorf("Error retrieving the status of transaction %s: %s", txUUID, err)
+		}
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	encoder.Encode(status)
+}
+
 // Deploy first builds the chaincode package and subsequently deploys it to the
 // blockchain.
 //
-// Deprecated: use the /chaincode endpoint instead (routes to ProcessChaincode)
+// Deprecated: use the /chaincode endpoint (routes to ProcessChaincode) or the
+// /v1/Devops/Deploy endpoint instead
 func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 	restLogger.Info("REST deploying chaincode...")
 
 	// This endpoint has been deprecated. Add a warning header to all responses.
-	rw.Header().Set("Warning", "299 - /devops/deploy endpoint has been deprecated. Use /chaincode endpoint instead.")
+	rw.Header().Add("Warning", "299 - /devops/deploy endpoint has been deprecated. Use /chaincode endpoint instead.")
 
 	// Decode the incoming JSON payload
 	var spec pb.ChaincodeSpec
@@ -798,7 +1176,14 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 
 	// If security is enabled, add client login token
 	if core.SecurityEnabled() {
-		chaincodeUsr := spec.SecureContext
+		chaincodeUsr, authorized := s.secureContextUser(spec.SecureContext)
+		if !authorized {
+			rw.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(rw, "{\"Error\": \"Not authorized to act as the user of the request.\"}")
+			restLogger.Error("{\"Error\": \"Not authorized to act as the user of the request.\"}")
+
+			return
+		}
 		if chaincodeUsr == "" {
 			rw.WriteHeader(http.StatusBadRequest)
 			fmt.Fprintf(rw, "{\"Error\": \"Must supply username for chaincode when security is enabled.\"}")
@@ -847,12 +1232,13 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 	}
 
 	// Deploy the ChaincodeSpec
-	chaincodeDeploymentSpec, err := s.devops.Deploy(context.Background(), &spec)
+	response, err := s.callGateway(clientContext(req), "Devops.Deploy", &spec)
+	chaincodeDeploymentSpec, _ := response.(*pb.ChaincodeDeploymentSpec)
 	if err != nil {
 		// Replace " characters with '
 		errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
-		rw.WriteHeader(http.StatusBadRequest)
+		writeErrorStatus(rw, nil, err)
 		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
 		restLogger.Errorf("{\"Error\": \"Deploying Chaincode -- %s\"}", errVal)
 
@@ -869,12 +1255,13 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 
 // Invoke executes a specified function within a target Chaincode.
 //
-// Deprecated: use the /chaincode endpoint instead (routes to ProcessChaincode)
+// Deprecated: use the /chaincode endpoint (routes to ProcessChaincode) or the
+// /v1/Devops/Invoke endpoint instead
 func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 	restLogger.Info("REST invoking chaincode...")
 
 	// This endpoint has been deprecated. Add a warning header to all responses.
-	rw.Header().Set("Warning", "299 - /devops/invoke endpoint has been deprecated. Use /chaincode endpoint instead.")
+	rw.Header().Add("Warning", "299 - /devops/invoke endpoint has been deprecated. Use /chaincode endpoint instead.")
 
 	// Decode the incoming JSON payload
 	var spec pb.ChaincodeInvocationSpec
@@ -939,7 +1326,14 @@ func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 
 	// If security is enabled, add client login token
 	if core.SecurityEnabled() {
-		chaincodeUsr := spec.ChaincodeSpec.SecureContext
+		chaincodeUsr, authorized := s.secureContextUser(spec.ChaincodeSpec.SecureContext)
+		if !authorized {
+			rw.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(rw, "{\"Error\": \"Not authorized to act as the user of the request.\"}")
+			restLogger.Error("{\"Error\": \"Not authorized to act as the user of the request.\"}")
+
+			return
+		}
 		if chaincodeUsr == "" {
 			rw.WriteHeader(http.StatusBadRequest)
 			fmt.Fprintf(rw, "{\"Error\": \"Must supply username for chaincode when security is enabled.\"}")
@@ -988,12 +1382,13 @@ func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 	}
 
 	// Invoke the chainCode
-	resp, err := s.devops.Invoke(context.Background(), &spec)
+	response, err := s.callGateway(clientContext(req), "Devops.Invoke", &spec)
+	resp, _ := response.(*pb.Response)
 	if err != nil {
 		// Replace " characters with '
 		errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
-		rw.WriteHeader(http.StatusBadRequest)
+		writeErrorStatus(rw, resp, err)
 		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
 		restLogger.Errorf("{\"Error\": \"Invoking Chaincode -- %s\"}", errVal)
 
@@ -1011,12 +1406,13 @@ func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 
 // Query performs the requested query on the target Chaincode.
 //
-// Deprecated: use the /chaincode endpoint instead (routes to ProcessChaincode)
+// Deprecated: use the /chaincode endpoint (routes to ProcessChaincode) or the
+// /v1/Devops/Query endpoint instead
 func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 	restLogger.Info("REST querying chaincode...")
 
 	// This endpoint has been deprecated. Add a warning header to all responses.
-	rw.Header().Set("Warning", "299 - /devops/query endpoint has been deprecated. Use /chaincode endpoint instead.")
+	rw.Header().Add("Warning", "299 - /devops/query endpoint has been deprecated. Use /chaincode endpoint instead.")
 
 	// Decode the incoming JSON payload
 	var spec pb.ChaincodeInvocationSpec
@@ -1081,7 +1477,14 @@ func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 
 	// If security is enabled, add client login token
 	if core.SecurityEnabled() {
-		chaincodeUsr := spec.ChaincodeSpec.SecureContext
+		chaincodeUsr, authorized := s.secureContextUser(spec.ChaincodeSpec.SecureContext)
+		if !authorized {
+			rw.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(rw, "{\"Error\": \"Not authorized to act as the user of the request.\"}")
+			restLogger.Error("{\"Error\": \"Not authorized to act as the user of the request.\"}")
+
+			return
+		}
 		if chaincodeUsr == "" {
 			rw.WriteHeader(http.StatusBadRequest)
 			fmt.Fprintf(rw, "{\"Error\": \"Must supply username for chaincode when security is enabled.\"}")
@@ -1130,12 +1533,13 @@ func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 	}
 
 	// Query the chainCode
-	resp, err := s.devops.Query(context.Background(), &spec)
+	response, err := s.callGateway(clientContext(req), "Devops.Query", &spec)
+	resp, _ := response.(*pb.Response)
 	if err != nil {
 		// Replace " characters with '
 		errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
-		rw.WriteHeader(http.StatusBadRequest)
+		writeErrorStatus(rw, resp, err)
 		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
 		restLogger.Errorf("{\"Error\": \"Querying Chaincode -- %s\"}", errVal)
 
@@ -1300,7 +1704,7 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 		deploySpec := requestPayload.Params
 
 		// Process the chaincode deployment request and record the result
-		result = s.processChaincodeDeploy(deploySpec)
+		result = s.processChaincodeDeploy(clientContext(req), deploySpec)
 	} else {
 
 		//
@@ -1327,7 +1731,7 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 		}
 
 		// Process the chaincode invoke/query request and record the result
-		result = s.processChaincodeInvokeOrQuery(*(requestPayload.Method), invokequeryPayload)
+		result = s.processChaincodeInvokeOrQuery(clientContext(req), *(requestPayload.Method), invokequeryPayload)
 	}
 
 	//
@@ -1339,7 +1743,15 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 
 	// If the request is not a notification, produce a response.
 	if !notification {
-		rw.WriteHeader(http.StatusOK)
+		if result.Error != nil && result.Error.Code == NetworkSaturatedError.Code {
+			rw.Header().Set("Retry-After", saturatedRetryAfter)
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		} else if result.Error != nil && result.Error.Code == RateLimitedError.Code {
+			rw.Header().Set("Retry-After", rateLimitedRetryAfter)
+			rw.WriteHeader(rateLimitedStatus)
+		} else {
+			rw.WriteHeader(http.StatusOK)
+		}
 		rw.Write(jsonResponse)
 	}
 
@@ -1354,7 +1766,7 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 }
 
 // processChaincodeDeploy triggers chaincode deploy and returns a result or an error
-func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpcResult {
+func (s *ServerOpenchainREST) processChaincodeDeploy(ctx context.Context, spec *pb.ChaincodeSpec) rpcResult {
 	restLogger.Info("REST deploying chaincode...")
 
 	// Check that the ChaincodeID is not nil.
@@ -1414,7 +1826,13 @@ func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpc
 
 	if core.SecurityEnabled() {
 		// User registrationID must be present inside request payload with security enabled
-		chaincodeUsr := spec.SecureContext
+		chaincodeUsr, authorized := s.secureContextUser(spec.SecureContext)
+		if !authorized {
+			error := formatRPCError(UnauthorizedError.Code, UnauthorizedError.Message, UnauthorizedError.Data)
+			restLogger.Error(UnauthorizedError.Data)
+
+			return error
+		}
 		if chaincodeUsr == "" {
 			// Format the error appropriately for further processing
 			error := formatRPCError(InvalidParams.Code, InvalidParams.Message, "Must supply username for chaincode when security is enabled.")
@@ -1470,12 +1888,16 @@ func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpc
 	//
 	// Trigger the chaincode deployment through the devops service
 	//
-	chaincodeDeploymentSpec, err := s.devops.Deploy(context.Background(), spec)
+	chaincodeDeploymentSpec, err := s.devops.Deploy(ctx, spec)
 
 	//
 	// Deployment failed
 	//
 
+	if isRateLimited(err) {
+		restLogger.Warningf("Rejecting chaincode deployment: %s", err)
+		return formatRPCError(RateLimitedError.Code, RateLimitedError.Message, fmt.Sprintf("Error when deploying chaincode: %s", err))
+	}
 	if err != nil {
 		// Format the error appropriately for further processing
 		error := formatRPCError(ChaincodeDeployError.Code, ChaincodeDeployError.Message, fmt.Sprintf("Error when deploying chaincode: %s", err))
@@ -1502,16 +1924,120 @@ func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpc
 }
 
 // processChaincodeInvokeOrQuery triggers chaincode invoke or query and returns a result or an error
-func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec *pb.ChaincodeInvocationSpec) rpcResult {
+func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(ctx context.Context, method string, spec *pb.ChaincodeInvocationSpec) rpcResult {
 	restLogger.Infof("REST %s chaincode...", method)
 
+	if failure := s.prepareInvocation(spec); failure != nil {
+		return *failure
+	}
+
+	//
+	// Create the result variable
+	//
+	var result rpcResult
+
+	// Check the method that is being requested and execute either an invoke or a query
+	if method == "invoke" {
+
+		//
+		// Trigger the chaincode invoke through the devops service
+		//
+
+		resp, err := s.devops.Invoke(ctx, spec)
+
+		//
+		// Invocation failed
+		//
+
+		if resp != nil && resp.Status == pb.Response_SATURATED {
+			restLogger.Warningf("Network saturated, rejecting chaincode invocation: %s", err)
+			return formatRPCError(NetworkSaturatedError.Code, NetworkSaturatedError.Message, fmt.Sprintf("Error when invoking chaincode: %s", err))
+		}
+		if isRateLimited(err) {
+			restLogger.Warningf("Rejecting chaincode invocation: %s", err)
+			return formatRPCError(RateLimitedError.Code, RateLimitedError.Message, fmt.Sprintf("Error when invoking chaincode: %s", err))
+		}
+		if err != nil {
+			// Format the error appropriately for further processing
+			error := formatRPCError(ChaincodeInvokeError.Code, ChaincodeInvokeError.Message, fmt.Sprintf("Error when invoking chaincode: %s", err))
+			restLogger.Errorf("Error when invoking chaincode: %s", err)
+
+			return error
+		}
+
+		//
+		// Invocation succeeded
+		//
+
+		// Clients will need the txuuid in order to track it after invocation, record it
+		txuuid := string(resp.Msg)
+
+		//
+		// Output correctly formatted response
+		//
+
+		result = formatRPCOK(txuuid)
+		// Make a clarification in the invoke response message, that the transaction has been successfully submitted but not completed
+		restLogger.Infof("Successfully submitted invoke transaction with txuuid (%s)", txuuid)
+	}
+
+	if method == "query" {
+
+		//
+		// Trigger the chaincode query through the devops service
+		//
+
+		resp, err := s.devops.Query(ctx, spec)
+
+		//
+		// Query failed
+		//
+
+		if resp != nil && resp.Status == pb.Response_SATURATED {
+			restLogger.Warningf("Network saturated, rejecting chaincode query: %s", err)
+			return formatRPCError(NetworkSaturatedError.Code, NetworkSaturatedError.Message, fmt.Sprintf("Error when querying chaincode: %s", err))
+		}
+		if isRateLimited(err) {
+			restLogger.Warningf("Rejecting chaincode query: %s", err)
+			return formatRPCError(RateLimitedError.Code, RateLimitedError.Message, fmt.Sprintf("Error when querying chaincode: %s", err))
+		}
+		if err != nil {
+			// Format the error appropriately for further processing
+			error := formatRPCError(ChaincodeQueryError.Code, ChaincodeQueryError.Message, fmt.Sprintf("Error when querying chaincode: %s", err))
+			restLogger.Errorf("Error when querying chaincode: %s", err)
+
+			return error
+		}
+
+		//
+		// Query succeeded
+		//
+
+		// Clients will need the returned value, record it
+		val := string(resp.Msg)
+
+		//
+		// Output correctly formatted response
+		//
+
+		result = formatRPCOK(val)
+		restLogger.Infof("Successfully queried chaincode: %s", val)
+	}
+
+	return result
+}
+
+// prepareInvocation checks the invocation spec of an invoke or query and, if
+// security is enabled, adds the login token of its user to it. It returns the
+// error result, if any.
+func (s *ServerOpenchainREST) prepareInvocation(spec *pb.ChaincodeInvocationSpec) *rpcResult {
 	// Check that the ChaincodeID is not nil.
 	if spec.ChaincodeSpec.ChaincodeID == nil {
 		// Format the error appropriately for further processing
 		error := formatRPCError(InvalidParams.Code, InvalidParams.Message, "Payload must contain a ChaincodeID.")
 		restLogger.Error("Payload must contain a ChaincodeID.")
 
-		return error
+		return &error
 	}
 
 	// Check that the Chaincode name is not blank.
@@ -1520,7 +2046,7 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 		error := formatRPCError(InvalidParams.Code, InvalidParams.Message, "Chaincode name may not be blank.")
 		restLogger.Error("Chaincode name may not be blank.")
 
-		return error
+		return &error
 	}
 
 	// Check that the CtorMsg is not left blank.
@@ -1529,7 +2055,7 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 		error := formatRPCError(InvalidParams.Code, InvalidParams.Message, "Payload must contain a CtorMsg with a Chaincode function name.")
 		restLogger.Error("Payload must contain a CtorMsg with a Chaincode function name.")
 
-		return error
+		return &error
 	}
 
 	//
@@ -1538,13 +2064,19 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 
 	if core.SecurityEnabled() {
 		// User registrationID must be present inside request payload with security enabled
-		chaincodeUsr := spec.ChaincodeSpec.SecureContext
+		chaincodeUsr, authorized := s.secureContextUser(spec.ChaincodeSpec.SecureContext)
+		if !authorized {
+			error := formatRPCError(UnauthorizedError.Code, UnauthorizedError.Message, UnauthorizedError.Data)
+			restLogger.Error(UnauthorizedError.Data)
+
+			return &error
+		}
 		if chaincodeUsr == "" {
 			// Format the error appropriately for further processing
 			error := formatRPCError(InvalidParams.Code, InvalidParams.Message, "Must supply username for chaincode when security is enabled.")
 			restLogger.Error("Must supply username for chaincode when security is enabled.")
 
-			return error
+			return &error
 		}
 
 		// Retrieve the REST data storage path
@@ -1563,7 +2095,7 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 				error := formatRPCError(InternalError.Code, InternalError.Message, fmt.Sprintf("Fatal error when reading client login token: %s", err))
 				restLogger.Errorf("Fatal error when reading client login token: %s", err)
 
-				return error
+				return &error
 			}
 
 			// Add the login token to the chaincodeSpec
@@ -1580,95 +2112,105 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 				error := formatRPCError(MissingRegistrationError.Code, MissingRegistrationError.Message, MissingRegistrationError.Data)
 				restLogger.Error(MissingRegistrationError.Data)
 
-				return error
+				return &error
 			}
 			// Unexpected error
 			// Format the error appropriately for further processing
 			error := formatRPCError(InternalError.Code, InternalError.Message, fmt.Sprintf("Unexpected fatal error when checking for client login token: %s", err))
 			restLogger.Errorf("Unexpected fatal error when checking for client login token: %s", err)
 
-			return error
+			return &error
 		}
 	}
 
-	//
-	// Create the result variable
-	//
-	var result rpcResult
+	return nil
+}
 
-	// Check the method that is being requested and execute either an invoke or a query
-	if method == "invoke" {
+// batchResult defines the response payload of the /chaincode/batch endpoint,
+// holding the result of each invocation of the batch in the same order.
+type batchResult struct {
+	Results []batchItemResult `json:"results"`
+}
 
-		//
-		// Trigger the chaincode invoke through the devops service
-		//
+// batchItemResult is the result of one invocation of a batch, carrying the
+// UUID of its transaction if submitted.
+type batchItemResult struct {
+	Status string `json:"status"`
+	TxID   string `json:"txid,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
 
-		resp, err := s.devops.Invoke(context.Background(), spec)
+// rpcErrorStatus returns the HTTP status of a failed chaincode request
+func rpcErrorStatus(err *rpcError) int {
+	switch err.Code {
+	case UnauthorizedError.Code:
+		return http.StatusForbidden
+	case MissingRegistrationError.Code:
+		return http.StatusUnauthorized
+	case InternalError.Code:
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
 
-		//
-		// Invocation failed
-		//
+// ProcessChaincodeBatch submits a batch of chaincode invocations, each
+// through its own transaction, consecutively so that consensus may order
+// them together. A batch with an invalid invocation is refused as a whole.
+func (s *ServerOpenchainREST) ProcessChaincodeBatch(rw web.ResponseWriter, req *web.Request) {
+	restLogger.Info("REST invoking chaincode batch...")
 
-		if err != nil {
-			// Format the error appropriately for further processing
-			error := formatRPCError(ChaincodeInvokeError.Code, ChaincodeInvokeError.Message, fmt.Sprintf("Error when invoking chaincode: %s", err))
-			restLogger.Errorf("Error when invoking chaincode: %s", err)
+	encoder := json.NewEncoder(rw)
 
-			return error
+	var batch pb.ChaincodeInvocationBatch
+	if err := jsonpb.Unmarshal(req.Body, &batch); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		if err == io.EOF {
+			encoder.Encode(restResult{Error: "Payload must contain a ChaincodeInvocationBatch."})
+		} else {
+			encoder.Encode(restResult{Error: err.Error()})
 		}
+		restLogger.Errorf("Error decoding chaincode batch: %s", err)
 
-		//
-		// Invocation succeeded
-		//
-
-		// Clients will need the txuuid in order to track it after invocation, record it
-		txuuid := string(resp.Msg)
-
-		//
-		// Output correctly formatted response
-		//
-
-		result = formatRPCOK(txuuid)
-		// Make a clarification in the invoke response message, that the transaction has been successfully submitted but not completed
-		restLogger.Infof("Successfully submitted invoke transaction with txuuid (%s)", txuuid)
+		return
 	}
 
-	if method == "query" {
-
-		//
-		// Trigger the chaincode query through the devops service
-		//
-
-		resp, err := s.devops.Query(context.Background(), spec)
-
-		//
-		// Query failed
-		//
-
-		if err != nil {
-			// Format the error appropriately for further processing
-			error := formatRPCError(ChaincodeQueryError.Code, ChaincodeQueryError.Message, fmt.Sprintf("Error when querying chaincode: %s", err))
-			restLogger.Errorf("Error when querying chaincode: %s", err)
+	for i, spec := range batch.Invocations {
+		if spec.ChaincodeSpec == nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			encoder.Encode(restResult{Error: fmt.Sprintf("Invocation %d: Payload must contain a ChaincodeSpec.", i)})
+			restLogger.Errorf("Invocation %d of batch lacks a ChaincodeSpec", i)
 
-			return error
+			return
 		}
+		if failure := s.prepareInvocation(spec); failure != nil {
+			rw.WriteHeader(rpcErrorStatus(failure.Error))
+			encoder.Encode(restResult{Error: fmt.Sprintf("Invocation %d: %s", i, failure.Error.Data)})
 
-		//
-		// Query succeeded
-		//
+			return
+		}
+	}
 
-		// Clients will need the returned value, record it
-		val := string(resp.Msg)
+	resp, err := s.devops.InvokeBatch(clientContext(req), &batch)
+	if err != nil {
+		writeErrorStatus(rw, nil, err)
+		encoder.Encode(restResult{Error: err.Error()})
+		restLogger.Errorf("Error invoking chaincode batch: %s", err)
 
-		//
-		// Output correctly formatted response
-		//
+		return
+	}
 
-		result = formatRPCOK(val)
-		restLogger.Infof("Successfully queried chaincode: %s", val)
+	result := batchResult{Results: make([]batchItemResult, len(resp.Responses))}
+	for i, item := range resp.Responses {
+		if item.Status == pb.Response_SUCCESS {
+			result.Results[i] = batchItemResult{Status: item.Status.String(), TxID: string(item.Msg)}
+		} else {
+			result.Results[i] = batchItemResult{Status: item.Status.String(), Error: string(item.Msg)}
+		}
 	}
 
-	return result
+	rw.WriteHeader(http.StatusOK)
+	encoder.Encode(result)
+	restLogger.Infof("Submitted chaincode batch of %d invocations", len(resp.Responses))
 }
 
 // GetPeers returns a list of all peer nodes currently connected to the target peer, including itself
@@ -1709,6 +2251,52 @@ func (s *ServerOpenchainREST) GetPeers(rw web.ResponseWriter, req *web.Request)
 	}
 }
 
+// GetNetworkHealth returns the health of the validating network as assessed by
+// the target peer. An unhealthy network is reported with status 503.
+func (s *ServerOpenchainREST) GetNetworkHealth(rw web.ResponseWriter, req *web.Request) {
+	health, err := s.server.GetConsensusHealth(context.Background(), &google_protobuf.Empty{})
+
+	encoder := json.NewEncoder(rw)
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusBadRequest)
+		encoder.Encode(restResult{Error: err.Error()})
+		restLogger.Errorf("Error: Querying network health -- %s", err)
+	} else if !health.Healthy {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		encoder.Encode(health)
+	} else {
+		// Success
+		rw.WriteHeader(http.StatusOK)
+		encoder.Encode(health)
+	}
+}
+
+// GetNetworkMap returns the target peer's view of the network: the connected
+// peers with the details of their connection, and the known peers which are
+// not connected.
+//
+// Deprecated: use the /v1/Openchain/GetNetworkMap endpoint instead
+func (s *ServerOpenchainREST) GetNetworkMap(rw web.ResponseWriter, req *web.Request) {
+	networkMap, err := s.callGateway(clientContext(req), "Openchain.GetNetworkMap", &google_protobuf.Empty{})
+
+	encoder := json.NewEncoder(rw)
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusBadRequest)
+		encoder.Encode(restResult{Error: err.Error()})
+		restLogger.Errorf("Error: Querying network map -- %s", err)
+	} else {
+		// Success
+		rw.WriteHeader(http.StatusOK)
+		encoder.Encode(networkMap)
+	}
+}
+
 // NotFound returns a custom landing page when a given hyperledger end point
 // had not been defined.
 func (s *ServerOpenchainREST) NotFound(rw web.ResponseWriter, r *web.Request) {
@@ -1716,6 +2304,116 @@ func (s *ServerOpenchainREST) NotFound(rw web.ResponseWriter, r *web.Request) {
 	json.NewEncoder(rw).Encode(restResult{Error: "Openchain endpoint not found."})
 }
 
+// restRoutes is the route table of the REST API, from which its Swagger
+// specification is generated. New routes must be added here.
+var restRoutes = []restRoute{
+	{method: "POST", path: "/registrar", handler: (*ServerOpenchainREST).Register,
+		operationID: "registerUser", tag: "Registrar", summary: "Register a user with the certificate authority",
+		request: pb.Secret{}, response: restResult{}},
+	{method: "GET", path: "/registrar/:id", handler: (*ServerOpenchainREST).GetEnrollmentID, owner: "id",
+		operationID: "getUserRegistration", tag: "Registrar", summary: "Confirm the user has registered with the certificate authority",
+		params:   []restParam{{"id", "path", "string", "Username for which registration is to be confirmed"}},
+		response: restResult{}},
+	{method: "DELETE", path: "/registrar/:id", handler: (*ServerOpenchainREST).DeleteEnrollmentID, owner: "id",
+		operationID: "deleteUserRegistration", tag: "Registrar", summary: "Delete user login tokens from local storage",
+		params:   []restParam{{"id", "path", "string", "Username for which login tokens are to be deleted"}},
+		response: restResult{}},
+	{method: "GET", path: "/registrar/:id/ecert", handler: (*ServerOpenchainREST).GetEnrollmentCert, owner: "id",
+		operationID: "getUserEnrollmentCertificate", tag: "Registrar", summary: "Retrieve user enrollment certificate",
+		params:   []restParam{{"id", "path", "string", "EnrollmentID for which the certificate is requested"}},
+		response: restResult{}},
+	{method: "GET", path: "/registrar/:id/tcert", handler: (*ServerOpenchainREST).GetTransactionCert, owner: "id",
+		operationID: "getUserTransactionCertificate", tag: "Registrar", summary: "Retrieve user transaction certificates",
+		params: []restParam{
+			{"id", "path", "string", "EnrollmentID for which the certificate is requested"},
+			{"count", "query", "integer", "The desired number of transaction certificates, 1 by default and at most 500"},
+		},
+		response: tcertsResult{}},
+
+	// The routes below with a gateway are deprecated and superseded by the
+	// gateway routes of the RPCs they name
+	{method: "GET", path: "/chain", handler: (*ServerOpenchainREST).GetBlockchainInfo,
+		operationID: "getChain", tag: "Blockchain", summary: "Blockchain information",
+		deprecated: true, gateway: "Openchain.GetBlockchainInfo",
+		response: pb.BlockchainInfo{}},
+	{method: "GET", path: "/chain/blocks/:id", handler: (*ServerOpenchainREST).GetBlockByNumber,
+		operationID: "getBlock", tag: "Block", summary: "Individual block information",
+		deprecated: true, gateway: "Openchain.GetBlockByNumber",
+		params: []restParam{
+			{"id", "path", "integer", "Block number to retrieve, the genesis block being block zero"},
+			{"fields", "query", "string", "Comma separated list of the block fields to return, all of them by default"},
+		},
+		response: pb.Block{}},
+	{method: "GET", path: "/chain/blocks", handler: (*ServerOpenchainREST).GetBlocks,
+		operationID: "getBlocks", tag: "Block", summary: "Page of the blocks of the blockchain",
+		params: []restParam{
+			{"offset", "query", "integer", "Number of the first block of the page, 0 by default or the latest block in descending order"},
+			{"limit", "query", "integer", "Maximum number of blocks of the page, 10 by default and at most 100"},
+			{"order", "query", "string", "Either asc (default) or desc"},
+			{"fields", "query", "string", "Comma separated list of the block fields to return, all of them by default"},
+		},
+		response: blocksPage{}},
+	{method: "GET", path: "/chain/blocks/:id/transactions", handler: (*ServerOpenchainREST).GetBlockTransactions,
+		operationID: "getBlockTransactions", tag: "Block", summary: "Page of the transactions of a block",
+		params: []restParam{
+			{"id", "path", "integer", "Number of the block"},
+			{"offset", "query", "integer", "Index of the first matching transaction of the page, 0 by default"},
+			{"limit", "query", "integer", "Maximum number of transactions of the page, 10 by default and at most 100"},
+			{"type", "query", "string", "Transaction type to keep, e.g. CHAINCODE_INVOKE"},
+			{"chaincodeID", "query", "string", "Name or path of the chaincode whose transactions to keep"},
+			{"fields", "query", "string", "Comma separated list of the transaction fields to return, all of them by default"},
+		},
+		response: transactionsPage{}},
+
+	// The /devops endpoint is now considered deprecated and superseded by the /chaincode endpoint
+	{method: "POST", path: "/devops/deploy", handler: (*ServerOpenchainREST).Deploy,
+		operationID: "chaincodeDeploy", tag: "Chaincode", summary: "Service endpoint for deploying Chaincode", deprecated: true, gateway: "Devops.Deploy",
+		request: pb.ChaincodeSpec{}, response: restResult{}},
+	{method: "POST", path: "/devops/invoke", handler: (*ServerOpenchainREST).Invoke,
+		operationID: "chaincodeInvoke", tag: "Chaincode", summary: "Service endpoint for invoking Chaincode functions", deprecated: true, gateway: "Devops.Invoke",
+		request: pb.ChaincodeInvocationSpec{}, response: restResult{}},
+	{method: "POST", path: "/devops/query", handler: (*ServerOpenchainREST).Query,
+		operationID: "chaincodeQuery", tag: "Chaincode", summary: "Service endpoint for querying Chaincode state", deprecated: true, gateway: "Devops.Query",
+		request: pb.ChaincodeInvocationSpec{}, response: restResult{}},
+
+	// The /chaincode endpoint which superceedes the /devops endpoint from above
+	{method: "POST", path: "/chaincode", handler: (*ServerOpenchainREST).ProcessChaincode,
+		operationID: "chaincodeOp", tag: "Chaincode", summary: "Service endpoint for Chaincode operations",
+		request: rpcRequest{}, response: rpcResponse{}, failure: rpcResponse{}},
+	{method: "POST", path: "/chaincode/batch", handler: (*ServerOpenchainREST).ProcessChaincodeBatch,
+		operationID: "chaincodeInvokeBatch", tag: "Chaincode", summary: "Service endpoint for invoking Chaincode functions through a batch of transactions",
+		request: pb.ChaincodeInvocationBatch{}, response: batchResult{}},
+
+	{method: "GET", path: "/events/chaincode/:id", handler: (*ServerOpenchainREST).GetChaincodeEvents,
+		operationID: "getChaincodeEvents", tag: "Events", summary: "Stream of the events of a chaincode, as Server-Sent Events of type text/event-stream",
+		params: []restParam{
+			{"id", "path", "string", "Name of the chaincode"},
+			{"event", "query", "string", "Name of the events to stream, all of them by default"},
+		},
+		response: pb.ChaincodeEvent{}},
+
+	{method: "GET", path: "/transactions/:uuid", handler: (*ServerOpenchainREST).GetTransactionByUUID,
+		operationID: "getTransaction", tag: "Transactions", summary: "Individual transaction contents",
+		deprecated: true, gateway: "Openchain.GetTransaction",
+		params:   []restParam{{"uuid", "path", "string", "Transaction to retrieve from the blockchain"}},
+		response: pb.Transaction{}},
+	{method: "GET", path: "/transactions/:uuid/status", handler: (*ServerOpenchainREST).GetTransactionStatus,
+		operationID: "getTransactionStatus", tag: "Transactions", summary: "Status of a submitted transaction, queued, ordered, committed or rejected",
+		params:   []restParam{{"uuid", "path", "string", "Transaction whose status to retrieve"}},
+		response: txstatus.Status{}},
+
+	{method: "GET", path: "/network/peers", handler: (*ServerOpenchainREST).GetPeers,
+		operationID: "getPeers", tag: "Network", summary: "List of network peers",
+		response: pb.PeersMessage{}},
+	{method: "GET", path: "/network/health", handler: (*ServerOpenchainREST).GetNetworkHealth,
+		operationID: "getNetworkHealth", tag: "Network", summary: "Health of the validating network",
+		response: pb.ConsensusHealth{}},
+	{method: "GET", path: "/network/map", handler: (*ServerOpenchainREST).GetNetworkMap,
+		operationID: "getNetworkMap", tag: "Network", summary: "Target peer's view of the network",
+		deprecated: true, gateway: "Openchain.GetNetworkMap",
+		response: pb.NetworkMap{}},
+}
+
 func buildOpenchainRESTRouter() *web.Router {
 	router := web.New(ServerOpenchainREST{})
 
@@ -1724,26 +2422,32 @@ func buildOpenchainRESTRouter() *web.Router {
 	router.Middleware((*ServerOpenchainREST).SetResponseType)
 
 	// Add routes
-	router.Post("/registrar", (*ServerOpenchainREST).Register)
-	router.Get("/registrar/:id", (*ServerOpenchainREST).GetEnrollmentID)
-	router.Delete("/registrar/:id", (*ServerOpenchainREST).DeleteEnrollmentID)
-	router.Get("/registrar/:id/ecert", (*ServerOpenchainREST).GetEnrollmentCert)
-	router.Get("/registrar/:id/tcert", (*ServerOpenchainREST).GetTransactionCert)
-
-	router.Get("/chain", (*ServerOpenchainREST).GetBlockchainInfo)
-	router.Get("/chain/blocks/:id", (*ServerOpenchainREST).GetBlockByNumber)
-
-	// The /devops endpoint is now considered deprecated and superseded by the /chaincode endpoint
-	router.Post("/devops/deploy", (*ServerOpenchainREST).Deploy)
-	router.Post("/devops/invoke", (*ServerOpenchainREST).Invoke)
-	router.Post("/devops/query", (*ServerOpenchainREST).Query)
-
-	// The /chaincode endpoint which superceedes the /devops endpoint from above
-	router.Post("/chaincode", (*ServerOpenchainREST).ProcessChaincode)
+	for _, route := range restRoutes {
+		handler := route.handler
+		if (restTokenVerifier != nil || restCertAuthenticator != nil) && !route.public {
+			handler = authenticated(route)
+		}
+		if restLimits != nil && !route.unlimited {
+			handler = limited(route, handler)
+		}
+		if route.gateway != "" {
+			handler = deprecatedByGateway(route, handler)
+		}
 
-	router.Get("/transactions/:uuid", (*ServerOpenchainREST).GetTransactionByUUID)
+		switch route.method {
+		case "GET":
+			router.Get(route.path, handler)
+		case "POST":
+			router.Post(route.path, handler)
+		case "DELETE":
+			router.Delete(route.path, handler)
+		default:
+			panic(fmt.Errorf("Unsupported method %s of REST route %s", route.method, route.path))
+		}
+	}
 
-	router.Get("/network/peers", (*ServerOpenchainREST).GetPeers)
+	// Answer CORS preflight requests
+	router.OptionsHandler((*ServerOpenchainREST).Preflight)
 
 	// Add not found page
 	router.NotFound((*ServerOpenchainREST).NotFound)
@@ -1752,20 +2456,45 @@ func buildOpenchainRESTRouter() *web.Router {
 }
 
 // StartOpenchainRESTServer initializes the REST service and adds the required
-// middleware and routes.
-func StartOpenchainRESTServer(server *ServerOpenchain, devops *core.Devops) {
+// middleware and routes. health reports the health of the peer at /healthz
+// and /readyz.
+func StartOpenchainRESTServer(server *ServerOpenchain, devops *core.Devops, health HealthChecker) {
 	// Initialize the REST service object
-	restLogger.Infof("Initializing the REST service on %s, TLS is %s.", viper.GetString("rest.address"), (map[bool]string{true: "enabled", false: "disabled"})[comm.TLSEnabled()])
+	serviceTLS := comm.GetServiceTLS("rest.tls")
+	restLogger.Infof("Initializing the REST service on %s, TLS is %s.", viper.GetString("rest.address"), (map[bool]string{true: "enabled", false: "disabled"})[serviceTLS.Enabled])
 
 	// Record the pointer to the underlying ServerOpenchain and Devops objects.
 	serverOpenchain = server
 	serverDevops = devops
+	restHealth = health
+
+	var err error
+	if restTokenVerifier, err = newTokenVerifier(); err != nil {
+		restLogger.Errorf("Failed configuring the authentication of REST clients: %s", err)
+		return
+	}
+	if restCertAuthenticator, err = newCertAuthenticator(serviceTLS.Enabled); err != nil {
+		restLogger.Errorf("Failed configuring the client certificate authentication of REST clients: %s", err)
+		return
+	}
+	if restHeaders, err = newHeaderPolicy(serviceTLS.Enabled); err != nil {
+		restLogger.Errorf("Failed configuring the CORS and security headers of the REST service: %s", err)
+		return
+	}
+	if restLimits, err = newLimitPolicy(); err != nil {
+		restLogger.Errorf("Failed configuring the request limits of the REST service: %s", err)
+		return
+	}
 
 	router := buildOpenchainRESTRouter()
 
 	// Start server
-	if comm.TLSEnabled() {
-		err := http.ListenAndServeTLS(viper.GetString("rest.address"), viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"), router)
+	if serviceTLS.Enabled {
+		httpServer := &http.Server{Addr: viper.GetString("rest.address"), Handler: router}
+		if restCertAuthenticator != nil {
+			httpServer.TLSConfig = restCertAuthenticator.tlsConfig()
+		}
+		err := httpServer.ListenAndServeTLS(serviceTLS.CertFile, serviceTLS.KeyFile)
 		if err != nil {
 			restLogger.Errorf("ListenAndServeTLS: %s", err)
 		}
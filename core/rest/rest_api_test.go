@@ -17,19 +17,26 @@ limitations under the License.
 package rest
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"golang.org/x/net/context"
 
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/txstatus"
+	"github.com/hyperledger/fabric/events/producer"
 	"github.com/hyperledger/fabric/protos"
 )
 
@@ -122,6 +129,8 @@ func (d *mockDevops) Invoke(c context.Context, cis *protos.ChaincodeInvocationSp
 		return nil, fmt.Errorf("Invoke failure")
 	case "change_owner":
 		return &protos.Response{Status: protos.Response_SUCCESS, Msg: []byte("change_owner_invoke_result")}, nil
+	case "echo_metadata":
+		return &protos.Response{Status: protos.Response_SUCCESS, Msg: []byte(cis.ChaincodeSpec.MetadataEntries["order"])}, nil
 	}
 	return nil, fmt.Errorf("Unknown function invoked")
 }
@@ -136,6 +145,18 @@ func (d *mockDevops) Query(c context.Context, cis *protos.ChaincodeInvocationSpe
 	return nil, fmt.Errorf("Unknown query function")
 }
 
+func (d *mockDevops) InvokeBatch(c context.Context, batch *protos.ChaincodeInvocationBatch) (*protos.BatchResponse, error) {
+	resp := &protos.BatchResponse{}
+	for _, cis := range batch.Invocations {
+		r, err := d.Invoke(c, cis)
+		if err != nil {
+			r = &protos.Response{Status: protos.Response_FAILURE, Msg: []byte(err.Error())}
+		}
+		resp.Responses = append(resp.Responses, r)
+	}
+	return resp, nil
+}
+
 func (d *mockDevops) EXP_GetApplicationTCert(ctx context.Context, secret *protos.Secret) (*protos.Response, error) {
 	return nil, nil
 }
@@ -152,6 +173,14 @@ func (d *mockDevops) EXP_ExecuteWithBinding(ctx context.Context, executeWithBind
 	return nil, nil
 }
 
+func (d *mockDevops) BuildTransaction(ctx context.Context, request *protos.UnsignedTransactionRequest) (*protos.UnsignedTransaction, error) {
+	return nil, nil
+}
+
+func (d *mockDevops) SubmitTransaction(ctx context.Context, tx *protos.Transaction) (*protos.Response, error) {
+	return nil, nil
+}
+
 func initGlobalServerOpenchain(t *testing.T) {
 	var err error
 	serverOpenchain, err = NewOpenchainServerWithPeerInfo(new(peerInfo))
@@ -266,6 +295,142 @@ func TestServerOpenchainREST_API_GetBlockByNumber(t *testing.T) {
 	}
 }
 
+func TestServerOpenchainREST_API_GetBlocks(t *testing.T) {
+	// Construct a ledger with 3 blocks.
+	ledger := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger, t)
+
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	parsePage := func(body []byte) blocksPage {
+		var page blocksPage
+		if err := json.Unmarshal(body, &page); err != nil {
+			t.Fatalf("Invalid JSON response: %v", err)
+		}
+		return page
+	}
+
+	// First page of 2 blocks, with only their transactions
+	page := parsePage(performHTTPGet(t, httpServer.URL+"/chain/blocks?limit=2&fields=transactions"))
+	if page.Height != 3 || len(page.Blocks) != 2 {
+		t.Fatalf("Expected 2 blocks of a blockchain of height 3, got %v", page)
+	}
+	if page.Next == nil || *page.Next != 2 {
+		t.Fatalf("Expected the next page to start at block 2, got %v", page.Next)
+	}
+	if _, ok := page.Blocks[1]["transactions"]; !ok || len(page.Blocks[1]) != 2 {
+		t.Errorf("Expected only the block number and transactions, got %v", page.Blocks[1])
+	}
+
+	// Last page
+	page = parsePage(performHTTPGet(t, httpServer.URL+"/chain/blocks?limit=2&offset=2"))
+	if len(page.Blocks) != 1 || page.Next != nil {
+		t.Fatalf("Expected the last block only, got %v", page)
+	}
+	if page.Blocks[0]["number"].(float64) != 2 || page.Blocks[0]["transactions"] == nil {
+		t.Errorf("Expected the whole block 2, got %v", page.Blocks[0])
+	}
+
+	// Latest blocks first
+	page = parsePage(performHTTPGet(t, httpServer.URL+"/chain/blocks?order=desc"))
+	if len(page.Blocks) != 3 || page.Blocks[0]["number"].(float64) != 2 || page.Next != nil {
+		t.Errorf("Expected the 3 blocks from block 2, got %v", page)
+	}
+
+	// Illegal parameters
+	for _, query := range []string{"limit=0", "limit=x", "offset=-1", "order=random"} {
+		res := parseRESTResult(t, performHTTPGet(t, httpServer.URL+"/chain/blocks?"+query))
+		if res.Error == "" {
+			t.Errorf("Expected an error with the query %s, but got none", query)
+		}
+	}
+
+	// Transactions of block 2, filtered by chaincode
+	var txs transactionsPage
+	err := json.Unmarshal(performHTTPGet(t, httpServer.URL+"/chain/blocks/2/transactions?limit=1"), &txs)
+	if err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if len(txs.Transactions) != 1 || txs.Next == nil || *txs.Next != 1 {
+		t.Errorf("Expected the first of 2 transactions, got %v", txs)
+	}
+	var filtered transactionsPage
+	err = json.Unmarshal(performHTTPGet(t, httpServer.URL+"/chain/blocks/2/transactions?chaincodeID=MyOtherContract&fields=uuid"), &filtered)
+	if err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if len(filtered.Transactions) != 1 || filtered.Next != nil || len(filtered.Transactions[0]) != 1 {
+		t.Errorf("Expected the uuid of a single transaction, got %v", filtered)
+	}
+
+	res := parseRESTResult(t, performHTTPGet(t, httpServer.URL+"/chain/blocks/2/transactions?type=UNKNOWN"))
+	if res.Error == "" {
+		t.Errorf("Expected an error with an unknown transaction type, but got none")
+	}
+	res = parseRESTResult(t, performHTTPGet(t, httpServer.URL+"/chain/blocks/5/transactions"))
+	if res.Error == "" {
+		t.Errorf("Expected an error when retrieving the transactions of a non-existing block, but got none")
+	}
+}
+
+func TestServerOpenchainREST_API_GetChaincodeEvents(t *testing.T) {
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	// Without event hub
+	response, err := http.Get(httpServer.URL + "/events/chaincode/mycc")
+	if err != nil {
+		t.Fatalf("Error attempt to GET the chaincode events: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 without event hub, got %d", response.StatusCode)
+	}
+
+	producer.NewEventsServer(10, 0)
+
+	response, err = http.Get(httpServer.URL + "/events/chaincode/mycc?event=transfer")
+	if err != nil {
+		t.Fatalf("Error attempt to GET the chaincode events: %v", err)
+	}
+	defer response.Body.Close()
+	if response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %s", response.Header.Get("Content-Type"))
+	}
+
+	// The stream is subscribed once its headers are received
+	producer.Send(producer.CreateChaincodeEvent(&protos.ChaincodeEvent{ChaincodeID: "mycc", TxID: "tx1", EventName: "other"}))
+	producer.Send(producer.CreateChaincodeEvent(&protos.ChaincodeEvent{ChaincodeID: "mycc", TxID: "tx2", EventName: "transfer", Payload: []byte("payload")}))
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	expected := []string{"id: tx2", "event: transfer", `data: {"chaincodeID":"mycc","txID":"tx2","eventName":"transfer","payload":"cGF5bG9hZA=="}`, ""}
+	for _, line := range expected {
+		select {
+		case received := <-lines:
+			if received != line {
+				t.Fatalf("Expected the line %q of the event stream, got %q", line, received)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for the line %q of the event stream", line)
+		}
+	}
+}
+
 func TestServerOpenchainREST_API_GetTransactionByUUID(t *testing.T) {
 	startTime := time.Now().Unix()
 
@@ -311,6 +476,45 @@ func TestServerOpenchainREST_API_GetTransactionByUUID(t *testing.T) {
 	}
 }
 
+func TestServerOpenchainREST_API_GetTransactionStatus(t *testing.T) {
+	// Construct a ledger with 3 blocks.
+	ledger := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger, t)
+
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	body := performHTTPGet(t, httpServer.URL+"/transactions/NON-EXISTING-UUID/status")
+	if res := parseRESTResult(t, body); res.Error == "" {
+		t.Errorf("Expected an error when retrieving the status of a non-existing transaction, but got none")
+	}
+
+	block2, err := ledger.GetBlockByNumber(2)
+	if err != nil {
+		t.Fatalf("Can't fetch second block from ledger: %v", err)
+	}
+	var status txstatus.Status
+	body = performHTTPGet(t, httpServer.URL+"/transactions/"+block2.Transactions[0].Uuid+"/status")
+	if err = json.Unmarshal(body, &status); err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if status.State != txstatus.Committed || status.Block == nil || *status.Block != 2 {
+		t.Errorf("Expected the transaction committed in block 2 but got %#v", status)
+	}
+
+	txstatus.Default().Rejected("REJECTED-UUID", "Invalid signature")
+	body = performHTTPGet(t, httpServer.URL+"/transactions/REJECTED-UUID/status")
+	if err = json.Unmarshal(body, &status); err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if status.State != txstatus.Rejected || status.Reason != "Invalid signature" {
+		t.Errorf("Expected the transaction rejected but got %#v", status)
+	}
+}
+
 func TestServerOpenchainREST_API_Register(t *testing.T) {
 	os.RemoveAll(getRESTFilePath())
 	initGlobalServerOpenchain(t)
@@ -700,6 +904,62 @@ func TestServerOpenchainREST_API_Chaincode_Invoke(t *testing.T) {
 	if res.Result.Message != "change_owner_invoke_result" {
 		t.Errorf("Expected 'change_owner_invoke_result' but got '%v'", res.Result.Message)
 	}
+
+	// Test invoke with metadata entries
+	httpResponse, body = performHTTPPost(t, httpServer.URL+"/chaincode", []byte(`{"jsonrpc":"2.0","ID":123,"method":"invoke","params":{"type":1,"chaincodeID":{"name":"dummy"},"ctorMsg":{"function":"echo_metadata","args":[]},"secureContext":"myuser","metadataEntries":{"order":"42"}}}`))
+	if httpResponse.StatusCode != http.StatusOK {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusOK, httpResponse.StatusCode)
+	}
+	res = parseRPCResponse(t, body)
+	if res.Error != nil {
+		t.Errorf("Expected success but got %#v", res.Error)
+	}
+	if res.Result.Message != "42" {
+		t.Errorf("Expected the metadata entry '42' but got '%v'", res.Result.Message)
+	}
+}
+
+func TestServerOpenchainREST_API_Chaincode_InvokeBatch(t *testing.T) {
+	// Construct a ledger with 3 blocks.
+	ledger := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger, t)
+
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	// Login
+	performHTTPPost(t, httpServer.URL+"/registrar", []byte(`{"enrollId":"myuser","enrollSecret":"password"}`))
+
+	// Test a batch with an invocation lacking a function
+	httpResponse, body := performHTTPPost(t, httpServer.URL+"/chaincode/batch", []byte(`{"invocations":[{"chaincodeSpec":{"type":1,"chaincodeID":{"name":"dummy"},"ctorMsg":{"function":"change_owner"},"secureContext":"myuser"}},{"chaincodeSpec":{"type":1,"chaincodeID":{"name":"dummy"},"secureContext":"myuser"}}]}`))
+	if httpResponse.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusBadRequest, httpResponse.StatusCode)
+	}
+	if res := parseRESTResult(t, body); !strings.HasPrefix(res.Error, "Invocation 1:") {
+		t.Errorf("Expected an error about invocation 1 but got '%v'", res.Error)
+	}
+
+	// Test a batch of a succeeding and a failing invocation
+	httpResponse, body = performHTTPPost(t, httpServer.URL+"/chaincode/batch", []byte(`{"invocations":[{"chaincodeSpec":{"type":1,"chaincodeID":{"name":"dummy"},"ctorMsg":{"function":"change_owner"},"secureContext":"myuser"}},{"chaincodeSpec":{"type":1,"chaincodeID":{"name":"dummy"},"ctorMsg":{"function":"fail"},"secureContext":"myuser"}}]}`))
+	if httpResponse.StatusCode != http.StatusOK {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusOK, httpResponse.StatusCode)
+	}
+	var res batchResult
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if len(res.Results) != 2 {
+		t.Fatalf("Expected 2 results but got %d", len(res.Results))
+	}
+	if res.Results[0].Status != "SUCCESS" || res.Results[0].TxID != "change_owner_invoke_result" {
+		t.Errorf("Expected the first invocation to succeed but got %#v", res.Results[0])
+	}
+	if res.Results[1].Status != "FAILURE" || res.Results[1].Error != "Invoke failure" {
+		t.Errorf("Expected the second invocation to fail but got %#v", res.Results[1])
+	}
 }
 
 func TestServerOpenchainREST_API_Chaincode_Query(t *testing.T) {
@@ -766,6 +1026,150 @@ func TestServerOpenchainREST_API_Chaincode_Query(t *testing.T) {
 	}
 }
 
+func TestServerOpenchainREST_API_TokenAuth(t *testing.T) {
+	initGlobalServerOpenchain(t)
+
+	restTokenVerifier = &tokenVerifier{issuer: "https://issuer", claim: "sub", secret: []byte("secret")}
+	defer func() { restTokenVerifier = nil }()
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	myuser := hmacToken(t, "secret", map[string]interface{}{"iss": "https://issuer", "sub": "myuser", "exp": exp})
+	other := hmacToken(t, "secret", map[string]interface{}{"iss": "https://issuer", "sub": "other", "exp": exp})
+
+	post := func(url, token string, body string) (*http.Response, []byte) {
+		req, _ := http.NewRequest("POST", httpServer.URL+url, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		response, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error attempt to POST %s: %v", url, err)
+		}
+		defer response.Body.Close()
+		respBody, _ := ioutil.ReadAll(response.Body)
+		return response, respBody
+	}
+	query := `{"jsonrpc":"2.0","ID":123,"method":"query","params":{"type":1,"chaincodeID":{"name":"dummy"},"ctorMsg":{"function":"get_owner","args":[]}%s}}`
+
+	// Without or with an invalid token
+	response, _ := post("/chaincode", "", fmt.Sprintf(query, ""))
+	if response.StatusCode != http.StatusUnauthorized || response.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected status 401 without token, got %d", response.StatusCode)
+	}
+	response, _ = post("/chaincode", hmacToken(t, "wrong", map[string]interface{}{"iss": "https://issuer", "sub": "myuser", "exp": exp}), fmt.Sprintf(query, ""))
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with an invalid token, got %d", response.StatusCode)
+	}
+
+	// The secure context is that of the token
+	_, body := post("/chaincode", myuser, fmt.Sprintf(query, ""))
+	res := parseRPCResponse(t, body)
+	if res.Error != nil || res.Result.Message != "get_owner_query_result" {
+		t.Errorf("Expected the query of myuser to succeed, got %s", body)
+	}
+	_, body = post("/chaincode", other, fmt.Sprintf(query, `,"secureContext":"myuser"`))
+	res = parseRPCResponse(t, body)
+	if res.Error == nil || res.Error.Code != UnauthorizedError.Code {
+		t.Errorf("Expected another user not to act as myuser, got %s", body)
+	}
+
+	// Registrar entries of other users
+	req, _ := http.NewRequest("GET", httpServer.URL+"/registrar/myuser", nil)
+	req.Header.Set("Authorization", "Bearer "+other)
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error attempt to GET /registrar/myuser: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403 for the registration of another user, got %d", response.StatusCode)
+	}
+	response, _ = post("/registrar", other, `{"enrollId":"myuser","enrollSecret":"password"}`)
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403 when logging in another user, got %d", response.StatusCode)
+	}
+
+	// The specification is public
+	response, err = http.Get(httpServer.URL + "/swagger.json")
+	if err != nil {
+		t.Fatalf("Error attempt to GET /swagger.json: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected the specification to be served without token, got %d", response.StatusCode)
+	}
+}
+
+func TestServerOpenchainREST_API_CertAuth(t *testing.T) {
+	initGlobalServerOpenchain(t)
+
+	ca := issueCert(t, nil, "ca", "")
+	restCertAuthenticator = &certAuthenticator{roots: x509.NewCertPool(), field: "commonName"}
+	restCertAuthenticator.roots.AddCert(ca.Leaf)
+	defer func() { restCertAuthenticator = nil }()
+
+	// Start the HTTPS REST test server
+	httpServer := httptest.NewUnstartedServer(buildOpenchainRESTRouter())
+	httpServer.TLS = restCertAuthenticator.tlsConfig()
+	httpServer.StartTLS()
+	defer httpServer.Close()
+
+	post := func(url string, cert *tls.Certificate, body string) *http.Response {
+		config := &tls.Config{InsecureSkipVerify: true}
+		if cert != nil {
+			config.Certificates = []tls.Certificate{*cert}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		response, err := client.Post(httpServer.URL+url, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Error attempt to POST %s: %v", url, err)
+		}
+		return response
+	}
+	query := `{"jsonrpc":"2.0","ID":123,"method":"query","params":{"type":1,"chaincodeID":{"name":"dummy"},"ctorMsg":{"function":"get_owner","args":[]}%s}}`
+
+	// Without certificate
+	response := post("/chaincode", nil, fmt.Sprintf(query, ""))
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without certificate, got %d", response.StatusCode)
+	}
+
+	// The secure context is that of the certificate
+	myuser := issueCert(t, &ca, "myuser", "")
+	post("/registrar", &myuser, `{"enrollId":"myuser","enrollSecret":"password"}`).Body.Close()
+	response = post("/chaincode", &myuser, fmt.Sprintf(query, ""))
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	res := parseRPCResponse(t, body)
+	if res.Error != nil || res.Result.Message != "get_owner_query_result" {
+		t.Errorf("Expected the query of myuser to succeed, got %s", body)
+	}
+	other := issueCert(t, &ca, "other", "")
+	response = post("/chaincode", &other, fmt.Sprintf(query, `,"secureContext":"myuser"`))
+	body, _ = ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	res = parseRPCResponse(t, body)
+	if res.Error == nil || res.Error.Code != UnauthorizedError.Code {
+		t.Errorf("Expected another user not to act as myuser, got %s", body)
+	}
+
+	// Certificates of another issuer are not accepted
+	untrusted := issueCert(t, nil, "myuser", "")
+	config := &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{untrusted}}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	if response, err := client.Post(httpServer.URL+"/chaincode", "application/json", strings.NewReader(fmt.Sprintf(query, ""))); err == nil {
+		response.Body.Close()
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected a certificate of another issuer to be refused, got %d", response.StatusCode)
+		}
+	}
+}
+
 func TestServerOpenchainREST_API_NotFound(t *testing.T) {
 	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
 	defer httpServer.Close()
@@ -775,3 +1179,44 @@ func TestServerOpenchainREST_API_NotFound(t *testing.T) {
 		t.Errorf("Expected an error when accessing non-existing endpoint, but got %#v", res.Error)
 	}
 }
+
+func TestServerOpenchainREST_API_GetSwagger(t *testing.T) {
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	body := performHTTPGet(t, httpServer.URL+"/swagger.json")
+	var spec struct {
+		Swagger     string
+		Host        string
+		Paths       map[string]map[string]json.RawMessage
+		Definitions map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(body, &spec); err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if spec.Swagger != "2.0" || spec.Host != strings.TrimPrefix(httpServer.URL, "http://") {
+		t.Errorf("Unexpected Swagger version %s or host %s", spec.Swagger, spec.Host)
+	}
+
+	for _, route := range restRoutes {
+		path := routePathParam.ReplaceAllString(route.path, "{$1}")
+		if _, ok := spec.Paths[path][strings.ToLower(route.method)]; !ok {
+			t.Errorf("Expected the route %s %s to be described as %s", route.method, route.path, path)
+		}
+	}
+	if _, ok := spec.Paths["/chain/blocks/{id}"]["get"]; !ok {
+		t.Error("Expected the blocks route to be described")
+	}
+
+	// Every referenced definition is defined
+	for _, ref := range regexp.MustCompile(`"#/definitions/([A-Za-z0-9_]+)"`).FindAllStringSubmatch(string(body), -1) {
+		if _, ok := spec.Definitions[ref[1]]; !ok {
+			t.Errorf("Undefined definition %s", ref[1])
+		}
+	}
+	for _, name := range []string{"Block", "Transaction", "ChaincodeSpec", "RpcRequest", "RestResult"} {
+		if _, ok := spec.Definitions[name]; !ok {
+			t.Errorf("Expected the definition of %s", name)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gocraft/web"
+	"github.com/golang/protobuf/proto"
+)
+
+// restParam describes a path or query parameter of a REST route.
+type restParam struct {
+	name        string
+	in          string // path or query
+	typ         string // a Swagger type: string, integer, ...
+	description string
+}
+
+// restRoute is an entry of the route table, from which both the router and
+// the Swagger specification of the REST API are built. request and response
+// are values of the types of the request and response bodies, nil if there
+// is none.
+type restRoute struct {
+	method      string
+	path        string
+	handler     func(*ServerOpenchainREST, web.ResponseWriter, *web.Request)
+	operationID string
+	tag         string
+	summary     string
+	deprecated  bool
+	params      []restParam
+	request     interface{}
+	response    interface{}
+	// The body of failed responses, a restResult unless set
+	failure interface{}
+	// Served without authentication
+	public bool
+	// The path parameter that must be the enrollment ID of the client
+	// authenticated by its token
+	owner string
+	// Served without the limits of rest.limits
+	unlimited bool
+	// The gateway RPC superseding this deprecated route, as Service.Method,
+	// through which its requests are served
+	gateway string
+}
+
+// routePathParam matches the parameters of the router paths, e.g. :id
+var routePathParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+func init() {
+	// Added here as GetSwagger itself reads the route table
+	restRoutes = append(restRoutes, restRoute{method: "GET", path: "/swagger.json", handler: (*ServerOpenchainREST).GetSwagger,
+		operationID: "getSwagger", tag: "API", summary: "Swagger 2.0 specification of this API", public: true})
+}
+
+// GetSwagger returns the Swagger 2.0 specification of the REST API, built
+// from the route table so that it describes the routes actually served.
+func (s *ServerOpenchainREST) GetSwagger(rw web.ResponseWriter, req *web.Request) {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	json.NewEncoder(rw).Encode(buildSwagger(restRoutes, req.Host, scheme, restTokenVerifier != nil))
+}
+
+// buildSwagger returns the Swagger 2.0 specification of routes, served by
+// host with scheme, requiring bearer tokens if auth is set.
+func buildSwagger(routes []restRoute, host, scheme string, auth bool) map[string]interface{} {
+	definitions := make(map[string]interface{})
+	paths := make(map[string]map[string]interface{})
+
+	for _, route := range routes {
+		path := routePathParam.ReplaceAllString(route.path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+
+		parameters := []interface{}{}
+		for _, param := range route.params {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        param.name,
+				"in":          param.in,
+				"type":        param.typ,
+				"description": param.description,
+				"required":    param.in == "path",
+			})
+		}
+		if route.request != nil {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     "body",
+				"in":       "body",
+				"required": true,
+				"schema":   swaggerSchema(reflect.TypeOf(route.request), definitions),
+			})
+		}
+
+		failure := route.failure
+		if failure == nil {
+			failure = restResult{}
+		}
+		responses := map[string]interface{}{
+			"default": map[string]interface{}{
+				"description": "Unexpected error",
+				"schema":      swaggerSchema(reflect.TypeOf(failure), definitions),
+			},
+		}
+		success := map[string]interface{}{"description": route.summary}
+		if route.response != nil {
+			success["schema"] = swaggerSchema(reflect.TypeOf(route.response), definitions)
+		}
+		responses["200"] = success
+
+		operation := map[string]interface{}{
+			"summary":     route.summary,
+			"tags":        []string{route.tag},
+			"operationId": route.operationID,
+			"parameters":  parameters,
+			"responses":   responses,
+		}
+		if route.deprecated {
+			operation["deprecated"] = true
+		}
+		if route.gateway != "" {
+			operation["description"] = fmt.Sprintf("Deprecated, superseded by %s of the REST gateway.", gatewayPath(route.gateway))
+		}
+		if auth && !route.public {
+			operation["security"] = []interface{}{map[string]interface{}{"bearer": []string{}}}
+		}
+		paths[path][strings.ToLower(route.method)] = operation
+	}
+
+	spec := map[string]interface{}{
+		"swagger": "2.0",
+		"info": map[string]interface{}{
+			"title":       "Hyperledger Fabric API",
+			"description": "Interact with the enterprise blockchain through Hyperledger Fabric API",
+			"version":     "1.0.0",
+		},
+		"host":        host,
+		"schemes":     []string{scheme},
+		"consumes":    []string{"application/json"},
+		"produces":    []string{"application/json"},
+		"paths":       paths,
+		"definitions": definitions,
+	}
+	if auth {
+		spec["securityDefinitions"] = map[string]interface{}{
+			"bearer": map[string]interface{}{
+				"type":        "apiKey",
+				"name":        "Authorization",
+				"in":          "header",
+				"description": "A JSON Web Token of the trusted issuer, as Bearer <token>",
+			},
+		}
+	}
+	return spec
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// swaggerSchema returns the schema of the JSON encoding of values of type t,
+// adding the definitions of the structs it refers to to definitions.
+func swaggerSchema(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		// Custom encoding, any value
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return swaggerSchema(t.Elem(), definitions)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		schema := map[string]interface{}{"type": "integer", "format": "int32"}
+		if values := enumValues(t); values != "" {
+			schema["description"] = values
+		}
+		return schema
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "uint64"}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": swaggerSchema(t.Elem(), definitions)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": swaggerSchema(t.Elem(), definitions)}
+	case reflect.Struct:
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		ref := map[string]interface{}{"$ref": "#/definitions/" + name}
+		if _, ok := definitions[name]; ok {
+			return ref
+		}
+		// Registered before the fields, for recursive types
+		definitions[name] = nil
+
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			tag := strings.Split(field.Tag.Get("json"), ",")[0]
+			if tag == "-" {
+				continue
+			}
+			if tag == "" {
+				tag = field.Name
+			}
+			properties[tag] = swaggerSchema(field.Type, definitions)
+		}
+		definitions[name] = map[string]interface{}{"type": "object", "properties": properties}
+		return ref
+	default:
+		// Interfaces, such as the oneof fields of protocol buffers
+		return map[string]interface{}{"type": "object"}
+	}
+}
+
+// enumValues describes the values of t if it is a protocol buffer enum
+func enumValues(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	values := proto.EnumValueMap(pkg + "." + t.Name())
+	if len(values) == 0 {
+		return ""
+	}
+
+	names := make(map[int]string)
+	var numbers []int
+	for name, number := range values {
+		names[int(number)] = name
+		numbers = append(numbers, int(number))
+	}
+	sort.Ints(numbers)
+
+	var described []string
+	for _, number := range numbers {
+		described = append(described, fmt.Sprintf("%s (%d)", names[number], number))
+	}
+	return "One of " + strings.Join(described, ", ")
+}
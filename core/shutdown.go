@@ -0,0 +1,79 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/core/peer"
+)
+
+// Shutdown stops the peer in order, whether the stop was requested by a
+// signal or through the admin service: the peer refuses new transactions,
+// waits for the in-flight ones, flushes the event hub, stops consensus and
+// closes its servers, as set up by the steps added in that order. The steps
+// run once, later stops wait for the first one to complete.
+type Shutdown struct {
+	once  sync.Once
+	steps []shutdownStep
+}
+
+type shutdownStep struct {
+	name string
+	run  func(deadline time.Time)
+}
+
+// AddStep appends a step to the shutdown. Steps waiting for work to complete
+// give up at the deadline shared by the whole shutdown.
+func (s *Shutdown) AddStep(name string, step func(deadline time.Time)) {
+	s.steps = append(s.steps, shutdownStep{name: name, run: step})
+}
+
+// Run runs the steps in order, giving the in-flight work up to timeout to
+// complete
+func (s *Shutdown) Run(timeout time.Duration) {
+	s.once.Do(func() {
+		deadline := time.Now().Add(timeout)
+		log.Infof("Shutting down, waiting up to %s for in-flight work", timeout)
+		for _, step := range s.steps {
+			log.Infof("Shutdown: %s", step.name)
+			step.run(deadline)
+		}
+		log.Info("Shutdown complete")
+	})
+}
+
+// WaitPending waits until consensus has no outstanding requests or the
+// deadline passes, and returns the number still outstanding
+func WaitPending(coord peer.MessageHandlerCoordinator, deadline time.Time) uint64 {
+	reporter, ok := coord.(peer.HealthReporter)
+	if !ok || !peer.ValidatorEnabled() {
+		return 0
+	}
+	for {
+		health, err := reporter.GetConsensusHealth()
+		if err != nil {
+			log.Warningf("Could not tell whether transactions are pending: %s", err)
+			return 0
+		}
+		if health.Outstanding == 0 || !time.Now().Before(deadline) {
+			return health.Outstanding
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownRunsStepsInOrderOnce(t *testing.T) {
+	shutdown := &Shutdown{}
+	var steps []string
+	var deadlines []time.Time
+	for _, name := range []string{"drain", "flush", "stop"} {
+		name := name
+		shutdown.AddStep(name, func(deadline time.Time) {
+			steps = append(steps, name)
+			deadlines = append(deadlines, deadline)
+		})
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			shutdown.Run(time.Minute)
+		}()
+	}
+	wg.Wait()
+
+	if len(steps) != 3 || steps[0] != "drain" || steps[1] != "flush" || steps[2] != "stop" {
+		t.Fatalf("Expected the steps to run once in order, ran %v", steps)
+	}
+	for _, deadline := range deadlines {
+		if deadline != deadlines[0] || deadline.Before(start.Add(time.Minute)) {
+			t.Errorf("Expected the steps to share the deadline a minute ahead, got %v", deadlines)
+		}
+	}
+}
+
+func TestWaitPendingWithoutConsensus(t *testing.T) {
+	if pending := WaitPending(nil, time.Now().Add(time.Minute)); pending != 0 {
+		t.Errorf("Expected nothing to be pending without a coordinator, got %d", pending)
+	}
+}
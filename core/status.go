@@ -0,0 +1,60 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package core
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/hyperledger/fabric/core/peer"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// RegisterStatusSource adds a subsystem to the node status, source fills in
+// the state of the subsystem. The sources are called for every status request
+// and should not block.
+func (s *ServerAdmin) RegisterStatusSource(source func(status *pb.NodeStatus)) {
+	s.statusSources = append(s.statusSources, source)
+}
+
+// aggregateStatus adds the consensus state, the resources used by the node and
+// the state of the registered subsystems to status
+func (s *ServerAdmin) aggregateStatus(status *pb.NodeStatus) {
+	status.Resources = resourceUsage()
+	if reporter, ok := s.coord.(peer.HealthReporter); ok && peer.ValidatorEnabled() {
+		if health, err := reporter.GetConsensusHealth(); err == nil {
+			status.Consensus = health
+		} else {
+			log.Debugf("Consensus health unavailable for the node status: %s", err)
+		}
+	}
+	for _, source := range s.statusSources {
+		source(status)
+	}
+}
+
+// resourceUsage returns the resources used by the process
+func resourceUsage() *pb.ResourceUsage {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return &pb.ResourceUsage{
+		Goroutines:     int32(runtime.NumGoroutine()),
+		HeapAllocBytes: mem.HeapAlloc,
+		SysBytes:       mem.Sys,
+		GcCycles:       mem.NumGC,
+		GcPauseMicros:  mem.PauseTotalNs / uint64(time.Microsecond),
+	}
+}
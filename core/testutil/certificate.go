@@ -0,0 +1,80 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testutil holds the fixtures shared by the tests of several packages
+package testutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// NewKey returns a new ECDSA P-256 key
+func NewKey(t testing.TB) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating a key: %s", err)
+	}
+	return key
+}
+
+// NewCertificate returns a certificate of key following template, issued by
+// parent with parentKey, or self-signed if parent is nil. The serial number
+// defaults to the current time, the validity to an hour either side of it.
+func NewCertificate(t testing.TB, template *x509.Certificate, key crypto.Signer, parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+	tmpl := *template
+	if tmpl.SerialNumber == nil {
+		tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	}
+	if tmpl.NotBefore.IsZero() {
+		tmpl.NotBefore = time.Now().Add(-time.Hour)
+	}
+	if tmpl.NotAfter.IsZero() {
+		tmpl.NotAfter = time.Now().Add(time.Hour)
+	}
+	if parent == nil {
+		parent, parentKey = &tmpl, key
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, &tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatalf("Error creating a certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatalf("Error parsing a certificate: %s", err)
+	}
+	return cert
+}
+
+// NewCA returns a new key and a self-signed CA certificate of it for
+// commonName
+func NewCA(t testing.TB, commonName string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key := NewKey(t)
+	cert := NewCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, key, nil, nil)
+	return cert, key
+}
@@ -0,0 +1,265 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/metrics"
+)
+
+const (
+	// exportQueueSize bounds the spans waiting to be exported, beyond which
+	// they are dropped rather than slowing the transactions down
+	exportQueueSize = 2048
+	// exportBatchSize is the maximum number of spans exported at once
+	exportBatchSize = 256
+)
+
+var (
+	spansExported = metrics.NewCounter("fabric_tracing_spans_exported_total",
+		"Spans exported to the trace collector.")
+	spansDropped = metrics.NewCounter("fabric_tracing_spans_dropped_total",
+		"Spans dropped as the export queue was full or the trace collector failed.")
+)
+
+// The exporter is started with the first span finished
+var exporter struct {
+	once    sync.Once
+	started int32
+	spans   chan *Span
+	flush   chan chan struct{}
+}
+
+func export(s *Span) {
+	exporter.once.Do(startExporter)
+	select {
+	case exporter.spans <- s:
+	default:
+		spansDropped.Inc()
+	}
+}
+
+func startExporter() {
+	exporter.spans = make(chan *Span, exportQueueSize)
+	exporter.flush = make(chan chan struct{})
+	e := &otlpExporter{
+		endpoint: viper.GetString("peer.tracing.endpoint"),
+		client:   &http.Client{Timeout: viper.GetDuration("peer.tracing.timeout")},
+		resource: otlpResource{Attributes: []otlpAttribute{
+			stringAttribute("service.name", viper.GetString("peer.tracing.serviceName")),
+			stringAttribute("service.instance.id", viper.GetString("peer.id")),
+		}},
+	}
+	interval := viper.GetDuration("peer.tracing.batchTimeout")
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	logger.Infof("Exporting the spans of the transactions to %s", e.endpoint)
+	go e.run(interval)
+	atomic.StoreInt32(&exporter.started, 1)
+}
+
+// Flush exports the spans finished so far, and waits until they are
+// exported or the deadline passes
+func Flush(deadline time.Time) {
+	if atomic.LoadInt32(&exporter.started) == 0 {
+		return
+	}
+	done := make(chan struct{})
+	timeout := time.NewTimer(deadline.Sub(time.Now()))
+	defer timeout.Stop()
+	select {
+	case exporter.flush <- done:
+	case <-timeout.C:
+		return
+	}
+	select {
+	case <-done:
+	case <-timeout.C:
+	}
+}
+
+// otlpExporter exports spans to an OTLP/HTTP collector, e.g. the
+// OpenTelemetry collector or Jaeger, at endpoint, e.g.
+// http://localhost:4318/v1/traces
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+	resource otlpResource
+}
+
+func (e *otlpExporter) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var batch []*Span
+	for {
+		select {
+		case s := <-exporter.spans:
+			if batch = append(batch, s); len(batch) >= exportBatchSize {
+				e.send(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) != 0 {
+				e.send(batch)
+				batch = nil
+			}
+		case done := <-exporter.flush:
+			for queued := len(exporter.spans); queued > 0; queued-- {
+				batch = append(batch, <-exporter.spans)
+			}
+			for len(batch) != 0 {
+				n := len(batch)
+				if n > exportBatchSize {
+					n = exportBatchSize
+				}
+				e.send(batch[:n])
+				batch = batch[n:]
+			}
+			batch = nil
+			close(done)
+		}
+	}
+}
+
+func (e *otlpExporter) send(batch []*Span) {
+	if err := e.post(batch); err != nil {
+		logger.Warningf("Error exporting %d spans to %s: %s", len(batch), e.endpoint, err)
+		spansDropped.Add(float64(len(batch)))
+		return
+	}
+	spansExported.Add(float64(len(batch)))
+}
+
+func (e *otlpExporter) post(batch []*Span) error {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = newOTLPSpan(s)
+	}
+	body, err := json.Marshal(&otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource:   e.resource,
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/hyperledger/fabric"}, Spans: spans}},
+	}}})
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Collector answered %s", resp.Status)
+	}
+	return nil
+}
+
+// The messages of the JSON encoding of OTLP, see
+// https://github.com/open-telemetry/opentelemetry-proto
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              Kind            `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// otlpStatus is the status of a span, unset unless it failed
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+const otlpStatusError = 2
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: value}}
+}
+
+func newOTLPSpan(s *Span) otlpSpan {
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.context.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.context.SpanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	keys := make([]string, 0, len(s.attributes))
+	for key := range s.attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		span.Attributes = append(span.Attributes, stringAttribute(key, s.attributes[key]))
+	}
+	if s.err != "" {
+		span.Status = otlpStatus{Code: otlpStatusError, Message: s.err}
+	}
+	return span
+}
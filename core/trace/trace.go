@@ -0,0 +1,235 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package trace traces the lifecycle of transactions, from their submission
+// through their ordering, execution and commit, to the emission of their
+// events, so that a slow transaction can be attributed to one stage. The
+// spans are exported to a collector over OTLP, the OpenTelemetry protocol,
+// in its HTTP/JSON encoding. The trace context of a transaction travels with
+// it in the W3C traceparent format, so that the spans of all the peers
+// processing it belong to the same trace.
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+var logger = logging.MustGetLogger("trace")
+
+// Header is the name of the HTTP header and gRPC metadata key carrying the
+// trace context of a request
+const Header = "traceparent"
+
+// SpanContext identifies a span and the trace it belongs to
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	// Sampled tells whether the spans of the trace are recorded
+	Sampled bool
+}
+
+// IsValid tells whether c identifies a span
+func (c SpanContext) IsValid() bool {
+	return c.TraceID != [16]byte{} && c.SpanID != [8]byte{}
+}
+
+// String formats c as a W3C traceparent, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (c SpanContext) String() string {
+	flags := "00"
+	if c.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(c.TraceID[:]) + "-" + hex.EncodeToString(c.SpanID[:]) + "-" + flags
+}
+
+// ParseSpanContext parses a W3C traceparent. It returns false if
+// traceparent is not a valid one.
+func ParseSpanContext(traceparent string) (SpanContext, bool) {
+	var c SpanContext
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return c, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return c, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return c, false
+	}
+	if _, err = hex.Decode(c.TraceID[:], []byte(parts[1])); err != nil {
+		return c, false
+	}
+	if _, err = hex.Decode(c.SpanID[:], []byte(parts[2])); err != nil {
+		return c, false
+	}
+	c.Sampled = flags[0]&1 == 1
+	return c, c.IsValid()
+}
+
+type spanContextKey struct{}
+
+// NewContext returns a context carrying the trace context of a request which
+// did not arrive over gRPC, e.g. a REST request
+func NewContext(ctx context.Context, c SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, c)
+}
+
+// FromContext returns the trace context of the request of ctx, given with
+// NewContext or in the traceparent metadata of a gRPC call. The context is
+// not valid if the request carries none.
+func FromContext(ctx context.Context) SpanContext {
+	if c, ok := ctx.Value(spanContextKey{}).(SpanContext); ok {
+		return c
+	}
+	if md, ok := metadata.FromContext(ctx); ok {
+		if values := md[Header]; len(values) > 0 {
+			c, _ := ParseSpanContext(values[0])
+			return c
+		}
+	}
+	return SpanContext{}
+}
+
+// FromTransaction returns the trace context of tx, not valid if it carries
+// none
+func FromTransaction(tx *pb.Transaction) SpanContext {
+	c, _ := ParseSpanContext(tx.TraceContext)
+	return c
+}
+
+// Enabled tells whether the peer traces transactions
+func Enabled() bool {
+	return viper.GetBool("peer.tracing.enabled")
+}
+
+// Kind is the kind of a span, as defined by OTLP
+type Kind int
+
+const (
+	// KindInternal is the kind of the spans of an operation of the peer
+	KindInternal Kind = 1
+	// KindServer is the kind of the spans of a request to the peer
+	KindServer Kind = 2
+)
+
+// Span is an operation traced, e.g. the execution of a transaction. The
+// methods of a nil span do nothing, so that operations are traced without
+// checking whether tracing is enabled.
+type Span struct {
+	name       string
+	kind       Kind
+	context    SpanContext
+	parentID   [8]byte
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        string
+}
+
+// StartSpan starts a span of the trace of parent, as its child, or of a new
+// trace if parent is not valid. It returns nil if tracing is disabled or the
+// trace of parent is not sampled.
+func StartSpan(name string, kind Kind, parent SpanContext) *Span {
+	return StartSpanAt(name, kind, parent, time.Now())
+}
+
+// StartSpanAt starts a span like StartSpan, as of start
+func StartSpanAt(name string, kind Kind, parent SpanContext, start time.Time) *Span {
+	if !Enabled() || parent.IsValid() && !parent.Sampled {
+		return nil
+	}
+	span := &Span{name: name, kind: kind, start: start, attributes: make(map[string]string)}
+	span.context.Sampled = true
+	if parent.IsValid() {
+		span.context.TraceID = parent.TraceID
+		span.parentID = parent.SpanID
+	} else if _, err := rand.Read(span.context.TraceID[:]); err != nil {
+		logger.Errorf("Error generating a trace ID: %s", err)
+		return nil
+	}
+	if _, err := rand.Read(span.context.SpanID[:]); err != nil {
+		logger.Errorf("Error generating a span ID: %s", err)
+		return nil
+	}
+	return span
+}
+
+// StartTransactionSpan starts a span of the trace of tx, as of start, with
+// the ID and type of tx as attributes. It returns nil if tx carries no trace
+// context.
+func StartTransactionSpan(name string, tx *pb.Transaction, start time.Time) *Span {
+	if !Enabled() || tx.TraceContext == "" {
+		return nil
+	}
+	parent := FromTransaction(tx)
+	if !parent.IsValid() {
+		return nil
+	}
+	span := StartSpanAt(name, KindInternal, parent, start)
+	span.SetTransaction(tx)
+	return span
+}
+
+// Context returns the context of the span, to propagate to its children, or
+// a context which is not valid for a nil span
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttribute sets an attribute of the span, e.g. the name of a chaincode
+func (s *Span) SetAttribute(key, value string) {
+	if s != nil {
+		s.attributes[key] = value
+	}
+}
+
+// SetTransaction sets the ID and type of tx as attributes of the span
+func (s *Span) SetTransaction(tx *pb.Transaction) {
+	s.SetAttribute("fabric.tx.id", tx.Uuid)
+	s.SetAttribute("fabric.tx.type", tx.Type.String())
+}
+
+// Finish ends the span, which failed with err if not nil, and exports it
+func (s *Span) Finish(err error) {
+	s.FinishAt(time.Now(), err)
+}
+
+// FinishAt ends the span like Finish, as of end
+func (s *Span) FinishAt(end time.Time, err error) {
+	if s == nil {
+		return
+	}
+	s.end = end
+	if err != nil {
+		s.err = err.Error()
+	}
+	export(s)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseSpanContext(t *testing.T) {
+	c, ok := ParseSpanContext(testTraceparent)
+	if !ok || !c.Sampled || c.String() != testTraceparent {
+		t.Fatalf("Expected %s to be parsed, got %s", testTraceparent, c)
+	}
+	if c, ok = ParseSpanContext("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"); !ok || c.Sampled {
+		t.Errorf("Expected a trace context not sampled, got %s", c)
+	}
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if c, ok := ParseSpanContext(invalid); ok {
+			t.Errorf("Expected %q to be refused, got %s", invalid, c)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if c := FromContext(context.Background()); c.IsValid() {
+		t.Errorf("Expected no trace context, got %s", c)
+	}
+	ctx := metadata.NewContext(context.Background(), metadata.Pairs(Header, testTraceparent))
+	if c := FromContext(ctx); c.String() != testTraceparent {
+		t.Errorf("Expected the trace context of the gRPC metadata, got %s", c)
+	}
+	c, _ := ParseSpanContext(testTraceparent)
+	if c := FromContext(NewContext(context.Background(), c)); c.String() != testTraceparent {
+		t.Errorf("Expected the trace context of the context, got %s", c)
+	}
+}
+
+func TestSpansDisabled(t *testing.T) {
+	viper.Set("peer.tracing.enabled", false)
+	span := StartSpan("submit", KindServer, SpanContext{})
+	if span != nil {
+		t.Fatal("Expected no span with tracing disabled")
+	}
+	// The methods of a nil span do nothing
+	span.SetAttribute("key", "value")
+	span.Finish(nil)
+	if c := span.Context(); c.IsValid() {
+		t.Errorf("Expected no context for a nil span, got %s", c)
+	}
+}
+
+func TestExport(t *testing.T) {
+	received := make(chan *otlpTraces, 10)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		traces := &otlpTraces{}
+		if err := json.Unmarshal(body, traces); err != nil {
+			t.Errorf("Invalid OTLP request: %s", err)
+		}
+		received <- traces
+	}))
+	defer collector.Close()
+	viper.Set("peer.tracing.enabled", true)
+	viper.Set("peer.tracing.endpoint", collector.URL+"/v1/traces")
+	viper.Set("peer.tracing.serviceName", "fabric-peer")
+	viper.Set("peer.tracing.batchTimeout", "1h")
+	defer viper.Set("peer.tracing.enabled", false)
+
+	parent, _ := ParseSpanContext(testTraceparent)
+	submit := StartSpan("submit", KindServer, parent)
+	if submit.Context().TraceID != parent.TraceID || submit.Context().SpanID == parent.SpanID {
+		t.Fatalf("Expected a child span of %s, got %s", parent, submit.Context())
+	}
+	tx := &pb.Transaction{Uuid: "tx1", Type: pb.Transaction_CHAINCODE_INVOKE, TraceContext: submit.Context().String()}
+	submit.Finish(nil)
+	StartTransactionSpan("execute", tx, time.Now()).Finish(errors.New("chaincode failed"))
+	if span := StartTransactionSpan("execute", &pb.Transaction{Uuid: "tx2"}, time.Now()); span != nil {
+		t.Error("Expected no span for a transaction without trace context")
+	}
+	unsampled, _ := ParseSpanContext("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	if span := StartSpan("submit", KindServer, unsampled); span != nil {
+		t.Error("Expected no span for a trace not sampled")
+	}
+
+	Flush(time.Now().Add(5 * time.Second))
+	var traces *otlpTraces
+	select {
+	case traces = <-received:
+	default:
+		t.Fatal("Expected the spans to be exported on flush")
+	}
+	if len(traces.ResourceSpans) != 1 || len(traces.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Expected the spans of one resource, got %+v", traces)
+	}
+	if attributes := traces.ResourceSpans[0].Resource.Attributes; len(attributes) == 0 || attributes[0].Value.StringValue != "fabric-peer" {
+		t.Errorf("Expected the service name as resource attribute, got %+v", attributes)
+	}
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %+v", spans)
+	}
+	submitted, executed := spans[0], spans[1]
+	if submitted.Name != "submit" || submitted.Kind != KindServer || submitted.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || submitted.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the submission as a child of the client span, got %+v", submitted)
+	}
+	if executed.Name != "execute" || executed.TraceID != submitted.TraceID || executed.ParentSpanID != submitted.SpanID {
+		t.Errorf("Expected the execution as a child of the submission, got %+v", executed)
+	}
+	if executed.Status.Code != otlpStatusError || executed.Status.Message != "chaincode failed" {
+		t.Errorf("Expected the execution to have failed, got %+v", executed.Status)
+	}
+	if len(executed.Attributes) != 2 || executed.Attributes[0].Value.StringValue != "tx1" {
+		t.Errorf("Expected the transaction as attributes, got %+v", executed.Attributes)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package txstatus tracks transactions from their submission until they are
+// committed to the ledger or rejected, so that clients may poll for the
+// outcome of the transactions they submitted instead of watching the chain.
+package txstatus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// State is the stage a transaction reached on its way to the ledger
+type State string
+
+// The states of a transaction. Committed and rejected are final.
+const (
+	// Submitted to consensus, but not yet ordered
+	Queued State = "queued"
+	// Executed in the order agreed by consensus, but not yet committed
+	Ordered State = "ordered"
+	// Committed to the ledger in a block
+	Committed State = "committed"
+	// Refused at submission or failed at execution
+	Rejected State = "rejected"
+)
+
+// Status is the last known status of a transaction
+type Status struct {
+	UUID  string `json:"txid"`
+	State State  `json:"status"`
+	// Why the transaction was rejected
+	Reason string `json:"reason,omitempty"`
+	// Number of the block holding the transaction once committed
+	Block   *uint64   `json:"block,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// Tracker keeps the status of the most recent transactions, forgetting the
+// oldest ones beyond its capacity
+type Tracker struct {
+	lock     sync.Mutex
+	capacity int
+	statuses map[string]*Status
+	// UUIDs in the order they were first tracked
+	order []string
+}
+
+// NewTracker creates a tracker of up to capacity transactions
+func NewTracker(capacity int) *Tracker {
+	return &Tracker{capacity: capacity, statuses: make(map[string]*Status)}
+}
+
+// update applies change to the status of the transaction, tracking it if
+// unknown. change returns false to leave the status as it was.
+func (t *Tracker) update(uuid string, change func(*Status) bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	status, ok := t.statuses[uuid]
+	if !ok {
+		status = &Status{UUID: uuid}
+	}
+	if !change(status) {
+		return
+	}
+	status.Updated = time.Now()
+	if ok {
+		return
+	}
+	t.statuses[uuid] = status
+	t.order = append(t.order, uuid)
+	for len(t.order) > t.capacity {
+		delete(t.statuses, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// Queued records that the transaction was submitted to consensus. A rejected
+// transaction may be submitted again, with the same UUID if generated from
+// its arguments.
+func (t *Tracker) Queued(uuid string) {
+	t.update(uuid, func(status *Status) bool {
+		if status.State != "" && status.State != Rejected {
+			return false
+		}
+		*status = Status{UUID: uuid, State: Queued}
+		return true
+	})
+}
+
+// Requeued records that the execution of the ordered transaction was rolled
+// back, the transaction waiting to be executed again
+func (t *Tracker) Requeued(uuid string) {
+	t.update(uuid, func(status *Status) bool {
+		if status.State != Ordered {
+			return false
+		}
+		status.State = Queued
+		return true
+	})
+}
+
+// Ordered records that the transaction was executed in the order agreed by
+// consensus
+func (t *Tracker) Ordered(uuid string) {
+	t.update(uuid, func(status *Status) bool {
+		if status.State == Committed || status.State == Rejected {
+			return false
+		}
+		status.State = Ordered
+		return true
+	})
+}
+
+// Committed records that the transaction was committed in block
+func (t *Tracker) Committed(uuid string, block uint64) {
+	t.update(uuid, func(status *Status) bool {
+		*status = Status{UUID: uuid, State: Committed, Block: &block}
+		return true
+	})
+}
+
+// Rejected records that the transaction was refused or failed for reason
+func (t *Tracker) Rejected(uuid string, reason string) {
+	t.update(uuid, func(status *Status) bool {
+		if status.State == Committed {
+			return false
+		}
+		*status = Status{UUID: uuid, State: Rejected, Reason: reason}
+		return true
+	})
+}
+
+// Get returns the status of the transaction, if tracked
+func (t *Tracker) Get(uuid string) (Status, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	status, ok := t.statuses[uuid]
+	if !ok {
+		return Status{}, false
+	}
+	return *status, true
+}
+
+var (
+	defaultOnce    sync.Once
+	defaultTracker *Tracker
+)
+
+// Default returns the tracker of the peer, holding the status of up to
+// peer.txStatus.capacity transactions
+func Default() *Tracker {
+	defaultOnce.Do(func() {
+		capacity := viper.GetInt("peer.txStatus.capacity")
+		if capacity <= 0 {
+			capacity = 10000
+		}
+		defaultTracker = NewTracker(capacity)
+	})
+	return defaultTracker
+}
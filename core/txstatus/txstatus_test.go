@@ -0,0 +1,90 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package txstatus
+
+import (
+	"testing"
+)
+
+func TestTrackerLifecycle(t *testing.T) {
+	tracker := NewTracker(10)
+
+	if _, ok := tracker.Get("tx1"); ok {
+		t.Fatal("Expected an untracked transaction to be unknown")
+	}
+
+	tracker.Queued("tx1")
+	if status, _ := tracker.Get("tx1"); status.State != Queued {
+		t.Fatalf("Expected the transaction queued but got %s", status.State)
+	}
+
+	tracker.Ordered("tx1")
+	tracker.Requeued("tx1")
+	if status, _ := tracker.Get("tx1"); status.State != Queued {
+		t.Fatalf("Expected the rolled back transaction queued but got %s", status.State)
+	}
+
+	tracker.Ordered("tx1")
+	tracker.Committed("tx1", 7)
+	// A late submission report must not hide the commit
+	tracker.Queued("tx1")
+	tracker.Rejected("tx1", "late")
+	status, _ := tracker.Get("tx1")
+	if status.State != Committed || status.Block == nil || *status.Block != 7 {
+		t.Fatalf("Expected the transaction committed in block 7 but got %#v", status)
+	}
+}
+
+func TestTrackerRejectedResubmitted(t *testing.T) {
+	tracker := NewTracker(10)
+
+	tracker.Rejected("tx1", "Network saturated")
+	status, _ := tracker.Get("tx1")
+	if status.State != Rejected || status.Reason != "Network saturated" {
+		t.Fatalf("Expected the transaction rejected but got %#v", status)
+	}
+
+	// Resubmitting a transaction with the same UUID queues it again
+	tracker.Queued("tx1")
+	status, _ = tracker.Get("tx1")
+	if status.State != Queued || status.Reason != "" {
+		t.Fatalf("Expected the resubmitted transaction queued but got %#v", status)
+	}
+}
+
+func TestTrackerCapacity(t *testing.T) {
+	tracker := NewTracker(2)
+
+	tracker.Queued("tx1")
+	tracker.Queued("tx2")
+	tracker.Ordered("tx1")
+	tracker.Queued("tx3")
+
+	if _, ok := tracker.Get("tx1"); ok {
+		t.Error("Expected the oldest transaction forgotten")
+	}
+	for _, uuid := range []string{"tx2", "tx3"} {
+		if _, ok := tracker.Get(uuid); !ok {
+			t.Errorf("Expected transaction %s tracked", uuid)
+		}
+	}
+
+	// Updates of transactions not tracked yet only track them if they apply
+	tracker.Requeued("tx4")
+	if _, ok := tracker.Get("tx4"); ok {
+		t.Error("Expected a requeue of an unknown transaction ignored")
+	}
+}
@@ -0,0 +1,385 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package core
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/core/chaincode"
+)
+
+// Severities of the problems found by ValidateConfig. The peer fails or
+// misbehaves with a configuration in error, while a warning points at a
+// likely mistake, such as a misspelled key the peer ignores.
+const (
+	ConfigError   = "error"
+	ConfigWarning = "warning"
+)
+
+// ConfigProblem is a mistake found in the configuration of the peer
+type ConfigProblem struct {
+	Key      string `json:"key"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (p *ConfigProblem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Severity, p.Key, p.Message)
+}
+
+// validatedSettings are checked by ValidateConfig beyond their type. A key
+// ending with a dot stands for all the settings below it, and the first
+// entry matching a setting applies.
+var validatedSettings = []reloadableSetting{
+	{"logging.format", checkOneOf(loggingFormatText, loggingFormatJSON)},
+	{"logging.", checkLoggingSpec},
+	{"cli.address", checkAddress},
+	{"rest.address", checkAddress},
+	{"peer.listenaddress", checkAddress},
+	{"peer.address", checkAddress},
+	{"peer.validator.events.address", checkAddress},
+	{"peer.profile.listenaddress", checkAddress},
+	{"peer.metrics.listenaddress", checkAddress},
+	{"peer.metrics.path", checkURLPath},
+	{"peer.tracing.endpoint", checkHTTPURL},
+	{"chaincode.listenaddress", checkAddress},
+	{"chaincode.address", checkAddress},
+	{"peer.filesystempath", checkNotEmpty},
+	{"peer.validator.events.buffersize", checkPositiveInt},
+	{"peer.validator.consensus.plugin", checkConsensusPlugin},
+	{"chaincode.mode", checkOneOf(chaincode.DevModeUserRunsChaincode, "net")},
+}
+
+// fileListSettings list files which must all exist, like the settings
+// ending with .file
+var fileListSettings = []string{"peer.admin.quorum.certificates", "security.pkcs11.library"}
+
+// listenerSettings are the addresses the peer listens on, if the setting
+// enabling the listener, if any, is true
+var listenerSettings = []struct {
+	key     string
+	enabled string
+}{
+	{"peer.listenaddress", ""},
+	{"chaincode.listenaddress", ""},
+	{"rest.address", "rest.enabled"},
+	{"peer.validator.events.address", "peer.validator.enabled"},
+	{"peer.profile.listenaddress", "peer.profile.enabled"},
+	{"peer.metrics.listenaddress", "peer.metrics.enabled"},
+}
+
+// ValidateConfig checks the configuration of the peer, the configuration
+// file with its environment overrides, and returns the problems found sorted
+// by key. It checks that the settings have the type of those of reference,
+// e.g. the core.yaml shipped with the peer, the values of the settings of
+// validatedSettings, that the files configured exist where their section is
+// enabled, and that no two listeners share a port. Settings missing from
+// reference and environment variables overriding no setting are reported as
+// warnings, as the peer ignores them. Without reference, only the latter are.
+func ValidateConfig(reference string) ([]*ConfigProblem, error) {
+	settings := allSettings(viper.AllSettings())
+	for key := range settings {
+		if value := os.Getenv(configEnvName(key)); value != "" {
+			settings[key] = value
+		}
+	}
+	// Settings bound to flags are not those of the file
+	fileSettings, err := readSettings(viper.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+	referenceSettings := make(map[string]interface{})
+	if reference != "" {
+		if referenceSettings, err = readSettings(reference); err != nil {
+			return nil, err
+		}
+	}
+
+	var problems []*ConfigProblem
+	report := func(key, severity, format string, args ...interface{}) {
+		problems = append(problems, &ConfigProblem{Key: key, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := settings[key]
+		if _, inFile := fileSettings[key]; inFile && reference != "" && !knownSetting(key, referenceSettings) {
+			report(key, ConfigWarning, "Unknown setting, ignored by the peer")
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		if check := checkLike(referenceSettings[key]); check != nil {
+			if err := check(value); err != nil {
+				report(key, ConfigError, "Invalid value '%v': %s", value, err)
+				continue
+			}
+		}
+		for _, setting := range validatedSettings {
+			if key == setting.key || (strings.HasSuffix(setting.key, ".") && strings.HasPrefix(key, setting.key)) {
+				if err := setting.check(value); err != nil {
+					report(key, ConfigError, "Invalid value '%v': %s", value, err)
+				}
+				break
+			}
+		}
+		if isFileSetting(key) && enabledSection(key, settings) {
+			for _, file := range cast.ToStringSlice(value) {
+				if _, err := os.Stat(file); err != nil && !provisionedFile(key, settings) {
+					report(key, ConfigError, "%s", err)
+				}
+			}
+		}
+	}
+
+	ports := make(map[string]string)
+	for _, listener := range listenerSettings {
+		address := cast.ToString(settings[listener.key])
+		if address == "" || (listener.enabled != "" && !cast.ToBool(settings[listener.enabled])) {
+			continue
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			// Reported above
+			continue
+		}
+		for other, otherAddress := range ports {
+			otherHost, otherPort, _ := net.SplitHostPort(otherAddress)
+			if port == otherPort && (host == otherHost || unspecifiedHost(host) || unspecifiedHost(otherHost)) {
+				report(listener.key, ConfigError, "Address %s conflicts with %s of %s", address, otherAddress, other)
+			}
+		}
+		ports[listener.key] = address
+	}
+
+	if viper.GetBool("security.enabled") && viper.GetString("security.enrollid") == "" {
+		report("security.enrollid", ConfigWarning, "Not set with security enabled, the peer cannot enroll unless it already did")
+	}
+
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if !strings.HasPrefix(name, configEnvPrefix) {
+			continue
+		}
+		if !overridesSetting(name, settings) && !overridesSetting(name, referenceSettings) {
+			report(name, ConfigWarning, "Environment variable overriding no setting, ignored by the peer")
+		}
+	}
+
+	sort.Sort(configProblems(problems))
+	return problems, nil
+}
+
+type configProblems []*ConfigProblem
+
+func (p configProblems) Len() int           { return len(p) }
+func (p configProblems) Less(i, j int) bool { return p[i].Key < p[j].Key }
+func (p configProblems) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// allSettings returns the leaves of the nested settings by their dotted key
+func allSettings(nested map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{})
+	flattenSettings("", nested, flat)
+	return flat
+}
+
+// readSettings returns the settings of the configuration file by their
+// dotted key
+func readSettings(file string) (map[string]interface{}, error) {
+	config := viper.New()
+	config.SetConfigFile(file)
+	if err := config.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", file, err)
+	}
+	return allSettings(config.AllSettings()), nil
+}
+
+// knownSetting tells whether key, or a setting above it left empty for
+// entries of its own such as rest.tls.clientAuth.identities, is one of
+// settings
+func knownSetting(key string, settings map[string]interface{}) bool {
+	if _, ok := settings[key]; ok {
+		return true
+	}
+	for i := strings.LastIndex(key, "."); i > 0; i = strings.LastIndex(key[:i], ".") {
+		if value, ok := settings[key[:i]]; ok {
+			return value == nil
+		}
+	}
+	return false
+}
+
+// checkLike returns the check of the values of the type of reference, nil
+// if it has none
+func checkLike(reference interface{}) func(interface{}) error {
+	switch reference := reference.(type) {
+	case bool:
+		return checkBool
+	case int:
+		return checkInt
+	case float64:
+		return checkFloat
+	case string:
+		if _, err := time.ParseDuration(reference); err == nil {
+			return checkDuration
+		}
+	}
+	return nil
+}
+
+func isFileSetting(key string) bool {
+	if strings.HasSuffix(key, ".file") {
+		return true
+	}
+	for _, setting := range fileListSettings {
+		if key == setting {
+			return true
+		}
+	}
+	return false
+}
+
+// enabledSection tells whether the section of key is in effect: the
+// closest enabled setting above key which is set is not false
+func enabledSection(key string, settings map[string]interface{}) bool {
+	for i := strings.LastIndex(key, "."); i > 0; i = strings.LastIndex(key[:i], ".") {
+		if enabled := settings[key[:i]+".enabled"]; enabled != nil {
+			return cast.ToBool(enabled)
+		}
+	}
+	return true
+}
+
+// provisionedFile tells whether the file of key is written by the peer when
+// it starts, as the TLS certificate provisioned from the TLSCA is
+func provisionedFile(key string, settings map[string]interface{}) bool {
+	return (key == "peer.tls.cert.file" || key == "peer.tls.key.file") && cast.ToBool(settings["peer.tls.provision.enabled"])
+}
+
+func overridesSetting(name string, settings map[string]interface{}) bool {
+	for key := range settings {
+		if name == configEnvName(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func unspecifiedHost(host string) bool {
+	ip := net.ParseIP(host)
+	return host == "" || (ip != nil && ip.IsUnspecified())
+}
+
+func checkBool(value interface{}) error {
+	_, err := cast.ToBoolE(value)
+	return err
+}
+
+func checkFloat(value interface{}) error {
+	_, err := cast.ToFloat64E(value)
+	return err
+}
+
+func checkNotEmpty(value interface{}) error {
+	if cast.ToString(value) == "" {
+		return fmt.Errorf("Must be set")
+	}
+	return nil
+}
+
+func checkPositiveInt(value interface{}) error {
+	n, err := cast.ToIntE(value)
+	if err == nil && n <= 0 {
+		err = fmt.Errorf("Must be positive")
+	}
+	return err
+}
+
+// checkAddress returns an error unless value is empty or a host:port address
+// with a valid port
+func checkAddress(value interface{}) error {
+	address, err := cast.ToStringE(value)
+	if err != nil || address == "" {
+		return err
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("Invalid port %s", port)
+	}
+	return nil
+}
+
+func checkURLPath(value interface{}) error {
+	if !strings.HasPrefix(cast.ToString(value), "/") {
+		return fmt.Errorf("Must be a path starting with /")
+	}
+	return nil
+}
+
+func checkHTTPURL(value interface{}) error {
+	u, err := url.Parse(cast.ToString(value))
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("Must be an http or https URL")
+	}
+	return nil
+}
+
+func checkOneOf(values ...string) func(interface{}) error {
+	return func(value interface{}) error {
+		s := cast.ToString(value)
+		for _, v := range values {
+			if s == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("Must be one of %s", strings.Join(values, ", "))
+	}
+}
+
+// checkConsensusPlugin returns an error unless value names a registered
+// consensus plugin. The peer would otherwise fall back to noops.
+func checkConsensusPlugin(value interface{}) error {
+	plugin := cast.ToString(value)
+	if plugin == "" || len(consensus.RegisteredPlugins()) == 0 {
+		return nil
+	}
+	if _, ok := consensus.GetPluginFactory(plugin); !ok {
+		return fmt.Errorf("Unknown consensus plugin, registered plugins are %v", consensus.RegisteredPlugins())
+	}
+	return nil
+}
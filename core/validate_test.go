@@ -0,0 +1,128 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const validateTestReference = `
+logging:
+    node: info
+    format: text
+rest:
+    enabled: true
+    address: 0.0.0.0:5000
+    tls:
+        clientAuth:
+            identities:
+peer:
+    listenAddress: 0.0.0.0:30303
+    gomaxprocs: -1
+    shutdown:
+        timeout: 30s
+    tls:
+        enabled: false
+        cert:
+            file: server.pem
+    profile:
+        enabled: false
+        listenAddress: 0.0.0.0:6060
+`
+
+func TestValidateConfig(t *testing.T) {
+	_, cleanup := setupReloadTest(t, `
+logging:
+    node: loud
+    format: json
+rest:
+    enabled: true
+    address: 0.0.0.0:30303
+    tls:
+        clientAuth:
+            identities:
+                billing.example.com: jim
+peer:
+    listenAddress: 0.0.0.0:30303
+    gomaxprocs: -1
+    shutdown:
+        timeout: 30 seconds
+    tls:
+        enabeld: true
+        enabled: true
+        cert:
+            file: missing.pem
+    profile:
+        enabled: false
+        listenAddress: 0.0.0.0:30303
+`)
+	defer cleanup()
+	dir, err := ioutil.TempDir("", "validate")
+	if err != nil {
+		t.Fatalf("Error creating directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	reference := filepath.Join(dir, "core.yaml")
+	if err = ioutil.WriteFile(reference, []byte(validateTestReference), 0600); err != nil {
+		t.Fatalf("Error writing %s: %s", reference, err)
+	}
+	os.Setenv("CORE_PEER_GOMAXPROCS", "two")
+	os.Setenv("CORE_PEER_LISTENADRESS", "0.0.0.0:30304")
+	defer os.Unsetenv("CORE_PEER_GOMAXPROCS")
+	defer os.Unsetenv("CORE_PEER_LISTENADRESS")
+
+	problems, err := ValidateConfig(reference)
+	if err != nil {
+		t.Fatalf("Error validating: %s", err)
+	}
+	found := make(map[string]string)
+	for _, problem := range problems {
+		found[problem.Key] = problem.Severity
+	}
+	expected := map[string]string{
+		"CORE_PEER_LISTENADRESS": ConfigWarning,
+		"logging.node":           ConfigError,
+		"peer.gomaxprocs":        ConfigError,
+		"peer.shutdown.timeout":  ConfigError,
+		"peer.tls.cert.file":     ConfigError,
+		"peer.tls.enabeld":       ConfigWarning,
+		"rest.address":           ConfigError,
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected the problems %v, got %v", expected, problems)
+	}
+
+	if _, err = ValidateConfig(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Expected an error for a missing reference")
+	}
+}
+
+func TestCheckAddress(t *testing.T) {
+	for _, address := range []string{"", "0.0.0.0:30303", "peer0:7051", "[::1]:5000"} {
+		if err := checkAddress(address); err != nil {
+			t.Errorf("Expected '%s' to be valid: %s", address, err)
+		}
+	}
+	for _, address := range []string{"30303", "0.0.0.0:http", "0.0.0.0:70000"} {
+		if err := checkAddress(address); err == nil {
+			t.Errorf("Expected '%s' to be refused", address)
+		}
+	}
+}
@@ -42,10 +42,11 @@ func NewEventsClient(peerAddress string, adapter EventAdapter) *EventsClient {
 
 //newEventsClientConnectionWithAddress Returns a new grpc.ClientConn to the configured local PEER.
 func newEventsClientConnectionWithAddress(peerAddress string) (*grpc.ClientConn, error) {
+	limits := comm.GetMessageSizeLimits("peer.validator.events.messageSize")
 	if comm.TLSEnabled() {
-		return comm.NewClientConnectionWithAddress(peerAddress, true, true, comm.InitTLSForPeer())
+		return comm.NewClientConnectionWithAddress(peerAddress, true, true, comm.InitTLSForPeer(), limits.DialOption())
 	}
-	return comm.NewClientConnectionWithAddress(peerAddress, true, false, nil)
+	return comm.NewClientConnectionWithAddress(peerAddress, true, false, nil, limits.DialOption())
 }
 
 func (ec *EventsClient) register(ies []*ehpb.Interest) error {
@@ -112,7 +113,12 @@ func (ec *EventsClient) Start() error {
 	if err != nil {
 		return fmt.Errorf("Could not create client conn to %s", ec.peerAddress)
 	}
+	return ec.StartWithConnection(conn)
+}
 
+//StartWithConnection registers interested events with the event hub at the
+//other end of conn, which the caller dialed and closes
+func (ec *EventsClient) StartWithConnection(conn *grpc.ClientConn) error {
 	ies, err := ec.adapter.GetInterestedEvents()
 	if err != nil {
 		return fmt.Errorf("error getting interested events:%s", err)
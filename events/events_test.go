@@ -24,6 +24,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/events/consumer"
 	"github.com/hyperledger/fabric/events/producer"
 	ehpb "github.com/hyperledger/fabric/protos"
@@ -190,6 +191,107 @@ func BenchmarkMessages(b *testing.B) {
 	}
 }
 
+type revocationAdapter struct {
+	revocations chan *ehpb.Revocation
+}
+
+func (a *revocationAdapter) GetInterestedEvents() ([]*ehpb.Interest, error) {
+	return []*ehpb.Interest{&ehpb.Interest{EventType: ehpb.EventType_REVOCATION}}, nil
+}
+
+func (a *revocationAdapter) Recv(msg *ehpb.Event) (bool, error) {
+	a.revocations <- msg.GetRevocation()
+	return true, nil
+}
+
+func (a *revocationAdapter) Disconnected(err error) {
+}
+
+func TestReceiveRevocation(t *testing.T) {
+	var conn *grpc.ClientConn
+	var err error
+	if comm.TLSEnabled() {
+		conn, err = comm.NewClientConnectionWithAddress(peerAddress, true, true, comm.InitTLSForPeer())
+	} else {
+		conn, err = comm.NewClientConnectionWithAddress(peerAddress, true, false, nil)
+	}
+	if err != nil {
+		t.Fatalf("Error dialing the event hub %s", err)
+	}
+	defer conn.Close()
+
+	revAdapter := &revocationAdapter{revocations: make(chan *ehpb.Revocation, 1)}
+	client := consumer.NewEventsClient(peerAddress, revAdapter)
+	if err = client.StartWithConnection(conn); err != nil {
+		t.Fatalf("could not start chat %s", err)
+	}
+	defer client.Stop()
+
+	adapter.count = 1
+	if err = producer.Send(producer.CreateRevocationEvent("ECA", []byte("crl"))); err != nil {
+		t.Fatalf("Error sending message %s", err)
+	}
+
+	select {
+	case revocation := <-revAdapter.revocations:
+		if revocation.Issuer != "ECA" || string(revocation.Crl) != "crl" {
+			t.Fatalf("Unexpected revocation event %v", revocation)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out on the revocation event")
+	}
+
+	// consumers not interested in revocations do not receive them
+	select {
+	case <-adapter.notfy:
+		t.Fatal("should NOT have received the revocation event")
+	case <-time.After(time.Second):
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	interests := []*ehpb.Interest{
+		&ehpb.Interest{EventType: ehpb.EventType_CHAINCODE, RegInfo: &ehpb.Interest_ChaincodeRegInfo{ChaincodeRegInfo: &ehpb.ChaincodeReg{ChaincodeID: "local", EventName: "event1"}}},
+	}
+	events, cancel, err := producer.Subscribe(interests, 1)
+	if err != nil {
+		t.Fatalf("Error subscribing %s", err)
+	}
+
+	if err = producer.Send(createTestChaincodeEvent("local", "event2")); err != nil {
+		t.Fatalf("Error sending message %s", err)
+	}
+	if err = producer.Send(createTestChaincodeEvent("local", "event1")); err != nil {
+		t.Fatalf("Error sending message %s", err)
+	}
+
+	select {
+	case e := <-events:
+		if e.GetChaincodeEvent().EventName != "event1" {
+			t.Fatalf("Unexpected event %v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out on the chaincode event")
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Fatal("the channel should be closed once cancelled")
+	}
+	// cancelling twice is harmless
+	cancel()
+
+	if _, _, err = producer.Subscribe([]*ehpb.Interest{&ehpb.Interest{EventType: ehpb.EventType_CHAINCODE}}, 1); err == nil {
+		t.Fatal("subscribing to chaincode events without a chaincode ID should fail")
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	if err := producer.CheckHealth(); err != nil {
+		t.Fatalf("Expected the started event hub to be healthy, got %s", err)
+	}
+}
+
 func TestMain(m *testing.M) {
 	SetupTestConfig()
 	var opts []grpc.ServerOption
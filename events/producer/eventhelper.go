@@ -34,3 +34,18 @@ func CreateChaincodeEvent(te *ehpb.ChaincodeEvent) *ehpb.Event {
 func CreateRejectionEvent(tx *ehpb.Transaction, errorMsg string) *ehpb.Event {
 	return &ehpb.Event{Event: &ehpb.Event_Rejection{Rejection: &ehpb.Rejection{Tx: tx, ErrorMsg: errorMsg}}}
 }
+
+//CreateConsensusHealthEvent creates an Event from a ConsensusHealth
+func CreateConsensusHealthEvent(health *ehpb.ConsensusHealth) *ehpb.Event {
+	return &ehpb.Event{Event: &ehpb.Event_ConsensusHealth{ConsensusHealth: health}}
+}
+
+//CreateSecurityEvent creates an Event from a SecurityEvent
+func CreateSecurityEvent(event *ehpb.SecurityEvent) *ehpb.Event {
+	return &ehpb.Event{Event: &ehpb.Event_SecurityEvent{SecurityEvent: event}}
+}
+
+//CreateRevocationEvent creates an Event from a CRL issued by a CA
+func CreateRevocationEvent(issuer string, crl []byte) *ehpb.Event {
+	return &ehpb.Event{Event: &ehpb.Event_Revocation{Revocation: &ehpb.Revocation{Issuer: issuer, Crl: crl}}}
+}
@@ -19,6 +19,7 @@ package producer
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/hyperledger/fabric/protos"
@@ -207,6 +208,9 @@ type eventProcessor struct {
 	//if 0, if buffer full, will block and guarantee the event will be sent out
 	//if > 0, if buffer full, blocks till timeout
 	timeout int
+
+	//number of events sent and not yet passed on to the consumers
+	pending int32
 }
 
 //global eventProcessor singleton created by initializeEvents. Openchain producers
@@ -225,6 +229,7 @@ func (ep *eventProcessor) start() {
 		if hl, _ = ep.eventConsumers[eType]; hl == nil {
 			producerLogger.Errorf("Event of type %s does not exist", eType)
 			ep.Unlock()
+			atomic.AddInt32(&ep.pending, -1)
 			continue
 		}
 		//lock the handler map lock
@@ -235,7 +240,8 @@ func (ep *eventProcessor) start() {
 				h.SendMessage(e)
 			}
 		})
-
+		eventsDelivered.Inc(eType.String())
+		atomic.AddInt32(&ep.pending, -1)
 	}
 }
 
@@ -263,11 +269,9 @@ func AddEventType(eventType pb.EventType) error {
 	}
 
 	switch eventType {
-	case pb.EventType_BLOCK:
-		gEventProcessor.eventConsumers[eventType] = &genericHandlerList{handlers: make(map[*handler]bool)}
 	case pb.EventType_CHAINCODE:
 		gEventProcessor.eventConsumers[eventType] = &chaincodeHandlerList{handlers: make(map[string]map[string]map[*handler]bool)}
-	case pb.EventType_REJECTION:
+	default:
 		gEventProcessor.eventConsumers[eventType] = &genericHandlerList{handlers: make(map[*handler]bool)}
 	}
 	gEventProcessor.Unlock()
@@ -316,10 +320,13 @@ func Send(e *pb.Event) error {
 		return nil
 	}
 
+	atomic.AddInt32(&gEventProcessor.pending, 1)
 	if gEventProcessor.timeout < 0 {
 		select {
 		case gEventProcessor.eventChannel <- e:
 		default:
+			atomic.AddInt32(&gEventProcessor.pending, -1)
+			eventsDropped.Inc(getMessageType(e).String())
 			return fmt.Errorf("could not send the blocking event")
 		}
 	} else if gEventProcessor.timeout == 0 {
@@ -328,9 +335,56 @@ func Send(e *pb.Event) error {
 		select {
 		case gEventProcessor.eventChannel <- e:
 		case <-time.After(time.Duration(gEventProcessor.timeout) * time.Millisecond):
+			atomic.AddInt32(&gEventProcessor.pending, -1)
+			eventsDropped.Inc(getMessageType(e).String())
 			return fmt.Errorf("could not send the blocking event")
 		}
 	}
 
 	return nil
 }
+
+//Subscribe registers a consumer within this process for the events matching
+//interests, which are delivered on the returned channel. Events are dropped
+//rather than delaying the other consumers if the channel, of size
+//bufferSize, is full. The returned function deregisters the consumer and
+//closes the channel
+func Subscribe(interests []*pb.Interest, bufferSize int) (<-chan *pb.Event, func(), error) {
+	if gEventProcessor == nil {
+		return nil, nil, fmt.Errorf("event hub not started")
+	}
+
+	h := &handler{localChan: make(chan *pb.Event, bufferSize)}
+	for _, interest := range interests {
+		if err := registerHandler(interest, h); err != nil {
+			h.deregister()
+			return nil, nil, err
+		}
+		h.addInterest(interest)
+	}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			//once deregistered the event processor no longer sends to the channel
+			h.deregister()
+			close(h.localChan)
+		})
+	}
+	return h.localChan, cancel, nil
+}
+
+//Flush waits until the events sent so far are passed on to the consumers or
+//the deadline passes, and returns the number of events not passed on
+func Flush(deadline time.Time) int {
+	if gEventProcessor == nil {
+		return 0
+	}
+	for {
+		pending := int(atomic.LoadInt32(&gEventProcessor.pending))
+		if pending == 0 || !time.Now().Before(deadline) {
+			return pending
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -24,6 +24,8 @@ import (
 
 type handler struct {
 	ChatStream pb.Events_ChatServer
+	// events of a local consumer, used instead of ChatStream if set
+	localChan  chan *pb.Event
 	doneChan   chan bool
 	registered bool
 	// PM: this should be a list, add/del, iterate
@@ -109,6 +111,16 @@ func (d *handler) HandleMessage(msg *pb.Event) error {
 
 // SendMessage sends a message to the remote PEER through the stream
 func (d *handler) SendMessage(msg *pb.Event) error {
+	if d.localChan != nil {
+		//never block the event processor on a slow local consumer
+		select {
+		case d.localChan <- msg:
+			return nil
+		default:
+			producerLogger.Warning("Local consumer is not keeping up, event dropped")
+			return fmt.Errorf("Local consumer is not keeping up, event dropped")
+		}
+	}
 	err := d.ChatStream.Send(msg)
 	if err != nil {
 		return fmt.Errorf("Error Sending message through ChatStream: %s", err)
@@ -0,0 +1,42 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import (
+	"sync/atomic"
+
+	"github.com/hyperledger/fabric/core/metrics"
+)
+
+var (
+	eventsDelivered = metrics.NewCounter("fabric_events_delivered_total",
+		"Events passed on to the consumers of the event hub, by type.", "type")
+	eventsDropped = metrics.NewCounter("fabric_events_dropped_total",
+		"Events dropped as the buffer of the event hub was full, by type.", "type")
+)
+
+func init() {
+	metrics.NewGaugeFunc("fabric_events_queue_depth", "Events sent and not yet passed on to the consumers.",
+		func() float64 {
+			if gEventProcessor == nil {
+				return 0
+			}
+			return float64(atomic.LoadInt32(&gEventProcessor.pending))
+		})
+	metrics.NewGaugeFunc("fabric_events_consumers", "Clients connected to the event hub.",
+		func() float64 { return float64(ConsumerCount()) })
+}
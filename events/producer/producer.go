@@ -17,8 +17,10 @@ limitations under the License.
 package producer
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/hyperledger/fabric/protos"
@@ -36,6 +38,9 @@ type EventsServer struct {
 //singleton - if we want to create multiple servers, we need to subsume events.gEventConsumers into EventsServer
 var globalEventsServer *EventsServer
 
+//number of clients connected to the event hub
+var consumers int32
+
 // NewEventsServer returns a EventsServer
 func NewEventsServer(bufferSize uint, timeout int) *EventsServer {
 	if globalEventsServer != nil {
@@ -47,6 +52,24 @@ func NewEventsServer(bufferSize uint, timeout int) *EventsServer {
 	return globalEventsServer
 }
 
+// ConsumerCount returns the number of clients connected to the event hub
+func ConsumerCount() int {
+	return int(atomic.LoadInt32(&consumers))
+}
+
+// CheckHealth returns why the event hub cannot pass events on, i.e. it is
+// not started or its buffer of events is full, so that producers block or
+// drop their events
+func CheckHealth() error {
+	if gEventProcessor == nil {
+		return errors.New("Event hub not started")
+	}
+	if buffered, size := len(gEventProcessor.eventChannel), cap(gEventProcessor.eventChannel); size > 0 && buffered >= size {
+		return fmt.Errorf("Event buffer full with %d events", buffered)
+	}
+	return nil
+}
+
 // Chat implementation of the the Chat bidi streaming RPC function
 func (p *EventsServer) Chat(stream pb.Events_ChatServer) error {
 	handler, err := newEventHandler(stream)
@@ -54,6 +77,8 @@ func (p *EventsServer) Chat(stream pb.Events_ChatServer) error {
 		return fmt.Errorf("Error creating handler during handleChat initiation: %s", err)
 	}
 	defer handler.Stop()
+	atomic.AddInt32(&consumers, 1)
+	defer atomic.AddInt32(&consumers, -1)
 	for {
 		in, err := stream.Recv()
 		if err == io.EOF {
@@ -42,6 +42,12 @@ func getMessageType(e *pb.Event) pb.EventType {
 		return pb.EventType_CHAINCODE
 	case *pb.Event_Rejection:
 		return pb.EventType_REJECTION
+	case *pb.Event_ConsensusHealth:
+		return pb.EventType_CONSENSUS_HEALTH
+	case *pb.Event_Revocation:
+		return pb.EventType_REVOCATION
+	case *pb.Event_SecurityEvent:
+		return pb.EventType_SECURITY
 	default:
 		return -1
 	}
@@ -52,5 +58,8 @@ func addInternalEventTypes() {
 	AddEventType(pb.EventType_BLOCK)
 	AddEventType(pb.EventType_CHAINCODE)
 	AddEventType(pb.EventType_REJECTION)
+	AddEventType(pb.EventType_CONSENSUS_HEALTH)
+	AddEventType(pb.EventType_REVOCATION)
+	AddEventType(pb.EventType_SECURITY)
 	AddEventType(pb.EventType_REGISTER)
 }
@@ -0,0 +1,218 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package timestamp is a toy consensus plugin ordering transactions by the
+timestamp their client assigned. It exists to illustrate the consensus plugin
+interfaces and is not fault tolerant: it trusts client clocks and assumes
+every transaction reaches every validator within a bounded delay.
+
+Transactions are grouped into epochs of a configured length. Once an epoch
+has ended and its settle time has passed, each validator sorts the
+transactions of the epoch by timestamp and UUID and commits them as one
+block. Importing the package registers the plugin under the name
+"timestamp", see consensus.RegisterPlugin.
+*/
+package timestamp
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/consensus"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+var logger *logging.Logger // package-level logger
+
+func init() {
+	logger = logging.MustGetLogger("consensus/timestamp")
+
+	consensus.RegisterPlugin("timestamp", New)
+}
+
+// Timestamp is a plugin object implementing the consensus.Consenter interface
+type Timestamp struct {
+	stack  consensus.Stack
+	period time.Duration
+	settle time.Duration
+
+	lock   sync.Mutex
+	epochs map[int64][]*pb.Transaction // pending transactions by epoch
+	seen   map[string]bool             // UUIDs of pending transactions
+	cut    int64                       // last epoch committed
+
+	closed chan struct{}
+}
+
+// New creates a timestamp plugin using the configuration in config.yaml
+func New(stack consensus.Stack) consensus.Consenter {
+	return newTimestamp(stack, loadConfig())
+}
+
+func newTimestamp(stack consensus.Stack, config *viper.Viper) *Timestamp {
+	var err error
+	ts := &Timestamp{
+		stack:  stack,
+		epochs: make(map[int64][]*pb.Transaction),
+		seen:   make(map[string]bool),
+		closed: make(chan struct{}),
+	}
+	if ts.period, err = time.ParseDuration(config.GetString("epoch.period")); err != nil || ts.period <= 0 {
+		panic(fmt.Errorf("Cannot parse epoch period: %s", err))
+	}
+	if ts.settle, err = time.ParseDuration(config.GetString("epoch.settle")); err != nil {
+		panic(fmt.Errorf("Cannot parse epoch settle time: %s", err))
+	}
+	ts.cut = ts.epoch(time.Now()) - 1
+
+	logger.Infof("Timestamp epoch period = %v", ts.period)
+	logger.Infof("Timestamp epoch settle = %v", ts.settle)
+
+	go ts.run()
+	return ts
+}
+
+// Close stops cutting epochs
+func (ts *Timestamp) Close() {
+	close(ts.closed)
+}
+
+// RecvMsg is called for Message_CHAIN_TRANSACTION and Message_CONSENSUS messages.
+// Transactions from clients are relayed to the other validators unchanged.
+func (ts *Timestamp) RecvMsg(msg *pb.Message, senderHandle *pb.PeerID) error {
+	if msg.Type != pb.Message_CHAIN_TRANSACTION && msg.Type != pb.Message_CONSENSUS {
+		return fmt.Errorf("Unexpected message type %s", msg.Type)
+	}
+
+	tx := &pb.Transaction{}
+	if err := proto.Unmarshal(msg.Payload, tx); err != nil {
+		return fmt.Errorf("Error unmarshalling payload of received Message:%s.", msg.Type)
+	}
+	if tx.Timestamp == nil {
+		return fmt.Errorf("Transaction %s has no timestamp", tx.Uuid)
+	}
+
+	if msg.Type == pb.Message_CHAIN_TRANSACTION {
+		relay := &pb.Message{Type: pb.Message_CONSENSUS, Payload: msg.Payload, Timestamp: msg.Timestamp}
+		if err := ts.stack.Broadcast(relay, pb.PeerEndpoint_VALIDATOR); err != nil {
+			return fmt.Errorf("Failed to broadcast transaction %s: %v", tx.Uuid, err)
+		}
+	}
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	epoch := ts.epoch(time.Unix(tx.Timestamp.Seconds, int64(tx.Timestamp.Nanos)))
+	if epoch <= ts.cut {
+		logger.Warningf("Dropping transaction %s, its epoch %d was already committed", tx.Uuid, epoch)
+		return nil
+	}
+	if ts.seen[tx.Uuid] {
+		return nil
+	}
+	ts.seen[tx.Uuid] = true
+	ts.epochs[epoch] = append(ts.epochs[epoch], tx)
+	return nil
+}
+
+// Executed is unused, the plugin executes through the LegacyExecutor
+func (ts *Timestamp) Executed(tag interface{}) {}
+
+// Committed is unused, the plugin executes through the LegacyExecutor
+func (ts *Timestamp) Committed(tag interface{}, target *pb.BlockchainInfo) {}
+
+// RolledBack is unused, the plugin executes through the LegacyExecutor
+func (ts *Timestamp) RolledBack(tag interface{}) {}
+
+// StateUpdated is unused, the plugin never requests state transfer
+func (ts *Timestamp) StateUpdated(tag interface{}, target *pb.BlockchainInfo) {}
+
+func (ts *Timestamp) epoch(t time.Time) int64 {
+	return t.UnixNano() / int64(ts.period)
+}
+
+func (ts *Timestamp) run() {
+	ticker := time.NewTicker(ts.period / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ts.closed:
+			return
+		case now := <-ticker.C:
+			ts.cutEpochs(now)
+		}
+	}
+}
+
+// cutEpochs commits all epochs which have settled by now, oldest first
+func (ts *Timestamp) cutEpochs(now time.Time) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	settled := ts.epoch(now.Add(-ts.settle)) - 1
+	for ; ts.cut < settled; ts.cut++ {
+		epoch := ts.cut + 1
+		txs := ts.epochs[epoch]
+		if len(txs) == 0 {
+			continue
+		}
+		delete(ts.epochs, epoch)
+		for _, tx := range txs {
+			delete(ts.seen, tx.Uuid)
+		}
+
+		sort.Sort(byTimestamp(txs))
+		if err := ts.commit(epoch, txs); err != nil {
+			logger.Errorf("Could not commit epoch %d: %s", epoch, err)
+		}
+	}
+}
+
+func (ts *Timestamp) commit(epoch int64, txs []*pb.Transaction) error {
+	logger.Debugf("Committing epoch %d with %d transactions", epoch, len(txs))
+	if err := ts.stack.BeginTxBatch(epoch); err != nil {
+		return err
+	}
+	if _, err := ts.stack.ExecTxs(epoch, txs); err != nil {
+		ts.stack.RollbackTxBatch(epoch)
+		return err
+	}
+	_, err := ts.stack.CommitTxBatch(epoch, nil)
+	return err
+}
+
+// byTimestamp sorts transactions by client timestamp, breaking ties by UUID
+type byTimestamp []*pb.Transaction
+
+func (s byTimestamp) Len() int      { return len(s) }
+func (s byTimestamp) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byTimestamp) Less(i, j int) bool {
+	a, b := s[i].Timestamp, s[j].Timestamp
+	if a.Seconds != b.Seconds {
+		return a.Seconds < b.Seconds
+	}
+	if a.Nanos != b.Nanos {
+		return a.Nanos < b.Nanos
+	}
+	return s[i].Uuid < s[j].Uuid
+}
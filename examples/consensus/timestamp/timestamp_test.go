@@ -0,0 +1,60 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package timestamp
+
+import (
+	"sort"
+	"testing"
+
+	gp "google/protobuf"
+
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/consensus/conformance"
+)
+
+func newTestTimestamp(stack consensus.Stack) consensus.Consenter {
+	config := loadConfig()
+	config.Set("epoch.period", "100ms")
+	config.Set("epoch.settle", "100ms")
+	return newTimestamp(stack, config)
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, newTestTimestamp, conformance.Options{})
+}
+
+func TestRegistered(t *testing.T) {
+	if _, ok := consensus.GetPluginFactory("timestamp"); !ok {
+		t.Fatalf("Expected the timestamp plugin to be registered")
+	}
+}
+
+func TestOrderByTimestamp(t *testing.T) {
+	txs := byTimestamp{
+		{Uuid: "c", Timestamp: &gp.Timestamp{Seconds: 2}},
+		{Uuid: "b", Timestamp: &gp.Timestamp{Seconds: 1, Nanos: 5}},
+		{Uuid: "a", Timestamp: &gp.Timestamp{Seconds: 1, Nanos: 5}},
+		{Uuid: "d", Timestamp: &gp.Timestamp{Seconds: 1}},
+	}
+	sort.Sort(txs)
+	expected := []string{"d", "a", "b", "c"}
+	for i, tx := range txs {
+		if tx.Uuid != expected[i] {
+			t.Fatalf("Expected %s at position %d, got %s", expected[i], i, tx.Uuid)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flogging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+// Names of the fields of the records written by the JSON formatter, besides
+// the contextual fields
+const (
+	TimeField     = "time"
+	LevelField    = "level"
+	ModuleField   = "module"
+	FunctionField = "func"
+	PeerIDField   = "peer"
+	MessageField  = "msg"
+)
+
+type jsonFormatter struct {
+	peerID string
+}
+
+// NewJSONFormatter returns a formatter writing each record as a JSON object
+// on a line of its own, with its time, level, module, the function which
+// logged it, peerID if not empty, its message and contextual fields
+func NewJSONFormatter(peerID string) logging.Formatter {
+	return &jsonFormatter{peerID: peerID}
+}
+
+// Format writes record r as a JSON object to output
+func (f *jsonFormatter) Format(calldepth int, r *logging.Record, output io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	writeField(&buf, TimeField, r.Time.Format(time.RFC3339Nano))
+	writeField(&buf, LevelField, r.Level.String())
+	writeField(&buf, ModuleField, r.Module)
+	if pc, _, _, ok := runtime.Caller(calldepth + 1); ok {
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			writeField(&buf, FunctionField, shortFuncName(fn.Name()))
+		}
+	}
+	if f.peerID != "" {
+		writeField(&buf, PeerIDField, f.peerID)
+	}
+	if e, ok := recordEntry(r); ok {
+		writeField(&buf, MessageField, e.message)
+		for _, field := range e.fields {
+			writeField(&buf, field.key, field.value)
+		}
+	} else {
+		writeField(&buf, MessageField, r.Message())
+	}
+	buf.WriteByte('}')
+	_, err := output.Write(buf.Bytes())
+	return err
+}
+
+// recordEntry returns the entry of a record logged by a Logger
+func recordEntry(r *logging.Record) (*entry, bool) {
+	if len(r.Args) != 1 {
+		return nil, false
+	}
+	e, ok := r.Args[0].(*entry)
+	return e, ok
+}
+
+func writeField(buf *bytes.Buffer, key, value string) {
+	if buf.Len() > 1 {
+		buf.WriteByte(',')
+	}
+	// Marshaling a string cannot fail
+	k, _ := json.Marshal(key)
+	v, _ := json.Marshal(value)
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+}
+
+// shortFuncName returns the name of a function without its package, e.g.
+// (*Ledger).CommitTxBatch
+func shortFuncName(name string) string {
+	name = path.Base(name)
+	if i := strings.Index(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,151 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package flogging adds structured logging to the go-logging loggers of the
+// peer. A Logger adds contextual fields, such as the ID of the transaction
+// or the chaincode a record is about, to the records of a module, and the
+// JSON formatter writes each record as a JSON object with consistent field
+// names, so that the logs can be ingested by log collectors without parsing
+// the text of the messages.
+package flogging
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/op/go-logging"
+)
+
+// Names of the contextual fields of the records
+const (
+	TxIDField      = "txid"
+	ChaincodeField = "chaincode"
+)
+
+// Logger logs the records of a module with contextual fields. The fields
+// follow the message in the text format, and are fields of their own in the
+// JSON format.
+type Logger struct {
+	logger *logging.Logger
+	fields []field
+}
+
+type field struct {
+	key, value string
+}
+
+// With returns a Logger logging to the module of logger with the field
+// key=value
+func With(logger *logging.Logger, key, value string) *Logger {
+	// The methods of Logger add two calls between the caller and logger
+	wrapped := &logging.Logger{Module: logger.Module, ExtraCalldepth: logger.ExtraCalldepth + 2}
+	return &Logger{logger: wrapped, fields: []field{{key, value}}}
+}
+
+// WithTx returns a Logger logging to the module of logger with the ID of a
+// transaction and the name of its chaincode, if not empty
+func WithTx(logger *logging.Logger, txid, chaincode string) *Logger {
+	l := With(logger, TxIDField, txid)
+	if chaincode != "" {
+		l = l.With(ChaincodeField, chaincode)
+	}
+	return l
+}
+
+// With returns a Logger logging with the fields of l and key=value
+func (l *Logger) With(key, value string) *Logger {
+	fields := make([]field, len(l.fields), len(l.fields)+1)
+	copy(fields, l.fields)
+	return &Logger{logger: l.logger, fields: append(fields, field{key, value})}
+}
+
+// IsEnabledFor returns true if the module of l logs records of level
+func (l *Logger) IsEnabledFor(level logging.Level) bool {
+	return l.logger.IsEnabledFor(level)
+}
+
+// Criticalf logs a record of level CRITICAL
+func (l *Logger) Criticalf(format string, args ...interface{}) {
+	l.log(logging.CRITICAL, format, args)
+}
+
+// Errorf logs a record of level ERROR
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(logging.ERROR, format, args)
+}
+
+// Warningf logs a record of level WARNING
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	l.log(logging.WARNING, format, args)
+}
+
+// Noticef logs a record of level NOTICE
+func (l *Logger) Noticef(format string, args ...interface{}) {
+	l.log(logging.NOTICE, format, args)
+}
+
+// Infof logs a record of level INFO
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(logging.INFO, format, args)
+}
+
+// Debugf logs a record of level DEBUG
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.log(logging.DEBUG, format, args)
+}
+
+func (l *Logger) log(level logging.Level, format string, args []interface{}) {
+	if !l.logger.IsEnabledFor(level) {
+		return
+	}
+	e := &entry{message: fmt.Sprintf(format, args...), fields: l.fields}
+	switch level {
+	case logging.CRITICAL:
+		l.logger.Critical(e)
+	case logging.ERROR:
+		l.logger.Error(e)
+	case logging.WARNING:
+		l.logger.Warning(e)
+	case logging.NOTICE:
+		l.logger.Notice(e)
+	case logging.INFO:
+		l.logger.Info(e)
+	default:
+		l.logger.Debug(e)
+	}
+}
+
+// entry is the only argument of the records logged by a Logger, from which
+// the JSON formatter takes the message and the fields
+type entry struct {
+	message string
+	fields  []field
+}
+
+// String returns the message followed by the fields, as in the text format
+func (e *entry) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(e.message)
+	buf.WriteString(" [")
+	for i, f := range e.fields {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "%s=%s", f.key, f.value)
+	}
+	buf.WriteByte(']')
+	return buf.String()
+}
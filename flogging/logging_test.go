@@ -0,0 +1,96 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flogging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+func logTo(buf *bytes.Buffer, formatter logging.Formatter) {
+	backend := logging.NewBackendFormatter(logging.NewLogBackend(buf, "", 0), formatter)
+	logging.SetBackend(backend).SetLevel(logging.DEBUG, "")
+}
+
+func TestJSONFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	logTo(&buf, NewJSONFormatter("vp0"))
+	defer logging.Reset()
+	logger := logging.MustGetLogger("flogging_test")
+
+	WithTx(logger, "tx1", "mycc").Infof("Executed in %d ms", 12)
+	logger.Warningf("Plain %s", "record")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 records, got %q", buf.String())
+	}
+	expected := []map[string]string{
+		{LevelField: "INFO", ModuleField: "flogging_test", FunctionField: "TestJSONFormatter", PeerIDField: "vp0", MessageField: "Executed in 12 ms", TxIDField: "tx1", ChaincodeField: "mycc"},
+		{LevelField: "WARNING", ModuleField: "flogging_test", FunctionField: "TestJSONFormatter", PeerIDField: "vp0", MessageField: "Plain record"},
+	}
+	for i, line := range lines {
+		record := make(map[string]string)
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Record %q is not JSON: %s", line, err)
+		}
+		if record[TimeField] == "" {
+			t.Errorf("Record %q has no time", line)
+		}
+		delete(record, TimeField)
+		if len(record) != len(expected[i]) {
+			t.Errorf("Expected the fields %v, got %v", expected[i], record)
+		}
+		for key, value := range expected[i] {
+			if record[key] != value {
+				t.Errorf("Expected %s=%q in %q, got %q", key, value, line, record[key])
+			}
+		}
+	}
+}
+
+func TestLoggerTextFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logTo(&buf, logging.MustStringFormatter("%{shortfunc} %{level} %{message}"))
+	defer logging.Reset()
+	logger := logging.MustGetLogger("flogging_test")
+
+	WithTx(logger, "tx1", "").With("block", "3").Errorf("Commit failed: %s", "disk full")
+
+	expected := "TestLoggerTextFormat ERROR Commit failed: disk full [txid=tx1 block=3]\n"
+	if buf.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logTo(&buf, NewJSONFormatter(""))
+	defer logging.Reset()
+	logging.SetLevel(logging.INFO, "flogging_test")
+	logger := logging.MustGetLogger("flogging_test")
+
+	WithTx(logger, "tx1", "mycc").Debugf("Not logged")
+
+	if buf.Len() != 0 {
+		t.Fatalf("Expected no record below the level of the module, got %q", buf.String())
+	}
+}
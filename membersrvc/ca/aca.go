@@ -42,15 +42,10 @@ var (
 // ACA is the attribute certificate authority.
 type ACA struct {
 	*CA
+	eca        *ECA
 	gRPCServer *grpc.Server
 }
 
-// ACAA serves the administrator GRPC interface of the ACA.
-//
-type ACAA struct {
-	aca *ACA
-}
-
 //IsAttributeOID returns if the oid passed as parameter is or not linked with an attribute
 func IsAttributeOID(oid asn1.ObjectIdentifier) bool {
 	l := len(oid)
@@ -206,20 +201,40 @@ func (attrPair *AttributePair) ToACAAttribute() *pb.ACAAttribute {
 	if attrPair.validFrom.IsZero() {
 		from = nil
 	} else {
-		from = &google_protobuf.Timestamp{Seconds: attrPair.validFrom.Unix(), Nanos: int32(attrPair.validFrom.UnixNano())}
+		from = &google_protobuf.Timestamp{Seconds: attrPair.validFrom.Unix(), Nanos: int32(attrPair.validFrom.Nanosecond())}
 	}
 	if attrPair.validTo.IsZero() {
 		to = nil
 	} else {
-		to = &google_protobuf.Timestamp{Seconds: attrPair.validTo.Unix(), Nanos: int32(attrPair.validTo.UnixNano())}
+		to = &google_protobuf.Timestamp{Seconds: attrPair.validTo.Unix(), Nanos: int32(attrPair.validTo.Nanosecond())}
 
 	}
 	return &pb.ACAAttribute{AttributeName: attrPair.attributeName, AttributeValue: attrPair.attributeValue, ValidFrom: from, ValidTo: to}
 }
 
-// NewACA sets up a new ACA.
-func NewACA() *ACA {
-	aca := &ACA{CA: NewCA("aca", initializeACATables)}
+// newAttributePair converts an attribute received in a request. An
+// attribute without validFrom is valid from now on.
+func newAttributePair(owner *AttributeOwner, att *pb.ACAAttribute, now time.Time) (*AttributePair, error) {
+	if att == nil || strings.TrimSpace(att.AttributeName) == "" {
+		return nil, errors.New("Invalid attribute.")
+	}
+	attrPair := &AttributePair{owner: owner, attributeName: strings.TrimSpace(att.AttributeName), attributeValue: att.AttributeValue, validFrom: now}
+	if att.ValidFrom != nil {
+		attrPair.validFrom = time.Unix(att.ValidFrom.Seconds, int64(att.ValidFrom.Nanos))
+	}
+	if att.ValidTo != nil {
+		attrPair.validTo = time.Unix(att.ValidTo.Seconds, int64(att.ValidTo.Nanos))
+		if !attrPair.validTo.After(attrPair.validFrom) {
+			return nil, errors.New("Attribute " + attrPair.attributeName + " expires before it is valid.")
+		}
+	}
+	return attrPair, nil
+}
+
+// NewACA sets up a new ACA. The members administering attributes are
+// authenticated by eca.
+func NewACA(eca *ECA) *ACA {
+	aca := &ACA{CA: NewCA("aca", initializeACATables), eca: eca}
 
 	return aca
 }
@@ -240,7 +255,9 @@ func (aca *ACA) getTCACertificate() (*x509.Certificate, error) {
 	return x509.ParseCertificate(raw)
 }
 
-func (aca *ACA) fetchAttributes(id, affiliation string) ([]*AttributePair, error) {
+// fetchAttributes reads the attributes of a user from the sources, only
+// those with the given names if any.
+func (aca *ACA) fetchAttributes(id, affiliation string, names ...string) ([]*AttributePair, error) {
 	// TODO this attributes should be readed from the outside world in place of configuration file.
 	var attributes = make([]*AttributePair, 0)
 	attrs := viper.GetStringMapString("aca.attributes")
@@ -261,6 +278,9 @@ func (aca *ACA) fetchAttributes(id, affiliation string) ([]*AttributePair, error
 				if attrPair.GetID() != id || attrPair.GetAffiliation() != affiliation {
 					continue
 				}
+				if len(names) > 0 && !containsAttributeName(names, attrPair.GetAttributeName()) {
+					continue
+				}
 				attributes = append(attributes, attrPair)
 			} else {
 				Error.Printf("Invalid attribute entry '%v'", vals[0])
@@ -322,9 +342,9 @@ func (aca *ACA) populateAttribute(tx *sql.Tx, attr *AttributePair) error {
 	return nil
 }
 
-func (aca *ACA) fetchAndPopulateAttributes(id, affiliation string) error {
+func (aca *ACA) fetchAndPopulateAttributes(id, affiliation string, names ...string) error {
 	var attrs []*AttributePair
-	attrs, err := aca.fetchAttributes(id, affiliation)
+	attrs, err := aca.fetchAttributes(id, affiliation, names...)
 	if err != nil {
 		return err
 	}
@@ -363,15 +383,130 @@ func (aca *ACA) findAttribute(owner *AttributeOwner, attributeName string) (*Att
 	return &AttributePair{owner, attName, attValue, validFrom, validTo}, nil
 }
 
+// readAttributes reads the attributes of owner, only those with the given
+// names if any.
+func (aca *ACA) readAttributes(owner *AttributeOwner, names ...string) ([]*AttributePair, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	rows, err := aca.db.Query("SELECT attributeName, attributeValue, validFrom, validTo FROM Attributes WHERE id=? AND affiliation=? ORDER BY attributeName",
+		owner.GetID(), owner.GetAffiliation())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attrs []*AttributePair
+	for rows.Next() {
+		attr := &AttributePair{owner: owner}
+		if err = rows.Scan(&attr.attributeName, &attr.attributeValue, &attr.validFrom, &attr.validTo); err != nil {
+			return nil, err
+		}
+		if len(names) > 0 && !containsAttributeName(names, attr.attributeName) {
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, rows.Err()
+}
+
+// updateAttributes adds the attributes, or replaces the value and validity
+// of those the owner already has.
+func (aca *ACA) updateAttributes(attrs []*AttributePair) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	tx, err := aca.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, attr := range attrs {
+		res, err := tx.Exec("UPDATE Attributes SET validFrom=?, validTo=?, attributeValue=? WHERE id=? AND affiliation=? AND attributeName=?",
+			attr.GetValidFrom(), attr.GetValidTo(), attr.GetAttributeValue(), attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName())
+		if err == nil {
+			var n int64
+			if n, err = res.RowsAffected(); err == nil && n == 0 {
+				_, err = tx.Exec("INSERT INTO Attributes (validFrom, validTo, attributeValue, id, affiliation, attributeName) VALUES (?,?,?,?,?,?)",
+					attr.GetValidFrom(), attr.GetValidTo(), attr.GetAttributeValue(), attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName())
+			}
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// expireAttributes makes the attributes of owner with the given names, or
+// all of them, expire at the given time. Attributes which expire earlier
+// are left alone.
+func (aca *ACA) expireAttributes(owner *AttributeOwner, at time.Time, names ...string) error {
+	attrs, err := aca.readAttributes(owner, names...)
+	if err != nil {
+		return err
+	}
+
+	var expired []*AttributePair
+	for _, attr := range attrs {
+		if !attr.validTo.IsZero() && !attr.validTo.After(at) {
+			continue
+		}
+		attr.validTo = at
+		if attr.validFrom.After(at) {
+			attr.validFrom = at
+		}
+		expired = append(expired, attr)
+	}
+	return aca.updateAttributes(expired)
+}
+
+// readAttributeOwner returns the owner of the attributes of the member id,
+// as registered by the ECA.
+func (aca *ACA) readAttributeOwner(id string) (*AttributeOwner, error) {
+	if aca.eca == nil {
+		return nil, errors.New("The ACA cannot look up members.")
+	}
+
+	mutex.RLock()
+	var enrollID string
+	err := aca.eca.db.QueryRow("SELECT enrollmentId FROM Users WHERE id=?", id).Scan(&enrollID)
+	mutex.RUnlock()
+	if err != nil {
+		return nil, errors.New("Unknown member " + id + ".")
+	}
+
+	id, affiliation, err := aca.parseEnrollID(enrollID)
+	if err != nil {
+		return nil, err
+	}
+	return &AttributeOwner{id, affiliation}, nil
+}
+
+func containsAttributeName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.TrimSpace(n) == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (aca *ACA) startACAP(srv *grpc.Server) {
 	pb.RegisterACAPServer(srv, &ACAP{aca})
 	Info.Println("ACA PUBLIC gRPC API server started")
 }
 
+func (aca *ACA) startACAA(srv *grpc.Server) {
+	pb.RegisterACAAServer(srv, &ACAA{aca})
+	Info.Println("ACA ADMIN gRPC API server started")
+}
+
 // Start starts the ACA.
 func (aca *ACA) Start(srv *grpc.Server) {
 	Info.Println("Staring ACA services...")
 	aca.startACAP(srv)
+	aca.startACAA(srv)
 	aca.gRPCServer = srv
 	Info.Println("ACA services started")
 }
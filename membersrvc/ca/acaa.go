@@ -0,0 +1,142 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"golang.org/x/net/context"
+)
+
+// ACAA serves the administrator GRPC interface of the ACA.
+//
+type ACAA struct {
+	aca *ACA
+}
+
+// ReadAttributes lists the attributes of a user. Users can read their own
+// attributes, admins those of the members they may register.
+func (acaa *ACAA) ReadAttributes(ctx context.Context, in *pb.ACAAttrReadReq) (*pb.ACAAttrReadResp, error) {
+	Trace.Println("grpc ACAA:ReadAttributes")
+
+	if in.Id == nil || in.User == nil {
+		return nil, errors.New("Invalid attributes request.")
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := acaa.authorize(in.Id.Id, in.User.Id, in, sig, acaa.aca.eca.canRevoke); err != nil {
+		return nil, err
+	}
+
+	owner, err := acaa.aca.readAttributeOwner(in.User.Id)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := acaa.aca.readAttributes(owner, in.AttributeNames...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	resp := &pb.ACAAttrReadResp{}
+	for _, attr := range attrs {
+		if in.ValidOnly && !attr.IsValidFor(now) {
+			continue
+		}
+		resp.Attributes = append(resp.Attributes, attr.ToACAAttribute())
+	}
+	return resp, nil
+}
+
+// UpdateAttributes adds attributes to a user or replaces their value and
+// validity. Admins can update the attributes of the members they may
+// register.
+func (acaa *ACAA) UpdateAttributes(ctx context.Context, in *pb.ACAAttrUpdateReq) (*pb.CAStatus, error) {
+	Trace.Println("grpc ACAA:UpdateAttributes")
+
+	if in.Id == nil || in.User == nil || len(in.Attributes) == 0 {
+		return nil, errors.New("Invalid attributes request.")
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := acaa.authorize(in.Id.Id, in.User.Id, in, sig, acaa.aca.eca.canAdminister); err != nil {
+		return nil, err
+	}
+
+	owner, err := acaa.aca.readAttributeOwner(in.User.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	attrs := make([]*AttributePair, len(in.Attributes))
+	for i, att := range in.Attributes {
+		if attrs[i], err = newAttributePair(owner, att, now); err != nil {
+			return nil, err
+		}
+	}
+	if err = acaa.aca.updateAttributes(attrs); err != nil {
+		return nil, err
+	}
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// ExpireAttributes makes attributes of a user expire now, so that they are
+// no longer included in his/her TCerts. Admins can expire the attributes of
+// the members they may register.
+func (acaa *ACAA) ExpireAttributes(ctx context.Context, in *pb.ACAAttrExpireReq) (*pb.CAStatus, error) {
+	Trace.Println("grpc ACAA:ExpireAttributes")
+
+	if in.Id == nil || in.User == nil {
+		return nil, errors.New("Invalid attributes request.")
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := acaa.authorize(in.Id.Id, in.User.Id, in, sig, acaa.aca.eca.canAdminister); err != nil {
+		return nil, err
+	}
+
+	owner, err := acaa.aca.readAttributeOwner(in.User.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err = acaa.aca.expireAttributes(owner, time.Now(), in.AttributeNames...); err != nil {
+		return nil, err
+	}
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// authorize checks that the request in was signed by the member requester,
+// and that allow lets requester manage the attributes of user.
+func (acaa *ACAA) authorize(requester, user string, in proto.Message, sig *pb.Signature, allow func(requester, user string) error) error {
+	if acaa.aca.eca == nil {
+		return errors.New("The ACA cannot authenticate members.")
+	}
+	if err := acaa.aca.eca.checkSignature(requester, in, sig); err != nil {
+		return err
+	}
+	if err := allow(requester, user); err != nil {
+		return errors.New("Access denied.")
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"google/protobuf"
+	"testing"
+	"time"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"golang.org/x/net/context"
+)
+
+func TestAttributesLifecycle(t *testing.T) {
+	registrar := User{enrollID: "testAttrRegistrar", enrollPwd: []byte("h7Q2mZ0vXk3e")}
+	if _, err := eca.registerUser(registrar.enrollID, "institution_a", pb.Role_CLIENT, "", `{"registrar":{"roles":["client"]}}`, string(registrar.enrollPwd)); err != nil {
+		t.Fatalf("Failed registering the registrar: [%s]", err)
+	}
+	user := User{enrollID: "testAttrUser", enrollPwd: []byte("c4Wn8pLr2yTd")}
+	if _, err := eca.registerUser(user.enrollID, "institution_a", pb.Role_CLIENT, "", "", string(user.enrollPwd)); err != nil {
+		t.Fatalf("Failed registering the user: [%s]", err)
+	}
+	for _, u := range []*User{&registrar, &user} {
+		if err := enrollUser(u); err != nil {
+			t.Fatalf("Failed enrolling %s: [%s]", u.enrollID, err)
+		}
+	}
+
+	acaa := &ACAA{aca}
+	readAttributes := func(validOnly bool, names ...string) []*pb.ACAAttribute {
+		req := &pb.ACAAttrReadReq{Id: &pb.Identity{Id: user.enrollID}, User: &pb.Identity{Id: user.enrollID}, AttributeNames: names, ValidOnly: validOnly}
+		sig, err := signRevocationRequest(user.enrollPrivKey, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Sig = sig
+		resp, err := acaa.ReadAttributes(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Failed reading the attributes: [%s]", err)
+		}
+		return resp.Attributes
+	}
+
+	update := &pb.ACAAttrUpdateReq{
+		Id:   &pb.Identity{Id: registrar.enrollID},
+		User: &pb.Identity{Id: user.enrollID},
+		Attributes: []*pb.ACAAttribute{
+			{AttributeName: "role", AttributeValue: []byte("auditor")},
+			{AttributeName: "level", AttributeValue: []byte("3"), ValidTo: &google_protobuf.Timestamp{Seconds: time.Now().Add(time.Hour).Unix()}},
+		},
+	}
+	sig, err := signRevocationRequest(registrar.enrollPrivKey, update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update.Sig = sig
+	if _, err = acaa.UpdateAttributes(context.Background(), update); err != nil {
+		t.Fatalf("Failed updating the attributes: [%s]", err)
+	}
+
+	if attrs := readAttributes(true); len(attrs) != 2 {
+		t.Fatalf("Expected 2 attributes, got %d", len(attrs))
+	}
+	attrs := readAttributes(false, "role")
+	if len(attrs) != 1 || attrs[0].AttributeName != "role" || string(attrs[0].AttributeValue) != "auditor" {
+		t.Fatalf("Expected the role attribute only, got %v", attrs)
+	}
+
+	// A user cannot update his/her own attributes
+	update.Id, update.Sig = &pb.Identity{Id: user.enrollID}, nil
+	if update.Sig, err = signRevocationRequest(user.enrollPrivKey, update); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = acaa.UpdateAttributes(context.Background(), update); err == nil {
+		t.Fatal("The user should not be allowed to update his/her attributes")
+	}
+
+	expire := &pb.ACAAttrExpireReq{Id: &pb.Identity{Id: registrar.enrollID}, User: &pb.Identity{Id: user.enrollID}, AttributeNames: []string{"level"}}
+	if _, err = acaa.ExpireAttributes(context.Background(), expire); err == nil {
+		t.Fatal("An unsigned request should be rejected")
+	}
+	if expire.Sig, err = signRevocationRequest(registrar.enrollPrivKey, expire); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = acaa.ExpireAttributes(context.Background(), expire); err != nil {
+		t.Fatalf("Failed expiring the attributes: [%s]", err)
+	}
+
+	// Let the expiry time pass
+	time.Sleep(10 * time.Millisecond)
+	attrs = readAttributes(true)
+	if len(attrs) != 1 || attrs[0].AttributeName != "role" {
+		t.Fatalf("Expected the role attribute only to be valid, got %v", attrs)
+	}
+	if attrs = readAttributes(false); len(attrs) != 2 {
+		t.Fatalf("Expired attributes should still be listed, got %v", attrs)
+	}
+}
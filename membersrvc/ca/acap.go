@@ -77,7 +77,7 @@ func (acap *ACAP) FetchAttributes(ctx context.Context, in *pb.ACAFetchAttrReq) (
 		return &pb.ACAFetchAttrResp{Status: pb.ACAFetchAttrResp_FAILURE}, err
 	}
 
-	err = acap.aca.fetchAndPopulateAttributes(id, affiliation)
+	err = acap.aca.fetchAndPopulateAttributes(id, affiliation, in.AttributeNames...)
 	if err != nil {
 		return &pb.ACAFetchAttrResp{Status: pb.ACAFetchAttrResp_FAILURE}, err
 	}
@@ -222,10 +222,10 @@ func (acap *ACAP) addAttributesToExtensions(attributes *[]AttributePair, extensi
 	return exts, nil
 }
 
-// ReadCACertificate reads the certificate of the ACA.
+// ReadCACertificate reads the certificate of the ACA, along with the certificates of its issuers.
 //
 func (acap *ACAP) ReadCACertificate(ctx context.Context, in *pb.Empty) (*pb.Cert, error) {
 	Trace.Println("grpc ACAP:ReadCACertificate")
 
-	return &pb.Cert{Cert: acap.aca.raw}, nil
+	return &pb.Cert{Cert: acap.aca.raw, Chain: acap.aca.chain}, nil
 }
@@ -0,0 +1,201 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+
+	"google/protobuf"
+)
+
+// The actions recorded in the audit log.
+//
+const (
+	auditRegistration = "registration"
+	auditEnrollment   = "enrollment"
+	auditReEnrollment = "reenrollment"
+	auditTCerts       = "tcerts"
+	auditCredential   = "credential"
+	auditTLSCert      = "tlscert"
+	auditRevocation   = "revocation"
+	auditLockout      = "lockout"
+	auditToken        = "token"
+)
+
+// auditDirectoryAgent is the requester recorded for the registrations of the
+// members of the LDAP directory.
+//
+const auditDirectoryAgent = "ldap"
+
+// initializeAuditTables creates the audit log of the ECA. The triggers keep
+// the log append-only.
+//
+func initializeAuditTables(db *sql.DB) error {
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS AuditLog (row INTEGER PRIMARY KEY AUTOINCREMENT, timestamp INTEGER, action VARCHAR(16), requester VARCHAR(64), subject VARCHAR(64), detail TEXT, error TEXT)"); err != nil {
+		return err
+	}
+	if d := databaseDialect(db); d != nil {
+		for _, trigger := range d.auditTriggers {
+			if _, err := db.Exec(trigger); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, err := db.Exec("CREATE TRIGGER IF NOT EXISTS AuditLogNoUpdate BEFORE UPDATE ON AuditLog BEGIN SELECT RAISE(ABORT, 'The audit log is append-only.'); END"); err != nil {
+		return err
+	}
+	if _, err := db.Exec("CREATE TRIGGER IF NOT EXISTS AuditLogNoDelete BEFORE DELETE ON AuditLog BEGIN SELECT RAISE(ABORT, 'The audit log is append-only.'); END"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// audit records that requester asked for action on subject, and its result.
+// The request has been served already, a failure to record it is only
+// logged.
+//
+func (eca *ECA) audit(action, requester, subject, detail string, result error) {
+	var errMsg string
+	if result != nil {
+		errMsg = result.Error()
+	}
+
+	mutex.Lock()
+	_, err := eca.db.Exec("INSERT INTO AuditLog (timestamp, action, requester, subject, detail, error) VALUES (?, ?, ?, ?, ?, ?)",
+		time.Now().UnixNano(), action, requester, subject, detail, errMsg)
+	mutex.Unlock()
+
+	if err != nil {
+		Error.Printf("Failed to record the %s of %s by %s in the audit log: %s", action, subject, requester, err)
+	}
+}
+
+// identityOf returns the identifier of id, empty if id is missing from a
+// request.
+//
+func identityOf(id *pb.Identity) string {
+	if id == nil {
+		return ""
+	}
+	return id.Id
+}
+
+// describeRegistration describes the role and affiliation a member is
+// registered with.
+//
+func describeRegistration(role pb.Role, affiliation string) string {
+	name := role2String(int(role))
+	if name == "" {
+		// a combination of roles
+		name = fmt.Sprintf("%d", role)
+	}
+	return fmt.Sprintf("role %s, affiliation %s", name, affiliation)
+}
+
+// describeCertificates lists the serial numbers of the certificates raw.
+//
+func describeCertificates(raw ...[]byte) string {
+	var serials []string
+	for _, r := range raw {
+		if cert, err := x509.ParseCertificate(r); err == nil {
+			serials = append(serials, cert.SerialNumber.String())
+		}
+	}
+	return "serials " + strings.Join(serials, ", ")
+}
+
+// describeCertificateSet describes the TCerts requested by in, and those
+// issued in resp if any.
+//
+func describeCertificateSet(in *pb.TCertCreateSetReq, resp *pb.TCertCreateSetResp) string {
+	var timestamp int64
+	if in.Ts != nil {
+		timestamp = in.Ts.Seconds
+	}
+	if resp == nil || resp.Certs == nil {
+		return fmt.Sprintf("%d TCerts requested, set %d", in.Num, timestamp)
+	}
+	return fmt.Sprintf("%d TCerts issued, set %d", len(resp.Certs.Certs), timestamp)
+}
+
+// readAuditLog returns the entries of the audit log matching the filters of
+// in, in the order they were recorded. With a limit, only the most recent
+// entries are returned.
+//
+func (eca *ECA) readAuditLog(in *pb.AuditLogReq) ([]*pb.AuditEntry, error) {
+	var conds []string
+	var args []interface{}
+	if in.Start != nil {
+		conds = append(conds, "timestamp>=?")
+		args = append(args, time.Unix(in.Start.Seconds, int64(in.Start.Nanos)).UnixNano())
+	}
+	if in.End != nil {
+		conds = append(conds, "timestamp<?")
+		args = append(args, time.Unix(in.End.Seconds, int64(in.End.Nanos)).UnixNano())
+	}
+	for column, value := range map[string]string{"action": in.Action, "requester": in.Requester, "subject": in.Subject} {
+		if value != "" {
+			conds = append(conds, column+"=?")
+			args = append(args, value)
+		}
+	}
+
+	query := "SELECT row, timestamp, action, requester, subject, detail, error FROM AuditLog"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY row DESC"
+	if in.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", in.Limit)
+	}
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	rows, err := eca.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*pb.AuditEntry
+	for rows.Next() {
+		var entry pb.AuditEntry
+		var ts int64
+		if err := rows.Scan(&entry.Seq, &ts, &entry.Action, &entry.Requester, &entry.Subject, &entry.Detail, &entry.Error); err != nil {
+			return nil, err
+		}
+		entry.Ts = &google_protobuf.Timestamp{Seconds: ts / int64(time.Second), Nanos: int32(ts % int64(time.Second))}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// most recent first for the limit, in the order recorded for the reader
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
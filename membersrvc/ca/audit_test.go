@@ -0,0 +1,94 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"testing"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"golang.org/x/net/context"
+)
+
+func readAuditLog(reader *User, in *pb.AuditLogReq) ([]*pb.AuditEntry, error) {
+	in.Req = &pb.Identity{Id: reader.enrollID}
+	sig, err := signRevocationRequest(reader.enrollPrivKey, in)
+	if err != nil {
+		return nil, err
+	}
+	in.Sig = sig
+
+	ecaa := &ECAA{eca}
+	resp, err := ecaa.ReadAuditLog(context.Background(), in)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Entries, nil
+}
+
+func TestAuditLog(t *testing.T) {
+	auditor := User{enrollID: "testAuditLogAuditor", role: int(pb.Role_AUDITOR)}
+	user := User{enrollID: "testAuditLogUser", role: int(pb.Role_CLIENT), affiliation: "institution_a"}
+	for _, u := range []*User{&auditor, &user} {
+		tok, err := eca.registerUser(u.enrollID, u.affiliation, pb.Role(u.role), "", "")
+		if err != nil {
+			t.Fatalf("Failed to register %s: [%s]", u.enrollID, err)
+		}
+		u.enrollPwd = []byte(tok)
+		if err := enrollUser(u); err != nil {
+			t.Fatalf("Failed to enroll %s: [%s]", u.enrollID, err)
+		}
+	}
+
+	entries, err := readAuditLog(&auditor, &pb.AuditLogReq{Subject: user.enrollID})
+	if err != nil {
+		t.Fatalf("Failed to read the audit log: [%s]", err)
+	}
+	if len(entries) != 1 || entries[0].Action != auditEnrollment || entries[0].Requester != user.enrollID || entries[0].Detail == "" || entries[0].Error != "" {
+		t.Fatalf("Expected the enrollment of %s, got %v", user.enrollID, entries)
+	}
+
+	// failures are recorded as well
+	other := User{enrollID: "testAuditLogOther", role: int(pb.Role_CLIENT), affiliation: "institution_a"}
+	if err := registerUser(user, &other); err == nil {
+		t.Fatal("A member which is not a registrar should not register members")
+	}
+	entries, err = readAuditLog(&auditor, &pb.AuditLogReq{Action: auditRegistration, Requester: user.enrollID, Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to read the audit log: [%s]", err)
+	}
+	if len(entries) != 1 || entries[0].Subject != other.enrollID || entries[0].Error == "" {
+		t.Fatalf("Expected the failed registration of %s, got %v", other.enrollID, entries)
+	}
+
+	if _, err := readAuditLog(&user, &pb.AuditLogReq{}); err == nil {
+		t.Fatal("Only auditors should read the audit log")
+	}
+}
+
+func TestAuditLogAppendOnly(t *testing.T) {
+	eca.audit(auditRevocation, "testAuditLogRequester", "testAuditLogSubject", "", nil)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, err := eca.db.Exec("UPDATE AuditLog SET requester=? WHERE subject=?", "someoneElse", "testAuditLogSubject"); err == nil {
+		t.Fatal("The audit log entries should not be updated")
+	}
+	if _, err := eca.db.Exec("DELETE FROM AuditLog WHERE subject=?", "testAuditLogSubject"); err == nil {
+		t.Fatal("The audit log entries should not be deleted")
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// errNoCRL is returned by the signing backends which do not issue CRLs.
+//
+var errNoCRL = errors.New("The CRLs of the CA are issued by the external CA.")
+
+// signingBackend issues the certificates of a CA. The CA signs them with its
+// own key by default, or forwards them to an external CA which holds the
+// signing key.
+//
+type signingBackend interface {
+	// issue returns the DER encoded certificate issued for pub as described
+	// by tmpl. The backend may assign another serial number.
+	issue(tmpl *x509.Certificate, pub interface{}) ([]byte, error)
+
+	// createCRL returns a DER encoded CRL listing the revoked certificates,
+	// or errNoCRL.
+	createCRL(revoked []pkix.RevokedCertificate, now, nextUpdate time.Time) ([]byte, error)
+}
+
+// localBackend signs the certificates of a CA with the key of the CA.
+//
+type localBackend struct {
+	ca *CA
+}
+
+func (b *localBackend) issue(tmpl *x509.Certificate, pub interface{}) ([]byte, error) {
+	return x509.CreateCertificate(rand.Reader, tmpl, b.ca.cert, pub, b.ca.priv)
+}
+
+func (b *localBackend) createCRL(revoked []pkix.RevokedCertificate, now, nextUpdate time.Time) ([]byte, error) {
+	return b.ca.cert.CreateCRL(rand.Reader, b.ca.priv, revoked, now, nextUpdate)
+}
+
+// newExternalBackend returns the backend of pki.backend.type forwarding the
+// certificates of the CA name to an external CA, when name is listed in
+// pki.backend.cas, along with the certificate of the external CA followed by
+// the certificates of its issuers.
+//
+func newExternalBackend(name string) (signingBackend, [][]byte, error) {
+	external := false
+	for _, ca := range viper.GetStringSlice("pki.backend.cas") {
+		external = external || ca == name
+	}
+	if !external {
+		return nil, nil, nil
+	}
+	if name != "eca" && name != "tlsca" {
+		// the TCA derives the keys of the TCerts it issues
+		return nil, nil, fmt.Errorf("Only the eca and tlsca can use an external signing backend, not the %s", name)
+	}
+
+	switch backend := viper.GetString("pki.backend.type"); backend {
+	case "cmp":
+		b, err := newCMPBackend("pki.backend.cmp")
+		if err != nil {
+			return nil, nil, err
+		}
+		return b, b.chain, nil
+	default:
+		return nil, nil, fmt.Errorf("Unknown signing backend %q for the %s", backend, name)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package ca
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/x509"
@@ -35,8 +36,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/hyperledger/fabric/core/crypto/pkcs11"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"golang.org/x/crypto/ed25519"
 
 	_ "github.com/mattn/go-sqlite3" // This blank import is required to load sqlite3 driver
 	"github.com/spf13/viper"
@@ -46,11 +49,27 @@ import (
 type CA struct {
 	db *sql.DB
 
+	name string
 	path string
 
-	priv *ecdsa.PrivateKey
+	priv crypto.Signer // An *ecdsa.PrivateKey, or a key held by an HSM
 	cert *x509.Certificate
 	raw  []byte
+
+	// The certificates of the issuers of cert up to the root, when cert was
+	// issued by an operator-provided CA rather than self-signed
+	chain [][]byte
+
+	issuer *caIssuer // The CA issuing cert, while it is created
+
+	backend signingBackend // Issues the certificates of the CA
+
+	// The last CRL issued, and the channels of the CRL watchers
+	crlMutex       sync.Mutex
+	crl            []byte
+	crlNextUpdate  time.Time
+	crlRevocations string // the state of the revocations the CRL lists
+	crlWatchers    map[chan []byte]struct{}
 }
 
 // CertificateSpec defines the parameter used to create a new certificate.
@@ -212,6 +231,9 @@ func initializeCommonTables(db *sql.DB) error {
 	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Users (row INTEGER PRIMARY KEY, id VARCHAR(64), enrollmentId VARCHAR(100), role INTEGER, metadata VARCHAR(256), token BLOB, state INTEGER, key BLOB)"); err != nil {
 		return err
 	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Revocations (row INTEGER PRIMARY KEY, serial VARCHAR(64), id VARCHAR(64), timestamp INTEGER)"); err != nil {
+		return err
+	}
 	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS AffiliationGroups (row INTEGER PRIMARY KEY, name VARCHAR(64), parent INTEGER, FOREIGN KEY(parent) REFERENCES AffiliationGroups(row))"); err != nil {
 		return err
 	}
@@ -221,6 +243,7 @@ func initializeCommonTables(db *sql.DB) error {
 // NewCA sets up a new CA.
 func NewCA(name string, initTables TableInitializer) *CA {
 	ca := new(CA)
+	ca.name = name
 	ca.path = filepath.Join(rootPath, caDir)
 
 	if _, err := os.Stat(ca.path); err != nil {
@@ -232,7 +255,7 @@ func NewCA(name string, initTables TableInitializer) *CA {
 	}
 
 	// open or create certificate database
-	db, err := sql.Open("sqlite3", ca.path+"/"+name+".db")
+	db, err := openDatabase(ca.path, name)
 	if err != nil {
 		Panic.Panicln(err)
 	}
@@ -246,26 +269,65 @@ func NewCA(name string, initTables TableInitializer) *CA {
 	}
 	ca.db = db
 
-	// read or create signing key pair
-	priv, err := ca.readCAPrivateKey(name)
+	// open the signing key on the HSM, or read or create the signing key pair
+	hsm, err := pkcs11.GetConfig("pki.pkcs11")
 	if err != nil {
-		priv = ca.createCAKeyPair(name)
+		Panic.Panicln(err)
+	}
+	if hsm != nil {
+		priv, err := ca.openCAPrivateKey(hsm, name)
+		if err != nil {
+			Panic.Panicln(err)
+		}
+		ca.priv = priv
+	} else {
+		priv, err := ca.readCAPrivateKey(name)
+		if err != nil {
+			priv = ca.createCAKeyPair(name)
+		}
+		ca.priv = priv
 	}
-	ca.priv = priv
 
-	// read CA certificate, or create a self-signed CA certificate
+	// read CA certificate, or create a CA certificate, self-signed or issued
+	// by the CA of pki.ca.issuer
 	raw, err := ca.readCACertificate(name)
 	if err != nil {
-		raw = ca.createCACertificate(name, &ca.priv.PublicKey)
+		if ca.issuer, err = readCAIssuer(); err != nil {
+			Panic.Panicln(err)
+		}
+		raw = ca.createCACertificate(name, ca.priv.Public().(*ecdsa.PublicKey))
 	}
 	cert, err := x509.ParseCertificate(raw)
 	if err != nil {
 		Panic.Panicln(err)
 	}
+	if err = checkCAKeyPair(cert, ca.priv); err != nil {
+		Panic.Panicln(err)
+	}
+	if ca.chain, err = ca.readCAChain(name); err != nil {
+		Panic.Panicln(err)
+	}
 
 	ca.raw = raw
 	ca.cert = cert
 
+	// forward the certificates to the external CA of pki.backend, if any,
+	// which the CA then presents as its certificate; the own certificate of
+	// the CA still certifies the key signing the requests among the CAs
+	backend, chain, err := newExternalBackend(name)
+	if err != nil {
+		Panic.Panicln(err)
+	}
+	if backend != nil {
+		if ca.cert, err = x509.ParseCertificate(chain[0]); err != nil {
+			Panic.Panicln(err)
+		}
+		ca.raw, ca.chain = chain[0], chain[1:]
+		ca.backend = backend
+	} else {
+		ca.backend = &localBackend{ca}
+	}
+
 	return ca
 }
 
@@ -328,6 +390,21 @@ func (ca *CA) readCAPrivateKey(name string) (*ecdsa.PrivateKey, error) {
 	return x509.ParseECPrivateKey(block.Bytes)
 }
 
+// openCAPrivateKey returns the signing key labeled name on the HSM, which
+// holds the keys of the CAs instead of the .priv files
+func (ca *CA) openCAPrivateKey(hsm *pkcs11.Config, name string) (crypto.Signer, error) {
+	Trace.Println("Opening CA private key on the HSM.")
+
+	priv, err := pkcs11.NewSigner(hsm, name)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening the %s key on the HSM: %s", name, err)
+	}
+	if _, ok := priv.Public().(*ecdsa.PublicKey); !ok {
+		return nil, fmt.Errorf("The %s key on the HSM is not an ECDSA key", name)
+	}
+	return priv, nil
+}
+
 func (ca *CA) createCACertificate(name string, pub *ecdsa.PublicKey) []byte {
 	Trace.Println("Creating CA certificate.")
 
@@ -346,6 +423,17 @@ func (ca *CA) createCACertificate(name string, pub *ecdsa.PublicKey) []byte {
 		Panic.Panicln(err)
 	}
 
+	if ca.issuer != nil {
+		var chain []byte
+		for _, raw := range ca.issuer.chain {
+			chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw})...)
+		}
+		err = ioutil.WriteFile(ca.path+"/"+name+".chain", chain, 0644)
+		if err != nil {
+			Panic.Panicln(err)
+		}
+	}
+
 	return raw
 }
 
@@ -407,6 +495,7 @@ func (ca *CA) newCertificateFromSpec(spec *CertificateSpec) ([]byte, error) {
 
 	parent := ca.cert
 	isCA := parent == nil
+	signer := ca.priv
 
 	tmpl := x509.Certificate{
 		SerialNumber: spec.GetSerialNumber(),
@@ -432,6 +521,16 @@ func (ca *CA) newCertificateFromSpec(spec *CertificateSpec) ([]byte, error) {
 	}
 	if isCA {
 		parent = &tmpl
+		if ca.issuer != nil {
+			// the signature algorithm of the key of the issuer
+			parent = ca.issuer.cert
+			signer = ca.issuer.priv
+			tmpl.SignatureAlgorithm = x509.UnknownSignatureAlgorithm
+		}
+	}
+
+	if !isCA {
+		return ca.backend.issue(&tmpl, spec.GetPublicKey())
 	}
 
 	raw, err := x509.CreateCertificate(
@@ -439,9 +538,9 @@ func (ca *CA) newCertificateFromSpec(spec *CertificateSpec) ([]byte, error) {
 		&tmpl,
 		parent,
 		spec.GetPublicKey(),
-		ca.priv,
+		signer,
 	)
-	if isCA && err != nil {
+	if err != nil {
 		Panic.Panicln(err)
 	}
 
@@ -455,7 +554,8 @@ func (ca *CA) readCertificateByKeyUsage(id string, usage x509.KeyUsage) ([]byte,
 	defer mutex.RUnlock()
 
 	var raw []byte
-	err := ca.db.QueryRow("SELECT cert FROM Certificates WHERE id=? AND usage=?", id, usage).Scan(&raw)
+	// the latest one, after re-enrollments
+	err := ca.db.QueryRow("SELECT cert FROM Certificates WHERE id=? AND usage=? ORDER BY row DESC", id, usage).Scan(&raw)
 
 	if err != nil {
 		Trace.Printf("readCertificateByKeyUsage() Error: %v", err)
@@ -486,7 +586,8 @@ func (ca *CA) readCertificates(id string, opt ...int64) (*sql.Rows, error) {
 		return ca.db.Query("SELECT cert, kdfkey FROM Certificates WHERE id=? AND timestamp=? ORDER BY usage", id, opt[0])
 	}
 
-	return ca.db.Query("SELECT cert, kdfkey FROM Certificates WHERE id=?", id)
+	// the latest certificates, after re-enrollments
+	return ca.db.Query("SELECT cert, kdfkey FROM Certificates WHERE id=? AND timestamp=(SELECT MAX(timestamp) FROM Certificates WHERE id=?) ORDER BY usage", id, id)
 }
 
 func (ca *CA) readCertificateSets(id string, start, end int64) (*sql.Rows, error) {
@@ -897,6 +998,26 @@ func strContained(str string, strs []string) bool {
 	return false
 }
 
+// verifySignature checks that sig is the signature of raw by the holder of
+// pub, an ECDSA or Ed25519 public key. ECDSA signs the hash of raw, Ed25519
+// raw itself.
+func verifySignature(pub interface{}, raw []byte, sig *pb.Signature) bool {
+	r, s := big.NewInt(0), big.NewInt(0)
+	r.UnmarshalText(sig.R)
+	s.UnmarshalText(sig.S)
+
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		hash := primitives.NewHash()
+		hash.Write(raw)
+		return sig.Type == pb.CryptoType_ECDSA && ecdsa.Verify(pub, hash.Sum(nil), r, s)
+	case ed25519.PublicKey:
+		ok, err := primitives.Ed25519VerifyDirect(pub, raw, r, s)
+		return sig.Type == pb.CryptoType_ED25519 && err == nil && ok
+	}
+	return false
+}
+
 // convert a role to a string
 func role2String(role int) string {
 	if role == int(pb.Role_CLIENT) {
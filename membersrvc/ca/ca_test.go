@@ -17,8 +17,13 @@ limitations under the License.
 package ca
 
 import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"database/sql"
@@ -92,6 +97,65 @@ func TestNewCA(t *testing.T) {
 
 }
 
+func TestNewCAIssuedByIssuer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issuer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the root CA of the organization
+	root, rootPriv := newTestCertificate(t, "Organization Root CA", nil, nil, true)
+	keyRaw, _ := x509.MarshalECPrivateKey(rootPriv)
+	certFile, keyFile := filepath.Join(dir, "root.cert"), filepath.Join(dir, "root.key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyRaw}), 0600)
+
+	viper.Set("pki.ca.issuer.cert.file", certFile)
+	viper.Set("pki.ca.issuer.key.file", keyFile)
+	defer viper.Set("pki.ca.issuer.cert.file", "")
+	defer viper.Set("pki.ca.issuer.key.file", "")
+
+	const issuedName = "TestIssuedCA"
+	issued := NewCA(issuedName, initializeTables)
+	defer func() {
+		issued.Stop()
+		for _, ext := range []string{".cert", ".chain", ".db", ".priv", ".pub"} {
+			os.Remove(filepath.Join(issued.path, issuedName+ext))
+		}
+	}()
+
+	if err := issued.cert.CheckSignatureFrom(root); err != nil {
+		t.Fatalf("The CA certificate was not issued by the issuer: [%s]", err)
+	}
+	if !issued.cert.IsCA || len(issued.chain) != 1 || string(issued.chain[0]) != string(root.Raw) {
+		t.Fatal("The CA should be a CA with the certificate of its issuer as chain")
+	}
+
+	// the certificates issued by the CA chain to the root
+	priv, _ := ecdsa.GenerateKey(primitives.GetDefaultCurve(), rand.Reader)
+	raw, err := issued.newCertificate("testIssuedUser", &priv.PublicKey, x509.KeyUsageDigitalSignature, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(raw)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(issued.cert)
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Fatalf("The certificate issued by the CA does not chain to the root: [%s]", err)
+	}
+
+	// the chain is read back once the issuer is no longer configured
+	viper.Set("pki.ca.issuer.cert.file", "")
+	viper.Set("pki.ca.issuer.key.file", "")
+	chain, err := issued.readCAChain(issuedName)
+	if err != nil || len(chain) != 1 {
+		t.Fatalf("Failed reading back the chain: [%v]", err)
+	}
+}
+
 // Empty initializer for CA
 func initializeTables(db *sql.DB) error {
 	return nil
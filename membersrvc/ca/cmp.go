@@ -0,0 +1,458 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// The certificate management protocol (CMP, RFC 4210) messages exchanged
+// with the external CA. The CA acts as registration authority: it verified
+// the possession of the keys of the members, and protects its certification
+// requests with its own signature.
+
+const (
+	cmpVersion        = 2
+	cmpBodyCR         = 2 // certification request
+	cmpBodyCP         = 3 // certification response
+	cmpBodyError      = 23
+	cmpStatusAccepted = 0
+	cmpStatusGranted  = 1 // with modifications
+	cmpContentType    = "application/pkixcmp"
+	cmpTimeout        = 30 * time.Second
+)
+
+var (
+	oidCMPImplicitConfirm = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 4, 13}
+	oidECDSAWithSHA256    = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSHA256WithRSA      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+
+	// asn1Null is the ASN.1 NULL, the value of implicitConfirm and the
+	// parameters of RSA algorithm identifiers
+	asn1Null = asn1.RawValue{Tag: 5}
+)
+
+type cmpMessage struct {
+	Header     cmpHeader
+	Body       asn1.RawValue
+	Protection asn1.BitString  `asn1:"optional,explicit,tag:0"`
+	ExtraCerts []asn1.RawValue `asn1:"optional,explicit,tag:1"`
+}
+
+type cmpHeader struct {
+	PVNO          int
+	Sender        asn1.RawValue
+	Recipient     asn1.RawValue
+	MessageTime   time.Time                `asn1:"optional,explicit,tag:0,generalized"`
+	ProtectionAlg pkix.AlgorithmIdentifier `asn1:"optional,explicit,tag:1"`
+	SenderKID     []byte                   `asn1:"optional,explicit,tag:2"`
+	RecipKID      []byte                   `asn1:"optional,explicit,tag:3"`
+	TransactionID []byte                   `asn1:"optional,explicit,tag:4"`
+	SenderNonce   []byte                   `asn1:"optional,explicit,tag:5"`
+	RecipNonce    []byte                   `asn1:"optional,explicit,tag:6"`
+	FreeText      []string                 `asn1:"optional,explicit,tag:7,utf8"`
+	GeneralInfo   []cmpInfoTypeAndValue    `asn1:"optional,explicit,tag:8"`
+}
+
+type cmpInfoTypeAndValue struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"optional"`
+}
+
+// cmpRawMessage is a received message, whose protection is verified over
+// the header and body as encoded by the sender.
+type cmpRawMessage struct {
+	Header     asn1.RawValue
+	Body       asn1.RawValue
+	Protection asn1.BitString  `asn1:"optional,explicit,tag:0"`
+	ExtraCerts []asn1.RawValue `asn1:"optional,explicit,tag:1"`
+}
+
+// cmpProtectedPart is what the protection of a message signs.
+type cmpProtectedPart struct {
+	Header cmpHeader
+	Body   asn1.RawValue
+}
+
+type cmpRawProtectedPart struct {
+	Header asn1.RawValue
+	Body   asn1.RawValue
+}
+
+type cmpCertReqMsg struct {
+	CertReq    cmpCertRequest
+	RAVerified asn1.RawValue // the proof of possession, verified by the registration authority
+}
+
+type cmpCertRequest struct {
+	CertReqID    int
+	CertTemplate cmpCertTemplate
+}
+
+// The subject [5] and publicKey [6] of a template are tagged by hand, as
+// encoding/asn1 does not apply the tags of raw values.
+type cmpCertTemplate struct {
+	Validity   cmpValidity      `asn1:"optional,tag:4"`
+	Subject    asn1.RawValue    `asn1:"optional"`
+	PublicKey  asn1.RawValue    `asn1:"optional"`
+	Extensions []pkix.Extension `asn1:"optional,tag:9"`
+}
+
+type cmpValidity struct {
+	NotBefore time.Time `asn1:"optional,explicit,tag:0,utc"`
+	NotAfter  time.Time `asn1:"optional,explicit,tag:1,utc"`
+}
+
+type cmpCertRepMessage struct {
+	CAPubs   []asn1.RawValue `asn1:"optional,explicit,tag:1"`
+	Response []cmpCertResponse
+}
+
+type cmpCertResponse struct {
+	CertReqID        int
+	Status           cmpStatusInfo
+	CertifiedKeyPair cmpCertifiedKeyPair `asn1:"optional"`
+}
+
+type cmpStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional,utf8"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type cmpCertifiedKeyPair struct {
+	CertOrEncCert asn1.RawValue // certificate [0], unwrapped by hand
+}
+
+type cmpErrorMsgContent struct {
+	Status cmpStatusInfo
+}
+
+// cmpBackend forwards the certificates of a CA to an external CA over CMP.
+//
+type cmpBackend struct {
+	url    string
+	client *http.Client
+
+	caCert *x509.Certificate // The external CA, which issues the certificates
+	chain  [][]byte          // Its certificate followed by those of its issuers
+
+	raCert *x509.Certificate // The identity protecting the requests
+	raKey  crypto.Signer
+}
+
+// newCMPBackend sets up the CMP backend configured under key.
+//
+func newCMPBackend(key string) (*cmpBackend, error) {
+	b := &cmpBackend{url: viper.GetString(key + ".url")}
+	if b.url == "" {
+		return nil, errors.New(key + ".url is required")
+	}
+
+	cooked, err := ioutil.ReadFile(viper.GetString(key + ".ca.cert.file"))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the certificate of the external CA: %s", err)
+	}
+	if b.chain, err = decodeCertificates(cooked); err != nil {
+		return nil, err
+	}
+	if b.caCert, err = x509.ParseCertificate(b.chain[0]); err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(viper.GetString(key+".ra.cert.file"), viper.GetString(key+".ra.key.file"))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the registration authority identity: %s", err)
+	}
+	if b.raCert, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, err
+	}
+	var ok bool
+	if b.raKey, ok = cert.PrivateKey.(crypto.Signer); !ok {
+		return nil, errors.New("Unsupported registration authority key")
+	}
+
+	config := &tls.Config{}
+	if rootFile := viper.GetString(key + ".tls.rootcert.file"); rootFile != "" {
+		pem, err := ioutil.ReadFile(rootFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("No certificate found in " + rootFile)
+		}
+	}
+	b.client = &http.Client{Timeout: cmpTimeout, Transport: &http.Transport{TLSClientConfig: config}}
+
+	Info.Printf("Forwarding certificate requests to %s at %s", b.caCert.Subject.CommonName, b.url)
+	return b, nil
+}
+
+func (b *cmpBackend) issue(tmpl *x509.Certificate, pub interface{}) ([]byte, error) {
+	spki, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	var publicKey asn1.RawValue
+	if _, err = asn1.Unmarshal(spki, &publicKey); err != nil {
+		return nil, err
+	}
+	subject, err := asn1.Marshal(tmpl.Subject.ToRDNSequence())
+	if err != nil {
+		return nil, err
+	}
+
+	// the key usage is requested along with the other extensions
+	extensions := append([]pkix.Extension{}, tmpl.ExtraExtensions...)
+	if tmpl.KeyUsage != 0 {
+		usage, err := marshalKeyUsage(tmpl.KeyUsage)
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, usage)
+	}
+
+	req := []cmpCertReqMsg{{
+		CertReq: cmpCertRequest{
+			CertTemplate: cmpCertTemplate{
+				Validity:   cmpValidity{NotBefore: tmpl.NotBefore.UTC(), NotAfter: tmpl.NotAfter.UTC()},
+				Subject:    asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 5, IsCompound: true, Bytes: subject},
+				PublicKey:  asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 6, IsCompound: true, Bytes: publicKey.Bytes},
+				Extensions: extensions,
+			},
+		},
+		RAVerified: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0},
+	}}
+	body, err := asn1.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.exchange(cmpBodyCR, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Tag != cmpBodyCP {
+		return nil, fmt.Errorf("Unexpected CMP response body %d", resp.Tag)
+	}
+	var rep cmpCertRepMessage
+	if _, err = asn1.Unmarshal(resp.Bytes, &rep); err != nil {
+		return nil, err
+	}
+	if len(rep.Response) != 1 {
+		return nil, errors.New("The CMP response does not hold one certificate")
+	}
+	if err = rep.Response[0].Status.err(); err != nil {
+		return nil, err
+	}
+
+	certOrEncCert := rep.Response[0].CertifiedKeyPair.CertOrEncCert
+	if certOrEncCert.Class != asn1.ClassContextSpecific || certOrEncCert.Tag != 0 {
+		return nil, errors.New("The CMP response does not hold a certificate")
+	}
+	raw := certOrEncCert.Bytes
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err = cert.CheckSignatureFrom(b.caCert); err != nil {
+		return nil, fmt.Errorf("The certificate was not issued by the external CA: %s", err)
+	}
+	certPub, _ := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if !bytes.Equal(certPub, spki) {
+		return nil, errors.New("The external CA certified another key")
+	}
+	return raw, nil
+}
+
+func (b *cmpBackend) createCRL(revoked []pkix.RevokedCertificate, now, nextUpdate time.Time) ([]byte, error) {
+	return nil, errNoCRL
+}
+
+// exchange sends a protected message of the given body type to the external
+// CA, and returns the body of its protected response.
+//
+func (b *cmpBackend) exchange(bodyType int, content []byte) (*asn1.RawValue, error) {
+	alg, hash, err := cmpProtectionAlgorithm(b.raKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	transactionID, nonce := make([]byte, 16), make([]byte, 16)
+	rand.Read(transactionID)
+	rand.Read(nonce)
+
+	msg := cmpMessage{
+		Header: cmpHeader{
+			PVNO:          cmpVersion,
+			Sender:        cmpDirectoryName(b.raCert.RawSubject),
+			Recipient:     cmpDirectoryName(b.caCert.RawSubject),
+			MessageTime:   time.Now().UTC(),
+			ProtectionAlg: alg,
+			SenderKID:     b.raCert.SubjectKeyId,
+			TransactionID: transactionID,
+			SenderNonce:   nonce,
+			GeneralInfo:   []cmpInfoTypeAndValue{{Type: oidCMPImplicitConfirm, Value: asn1Null}},
+		},
+		Body:       asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: bodyType, IsCompound: true, Bytes: content},
+		ExtraCerts: []asn1.RawValue{{FullBytes: b.raCert.Raw}},
+	}
+	protected, err := asn1.Marshal(cmpProtectedPart{msg.Header, msg.Body})
+	if err != nil {
+		return nil, err
+	}
+	digest := hash.New()
+	digest.Write(protected)
+	sig, err := b.raKey.Sign(rand.Reader, digest.Sum(nil), hash)
+	if err != nil {
+		return nil, err
+	}
+	msg.Protection = asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)}
+
+	raw, err := asn1.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	httpResp, err := b.client.Post(b.url, cmpContentType, bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("The external CA answered with HTTP status %d", httpResp.StatusCode)
+	}
+	if contentType := httpResp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, cmpContentType) {
+		return nil, fmt.Errorf("Unexpected content type %q of the CMP response", contentType)
+	}
+	raw, err = ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp cmpRawMessage
+	if rest, err := asn1.Unmarshal(raw, &resp); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("Invalid CMP response: %v", err)
+	}
+	var header cmpHeader
+	if _, err = asn1.Unmarshal(resp.Header.FullBytes, &header); err != nil {
+		return nil, fmt.Errorf("Invalid CMP response header: %s", err)
+	}
+	if !bytes.Equal(header.TransactionID, transactionID) || !bytes.Equal(header.RecipNonce, nonce) {
+		return nil, errors.New("The CMP response does not answer the request")
+	}
+	if err = b.checkProtection(&header, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Body.Tag == cmpBodyError {
+		var content cmpErrorMsgContent
+		if _, err = asn1.Unmarshal(resp.Body.Bytes, &content); err != nil {
+			return nil, err
+		}
+		return nil, content.Status.err()
+	}
+	return &resp.Body, nil
+}
+
+// checkProtection verifies that the external CA signed a response.
+//
+func (b *cmpBackend) checkProtection(header *cmpHeader, msg *cmpRawMessage) error {
+	var alg x509.SignatureAlgorithm
+	switch {
+	case header.ProtectionAlg.Algorithm.Equal(oidECDSAWithSHA256):
+		alg = x509.ECDSAWithSHA256
+	case header.ProtectionAlg.Algorithm.Equal(oidSHA256WithRSA):
+		alg = x509.SHA256WithRSA
+	default:
+		return fmt.Errorf("Unsupported CMP protection algorithm %v", header.ProtectionAlg.Algorithm)
+	}
+
+	protected, err := asn1.Marshal(cmpRawProtectedPart{msg.Header, msg.Body})
+	if err != nil {
+		return err
+	}
+	if err = b.caCert.CheckSignature(alg, protected, msg.Protection.RightAlign()); err != nil {
+		return fmt.Errorf("The CMP response is not protected by the external CA: %s", err)
+	}
+	return nil
+}
+
+// cmpProtectionAlgorithm returns the algorithm protecting the messages signed
+// with the key of pub, and its hash function.
+//
+func cmpProtectionAlgorithm(pub crypto.PublicKey) (pkix.AlgorithmIdentifier, crypto.Hash, error) {
+	switch pub.(type) {
+	case *ecdsa.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}, crypto.SHA256, nil
+	case *rsa.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSHA256WithRSA, Parameters: asn1Null}, crypto.SHA256, nil
+	}
+	return pkix.AlgorithmIdentifier{}, 0, errors.New("Unsupported registration authority key type")
+}
+
+// cmpDirectoryName returns the GeneralName of a DER encoded distinguished name.
+//
+func cmpDirectoryName(name []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: name}
+}
+
+// marshalKeyUsage returns the key usage extension of usage.
+//
+func marshalKeyUsage(usage x509.KeyUsage) (pkix.Extension, error) {
+	var bits [2]byte
+	for i := uint(0); i < 9; i++ {
+		if usage&(1<<i) != 0 {
+			bits[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	length := 2
+	if bits[1] == 0 {
+		length = 1
+	}
+	bitLength := 8 * length
+	for bitLength > 0 && bits[(bitLength-1)/8]&(0x80>>uint((bitLength-1)%8)) == 0 {
+		bitLength--
+	}
+	value, err := asn1.Marshal(asn1.BitString{Bytes: bits[:length], BitLength: bitLength})
+	return pkix.Extension{Id: asn1.ObjectIdentifier{2, 5, 29, 15}, Critical: true, Value: value}, err
+}
+
+// err returns the error of a rejected request.
+//
+func (s cmpStatusInfo) err() error {
+	if s.Status == cmpStatusAccepted || s.Status == cmpStatusGranted {
+		return nil
+	}
+	return fmt.Errorf("The external CA rejected the request with status %d: %s", s.Status, strings.Join(s.StatusString, " "))
+}
@@ -0,0 +1,220 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/testutil"
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+)
+
+// fakeCMPServer is an external CA answering the certification requests of
+// its registration authority.
+type fakeCMPServer struct {
+	t    *testing.T
+	cert *x509.Certificate
+	priv *ecdsa.PrivateKey
+}
+
+func newTestCertificate(t *testing.T, cn string, parent *x509.Certificate, parentPriv *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	priv := testutil.NewKey(t)
+	tmpl := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: cn},
+		SubjectKeyId:          []byte(cn),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	return testutil.NewCertificate(t, tmpl, priv, parent, parentPriv), priv
+}
+
+func (s *fakeCMPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	raw, _ := ioutil.ReadAll(r.Body)
+	var msg cmpRawMessage
+	if _, err := asn1.Unmarshal(raw, &msg); err != nil {
+		s.t.Errorf("Invalid CMP request: [%s]", err)
+		return
+	}
+	var header cmpHeader
+	if _, err := asn1.Unmarshal(msg.Header.FullBytes, &header); err != nil {
+		s.t.Errorf("Invalid CMP request header: [%s]", err)
+		return
+	}
+	raCert, err := x509.ParseCertificate(msg.ExtraCerts[0].FullBytes)
+	if err != nil {
+		s.t.Errorf("Invalid registration authority certificate: [%s]", err)
+		return
+	}
+	protected, _ := asn1.Marshal(cmpRawProtectedPart{msg.Header, msg.Body})
+	if err := raCert.CheckSignature(x509.ECDSAWithSHA256, protected, msg.Protection.RightAlign()); err != nil {
+		s.t.Errorf("Invalid CMP request protection: [%s]", err)
+		return
+	}
+
+	var reqs []cmpCertReqMsg
+	if _, err := asn1.Unmarshal(msg.Body.Bytes, &reqs); err != nil || msg.Body.Tag != cmpBodyCR {
+		s.t.Errorf("Invalid certification request: [%v]", err)
+		return
+	}
+	tmpl := reqs[0].CertReq.CertTemplate
+	var subject pkix.RDNSequence
+	asn1.Unmarshal(tmpl.Subject.Bytes, &subject)
+	spki, _ := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true, Bytes: tmpl.PublicKey.Bytes})
+	pub, err := x509.ParsePKIXPublicKey(spki)
+	if err != nil {
+		s.t.Errorf("Invalid public key: [%s]", err)
+		return
+	}
+
+	var body asn1.RawValue
+	cert := &x509.Certificate{
+		SerialNumber:    big.NewInt(time.Now().UnixNano()),
+		NotBefore:       tmpl.Validity.NotBefore,
+		NotAfter:        tmpl.Validity.NotAfter,
+		ExtraExtensions: tmpl.Extensions,
+	}
+	cert.Subject.FillFromRDNSequence(&subject)
+	if cert.Subject.CommonName == "rejected" {
+		content, _ := asn1.Marshal(cmpErrorMsgContent{cmpStatusInfo{Status: 2, StatusString: []string{"badRequest"}}})
+		body = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: cmpBodyError, IsCompound: true, Bytes: content}
+	} else {
+		der, err := x509.CreateCertificate(rand.Reader, cert, s.cert, pub, s.priv)
+		if err != nil {
+			s.t.Errorf("Failed to issue the certificate: [%s]", err)
+			return
+		}
+		content, _ := asn1.Marshal(cmpCertRepMessage{Response: []cmpCertResponse{{
+			CertifiedKeyPair: cmpCertifiedKeyPair{asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}},
+		}}})
+		body = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: cmpBodyCP, IsCompound: true, Bytes: content}
+	}
+
+	resp := cmpMessage{
+		Header: cmpHeader{
+			PVNO:          cmpVersion,
+			Sender:        cmpDirectoryName(s.cert.RawSubject),
+			Recipient:     header.Sender,
+			ProtectionAlg: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+			TransactionID: header.TransactionID,
+			SenderNonce:   []byte("nonce"),
+			RecipNonce:    header.SenderNonce,
+		},
+		Body: body,
+	}
+	protected, _ = asn1.Marshal(cmpProtectedPart{resp.Header, resp.Body})
+	digest := sha256.Sum256(protected)
+	sig, _ := s.priv.Sign(rand.Reader, digest[:], crypto.SHA256)
+	resp.Protection = asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)}
+
+	raw, _ = asn1.Marshal(resp)
+	w.Header().Set("Content-Type", cmpContentType)
+	w.Write(raw)
+}
+
+func writeTestPEM(t *testing.T, path, typ string, der []byte) string {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCMPBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caCert, caPriv := newTestCertificate(t, "externalCA", nil, nil, true)
+	raCert, raPriv := newTestCertificate(t, "registrationAuthority", caCert, caPriv, false)
+	raKey, _ := x509.MarshalECPrivateKey(raPriv)
+
+	server := httptest.NewServer(&fakeCMPServer{t, caCert, caPriv})
+	defer server.Close()
+
+	viper.Set("test.cmp.url", server.URL)
+	viper.Set("test.cmp.ca.cert.file", writeTestPEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", caCert.Raw))
+	viper.Set("test.cmp.ra.cert.file", writeTestPEM(t, filepath.Join(dir, "ra.pem"), "CERTIFICATE", raCert.Raw))
+	viper.Set("test.cmp.ra.key.file", writeTestPEM(t, filepath.Join(dir, "ra.key"), "EC PRIVATE KEY", raKey))
+
+	b, err := newCMPBackend("test.cmp")
+	if err != nil {
+		t.Fatalf("Failed to set up the CMP backend: [%s]", err)
+	}
+	if len(b.chain) != 1 || !b.caCert.Equal(caCert) {
+		t.Fatal("The backend should present the certificate of the external CA")
+	}
+
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ext := pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte{byte(pb.Role_CLIENT)}}
+	tmpl := &x509.Certificate{
+		Subject:         pkix.Name{CommonName: "testCMPUser"},
+		NotBefore:       time.Now().Truncate(time.Second),
+		NotAfter:        time.Now().Add(time.Hour).Truncate(time.Second),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{ext},
+	}
+	raw, err := b.issue(tmpl, &priv.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to issue a certificate: [%s]", err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "testCMPUser" || cert.KeyUsage != x509.KeyUsageDigitalSignature || !cert.NotAfter.Equal(tmpl.NotAfter) {
+		t.Fatalf("The certificate does not follow the template: %v", cert.Subject)
+	}
+	role := false
+	for _, e := range cert.Extensions {
+		role = role || e.Id.Equal(ECertSubjectRole)
+	}
+	if !role {
+		t.Fatal("The certificate should carry the requested extensions")
+	}
+
+	tmpl.Subject.CommonName = "rejected"
+	if _, err := b.issue(tmpl, &priv.PublicKey); err == nil {
+		t.Fatal("A rejected request should fail")
+	}
+
+	// responses must be protected by the external CA
+	other, _ := newTestCertificate(t, "externalCA", nil, nil, true)
+	b.caCert = other
+	tmpl.Subject.CommonName = "testCMPUser"
+	if _, err := b.issue(tmpl, &priv.PublicKey); err == nil {
+		t.Fatal("A response protected by another CA should be refused")
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/crypto/idemix"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+var errCredentialsDisabled = errors.New("Anonymous credentials are not enabled.")
+
+// readIssuerKey reads the key the TCA issues anonymous credentials with if
+// tca.credentials.enabled is set, generating it on the first start. The key
+// fixes the attributes of the credentials.
+func (tca *TCA) readIssuerKey() error {
+	if !viper.GetBool("tca.credentials.enabled") {
+		return nil
+	}
+	if primitives.IsFIPSMode() {
+		return errors.New("Anonymous credentials are not supported in FIPS mode.")
+	}
+	attributes := viper.GetStringSlice("tca.credentials.attributes")
+
+	raw, err := ioutil.ReadFile(tca.path + "/tca.idemix")
+	if err == nil {
+		if tca.issuerKey, err = idemix.ParseIssuerKey(raw); err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(tca.issuerKey.Attributes, attributes) && len(tca.issuerKey.Attributes)+len(attributes) > 0 {
+			Warning.Printf("The credentials are issued on the attributes [%s] of the issuer key rather than on tca.credentials.attributes\n",
+				strings.Join(tca.issuerKey.Attributes, ", "))
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	params := idemix.DefaultParams
+	if modulus := viper.GetInt("tca.credentials.modulus"); modulus > 0 {
+		params.Modulus = modulus
+	}
+	Info.Printf("Generating the issuer key of the anonymous credentials, which may take a minute...\n")
+	isk, err := idemix.NewIssuerKey(params, attributes, rand.Reader)
+	if err != nil {
+		return err
+	}
+	if raw, err = isk.Bytes(); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(tca.path+"/tca.idemix", raw, 0600); err != nil {
+		return err
+	}
+	tca.issuerKey = isk
+	return nil
+}
+
+// ReadIssuerKey reads the public key the TCA issues anonymous credentials
+// with, signed by the TCA.
+func (tcap *TCAP) ReadIssuerKey(ctx context.Context, in *pb.Empty) (*pb.IssuerKey, error) {
+	Trace.Println("grpc TCAP:ReadIssuerKey")
+
+	isk := tcap.tca.issuerKey
+	if isk == nil {
+		return nil, errCredentialsDisabled
+	}
+	raw, err := isk.Public().Bytes()
+	if err != nil {
+		return nil, err
+	}
+	r, s, err := primitives.ECDSASignDirect(tcap.tca.priv, raw)
+	if err != nil {
+		return nil, err
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	return &pb.IssuerKey{Key: raw, Sig: &pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S}}, nil
+}
+
+// CreateCredential issues an anonymous credential on the secret committed
+// in the request and on the attributes the requester holds at the ACA. The
+// request is signed with the enrollment key of the requester.
+func (tcap *TCAP) CreateCredential(ctx context.Context, in *pb.CredentialCreateReq) (resp *pb.CredentialCreateResp, err error) {
+	Trace.Println("grpc TCAP:CreateCredential")
+
+	isk := tcap.tca.issuerKey
+	if isk == nil {
+		return nil, errCredentialsDisabled
+	}
+	defer func() {
+		tcap.tca.eca.audit(auditCredential, identityOf(in.Id), identityOf(in.Id), "credential on attributes "+strings.Join(isk.Attributes, ", "), err)
+	}()
+	if in.Ts == nil || in.Id == nil || in.Sig == nil {
+		return nil, errors.New("Invalid credential request.")
+	}
+
+	raw, err := tcap.tca.eca.readCertificateByKeyUsage(in.Id.Id, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		return nil, err
+	}
+
+	return tcap.createCredential(isk, raw, in)
+}
+
+// createCredential issues the credential of the request in, signed with the
+// key of the enrollment certificate raw
+func (tcap *TCAP) createCredential(isk *idemix.IssuerKey, raw []byte, in *pb.CredentialCreateReq) (*pb.CredentialCreateResp, error) {
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, err
+	}
+	if tcap.tca.eca.isRevoked(cert.SerialNumber) {
+		return nil, errors.New("The enrollment certificate has been revoked.")
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	rawReq, _ := proto.Marshal(in)
+	in.Sig = sig
+	if !verifySignature(cert.PublicKey, rawReq, sig) {
+		return nil, errors.New("signature does not verify")
+	}
+
+	req, err := idemix.ParseCredentialRequest(in.Request)
+	if err != nil {
+		return nil, err
+	}
+	values, err := tcap.credentialValues(in.Id.Id, raw, isk.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	issued, err := isk.Issue(req, CredentialNonce(in), values, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	rawIssued, err := issued.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CredentialCreateResp{Signature: rawIssued, Values: values}, nil
+}
+
+// credentialValues returns the values of the attributes the member id
+// holds at the ACA, empty for those it does not hold
+func (tcap *TCAP) credentialValues(id string, ecert []byte, attributes []string) ([]string, error) {
+	values := make([]string, len(attributes))
+	if len(attributes) == 0 || !viper.GetBool("aca.enabled") {
+		return values, nil
+	}
+
+	var names []*pb.TCertAttribute
+	for _, name := range attributes {
+		names = append(names, &pb.TCertAttribute{AttributeName: name})
+	}
+	held, err := tcap.requestAttributes(id, ecert, names)
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range attributes {
+		for _, attr := range held {
+			if attr.AttributeName == name {
+				values[i] = string(attr.AttributeValue)
+			}
+		}
+	}
+	return values, nil
+}
+
+// CredentialNonce returns the nonce the proof of a credential request is
+// bound to, the timestamp and the identity of the request
+func CredentialNonce(in *pb.CredentialCreateReq) []byte {
+	raw, _ := proto.Marshal(&pb.CredentialCreateReq{Ts: in.Ts, Id: in.Id})
+	return raw
+}
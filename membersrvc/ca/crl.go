@@ -0,0 +1,326 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/events/producer"
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+// crlStream is the server side of a WatchCRL call on either CA.
+type crlStream interface {
+	Send(*pb.CRL) error
+	Context() context.Context
+}
+
+// getCRLValidity returns how long a CRL is valid for. A CRL is reissued
+// when it expires even if nothing has been revoked in the meantime.
+func getCRLValidity() time.Duration {
+	validity := viper.GetDuration("pki.crl.validity")
+	if validity <= 0 {
+		validity = 24 * time.Hour
+	}
+	return validity
+}
+
+// revokeCertificates records the certificates with the given serial numbers,
+// issued to id, as revoked and publishes a new CRL.
+func (ca *CA) revokeCertificates(id string, serials ...*big.Int) error {
+	return ca.revokeCertificatesAt(id, time.Now(), serials...)
+}
+
+// revokeCertificatesAt records the certificates with the given serial
+// numbers, issued to id, as revoked from time at on, which may be in the
+// future, and publishes a new CRL. The certificates are listed in the CRLs
+// issued from time at on.
+func (ca *CA) revokeCertificatesAt(id string, at time.Time, serials ...*big.Int) error {
+	Trace.Printf("Revoking %d certificates of %s at %v.", len(serials), id, at)
+
+	mutex.Lock()
+	for _, serial := range serials {
+		var row int
+		err := ca.db.QueryRow("SELECT row FROM Revocations WHERE serial=?", serial.String()).Scan(&row)
+		if err == nil {
+			// a pending revocation may be brought forward
+			_, err = ca.db.Exec("UPDATE Revocations SET timestamp=? WHERE row=? AND timestamp>?", at.Unix(), row, at.Unix())
+		} else {
+			_, err = ca.db.Exec("INSERT INTO Revocations (serial, id, timestamp) VALUES (?, ?, ?)", serial.String(), id, at.Unix())
+		}
+		if err != nil {
+			mutex.Unlock()
+			Error.Println(err)
+			return err
+		}
+	}
+	mutex.Unlock()
+
+	if _, err := ca.publishCRL(); err != nil && err != errNoCRL {
+		return err
+	}
+	return nil
+}
+
+// isRevoked tells whether the certificate with the given serial number has
+// been revoked.
+func (ca *CA) isRevoked(serial *big.Int) bool {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var row int
+	return ca.db.QueryRow("SELECT row FROM Revocations WHERE serial=? AND timestamp<=?", serial.String(), time.Now().Unix()).Scan(&row) == nil
+}
+
+// createCRL issues a CRL listing every certificate revoked by the CA. The
+// CRL expires no later than the next pending revocation.
+func (ca *CA) createCRL() ([]byte, time.Time, error) {
+	now := time.Now().UTC()
+	nextUpdate := now.Add(getCRLValidity())
+
+	mutex.RLock()
+	var pending sql.NullInt64
+	if err := ca.db.QueryRow("SELECT MIN(timestamp) FROM Revocations WHERE timestamp>?", now.Unix()).Scan(&pending); err != nil {
+		mutex.RUnlock()
+		return nil, time.Time{}, err
+	}
+	if pending.Valid && time.Unix(pending.Int64, 0).Before(nextUpdate) {
+		nextUpdate = time.Unix(pending.Int64, 0).UTC()
+	}
+
+	rows, err := ca.db.Query("SELECT serial, timestamp FROM Revocations WHERE timestamp<=? ORDER BY row", now.Unix())
+	if err != nil {
+		mutex.RUnlock()
+		return nil, time.Time{}, err
+	}
+
+	var revoked []pkix.RevokedCertificate
+	for rows.Next() {
+		var serial string
+		var timestamp int64
+		if err = rows.Scan(&serial, &timestamp); err != nil {
+			break
+		}
+		number, ok := new(big.Int).SetString(serial, 10)
+		if !ok {
+			err = errors.New("Invalid serial number " + serial + " in the revocations table")
+			break
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: number, RevocationTime: time.Unix(timestamp, 0).UTC()})
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close()
+	mutex.RUnlock()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	raw, err := ca.backend.createCRL(revoked, now, nextUpdate)
+	return raw, nextUpdate, err
+}
+
+// publishCRL issues a new CRL and pushes it to every watcher, and to the
+// peers listening for revocation events.
+func (ca *CA) publishCRL() ([]byte, error) {
+	ca.crlMutex.Lock()
+	defer ca.crlMutex.Unlock()
+
+	revocations, err := ca.readRevocationsState()
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+	raw, nextUpdate, err := ca.createCRL()
+	if err == errNoCRL {
+		return nil, err
+	}
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	ca.crl, ca.crlNextUpdate, ca.crlRevocations = raw, nextUpdate, revocations
+	for watcher := range ca.crlWatchers {
+		// keep only the latest CRL for watchers which lag behind
+		select {
+		case <-watcher:
+		default:
+		}
+		watcher <- raw
+	}
+	if err := producer.Send(producer.CreateRevocationEvent(strings.ToUpper(ca.name), raw)); err != nil {
+		Warning.Printf("Failed sending the revocation event [%s].", err)
+	}
+
+	Trace.Printf("Published a CRL valid until %v to %d watchers.", nextUpdate, len(ca.crlWatchers))
+	return raw, nil
+}
+
+// readCRL returns the current CRL, issuing a new one if it has expired, or
+// if another instance of membersrvc sharing the database of the CA revoked a
+// certificate since.
+func (ca *CA) readCRL() ([]byte, error) {
+	ca.crlMutex.Lock()
+	raw, nextUpdate, revocations := ca.crl, ca.crlNextUpdate, ca.crlRevocations
+	ca.crlMutex.Unlock()
+
+	if raw != nil && time.Now().Before(nextUpdate) {
+		if databaseDialect(ca.db) == nil {
+			return raw, nil
+		}
+		if current, err := ca.readRevocationsState(); err == nil && current == revocations {
+			return raw, nil
+		}
+	}
+	return ca.publishCRL()
+}
+
+// readRevocationsState returns a digest of the revocations table, which
+// changes with every revocation.
+func (ca *CA) readRevocationsState() (string, error) {
+	var count, sum int64
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if err := ca.db.QueryRow("SELECT COUNT(*), COALESCE(SUM(timestamp), 0) FROM Revocations").Scan(&count, &sum); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%d", count, sum), nil
+}
+
+// watchCRL registers a watcher for the CRLs published by the CA. The
+// returned function unregisters it.
+func (ca *CA) watchCRL() (<-chan []byte, func()) {
+	watcher := make(chan []byte, 1)
+
+	ca.crlMutex.Lock()
+	if ca.crlWatchers == nil {
+		ca.crlWatchers = make(map[chan []byte]struct{})
+	}
+	ca.crlWatchers[watcher] = struct{}{}
+	ca.crlMutex.Unlock()
+
+	return watcher, func() {
+		ca.crlMutex.Lock()
+		delete(ca.crlWatchers, watcher)
+		ca.crlMutex.Unlock()
+	}
+}
+
+// serveCRL sends the current CRL on stream, followed by every CRL the CA
+// publishes until the client goes away.
+func (ca *CA) serveCRL(stream crlStream) error {
+	watcher, cancel := ca.watchCRL()
+	defer cancel()
+
+	raw, err := ca.readCRL()
+	if err != nil {
+		return err
+	}
+
+	for {
+		if err := stream.Send(&pb.CRL{Crl: raw}); err != nil {
+			return err
+		}
+
+		select {
+		case raw = <-watcher:
+		case <-time.After(ca.nextCRLUpdate().Sub(time.Now())):
+			// reissue the CRL before the watchers consider it expired
+			if raw, err = ca.readCRL(); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (ca *CA) nextCRLUpdate() time.Time {
+	ca.crlMutex.Lock()
+	defer ca.crlMutex.Unlock()
+	return ca.crlNextUpdate
+}
+
+// checkSignature verifies that sig is the signature of the member id over
+// in, whose own signature field must have been cleared by the caller. The
+// enrollment certificate of id must not have been revoked.
+func (ca *CA) checkSignature(id string, in proto.Message, sig *pb.Signature) error {
+	if id == "" || sig == nil {
+		return errors.New("The request is not signed.")
+	}
+
+	raw, err := ca.readCertificateByKeyUsage(id, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		return err
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return err
+	}
+	if ca.isRevoked(cert.SerialNumber) {
+		return errors.New("The enrollment certificate of " + id + " has been revoked.")
+	}
+
+	raw, _ = proto.Marshal(in)
+	if !verifySignature(cert.PublicKey, raw, sig) {
+		return errors.New("Signature verification failed.")
+	}
+
+	return nil
+}
+
+// canRevoke checks that the member admin may revoke the certificates of
+// the member id, that is, that admin is id or administers id.
+func (ca *CA) canRevoke(admin string, id string) error {
+	if admin == id {
+		return nil
+	}
+	return ca.canAdminister(admin, id)
+}
+
+// canAdminister checks that the member admin administers the member id,
+// that is, that admin could have registered id.
+func (ca *CA) canAdminister(admin string, id string) error {
+	return ca.canRegister(admin, role2String(ca.readRole(id)), "")
+}
+
+// checkRegistrar checks that the member id is a registrar.
+func (ca *CA) checkRegistrar(id string) error {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var metadata string
+	if err := ca.db.QueryRow("SELECT metadata FROM Users WHERE id=?", id).Scan(&metadata); err != nil {
+		return err
+	}
+	if metadata == "" {
+		return errors.New("member " + id + " is not a registrar")
+	}
+	return nil
+}
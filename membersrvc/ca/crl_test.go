@@ -0,0 +1,165 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"golang.org/x/net/context"
+)
+
+func signRevocationRequest(priv *ecdsa.PrivateKey, req proto.Message) (*pb.Signature, error) {
+	hash := primitives.NewHash()
+	raw, _ := proto.Marshal(req)
+	hash.Write(raw)
+
+	r, s, err := ecdsa.Sign(rand.Reader, priv, hash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	return &pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S}, nil
+}
+
+func checkCRL(t *testing.T, ca *CA, raw []byte, serials ...*big.Int) {
+	crl, err := x509.ParseCRL(raw)
+	if err != nil {
+		t.Fatalf("Failed parsing the CRL: [%s]", err)
+	}
+	if err = ca.cert.CheckCRLSignature(crl); err != nil {
+		t.Fatalf("The CRL is not signed by the CA: [%s]", err)
+	}
+
+	revoked := make(map[string]bool)
+	for _, cert := range crl.TBSCertList.RevokedCertificates {
+		revoked[cert.SerialNumber.String()] = true
+	}
+	for _, serial := range serials {
+		if !revoked[serial.String()] {
+			t.Fatalf("The CRL does not list the certificate [%s]", serial)
+		}
+	}
+}
+
+func TestRevokeCertificates(t *testing.T) {
+	user := User{enrollID: "testRevokedUser", enrollPwd: []byte("9T4zl2kXr1Qa")}
+	if _, err := eca.registerUser(user.enrollID, "institution_a", pb.Role_CLIENT, "", "", string(user.enrollPwd)); err != nil {
+		t.Fatalf("Failed registering the user: [%s]", err)
+	}
+	if err := enrollUser(&user); err != nil {
+		t.Fatalf("Failed enrolling the user: [%s]", err)
+	}
+
+	raw, err := eca.readCertificateByKeyUsage(user.enrollID, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Issue a set of TCerts to the user
+	tcap := &TCAP{tca}
+	setReq, err := buildCertificateSetRequest(user.enrollID, user.enrollPrivKey, 2, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := tcap.CreateCertificateSet(context.Background(), setReq)
+	if err != nil {
+		t.Fatalf("Failed creating the TCerts: [%s]", err)
+	}
+	var tcerts []*x509.Certificate
+	for _, tcert := range set.Certs.Certs {
+		cert, err := x509.ParseCertificate(tcert.Cert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tcerts = append(tcerts, cert)
+	}
+
+	watcher, cancel := tca.watchCRL()
+	defer cancel()
+
+	// The user revokes its first TCert, and the new CRL is pushed
+	tcertReq := &pb.TCertRevokeReq{Id: &pb.Identity{Id: user.enrollID}, Cert: &pb.Cert{Cert: set.Certs.Certs[0].Cert}}
+	if tcertReq.Sig, err = signRevocationRequest(user.enrollPrivKey, tcertReq); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tcap.RevokeCertificate(context.Background(), tcertReq); err != nil {
+		t.Fatalf("Failed revoking the TCert: [%s]", err)
+	}
+	if !tca.isRevoked(tcerts[0].SerialNumber) || tca.isRevoked(tcerts[1].SerialNumber) {
+		t.Fatal("Only the first TCert should have been revoked")
+	}
+	select {
+	case raw := <-watcher:
+		checkCRL(t, tca.CA, raw, tcerts[0].SerialNumber)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The CRL of the TCA was not pushed")
+	}
+
+	// Another member cannot revoke the certificates of the user
+	ecertReq := &pb.ECertRevokeReq{Id: &pb.Identity{Id: user.enrollID}, Cert: &pb.Cert{Cert: raw}}
+	if ecertReq.Sig, err = signRevocationRequest(user.enrollPrivKey, ecertReq); err != nil {
+		t.Fatal(err)
+	}
+	ecertReq.Id.Id = "admin"
+	if _, err = (&ECAP{eca}).RevokeCertificatePair(context.Background(), ecertReq); err == nil {
+		t.Fatal("The ECert should not have been revoked on a forged request")
+	}
+
+	// The user revokes its ECert, which revokes its TCerts as well
+	ecertReq = &pb.ECertRevokeReq{Id: &pb.Identity{Id: user.enrollID}, Cert: &pb.Cert{Cert: raw}}
+	if ecertReq.Sig, err = signRevocationRequest(user.enrollPrivKey, ecertReq); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = (&ECAP{eca}).RevokeCertificatePair(context.Background(), ecertReq); err != nil {
+		t.Fatalf("Failed revoking the ECert: [%s]", err)
+	}
+	if !eca.isRevoked(ecert.SerialNumber) || !tca.isRevoked(tcerts[1].SerialNumber) {
+		t.Fatal("The ECert and TCerts of the user should have been revoked")
+	}
+
+	crl, err := (&ECAP{eca}).ReadCRL(context.Background(), &pb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCRL(t, eca.CA, crl.Crl, ecert.SerialNumber)
+	crl, err = tcap.ReadCRL(context.Background(), &pb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCRL(t, tca.CA, crl.Crl, tcerts[0].SerialNumber, tcerts[1].SerialNumber)
+
+	// A revoked member gets no more TCerts
+	if setReq, err = buildCertificateSetRequest(user.enrollID, user.enrollPrivKey, 1, -1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tcap.CreateCertificateSet(context.Background(), setReq); err == nil {
+		t.Fatal("TCerts should not be issued with a revoked ECert")
+	}
+}
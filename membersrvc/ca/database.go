@@ -0,0 +1,247 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// The database of each CA is an SQLite file in the CA directory by default.
+// With pki.database.driver set to postgres or mysql, the CAs keep their state
+// on a database server instead, so that several instances of membersrvc can
+// share it. The statements of the CAs are written for SQLite, and rewritten
+// for the dialect of the server by a driver wrapping the driver of the
+// server, registered as membersrvc-postgres and membersrvc-mysql.
+
+// databasePlaceholder is replaced by the name of the CA in
+// pki.database.datasource, so that each CA has its own database.
+const databasePlaceholder = "{ca}"
+
+// dialect describes how the statements of the CAs are rewritten for a
+// database server.
+type dialect struct {
+	name string
+
+	// numbered placeholders $1, $2, ... rather than ?
+	numbered bool
+
+	// rewrites the column types of CREATE TABLE statements
+	types *strings.Replacer
+
+	// identifiers reserved by the server, quoted with quote
+	reserved map[string]bool
+	quote    string
+
+	// the statements making the audit log append-only
+	auditTriggers []string
+}
+
+var dialects = map[string]*dialect{
+	"postgres": {
+		name:     "postgres",
+		numbered: true,
+		types: strings.NewReplacer(
+			"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+			"INTEGER PRIMARY KEY", "BIGSERIAL PRIMARY KEY",
+			"INTEGER", "BIGINT",
+			"BLOB", "BYTEA",
+			"DATETIME", "TIMESTAMP",
+			// Root affiliation groups have parent 0, which SQLite accepts
+			// as it does not enforce foreign keys
+			", FOREIGN KEY(parent) REFERENCES AffiliationGroups(row)", "",
+		),
+		// keywords that are only parsed as column names where unambiguous
+		reserved: map[string]bool{"row": true, "timestamp": true},
+		quote:    `"`,
+		auditTriggers: []string{
+			"CREATE OR REPLACE FUNCTION AuditLogAppendOnly() RETURNS trigger AS $$ BEGIN RAISE EXCEPTION 'The audit log is append-only.'; END $$ LANGUAGE plpgsql",
+			"DROP TRIGGER IF EXISTS AuditLogAppendOnly ON AuditLog",
+			"CREATE TRIGGER AuditLogAppendOnly BEFORE UPDATE OR DELETE ON AuditLog FOR EACH ROW EXECUTE PROCEDURE AuditLogAppendOnly()",
+		},
+	},
+	"mysql": {
+		name: "mysql",
+		types: strings.NewReplacer(
+			"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGINT PRIMARY KEY AUTO_INCREMENT",
+			"INTEGER PRIMARY KEY", "BIGINT PRIMARY KEY AUTO_INCREMENT",
+			"INTEGER", "BIGINT",
+			", FOREIGN KEY(parent) REFERENCES AffiliationGroups(row)", "",
+		),
+		reserved: map[string]bool{"key": true, "row": true, "usage": true},
+		quote:    "`",
+		auditTriggers: []string{
+			"DROP TRIGGER IF EXISTS AuditLogNoUpdate",
+			"CREATE TRIGGER AuditLogNoUpdate BEFORE UPDATE ON AuditLog FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'The audit log is append-only.'",
+			"DROP TRIGGER IF EXISTS AuditLogNoDelete",
+			"CREATE TRIGGER AuditLogNoDelete BEFORE DELETE ON AuditLog FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'The audit log is append-only.'",
+		},
+	},
+}
+
+// The drivers of the servers are imported by the files built with the
+// postgres and mysql tags, which are initialized first.
+func init() {
+	for name, d := range dialects {
+		if !driverRegistered(name) {
+			continue
+		}
+		// sql.Open only looks the driver up
+		db, err := sql.Open(name, "")
+		if err != nil {
+			continue
+		}
+		sql.Register("membersrvc-"+name, &dialectDriver{db.Driver(), d})
+		db.Close()
+	}
+}
+
+// openDatabase opens the database of the CA name: the SQLite file name.db in
+// the directory of the CA, or the database configured in pki.database.
+func openDatabase(path, name string) (*sql.DB, error) {
+	driverName := viper.GetString("pki.database.driver")
+	if driverName == "" || driverName == "sqlite3" {
+		return sql.Open("sqlite3", filepath.Join(path, name+".db"))
+	}
+
+	if _, ok := dialects[driverName]; !ok {
+		return nil, fmt.Errorf("Unsupported database driver %s, expected sqlite3, postgres or mysql", driverName)
+	}
+	if !driverRegistered("membersrvc-" + driverName) {
+		return nil, fmt.Errorf("The %s database driver is not supported by this build, build with -tags %s", driverName, driverName)
+	}
+	source := viper.GetString("pki.database.datasource")
+	if !strings.Contains(source, databasePlaceholder) {
+		return nil, errors.New("pki.database.datasource must name the database of each CA with " + databasePlaceholder)
+	}
+	return sql.Open("membersrvc-"+driverName, strings.Replace(source, databasePlaceholder, name, -1))
+}
+
+func driverRegistered(name string) bool {
+	for _, registered := range sql.Drivers() {
+		if registered == name {
+			return true
+		}
+	}
+	return false
+}
+
+// databaseDialect returns the dialect of db, nil for SQLite.
+func databaseDialect(db *sql.DB) *dialect {
+	if d, ok := db.Driver().(*dialectDriver); ok {
+		return d.dialect
+	}
+	return nil
+}
+
+// rewrite rewrites query, written for SQLite, for the dialect.
+func (d *dialect) rewrite(query string) string {
+	if strings.HasPrefix(query, "CREATE TABLE") {
+		query = d.types.Replace(query)
+	}
+
+	var b bytes.Buffer
+	n := 0
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'':
+			// string literals are kept as is
+			j := strings.IndexByte(query[i+1:], '\'')
+			if j < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+j+2])
+			i += j + 2
+		case c == '?':
+			n++
+			if d.numbered {
+				fmt.Fprintf(&b, "$%d", n)
+			} else {
+				b.WriteByte(c)
+			}
+			i++
+		case isIdentifier(c):
+			j := i
+			for j < len(query) && (isIdentifier(query[j]) || query[j] >= '0' && query[j] <= '9') {
+				j++
+			}
+			if word := query[i:j]; d.reserved[word] {
+				b.WriteString(d.quote + word + d.quote)
+			} else {
+				b.WriteString(word)
+			}
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isIdentifier(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// dialectDriver wraps the driver of a database server, rewriting the
+// statements of the CAs for its dialect.
+type dialectDriver struct {
+	driver.Driver
+	dialect *dialect
+}
+
+func (d *dialectDriver) Open(source string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	return &dialectConn{conn, d.dialect}, nil
+}
+
+type dialectConn struct {
+	driver.Conn
+	dialect *dialect
+}
+
+func (c *dialectConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(c.dialect.rewrite(query))
+}
+
+// Exec executes the statements without preparing them where the driver can,
+// as not every statement can be prepared.
+func (c *dialectConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.Execer); ok {
+		return execer.Exec(c.dialect.rewrite(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *dialectConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.Queryer); ok {
+		return queryer.Query(c.dialect.rewrite(query), args)
+	}
+	return nil, driver.ErrSkip
+}
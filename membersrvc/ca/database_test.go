@@ -0,0 +1,69 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestDialectRewrite(t *testing.T) {
+	tests := []struct {
+		dialect, query, expected string
+	}{
+		{"postgres",
+			"CREATE TABLE IF NOT EXISTS AuditLog (row INTEGER PRIMARY KEY AUTOINCREMENT, timestamp INTEGER, detail TEXT)",
+			`CREATE TABLE IF NOT EXISTS AuditLog ("row" BIGSERIAL PRIMARY KEY, "timestamp" BIGINT, detail TEXT)`},
+		{"postgres",
+			"CREATE TABLE IF NOT EXISTS AffiliationGroups (row INTEGER PRIMARY KEY, name VARCHAR(64), parent INTEGER, FOREIGN KEY(parent) REFERENCES AffiliationGroups(row))",
+			`CREATE TABLE IF NOT EXISTS AffiliationGroups ("row" BIGSERIAL PRIMARY KEY, name VARCHAR(64), parent BIGINT)`},
+		{"postgres",
+			"UPDATE Revocations SET timestamp=? WHERE row=? AND timestamp>?",
+			`UPDATE Revocations SET "timestamp"=$1 WHERE "row"=$2 AND "timestamp">$3`},
+		{"postgres",
+			"SELECT cert FROM Certificates WHERE id='row?' AND usage=?",
+			"SELECT cert FROM Certificates WHERE id='row?' AND usage=$1"},
+		{"mysql",
+			"CREATE TABLE IF NOT EXISTS Users (row INTEGER PRIMARY KEY, id VARCHAR(64), token BLOB, key BLOB)",
+			"CREATE TABLE IF NOT EXISTS Users (`row` BIGINT PRIMARY KEY AUTO_INCREMENT, id VARCHAR(64), token BLOB, `key` BLOB)"},
+		{"mysql",
+			"SELECT role, token, state, key, enrollmentId FROM Users WHERE id=? ORDER BY row2, row",
+			"SELECT role, token, state, `key`, enrollmentId FROM Users WHERE id=? ORDER BY row2, `row`"},
+	}
+	for _, test := range tests {
+		if rewritten := dialects[test.dialect].rewrite(test.query); rewritten != test.expected {
+			t.Errorf("Expected %s to be rewritten for %s as %s, got %s", test.query, test.dialect, test.expected, rewritten)
+		}
+	}
+}
+
+func TestOpenDatabaseDriver(t *testing.T) {
+	defer viper.Set("pki.database.driver", "")
+
+	viper.Set("pki.database.driver", "oracle")
+	if _, err := openDatabase(".", "eca"); err == nil {
+		t.Fatal("Expected an unsupported driver to be refused")
+	}
+	if driverRegistered("membersrvc-postgres") {
+		t.Skip("Built with the postgres driver")
+	}
+	viper.Set("pki.database.driver", "postgres")
+	if _, err := openDatabase(".", "eca"); err == nil {
+		t.Fatal("Expected the postgres driver to require the postgres build tag")
+	}
+}
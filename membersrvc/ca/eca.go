@@ -25,6 +25,7 @@ import (
 	"encoding/base64"
 	"encoding/pem"
 	"io/ioutil"
+	"math/big"
 	"strconv"
 	"strings"
 
@@ -47,16 +48,28 @@ type ECA struct {
 	obcKey          []byte
 	obcPriv, obcPub []byte
 	gRPCServer      *grpc.Server
+	tca             *TCA // Revokes the TCerts of the members whose ECerts are revoked
+	directory       *directory
+	guard           *enrollmentGuard
 }
 
 func initializeECATables(db *sql.DB) error {
-	return initializeCommonTables(db)
+	if err := initializeCommonTables(db); err != nil {
+		return err
+	}
+	if err := initializeLockoutTables(db); err != nil {
+		return err
+	}
+	if err := initializeTokenTables(db); err != nil {
+		return err
+	}
+	return initializeAuditTables(db)
 }
 
 // NewECA sets up a new ECA.
 //
 func NewECA() *ECA {
-	eca := &ECA{CA: NewCA("eca", initializeECATables)}
+	eca := &ECA{CA: NewCA("eca", initializeECATables), guard: newEnrollmentGuard()}
 
 	{
 		// read or create global symmetric encryption key
@@ -122,6 +135,12 @@ func NewECA() *ECA {
 
 	eca.populateAffiliationGroupsTable()
 	eca.populateUsersTable()
+
+	directory, err := newDirectory()
+	if err != nil {
+		Panic.Panicln(err)
+	}
+	eca.directory = directory
 	return eca
 }
 
@@ -205,6 +224,102 @@ func (eca *ECA) Stop() {
 	}
 }
 
+// registerFromDirectory registers a member of the LDAP directory which is not
+// registered with the ECA yet, on its first enrollment, once its password
+// has been checked against the directory. The role and affiliation of the
+// member are those of its groups. The password becomes its enrollment token.
+//
+func (eca *ECA) registerFromDirectory(id string, password []byte) error {
+	if eca.directory == nil {
+		return nil
+	}
+
+	var row int
+	mutex.RLock()
+	err := eca.db.QueryRow("SELECT row FROM Users WHERE id=?", id).Scan(&row)
+	mutex.RUnlock()
+	if err == nil {
+		return nil
+	}
+
+	role, affiliation, err := eca.directory.authenticate(id, string(password))
+	if err != nil {
+		return err
+	}
+	_, err = eca.registerUser(id, affiliation, role, "", "", string(password))
+	eca.audit(auditRegistration, auditDirectoryAgent, id, describeRegistration(role, affiliation), err)
+	if err != nil {
+		return err
+	}
+	Info.Printf("Registered %s from the LDAP directory as %s", id, role2String(int(role)))
+	return nil
+}
+
+// readCertificateOwner returns the member an enrollment certificate was
+// issued to, and the timestamp of the certificate pair it belongs to.
+//
+func (eca *ECA) readCertificateOwner(raw []byte) (string, int64, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	hash := primitives.NewHash()
+	hash.Write(raw)
+
+	var id string
+	var timestamp int64
+	err := eca.db.QueryRow("SELECT id, timestamp FROM Certificates WHERE hash=?", hash.Sum(nil)).Scan(&id, &timestamp)
+	return id, timestamp, err
+}
+
+// revokeCertificatePair revokes the enrollment certificate pair issued to
+// id at timestamp, and every TCert issued to id.
+//
+func (eca *ECA) revokeCertificatePair(id string, timestamp int64) error {
+	serials, err := eca.readCertificatePairSerials(id, timestamp)
+	if err != nil {
+		return err
+	}
+
+	if err = eca.revokeCertificates(id, serials...); err != nil {
+		return err
+	}
+	if eca.tca != nil {
+		return eca.tca.revokeCertificateSets(id)
+	}
+	return nil
+}
+
+// readCertificatePairSerials returns the serial numbers of the enrollment
+// certificate pair issued to id at timestamp.
+//
+func (eca *ECA) readCertificatePairSerials(id string, timestamp int64) ([]*big.Int, error) {
+	mutex.RLock()
+	rows, err := eca.db.Query("SELECT cert FROM Certificates WHERE id=? AND timestamp=?", id, timestamp)
+	if err != nil {
+		mutex.RUnlock()
+		return nil, err
+	}
+
+	var serials []*big.Int
+	for rows.Next() {
+		var raw []byte
+		if err = rows.Scan(&raw); err != nil {
+			break
+		}
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(raw); err != nil {
+			break
+		}
+		serials = append(serials, cert.SerialNumber)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close()
+	mutex.RUnlock()
+	return serials, err
+}
+
 func (eca *ECA) startECAP(srv *grpc.Server) {
 	pb.RegisterECAPServer(srv, &ECAP{eca})
 	Info.Println("ECA PUBLIC gRPC API server started")
@@ -23,13 +23,16 @@ import (
 	"errors"
 	"google/protobuf"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/primitives/ecies"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 )
 
@@ -541,13 +544,196 @@ func TestCreateCertificatePairBadToken(t *testing.T) {
 	}
 }
 
+func TestCreateCertificatePairLockout(t *testing.T) {
+	guard := eca.guard
+	defer func() { eca.guard = guard }()
+	eca.guard = &enrollmentGuard{attempts: 3, duration: time.Minute}
+
+	ecap := &ECAP{eca}
+	enroll := func(tok string) error {
+		req := &pb.ECertCreateReq{
+			Ts:   &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0},
+			Id:   &pb.Identity{Id: "test_user9"},
+			Tok:  &pb.Token{Tok: []byte(tok)},
+			Sign: &pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: []byte{0}},
+			Enc:  &pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: []byte{0}},
+			Sig:  nil}
+		_, err := ecap.CreateCertificatePair(context.Background(), req)
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := enroll("badPassword"); err == nil || err.Error() != "Identity or token does not match." {
+			t.Fatalf("Expected a bad password to be refused, got %v", err)
+		}
+	}
+	if err := enroll("H80SiB5ODKKQ"); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("Expected test_user9 to be locked out, got %v", err)
+	}
+
+	// The failures are forgotten once the lockout is over
+	eca.guard.duration = 0
+	if err := enroll("H80SiB5ODKKQ"); err == nil || err.Error() == "Identity or token does not match." || strings.Contains(err.Error(), "locked") {
+		t.Fatalf("Expected the enrollment of test_user9 to proceed, got %v", err)
+	}
+}
+
+func TestEnrollmentToken(t *testing.T) {
+	ecaa := &ECAA{eca}
+	createToken := func(prefix string, maxUses uint32) *pb.EnrollmentToken {
+		req := &pb.EnrollmentTokenCreateReq{
+			Ts:          &google_protobuf.Timestamp{Seconds: time.Now().Unix()},
+			Registrar:   &pb.Identity{Id: testAdmin.enrollID},
+			Role:        pb.Role_CLIENT,
+			Affiliation: "institution_a",
+			Prefix:      prefix,
+			Ttl:         60,
+			MaxUses:     maxUses,
+		}
+		sig, err := signRevocationRequest(testAdmin.enrollPrivKey, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Sig = sig
+		token, err := ecaa.CreateEnrollmentToken(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Failed minting an enrollment token: %s", err)
+		}
+		return token
+	}
+
+	token := createToken("device", 2)
+	for _, id := range []string{"device1", "device2"} {
+		if err := enrollUser(&User{enrollID: id, enrollPwd: token.Tok.Tok}); err != nil {
+			t.Fatalf("Failed enrolling %s with the enrollment token: %s", id, err)
+		}
+	}
+	if err := enrollUser(&User{enrollID: "device3", enrollPwd: token.Tok.Tok}); err == nil {
+		t.Fatal("Expected the enrollment token to be used up")
+	}
+	if err := enrollUser(&User{enrollID: "device1", enrollPwd: token.Tok.Tok}); err == nil {
+		t.Fatal("Expected the enrollment token not to enroll a member twice")
+	}
+
+	token = createToken("device", 0)
+	if err := enrollUser(&User{enrollID: "sensor1", enrollPwd: token.Tok.Tok}); err == nil {
+		t.Fatal("Expected the enrollment token to refuse an enrollment ID without its prefix")
+	}
+
+	req := &pb.EnrollmentTokenRevokeReq{
+		Ts:        &google_protobuf.Timestamp{Seconds: time.Now().Unix()},
+		Registrar: &pb.Identity{Id: testAdmin.enrollID},
+		Handle:    token.Handle,
+	}
+	sig, err := signRevocationRequest(testAdmin.enrollPrivKey, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Sig = sig
+	if _, err = ecaa.RevokeEnrollmentToken(context.Background(), req); err != nil {
+		t.Fatalf("Failed revoking the enrollment token: %s", err)
+	}
+	if err := enrollUser(&User{enrollID: "device4", enrollPwd: token.Tok.Tok}); err == nil {
+		t.Fatal("Expected the revoked enrollment token to be refused")
+	}
+}
+
+func TestCreateCertificatePairThrottle(t *testing.T) {
+	guard := eca.guard
+	defer func() { eca.guard = guard }()
+	viper.Set("ecatest.throttle.rate", 0.001)
+	viper.Set("ecatest.throttle.burst", 2)
+	eca.guard = &enrollmentGuard{users: comm.NewRateLimiter("ecatest.throttle")}
+
+	ecap := &ECAP{eca}
+	req := &pb.ECertCreateReq{
+		Ts:   &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0},
+		Id:   &pb.Identity{Id: "badIdentity"},
+		Tok:  &pb.Token{Tok: []byte("badPassword")},
+		Sign: &pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: []byte{0}},
+		Enc:  &pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: []byte{0}},
+		Sig:  nil}
+	for i := 0; i < 2; i++ {
+		if _, err := ecap.CreateCertificatePair(context.Background(), req); err == nil {
+			t.Fatal("Expected a bad identity to be refused")
+		} else if _, ok := err.(*comm.RateLimitedError); ok {
+			t.Fatalf("Expected attempt %d to be admitted", i)
+		}
+	}
+	if _, err := ecap.CreateCertificatePair(context.Background(), req); err == nil {
+		t.Fatal("Expected a bad identity to be refused")
+	} else if _, ok := err.(*comm.RateLimitedError); !ok {
+		t.Fatalf("Expected the third attempt to be throttled, got %s", err)
+	}
+}
+
+func TestCreateCertificatePairEd25519(t *testing.T) {
+	user := User{enrollID: "testEd25519User", role: 1, affiliation: "institution_a"}
+	if err := registerUser(testAdmin, &user); err != nil {
+		t.Fatal(err)
+	}
+
+	ecap := &ECAP{eca}
+
+	signPriv, err := primitives.NewEd25519Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signPub, _ := x509.MarshalPKIXPublicKey(signPriv.Public())
+	encPriv, _ := primitives.NewECDSAKey()
+	encPub, _ := x509.MarshalPKIXPublicKey(&encPriv.PublicKey)
+
+	req := &pb.ECertCreateReq{
+		Ts:   &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0},
+		Id:   &pb.Identity{Id: user.enrollID},
+		Tok:  &pb.Token{Tok: user.enrollPwd},
+		Sign: &pb.PublicKey{Type: pb.CryptoType_ED25519, Key: signPub},
+		Enc:  &pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: encPub},
+		Sig:  nil}
+
+	resp, err := ecap.CreateCertificatePair(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spi := ecies.NewSPI()
+	eciesKey, _ := spi.NewPrivateKey(nil, encPriv)
+	cipher, _ := spi.NewAsymmetricCipherFromPublicKey(eciesKey)
+	if req.Tok.Tok, err = cipher.Process(resp.Tok.Tok); err != nil {
+		t.Fatal(err)
+	}
+
+	// An ECDSA signature does not verify with an Ed25519 key
+	raw, _ := proto.Marshal(req)
+	r, s, _ := primitives.Ed25519SignDirect(signPriv, raw)
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	req.Sig = &pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S}
+	if _, err := ecap.CreateCertificatePair(context.Background(), req); err == nil {
+		t.Fatal("A signature of the wrong type should be rejected")
+	}
+
+	req.Sig = &pb.Signature{Type: pb.CryptoType_ED25519, R: R, S: S}
+	resp, err = ecap.CreateCertificatePair(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to enroll with an Ed25519 key: [%s]", err)
+	}
+
+	cert, err := primitives.DERToX509Certificate(resp.Certs.Sign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := primitives.CheckCertPKAgainstSK(cert, signPriv); err != nil {
+		t.Fatalf("The enrollment certificate does not certify the Ed25519 key: [%s]", err)
+	}
+}
+
 func TestRevokeCertificatePair(t *testing.T) {
 
 	ecap := &ECAP{eca}
 
 	_, err := ecap.RevokeCertificatePair(context.Background(), &pb.ECertRevokeReq{})
-	if err.Error() != "ECAP:RevokeCertificate method not (yet) implemented" {
-		t.Fatalf("Expected error was not returned: [%s]", err.Error())
+	if err == nil || err.Error() != "Invalid revocation request." {
+		t.Fatalf("Expected error was not returned: [%v]", err)
 	}
 }
 
@@ -556,8 +742,8 @@ func TestRevokeCertificate(t *testing.T) {
 	ecaa := &ECAA{eca}
 
 	_, err := ecaa.RevokeCertificate(context.Background(), &pb.ECertRevokeReq{})
-	if err.Error() != "ECAA:RevokeCertificate method not (yet) implemented" {
-		t.Fatalf("Expected error was not returned: [%s]", err.Error())
+	if err == nil || err.Error() != "Invalid revocation request." {
+		t.Fatalf("Expected error was not returned: [%v]", err)
 	}
 }
 
@@ -565,7 +751,124 @@ func TestPublishCRL(t *testing.T) {
 	ecaa := &ECAA{eca}
 
 	_, err := ecaa.PublishCRL(context.Background(), &pb.ECertCRLReq{})
-	if err.Error() != "ECAA:PublishCRL method not (yet) implemented" {
-		t.Fatalf("Expected error was not returned: [%s]", err.Error())
+	if err == nil || err.Error() != "Invalid CRL request." {
+		t.Fatalf("Expected error was not returned: [%v]", err)
+	}
+
+	req := &pb.ECertCRLReq{Id: &pb.Identity{Id: testAdmin.enrollID}}
+	hash := primitives.NewHash()
+	raw, _ := proto.Marshal(req)
+	hash.Write(raw)
+	r, s, err := ecdsa.Sign(rand.Reader, testAdmin.enrollPrivKey, hash.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	req.Sig = &pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S}
+
+	if _, err = ecaa.PublishCRL(context.Background(), req); err != nil {
+		t.Fatalf("Failed publishing the CRL: [%s]", err)
+	}
+}
+
+func reEnrollUser(user *User, overlap time.Duration) (*pb.ECertCreateResp, error) {
+	ecap := &ECAP{eca}
+
+	signPriv, err := primitives.NewECDSAKey()
+	if err != nil {
+		return nil, err
+	}
+	signPub, _ := x509.MarshalPKIXPublicKey(&signPriv.PublicKey)
+	encPriv, _ := primitives.NewECDSAKey()
+	encPub, _ := x509.MarshalPKIXPublicKey(&encPriv.PublicKey)
+
+	req := &pb.ECertReEnrollReq{
+		Ts:   &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0},
+		Id:   &pb.Identity{Id: user.enrollID},
+		Sign: &pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: signPub},
+		Enc:  &pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: encPub}}
+
+	// the new key signs first, the current key signs the request with the new signature
+	for _, key := range []*ecdsa.PrivateKey{signPriv, user.enrollPrivKey} {
+		hash := primitives.NewHash()
+		raw, _ := proto.Marshal(req)
+		hash.Write(raw)
+		r, s, err := ecdsa.Sign(rand.Reader, key, hash.Sum(nil))
+		if err != nil {
+			return nil, err
+		}
+		R, _ := r.MarshalText()
+		S, _ := s.MarshalText()
+		if req.NewSig == nil {
+			req.NewSig = &pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S}
+		} else {
+			req.Sig = &pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S}
+		}
+	}
+
+	viper.Set("pki.reenrollment.overlap", overlap)
+	defer viper.Set("pki.reenrollment.overlap", 24*time.Hour)
+	resp, err := ecap.ReEnrollCertificatePair(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	user.enrollPrivKey = signPriv
+	return resp, nil
+}
+
+func TestReEnrollCertificatePair(t *testing.T) {
+	user := User{enrollID: "testReEnrollUser", role: 1, affiliation: "institution_a"}
+	if err := registerUser(testAdmin, &user); err != nil {
+		t.Fatal(err)
+	}
+	user.enrollPrivKey, _ = primitives.NewECDSAKey()
+	if _, err := reEnrollUser(&user, 0); err == nil {
+		t.Fatal("An identity which has not enrolled yet should not re-enroll")
+	}
+	if err := enrollUser(&user); err != nil {
+		t.Fatal(err)
+	}
+	first, err := eca.readCertificateByKeyUsage(user.enrollID, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstCert, _ := primitives.DERToX509Certificate(first)
+
+	// a request signed with a key other than the current one is rejected
+	current := user.enrollPrivKey
+	user.enrollPrivKey, _ = primitives.NewECDSAKey()
+	if _, err := reEnrollUser(&user, 0); err == nil {
+		t.Fatal("A re-enrollment request not signed with the current key should be rejected")
+	}
+	user.enrollPrivKey = current
+
+	resp, err := reEnrollUser(&user, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to re-enroll: [%s]", err)
+	}
+	secondCert, err := primitives.DERToX509Certificate(resp.Certs.Sign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := primitives.CheckCertPKAgainstSK(secondCert, user.enrollPrivKey); err != nil {
+		t.Fatalf("The new enrollment certificate does not certify the new key: [%s]", err)
+	}
+	if eca.isRevoked(firstCert.SerialNumber) {
+		t.Fatal("The previous enrollment certificate should stay valid during the overlap")
+	}
+	latest, err := eca.readCertificateByKeyUsage(user.enrollID, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(latest) != string(resp.Certs.Sign) {
+		t.Fatal("The ECA should serve the new enrollment certificate")
+	}
+
+	if _, err := reEnrollUser(&user, 0); err != nil {
+		t.Fatalf("Failed to re-enroll: [%s]", err)
+	}
+	if !eca.isRevoked(secondCert.SerialNumber) {
+		t.Fatal("The previous enrollment certificate should be revoked without overlap")
 	}
 }
@@ -17,14 +17,11 @@ limitations under the License.
 package ca
 
 import (
-	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/json"
 	"errors"
-	"math/big"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/hyperledger/fabric/core/crypto/primitives"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
 	"golang.org/x/net/context"
 )
@@ -38,11 +35,20 @@ type ECAA struct {
 // RegisterUser registers a new user with the ECA.  If the user had been registered before
 // an error is returned.
 //
-func (ecaa *ECAA) RegisterUser(ctx context.Context, in *pb.RegisterUserReq) (*pb.Token, error) {
+func (ecaa *ECAA) RegisterUser(ctx context.Context, in *pb.RegisterUserReq) (_ *pb.Token, err error) {
 	Trace.Println("gRPC ECAA:RegisterUser")
 
+	// the registrar identity is cleared before the registration is recorded
+	var requester string
+	if in.Registrar != nil && in.Registrar.Id != nil {
+		requester = in.Registrar.Id.Id
+	}
+	defer func() {
+		ecaa.eca.audit(auditRegistration, requester, identityOf(in.Id), describeRegistration(in.Role, in.Affiliation), err)
+	}()
+
 	// Check the signature
-	err := ecaa.checkRegistrarSignature(in)
+	err = ecaa.checkRegistrarSignature(in)
 	if err != nil {
 		return nil, err
 	}
@@ -91,16 +97,10 @@ func (ecaa *ECAA) checkRegistrarSignature(in *pb.RegisterUserReq) error {
 	in.Sig = nil
 
 	// Marshall the raw bytes
-	r, s := big.NewInt(0), big.NewInt(0)
-	r.UnmarshalText(sig.R)
-	s.UnmarshalText(sig.S)
-
-	hash := primitives.NewHash()
 	raw, _ = proto.Marshal(in)
-	hash.Write(raw)
 
 	// Check the signature
-	if ecdsa.Verify(cert.PublicKey.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
+	if !verifySignature(cert.PublicKey, raw, sig) {
 		// Signature verification failure
 		Trace.Printf("ECAA.checkRegistrarSignature: failure for %s\n", registrar)
 		return errors.New("Signature verification failed.")
@@ -133,14 +133,8 @@ func (ecaa *ECAA) ReadUserSet(ctx context.Context, in *pb.ReadUserSetReq) (*pb.U
 	sig := in.Sig
 	in.Sig = nil
 
-	r, s := big.NewInt(0), big.NewInt(0)
-	r.UnmarshalText(sig.R)
-	s.UnmarshalText(sig.S)
-
-	hash := primitives.NewHash()
 	raw, _ = proto.Marshal(in)
-	hash.Write(raw)
-	if ecdsa.Verify(cert.PublicKey.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
+	if !verifySignature(cert.PublicKey, raw, sig) {
 		return nil, errors.New("Signature verification failed.")
 	}
 
@@ -165,18 +159,92 @@ func (ecaa *ECAA) ReadUserSet(ctx context.Context, in *pb.ReadUserSetReq) (*pb.U
 	return &pb.UserSet{Users: users}, err
 }
 
-// RevokeCertificate revokes a certificate from the ECA.  Not yet implemented.
+// RevokeCertificate revokes a certificate pair from the ECA, along with the
+// TCerts of its owner.  Admins can revoke the certificates of the members
+// they may register.
 //
-func (ecaa *ECAA) RevokeCertificate(context.Context, *pb.ECertRevokeReq) (*pb.CAStatus, error) {
+func (ecaa *ECAA) RevokeCertificate(ctx context.Context, in *pb.ECertRevokeReq) (_ *pb.CAStatus, err error) {
 	Trace.Println("gRPC ECAA:RevokeCertificate")
 
-	return nil, errors.New("ECAA:RevokeCertificate method not (yet) implemented")
+	if in.Id == nil || in.Cert == nil {
+		return nil, errors.New("Invalid revocation request.")
+	}
+
+	var id string
+	defer func() {
+		ecaa.eca.audit(auditRevocation, in.Id.Id, id, "enrollment certificate pair of "+describeCertificates(in.Cert.Cert), err)
+	}()
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecaa.eca.checkSignature(in.Id.Id, in, sig); err != nil {
+		return nil, err
+	}
+
+	var timestamp int64
+	id, timestamp, err = ecaa.eca.readCertificateOwner(in.Cert.Cert)
+	if err != nil {
+		return nil, errors.New("The certificate was not issued by the ECA.")
+	}
+	if err = ecaa.eca.canRevoke(in.Id.Id, id); err != nil {
+		return nil, err
+	}
+
+	if err = ecaa.eca.revokeCertificatePair(id, timestamp); err != nil {
+		return nil, err
+	}
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
-// PublishCRL requests the creation of a certificate revocation list from the ECA.  Not yet implemented.
+// PublishCRL issues a new certificate revocation list and pushes it to the
+// peers watching the ECA.
 //
-func (ecaa *ECAA) PublishCRL(context.Context, *pb.ECertCRLReq) (*pb.CAStatus, error) {
+func (ecaa *ECAA) PublishCRL(ctx context.Context, in *pb.ECertCRLReq) (*pb.CAStatus, error) {
 	Trace.Println("gRPC ECAA:CreateCRL")
 
-	return nil, errors.New("ECAA:PublishCRL method not (yet) implemented")
+	if in.Id == nil {
+		return nil, errors.New("Invalid CRL request.")
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecaa.eca.checkSignature(in.Id.Id, in, sig); err != nil {
+		return nil, err
+	}
+	if err := ecaa.eca.checkRegistrar(in.Id.Id); err != nil {
+		return nil, err
+	}
+
+	if _, err := ecaa.eca.publishCRL(); err != nil {
+		return nil, err
+	}
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// ReadAuditLog returns the entries of the issuance audit log matching the
+// filters of the request.  Only auditors may read the audit log.
+//
+func (ecaa *ECAA) ReadAuditLog(ctx context.Context, in *pb.AuditLogReq) (*pb.AuditLog, error) {
+	Trace.Println("gRPC ECAA:ReadAuditLog")
+
+	if in.Req == nil {
+		return nil, errors.New("Invalid audit log request.")
+	}
+
+	req := in.Req.Id
+	if ecaa.eca.readRole(req)&int(pb.Role_AUDITOR) == 0 {
+		return nil, errors.New("Access denied.")
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecaa.eca.checkSignature(req, in, sig); err != nil {
+		return nil, err
+	}
+
+	entries, err := ecaa.eca.readAuditLog(in)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AuditLog{Entries: entries}, nil
 }
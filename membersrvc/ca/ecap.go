@@ -17,7 +17,6 @@ limitations under the License.
 package ca
 
 import (
-	"bytes"
 	"crypto/ecdsa"
 	"crypto/subtle"
 	"crypto/x509"
@@ -30,9 +29,12 @@ import (
 	"time"
 
 	"github.com/hyperledger/fabric/core/crypto/primitives/ecies"
+	"golang.org/x/crypto/ed25519"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/util"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
@@ -44,12 +46,12 @@ type ECAP struct {
 	eca *ECA
 }
 
-// ReadCACertificate reads the certificate of the ECA.
+// ReadCACertificate reads the certificate of the ECA, along with the certificates of its issuers.
 //
 func (ecap *ECAP) ReadCACertificate(ctx context.Context, in *pb.Empty) (*pb.Cert, error) {
 	Trace.Println("gRPC ECAP:ReadCACertificate")
 
-	return &pb.Cert{Cert: ecap.eca.raw}, nil
+	return &pb.Cert{Cert: ecap.eca.raw, Chain: ecap.eca.chain}, nil
 }
 
 func (ecap *ECAP) fetchAttributes(cert *pb.Cert) error {
@@ -97,24 +99,50 @@ func (ecap *ECAP) fetchAttributes(cert *pb.Cert) error {
 
 // CreateCertificatePair requests the creation of a new enrollment certificate pair by the ECA.
 //
-func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateReq) (*pb.ECertCreateResp, error) {
+func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateReq) (resp *pb.ECertCreateResp, err error) {
 	Trace.Println("gRPC ECAP:CreateCertificate")
 
+	// the encryption challenge issued first is not recorded, unless it fails
+	defer func() {
+		if err == nil && resp.GetCerts() == nil {
+			return
+		}
+		var detail string
+		if err == nil {
+			detail = describeCertificates(resp.Certs.Sign, resp.Certs.Enc)
+		}
+		ecap.eca.audit(auditEnrollment, identityOf(in.Id), identityOf(in.Id), detail, err)
+	}()
+
 	// validate token
 	var tok, prev []byte
 	var role, state int
 	var enrollID string
 
 	id := in.Id.Id
-	err := ecap.eca.readUser(id).Scan(&role, &tok, &state, &prev, &enrollID)
+	client := comm.ClientIdentity(ctx)
+	if err := ecap.eca.admitEnrollment(id, client); err != nil {
+		return nil, err
+	}
+	if err := ecap.eca.registerFromToken(id, in.Tok.Tok); err != nil {
+		ecap.eca.enrollmentFailed(id, client)
+		return nil, err
+	}
+	if err := ecap.eca.registerFromDirectory(id, in.Tok.Tok); err != nil {
+		Trace.Println(err)
+		ecap.eca.enrollmentFailed(id, client)
+		return nil, err
+	}
+	err = ecap.eca.readUser(id).Scan(&role, &tok, &state, &prev, &enrollID)
 
 	if err != nil {
 		errMsg := "Identity lookup error: " + err.Error()
 		Trace.Println(errMsg)
 		return nil, errors.New(errMsg)
 	}
-	if !bytes.Equal(tok, in.Tok.Tok) {
+	if subtle.ConstantTimeCompare(tok, in.Tok.Tok) != 1 {
 		Trace.Printf("id or token mismatch: id=%s\n", id)
+		ecap.eca.enrollmentFailed(id, client)
 		return nil, errors.New("Identity or token does not match.")
 	}
 
@@ -123,7 +151,6 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 		return nil, err
 	}
 
-	fetchResult := pb.FetchAttrsResult{Status: pb.FetchAttrsResult_SUCCESS, Msg: ""}
 	switch {
 	case state == 0:
 		// initial request, create encryption challenge
@@ -163,79 +190,219 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 		sig := in.Sig
 		in.Sig = nil
 
-		r, s := big.NewInt(0), big.NewInt(0)
-		r.UnmarshalText(sig.R)
-		s.UnmarshalText(sig.S)
-
-		if in.Sign.Type != pb.CryptoType_ECDSA {
-			return nil, errors.New("Unsupported (signing) key type.")
-		}
-		skey, err := x509.ParsePKIXPublicKey(in.Sign.Key)
+		skey, err := parseSigningKey(in.Sign)
 		if err != nil {
 			return nil, err
 		}
 
-		hash := primitives.NewHash()
 		raw, _ := proto.Marshal(in)
-		hash.Write(raw)
-		if ecdsa.Verify(skey.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
+		if !verifySignature(skey, raw, sig) {
 			return nil, errors.New("Signature verification failed.")
 		}
 
-		// create new certificate pair
-		ts := time.Now().Add(-1 * time.Minute).UnixNano()
-
-		spec := NewDefaultCertificateSpecWithCommonName(id, enrollID, skey.(*ecdsa.PublicKey), x509.KeyUsageDigitalSignature, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
-		sraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil, true)
-		if err != nil {
-			Error.Println(err)
-			return nil, err
+		resp, err := ecap.enroll(id, enrollID, role, skey, ekey)
+		if err == nil {
+			ecap.eca.enrollmentSucceeded(id)
 		}
+		return resp, err
+	}
 
-		_ = ioutil.WriteFile("/tmp/ecert_"+id, sraw, 0644)
+	return nil, errors.New("Invalid (=expired) certificate creation token provided.")
+}
 
-		spec = NewDefaultCertificateSpecWithCommonName(id, enrollID, ekey.(*ecdsa.PublicKey), x509.KeyUsageDataEncipherment, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
-		eraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil, true)
-		if err != nil {
-			mutex.Lock()
-			ecap.eca.db.Exec("DELETE FROM Certificates Where id=?", id)
-			mutex.Unlock()
-			Error.Println(err)
-			return nil, err
-		}
+// enroll issues the first enrollment certificate pair of id, for the signing key skey and the
+// encryption key ekey, and marks id as enrolled.
+//
+func (ecap *ECAP) enroll(id, enrollID string, role int, skey, ekey interface{}) (*pb.ECertCreateResp, error) {
+	sraw, eraw, err := ecap.createCertificatePair(id, enrollID, skey, ekey)
+	if err != nil {
+		return nil, err
+	}
 
+	mutex.Lock()
+	_, err = ecap.eca.db.Exec("UPDATE Users SET state=? WHERE id=?", 2, id)
+	mutex.Unlock()
+	if err != nil {
 		mutex.Lock()
-		_, err = ecap.eca.db.Exec("UPDATE Users SET state=? WHERE id=?", 2, id)
+		ecap.eca.db.Exec("DELETE FROM Certificates Where id=?", id)
 		mutex.Unlock()
-		if err != nil {
-			mutex.Lock()
-			ecap.eca.db.Exec("DELETE FROM Certificates Where id=?", id)
-			mutex.Unlock()
-			Error.Println(err)
-			return nil, err
+		Error.Println(err)
+		return nil, err
+	}
+
+	fetchResult := pb.FetchAttrsResult{Status: pb.FetchAttrsResult_SUCCESS, Msg: ""}
+	if role == int(pb.Role_CLIENT) {
+		//Only client have to fetch attributes.
+		if viper.GetBool("aca.enabled") {
+			err = ecap.fetchAttributes(&pb.Cert{Cert: sraw})
+			if err != nil {
+				fetchResult = pb.FetchAttrsResult{Status: pb.FetchAttrsResult_FAILURE, Msg: err.Error()}
+
+			}
 		}
+	}
+
+	return &pb.ECertCreateResp{Certs: &pb.CertPair{Sign: sraw, Enc: eraw}, Chain: &pb.Token{Tok: ecap.eca.obcKey}, Pkchain: ecap.chainKey(role), Tok: nil, FetchResult: &fetchResult}, nil
+}
 
-		var obcECKey []byte
-		if role == int(pb.Role_VALIDATOR) {
-			obcECKey = ecap.eca.obcPriv
-		} else {
-			obcECKey = ecap.eca.obcPub
+// ReEnrollCertificatePair requests a new enrollment certificate pair for new keys from the ECA.
+// The member proves the possession of both its current and its new signing key. The current
+// certificate pair is revoked once the re-enrollment overlap period is over, until when peers
+// keep accepting the signatures of the member with either key.
+//
+func (ecap *ECAP) ReEnrollCertificatePair(ctx context.Context, in *pb.ECertReEnrollReq) (resp *pb.ECertCreateResp, err error) {
+	Trace.Println("gRPC ECAP:ReEnrollCertificatePair")
+
+	defer func() {
+		var detail string
+		if err == nil {
+			detail = describeCertificates(resp.Certs.Sign, resp.Certs.Enc)
 		}
-		if role == int(pb.Role_CLIENT) {
-			//Only client have to fetch attributes.
-			if viper.GetBool("aca.enabled") {
-				err = ecap.fetchAttributes(&pb.Cert{Cert: sraw})
-				if err != nil {
-					fetchResult = pb.FetchAttrsResult{Status: pb.FetchAttrsResult_FAILURE, Msg: err.Error()}
-
-				}
-			}
+		ecap.eca.audit(auditReEnrollment, identityOf(in.Id), identityOf(in.Id), detail, err)
+	}()
+
+	if in.Id == nil || in.Sign == nil || in.Enc == nil || in.NewSig == nil {
+		return nil, errors.New("Invalid re-enrollment request.")
+	}
+
+	var tok, prev []byte
+	var role, state int
+	var enrollID string
+
+	id := in.Id.Id
+	if err := ecap.eca.readUser(id).Scan(&role, &tok, &state, &prev, &enrollID); err != nil {
+		errMsg := "Identity lookup error: " + err.Error()
+		Trace.Println(errMsg)
+		return nil, errors.New(errMsg)
+	}
+	if state != 2 {
+		return nil, errors.New("The identity has not enrolled yet.")
+	}
+
+	current, err := ecap.eca.readCertificateByKeyUsage(id, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		return nil, err
+	}
+	_, timestamp, err := ecap.eca.readCertificateOwner(current)
+	if err != nil {
+		return nil, err
+	}
+
+	// the current key signs the request, the signature of the new key included
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecap.eca.checkSignature(id, in, sig); err != nil {
+		return nil, err
+	}
+
+	skey, err := parseSigningKey(in.Sign)
+	if err != nil {
+		return nil, err
+	}
+	ekey, err := x509.ParsePKIXPublicKey(in.Enc.Key)
+	if err != nil {
+		return nil, err
+	}
+	newSig := in.NewSig
+	in.NewSig = nil
+	raw, _ := proto.Marshal(in)
+	if !verifySignature(skey, raw, newSig) {
+		return nil, errors.New("Signature verification failed.")
+	}
+
+	sraw, eraw, err := ecap.createCertificatePair(id, enrollID, skey, ekey)
+	if err != nil {
+		return nil, err
+	}
+
+	serials, err := ecap.eca.readCertificatePairSerials(id, timestamp)
+	if err == nil {
+		err = ecap.eca.revokeCertificatesAt(id, time.Now().Add(getReEnrollmentOverlap()), serials...)
+	}
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	Info.Printf("Re-enrolled %s, the previous enrollment certificates are revoked in %v.", id, getReEnrollmentOverlap())
+	return &pb.ECertCreateResp{Certs: &pb.CertPair{Sign: sraw, Enc: eraw}, Chain: &pb.Token{Tok: ecap.eca.obcKey}, Pkchain: ecap.chainKey(role)}, nil
+}
+
+// getReEnrollmentOverlap returns how long the previous enrollment
+// certificates of a re-enrolled member stay valid, a day by default.
+//
+func getReEnrollmentOverlap() time.Duration {
+	if !viper.IsSet("pki.reenrollment.overlap") {
+		return 24 * time.Hour
+	}
+	overlap := viper.GetDuration("pki.reenrollment.overlap")
+	if overlap < 0 {
+		overlap = 0
+	}
+	return overlap
+}
+
+// parseSigningKey parses the ECDSA or Ed25519 key a member asks the ECA to certify.
+//
+func parseSigningKey(key *pb.PublicKey) (interface{}, error) {
+	skey, err := primitives.DERToPublicKey(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	switch skey.(type) {
+	case *ecdsa.PublicKey:
+		if key.Type == pb.CryptoType_ECDSA {
+			return skey, nil
+		}
+	case ed25519.PublicKey:
+		if key.Type == pb.CryptoType_ED25519 {
+			return skey, nil
 		}
+	}
+	return nil, errors.New("Unsupported (signing) key type.")
+}
 
-		return &pb.ECertCreateResp{Certs: &pb.CertPair{Sign: sraw, Enc: eraw}, Chain: &pb.Token{Tok: ecap.eca.obcKey}, Pkchain: obcECKey, Tok: nil, FetchResult: &fetchResult}, nil
+// createCertificatePair issues the enrollment certificates of id for the signing key skey and
+// the encryption key ekey.
+//
+func (ecap *ECAP) createCertificatePair(id, enrollID string, skey, ekey interface{}) ([]byte, []byte, error) {
+	epub, ok := ekey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, nil, errors.New("Unsupported (encryption) key type.")
 	}
 
-	return nil, errors.New("Invalid (=expired) certificate creation token provided.")
+	ts := time.Now().Add(-1 * time.Minute).UnixNano()
+
+	spec := NewDefaultPeriodCertificateSpecWithCommonName(id, enrollID, util.GenerateIntUUID(), skey, x509.KeyUsageDigitalSignature, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
+	sraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil, true)
+	if err != nil {
+		Error.Println(err)
+		return nil, nil, err
+	}
+
+	_ = ioutil.WriteFile("/tmp/ecert_"+id, sraw, 0644)
+
+	spec = NewDefaultPeriodCertificateSpecWithCommonName(id, enrollID, util.GenerateIntUUID(), epub, x509.KeyUsageDataEncipherment, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
+	eraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil, true)
+	if err != nil {
+		mutex.Lock()
+		ecap.eca.db.Exec("DELETE FROM Certificates WHERE id=? AND timestamp=?", id, ts)
+		mutex.Unlock()
+		Error.Println(err)
+		return nil, nil, err
+	}
+
+	return sraw, eraw, nil
+}
+
+// chainKey returns the chain key sent to members with the given role: the
+// private key to validators, the public key to the others.
+//
+func (ecap *ECAP) chainKey(role int) []byte {
+	if role == int(pb.Role_VALIDATOR) {
+		return ecap.eca.obcPriv
+	}
+	return ecap.eca.obcPub
 }
 
 // ReadCertificatePair reads an enrollment certificate pair from the ECA.
@@ -273,10 +440,56 @@ func (ecap *ECAP) ReadCertificateByHash(ctx context.Context, hash *pb.Hash) (*pb
 	return &pb.Cert{Cert: raw}, err
 }
 
-// RevokeCertificatePair revokes a certificate pair from the ECA.  Not yet implemented.
+// RevokeCertificatePair revokes a certificate pair from the ECA, along with
+// the TCerts of its owner.  Users can only revoke their own certificates.
 //
-func (ecap *ECAP) RevokeCertificatePair(context.Context, *pb.ECertRevokeReq) (*pb.CAStatus, error) {
+func (ecap *ECAP) RevokeCertificatePair(ctx context.Context, in *pb.ECertRevokeReq) (_ *pb.CAStatus, err error) {
 	Trace.Println("gRPC ECAP:RevokeCertificate")
 
-	return nil, errors.New("ECAP:RevokeCertificate method not (yet) implemented")
+	if in.Id == nil || in.Cert == nil {
+		return nil, errors.New("Invalid revocation request.")
+	}
+	defer func() {
+		ecap.eca.audit(auditRevocation, in.Id.Id, in.Id.Id, "enrollment certificate pair of "+describeCertificates(in.Cert.Cert), err)
+	}()
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecap.eca.checkSignature(in.Id.Id, in, sig); err != nil {
+		return nil, err
+	}
+
+	id, timestamp, err := ecap.eca.readCertificateOwner(in.Cert.Cert)
+	if err != nil {
+		return nil, errors.New("The certificate was not issued by the ECA.")
+	}
+	if id != in.Id.Id {
+		return nil, errors.New("Access denied.")
+	}
+
+	if err = ecap.eca.revokeCertificatePair(id, timestamp); err != nil {
+		return nil, err
+	}
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// ReadCRL reads the certificate revocation list of the ECA.
+//
+func (ecap *ECAP) ReadCRL(ctx context.Context, in *pb.Empty) (*pb.CRL, error) {
+	Trace.Println("gRPC ECAP:ReadCRL")
+
+	raw, err := ecap.eca.readCRL()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CRL{Crl: raw}, nil
+}
+
+// WatchCRL streams the certificate revocation list of the ECA, and every
+// list it publishes thereafter.
+//
+func (ecap *ECAP) WatchCRL(in *pb.Empty, stream pb.ECAP_WatchCRLServer) error {
+	Trace.Println("gRPC ECAP:WatchCRL")
+
+	return ecap.eca.serveCRL(stream)
 }
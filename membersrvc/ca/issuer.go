@@ -0,0 +1,152 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/viper"
+)
+
+// caIssuer is the root or intermediate CA of the organization which issues
+// the certificates of the CAs, rather than them being self-signed.
+//
+type caIssuer struct {
+	cert  *x509.Certificate
+	priv  crypto.Signer
+	chain [][]byte // The certificate of the issuer and those of its own issuers, up to the root
+}
+
+// readCAIssuer reads the CA configured as pki.ca.issuer, if any. The
+// certificate file holds the certificate of the issuer followed by the
+// certificates of its own issuers up to the root.
+//
+func readCAIssuer() (*caIssuer, error) {
+	certFile := viper.GetString("pki.ca.issuer.cert.file")
+	keyFile := viper.GetString("pki.ca.issuer.key.file")
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("Both pki.ca.issuer.cert.file and pki.ca.issuer.key.file are required")
+	}
+
+	cooked, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := decodeCertificates(cooked)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the certificates of %s: %s", certFile, err)
+	}
+	cert, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA || cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return nil, fmt.Errorf("The certificate of %s may not issue certificates", certFile)
+	}
+
+	cooked, err = ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(cooked)
+	if block == nil {
+		return nil, fmt.Errorf("No PEM encoded key in %s", keyFile)
+	}
+	var key interface{}
+	if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+		if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				return nil, fmt.Errorf("Error parsing the key of %s: %s", keyFile, err)
+			}
+		}
+	}
+	priv, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported key type in %s", keyFile)
+	}
+	if err = checkCAKeyPair(cert, priv); err != nil {
+		return nil, err
+	}
+
+	Info.Printf("Issuing the CA certificates with %s", cert.Subject.CommonName)
+	return &caIssuer{cert: cert, priv: priv, chain: chain}, nil
+}
+
+// decodeCertificates returns the DER encoded certificates of a PEM bundle.
+//
+func decodeCertificates(cooked []byte) ([][]byte, error) {
+	var certs [][]byte
+	for {
+		var block *pem.Block
+		block, cooked = pem.Decode(cooked)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, err
+		}
+		certs = append(certs, block.Bytes)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("No PEM encoded certificate found")
+	}
+	return certs, nil
+}
+
+// checkCAKeyPair checks that cert certifies the public key of priv.
+//
+func checkCAKeyPair(cert *x509.Certificate, priv crypto.Signer) error {
+	certPub, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(certPub, pub) {
+		return fmt.Errorf("The certificate of %s does not match its key", cert.Subject.CommonName)
+	}
+	return nil
+}
+
+// readCAChain reads the certificates of the issuers of the CA certificate,
+// none when it is self-signed.
+//
+func (ca *CA) readCAChain(name string) ([][]byte, error) {
+	cooked, err := ioutil.ReadFile(ca.path + "/" + name + ".chain")
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeCertificates(cooked)
+}
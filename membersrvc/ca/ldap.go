@@ -0,0 +1,633 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+)
+
+// directory authenticates the members which are not registered with the ECA
+// against an LDAP directory, and maps the groups they belong to to their role
+// and affiliation.
+//
+type directory struct {
+	url          *url.URL
+	tlsConfig    *tls.Config
+	bindDN       string
+	bindPassword string
+	userBase     string
+	userFilter   string
+	groupAttr    string
+	groups       map[string]directoryGroup
+}
+
+// directoryGroup is the role and affiliation of the members of a group.
+//
+type directoryGroup struct {
+	role        pb.Role
+	affiliation string
+}
+
+// LDAP result codes and protocol operations, RFC 4511
+const (
+	ldapSuccess            = 0
+	ldapInvalidCredentials = 49
+
+	ldapBindRequest       = 0x60
+	ldapBindResponse      = 0x61
+	ldapUnbindRequest     = 0x42
+	ldapSearchRequest     = 0x63
+	ldapSearchResultEntry = 0x64
+	ldapSearchResultDone  = 0x65
+	ldapSearchResultRef   = 0x73
+
+	ldapSimpleAuth        = 0x80
+	ldapScopeWholeSubtree = 2
+	ldapNeverDerefAliases = 0
+
+	ldapFilterAnd      = 0xa0
+	ldapFilterOr       = 0xa1
+	ldapFilterNot      = 0xa2
+	ldapFilterEquality = 0xa3
+	ldapFilterPresent  = 0x87
+)
+
+// BER tags of the universal types used by LDAP
+const (
+	berBoolean     = 0x01
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0a
+	berSequence    = 0x30
+
+	berLongLength = 0x80
+)
+
+// ldapTimeout bounds the connection to the directory and each search
+const ldapTimeout = 10 * time.Second
+
+// newDirectory reads the LDAP directory settings of eca.ldap, it returns nil
+// when members are only registered with the ECA.
+//
+func newDirectory() (*directory, error) {
+	if !viper.GetBool("eca.ldap.enabled") {
+		return nil, nil
+	}
+
+	u, err := url.Parse(viper.GetString("eca.ldap.url"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return nil, fmt.Errorf("Unsupported LDAP URL scheme %q, expected ldap or ldaps", u.Scheme)
+	}
+	d := &directory{
+		url:          u,
+		bindDN:       viper.GetString("eca.ldap.bindDN"),
+		bindPassword: viper.GetString("eca.ldap.bindPassword"),
+		userBase:     viper.GetString("eca.ldap.userBase"),
+		userFilter:   viper.GetString("eca.ldap.userFilter"),
+		groupAttr:    viper.GetString("eca.ldap.groupAttribute"),
+		groups:       make(map[string]directoryGroup),
+	}
+	if d.userFilter == "" {
+		d.userFilter = "(uid=%s)"
+	}
+	if d.groupAttr == "" {
+		d.groupAttr = "memberOf"
+	}
+	if _, err := parseLDAPFilter(fmt.Sprintf(d.userFilter, "user")); err != nil {
+		return nil, fmt.Errorf("Invalid eca.ldap.userFilter: %s", err)
+	}
+
+	if u.Scheme == "ldaps" {
+		host, _ := d.hostPort()
+		d.tlsConfig = &tls.Config{ServerName: host}
+		if file := viper.GetString("eca.ldap.tls.rootcert.file"); file != "" {
+			raw, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			d.tlsConfig.RootCAs = x509.NewCertPool()
+			if !d.tlsConfig.RootCAs.AppendCertsFromPEM(raw) {
+				return nil, fmt.Errorf("No certificate found in %s", file)
+			}
+		}
+	}
+
+	// <group DN>: <system_role> <Affiliation>
+	for dn, flds := range viper.GetStringMapString("eca.ldap.groups") {
+		vals := strings.Fields(flds)
+		if len(vals) == 0 {
+			return nil, fmt.Errorf("No role given to LDAP group %s", dn)
+		}
+		role, err := strconv.Atoi(vals[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid role of LDAP group %s: %s", dn, err)
+		}
+		group := directoryGroup{role: pb.Role(role)}
+		if len(vals) > 1 {
+			group.affiliation = vals[1]
+		}
+		d.groups[normalizeDN(dn)] = group
+	}
+	if len(d.groups) == 0 {
+		return nil, errors.New("No LDAP group is mapped to a role in eca.ldap.groups")
+	}
+
+	return d, nil
+}
+
+// normalizeDN returns the form distinguished names are compared in.
+//
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, part := range parts {
+		parts[i] = strings.ToLower(strings.TrimSpace(part))
+	}
+	return strings.Join(parts, ",")
+}
+
+// authenticate checks the password of the member id against the directory,
+// and returns the role and affiliation of the groups it belongs to. The
+// roles of several groups are combined, the affiliation is that of the first
+// group in the order of their names.
+//
+func (d *directory) authenticate(id, password string) (pb.Role, string, error) {
+	// an empty password makes an unauthenticated bind, which succeeds
+	if id == "" || password == "" {
+		return 0, "", errors.New("Identity or token does not match.")
+	}
+
+	conn, err := d.dial()
+	if err != nil {
+		return 0, "", err
+	}
+	defer conn.close()
+
+	if d.bindDN != "" {
+		if err := conn.bind(d.bindDN, d.bindPassword); err != nil {
+			return 0, "", fmt.Errorf("Error binding to the LDAP directory: %s", err)
+		}
+	}
+	entries, err := conn.search(d.userBase, fmt.Sprintf(d.userFilter, escapeLDAPFilter(id)), d.groupAttr)
+	if err != nil {
+		return 0, "", fmt.Errorf("Error searching the LDAP directory: %s", err)
+	}
+	if len(entries) != 1 {
+		Trace.Printf("%d LDAP entries match %s", len(entries), id)
+		return 0, "", errors.New("Identity or token does not match.")
+	}
+	if err := conn.bind(entries[0].dn, password); err != nil {
+		Trace.Printf("LDAP bind of %s failed: %s", entries[0].dn, err)
+		return 0, "", errors.New("Identity or token does not match.")
+	}
+
+	var memberOf []string
+	for _, dn := range entries[0].attributes[strings.ToLower(d.groupAttr)] {
+		if _, ok := d.groups[normalizeDN(dn)]; ok {
+			memberOf = append(memberOf, normalizeDN(dn))
+		}
+	}
+	if len(memberOf) == 0 {
+		return 0, "", fmt.Errorf("%s is not a member of an LDAP group mapped to a role", id)
+	}
+	sort.Strings(memberOf)
+
+	var role pb.Role
+	var affiliation string
+	for _, dn := range memberOf {
+		role |= d.groups[dn].role
+		if affiliation == "" {
+			affiliation = d.groups[dn].affiliation
+		}
+	}
+	return role, affiliation, nil
+}
+
+// ldapConn is a connection to the directory.
+//
+type ldapConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID int64
+}
+
+// ldapEntry is an entry returned by a search, with its attributes by
+// lowercase name.
+//
+type ldapEntry struct {
+	dn         string
+	attributes map[string][]string
+}
+
+// hostPort returns the host and the port of the directory, the default port
+// of the scheme when the URL has none.
+//
+func (d *directory) hostPort() (string, string) {
+	host, port, err := net.SplitHostPort(d.url.Host)
+	if err != nil {
+		// No port, an IPv6 address is still within brackets
+		host, port = strings.TrimSuffix(strings.TrimPrefix(d.url.Host, "["), "]"), ""
+	}
+	if port == "" {
+		if d.url.Scheme == "ldaps" {
+			port = "636"
+		} else {
+			port = "389"
+		}
+	}
+	return host, port
+}
+
+func (d *directory) dial() (*ldapConn, error) {
+	address := net.JoinHostPort(d.hostPort())
+
+	dialer := &net.Dialer{Timeout: ldapTimeout}
+	var conn net.Conn
+	var err error
+	if d.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, d.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to the LDAP directory: %s", err)
+	}
+	conn.SetDeadline(time.Now().Add(ldapTimeout))
+	return &ldapConn{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (c *ldapConn) close() {
+	c.send(berTLV(ldapUnbindRequest, nil))
+	c.conn.Close()
+}
+
+// send writes an LDAPMessage carrying the protocol operation op.
+//
+func (c *ldapConn) send(op []byte) (int64, error) {
+	c.nextID++
+	_, err := c.conn.Write(berTLV(berSequence, berInt(berInteger, c.nextID), op))
+	return c.nextID, err
+}
+
+// receive reads the next LDAPMessage answering the request id, and returns
+// the tag and the elements of its protocol operation.
+//
+func (c *ldapConn) receive(id int64) (byte, []berElement, error) {
+	tag, content, err := berRead(c.reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	if tag != berSequence {
+		return 0, nil, errors.New("Malformed LDAP message")
+	}
+	msg, err := berParse(content)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(msg) < 2 || msg[0].tag != berInteger || berToInt(msg[0].content) != id {
+		return 0, nil, errors.New("Unexpected LDAP message")
+	}
+	op, err := berParse(msg[1].content)
+	return msg[1].tag, op, err
+}
+
+// ldapResult checks the result code of an LDAPResult.
+//
+func ldapResult(op []berElement) error {
+	if len(op) < 3 {
+		return errors.New("Malformed LDAP result")
+	}
+	if code := berToInt(op[0].content); code != ldapSuccess {
+		if code == ldapInvalidCredentials {
+			return errors.New("invalid credentials")
+		}
+		return fmt.Errorf("LDAP result code %d: %s", code, op[2].content)
+	}
+	return nil
+}
+
+func (c *ldapConn) bind(dn, password string) error {
+	id, err := c.send(berTLV(ldapBindRequest,
+		berInt(berInteger, 3),
+		berTLV(berOctetString, []byte(dn)),
+		berTLV(ldapSimpleAuth, []byte(password))))
+	if err != nil {
+		return err
+	}
+	tag, op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if tag != ldapBindResponse {
+		return errors.New("Unexpected LDAP response to bind")
+	}
+	return ldapResult(op)
+}
+
+func (c *ldapConn) search(base, filter string, attrs ...string) ([]ldapEntry, error) {
+	rawFilter, err := parseLDAPFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	var rawAttrs [][]byte
+	for _, attr := range attrs {
+		rawAttrs = append(rawAttrs, berTLV(berOctetString, []byte(attr)))
+	}
+	id, err := c.send(berTLV(ldapSearchRequest,
+		berTLV(berOctetString, []byte(base)),
+		berInt(berEnumerated, ldapScopeWholeSubtree),
+		berInt(berEnumerated, ldapNeverDerefAliases),
+		berInt(berInteger, 2), // more than one match is an error anyway
+		berInt(berInteger, int64(ldapTimeout/time.Second)),
+		berTLV(berBoolean, []byte{0}),
+		rawFilter,
+		berTLV(berSequence, rawAttrs...)))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ldapEntry
+	for {
+		tag, op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case ldapSearchResultEntry:
+			entry, err := parseLDAPEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case ldapSearchResultRef:
+			// referrals to other servers are not followed
+		case ldapSearchResultDone:
+			if err := ldapResult(op); err != nil && len(entries) < 2 {
+				return nil, err
+			}
+			return entries, nil
+		default:
+			return nil, errors.New("Unexpected LDAP response to search")
+		}
+	}
+}
+
+func parseLDAPEntry(op []berElement) (ldapEntry, error) {
+	if len(op) < 2 {
+		return ldapEntry{}, errors.New("Malformed LDAP entry")
+	}
+	entry := ldapEntry{dn: string(op[0].content), attributes: make(map[string][]string)}
+	attrs, err := berParse(op[1].content)
+	if err != nil {
+		return ldapEntry{}, err
+	}
+	for _, attr := range attrs {
+		parts, err := berParse(attr.content)
+		if err != nil || len(parts) < 2 {
+			return ldapEntry{}, errors.New("Malformed LDAP attribute")
+		}
+		vals, err := berParse(parts[1].content)
+		if err != nil {
+			return ldapEntry{}, err
+		}
+		name := strings.ToLower(string(parts[0].content))
+		for _, val := range vals {
+			entry.attributes[name] = append(entry.attributes[name], string(val.content))
+		}
+	}
+	return entry, nil
+}
+
+// escapeLDAPFilter escapes the special characters of an assertion value,
+// RFC 4515.
+//
+func escapeLDAPFilter(value string) string {
+	var out []byte
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '*', '(', ')', '\\', 0:
+			out = append(out, []byte(fmt.Sprintf("\\%02x", c))...)
+		default:
+			out = append(out, c)
+		}
+	}
+	return string(out)
+}
+
+// parseLDAPFilter encodes the string representation of a search filter,
+// RFC 4515. Equality and presence assertions can be combined with &, | and
+// !, substring and ordering assertions are not supported.
+//
+func parseLDAPFilter(filter string) ([]byte, error) {
+	raw, rest, err := parseLDAPFilterItem(filter)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, errors.New("trailing characters after the filter")
+	}
+	return raw, nil
+}
+
+func parseLDAPFilterItem(filter string) ([]byte, string, error) {
+	if !strings.HasPrefix(filter, "(") {
+		return nil, "", errors.New("a filter must be enclosed in parentheses")
+	}
+	filter = filter[1:]
+	if filter == "" {
+		return nil, "", errors.New("unterminated filter")
+	}
+
+	switch filter[0] {
+	case '&', '|', '!':
+		tag := map[byte]byte{'&': ldapFilterAnd, '|': ldapFilterOr, '!': ldapFilterNot}[filter[0]]
+		rest := filter[1:]
+		var items [][]byte
+		for strings.HasPrefix(rest, "(") {
+			item, r, err := parseLDAPFilterItem(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			items = append(items, item)
+			rest = r
+		}
+		if !strings.HasPrefix(rest, ")") || len(items) == 0 || (tag == ldapFilterNot && len(items) != 1) {
+			return nil, "", errors.New("malformed filter")
+		}
+		return berTLV(tag, items...), rest[1:], nil
+	}
+
+	end := strings.Index(filter, ")")
+	if end < 0 {
+		return nil, "", errors.New("unterminated filter")
+	}
+	eq := strings.Index(filter[:end], "=")
+	if eq <= 0 {
+		return nil, "", errors.New("malformed assertion")
+	}
+	attr, value := filter[:eq], filter[eq+1:end]
+	if strings.ContainsAny(attr, "<>~:") {
+		return nil, "", errors.New("only equality and presence assertions are supported")
+	}
+	if value == "*" {
+		return berTLV(ldapFilterPresent, []byte(attr)), filter[end+1:], nil
+	}
+	if strings.Contains(value, "*") {
+		return nil, "", errors.New("substring assertions are not supported")
+	}
+	unescaped, err := unescapeLDAPFilter(value)
+	if err != nil {
+		return nil, "", err
+	}
+	return berTLV(ldapFilterEquality, berTLV(berOctetString, []byte(attr)), berTLV(berOctetString, unescaped)), filter[end+1:], nil
+}
+
+func unescapeLDAPFilter(value string) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			out = append(out, value[i])
+			continue
+		}
+		if i+2 >= len(value) {
+			return nil, errors.New("malformed escape sequence")
+		}
+		b, err := strconv.ParseUint(value[i+1:i+3], 16, 8)
+		if err != nil {
+			return nil, errors.New("malformed escape sequence")
+		}
+		out = append(out, byte(b))
+		i += 2
+	}
+	return out, nil
+}
+
+// berElement is a BER encoded element, with its tag and its content.
+//
+type berElement struct {
+	tag     byte
+	content []byte
+}
+
+// berTLV encodes an element with a single byte tag.
+//
+func berTLV(tag byte, contents ...[]byte) []byte {
+	var content []byte
+	for _, c := range contents {
+		content = append(content, c...)
+	}
+
+	out := []byte{tag}
+	if len(content) < berLongLength {
+		out = append(out, byte(len(content)))
+	} else {
+		var length []byte
+		for l := len(content); l > 0; l >>= 8 {
+			length = append([]byte{byte(l)}, length...)
+		}
+		out = append(out, berLongLength|byte(len(length)))
+		out = append(out, length...)
+	}
+	return append(out, content...)
+}
+
+// berInt encodes an INTEGER or ENUMERATED in two's complement.
+//
+func berInt(tag byte, n int64) []byte {
+	var content []byte
+	for {
+		content = append([]byte{byte(n)}, content...)
+		if (n < 0x80 && n >= -0x80) || len(content) == 8 {
+			break
+		}
+		n >>= 8
+	}
+	return berTLV(tag, content)
+}
+
+func berToInt(content []byte) int64 {
+	var n int64
+	for i, b := range content {
+		if i == 0 && b&0x80 != 0 {
+			n = -1
+		}
+		n = n<<8 | int64(b)
+	}
+	return n
+}
+
+// berRead reads an element with a single byte tag and a definite length.
+//
+func berRead(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := int(header[1])
+	if header[1]&berLongLength != 0 {
+		n := int(header[1] &^ berLongLength)
+		if n == 0 || n > 4 {
+			return 0, nil, errors.New("Unsupported BER length")
+		}
+		raw := make([]byte, n)
+		if _, err := io.ReadFull(r, raw); err != nil {
+			return 0, nil, err
+		}
+		length = 0
+		for _, b := range raw {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > 1<<24 {
+		return 0, nil, errors.New("BER element too large")
+	}
+	content := make([]byte, length)
+	_, err := io.ReadFull(r, content)
+	return header[0], content, err
+}
+
+// berParse splits the content of a constructed element into its elements.
+//
+func berParse(content []byte) ([]berElement, error) {
+	var elements []berElement
+	r := strings.NewReader(string(content))
+	for r.Len() > 0 {
+		tag, c, err := berRead(r)
+		if err != nil {
+			return nil, errors.New("Malformed BER element")
+		}
+		elements = append(elements, berElement{tag: tag, content: c})
+	}
+	return elements, nil
+}
@@ -0,0 +1,245 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+)
+
+// testDirectoryEntry is an entry of the fake LDAP directory.
+type testDirectoryEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+var testDirectory = []testDirectoryEntry{
+	{dn: "cn=admin,dc=example,dc=com", password: "adminpw"},
+	{dn: "uid=ldapUser,ou=people,dc=example,dc=com", password: "ldapUserPwd", attributes: map[string][]string{
+		"objectclass": {"person"},
+		"uid":         {"ldapUser"},
+		"memberof":    {"CN=Clients,OU=Groups,DC=example,DC=com", "cn=others,ou=groups,dc=example,dc=com"},
+	}},
+	{dn: "uid=ldapNobody,ou=people,dc=example,dc=com", password: "ldapNobodyPwd", attributes: map[string][]string{
+		"objectclass": {"person"},
+		"uid":         {"ldapNobody"},
+	}},
+}
+
+// matchTestFilter evaluates the and, equality and presence filters the tests use.
+func matchTestFilter(filter berElement, entry testDirectoryEntry) bool {
+	items, _ := berParse(filter.content)
+	switch filter.tag {
+	case ldapFilterAnd:
+		for _, item := range items {
+			if !matchTestFilter(item, entry) {
+				return false
+			}
+		}
+		return true
+	case ldapFilterEquality:
+		for _, val := range entry.attributes[strings.ToLower(string(items[0].content))] {
+			if val == string(items[1].content) {
+				return true
+			}
+		}
+	case ldapFilterPresent:
+		return len(entry.attributes[strings.ToLower(string(filter.content))]) > 0
+	}
+	return false
+}
+
+func ldapTestResult(code int64) []byte {
+	return append(berInt(berEnumerated, code), append(berTLV(berOctetString, nil), berTLV(berOctetString, nil)...)...)
+}
+
+// serveTestDirectory answers the bind and search requests of a connection.
+func serveTestDirectory(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		_, content, err := berRead(reader)
+		if err != nil {
+			return
+		}
+		msg, _ := berParse(content)
+		id := msg[0].content
+		op, _ := berParse(msg[1].content)
+		reply := func(tag byte, contents ...[]byte) {
+			conn.Write(berTLV(berSequence, berTLV(berInteger, id), berTLV(tag, contents...)))
+		}
+
+		switch msg[1].tag {
+		case ldapBindRequest:
+			code := int64(ldapInvalidCredentials)
+			for _, entry := range testDirectory {
+				if entry.dn == string(op[1].content) && entry.password == string(op[2].content) {
+					code = ldapSuccess
+				}
+			}
+			reply(ldapBindResponse, ldapTestResult(code))
+		case ldapSearchRequest:
+			for _, entry := range testDirectory {
+				if !strings.HasSuffix(entry.dn, string(op[0].content)) || !matchTestFilter(op[6], entry) {
+					continue
+				}
+				var attrs [][]byte
+				for name, vals := range entry.attributes {
+					var raw [][]byte
+					for _, val := range vals {
+						raw = append(raw, berTLV(berOctetString, []byte(val)))
+					}
+					attrs = append(attrs, berTLV(berSequence, berTLV(berOctetString, []byte(name)), berTLV(0x31, raw...)))
+				}
+				reply(ldapSearchResultEntry, berTLV(berOctetString, []byte(entry.dn)), berTLV(berSequence, attrs...))
+			}
+			reply(ldapSearchResultDone, ldapTestResult(ldapSuccess))
+		default:
+			return
+		}
+	}
+}
+
+// startTestDirectory configures the ECA to register the members of a fake
+// LDAP directory, and returns a function restoring the configuration.
+func startTestDirectory(t *testing.T) func() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestDirectory(conn)
+		}
+	}()
+
+	viper.Set("eca.ldap.enabled", true)
+	viper.Set("eca.ldap.url", "ldap://"+listener.Addr().String())
+	viper.Set("eca.ldap.bindDN", "cn=admin,dc=example,dc=com")
+	viper.Set("eca.ldap.bindPassword", "adminpw")
+	viper.Set("eca.ldap.userBase", "ou=people,dc=example,dc=com")
+	viper.Set("eca.ldap.userFilter", "(&(objectClass=person)(uid=%s))")
+	viper.Set("eca.ldap.groups", map[string]string{
+		"cn=clients,ou=groups,dc=example,dc=com": "1 institution_a",
+		"cn=others,ou=groups,dc=example,dc=com":  "2",
+	})
+
+	return func() {
+		listener.Close()
+		viper.Set("eca.ldap.enabled", false)
+		eca.directory = nil
+	}
+}
+
+func TestLDAPFilter(t *testing.T) {
+	if escaped := escapeLDAPFilter(`a*(b)\`); escaped != `a\2a\28b\29\5c` {
+		t.Fatalf("Unexpected escaping: %s", escaped)
+	}
+
+	raw, err := parseLDAPFilter(`(&(uid=a\2ab)(objectClass=*))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := berTLV(ldapFilterAnd,
+		berTLV(ldapFilterEquality, berTLV(berOctetString, []byte("uid")), berTLV(berOctetString, []byte("a*b"))),
+		berTLV(ldapFilterPresent, []byte("objectClass")))
+	if !bytes.Equal(raw, expected) {
+		t.Fatalf("Unexpected filter encoding: %x", raw)
+	}
+
+	for _, filter := range []string{"uid=a", "(uid=a", "(uid=a*)", "(uid>=a)", "(!(a=b)(c=d))", "(uid=a)(uid=b)", `(uid=\2)`} {
+		if _, err := parseLDAPFilter(filter); err == nil {
+			t.Fatalf("Filter %s should be rejected", filter)
+		}
+	}
+}
+
+func TestDirectoryHostPort(t *testing.T) {
+	for raw, expected := range map[string][2]string{
+		"ldap://ldap.example.com":       {"ldap.example.com", "389"},
+		"ldaps://ldap.example.com":      {"ldap.example.com", "636"},
+		"ldap://ldap.example.com:10389": {"ldap.example.com", "10389"},
+		"ldaps://[::1]":                 {"::1", "636"},
+		"ldap://[::1]:10389":            {"::1", "10389"},
+		"ldap://127.0.0.1:":             {"127.0.0.1", "389"},
+	} {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &directory{url: u}
+		if host, port := d.hostPort(); host != expected[0] || port != expected[1] {
+			t.Errorf("Expected %s to be dialed at %s port %s, got %s port %s", raw, expected[0], expected[1], host, port)
+		}
+	}
+}
+
+func TestDirectoryAuthenticate(t *testing.T) {
+	defer startTestDirectory(t)()
+
+	d, err := newDirectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	role, affiliation, err := d.authenticate("ldapUser", "ldapUserPwd")
+	if err != nil {
+		t.Fatalf("Failed to authenticate: [%s]", err)
+	}
+	if role != pb.Role_CLIENT|pb.Role_PEER || affiliation != "institution_a" {
+		t.Fatalf("Unexpected role %d and affiliation %s", role, affiliation)
+	}
+
+	for _, creds := range [][2]string{{"ldapUser", "bad"}, {"ldapUser", ""}, {"unknown", "ldapUserPwd"}, {"*", "ldapUserPwd"}, {"ldapNobody", "ldapNobodyPwd"}} {
+		if _, _, err := d.authenticate(creds[0], creds[1]); err == nil {
+			t.Fatalf("%s should not authenticate with password %q", creds[0], creds[1])
+		}
+	}
+}
+
+func TestEnrollFromDirectory(t *testing.T) {
+	defer startTestDirectory(t)()
+	viper.Set("eca.ldap.groups", map[string]string{"cn=clients,ou=groups,dc=example,dc=com": "1 institution_a"})
+
+	d, err := newDirectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eca.directory = d
+
+	if err := enrollUser(&User{enrollID: "ldapUser", enrollPwd: []byte("bad")}); err == nil {
+		t.Fatal("A member of the directory should not enroll with a bad password")
+	}
+	if err := enrollUser(&User{enrollID: "ldapUser", enrollPwd: []byte("ldapUserPwd")}); err != nil {
+		t.Fatalf("Failed to enroll a member of the directory: [%s]", err)
+	}
+	if role := eca.readRole("ldapUser"); role != int(pb.Role_CLIENT) {
+		t.Fatalf("The member of the directory should be registered as client, got role %d", role)
+	}
+}
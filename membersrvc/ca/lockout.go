@@ -0,0 +1,161 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/spf13/viper"
+)
+
+// enrollmentGuard protects the enrollment secrets of the members against
+// guessing: it throttles the enrollment attempts of each client and for each
+// enrollment ID, and locks an enrollment ID out after repeated failures.
+//
+type enrollmentGuard struct {
+	clients *comm.RateLimiter
+	users   *comm.RateLimiter
+
+	// an enrollment ID is locked out for duration after attempts failures,
+	// never if attempts is 0
+	attempts int
+	duration time.Duration
+}
+
+func newEnrollmentGuard() *enrollmentGuard {
+	return &enrollmentGuard{
+		clients:  comm.NewRateLimiter("eca.enrollment.throttle.client"),
+		users:    comm.NewRateLimiter("eca.enrollment.throttle.user"),
+		attempts: viper.GetInt("eca.enrollment.lockout.attempts"),
+		duration: viper.GetDuration("eca.enrollment.lockout.duration"),
+	}
+}
+
+// initializeLockoutTables creates the table of the failed enrollment
+// attempts, which is shared by the instances of membersrvc sharing the
+// database of the ECA.
+//
+func initializeLockoutTables(db *sql.DB) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS EnrollmentFailures (row INTEGER PRIMARY KEY, id VARCHAR(64), failures INTEGER, lastFailure INTEGER)")
+	return err
+}
+
+// admitEnrollment returns an error if the enrollment attempt of id by client
+// is throttled or if id is locked out.
+//
+func (eca *ECA) admitEnrollment(id, client string) error {
+	if err := eca.guard.clients.Admit(client); err != nil {
+		logSecurityEvent("enrollment_throttled", "id", id, "client", client, "by", "client")
+		return err
+	}
+	if err := eca.guard.users.Admit(id); err != nil {
+		logSecurityEvent("enrollment_throttled", "id", id, "client", client, "by", "id")
+		return err
+	}
+	if eca.guard.attempts <= 0 {
+		return nil
+	}
+
+	var failures int
+	var last int64
+	mutex.RLock()
+	err := eca.db.QueryRow("SELECT failures, lastFailure FROM EnrollmentFailures WHERE id=?", id).Scan(&failures, &last)
+	mutex.RUnlock()
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if until := time.Unix(0, last).Add(eca.guard.duration); failures >= eca.guard.attempts && time.Now().Before(until) {
+		logSecurityEvent("enrollment_locked_out", "id", id, "client", client, "failures", failures)
+		retry := until.Sub(time.Now())
+		retry -= retry % time.Second
+		return fmt.Errorf("Enrollment of %s locked after %d failed attempts, retry in %s.", id, failures, retry)
+	}
+	return nil
+}
+
+// enrollmentFailed records that client presented a wrong enrollment secret
+// for id, and locks id out once the failures reach the configured attempts.
+// Failures older than the lockout duration are forgotten.
+//
+func (eca *ECA) enrollmentFailed(id, client string) {
+	logSecurityEvent("enrollment_failed", "id", id, "client", client)
+	if eca.guard.attempts <= 0 {
+		return
+	}
+
+	now := time.Now()
+	failures := 1
+	mutex.Lock()
+	var last int64
+	err := eca.db.QueryRow("SELECT failures, lastFailure FROM EnrollmentFailures WHERE id=?", id).Scan(&failures, &last)
+	switch {
+	case err == sql.ErrNoRows:
+		failures = 1
+		_, err = eca.db.Exec("INSERT INTO EnrollmentFailures (id, failures, lastFailure) VALUES (?, ?, ?)", id, failures, now.UnixNano())
+	case err == nil:
+		if now.Sub(time.Unix(0, last)) >= eca.guard.duration {
+			failures = 0
+		}
+		failures++
+		_, err = eca.db.Exec("UPDATE EnrollmentFailures SET failures=?, lastFailure=? WHERE id=?", failures, now.UnixNano(), id)
+	}
+	if err == nil {
+		_, err = eca.db.Exec("DELETE FROM EnrollmentFailures WHERE lastFailure<?", now.Add(-eca.guard.duration).UnixNano())
+	}
+	mutex.Unlock()
+
+	if err != nil {
+		Error.Printf("Failed to record the failed enrollment of %s: %s", id, err)
+		return
+	}
+	if failures == eca.guard.attempts {
+		logSecurityEvent("enrollment_lockout", "id", id, "client", client, "failures", failures, "duration", eca.guard.duration)
+		eca.audit(auditLockout, id, id, fmt.Sprintf("locked for %s after %d failed attempts, the last from %s", eca.guard.duration, failures, client), nil)
+	}
+}
+
+// enrollmentSucceeded forgets the failed enrollment attempts of id.
+//
+func (eca *ECA) enrollmentSucceeded(id string) {
+	if eca.guard.attempts <= 0 {
+		return
+	}
+	mutex.Lock()
+	_, err := eca.db.Exec("DELETE FROM EnrollmentFailures WHERE id=?", id)
+	mutex.Unlock()
+	if err != nil {
+		Error.Printf("Failed to reset the failed enrollments of %s: %s", id, err)
+	}
+}
+
+// logSecurityEvent logs an event of the enrollment of the members as a
+// single line of key=value pairs, for log processing tools to pick up.
+//
+func logSecurityEvent(event string, fields ...interface{}) {
+	pairs := []string{"security_event=" + event}
+	for i := 0; i+1 < len(fields); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%v=%q", fields[i], fmt.Sprint(fields[i+1])))
+	}
+	Warning.Println(strings.Join(pairs, " "))
+}
@@ -71,8 +71,8 @@ func setupTestConfig() {
 func initPKI() {
 	LogInit(ioutil.Discard, os.Stdout, os.Stdout, os.Stderr, os.Stdout)
 	CacheConfiguration() // Cache configuration
-	aca = NewACA()
 	eca = NewECA()
+	aca = NewACA(eca)
 	tca = NewTCA(eca)
 }
 
@@ -0,0 +1,105 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// affiliationPolicy governs the TCerts issued to the members of an
+// affiliation group: the attributes they may embed and the chaincodes they
+// may invoke. A nil list allows any.
+type affiliationPolicy struct {
+	group      string
+	attributes []string
+	chaincodes []string
+}
+
+// readAffiliationPolicy returns the policy configured in tca.affiliations
+// for affiliation, or else for its nearest parent group, nil if there is none
+func (tca *TCA) readAffiliationPolicy(affiliation string) (*affiliationPolicy, error) {
+	policies := viper.GetStringMap("tca.affiliations")
+	if affiliation == "" || len(policies) == 0 {
+		return nil, nil
+	}
+
+	groups, err := tca.eca.readAffiliationGroups()
+	if err != nil {
+		return nil, err
+	}
+	var group *AffiliationGroup
+	for _, g := range groups {
+		if g.name == affiliation {
+			group = g
+			break
+		}
+	}
+
+	for ; group != nil; group = group.parent {
+		// viper lowercases the keys of the configuration
+		value, ok := policies[strings.ToLower(group.name)]
+		if !ok {
+			continue
+		}
+		policy := &affiliationPolicy{group: group.name}
+		for key, list := range cast.ToStringMap(value) {
+			switch key {
+			case "attributes":
+				policy.attributes = append([]string{}, cast.ToStringSlice(list)...)
+			case "chaincodes":
+				policy.chaincodes = append([]string{}, cast.ToStringSlice(list)...)
+			}
+		}
+		return policy, nil
+	}
+	return nil, nil
+}
+
+// checkAttributes returns an error if one of attrs may not be embedded in
+// the TCerts of the group
+func (policy *affiliationPolicy) checkAttributes(attrs []*pb.TCertAttribute) error {
+	if policy == nil || policy.attributes == nil {
+		return nil
+	}
+	for _, attr := range attrs {
+		if !containsAttributeName(policy.attributes, attr.AttributeName) {
+			return fmt.Errorf("Attribute %s may not be embedded in the TCerts of %s", attr.AttributeName, policy.group)
+		}
+	}
+	return nil
+}
+
+// chaincodesExtension returns the extension restricting the TCerts of the
+// group to its chaincodes, nil if they may invoke any
+func (policy *affiliationPolicy) chaincodesExtension() (*pkix.Extension, error) {
+	if policy == nil || policy.chaincodes == nil {
+		return nil, nil
+	}
+	value, err := asn1.Marshal(policy.chaincodes)
+	if err != nil {
+		return nil, err
+	}
+	return &pkix.Extension{Id: primitives.TCertChaincodes, Critical: false, Value: value}, nil
+}
@@ -0,0 +1,449 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocraft/web"
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ed25519"
+
+	"google/protobuf"
+)
+
+// RESTServer serves the registration, enrollment and TCert interfaces of the
+// CA over HTTPS, for the clients which do not speak gRPC. Enrollment is
+// authenticated with the enrollment ID and secret of the member, the other
+// requests with its enrollment certificate, presented as TLS client
+// certificate.
+//
+type RESTServer struct {
+	eca *ECA
+	tca *TCA
+}
+
+// restContext is the context of a request to the REST interface.
+//
+type restContext struct {
+	server *RESTServer
+
+	// The member who authenticated with its enrollment certificate
+	id   string
+	cert *x509.Certificate
+}
+
+type restResult struct {
+	OK    string `json:",omitempty"`
+	Error string `json:",omitempty"`
+}
+
+// RegisterRequest is the payload of a registration request.
+//
+type RegisterRequest struct {
+	ID            string   `json:"id"`
+	Affiliation   string   `json:"affiliation"`
+	Role          string   `json:"role"`
+	Roles         []string `json:"roles,omitempty"`
+	DelegateRoles []string `json:"delegateRoles,omitempty"`
+}
+
+// RegisterResponse carries the one-time enrollment secret of the registered member.
+//
+type RegisterResponse struct {
+	Secret string `json:"secret"`
+}
+
+// EnrollRequest carries the PEM encoded certificate signing requests for the
+// signing and the encryption key of the member.
+//
+type EnrollRequest struct {
+	Sign string `json:"sign"`
+	Enc  string `json:"enc"`
+}
+
+// EnrollResponse carries the PEM encoded enrollment certificate pair, and the
+// chain keys.
+//
+type EnrollResponse struct {
+	Sign    string `json:"sign"`
+	Enc     string `json:"enc"`
+	Chain   []byte `json:"chain"`
+	Pkchain []byte `json:"pkchain"`
+}
+
+// TCertRequest is the payload of a TCert request.
+//
+type TCertRequest struct {
+	Num        uint32   `json:"num"`
+	Attributes []string `json:"attributes,omitempty"`
+}
+
+// TCertResponse carries the PEM encoded TCerts issued, and the key the member
+// derives their keys with.
+//
+type TCertResponse struct {
+	Key   []byte       `json:"key"`
+	Certs []TCertEntry `json:"certs"`
+}
+
+// TCertEntry is a TCert with its pre-key.
+//
+type TCertEntry struct {
+	Cert  string `json:"cert"`
+	Prek0 []byte `json:"prek0"`
+}
+
+// NewRESTServer sets up the REST interface of the ECA and TCA.
+//
+func NewRESTServer(eca *ECA, tca *TCA) *RESTServer {
+	return &RESTServer{eca: eca, tca: tca}
+}
+
+// Handler returns the router of the REST interface.
+//
+func (s *RESTServer) Handler() http.Handler {
+	router := web.New(restContext{})
+	router.Middleware(func(c *restContext, rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
+		c.server = s
+		rw.Header().Set("Content-Type", "application/json")
+		next(rw, req)
+	})
+	router.Get("/cacerts", (*restContext).ReadCACertificates)
+	router.Post("/enrollment", (*restContext).Enroll)
+
+	authenticated := router.Subrouter(restContext{}, "")
+	authenticated.Middleware((*restContext).authenticate)
+	authenticated.Post("/registrar", (*restContext).Register)
+	authenticated.Post("/tcerts", (*restContext).CreateTCerts)
+
+	router.NotFound(func(rw web.ResponseWriter, req *web.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(rw).Encode(restResult{Error: "Openchain CA endpoint not found."})
+	})
+	return router
+}
+
+// Start serves the REST interface on server.rest.address, with the TLS
+// certificate of the gRPC interface.
+//
+func (s *RESTServer) Start() error {
+	certFile := viper.GetString("server.tls.cert.file")
+	keyFile := viper.GetString("server.tls.key.file")
+	if certFile == "" || keyFile == "" {
+		return errors.New("The REST interface requires server.tls.cert.file and server.tls.key.file to be set")
+	}
+
+	srv := &http.Server{
+		Addr:      viper.GetString("server.rest.address"),
+		Handler:   s.Handler(),
+		TLSConfig: &tls.Config{ClientAuth: tls.RequestClientCert},
+	}
+	Info.Printf("Starting the REST interface on %s", srv.Addr)
+	return srv.ListenAndServeTLS(certFile, keyFile)
+}
+
+func writeRESTError(rw web.ResponseWriter, status int, err error) {
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(restResult{Error: err.Error()})
+}
+
+// authenticate identifies the member by the enrollment certificate it
+// presented as TLS client certificate, the TLS handshake proving the
+// possession of its key.
+//
+func (c *restContext) authenticate(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		writeRESTError(rw, http.StatusUnauthorized, errors.New("An enrollment certificate is required as TLS client certificate."))
+		return
+	}
+
+	cert := req.TLS.PeerCertificates[0]
+	id, _, err := c.server.eca.readCertificateOwner(cert.Raw)
+	if err != nil || cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		writeRESTError(rw, http.StatusUnauthorized, errors.New("The TLS client certificate is not an enrollment certificate issued by the ECA."))
+		return
+	}
+	if time.Now().After(cert.NotAfter) || c.server.eca.isRevoked(cert.SerialNumber) {
+		writeRESTError(rw, http.StatusUnauthorized, errors.New("The enrollment certificate has expired or has been revoked."))
+		return
+	}
+
+	Trace.Printf("REST request of %s", id)
+	c.id = id
+	c.cert = cert
+	next(rw, req)
+}
+
+// ReadCACertificates returns the PEM encoded certificates of the ECA and TCA,
+// each followed by the certificates of its issuers.
+//
+func (c *restContext) ReadCACertificates(rw web.ResponseWriter, req *web.Request) {
+	json.NewEncoder(rw).Encode(map[string]string{
+		"eca": encodeCertificateChain(c.server.eca.CA),
+		"tca": encodeCertificateChain(c.server.tca.CA),
+	})
+}
+
+func encodeCertificateChain(ca *CA) string {
+	cooked := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.raw})
+	for _, raw := range ca.chain {
+		cooked = append(cooked, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw})...)
+	}
+	return string(cooked)
+}
+
+// Register registers a new member with the authenticated member as registrar,
+// and returns its enrollment secret.
+//
+func (c *restContext) Register(rw web.ResponseWriter, req *web.Request) {
+	Trace.Println("REST Register")
+
+	var in RegisterRequest
+	if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+		writeRESTError(rw, http.StatusBadRequest, fmt.Errorf("Invalid registration request: %s", err))
+		return
+	}
+	role, ok := pb.Role_value[strings.ToUpper(in.Role)]
+	if in.ID == "" || !ok {
+		writeRESTError(rw, http.StatusBadRequest, errors.New("Invalid registration request: an id and a role among client, peer, validator and auditor are required."))
+		return
+	}
+
+	// the registrar metadata, as recorded for gRPC registrations
+	metadata, err := json.Marshal(pb.RegisterUserReq{Registrar: &pb.Registrar{Roles: in.Roles, DelegateRoles: in.DelegateRoles}})
+	if err != nil {
+		writeRESTError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	tok, err := c.server.eca.registerUser(in.ID, in.Affiliation, pb.Role(role), c.id, string(metadata))
+	c.server.eca.audit(auditRegistration, c.id, in.ID, describeRegistration(pb.Role(role), in.Affiliation), err)
+	if err != nil {
+		writeRESTError(rw, http.StatusForbidden, err)
+		return
+	}
+
+	Info.Printf("Registered %s through the REST interface, registrar %s", in.ID, c.id)
+	json.NewEncoder(rw).Encode(RegisterResponse{Secret: tok})
+}
+
+// Enroll issues the enrollment certificate pair of the member authenticated
+// with its enrollment ID and secret, for the keys of the signing requests.
+//
+func (c *restContext) Enroll(rw web.ResponseWriter, req *web.Request) {
+	Trace.Println("REST Enroll")
+
+	id, secret, ok := req.BasicAuth()
+	if !ok {
+		rw.Header().Set("WWW-Authenticate", `Basic realm="membersrvc"`)
+		writeRESTError(rw, http.StatusUnauthorized, errors.New("The enrollment ID and secret are required."))
+		return
+	}
+
+	client := comm.HTTPClientIdentity(req.Request)
+	if err := c.server.eca.admitEnrollment(id, client); err != nil {
+		if limited, ok := err.(*comm.RateLimitedError); ok {
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+		}
+		writeRESTError(rw, http.StatusTooManyRequests, err)
+		return
+	}
+	if err := c.server.eca.registerFromToken(id, []byte(secret)); err != nil {
+		c.server.eca.enrollmentFailed(id, client)
+		writeRESTError(rw, http.StatusUnauthorized, err)
+		return
+	}
+	if err := c.server.eca.registerFromDirectory(id, []byte(secret)); err != nil {
+		c.server.eca.enrollmentFailed(id, client)
+		writeRESTError(rw, http.StatusUnauthorized, err)
+		return
+	}
+
+	var tok, prev []byte
+	var role, state int
+	var enrollID string
+	err := c.server.eca.readUser(id).Scan(&role, &tok, &state, &prev, &enrollID)
+	if err != nil || state != 0 || subtle.ConstantTimeCompare(tok, []byte(secret)) != 1 {
+		Trace.Printf("id or token mismatch: id=%s\n", id)
+		if err == nil {
+			c.server.eca.enrollmentFailed(id, client)
+		}
+		err = errors.New("Identity or token does not match.")
+		c.server.eca.audit(auditEnrollment, id, id, "", err)
+		writeRESTError(rw, http.StatusUnauthorized, err)
+		return
+	}
+
+	var in EnrollRequest
+	if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+		writeRESTError(rw, http.StatusBadRequest, fmt.Errorf("Invalid enrollment request: %s", err))
+		return
+	}
+	skey, err := parseCertificateRequest(in.Sign)
+	if err != nil {
+		writeRESTError(rw, http.StatusBadRequest, err)
+		return
+	}
+	ekey, err := parseCertificateRequest(in.Enc)
+	if err != nil {
+		writeRESTError(rw, http.StatusBadRequest, err)
+		return
+	}
+	if _, ok := ekey.(*ecdsa.PublicKey); !ok {
+		writeRESTError(rw, http.StatusBadRequest, errors.New("Unsupported (encryption) key type."))
+		return
+	}
+
+	ecap := &ECAP{c.server.eca}
+	resp, err := ecap.enroll(id, enrollID, role, skey, ekey)
+	if err != nil {
+		c.server.eca.audit(auditEnrollment, id, id, "", err)
+		writeRESTError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	c.server.eca.enrollmentSucceeded(id)
+	c.server.eca.audit(auditEnrollment, id, id, describeCertificates(resp.Certs.Sign, resp.Certs.Enc), nil)
+
+	Info.Printf("Enrolled %s through the REST interface", id)
+	json.NewEncoder(rw).Encode(EnrollResponse{
+		Sign:    string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: resp.Certs.Sign})),
+		Enc:     string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: resp.Certs.Enc})),
+		Chain:   resp.Chain.Tok,
+		Pkchain: resp.Pkchain,
+	})
+}
+
+// certificateRequest is the outer structure of a PKCS#10 certificate signing
+// request, see RFC 2986
+type certificateRequest struct {
+	Info               asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+}
+
+// parseCertificateRequest parses a PEM encoded certificate signing request,
+// and returns its key once its signature proved the possession of the key.
+//
+func parseCertificateRequest(raw string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(raw))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("A PEM encoded certificate signing request is required.")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the key types the gRPC interface accepts, crypto/x509 neither
+	// parses Ed25519 keys nor checks Ed25519 signatures before Go 1.13
+	key, err := primitives.DERToPublicKey(csr.RawSubjectPublicKeyInfo)
+	if err != nil {
+		return nil, errors.New("Unsupported (signing) key type.")
+	}
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if err := csr.CheckSignature(); err != nil {
+			return nil, fmt.Errorf("Invalid certificate signing request: %s", err)
+		}
+		return parseSigningKey(&pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: csr.RawSubjectPublicKeyInfo})
+	case ed25519.PublicKey:
+		if err := checkEd25519RequestSignature(block.Bytes, key); err != nil {
+			return nil, fmt.Errorf("Invalid certificate signing request: %s", err)
+		}
+		return parseSigningKey(&pb.PublicKey{Type: pb.CryptoType_ED25519, Key: csr.RawSubjectPublicKeyInfo})
+	}
+	return nil, errors.New("Unsupported (signing) key type.")
+}
+
+// checkEd25519RequestSignature checks that the certificate signing request
+// der is signed with the Ed25519 key it holds.
+//
+func checkEd25519RequestSignature(der []byte, key ed25519.PublicKey) error {
+	var req certificateRequest
+	if rest, err := asn1.Unmarshal(der, &req); err != nil {
+		return err
+	} else if len(rest) != 0 {
+		return errors.New("trailing data after the request")
+	}
+	if !primitives.IsEd25519SignatureAlgorithm(req.SignatureAlgorithm) {
+		return errors.New("the request is not signed with its Ed25519 key")
+	}
+	ok, err := primitives.Ed25519Verify(key, req.Info.FullBytes, req.Signature.RightAlign())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+// CreateTCerts issues a set of TCerts for the enrollment certificate the
+// member authenticated with.
+//
+func (c *restContext) CreateTCerts(rw web.ResponseWriter, req *web.Request) {
+	Trace.Println("REST CreateTCerts")
+
+	var in TCertRequest
+	if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+		writeRESTError(rw, http.StatusBadRequest, fmt.Errorf("Invalid TCert request: %s", err))
+		return
+	}
+
+	setReq := &pb.TCertCreateSetReq{
+		Ts:  &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0},
+		Id:  &pb.Identity{Id: c.id},
+		Num: in.Num,
+	}
+	for _, name := range in.Attributes {
+		setReq.Attributes = append(setReq.Attributes, &pb.TCertAttribute{AttributeName: name})
+	}
+
+	tcap := &TCAP{c.server.tca}
+	resp, err := tcap.issueCertificateSet(c.cert.Raw, setReq)
+	c.server.eca.audit(auditTCerts, c.id, c.id, describeCertificateSet(setReq, resp), err)
+	if err != nil {
+		writeRESTError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	out := TCertResponse{Key: resp.Certs.Key}
+	for _, tcert := range resp.Certs.Certs {
+		out.Certs = append(out.Certs, TCertEntry{
+			Cert:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tcert.Cert})),
+			Prek0: tcert.Prek0,
+		})
+	}
+	json.NewEncoder(rw).Encode(out)
+}
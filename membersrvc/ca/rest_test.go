@@ -0,0 +1,206 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"golang.org/x/crypto/ed25519"
+)
+
+func newRESTTestServer() *httptest.Server {
+	server := httptest.NewUnstartedServer(NewRESTServer(eca, tca).Handler())
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	return server
+}
+
+// newRESTClient returns a client authenticating with the enrollment
+// certificate cert and its key, or an anonymous client when cert is nil.
+func newRESTClient(cert []byte, key crypto.PrivateKey) *http.Client {
+	config := &tls.Config{InsecureSkipVerify: true}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{{Certificate: [][]byte{cert}, PrivateKey: key}}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+}
+
+func postREST(client *http.Client, req *http.Request, out interface{}) (int, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+}
+
+func newRESTRequest(url string, in interface{}) *http.Request {
+	body, _ := json.Marshal(in)
+	req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
+	return req
+}
+
+func newCertificateRequest(t *testing.T, key crypto.Signer) string {
+	raw, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: raw}))
+}
+
+// newEd25519CertificateRequest returns a certificate signing request for the
+// public key pub signed with key, encoded by hand as crypto/x509 only creates
+// Ed25519 requests from Go 1.13 on
+func newEd25519CertificateRequest(t *testing.T, pub ed25519.PublicKey, key ed25519.PrivateKey) string {
+	oidEd25519 := asn1.ObjectIdentifier{1, 3, 101, 112}
+	spki, err := asn1.Marshal(struct {
+		Algo      pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}{pkix.AlgorithmIdentifier{Algorithm: oidEd25519}, asn1.BitString{Bytes: pub, BitLength: 8 * len(pub)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := asn1.Marshal(pkix.RDNSequence{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := asn1.Marshal(struct {
+		Version    int
+		Subject    asn1.RawValue
+		PublicKey  asn1.RawValue
+		Attributes asn1.RawValue
+	}{0, asn1.RawValue{FullBytes: subject}, asn1.RawValue{FullBytes: spki}, asn1.RawValue{FullBytes: []byte{0xa0, 0x00}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := ed25519.Sign(key, info)
+	raw, err := asn1.Marshal(certificateRequest{
+		Info:               asn1.RawValue{FullBytes: info},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidEd25519},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: raw}))
+}
+
+func TestParseEd25519CertificateRequest(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := parseCertificateRequest(newEd25519CertificateRequest(t, pub, priv))
+	if err != nil {
+		t.Fatalf("Failed to parse an Ed25519 certificate signing request: [%s]", err)
+	}
+	if parsed, ok := key.(ed25519.PublicKey); !ok || !bytes.Equal(parsed, pub) {
+		t.Fatalf("Expected the Ed25519 key of the request, got %T", key)
+	}
+
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseCertificateRequest(newEd25519CertificateRequest(t, pub, other)); err == nil {
+		t.Fatal("A request not signed with its Ed25519 key should be refused")
+	}
+}
+
+func TestRESTEnrollment(t *testing.T) {
+	if testAdmin.enrollPrivKey == nil {
+		if err := enrollUser(&testAdmin); err != nil {
+			t.Fatal(err)
+		}
+	}
+	adminCert, err := eca.readCertificateByKeyUsage(testAdmin.enrollID, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newRESTTestServer()
+	defer server.Close()
+
+	registration := RegisterRequest{ID: "testRESTUser", Affiliation: "institution_a", Role: "client"}
+	var registered RegisterResponse
+	status, err := postREST(newRESTClient(nil, nil), newRESTRequest(server.URL+"/registrar", registration), &registered)
+	if err != nil || status != http.StatusUnauthorized {
+		t.Fatalf("A registration without enrollment certificate should be rejected, status %d: [%v]", status, err)
+	}
+	status, err = postREST(newRESTClient(adminCert, testAdmin.enrollPrivKey), newRESTRequest(server.URL+"/registrar", registration), &registered)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Failed to register, status %d: [%v]", status, err)
+	}
+
+	signPriv, _ := primitives.NewECDSAKey()
+	encPriv, _ := primitives.NewECDSAKey()
+	enrollment := EnrollRequest{Sign: newCertificateRequest(t, signPriv), Enc: newCertificateRequest(t, encPriv)}
+
+	var enrolled EnrollResponse
+	req := newRESTRequest(server.URL+"/enrollment", enrollment)
+	req.SetBasicAuth(registration.ID, "bad secret")
+	status, err = postREST(newRESTClient(nil, nil), req, &enrolled)
+	if err != nil || status != http.StatusUnauthorized {
+		t.Fatalf("An enrollment with a bad secret should be rejected, status %d: [%v]", status, err)
+	}
+
+	req = newRESTRequest(server.URL+"/enrollment", enrollment)
+	req.SetBasicAuth(registration.ID, registered.Secret)
+	status, err = postREST(newRESTClient(nil, nil), req, &enrolled)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Failed to enroll, status %d: [%v]", status, err)
+	}
+	block, _ := pem.Decode([]byte(enrolled.Sign))
+	cert, err := primitives.DERToX509Certificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := primitives.CheckCertPKAgainstSK(cert, signPriv); err != nil {
+		t.Fatalf("The enrollment certificate does not certify the key of the request: [%s]", err)
+	}
+
+	req = newRESTRequest(server.URL+"/enrollment", enrollment)
+	req.SetBasicAuth(registration.ID, registered.Secret)
+	status, err = postREST(newRESTClient(nil, nil), req, &enrolled)
+	if err != nil || status != http.StatusUnauthorized {
+		t.Fatalf("The enrollment secret should only be used once, status %d: [%v]", status, err)
+	}
+
+	var tcerts TCertResponse
+	status, err = postREST(newRESTClient(block.Bytes, signPriv), newRESTRequest(server.URL+"/tcerts", TCertRequest{Num: 2}), &tcerts)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Failed to request TCerts, status %d: [%v]", status, err)
+	}
+	if len(tcerts.Certs) != 2 || len(tcerts.Key) == 0 {
+		t.Fatalf("Expected 2 TCerts and their key, got %d", len(tcerts.Certs))
+	}
+}
@@ -24,8 +24,11 @@ import (
 	"encoding/asn1"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"math/big"
 
+	"github.com/hyperledger/fabric/core/crypto/idemix"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
 	"google.golang.org/grpc"
@@ -56,6 +59,8 @@ type TCA struct {
 	rootPreKey []byte
 	preKeys    map[string][]byte
 	gRPCServer *grpc.Server
+	// The key of the anonymous credentials, nil unless enabled
+	issuerKey *idemix.IssuerKey
 }
 
 // TCertSet contains relevant information of a set of tcerts
@@ -78,12 +83,17 @@ func initializeTCATables(db *sql.DB) error {
 		return err
 	}
 
+	if _, err = db.Exec("CREATE TABLE IF NOT EXISTS TCertificates (row INTEGER PRIMARY KEY, enrollmentID VARCHAR(64), timestamp INTEGER, serial VARCHAR(64))"); err != nil {
+		return err
+	}
+
 	return err
 }
 
 // NewTCA sets up a new TCA.
 func NewTCA(eca *ECA) *TCA {
-	tca := &TCA{NewCA("tca", initializeTCATables), eca, nil, nil, nil, nil}
+	tca := &TCA{NewCA("tca", initializeTCATables), eca, nil, nil, nil, nil, nil}
+	eca.tca = tca
 
 	err := tca.readHmacKey()
 	if err != nil {
@@ -99,6 +109,11 @@ func NewTCA(eca *ECA) *TCA {
 	if err != nil {
 		Panic.Panicln(err)
 	}
+
+	err = tca.readIssuerKey()
+	if err != nil {
+		Panic.Panicln(err)
+	}
 	return tca
 }
 
@@ -239,6 +254,85 @@ func (tca *TCA) startTCAA(srv *grpc.Server) {
 	Info.Println("TCA ADMIN gRPC API server started")
 }
 
+// revokeCertificate revokes the TCert of a revocation request, provided
+// that authorize lets the requester revoke the certificates of its owner.
+func (tca *TCA) revokeCertificate(in *pb.TCertRevokeReq, authorize func(requester, owner string) error) (_ *pb.CAStatus, err error) {
+	if in.Id == nil || in.Cert == nil {
+		return nil, errors.New("Invalid revocation request.")
+	}
+
+	var owner string
+	defer func() {
+		tca.eca.audit(auditRevocation, in.Id.Id, owner, "TCert of "+describeCertificates(in.Cert.Cert), err)
+	}()
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tca.eca.checkSignature(in.Id.Id, in, sig); err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(in.Cert.Cert)
+	if err != nil {
+		return nil, err
+	}
+	if err = cert.CheckSignatureFrom(tca.cert); err != nil {
+		return nil, errors.New("The certificate was not issued by the TCA.")
+	}
+	owner, err = tca.readCertificateOwner(cert.SerialNumber)
+	if err != nil {
+		return nil, errors.New("The certificate was not issued by the TCA.")
+	}
+	if err = authorize(in.Id.Id, owner); err != nil {
+		return nil, err
+	}
+
+	if err = tca.revokeCertificates(owner, cert.SerialNumber); err != nil {
+		return nil, err
+	}
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// revokeCertificateSet revokes the TCert set of a revocation request,
+// provided that authorize lets the requester revoke the certificates of its
+// owner.
+func (tca *TCA) revokeCertificateSet(in *pb.TCertRevokeSetReq, authorize func(requester, owner string) error) (_ *pb.CAStatus, err error) {
+	if in.Id == nil {
+		return nil, errors.New("Invalid revocation request.")
+	}
+
+	var owner string
+	var timestamp int64
+	defer func() {
+		tca.eca.audit(auditRevocation, in.Id.Id, owner, fmt.Sprintf("TCert set %d", timestamp), err)
+	}()
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tca.eca.checkSignature(in.Id.Id, in, sig); err != nil {
+		return nil, err
+	}
+
+	owner = in.Id.Id
+	if in.Owner != nil && in.Owner.Id != "" {
+		owner = in.Owner.Id
+	}
+	if err = authorize(in.Id.Id, owner); err != nil {
+		return nil, err
+	}
+
+	if in.Ts != nil && in.Ts.Seconds != 0 {
+		timestamp = in.Ts.Seconds
+	} else if timestamp, err = tca.readLatestCertificateSet(owner); err != nil {
+		return nil, errors.New("No certificate set was issued to " + owner + ".")
+	}
+
+	if err = tca.revokeCertificateSets(owner, timestamp); err != nil {
+		return nil, err
+	}
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
 func (tca *TCA) getCertificateSets(enrollmentID string) ([]*TCertSet, error) {
 	mutex.RLock()
 	defer mutex.RUnlock()
@@ -286,3 +380,79 @@ func (tca *TCA) persistCertificateSet(enrollmentID string, timestamp int64, nonc
 func (tca *TCA) retrieveCertificateSets(enrollmentID string) (*sql.Rows, error) {
 	return tca.db.Query("SELECT enrollmentID, timestamp, nonce, kdfkey FROM TCertificateSets WHERE enrollmentID=?", enrollmentID)
 }
+
+func (tca *TCA) persistCertificateSerials(enrollmentID string, timestamp int64, serials []*big.Int) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, serial := range serials {
+		if _, err := tca.db.Exec("INSERT INTO TCertificates (enrollmentID, timestamp, serial) VALUES (?, ?, ?)", enrollmentID, timestamp, serial.String()); err != nil {
+			Error.Println(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// readCertificateOwner returns the member the TCert with the given serial
+// number was issued to.
+func (tca *TCA) readCertificateOwner(serial *big.Int) (string, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var enrollmentID string
+	err := tca.db.QueryRow("SELECT enrollmentID FROM TCertificates WHERE serial=?", serial.String()).Scan(&enrollmentID)
+	return enrollmentID, err
+}
+
+// revokeCertificateSets revokes the TCerts issued to enrollmentID, either
+// those of the set created at timestamp, or all of them if none is given.
+func (tca *TCA) revokeCertificateSets(enrollmentID string, timestamp ...int64) error {
+	mutex.RLock()
+	var rows *sql.Rows
+	var err error
+	if len(timestamp) > 0 {
+		rows, err = tca.db.Query("SELECT serial FROM TCertificates WHERE enrollmentID=? AND timestamp=?", enrollmentID, timestamp[0])
+	} else {
+		rows, err = tca.db.Query("SELECT serial FROM TCertificates WHERE enrollmentID=?", enrollmentID)
+	}
+	if err != nil {
+		mutex.RUnlock()
+		return err
+	}
+
+	var serials []*big.Int
+	for rows.Next() {
+		var serial string
+		if err = rows.Scan(&serial); err != nil {
+			break
+		}
+		number, ok := new(big.Int).SetString(serial, 10)
+		if !ok {
+			err = errors.New("Invalid serial number " + serial + " in the TCertificates table")
+			break
+		}
+		serials = append(serials, number)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close()
+	mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return tca.revokeCertificates(enrollmentID, serials...)
+}
+
+// readLatestCertificateSet returns the timestamp of the last TCert set
+// issued to enrollmentID.
+func (tca *TCA) readLatestCertificateSet(enrollmentID string) (int64, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var timestamp int64
+	err := tca.db.QueryRow("SELECT timestamp FROM TCertificateSets WHERE enrollmentID=? ORDER BY row DESC LIMIT 1", enrollmentID).Scan(&timestamp)
+	return timestamp, err
+}
@@ -18,12 +18,16 @@ package ca
 
 import (
 	"crypto/ecdsa"
+	"crypto/rand"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/pem"
 	"fmt"
 	"google/protobuf"
 	"io/ioutil"
+	"math/big"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -31,8 +35,11 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/crypto/attributes"
+	"github.com/hyperledger/fabric/core/crypto/idemix"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
 )
 
 func TestNewTCA(t *testing.T) {
@@ -120,6 +127,196 @@ func TestCreateCertificateSet(t *testing.T) {
 	}
 }
 
+func TestCreateCertificateSetAffiliationPolicy(t *testing.T) {
+	tca, err := initTCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// test_user0 belongs to bank_a, a member of banks
+	viper.Set("tca.affiliations", map[string]interface{}{
+		"banks": map[string]interface{}{
+			"attributes": []string{"company"},
+			"chaincodes": []string{"mycc"},
+		},
+	})
+	defer viper.Set("tca.affiliations", map[string]interface{}{})
+
+	ecertRaw, priv, err := loadECertAndEnrollmentPrivateKey("test_user0", "MS9qrN8hFjlE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcap := &TCAP{tca}
+
+	req, err := buildCertificateSetRequest("test_user0", priv, 1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Attributes = []*protos.TCertAttribute{{AttributeName: "position"}}
+	req.Sig = nil
+	rawReq, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, s, err := primitives.ECDSASignDirect(priv, rawReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	req.Sig = &protos.Signature{Type: protos.CryptoType_ECDSA, R: R, S: S}
+	if _, err = tcap.createCertificateSet(context.Background(), ecertRaw, req); err == nil || !strings.Contains(err.Error(), "may not be embedded") {
+		t.Fatalf("Expected an attribute not allowed to the affiliation to be refused, got %v", err)
+	}
+
+	req, err = buildCertificateSetRequest("test_user0", priv, 1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := tcap.createCertificateSet(context.Background(), ecertRaw, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcert, err := x509.ParseCertificate(resp.Certs.Certs[0].Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := primitives.GetCriticalExtension(tcert, primitives.TCertChaincodes)
+	if err != nil {
+		t.Fatal("Expected the TCert to be restricted to the chaincodes of the affiliation")
+	}
+	var chaincodes []string
+	if _, err = asn1.Unmarshal(raw, &chaincodes); err != nil {
+		t.Fatal(err)
+	}
+	if len(chaincodes) != 1 || chaincodes[0] != "mycc" {
+		t.Fatalf("Expected the TCert to be restricted to [mycc], got %v", chaincodes)
+	}
+}
+
+func TestGenerateExtensionsWithAttributesValidity(t *testing.T) {
+	ecertRaw, _, err := loadECertAndEnrollmentPrivateKey("test_user0", "MS9qrN8hFjlE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecert, err := x509.ParseCertificate(ecertRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validTo := time.Now().Add(time.Hour)
+	attrs := []*protos.ACAAttribute{
+		{AttributeName: "company", AttributeValue: []byte("ACompany")},
+		{AttributeName: "position", AttributeValue: []byte("Software Engineer"), ValidTo: &google_protobuf.Timestamp{Seconds: validTo.Unix()}},
+	}
+	extensions, _, err := (&TCAP{tca}).generateExtensions(big.NewInt(1), []byte("tidx"), ecert, attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcert := &x509.Certificate{Extensions: extensions}
+	validity, err := attributes.ReadAttributesValidity(tcert, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(validity) != 1 || validity["position"].Unix() != validTo.Unix() {
+		t.Fatalf("Expected only position to expire, at %v, got %v", validTo, validity)
+	}
+	if err = attributes.CheckAttributeValidity(validity, "position", validTo); err == nil {
+		t.Fatal("Expected position to expire")
+	}
+}
+
+func TestCreateCredential(t *testing.T) {
+	params := idemix.DefaultParams
+	params.Modulus = 512
+	isk, err := idemix.NewIssuerKey(params, []string{"company", "position", "role"}, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecertRaw, priv, err := loadECertAndEnrollmentPrivateKey("test_user0", "MS9qrN8hFjlE")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &protos.CredentialCreateReq{
+		Ts: &google_protobuf.Timestamp{Seconds: time.Now().Unix()},
+		Id: &protos.Identity{Id: "test_user0"},
+	}
+	credReq, blinding, err := idemix.NewCredentialRequest(isk.Public(), CredentialNonce(req), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Request, err = credReq.Bytes(); err != nil {
+		t.Fatal(err)
+	}
+	rawReq, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, s, err := primitives.ECDSASignDirect(priv, rawReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	req.Sig = &protos.Signature{Type: protos.CryptoType_ECDSA, R: R, S: S}
+
+	tcap := &TCAP{tca}
+	resp, err := tcap.createCredential(isk, ecertRaw, req)
+	if err != nil {
+		t.Fatalf("Failed issuing the credential: %s", err)
+	}
+	expected := []string{"ACompany", "Software Engineer", ""}
+	if fmt.Sprint(resp.Values) != fmt.Sprint(expected) {
+		t.Fatalf("Expected the credential on %v, got %v", expected, resp.Values)
+	}
+	sig, err := idemix.ParseIssuedSignature(resp.Signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = idemix.NewCredential(isk.Public(), blinding, sig, resp.Values); err != nil {
+		t.Fatalf("Expected a valid credential, got %s", err)
+	}
+
+	// The proof of the request is bound to its identity
+	req.Id = &protos.Identity{Id: "test_user1"}
+	if _, err = tcap.createCredential(isk, ecertRaw, req); err == nil {
+		t.Fatal("Expected a request with another identity to be refused")
+	}
+}
+
+func TestReadIssuerKey(t *testing.T) {
+	tcap := &TCAP{tca}
+	if _, err := tcap.ReadIssuerKey(context.Background(), &protos.Empty{}); err == nil {
+		t.Fatal("Expected no issuer key without anonymous credentials")
+	}
+
+	params := idemix.DefaultParams
+	params.Modulus = 512
+	isk, err := idemix.NewIssuerKey(params, []string{"role"}, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tca.issuerKey = isk
+	defer func() { tca.issuerKey = nil }()
+
+	key, err := tcap.ReadIssuerKey(context.Background(), &protos.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(tca.raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verifySignature(cert.PublicKey, key.Key, key.Sig) {
+		t.Fatal("Expected the issuer key to be signed by the TCA")
+	}
+	if _, err = idemix.ParseIssuerPublicKey(key.Key); err != nil {
+		t.Fatalf("Failed parsing the issuer key: %s", err)
+	}
+}
+
 func loadECertAndEnrollmentPrivateKey(enrollmentID string, password string) ([]byte, *ecdsa.PrivateKey, error) {
 	cooked, err := ioutil.ReadFile("./test_resources/key_" + enrollmentID + ".dump")
 	if err != nil {
@@ -163,7 +360,7 @@ func initTCA() (*TCA, error) {
 		return nil, fmt.Errorf("Could not create a new ECA")
 	}
 
-	aca := NewACA()
+	aca := NewACA(eca)
 	if aca == nil {
 		return nil, fmt.Errorf("Could not create a new ACA")
 	}
@@ -28,23 +28,42 @@ type TCAA struct {
 	tca *TCA
 }
 
-// RevokeCertificate revokes a certificate from the TCA.  Not yet implemented.
-func (tcaa *TCAA) RevokeCertificate(context.Context, *pb.TCertRevokeReq) (*pb.CAStatus, error) {
+// RevokeCertificate revokes a certificate from the TCA.  Admins can revoke
+// the certificates of the members they may register.
+func (tcaa *TCAA) RevokeCertificate(ctx context.Context, in *pb.TCertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAA:RevokeCertificate")
 
-	return nil, errors.New("not yet implemented")
+	return tcaa.tca.revokeCertificate(in, tcaa.tca.eca.canRevoke)
 }
 
-// RevokeCertificateSet revokes a certificate set from the TCA.  Not yet implemented.
-func (tcaa *TCAA) RevokeCertificateSet(context.Context, *pb.TCertRevokeSetReq) (*pb.CAStatus, error) {
+// RevokeCertificateSet revokes the certificate set of in.Owner from the TCA.
+// Admins can revoke the certificates of the members they may register.
+func (tcaa *TCAA) RevokeCertificateSet(ctx context.Context, in *pb.TCertRevokeSetReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAA:RevokeCertificateSet")
 
-	return nil, errors.New("not yet implemented")
+	return tcaa.tca.revokeCertificateSet(in, tcaa.tca.eca.canRevoke)
 }
 
-// PublishCRL requests the creation of a certificate revocation list from the TCA.  Not yet implemented.
-func (tcaa *TCAA) PublishCRL(context.Context, *pb.TCertCRLReq) (*pb.CAStatus, error) {
+// PublishCRL issues a new certificate revocation list and pushes it to the
+// peers watching the TCA.
+func (tcaa *TCAA) PublishCRL(ctx context.Context, in *pb.TCertCRLReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAA:CreateCRL")
 
-	return nil, errors.New("not yet implemented")
+	if in.Id == nil {
+		return nil, errors.New("Invalid CRL request.")
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tcaa.tca.eca.checkSignature(in.Id.Id, in, sig); err != nil {
+		return nil, err
+	}
+	if err := tcaa.tca.eca.checkRegistrar(in.Id.Id); err != nil {
+		return nil, err
+	}
+
+	if _, err := tcaa.tca.publishCRL(); err != nil {
+		return nil, err
+	}
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
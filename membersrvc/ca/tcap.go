@@ -46,11 +46,11 @@ type TCAP struct {
 	tca *TCA
 }
 
-// ReadCACertificate reads the certificate of the TCA.
+// ReadCACertificate reads the certificate of the TCA, along with the certificates of its issuers.
 func (tcap *TCAP) ReadCACertificate(ctx context.Context, in *pb.Empty) (*pb.Cert, error) {
 	Trace.Println("grpc TCAP:ReadCACertificate")
 
-	return &pb.Cert{Cert: tcap.tca.raw}, nil
+	return &pb.Cert{Cert: tcap.tca.raw, Chain: tcap.tca.chain}, nil
 }
 
 func (tcap *TCAP) selectValidAttributes(certRaw []byte) ([]*pb.ACAAttribute, error) {
@@ -146,9 +146,13 @@ func (tcap *TCAP) requestAttributes(id string, ecert []byte, attrs []*pb.TCertAt
 }
 
 // CreateCertificateSet requests the creation of a new transaction certificate set by the TCA.
-func (tcap *TCAP) CreateCertificateSet(ctx context.Context, in *pb.TCertCreateSetReq) (*pb.TCertCreateSetResp, error) {
+func (tcap *TCAP) CreateCertificateSet(ctx context.Context, in *pb.TCertCreateSetReq) (resp *pb.TCertCreateSetResp, err error) {
 	Trace.Println("grpc TCAP:CreateCertificateSet")
 
+	defer func() {
+		tcap.tca.eca.audit(auditTCerts, identityOf(in.Id), identityOf(in.Id), describeCertificateSet(in, resp), err)
+	}()
+
 	id := in.Id.Id
 	raw, err := tcap.tca.eca.readCertificateByKeyUsage(id, x509.KeyUsageDigitalSignature)
 	if err != nil {
@@ -159,38 +163,74 @@ func (tcap *TCAP) CreateCertificateSet(ctx context.Context, in *pb.TCertCreateSe
 }
 
 func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.TCertCreateSetReq) (*pb.TCertCreateSetResp, error) {
+	_, pub, err := tcap.parseEnrollmentCertificate(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+
+	rawReq, _ := proto.Marshal(in)
+	if !verifySignature(pub, rawReq, sig) {
+		return nil, errors.New("signature does not verify")
+	}
+
+	return tcap.issueCertificateSet(raw, in)
+}
+
+// parseEnrollmentCertificate parses the enrollment certificate the TCerts of a set are issued
+// for, and returns its ECDSA key the keys of the TCerts are derived from.
+func (tcap *TCAP) parseEnrollmentCertificate(raw []byte) (*x509.Certificate, *ecdsa.PublicKey, error) {
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tcap.tca.eca.isRevoked(cert.SerialNumber) {
+		return nil, nil, errors.New("The enrollment certificate has been revoked.")
+	}
+
+	// The keys of the TCerts are derived from the enrollment key
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, nil, errors.New("TCerts can only be issued for ECDSA enrollment keys.")
+	}
+	return cert, pub, nil
+}
+
+// issueCertificateSet issues the TCerts of the authenticated request in for the enrollment
+// certificate raw.
+func (tcap *TCAP) issueCertificateSet(raw []byte, in *pb.TCertCreateSetReq) (*pb.TCertCreateSetResp, error) {
 	var attrs = []*pb.ACAAttribute{}
-	var err error
 	var id = in.Id.Id
 	var timestamp = in.Ts.Seconds
 	const TCERT_SUBJECT_COMMON_NAME_VALUE string = "Transaction Certificate"
 
-	if in.Attributes != nil && viper.GetBool("aca.enabled") {
-		attrs, err = tcap.requestAttributes(id, raw, in.Attributes)
-		if err != nil {
-			return nil, err
-		}
+	cert, pub, err := tcap.parseEnrollmentCertificate(raw)
+	if err != nil {
+		return nil, err
 	}
 
-	cert, err := x509.ParseCertificate(raw)
+	// The policy of the affiliation of the member, if any, restricts the
+	// attributes and the chaincodes of its TCerts
+	_, affiliation, _ := tcap.tca.eca.parseEnrollID(cert.Subject.CommonName)
+	policy, err := tcap.tca.readAffiliationPolicy(affiliation)
+	if err != nil {
+		return nil, err
+	}
+	if err = policy.checkAttributes(in.Attributes); err != nil {
+		return nil, err
+	}
+	chaincodes, err := policy.chaincodesExtension()
 	if err != nil {
 		return nil, err
 	}
 
-	pub := cert.PublicKey.(*ecdsa.PublicKey)
-
-	r, s := big.NewInt(0), big.NewInt(0)
-	r.UnmarshalText(in.Sig.R)
-	s.UnmarshalText(in.Sig.S)
-
-	//sig := in.Sig
-	in.Sig = nil
-
-	hash := primitives.NewHash()
-	raw, _ = proto.Marshal(in)
-	hash.Write(raw)
-	if ecdsa.Verify(pub, hash.Sum(nil), r, s) == false {
-		return nil, errors.New("signature does not verify")
+	if in.Attributes != nil && viper.GetBool("aca.enabled") {
+		attrs, err = tcap.requestAttributes(id, raw, in.Attributes)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Generate nonce for TCertIndex
@@ -210,6 +250,7 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 
 	// the batch of TCerts
 	var set []*pb.TCert
+	var serials []*big.Int
 
 	for i := 0; i < num; i++ {
 		tcertid := util.GenerateIntUUID()
@@ -248,6 +289,9 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 		if err != nil {
 			return nil, err
 		}
+		if chaincodes != nil {
+			extensions = append(extensions, *chaincodes)
+		}
 
 		spec := NewDefaultPeriodCertificateSpecWithCommonName(id, TCERT_SUBJECT_COMMON_NAME_VALUE, tcertid, &txPub, x509.KeyUsageDigitalSignature, extensions...)
 		if raw, err = tcap.tca.createCertificateFromSpec(spec, timestamp, kdfKey, false); err != nil {
@@ -256,9 +300,13 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 		}
 
 		set = append(set, &pb.TCert{Cert: raw, Prek0: preK0})
+		serials = append(serials, tcertid)
 	}
 
 	tcap.tca.persistCertificateSet(id, timestamp, nonce, kdfKey)
+	if err = tcap.tca.persistCertificateSerials(id, timestamp, serials); err != nil {
+		return nil, err
+	}
 
 	return &pb.TCertCreateSetResp{Certs: &pb.CertSet{Ts: in.Ts, Id: in.Id, Key: kdfKey, Certs: set}}, nil
 }
@@ -294,6 +342,7 @@ func (tcap *TCAP) generateExtensions(tcertid *big.Int, tidx []byte, enrollmentCe
 	attributeIdentifierIndex := 9
 	count := 0
 	attrsHeader := make(map[string]int)
+	attrsValidity := make(map[string]time.Time)
 	// Encrypt and append attrs to the extensions slice
 	for _, a := range attrs {
 		count++
@@ -303,6 +352,11 @@ func (tcap *TCAP) generateExtensions(tcertid *big.Int, tidx []byte, enrollmentCe
 		//Save the position of the attribute extension on the header.
 		attrsHeader[a.AttributeName] = count
 
+		//Save when the attribute expires, if it does.
+		if a.ValidTo != nil && (a.ValidTo.Seconds != 0 || a.ValidTo.Nanos != 0) {
+			attrsValidity[a.AttributeName] = time.Unix(a.ValidTo.Seconds, int64(a.ValidTo.Nanos))
+		}
+
 		if isEnabledAttributesEncryption() {
 			value, err = attributes.EncryptAttributeValuePK0(preK0, a.AttributeName, value)
 			if err != nil {
@@ -338,21 +392,66 @@ func (tcap *TCAP) generateExtensions(tcertid *big.Int, tidx []byte, enrollmentCe
 		extensions = append(extensions, pkix.Extension{Id: TCertAttributesHeaders, Critical: false, Value: headerValue})
 	}
 
+	// Append the validity of the attributes if any of them expires, so that
+	// validators refuse them once they have expired
+	if len(attrsValidity) > 0 {
+		validityValue, err := attributes.BuildAttributesValidity(attrsValidity)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isEnabledAttributesEncryption() {
+			validityValue, err = attributes.EncryptAttributeValuePK0(preK0, attributes.HeaderAttributeName, validityValue)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		extensions = append(extensions, pkix.Extension{Id: attributes.TCertAttributesValidity, Critical: false, Value: validityValue})
+	}
+
 	return extensions, preK0, nil
 }
 
-// RevokeCertificate revokes a certificate from the TCA.  Not yet implemented.
-func (tcap *TCAP) RevokeCertificate(context.Context, *pb.TCertRevokeReq) (*pb.CAStatus, error) {
+// RevokeCertificate revokes a certificate from the TCA.  Users can only
+// revoke their own certificates.
+func (tcap *TCAP) RevokeCertificate(ctx context.Context, in *pb.TCertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAP:RevokeCertificate")
 
-	return nil, errors.New("not yet implemented")
+	return tcap.tca.revokeCertificate(in, checkOwner)
 }
 
-// RevokeCertificateSet revokes a certificate set from the TCA.  Not yet implemented.
-func (tcap *TCAP) RevokeCertificateSet(context.Context, *pb.TCertRevokeSetReq) (*pb.CAStatus, error) {
+// RevokeCertificateSet revokes a certificate set from the TCA.  Users can
+// only revoke their own certificates.
+func (tcap *TCAP) RevokeCertificateSet(ctx context.Context, in *pb.TCertRevokeSetReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAP:RevokeCertificateSet")
 
-	return nil, errors.New("not yet implemented")
+	return tcap.tca.revokeCertificateSet(in, checkOwner)
+}
+
+// ReadCRL reads the certificate revocation list of the TCA.
+func (tcap *TCAP) ReadCRL(ctx context.Context, in *pb.Empty) (*pb.CRL, error) {
+	Trace.Println("grpc TCAP:ReadCRL")
+
+	raw, err := tcap.tca.readCRL()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CRL{Crl: raw}, nil
+}
+
+// WatchCRL streams the certificate revocation list of the TCA, and every
+// list it publishes thereafter.
+func (tcap *TCAP) WatchCRL(in *pb.Empty, stream pb.TCAP_WatchCRLServer) error {
+	Trace.Println("grpc TCAP:WatchCRL")
+
+	return tcap.tca.serveCRL(stream)
+}
+
+// checkOwner lets members revoke their own certificates only.
+func checkOwner(requester, owner string) error {
+	if requester != owner {
+		return errors.New("Access denied.")
+	}
+	return nil
 }
 
 func isEnabledAttributesEncryption() bool {
@@ -19,9 +19,14 @@ package ca
 import (
 	"crypto/ecdsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"database/sql"
+	"encoding/asn1"
 	"errors"
 	"math/big"
+	"net"
+	"strings"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
@@ -94,12 +99,12 @@ func (tlsca *TLSCA) Stop() error {
 	return err
 }
 
-// ReadCACertificate reads the certificate of the TLSCA.
+// ReadCACertificate reads the certificate of the TLSCA, along with the certificates of its issuers.
 //
 func (tlscap *TLSCAP) ReadCACertificate(ctx context.Context, in *pb.Empty) (*pb.Cert, error) {
 	Trace.Println("grpc TLSCAP:ReadCACertificate")
 
-	return &pb.Cert{Cert: tlscap.tlsca.raw}, nil
+	return &pb.Cert{Cert: tlscap.tlsca.raw, Chain: tlscap.tlsca.chain}, nil
 }
 
 // CreateCertificate requests the creation of a new enrollment certificate by the TLSCA.
@@ -132,12 +137,94 @@ func (tlscap *TLSCAP) CreateCertificate(ctx context.Context, in *pb.TLSCertCreat
 		return nil, errors.New("signature does not verify")
 	}
 
+	if len(in.Hosts) > 0 {
+		return tlscap.createHostCertificate(in, pub.(*ecdsa.PublicKey))
+	}
+
 	if raw, err = tlscap.tlsca.createCertificate(id, pub.(*ecdsa.PublicKey), x509.KeyUsageDigitalSignature, in.Ts.Seconds, nil); err != nil {
 		Error.Println(err)
 		return nil, err
 	}
 
-	return &pb.TLSCertCreateResp{Cert: &pb.Cert{Cert: raw}, RootCert: &pb.Cert{Cert: tlscap.tlsca.raw}}, nil
+	return &pb.TLSCertCreateResp{Cert: &pb.Cert{Cert: raw}, RootCert: &pb.Cert{Cert: tlscap.tlsca.raw, Chain: tlscap.tlsca.chain}}, nil
+}
+
+var (
+	oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtKeyUsage    = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidServerAuth     = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 1}
+	oidClientAuth     = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 2}
+)
+
+// createHostCertificate issues a certificate for the TLS servers and clients
+// of the hosts of the request in. The request must be signed with the key of
+// an enrollment certificate issued by the ECA, the certificate is issued to
+// its owner.
+//
+func (tlscap *TLSCAP) createHostCertificate(in *pb.TLSCertCreateReq, pub *ecdsa.PublicKey) (resp *pb.TLSCertCreateResp, err error) {
+	eca := tlscap.tlsca.eca
+	var owner string
+	defer func() {
+		eca.audit(auditTLSCert, owner, owner, strings.Join(in.Hosts, ","), err)
+	}()
+
+	cert, err := x509.ParseCertificate(in.EnrollCert)
+	if err != nil {
+		return nil, errors.New("Certificates for hosts require an enrollment certificate.")
+	}
+	if owner, _, err = eca.readCertificateOwner(cert.Raw); err != nil || cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return nil, errors.New("The certificate of the request is not an enrollment certificate issued by the ECA.")
+	}
+	if time.Now().After(cert.NotAfter) || eca.isRevoked(cert.SerialNumber) {
+		return nil, errors.New("The enrollment certificate has expired or has been revoked.")
+	}
+
+	sig := in.EnrollSig
+	in.EnrollSig = nil
+	raw, _ := proto.Marshal(in)
+	if sig == nil || !verifySignature(cert.PublicKey, raw, sig) {
+		return nil, errors.New("enrollment signature does not verify")
+	}
+
+	ext, err := hostExtensions(in.Hosts)
+	if err != nil {
+		return nil, err
+	}
+	if raw, err = tlscap.tlsca.createCertificate(owner, pub, x509.KeyUsageDigitalSignature, in.Ts.Seconds, nil, ext...); err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	return &pb.TLSCertCreateResp{Cert: &pb.Cert{Cert: raw}, RootCert: &pb.Cert{Cert: tlscap.tlsca.raw, Chain: tlscap.tlsca.chain}}, nil
+}
+
+// hostExtensions returns the extensions naming hosts, host names or IP
+// addresses, as the subject of a certificate for TLS servers and clients.
+//
+func hostExtensions(hosts []string) ([]pkix.Extension, error) {
+	var names []asn1.RawValue
+	for _, host := range hosts {
+		if host == "" {
+			return nil, errors.New("empty host name")
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 7, Bytes: ip})
+		} else {
+			names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, Bytes: []byte(host)})
+		}
+	}
+	san, err := asn1.Marshal(names)
+	if err != nil {
+		return nil, err
+	}
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{oidServerAuth, oidClientAuth})
+	if err != nil {
+		return nil, err
+	}
+	return []pkix.Extension{{Id: oidSubjectAltName, Value: san}, {Id: oidExtKeyUsage, Value: eku}}, nil
 }
 
 // ReadCertificate reads an enrollment certificate from the TLSCA.
@@ -32,6 +32,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/x509"
+	"encoding/pem"
 	"google/protobuf"
 	"path/filepath"
 
@@ -182,3 +183,89 @@ func storeCert(alias string, der []byte, t *testing.T) {
 		t.Fail()
 	}
 }
+
+func signTLSCertRequest(t *testing.T, priv *ecdsa.PrivateKey, req *membersrvc.TLSCertCreateReq) *membersrvc.Signature {
+	sig, err := signRevocationRequest(priv, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func TestCreateHostCertificate(t *testing.T) {
+	user := User{enrollID: "testTLSHostUser", enrollPwd: []byte("Bq7rT2mWx0Lp")}
+	if _, err := eca.registerUser(user.enrollID, "institution_a", membersrvc.Role_VALIDATOR, "", "", string(user.enrollPwd)); err != nil {
+		t.Fatalf("Failed registering the user: [%s]", err)
+	}
+	if err := enrollUser(&user); err != nil {
+		t.Fatalf("Failed enrolling the user: [%s]", err)
+	}
+	ecert, err := eca.readCertificateByKeyUsage(user.enrollID, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsca := NewTLSCA(eca)
+	defer tlsca.CA.Stop()
+	tlscap := &TLSCAP{tlsca}
+
+	priv, err := primitives.NewECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubraw, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	newRequest := func() *membersrvc.TLSCertCreateReq {
+		return &membersrvc.TLSCertCreateReq{
+			Ts:    &google_protobuf.Timestamp{Seconds: time.Now().Unix()},
+			Id:    &membersrvc.Identity{Id: user.enrollID},
+			Pub:   &membersrvc.PublicKey{Type: membersrvc.CryptoType_ECDSA, Key: pubraw},
+			Hosts: []string{"vp0.example.com", "10.0.0.1"},
+		}
+	}
+
+	// Without the enrollment certificate of the peer the request is refused
+	req := newRequest()
+	req.Sig = signTLSCertRequest(t, priv, req)
+	if _, err = tlscap.CreateCertificate(context.Background(), req); err == nil {
+		t.Fatal("A certificate for hosts should require an enrollment certificate")
+	}
+
+	// The request must be signed with the enrollment key
+	req = newRequest()
+	req.EnrollCert = ecert
+	req.EnrollSig = signTLSCertRequest(t, priv, req)
+	req.Sig = signTLSCertRequest(t, priv, req)
+	if _, err = tlscap.CreateCertificate(context.Background(), req); err == nil {
+		t.Fatal("A request not signed with the enrollment key should be refused")
+	}
+
+	req = newRequest()
+	req.EnrollCert = ecert
+	req.EnrollSig = signTLSCertRequest(t, user.enrollPrivKey, req)
+	req.Sig = signTLSCertRequest(t, priv, req)
+	resp, err := tlscap.CreateCertificate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed creating the certificate: [%s]", err)
+	}
+
+	cert, err := x509.ParseCertificate(resp.Cert.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != user.enrollID {
+		t.Fatalf("The certificate should be issued to [%s], got [%s]", user.enrollID, cert.Subject.CommonName)
+	}
+	if err = cert.VerifyHostname("vp0.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err = cert.VerifyHostname("10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: resp.RootCert.Cert}))
+	for _, usage := range []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth} {
+		if _, err = cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{usage}}); err != nil {
+			t.Fatalf("The certificate does not verify for %v: [%s]", usage, err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"google/protobuf"
+	"strings"
+	"time"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+// Enrollment tokens let a registrar provision many members without
+// registering each of them: a member enrolling with the secret of a token, and
+// an enrollment ID the ECA does not know yet, is first registered with the
+// role and affiliation of the token, as long as the token has not expired,
+// been revoked or been used up. Only the hash of the secret is kept.
+
+// initializeTokenTables creates the table of the enrollment tokens.
+//
+func initializeTokenTables(db *sql.DB) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS EnrollmentTokens (row INTEGER PRIMARY KEY, handle VARCHAR(64), hash BLOB, registrar VARCHAR(64), role INTEGER, affiliation VARCHAR(64), prefix VARCHAR(64), expiry INTEGER, uses INTEGER, maxUses INTEGER, revoked INTEGER)")
+	return err
+}
+
+// CreateEnrollmentToken mints an enrollment token for the members the
+// registrar of the request may register.
+//
+func (ecaa *ECAA) CreateEnrollmentToken(ctx context.Context, in *pb.EnrollmentTokenCreateReq) (_ *pb.EnrollmentToken, err error) {
+	Trace.Println("gRPC ECAA:CreateEnrollmentToken")
+
+	if in.Registrar == nil || in.Registrar.Id == "" {
+		return nil, errors.New("Invalid enrollment token request.")
+	}
+	registrar := in.Registrar.Id
+
+	var handle string
+	defer func() {
+		detail := fmt.Sprintf("token %s for %d %s members of %s", handle, in.MaxUses, role2String(int(in.Role)), in.Affiliation)
+		ecaa.eca.audit(auditToken, registrar, in.Prefix+"*", detail, err)
+	}()
+
+	sig := in.Sig
+	in.Sig = nil
+	if err = ecaa.eca.checkSignature(registrar, in, sig); err != nil {
+		return nil, err
+	}
+	if err = ecaa.eca.canRegister(registrar, role2String(int(in.Role)), ""); err != nil {
+		return nil, err
+	}
+	if strings.Contains(in.Prefix, "\\") {
+		return nil, errors.New("Do not include the escape character \\ as part of the values")
+	}
+	if ecaa.eca.requireAffiliation(in.Role) {
+		var valid bool
+		if valid, err = ecaa.eca.isValidAffiliation(in.Affiliation); err == nil && !valid {
+			err = errors.New("Invalid affiliation group " + in.Affiliation)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ttl := time.Duration(in.Ttl) * time.Second
+	if ttl == 0 {
+		ttl = viper.GetDuration("eca.enrollmentTokens.ttl")
+	}
+	if max := viper.GetDuration("eca.enrollmentTokens.maxTTL"); max > 0 && ttl > max {
+		return nil, fmt.Errorf("Enrollment tokens expire within %s.", max)
+	}
+	if ttl <= 0 {
+		return nil, errors.New("The time to live of the enrollment token is required.")
+	}
+	if in.MaxUses == 0 {
+		in.MaxUses = 1
+	}
+	if max := viper.GetInt("eca.enrollmentTokens.maxUses"); max > 0 && int(in.MaxUses) > max {
+		return nil, fmt.Errorf("Enrollment tokens register at most %d members.", max)
+	}
+
+	raw := make([]byte, 33)
+	if _, err = rand.Read(raw); err != nil {
+		return nil, err
+	}
+	handle, secret := base64.RawURLEncoding.EncodeToString(raw[:9]), base64.RawURLEncoding.EncodeToString(raw[9:])
+	expiry := time.Now().Add(ttl)
+
+	mutex.Lock()
+	_, err = ecaa.eca.db.Exec("INSERT INTO EnrollmentTokens (handle, hash, registrar, role, affiliation, prefix, expiry, uses, maxUses, revoked) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		handle, hashToken([]byte(secret)), registrar, int(in.Role), in.Affiliation, in.Prefix, expiry.Unix(), 0, in.MaxUses, 0)
+	mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	Info.Printf("%s minted the enrollment token %s for %d %s members, expiring at %v", registrar, handle, in.MaxUses, role2String(int(in.Role)), expiry)
+	return &pb.EnrollmentToken{
+		Handle:  handle,
+		Tok:     &pb.Token{Tok: []byte(secret)},
+		Expiry:  &google_protobuf.Timestamp{Seconds: expiry.Unix()},
+		MaxUses: in.MaxUses,
+	}, nil
+}
+
+// RevokeEnrollmentToken revokes an enrollment token, which registers no more
+// members. The members it registered already are not affected.
+//
+func (ecaa *ECAA) RevokeEnrollmentToken(ctx context.Context, in *pb.EnrollmentTokenRevokeReq) (_ *pb.CAStatus, err error) {
+	Trace.Println("gRPC ECAA:RevokeEnrollmentToken")
+
+	if in.Registrar == nil || in.Registrar.Id == "" || in.Handle == "" {
+		return nil, errors.New("Invalid enrollment token revocation request.")
+	}
+	registrar := in.Registrar.Id
+	defer func() {
+		ecaa.eca.audit(auditToken, registrar, "", "revocation of token "+in.Handle, err)
+	}()
+
+	sig := in.Sig
+	in.Sig = nil
+	if err = ecaa.eca.checkSignature(registrar, in, sig); err != nil {
+		return nil, err
+	}
+
+	mutex.Lock()
+	res, err := ecaa.eca.db.Exec("UPDATE EnrollmentTokens SET revoked=? WHERE handle=? AND registrar=?", 1, in.Handle, registrar)
+	mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return nil, errors.New("No enrollment token " + in.Handle + " minted by " + registrar + ".")
+	}
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// registerFromToken registers id with the enrollment token tok if id is not
+// registered yet and tok is the secret of an enrollment token. The enrollment
+// then proceeds with tok as enrollment secret.
+//
+func (eca *ECA) registerFromToken(id string, tok []byte) error {
+	var row int
+	mutex.RLock()
+	err := eca.db.QueryRow("SELECT row FROM Users WHERE id=?", id).Scan(&row)
+	mutex.RUnlock()
+	if err == nil {
+		return nil
+	}
+
+	var handle, registrar, affiliation, prefix string
+	var role int
+	var expiry, uses, maxUses, revoked int64
+	mutex.Lock()
+	err = eca.db.QueryRow("SELECT row, handle, registrar, role, affiliation, prefix, expiry, uses, maxUses, revoked FROM EnrollmentTokens WHERE hash=?", hashToken(tok)).
+		Scan(&row, &handle, &registrar, &role, &affiliation, &prefix, &expiry, &uses, &maxUses, &revoked)
+	switch {
+	case err == sql.ErrNoRows:
+		// not an enrollment token
+		mutex.Unlock()
+		return nil
+	case err != nil:
+	case revoked != 0:
+		err = errors.New("The enrollment token has been revoked.")
+	case time.Now().Unix() >= expiry:
+		err = errors.New("The enrollment token has expired.")
+	case uses >= maxUses:
+		err = errors.New("The enrollment token has been used up.")
+	case !strings.HasPrefix(id, prefix):
+		err = errors.New("The enrollment token only registers enrollment IDs starting with " + prefix + ".")
+	default:
+		// the use is taken first, so that a token is never used once more
+		// than allowed
+		var res sql.Result
+		if res, err = eca.db.Exec("UPDATE EnrollmentTokens SET uses=? WHERE row=? AND uses=?", uses+1, row, uses); err == nil {
+			if n, _ := res.RowsAffected(); n != 1 {
+				err = errors.New("The enrollment token is being used concurrently, retry.")
+			}
+		}
+	}
+	mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	_, err = eca.registerUser(id, affiliation, pb.Role(role), "", "", string(tok))
+	eca.audit(auditRegistration, registrar, id, describeRegistration(pb.Role(role), affiliation)+" with enrollment token "+handle, err)
+	if err != nil {
+		return err
+	}
+	Info.Printf("Registered %s as %s with the enrollment token %s of %s", id, role2String(role), handle, registrar)
+	return nil
+}
+
+func hashToken(tok []byte) []byte {
+	hash := sha256.Sum256(tok)
+	return hash[:]
+}
@@ -22,7 +22,14 @@ It has these top-level messages:
 	ReadUserSetReq
 	User
 	UserSet
+	AuditLogReq
+	AuditEntry
+	AuditLog
+	EnrollmentTokenCreateReq
+	EnrollmentToken
+	EnrollmentTokenRevokeReq
 	ECertCreateReq
+	ECertReEnrollReq
 	ECertCreateResp
 	ECertReadReq
 	ECertRevokeReq
@@ -36,6 +43,10 @@ It has these top-level messages:
 	TCertRevokeReq
 	TCertRevokeSetReq
 	TCertCRLReq
+	CRL
+	IssuerKey
+	CredentialCreateReq
+	CredentialCreateResp
 	TLSCertCreateReq
 	TLSCertCreateResp
 	TLSCertReadReq
@@ -51,6 +62,10 @@ It has these top-level messages:
 	ACAFetchAttrResp
 	FetchAttrsResult
 	ACAAttribute
+	ACAAttrReadReq
+	ACAAttrReadResp
+	ACAAttrUpdateReq
+	ACAAttrExpireReq
 */
 package protos
 
@@ -73,20 +88,23 @@ var _ = math.Inf
 type CryptoType int32
 
 const (
-	CryptoType_ECDSA CryptoType = 0
-	CryptoType_RSA   CryptoType = 1
-	CryptoType_DSA   CryptoType = 2
+	CryptoType_ECDSA   CryptoType = 0
+	CryptoType_RSA     CryptoType = 1
+	CryptoType_DSA     CryptoType = 2
+	CryptoType_ED25519 CryptoType = 3
 )
 
 var CryptoType_name = map[int32]string{
 	0: "ECDSA",
 	1: "RSA",
 	2: "DSA",
+	3: "ED25519",
 }
 var CryptoType_value = map[string]int32{
-	"ECDSA": 0,
-	"RSA":   1,
-	"DSA":   2,
+	"ECDSA":   0,
+	"RSA":     1,
+	"DSA":     2,
+	"ED25519": 3,
 }
 
 func (x CryptoType) String() string {
@@ -423,6 +441,184 @@ func (m *UserSet) GetUsers() []*User {
 	return nil
 }
 
+// Issuance audit log.
+//
+type AuditLogReq struct {
+	Req       *Identity                  `protobuf:"bytes,1,opt,name=req" json:"req,omitempty"`
+	Start     *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=start" json:"start,omitempty"`
+	End       *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=end" json:"end,omitempty"`
+	Action    string                     `protobuf:"bytes,4,opt,name=action" json:"action,omitempty"`
+	Requester string                     `protobuf:"bytes,5,opt,name=requester" json:"requester,omitempty"`
+	Subject   string                     `protobuf:"bytes,6,opt,name=subject" json:"subject,omitempty"`
+	Limit     uint32                     `protobuf:"varint,7,opt,name=limit" json:"limit,omitempty"`
+	Sig       *Signature                 `protobuf:"bytes,8,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *AuditLogReq) Reset()         { *m = AuditLogReq{} }
+func (m *AuditLogReq) String() string { return proto.CompactTextString(m) }
+func (*AuditLogReq) ProtoMessage()    {}
+
+func (m *AuditLogReq) GetReq() *Identity {
+	if m != nil {
+		return m.Req
+	}
+	return nil
+}
+
+func (m *AuditLogReq) GetStart() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Start
+	}
+	return nil
+}
+
+func (m *AuditLogReq) GetEnd() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.End
+	}
+	return nil
+}
+
+func (m *AuditLogReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+type AuditEntry struct {
+	Seq       uint64                     `protobuf:"varint,1,opt,name=seq" json:"seq,omitempty"`
+	Ts        *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=ts" json:"ts,omitempty"`
+	Action    string                     `protobuf:"bytes,3,opt,name=action" json:"action,omitempty"`
+	Requester string                     `protobuf:"bytes,4,opt,name=requester" json:"requester,omitempty"`
+	Subject   string                     `protobuf:"bytes,5,opt,name=subject" json:"subject,omitempty"`
+	Detail    string                     `protobuf:"bytes,6,opt,name=detail" json:"detail,omitempty"`
+	Error     string                     `protobuf:"bytes,7,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *AuditEntry) Reset()         { *m = AuditEntry{} }
+func (m *AuditEntry) String() string { return proto.CompactTextString(m) }
+func (*AuditEntry) ProtoMessage()    {}
+
+func (m *AuditEntry) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+type AuditLog struct {
+	Entries []*AuditEntry `protobuf:"bytes,1,rep,name=entries" json:"entries,omitempty"`
+}
+
+func (m *AuditLog) Reset()         { *m = AuditLog{} }
+func (m *AuditLog) String() string { return proto.CompactTextString(m) }
+func (*AuditLog) ProtoMessage()    {}
+
+func (m *AuditLog) GetEntries() []*AuditEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+// Enrollment tokens. Each member enrolling with the token, with an enrollment
+// ID starting with prefix, is registered with its role and affiliation, until
+// the token expires or maxUses members have been registered.
+//
+type EnrollmentTokenCreateReq struct {
+	Ts          *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
+	Registrar   *Identity                  `protobuf:"bytes,2,opt,name=registrar" json:"registrar,omitempty"`
+	Role        Role                       `protobuf:"varint,3,opt,name=role,enum=protos.Role" json:"role,omitempty"`
+	Affiliation string                     `protobuf:"bytes,4,opt,name=affiliation" json:"affiliation,omitempty"`
+	Prefix      string                     `protobuf:"bytes,5,opt,name=prefix" json:"prefix,omitempty"`
+	Ttl         uint64                     `protobuf:"varint,6,opt,name=ttl" json:"ttl,omitempty"`
+	MaxUses     uint32                     `protobuf:"varint,7,opt,name=maxUses" json:"maxUses,omitempty"`
+	Sig         *Signature                 `protobuf:"bytes,8,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *EnrollmentTokenCreateReq) Reset()         { *m = EnrollmentTokenCreateReq{} }
+func (m *EnrollmentTokenCreateReq) String() string { return proto.CompactTextString(m) }
+func (*EnrollmentTokenCreateReq) ProtoMessage()    {}
+
+func (m *EnrollmentTokenCreateReq) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+func (m *EnrollmentTokenCreateReq) GetRegistrar() *Identity {
+	if m != nil {
+		return m.Registrar
+	}
+	return nil
+}
+
+func (m *EnrollmentTokenCreateReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+type EnrollmentToken struct {
+	Handle  string                     `protobuf:"bytes,1,opt,name=handle" json:"handle,omitempty"`
+	Tok     *Token                     `protobuf:"bytes,2,opt,name=tok" json:"tok,omitempty"`
+	Expiry  *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=expiry" json:"expiry,omitempty"`
+	MaxUses uint32                     `protobuf:"varint,4,opt,name=maxUses" json:"maxUses,omitempty"`
+}
+
+func (m *EnrollmentToken) Reset()         { *m = EnrollmentToken{} }
+func (m *EnrollmentToken) String() string { return proto.CompactTextString(m) }
+func (*EnrollmentToken) ProtoMessage()    {}
+
+func (m *EnrollmentToken) GetTok() *Token {
+	if m != nil {
+		return m.Tok
+	}
+	return nil
+}
+
+func (m *EnrollmentToken) GetExpiry() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Expiry
+	}
+	return nil
+}
+
+type EnrollmentTokenRevokeReq struct {
+	Ts        *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
+	Registrar *Identity                  `protobuf:"bytes,2,opt,name=registrar" json:"registrar,omitempty"`
+	Handle    string                     `protobuf:"bytes,3,opt,name=handle" json:"handle,omitempty"`
+	Sig       *Signature                 `protobuf:"bytes,4,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *EnrollmentTokenRevokeReq) Reset()         { *m = EnrollmentTokenRevokeReq{} }
+func (m *EnrollmentTokenRevokeReq) String() string { return proto.CompactTextString(m) }
+func (*EnrollmentTokenRevokeReq) ProtoMessage()    {}
+
+func (m *EnrollmentTokenRevokeReq) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+func (m *EnrollmentTokenRevokeReq) GetRegistrar() *Identity {
+	if m != nil {
+		return m.Registrar
+	}
+	return nil
+}
+
+func (m *EnrollmentTokenRevokeReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
 // Certificate requests.
 //
 type ECertCreateReq struct {
@@ -480,6 +676,63 @@ func (m *ECertCreateReq) GetSig() *Signature {
 	return nil
 }
 
+// The new signing key is certified once the member proved the possession of
+// both the current and the new enrollment key.
+type ECertReEnrollReq struct {
+	Ts     *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
+	Id     *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Sign   *PublicKey                 `protobuf:"bytes,3,opt,name=sign" json:"sign,omitempty"`
+	Enc    *PublicKey                 `protobuf:"bytes,4,opt,name=enc" json:"enc,omitempty"`
+	NewSig *Signature                 `protobuf:"bytes,5,opt,name=newSig" json:"newSig,omitempty"`
+	Sig    *Signature                 `protobuf:"bytes,6,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *ECertReEnrollReq) Reset()         { *m = ECertReEnrollReq{} }
+func (m *ECertReEnrollReq) String() string { return proto.CompactTextString(m) }
+func (*ECertReEnrollReq) ProtoMessage()    {}
+
+func (m *ECertReEnrollReq) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+func (m *ECertReEnrollReq) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *ECertReEnrollReq) GetSign() *PublicKey {
+	if m != nil {
+		return m.Sign
+	}
+	return nil
+}
+
+func (m *ECertReEnrollReq) GetEnc() *PublicKey {
+	if m != nil {
+		return m.Enc
+	}
+	return nil
+}
+
+func (m *ECertReEnrollReq) GetNewSig() *Signature {
+	if m != nil {
+		return m.NewSig
+	}
+	return nil
+}
+
+func (m *ECertReEnrollReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
 type ECertCreateResp struct {
 	Certs       *CertPair         `protobuf:"bytes,1,opt,name=certs" json:"certs,omitempty"`
 	Chain       *Token            `protobuf:"bytes,2,opt,name=chain" json:"chain,omitempty"`
@@ -778,9 +1031,10 @@ func (m *TCertRevokeReq) GetSig() *Signature {
 }
 
 type TCertRevokeSetReq struct {
-	Id  *Identity                  `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
-	Ts  *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=ts" json:"ts,omitempty"`
-	Sig *Signature                 `protobuf:"bytes,3,opt,name=sig" json:"sig,omitempty"`
+	Id    *Identity                  `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Ts    *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=ts" json:"ts,omitempty"`
+	Sig   *Signature                 `protobuf:"bytes,3,opt,name=sig" json:"sig,omitempty"`
+	Owner *Identity                  `protobuf:"bytes,4,opt,name=owner" json:"owner,omitempty"`
 }
 
 func (m *TCertRevokeSetReq) Reset()         { *m = TCertRevokeSetReq{} }
@@ -808,6 +1062,13 @@ func (m *TCertRevokeSetReq) GetSig() *Signature {
 	return nil
 }
 
+func (m *TCertRevokeSetReq) GetOwner() *Identity {
+	if m != nil {
+		return m.Owner
+	}
+	return nil
+}
+
 type TCertCRLReq struct {
 	Id  *Identity  `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 	Sig *Signature `protobuf:"bytes,2,opt,name=sig" json:"sig,omitempty"`
@@ -831,11 +1092,82 @@ func (m *TCertCRLReq) GetSig() *Signature {
 	return nil
 }
 
+type CRL struct {
+	Crl []byte `protobuf:"bytes,1,opt,name=crl,proto3" json:"crl,omitempty"`
+}
+
+func (m *CRL) Reset()         { *m = CRL{} }
+func (m *CRL) String() string { return proto.CompactTextString(m) }
+func (*CRL) ProtoMessage()    {}
+
+// The public key the TCA issues anonymous credentials with
+type IssuerKey struct {
+	Key []byte     `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Sig *Signature `protobuf:"bytes,2,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *IssuerKey) Reset()         { *m = IssuerKey{} }
+func (m *IssuerKey) String() string { return proto.CompactTextString(m) }
+func (*IssuerKey) ProtoMessage()    {}
+
+func (m *IssuerKey) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+type CredentialCreateReq struct {
+	Ts      *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
+	Id      *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Request []byte                     `protobuf:"bytes,3,opt,name=request,proto3" json:"request,omitempty"`
+	Sig     *Signature                 `protobuf:"bytes,4,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *CredentialCreateReq) Reset()         { *m = CredentialCreateReq{} }
+func (m *CredentialCreateReq) String() string { return proto.CompactTextString(m) }
+func (*CredentialCreateReq) ProtoMessage()    {}
+
+func (m *CredentialCreateReq) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+func (m *CredentialCreateReq) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *CredentialCreateReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+type CredentialCreateResp struct {
+	Signature []byte   `protobuf:"bytes,1,opt,name=signature,proto3" json:"signature,omitempty"`
+	Values    []string `protobuf:"bytes,2,rep,name=values" json:"values,omitempty"`
+}
+
+func (m *CredentialCreateResp) Reset()         { *m = CredentialCreateResp{} }
+func (m *CredentialCreateResp) String() string { return proto.CompactTextString(m) }
+func (*CredentialCreateResp) ProtoMessage()    {}
+
 type TLSCertCreateReq struct {
 	Ts  *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
 	Id  *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
 	Pub *PublicKey                 `protobuf:"bytes,3,opt,name=pub" json:"pub,omitempty"`
 	Sig *Signature                 `protobuf:"bytes,4,opt,name=sig" json:"sig,omitempty"`
+	// The host names and IP addresses the certificate is for, which require
+	// the request to be signed with an enrollment key
+	Hosts      []string   `protobuf:"bytes,5,rep,name=hosts" json:"hosts,omitempty"`
+	EnrollCert []byte     `protobuf:"bytes,6,opt,name=enrollCert,proto3" json:"enrollCert,omitempty"`
+	EnrollSig  *Signature `protobuf:"bytes,7,opt,name=enrollSig" json:"enrollSig,omitempty"`
 }
 
 func (m *TLSCertCreateReq) Reset()         { *m = TLSCertCreateReq{} }
@@ -870,6 +1202,13 @@ func (m *TLSCertCreateReq) GetSig() *Signature {
 	return nil
 }
 
+func (m *TLSCertCreateReq) GetEnrollSig() *Signature {
+	if m != nil {
+		return m.EnrollSig
+	}
+	return nil
+}
+
 type TLSCertCreateResp struct {
 	Cert     *Cert `protobuf:"bytes,1,opt,name=cert" json:"cert,omitempty"`
 	RootCert *Cert `protobuf:"bytes,2,opt,name=rootCert" json:"rootCert,omitempty"`
@@ -942,7 +1281,8 @@ func (m *TLSCertRevokeReq) GetSig() *Signature {
 // Certificate issued by either the ECA or TCA.
 //
 type Cert struct {
-	Cert []byte `protobuf:"bytes,1,opt,name=cert,proto3" json:"cert,omitempty"`
+	Cert  []byte   `protobuf:"bytes,1,opt,name=cert,proto3" json:"cert,omitempty"`
+	Chain [][]byte `protobuf:"bytes,2,rep,name=chain,proto3" json:"chain,omitempty"`
 }
 
 func (m *Cert) Reset()         { *m = Cert{} }
@@ -1105,6 +1445,8 @@ type ACAFetchAttrReq struct {
 	ECert *Cert `protobuf:"bytes,2,opt,name=eCert" json:"eCert,omitempty"`
 	// The request is signed by the ECA.
 	Signature *Signature `protobuf:"bytes,3,opt,name=signature" json:"signature,omitempty"`
+	// Names of the attributes to refresh, all of them if empty.
+	AttributeNames []string `protobuf:"bytes,4,rep,name=attributeNames" json:"attributeNames,omitempty"`
 }
 
 func (m *ACAFetchAttrReq) Reset()         { *m = ACAFetchAttrReq{} }
@@ -1186,6 +1528,139 @@ func (m *ACAAttribute) GetValidTo() *google_protobuf.Timestamp {
 	return nil
 }
 
+// ACAAttrReadReq is sent to the ACA to list the attributes of a user.
+type ACAAttrReadReq struct {
+	// The identity of the member reading the attributes.
+	Id *Identity `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	// The user whose attributes are read.
+	User *Identity `protobuf:"bytes,2,opt,name=user" json:"user,omitempty"`
+	// Names of the attributes to read, all of them if empty.
+	AttributeNames []string `protobuf:"bytes,3,rep,name=attributeNames" json:"attributeNames,omitempty"`
+	// Whether to leave out the attributes which are not valid at the time of the request.
+	ValidOnly bool       `protobuf:"varint,4,opt,name=validOnly" json:"validOnly,omitempty"`
+	Sig       *Signature `protobuf:"bytes,5,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *ACAAttrReadReq) Reset()         { *m = ACAAttrReadReq{} }
+func (m *ACAAttrReadReq) String() string { return proto.CompactTextString(m) }
+func (*ACAAttrReadReq) ProtoMessage()    {}
+
+func (m *ACAAttrReadReq) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *ACAAttrReadReq) GetUser() *Identity {
+	if m != nil {
+		return m.User
+	}
+	return nil
+}
+
+func (m *ACAAttrReadReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+// ACAAttrReadResp lists the attributes of a user.
+type ACAAttrReadResp struct {
+	Attributes []*ACAAttribute `protobuf:"bytes,1,rep,name=attributes" json:"attributes,omitempty"`
+}
+
+func (m *ACAAttrReadResp) Reset()         { *m = ACAAttrReadResp{} }
+func (m *ACAAttrReadResp) String() string { return proto.CompactTextString(m) }
+func (*ACAAttrReadResp) ProtoMessage()    {}
+
+func (m *ACAAttrReadResp) GetAttributes() []*ACAAttribute {
+	if m != nil {
+		return m.Attributes
+	}
+	return nil
+}
+
+// ACAAttrUpdateReq is sent to the ACA to set the values and validity of attributes of a user.
+type ACAAttrUpdateReq struct {
+	// The identity of the registrar updating the attributes.
+	Id *Identity `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	// The user whose attributes are updated.
+	User *Identity `protobuf:"bytes,2,opt,name=user" json:"user,omitempty"`
+	// The attributes to add or replace. An attribute without validFrom is valid from the time of the request.
+	Attributes []*ACAAttribute `protobuf:"bytes,3,rep,name=attributes" json:"attributes,omitempty"`
+	Sig        *Signature      `protobuf:"bytes,4,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *ACAAttrUpdateReq) Reset()         { *m = ACAAttrUpdateReq{} }
+func (m *ACAAttrUpdateReq) String() string { return proto.CompactTextString(m) }
+func (*ACAAttrUpdateReq) ProtoMessage()    {}
+
+func (m *ACAAttrUpdateReq) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *ACAAttrUpdateReq) GetUser() *Identity {
+	if m != nil {
+		return m.User
+	}
+	return nil
+}
+
+func (m *ACAAttrUpdateReq) GetAttributes() []*ACAAttribute {
+	if m != nil {
+		return m.Attributes
+	}
+	return nil
+}
+
+func (m *ACAAttrUpdateReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+// ACAAttrExpireReq is sent to the ACA to make attributes of a user expire at the time of the request.
+type ACAAttrExpireReq struct {
+	// The identity of the registrar expiring the attributes.
+	Id *Identity `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	// The user whose attributes expire.
+	User *Identity `protobuf:"bytes,2,opt,name=user" json:"user,omitempty"`
+	// Names of the attributes to expire, all of them if empty.
+	AttributeNames []string   `protobuf:"bytes,3,rep,name=attributeNames" json:"attributeNames,omitempty"`
+	Sig            *Signature `protobuf:"bytes,4,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *ACAAttrExpireReq) Reset()         { *m = ACAAttrExpireReq{} }
+func (m *ACAAttrExpireReq) String() string { return proto.CompactTextString(m) }
+func (*ACAAttrExpireReq) ProtoMessage()    {}
+
+func (m *ACAAttrExpireReq) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *ACAAttrExpireReq) GetUser() *Identity {
+	if m != nil {
+		return m.User
+	}
+	return nil
+}
+
+func (m *ACAAttrExpireReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("protos.CryptoType", CryptoType_name, CryptoType_value)
 	proto.RegisterEnum("protos.Role", Role_name, Role_value)
@@ -1204,9 +1679,12 @@ var _ grpc.ClientConn
 type ECAPClient interface {
 	ReadCACertificate(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Cert, error)
 	CreateCertificatePair(ctx context.Context, in *ECertCreateReq, opts ...grpc.CallOption) (*ECertCreateResp, error)
+	ReEnrollCertificatePair(ctx context.Context, in *ECertReEnrollReq, opts ...grpc.CallOption) (*ECertCreateResp, error)
 	ReadCertificatePair(ctx context.Context, in *ECertReadReq, opts ...grpc.CallOption) (*CertPair, error)
 	ReadCertificateByHash(ctx context.Context, in *Hash, opts ...grpc.CallOption) (*Cert, error)
 	RevokeCertificatePair(ctx context.Context, in *ECertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error)
+	ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error)
+	WatchCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (ECAP_WatchCRLClient, error)
 }
 
 type eCAPClient struct {
@@ -1235,6 +1713,15 @@ func (c *eCAPClient) CreateCertificatePair(ctx context.Context, in *ECertCreateR
 	return out, nil
 }
 
+func (c *eCAPClient) ReEnrollCertificatePair(ctx context.Context, in *ECertReEnrollReq, opts ...grpc.CallOption) (*ECertCreateResp, error) {
+	out := new(ECertCreateResp)
+	err := grpc.Invoke(ctx, "/protos.ECAP/ReEnrollCertificatePair", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *eCAPClient) ReadCertificatePair(ctx context.Context, in *ECertReadReq, opts ...grpc.CallOption) (*CertPair, error) {
 	out := new(CertPair)
 	err := grpc.Invoke(ctx, "/protos.ECAP/ReadCertificatePair", in, out, c.cc, opts...)
@@ -1250,16 +1737,57 @@ func (c *eCAPClient) ReadCertificateByHash(ctx context.Context, in *Hash, opts .
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	return out, nil
+}
+
+func (c *eCAPClient) RevokeCertificatePair(ctx context.Context, in *ECertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error) {
+	out := new(CAStatus)
+	err := grpc.Invoke(ctx, "/protos.ECAP/RevokeCertificatePair", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCAPClient) ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error) {
+	out := new(CRL)
+	err := grpc.Invoke(ctx, "/protos.ECAP/ReadCRL", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCAPClient) WatchCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (ECAP_WatchCRLClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_ECAP_serviceDesc.Streams[0], c.cc, "/protos.ECAP/WatchCRL", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eCAPWatchCRLClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
 }
 
-func (c *eCAPClient) RevokeCertificatePair(ctx context.Context, in *ECertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error) {
-	out := new(CAStatus)
-	err := grpc.Invoke(ctx, "/protos.ECAP/RevokeCertificatePair", in, out, c.cc, opts...)
-	if err != nil {
+type ECAP_WatchCRLClient interface {
+	Recv() (*CRL, error)
+	grpc.ClientStream
+}
+
+type eCAPWatchCRLClient struct {
+	grpc.ClientStream
+}
+
+func (x *eCAPWatchCRLClient) Recv() (*CRL, error) {
+	m := new(CRL)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
-	return out, nil
+	return m, nil
 }
 
 // Server API for ECAP service
@@ -1267,9 +1795,12 @@ func (c *eCAPClient) RevokeCertificatePair(ctx context.Context, in *ECertRevokeR
 type ECAPServer interface {
 	ReadCACertificate(context.Context, *Empty) (*Cert, error)
 	CreateCertificatePair(context.Context, *ECertCreateReq) (*ECertCreateResp, error)
+	ReEnrollCertificatePair(context.Context, *ECertReEnrollReq) (*ECertCreateResp, error)
 	ReadCertificatePair(context.Context, *ECertReadReq) (*CertPair, error)
 	ReadCertificateByHash(context.Context, *Hash) (*Cert, error)
 	RevokeCertificatePair(context.Context, *ECertRevokeReq) (*CAStatus, error)
+	ReadCRL(context.Context, *Empty) (*CRL, error)
+	WatchCRL(*Empty, ECAP_WatchCRLServer) error
 }
 
 func RegisterECAPServer(s *grpc.Server, srv ECAPServer) {
@@ -1300,6 +1831,18 @@ func _ECAP_CreateCertificatePair_Handler(srv interface{}, ctx context.Context, d
 	return out, nil
 }
 
+func _ECAP_ReEnrollCertificatePair_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ECertReEnrollReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAPServer).ReEnrollCertificatePair(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func _ECAP_ReadCertificatePair_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
 	in := new(ECertReadReq)
 	if err := dec(in); err != nil {
@@ -1336,6 +1879,39 @@ func _ECAP_RevokeCertificatePair_Handler(srv interface{}, ctx context.Context, d
 	return out, nil
 }
 
+func _ECAP_ReadCRL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAPServer).ReadCRL(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ECAP_WatchCRL_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ECAPServer).WatchCRL(m, &eCAPWatchCRLServer{stream})
+}
+
+type ECAP_WatchCRLServer interface {
+	Send(*CRL) error
+	grpc.ServerStream
+}
+
+type eCAPWatchCRLServer struct {
+	grpc.ServerStream
+}
+
+func (x *eCAPWatchCRLServer) Send(m *CRL) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _ECAP_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.ECAP",
 	HandlerType: (*ECAPServer)(nil),
@@ -1348,6 +1924,10 @@ var _ECAP_serviceDesc = grpc.ServiceDesc{
 			MethodName: "CreateCertificatePair",
 			Handler:    _ECAP_CreateCertificatePair_Handler,
 		},
+		{
+			MethodName: "ReEnrollCertificatePair",
+			Handler:    _ECAP_ReEnrollCertificatePair_Handler,
+		},
 		{
 			MethodName: "ReadCertificatePair",
 			Handler:    _ECAP_ReadCertificatePair_Handler,
@@ -1360,8 +1940,18 @@ var _ECAP_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RevokeCertificatePair",
 			Handler:    _ECAP_RevokeCertificatePair_Handler,
 		},
+		{
+			MethodName: "ReadCRL",
+			Handler:    _ECAP_ReadCRL_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchCRL",
+			Handler:       _ECAP_WatchCRL_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams: []grpc.StreamDesc{},
 }
 
 // Client API for ECAA service
@@ -1371,6 +1961,9 @@ type ECAAClient interface {
 	ReadUserSet(ctx context.Context, in *ReadUserSetReq, opts ...grpc.CallOption) (*UserSet, error)
 	RevokeCertificate(ctx context.Context, in *ECertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error)
 	PublishCRL(ctx context.Context, in *ECertCRLReq, opts ...grpc.CallOption) (*CAStatus, error)
+	ReadAuditLog(ctx context.Context, in *AuditLogReq, opts ...grpc.CallOption) (*AuditLog, error)
+	CreateEnrollmentToken(ctx context.Context, in *EnrollmentTokenCreateReq, opts ...grpc.CallOption) (*EnrollmentToken, error)
+	RevokeEnrollmentToken(ctx context.Context, in *EnrollmentTokenRevokeReq, opts ...grpc.CallOption) (*CAStatus, error)
 }
 
 type eCAAClient struct {
@@ -1417,6 +2010,33 @@ func (c *eCAAClient) PublishCRL(ctx context.Context, in *ECertCRLReq, opts ...gr
 	return out, nil
 }
 
+func (c *eCAAClient) ReadAuditLog(ctx context.Context, in *AuditLogReq, opts ...grpc.CallOption) (*AuditLog, error) {
+	out := new(AuditLog)
+	err := grpc.Invoke(ctx, "/protos.ECAA/ReadAuditLog", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCAAClient) CreateEnrollmentToken(ctx context.Context, in *EnrollmentTokenCreateReq, opts ...grpc.CallOption) (*EnrollmentToken, error) {
+	out := new(EnrollmentToken)
+	err := grpc.Invoke(ctx, "/protos.ECAA/CreateEnrollmentToken", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCAAClient) RevokeEnrollmentToken(ctx context.Context, in *EnrollmentTokenRevokeReq, opts ...grpc.CallOption) (*CAStatus, error) {
+	out := new(CAStatus)
+	err := grpc.Invoke(ctx, "/protos.ECAA/RevokeEnrollmentToken", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for ECAA service
 
 type ECAAServer interface {
@@ -1424,6 +2044,9 @@ type ECAAServer interface {
 	ReadUserSet(context.Context, *ReadUserSetReq) (*UserSet, error)
 	RevokeCertificate(context.Context, *ECertRevokeReq) (*CAStatus, error)
 	PublishCRL(context.Context, *ECertCRLReq) (*CAStatus, error)
+	ReadAuditLog(context.Context, *AuditLogReq) (*AuditLog, error)
+	CreateEnrollmentToken(context.Context, *EnrollmentTokenCreateReq) (*EnrollmentToken, error)
+	RevokeEnrollmentToken(context.Context, *EnrollmentTokenRevokeReq) (*CAStatus, error)
 }
 
 func RegisterECAAServer(s *grpc.Server, srv ECAAServer) {
@@ -1478,6 +2101,42 @@ func _ECAA_PublishCRL_Handler(srv interface{}, ctx context.Context, dec func(int
 	return out, nil
 }
 
+func _ECAA_ReadAuditLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(AuditLogReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAAServer).ReadAuditLog(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ECAA_CreateEnrollmentToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(EnrollmentTokenCreateReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAAServer).CreateEnrollmentToken(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ECAA_RevokeEnrollmentToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(EnrollmentTokenRevokeReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAAServer).RevokeEnrollmentToken(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _ECAA_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.ECAA",
 	HandlerType: (*ECAAServer)(nil),
@@ -1498,6 +2157,18 @@ var _ECAA_serviceDesc = grpc.ServiceDesc{
 			MethodName: "PublishCRL",
 			Handler:    _ECAA_PublishCRL_Handler,
 		},
+		{
+			MethodName: "ReadAuditLog",
+			Handler:    _ECAA_ReadAuditLog_Handler,
+		},
+		{
+			MethodName: "CreateEnrollmentToken",
+			Handler:    _ECAA_CreateEnrollmentToken_Handler,
+		},
+		{
+			MethodName: "RevokeEnrollmentToken",
+			Handler:    _ECAA_RevokeEnrollmentToken_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
@@ -1509,6 +2180,10 @@ type TCAPClient interface {
 	CreateCertificateSet(ctx context.Context, in *TCertCreateSetReq, opts ...grpc.CallOption) (*TCertCreateSetResp, error)
 	RevokeCertificate(ctx context.Context, in *TCertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error)
 	RevokeCertificateSet(ctx context.Context, in *TCertRevokeSetReq, opts ...grpc.CallOption) (*CAStatus, error)
+	ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error)
+	WatchCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (TCAP_WatchCRLClient, error)
+	ReadIssuerKey(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*IssuerKey, error)
+	CreateCredential(ctx context.Context, in *CredentialCreateReq, opts ...grpc.CallOption) (*CredentialCreateResp, error)
 }
 
 type tCAPClient struct {
@@ -1555,6 +2230,65 @@ func (c *tCAPClient) RevokeCertificateSet(ctx context.Context, in *TCertRevokeSe
 	return out, nil
 }
 
+func (c *tCAPClient) ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error) {
+	out := new(CRL)
+	err := grpc.Invoke(ctx, "/protos.TCAP/ReadCRL", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tCAPClient) WatchCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (TCAP_WatchCRLClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TCAP_serviceDesc.Streams[0], c.cc, "/protos.TCAP/WatchCRL", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tCAPWatchCRLClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TCAP_WatchCRLClient interface {
+	Recv() (*CRL, error)
+	grpc.ClientStream
+}
+
+type tCAPWatchCRLClient struct {
+	grpc.ClientStream
+}
+
+func (x *tCAPWatchCRLClient) Recv() (*CRL, error) {
+	m := new(CRL)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tCAPClient) ReadIssuerKey(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*IssuerKey, error) {
+	out := new(IssuerKey)
+	err := grpc.Invoke(ctx, "/protos.TCAP/ReadIssuerKey", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tCAPClient) CreateCredential(ctx context.Context, in *CredentialCreateReq, opts ...grpc.CallOption) (*CredentialCreateResp, error) {
+	out := new(CredentialCreateResp)
+	err := grpc.Invoke(ctx, "/protos.TCAP/CreateCredential", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for TCAP service
 
 type TCAPServer interface {
@@ -1562,6 +2296,10 @@ type TCAPServer interface {
 	CreateCertificateSet(context.Context, *TCertCreateSetReq) (*TCertCreateSetResp, error)
 	RevokeCertificate(context.Context, *TCertRevokeReq) (*CAStatus, error)
 	RevokeCertificateSet(context.Context, *TCertRevokeSetReq) (*CAStatus, error)
+	ReadCRL(context.Context, *Empty) (*CRL, error)
+	WatchCRL(*Empty, TCAP_WatchCRLServer) error
+	ReadIssuerKey(context.Context, *Empty) (*IssuerKey, error)
+	CreateCredential(context.Context, *CredentialCreateReq) (*CredentialCreateResp, error)
 }
 
 func RegisterTCAPServer(s *grpc.Server, srv TCAPServer) {
@@ -1616,6 +2354,63 @@ func _TCAP_RevokeCertificateSet_Handler(srv interface{}, ctx context.Context, de
 	return out, nil
 }
 
+func _TCAP_ReadCRL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TCAPServer).ReadCRL(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TCAP_WatchCRL_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TCAPServer).WatchCRL(m, &tCAPWatchCRLServer{stream})
+}
+
+type TCAP_WatchCRLServer interface {
+	Send(*CRL) error
+	grpc.ServerStream
+}
+
+type tCAPWatchCRLServer struct {
+	grpc.ServerStream
+}
+
+func (x *tCAPWatchCRLServer) Send(m *CRL) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TCAP_ReadIssuerKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TCAPServer).ReadIssuerKey(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TCAP_CreateCredential_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(CredentialCreateReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TCAPServer).CreateCredential(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _TCAP_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.TCAP",
 	HandlerType: (*TCAPServer)(nil),
@@ -1636,8 +2431,26 @@ var _TCAP_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RevokeCertificateSet",
 			Handler:    _TCAP_RevokeCertificateSet_Handler,
 		},
+		{
+			MethodName: "ReadCRL",
+			Handler:    _TCAP_ReadCRL_Handler,
+		},
+		{
+			MethodName: "ReadIssuerKey",
+			Handler:    _TCAP_ReadIssuerKey_Handler,
+		},
+		{
+			MethodName: "CreateCredential",
+			Handler:    _TCAP_CreateCredential_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchCRL",
+			Handler:       _TCAP_WatchCRL_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams: []grpc.StreamDesc{},
 }
 
 // Client API for TCAA service
@@ -2056,3 +2869,114 @@ var _ACAP_serviceDesc = grpc.ServiceDesc{
 	},
 	Streams: []grpc.StreamDesc{},
 }
+
+// Client API for ACAA service
+
+type ACAAClient interface {
+	ReadAttributes(ctx context.Context, in *ACAAttrReadReq, opts ...grpc.CallOption) (*ACAAttrReadResp, error)
+	UpdateAttributes(ctx context.Context, in *ACAAttrUpdateReq, opts ...grpc.CallOption) (*CAStatus, error)
+	ExpireAttributes(ctx context.Context, in *ACAAttrExpireReq, opts ...grpc.CallOption) (*CAStatus, error)
+}
+
+type aCAAClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewACAAClient(cc *grpc.ClientConn) ACAAClient {
+	return &aCAAClient{cc}
+}
+
+func (c *aCAAClient) ReadAttributes(ctx context.Context, in *ACAAttrReadReq, opts ...grpc.CallOption) (*ACAAttrReadResp, error) {
+	out := new(ACAAttrReadResp)
+	err := grpc.Invoke(ctx, "/protos.ACAA/ReadAttributes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aCAAClient) UpdateAttributes(ctx context.Context, in *ACAAttrUpdateReq, opts ...grpc.CallOption) (*CAStatus, error) {
+	out := new(CAStatus)
+	err := grpc.Invoke(ctx, "/protos.ACAA/UpdateAttributes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aCAAClient) ExpireAttributes(ctx context.Context, in *ACAAttrExpireReq, opts ...grpc.CallOption) (*CAStatus, error) {
+	out := new(CAStatus)
+	err := grpc.Invoke(ctx, "/protos.ACAA/ExpireAttributes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for ACAA service
+
+type ACAAServer interface {
+	ReadAttributes(context.Context, *ACAAttrReadReq) (*ACAAttrReadResp, error)
+	UpdateAttributes(context.Context, *ACAAttrUpdateReq) (*CAStatus, error)
+	ExpireAttributes(context.Context, *ACAAttrExpireReq) (*CAStatus, error)
+}
+
+func RegisterACAAServer(s *grpc.Server, srv ACAAServer) {
+	s.RegisterService(&_ACAA_serviceDesc, srv)
+}
+
+func _ACAA_ReadAttributes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ACAAttrReadReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ACAAServer).ReadAttributes(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ACAA_UpdateAttributes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ACAAttrUpdateReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ACAAServer).UpdateAttributes(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ACAA_ExpireAttributes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ACAAttrExpireReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ACAAServer).ExpireAttributes(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var _ACAA_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "protos.ACAA",
+	HandlerType: (*ACAAServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReadAttributes",
+			Handler:    _ACAA_ReadAttributes_Handler,
+		},
+		{
+			MethodName: "UpdateAttributes",
+			Handler:    _ACAA_UpdateAttributes_Handler,
+		},
+		{
+			MethodName: "ExpireAttributes",
+			Handler:    _ACAA_ExpireAttributes_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
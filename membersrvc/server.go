@@ -28,7 +28,9 @@ import (
 	"strings"
 
 	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/events/producer"
 	"github.com/hyperledger/fabric/membersrvc/ca"
+	pb "github.com/hyperledger/fabric/protos"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -93,12 +95,12 @@ func main() {
 
 	ca.Info.Println("CA Server (" + viper.GetString("server.version") + ")")
 
-	aca := ca.NewACA()
-	defer aca.Stop()
-
 	eca := ca.NewECA()
 	defer eca.Stop()
 
+	aca := ca.NewACA(eca)
+	defer aca.Stop()
+
 	tca := ca.NewTCA(eca)
 	defer tca.Stop()
 
@@ -122,6 +124,22 @@ func main() {
 	tca.Start(srv)
 	tlsca.Start(srv)
 
+	if viper.GetBool("server.events.enabled") {
+		// peers listen for revocation events on the event hub
+		ehServer := producer.NewEventsServer(
+			uint(viper.GetInt("server.events.buffersize")),
+			viper.GetInt("server.events.timeout"))
+		pb.RegisterEventsServer(srv, ehServer)
+	}
+
+	if viper.GetBool("server.rest.enabled") {
+		go func() {
+			if err := ca.NewRESTServer(eca, tca).Start(); err != nil {
+				ca.Error.Println("Fail to start the REST interface: ", err)
+			}
+		}()
+	}
+
 	if sock, err := net.Listen("tcp", viper.GetString("server.port")); err != nil {
 		ca.Error.Println("Fail to start CA Server: ", err)
 		os.Exit(1)
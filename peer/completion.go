@@ -0,0 +1,348 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"google/protobuf"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/hyperledger/fabric/core/peer"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const completionFuncName = "completion"
+
+var completionCmd = &cobra.Command{
+	Use:   "completion <bash|zsh|fish>",
+	Short: "Generates the shell completion script of the peer commands.",
+	Long: `Prints the script completing the peer commands and their flags in the given shell, and the names of the chaincodes deployed on the peer of the current context after -n. To load it:
+
+  bash:  source <(peer completion bash)
+  zsh:   source <(peer completion zsh)
+  fish:  peer completion fish | source`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return completion(args)
+	},
+}
+
+// completeCmd lists the values the completion scripts complete from the peer
+var completeCmd = &cobra.Command{
+	Use:    "__complete chaincodes",
+	Hidden: true,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		useContext()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return completeValues(args)
+	},
+}
+
+func addCompletionCommands() {
+	mainCmd.AddCommand(completionCmd)
+	mainCmd.AddCommand(completeCmd)
+}
+
+// bashCompletionFunction completes the chaincode names after -n, which the
+// script generated by cobra leaves to __custom_func
+const bashCompletionFunction = `__peer_context_args()
+{
+    local i
+    for ((i = 1; i < cword; i++)); do
+        case ${words[i]} in
+            --context)
+                echo "--context ${words[i+1]}"
+                return
+                ;;
+            --context=*)
+                echo "${words[i]}"
+                return
+                ;;
+        esac
+    done
+}
+
+__custom_func()
+{
+    case ${last_command} in
+        peer_chaincode_*)
+            if [[ ${prev} == "-n" || ${prev} == "--name" ]]; then
+                COMPREPLY=( $(compgen -W "$(peer __complete chaincodes $(__peer_context_args) 2>/dev/null)" -- "$cur") )
+            fi
+            ;;
+    esac
+}
+`
+
+func completion(args []string) error {
+	if len(args) != 1 {
+		return usageError("Must supply the shell, bash, zsh or fish.")
+	}
+	var out bytes.Buffer
+	switch args[0] {
+	case "bash":
+		mainCmd.BashCompletionFunction = bashCompletionFunction
+		mainCmd.GenBashCompletion(&out)
+	case "zsh":
+		genZshCompletion(&out)
+	case "fish":
+		genFishCompletion(&out)
+	default:
+		return usageError(fmt.Sprintf("Unknown shell %s, must be bash, zsh or fish", args[0]))
+	}
+	_, err := out.WriteTo(os.Stdout)
+	return err
+}
+
+func completeValues(args []string) error {
+	if len(args) != 1 || args[0] != "chaincodes" {
+		return usageError("Must supply the values to complete, chaincodes.")
+	}
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return connectionError(err)
+	}
+	defer clientConn.Close()
+	names, err := listChaincodeNames(pb.NewOpenchainClient(clientConn))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// listChaincodeNames returns the names of the chaincodes deployed on the peer
+func listChaincodeNames(client pb.OpenchainClient) ([]string, error) {
+	chaincodes, err := client.GetChaincodes(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		return nil, peerError(fmt.Sprintf("Error trying to list the %ss", chainFuncName), err)
+	}
+	names := []string{}
+	for _, info := range chaincodes.Chaincodes {
+		names = append(names, info.ChaincodeID.Name)
+	}
+	return names, nil
+}
+
+// completionCommands returns the commands to complete, cmd and its available
+// subcommands, deepest first
+func completionCommands(cmd *cobra.Command) []*cobra.Command {
+	var cmds []*cobra.Command
+	for _, sub := range completionSubcommands(cmd) {
+		cmds = append(cmds, completionCommands(sub)...)
+	}
+	return append(cmds, cmd)
+}
+
+// completionSubcommands returns the subcommands of cmd offered as completions
+func completionSubcommands(cmd *cobra.Command) []*cobra.Command {
+	var subs []*cobra.Command
+	for _, sub := range cmd.Commands() {
+		if sub.IsAvailableCommand() && sub.Name() != "help" {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// completionPath returns the subcommands leading from peer to cmd
+func completionPath(cmd *cobra.Command) []string {
+	if !cmd.HasParent() {
+		return nil
+	}
+	return append(completionPath(cmd.Parent()), cmd.Name())
+}
+
+// completionValueFlags returns the flags taking a value, whose value is not
+// a subcommand, as --name and -n
+func completionValueFlags() []string {
+	seen := map[string]bool{}
+	for _, cmd := range completionCommands(mainCmd) {
+		cmd.NonInheritedFlags().VisitAll(func(flag *pflag.Flag) {
+			if flag.Value.Type() == "bool" {
+				return
+			}
+			seen["--"+flag.Name] = true
+			if flag.Shorthand != "" {
+				seen["-"+flag.Shorthand] = true
+			}
+		})
+	}
+	var flags []string
+	for flag := range seen {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	return flags
+}
+
+// zshQuote quotes s in single quotes for zsh and fish
+func zshQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// genZshCompletion writes the zsh completion function of the peer commands,
+// which finds the command from the words before the cursor and offers its
+// subcommands or flags
+func genZshCompletion(out *bytes.Buffer) {
+	fmt.Fprintf(out, `#compdef peer
+
+_peer() {
+    local -a cmd_words value_flags commands flags
+    local i skip=0
+    value_flags=(%s)
+    for ((i = 2; i < CURRENT; i++)); do
+        if (( skip )); then
+            skip=0
+            continue
+        fi
+        case ${words[i]} in
+            -*=*) ;;
+            -*) (( ${value_flags[(Ie)${words[i]}]} )) && skip=1 ;;
+            *) cmd_words+=(${words[i]}) ;;
+        esac
+    done
+
+    local prev=${words[CURRENT-1]}
+    if [[ ${cmd_words[1]} == chaincode && ( $prev == -n || $prev == --name ) ]]; then
+        local -a context
+        i=${words[(I)--context]}
+        (( i )) && context=(--context ${words[i+1]})
+        compadd -- ${(f)"$(peer __complete chaincodes $context 2>/dev/null)"}
+        return
+    fi
+    (( skip )) && return
+
+    case "${(j: :)cmd_words}" in
+`, strings.Join(completionValueFlags(), " "))
+
+	for _, cmd := range completionCommands(mainCmd) {
+		path := strings.Join(completionPath(cmd), " ")
+		if path == "" {
+			fmt.Fprintf(out, "        *)\n")
+		} else {
+			fmt.Fprintf(out, "        %s|%s\\ *)\n", zshQuote(path), zshQuote(path))
+		}
+		fmt.Fprintf(out, "            commands=(")
+		for _, sub := range completionSubcommands(cmd) {
+			fmt.Fprintf(out, " %s", zshQuote(sub.Name()+":"+strings.Replace(sub.Short, ":", `\:`, -1)))
+		}
+		fmt.Fprintf(out, " )\n            flags=(")
+		describe := func(flag *pflag.Flag) {
+			usage := strings.Replace(flag.Usage, ":", `\:`, -1)
+			fmt.Fprintf(out, " %s", zshQuote("--"+flag.Name+":"+usage))
+			if flag.Shorthand != "" {
+				fmt.Fprintf(out, " %s", zshQuote("-"+flag.Shorthand+":"+usage))
+			}
+		}
+		cmd.NonInheritedFlags().VisitAll(describe)
+		cmd.InheritedFlags().VisitAll(describe)
+		fmt.Fprintf(out, " )\n            ;;\n")
+	}
+
+	fmt.Fprintf(out, `    esac
+
+    if [[ ${words[CURRENT]} == -* ]]; then
+        _describe -t flags 'peer flags' flags
+    else
+        _describe -t commands 'peer commands' commands
+    fi
+}
+
+compdef _peer peer
+`)
+}
+
+// genFishCompletion writes the fish completions of the peer commands, offered
+// when the words before the cursor name their parent command
+func genFishCompletion(out *bytes.Buffer) {
+	fmt.Fprintf(out, `function __peer_words
+    set -l value_flags %s
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    set -l skip 0
+    for token in $tokens
+        if test $skip -eq 1
+            set skip 0
+        else if string match -q -- '-*=*' $token
+        else if string match -q -- '-*' $token
+            contains -- $token $value_flags; and set skip 1
+        else
+            echo $token
+        end
+    end
+end
+
+# __peer_at_command succeeds when the words before the cursor are the command
+function __peer_at_command
+    set -l words (__peer_words)
+    test (count $words) -eq (count $argv); and test "$words" = "$argv"
+end
+
+# __peer_in_command succeeds when the words before the cursor start with the
+# command
+function __peer_in_command
+    set -l words (__peer_words)
+    test (count $words) -ge (count $argv); or return 1
+    for i in (seq (count $argv))
+        test "$words[$i]" = "$argv[$i]"; or return 1
+    end
+end
+
+function __peer_chaincodes
+    set -l tokens (commandline -opc)
+    set -l i (contains -i -- --context $tokens)
+    if test -n "$i"
+        peer __complete chaincodes --context $tokens[(math $i + 1)] 2>/dev/null
+    else
+        peer __complete chaincodes 2>/dev/null
+    end
+end
+
+complete -c peer -f
+`, strings.Join(completionValueFlags(), " "))
+
+	for _, cmd := range completionCommands(mainCmd) {
+		path := strings.Join(completionPath(cmd), " ")
+		at := strings.TrimSpace("__peer_at_command " + path)
+		in := strings.TrimSpace("__peer_in_command " + path)
+		for _, sub := range completionSubcommands(cmd) {
+			fmt.Fprintf(out, "complete -c peer -n %s -a %s -d %s\n", zshQuote(at), zshQuote(sub.Name()), zshQuote(sub.Short))
+		}
+		cmd.NonInheritedFlags().VisitAll(func(flag *pflag.Flag) {
+			line := fmt.Sprintf("complete -c peer -n %s -l %s", zshQuote(in), flag.Name)
+			if flag.Shorthand != "" {
+				line += " -s " + flag.Shorthand
+			}
+			if cmd == chaincodeCmd && flag.Name == "name" {
+				line += " -x -a '(__peer_chaincodes)'"
+			} else if flag.Value.Type() != "bool" {
+				line += " -r"
+			}
+			fmt.Fprintf(out, "%s -d %s\n", line, zshQuote(flag.Usage))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core"
+)
+
+const configFuncName = "config"
+
+// The configuration the settings are checked against by config validate
+var configReference string
+
+var configCmd = &cobra.Command{
+	Use:   configFuncName,
+	Short: fmt.Sprintf("%s specific commands.", configFuncName),
+	Long:  fmt.Sprintf("%s specific commands.", configFuncName),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		core.LoggingInit(configFuncName)
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Checks the configuration of the peer.",
+	Long:  `Loads core.yaml with its environment overrides as the peer does, and reports the settings of the wrong type or out of range, the missing files of the enabled sections, the listeners sharing a port, and the settings and environment variables the peer ignores. Types and known settings are those of --reference, by default the core.yaml of the fabric sources in GOPATH, or else the configuration itself. The command fails if an error is found, but not for warnings only.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return configValidate()
+	},
+}
+
+func addConfigCommands() {
+	configValidateCmd.Flags().StringVar(&configReference, "reference", "", "Configuration file defining the known settings and their types")
+
+	configCmd.AddCommand(configValidateCmd)
+	mainCmd.AddCommand(configCmd)
+}
+
+// configValidationResult is the result of the config validate command
+type configValidationResult struct {
+	File     string                `json:"file"`
+	Problems []*core.ConfigProblem `json:"problems"`
+}
+
+func configValidate() error {
+	reference := configReference
+	if reference == "" {
+		reference = viper.ConfigFileUsed()
+		for _, p := range filepath.SplitList(os.Getenv("GOPATH")) {
+			shipped := filepath.Join(p, "src/github.com/hyperledger/fabric/peer", cmdRoot+".yaml")
+			if _, err := os.Stat(shipped); err == nil {
+				reference = shipped
+				break
+			}
+		}
+	}
+	logger.Infof("Validating %s against %s", viper.ConfigFileUsed(), reference)
+
+	problems, err := core.ValidateConfig(reference)
+	if err != nil {
+		return err
+	}
+	result := configValidationResult{File: viper.ConfigFileUsed(), Problems: problems}
+	if result.Problems == nil {
+		result.Problems = []*core.ConfigProblem{}
+	}
+	errors := 0
+	for _, problem := range problems {
+		if problem.Severity == core.ConfigError {
+			errors++
+		}
+	}
+	if err = printResult(result, func() {
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		fmt.Printf("%s: %d errors, %d warnings\n", result.File, errors, len(problems)-errors)
+	}); err != nil {
+		return err
+	}
+	if errors > 0 {
+		return fmt.Errorf("%d errors in %s", errors, result.File)
+	}
+	return nil
+}
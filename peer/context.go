@@ -0,0 +1,382 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hyperledger/fabric/core"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+const contextFuncName = "context"
+
+// cliContext is a named set of settings the client commands connect to a
+// peer with, so that one CLI can manage several peers and identities
+type cliContext struct {
+	Address string `yaml:"address" json:"address"`
+	TLS     struct {
+		Enabled            bool   `yaml:"enabled" json:"enabled"`
+		RootCert           string `yaml:"rootcert,omitempty" json:"rootcert,omitempty"`
+		ServerHostOverride string `yaml:"serverhostoverride,omitempty" json:"serverhostoverride,omitempty"`
+		// The client certificate and key, for peers requiring client
+		// authentication
+		Cert string `yaml:"cert,omitempty" json:"cert,omitempty"`
+		Key  string `yaml:"key,omitempty" json:"key,omitempty"`
+	} `yaml:"tls" json:"tls"`
+	// The enrolled user the chaincode commands run as unless -u is given
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	// Where the enrollment and login material of the users is kept, to keep
+	// the identities of different networks apart
+	FileSystemPath string `yaml:"fileSystemPath,omitempty" json:"fileSystemPath,omitempty"`
+	AdminToken     string `yaml:"adminToken,omitempty" json:"adminToken,omitempty"`
+}
+
+// cliContexts is the content of the contexts file
+type cliContexts struct {
+	Current  string                 `yaml:"current,omitempty"`
+	Contexts map[string]*cliContext `yaml:"contexts"`
+}
+
+// contextName is the context given with --context, overriding the current one
+var contextName string
+
+// contextSettings is the context set with peer context set
+var contextSettings cliContext
+
+// contextsFile returns the path of the contexts file, $PEER_CONTEXTS or
+// .hyperledger/peer-contexts.yaml in the home directory
+func contextsFile() string {
+	if file := os.Getenv("PEER_CONTEXTS"); file != "" {
+		return file
+	}
+	return filepath.Join(os.Getenv("HOME"), ".hyperledger", "peer-contexts.yaml")
+}
+
+// loadContexts reads the contexts file, which may not exist yet
+func loadContexts() (*cliContexts, error) {
+	contexts := &cliContexts{Contexts: map[string]*cliContext{}}
+	raw, err := ioutil.ReadFile(contextsFile())
+	if os.IsNotExist(err) {
+		return contexts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the contexts file: %s", err)
+	}
+	if err = yaml.Unmarshal(raw, contexts); err != nil {
+		return nil, fmt.Errorf("Error parsing the contexts file %s: %s", contextsFile(), err)
+	}
+	if contexts.Contexts == nil {
+		contexts.Contexts = map[string]*cliContext{}
+	}
+	return contexts, nil
+}
+
+// save writes the contexts file, readable by the user only as contexts may
+// hold admin tokens
+func (contexts *cliContexts) save() error {
+	raw, err := yaml.Marshal(contexts)
+	if err != nil {
+		return err
+	}
+	file := contextsFile()
+	if err = os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("Error writing the contexts file: %s", err)
+	}
+	if err = ioutil.WriteFile(file, raw, 0600); err != nil {
+		return fmt.Errorf("Error writing the contexts file: %s", err)
+	}
+	return nil
+}
+
+// names returns the names of the contexts, sorted
+func (contexts *cliContexts) names() []string {
+	names := make([]string, 0, len(contexts.Contexts))
+	for name := range contexts.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyContext overrides the connection settings of the configuration with
+// those of the context given with --context, or else of the current context
+func applyContext() error {
+	contexts, err := loadContexts()
+	if err != nil {
+		return err
+	}
+	name := contextName
+	if name == "" {
+		name = contexts.Current
+	}
+	if name == "" {
+		return nil
+	}
+	context, ok := contexts.Contexts[name]
+	if !ok {
+		return usageError(fmt.Sprintf("Unknown context %s", name))
+	}
+	logger.Debugf("Using context %s", name)
+
+	if context.Address != "" {
+		viper.Set("peer.address", context.Address)
+	}
+	viper.Set("peer.tls.enabled", context.TLS.Enabled)
+	if context.TLS.Enabled {
+		viper.Set("peer.tls.rootcert.file", context.TLS.RootCert)
+		viper.Set("peer.tls.serverhostoverride", context.TLS.ServerHostOverride)
+		viper.Set("peer.tls.clientAuth.enabled", context.TLS.Cert != "")
+		if context.TLS.Cert != "" {
+			viper.Set("peer.tls.cert.file", context.TLS.Cert)
+			viper.Set("peer.tls.key.file", context.TLS.Key)
+		}
+	}
+	if context.FileSystemPath != "" {
+		viper.Set("peer.fileSystemPath", context.FileSystemPath)
+	}
+	if context.AdminToken != "" {
+		viper.Set("peer.admin.token", context.AdminToken)
+	}
+	if chaincodeUsr == undefinedParamValue {
+		chaincodeUsr = context.Username
+	}
+	return nil
+}
+
+// useContext applies the context of the command, and exits if it cannot
+func useContext() {
+	if err := applyContext(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		exit(err)
+	}
+}
+
+var contextCmd = &cobra.Command{
+	Use:   contextFuncName,
+	Short: "Manages the contexts the commands connect to peers with.",
+	Long:  fmt.Sprintf(`A context names the address and TLS settings of a peer and the identity to use with it. The client commands use the current context, or the one given with --context. Contexts are kept in %s, or in the file given by PEER_CONTEXTS.`, contextsFile()),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		core.LoggingInit(contextFuncName)
+	},
+}
+
+var contextUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Makes a context the current one.",
+	Long:  `Makes the named context the current one, used by the client commands until another is chosen.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return contextUse(args)
+	},
+}
+
+var contextSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Creates or updates a context.",
+	Long:  `Creates the named context, or updates the settings given as flags of an existing one.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return contextSet(cmd, args)
+	},
+}
+
+var contextListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the contexts.",
+	Long:  `Lists the contexts, marking the current one.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return contextList()
+	},
+}
+
+var contextShowCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Shows a context.",
+	Long:  `Shows the settings of the named context, or of the current one.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return contextShow(args)
+	},
+}
+
+var contextDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Deletes a context.",
+	Long:  `Deletes the named context. Deleting the current context leaves no context current.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return contextDelete(args)
+	},
+}
+
+// addContextCommands adds the context commands to the main command
+func addContextCommands() {
+	flags := contextSetCmd.Flags()
+	flags.StringVar(&contextSettings.Address, "address", "", "Address of the peer")
+	flags.BoolVar(&contextSettings.TLS.Enabled, "tls", false, "Whether the peer serves TLS")
+	flags.StringVar(&contextSettings.TLS.RootCert, "rootcert", "", "Root certificate the TLS certificate of the peer is verified against")
+	flags.StringVar(&contextSettings.TLS.ServerHostOverride, "serverhostoverride", "", "Server name the TLS certificate of the peer is verified for")
+	flags.StringVar(&contextSettings.TLS.Cert, "cert", "", "TLS client certificate, for peers requiring client authentication")
+	flags.StringVar(&contextSettings.TLS.Key, "key", "", "Key of the TLS client certificate")
+	flags.StringVar(&contextSettings.Username, "username", "", "Enrolled user the chaincode commands run as")
+	flags.StringVar(&contextSettings.FileSystemPath, "fileSystemPath", "", "Where the enrollment and login material of the users is kept")
+	flags.StringVar(&contextSettings.AdminToken, "admin-token", "", "Token authenticating the admin commands")
+
+	contextCmd.AddCommand(contextUseCmd)
+	contextCmd.AddCommand(contextSetCmd)
+	contextCmd.AddCommand(contextListCmd)
+	contextCmd.AddCommand(contextShowCmd)
+	contextCmd.AddCommand(contextDeleteCmd)
+	mainCmd.AddCommand(contextCmd)
+}
+
+func contextUse(args []string) error {
+	if len(args) != 1 {
+		return usageError("Must supply the name of the context.")
+	}
+	contexts, err := loadContexts()
+	if err != nil {
+		return err
+	}
+	if _, ok := contexts.Contexts[args[0]]; !ok {
+		return usageError(fmt.Sprintf("Unknown context %s", args[0]))
+	}
+	contexts.Current = args[0]
+	if err = contexts.save(); err != nil {
+		return err
+	}
+	return printResult(&contextResult{Name: args[0], Current: true}, func() {
+		fmt.Printf("Switched to context %s\n", args[0])
+	})
+}
+
+func contextSet(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return usageError("Must supply the name of the context.")
+	}
+	contexts, err := loadContexts()
+	if err != nil {
+		return err
+	}
+	context, ok := contexts.Contexts[args[0]]
+	if !ok {
+		context = &cliContext{}
+		contexts.Contexts[args[0]] = context
+	}
+	// Only the settings given as flags change
+	flags := cmd.Flags()
+	set := func(flag string, value *string, setting string) {
+		if flags.Changed(flag) {
+			*value = setting
+		}
+	}
+	set("address", &context.Address, contextSettings.Address)
+	if flags.Changed("tls") {
+		context.TLS.Enabled = contextSettings.TLS.Enabled
+	}
+	set("rootcert", &context.TLS.RootCert, contextSettings.TLS.RootCert)
+	set("serverhostoverride", &context.TLS.ServerHostOverride, contextSettings.TLS.ServerHostOverride)
+	set("cert", &context.TLS.Cert, contextSettings.TLS.Cert)
+	set("key", &context.TLS.Key, contextSettings.TLS.Key)
+	set("username", &context.Username, contextSettings.Username)
+	set("fileSystemPath", &context.FileSystemPath, contextSettings.FileSystemPath)
+	set("admin-token", &context.AdminToken, contextSettings.AdminToken)
+	if (context.TLS.Cert == "") != (context.TLS.Key == "") {
+		return usageError("The TLS client certificate and its key must be given together.")
+	}
+	if err = contexts.save(); err != nil {
+		return err
+	}
+	return printResult(&contextResult{Name: args[0], Current: contexts.Current == args[0], Context: context}, func() {
+		fmt.Printf("Context %s saved\n", args[0])
+	})
+}
+
+func contextList() error {
+	contexts, err := loadContexts()
+	if err != nil {
+		return err
+	}
+	results := []*contextResult{}
+	for _, name := range contexts.names() {
+		results = append(results, &contextResult{Name: name, Current: name == contexts.Current, Context: contexts.Contexts[name]})
+	}
+	return printResult(results, func() {
+		for _, result := range results {
+			marker := " "
+			if result.Current {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\t%s\n", marker, result.Name, result.Context.Address)
+		}
+	})
+}
+
+func contextShow(args []string) error {
+	contexts, err := loadContexts()
+	if err != nil {
+		return err
+	}
+	name := contexts.Current
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if name == "" {
+		return usageError("No current context, must supply the name of the context.")
+	}
+	context, ok := contexts.Contexts[name]
+	if !ok {
+		return usageError(fmt.Sprintf("Unknown context %s", name))
+	}
+	result := &contextResult{Name: name, Current: name == contexts.Current, Context: context}
+	return printResult(result, func() {
+		raw, _ := yaml.Marshal(context)
+		fmt.Printf("name: %s\n%s", name, raw)
+	})
+}
+
+func contextDelete(args []string) error {
+	if len(args) != 1 {
+		return usageError("Must supply the name of the context.")
+	}
+	contexts, err := loadContexts()
+	if err != nil {
+		return err
+	}
+	if _, ok := contexts.Contexts[args[0]]; !ok {
+		return usageError(fmt.Sprintf("Unknown context %s", args[0]))
+	}
+	delete(contexts.Contexts, args[0])
+	if contexts.Current == args[0] {
+		contexts.Current = ""
+	}
+	if err = contexts.save(); err != nil {
+		return err
+	}
+	return printResult(&contextResult{Name: args[0]}, func() {
+		fmt.Printf("Context %s deleted\n", args[0])
+	})
+}
+
+// contextResult is the result of the context commands
+type contextResult struct {
+	Name    string      `json:"name"`
+	Current bool        `json:"current"`
+	Context *cliContext `json:"context,omitempty"`
+}
@@ -0,0 +1,284 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/hyperledger/fabric/core"
+	"github.com/hyperledger/fabric/events/consumer"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const eventsFuncName = "events"
+
+// Events subscribed to with peer events subscribe
+var (
+	eventsBlock      bool
+	eventsRejection  bool
+	eventsChaincodes []string
+	eventsAddress    string
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   eventsFuncName,
+	Short: fmt.Sprintf("%s specific commands.", eventsFuncName),
+	Long:  fmt.Sprintf("%s specific commands.", eventsFuncName),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		core.LoggingInit(eventsFuncName)
+		useContext()
+	},
+}
+
+var eventsSubscribeCmd = &cobra.Command{
+	Use:   "subscribe",
+	Short: "Prints the events of the peer as they happen.",
+	Long:  `Connects to the event hub of the peer and prints the events subscribed to, one JSON object per line, until interrupted. --chaincode subscribes to the events of a chaincode, all of them or only those with the given name.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return eventsSubscribe()
+	},
+}
+
+func addEventsCommands() {
+	flags := eventsSubscribeCmd.Flags()
+	flags.BoolVar(&eventsBlock, "block", false, "Subscribe to the blocks committed")
+	flags.BoolVar(&eventsRejection, "rejection", false, "Subscribe to the transactions rejected")
+	flags.StringSliceVar(&eventsChaincodes, "chaincode", nil, "Subscribe to the events of a chaincode, as <id> or <id>:<event name>, may be repeated")
+	flags.StringVar(&eventsAddress, "events-address", "", "Address of the event hub, defaults to peer.validator.events.address on the host of the peer")
+
+	eventsCmd.AddCommand(eventsSubscribeCmd)
+	mainCmd.AddCommand(eventsCmd)
+}
+
+// eventsInterests returns the interests given with the flags
+func eventsInterests() ([]*pb.Interest, error) {
+	var interests []*pb.Interest
+	if eventsBlock {
+		interests = append(interests, &pb.Interest{EventType: pb.EventType_BLOCK})
+	}
+	if eventsRejection {
+		interests = append(interests, &pb.Interest{EventType: pb.EventType_REJECTION})
+	}
+	for _, spec := range eventsChaincodes {
+		parts := strings.SplitN(spec, ":", 2)
+		if parts[0] == "" {
+			return nil, usageError(fmt.Sprintf("Invalid chaincode event %q, must be <id> or <id>:<event name>", spec))
+		}
+		reg := &pb.ChaincodeReg{ChaincodeID: parts[0]}
+		if len(parts) == 2 {
+			reg.EventName = parts[1]
+		}
+		interests = append(interests, &pb.Interest{
+			EventType: pb.EventType_CHAINCODE,
+			RegInfo:   &pb.Interest_ChaincodeRegInfo{ChaincodeRegInfo: reg},
+		})
+	}
+	if len(interests) == 0 {
+		return nil, usageError("Must subscribe to events with --block, --rejection or --chaincode.")
+	}
+	return interests, nil
+}
+
+// eventHubAddress returns the address of the event hub, which listens on
+// all interfaces of the peer unless configured otherwise
+func eventHubAddress() string {
+	if eventsAddress != "" {
+		return eventsAddress
+	}
+	address := viper.GetString("peer.validator.events.address")
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		if peerHost, _, err := net.SplitHostPort(viper.GetString("peer.address")); err == nil {
+			return net.JoinHostPort(peerHost, port)
+		}
+	}
+	return address
+}
+
+// eventsPrinter is the adapter of the events client, handing the events to
+// the command
+type eventsPrinter struct {
+	interests []*pb.Interest
+	events    chan *pb.Event
+	done      chan error
+}
+
+// GetInterestedEvents implements consumer.EventAdapter
+func (p *eventsPrinter) GetInterestedEvents() ([]*pb.Interest, error) {
+	return p.interests, nil
+}
+
+// Recv implements consumer.EventAdapter
+func (p *eventsPrinter) Recv(msg *pb.Event) (bool, error) {
+	p.events <- msg
+	return true, nil
+}
+
+// Disconnected implements consumer.EventAdapter
+func (p *eventsPrinter) Disconnected(err error) {
+	p.done <- err
+}
+
+func eventsSubscribe() error {
+	interests, err := eventsInterests()
+	if err != nil {
+		return err
+	}
+	address := eventHubAddress()
+	printer := &eventsPrinter{interests: interests, events: make(chan *pb.Event), done: make(chan error, 1)}
+	client := consumer.NewEventsClient(address, printer)
+	if err = client.Start(); err != nil {
+		return &exitError{code: exitUnavailable, err: fmt.Errorf("Error subscribing to the events of %s: %s", address, err)}
+	}
+	defer client.Stop()
+	logger.Info("Subscribed to the events of %s", address)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	marshaler := &jsonpb.Marshaler{}
+	for {
+		select {
+		case event := <-printer.events:
+			if err = marshaler.Marshal(os.Stdout, event); err != nil {
+				return fmt.Errorf("Error encoding an event: %s", err)
+			}
+			fmt.Println()
+		case err = <-printer.done:
+			if err != nil {
+				return &exitError{code: exitUnavailable, err: fmt.Errorf("Disconnected from the events of %s: %s", address, err)}
+			}
+			return nil
+		case <-sigs:
+			return nil
+		}
+	}
+}
+
+// Transaction states reported by peer chaincode invoke --wait-for-commit
+const (
+	txCommitted = "COMMITTED"
+	txRejected  = "REJECTED"
+	txTimedOut  = "TIMEOUT"
+)
+
+// commitResult is the result of an invocation waiting for its transaction
+type commitResult struct {
+	Txid   string `json:"txid"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// commitWatcher receives the blocks and rejections of the event hub, to
+// tell when a transaction is final. It subscribes before the transaction is
+// submitted, so that no event of the transaction is missed.
+type commitWatcher struct {
+	address string
+	client  *consumer.EventsClient
+	events  chan *pb.Event
+	done    chan error
+	stopped chan struct{}
+}
+
+// watchCommits subscribes to the blocks and rejections of the event hub
+func watchCommits() (*commitWatcher, error) {
+	w := &commitWatcher{
+		address: eventHubAddress(),
+		events:  make(chan *pb.Event, 16),
+		done:    make(chan error, 1),
+		stopped: make(chan struct{}),
+	}
+	w.client = consumer.NewEventsClient(w.address, w)
+	if err := w.client.Start(); err != nil {
+		return nil, &exitError{code: exitUnavailable, err: fmt.Errorf("Error subscribing to the events of %s: %s", w.address, err)}
+	}
+	return w, nil
+}
+
+// GetInterestedEvents implements consumer.EventAdapter
+func (w *commitWatcher) GetInterestedEvents() ([]*pb.Interest, error) {
+	return []*pb.Interest{{EventType: pb.EventType_BLOCK}, {EventType: pb.EventType_REJECTION}}, nil
+}
+
+// Recv implements consumer.EventAdapter
+func (w *commitWatcher) Recv(msg *pb.Event) (bool, error) {
+	select {
+	case w.events <- msg:
+		return true, nil
+	case <-w.stopped:
+		return false, nil
+	}
+}
+
+// Disconnected implements consumer.EventAdapter
+func (w *commitWatcher) Disconnected(err error) {
+	select {
+	case w.done <- err:
+	default:
+	}
+}
+
+// wait blocks until the transaction txid is committed in a block or
+// rejected, or until timeout
+func (w *commitWatcher) wait(txid string, timeout time.Duration) (*commitResult, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case event := <-w.events:
+			switch e := event.Event.(type) {
+			case *pb.Event_Block:
+				for _, tx := range e.Block.GetTransactions() {
+					if tx.Uuid == txid {
+						return &commitResult{Txid: txid, Status: txCommitted}, nil
+					}
+				}
+			case *pb.Event_Rejection:
+				if tx := e.Rejection.GetTx(); tx != nil && tx.Uuid == txid {
+					return &commitResult{Txid: txid, Status: txRejected, Error: e.Rejection.ErrorMsg}, nil
+				}
+			}
+		case err := <-w.done:
+			if err == nil {
+				err = errors.New("the event hub closed the stream")
+			}
+			return nil, &exitError{code: exitUnavailable, err: fmt.Errorf("Disconnected from the events of %s while waiting for transaction %s: %s", w.address, txid, err)}
+		case <-timer.C:
+			return &commitResult{Txid: txid, Status: txTimedOut}, nil
+		}
+	}
+}
+
+// stop ends the subscription
+func (w *commitWatcher) stop() {
+	close(w.stopped)
+	w.client.Stop()
+}
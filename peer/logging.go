@@ -0,0 +1,137 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hyperledger/fabric/core"
+	"github.com/hyperledger/fabric/core/peer"
+	pb "github.com/hyperledger/fabric/protos"
+
+	"google/protobuf"
+)
+
+const loggingFuncName = "logging"
+
+var loggingCmd = &cobra.Command{
+	Use:   loggingFuncName,
+	Short: fmt.Sprintf("%s specific commands.", loggingFuncName),
+	Long:  fmt.Sprintf("%s specific commands.", loggingFuncName),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		core.LoggingInit(loggingFuncName)
+		useContext()
+	},
+}
+
+var loggingGetLevelCmd = &cobra.Command{
+	Use:   "getlevel <module>",
+	Short: "Gets the log level of a module.",
+	Long:  `Gets the log level of a logging module of the running node, e.g. consensus/pbft. The empty module "" stands for the default level.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return usageError("Must supply a module")
+		}
+		return getLogLevel(args[0])
+	},
+}
+
+var loggingSetLevelCmd = &cobra.Command{
+	Use:   "setlevel <module> <level>",
+	Short: "Sets the log level of a module.",
+	Long:  `Sets the log level of a logging module of the running node, e.g. consensus/pbft, until the node restarts or its levels are reverted. The empty module "" stands for the default level.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 {
+			return usageError("Must supply a module and a level")
+		}
+		return setLogLevel(args[0], args[1])
+	},
+}
+
+var loggingRevertLevelsCmd = &cobra.Command{
+	Use:   "revertlevels",
+	Short: "Reverts the log levels to those of the configuration.",
+	Long:  `Restores the log levels of the logging specification of the configuration of the running node, undoing the levels set since.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return revertLogLevels()
+	},
+}
+
+func addLoggingCommands() {
+	loggingCmd.AddCommand(loggingGetLevelCmd)
+	loggingCmd.AddCommand(loggingSetLevelCmd)
+	loggingCmd.AddCommand(loggingRevertLevelsCmd)
+	mainCmd.AddCommand(loggingCmd)
+}
+
+// logLevel gets or sets the log level of a module for node loglevel
+func logLevel(args []string) error {
+	switch len(args) {
+	case 1:
+		return getLogLevel(args[0])
+	case 2:
+		return setLogLevel(args[0], args[1])
+	}
+	return usageError("Must supply a module and optionally a level")
+}
+
+func getLogLevel(module string) error {
+	return accessLogLevel(func(adminClient pb.AdminClient) (*pb.LogLevelResponse, error) {
+		return adminClient.GetModuleLogLevel(core.NewAdminContext(), &pb.LogLevelRequest{Module: module})
+	})
+}
+
+func setLogLevel(module, level string) error {
+	req := &pb.LogLevelRequest{Module: module, Level: level}
+	var err error
+	if req.Approvals, err = readApprovals(); err != nil {
+		return err
+	}
+	return accessLogLevel(func(adminClient pb.AdminClient) (*pb.LogLevelResponse, error) {
+		return adminClient.SetModuleLogLevel(core.NewAdminContext(), req)
+	})
+}
+
+func revertLogLevels() error {
+	return accessLogLevel(func(adminClient pb.AdminClient) (*pb.LogLevelResponse, error) {
+		return adminClient.RevertLogLevels(core.NewAdminContext(), &google_protobuf.Empty{})
+	})
+}
+
+// accessLogLevel calls the admin service of the local peer with access and
+// prints the log level it reports
+func accessLogLevel(access func(pb.AdminClient) (*pb.LogLevelResponse, error)) error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return connectionError(err)
+	}
+	defer clientConn.Close()
+
+	resp, err := access(pb.NewAdminClient(clientConn))
+	if err != nil {
+		return peerError("Error trying to access the log level of local peer", err)
+	}
+	return printResult(resp, func() {
+		module := resp.Module
+		if module == "" {
+			module = "default"
+		}
+		fmt.Printf("%s: %s\n", module, resp.Level)
+	})
+}
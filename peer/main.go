@@ -18,7 +18,12 @@ package main
 
 import (
 	"bytes"
+	gocrypto "crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"google/protobuf"
@@ -32,16 +37,18 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"golang.org/x/net/context"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/howeyc/gopass"
 	"github.com/op/go-logging"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/grpclog"
 
 	"net/http"
@@ -53,9 +60,12 @@ import (
 	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/ledger/genesis"
+	"github.com/hyperledger/fabric/core/metrics"
 	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/core/quorum"
 	"github.com/hyperledger/fabric/core/rest"
 	"github.com/hyperledger/fabric/core/system_chaincode"
+	"github.com/hyperledger/fabric/core/trace"
 	"github.com/hyperledger/fabric/events/producer"
 	pb "github.com/hyperledger/fabric/protos"
 )
@@ -67,6 +77,7 @@ const fabric = "hyperledger"
 const nodeFuncName = "node"
 const networkFuncName = "network"
 const chainFuncName = "chaincode"
+const ledgerFuncName = "ledger"
 const cmdRoot = "core"
 const undefinedParamValue = ""
 
@@ -110,6 +121,10 @@ var nodeCmd = &cobra.Command{
 	Long:  fmt.Sprintf("%s specific commands.", nodeFuncName),
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		core.LoggingInit(nodeFuncName)
+		// The node started is configured by core.yaml only
+		if cmd != nodeStartCmd {
+			useContext()
+		}
 	},
 }
 
@@ -125,9 +140,9 @@ var nodeStartCmd = &cobra.Command{
 var nodeStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Returns status of the node.",
-	Long:  `Returns the status of the running node.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		status()
+	Long:  `Returns the status of the running node: its ledger height, consensus view and health, connected peers, event consumers, chaincode containers, resource usage and the health of its subsystems. Without the admin credentials only whether the node is started is returned.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return status()
 	},
 }
 
@@ -139,8 +154,92 @@ var nodeStopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stops the running node.",
 	Long:  `Stops the running node, disconnecting from the network.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		stop()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return stop()
+	},
+}
+
+var (
+	drainTimeout time.Duration
+)
+
+var nodeHealthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Returns the health of the node.",
+	Long:  `Returns the status of the running node and the health of its subsystems.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return health()
+	},
+}
+
+var nodeDrainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "Drains and stops the running node.",
+	Long:  `Stops the running node once its pending transactions are ordered, refusing new transactions meanwhile.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return drain()
+	},
+}
+
+var nodeLogLevelCmd = &cobra.Command{
+	Use:        "loglevel <module> [level]",
+	Short:      "Gets or sets the log level of a module.",
+	Long:       `Gets the log level of a logging module of the running node, or sets it until the node restarts.`,
+	Deprecated: "use peer logging getlevel or setlevel instead.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return logLevel(args)
+	},
+}
+
+var (
+	approvals        []string
+	approvalKeyFile  string
+	approvalCertFile string
+	approvalPeerID   string
+)
+
+var nodeApproveCmd = &cobra.Command{
+	Use:   "approve <deploy <name>|loglevel <module> <level>>",
+	Short: "Approves an operation as an administrator.",
+	Long:  `Signs an operation requiring the approval of a quorum of administrators, see peer.admin.quorum, with the key of an administrator. The approval printed is passed with --approval to the command running the operation.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return approve(args)
+	},
+}
+
+var nodeTakeoverCmd = &cobra.Command{
+	Use:   "takeover",
+	Short: "Makes a standby node take over from its primary.",
+	Long:  `Makes the running standby node take over the identity and validating role of its primary, which must be down.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return takeover()
+	},
+}
+
+var nodeRenewCertsCmd = &cobra.Command{
+	Use:   "renewcerts",
+	Short: "Loads the renewed certificates of the node.",
+	Long:  `Loads the renewed enrollment and TLS certificates of the running node, and announces its new identity to the connected peers.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return renewCerts()
+	},
+}
+
+var nodeReEnrollCmd = &cobra.Command{
+	Use:   "reenroll",
+	Short: "Rotates the enrollment key of the node.",
+	Long:  `Makes the running node enroll new keys with the ECA, proving possession of its current enrollment key, and announces its new identity to the connected peers.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return reEnroll()
+	},
+}
+
+var nodeReloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Reloads the configuration of the node.",
+	Long:  `Reads the configuration file of the running node again, applies the settings which may change while it runs and lists those requiring a restart.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return reloadConfig()
 	},
 }
 
@@ -150,6 +249,7 @@ var networkCmd = &cobra.Command{
 	Long:  fmt.Sprintf("%s specific commands.", networkFuncName),
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		core.LoggingInit(networkFuncName)
+		useContext()
 	},
 }
 
@@ -162,6 +262,24 @@ var networkLoginCmd = &cobra.Command{
 	},
 }
 
+var networkExportCmd = &cobra.Command{
+	Use:   "export <username> <file>",
+	Short: "Exports the enrollment of a user as a PKCS#12 bundle.",
+	Long:  `Exports the enrollment key, certificate and certificates chain of a user logged in on this peer to a PKCS#12 bundle protected by a password.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return networkExport(args)
+	},
+}
+
+var networkImportCmd = &cobra.Command{
+	Use:   "import <username> <file>",
+	Short: "Logs in user to CLI with a PKCS#12 bundle.",
+	Long:  `Logs in the local user to CLI with the enrollment key, certificate and certificates chain of a PKCS#12 bundle exported by another peer, instead of the password of the user.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return networkImport(args)
+	},
+}
+
 // var vmCmd = &cobra.Command{
 // 	Use:   "vm",
 // 	Short: "Accesses VM specific functionality.",
@@ -190,9 +308,28 @@ var networkListCmd = &cobra.Command{
 	},
 }
 
+var networkMapCmd = &cobra.Command{
+	Use:   "map",
+	Short: "Shows the peer's view of the network.",
+	Long:  `Returns the connected peers with the protocol version and capabilities agreed on, the direction, age, idle time and drops of each connection, and the known peers which are not connected.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return networkMap()
+	},
+}
+
+var networkPeersCmd = &cobra.Command{
+	Use:   "peers",
+	Short: "Lists the known peers and whether they are alive.",
+	Long:  `Lists the peers the target peer node knows of, connected or only known from discovery, with their address, type, connection state, when a message last arrived from them and the protocol version agreed on.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return networkPeers()
+	},
+}
+
 // login related variables.
 var (
-	loginPW string
+	loginPW  string
+	bundlePW string
 )
 
 // Chaincode-related variables.
@@ -206,7 +343,10 @@ var (
 	chaincodeQueryRaw       bool
 	chaincodeQueryHex       bool
 	chaincodeAttributesJSON string
+	chaincodeMetadataJSON   string
 	customIDGenAlg          string
+	chaincodeWaitForCommit  bool
+	chaincodeWaitTimeout    time.Duration
 )
 
 // Peer command version flag
@@ -218,41 +358,94 @@ var chaincodeCmd = &cobra.Command{
 	Long:  fmt.Sprintf("%s specific commands.", chainFuncName),
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		core.LoggingInit(chainFuncName)
+		useContext()
 	},
 }
 
 var chaincodePathArgumentSpecifier = fmt.Sprintf("%s_PATH", strings.ToUpper(chainFuncName))
 
 var chaincodeDeployCmd = &cobra.Command{
-	Use:       "deploy",
-	Short:     fmt.Sprintf("Deploy the specified %s to the network.", chainFuncName),
-	Long:      fmt.Sprintf(`Deploy the specified %s to the network.`, chainFuncName),
-	ValidArgs: []string{"1"},
+	Use:   "deploy",
+	Short: fmt.Sprintf("Deploy the specified %s to the network.", chainFuncName),
+	Long:  fmt.Sprintf(`Deploy the specified %s to the network.`, chainFuncName),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return chaincodeDeploy(cmd, args)
 	},
 }
 
 var chaincodeInvokeCmd = &cobra.Command{
-	Use:       "invoke",
-	Short:     fmt.Sprintf("Invoke the specified %s.", chainFuncName),
-	Long:      fmt.Sprintf(`Invoke the specified %s.`, chainFuncName),
-	ValidArgs: []string{"1"},
+	Use:   "invoke",
+	Short: fmt.Sprintf("Invoke the specified %s.", chainFuncName),
+	Long:  fmt.Sprintf(`Invoke the specified %s.`, chainFuncName),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return chaincodeInvoke(cmd, args)
 	},
 }
 
 var chaincodeQueryCmd = &cobra.Command{
-	Use:       "query",
-	Short:     fmt.Sprintf("Query using the specified %s.", chainFuncName),
-	Long:      fmt.Sprintf(`Query using the specified %s.`, chainFuncName),
-	ValidArgs: []string{"1"},
+	Use:   "query",
+	Short: fmt.Sprintf("Query using the specified %s.", chainFuncName),
+	Long:  fmt.Sprintf(`Query using the specified %s.`, chainFuncName),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return chaincodeQuery(cmd, args)
 	},
 }
 
+var chaincodeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: fmt.Sprintf("Lists the %ss deployed on the blockchain.", chainFuncName),
+	Long:  fmt.Sprintf(`Lists the %ss deployed on the blockchain in the order they were deployed, with their path, type, deploy block and whether they are running on the target peer.`, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeList()
+	},
+}
+
+var chaincodeDescribeCmd = &cobra.Command{
+	Use:   "describe",
+	Short: fmt.Sprintf("Describes the deployment of the %s given with -n.", chainFuncName),
+	Long:  fmt.Sprintf(`Describes the deployment of the %s given with -n: its path and type, its deploy transaction, block and time, the size of its code package and whether it is running on the target peer.`, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeDescribe()
+	},
+}
+
+var ledgerCmd = &cobra.Command{
+	Use:   ledgerFuncName,
+	Short: fmt.Sprintf("%s specific commands.", ledgerFuncName),
+	Long:  fmt.Sprintf("%s specific commands.", ledgerFuncName),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		core.LoggingInit(ledgerFuncName)
+		useContext()
+	},
+}
+
+var ledgerHeightCmd = &cobra.Command{
+	Use:   "height",
+	Short: "Returns the height of the blockchain.",
+	Long:  `Returns the height of the blockchain of the peer, with the hashes of its last two blocks.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerHeight()
+	},
+}
+
+var ledgerBlockCmd = &cobra.Command{
+	Use:   "block <number|hash>",
+	Short: "Returns a block of the blockchain.",
+	Long:  `Returns the block with the given number, or with the given hash in hexadecimal or base64. The code packages of deploy transactions are left out.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerBlock(args)
+	},
+}
+
+var ledgerTxCmd = &cobra.Command{
+	Use:   "tx <txid>",
+	Short: "Returns a transaction of the blockchain.",
+	Long:  `Returns the transaction with the given ID, the UUID returned by chaincode invoke.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerTx(args)
+	},
+}
+
 func main() {
 	// For environment variables.
 	viper.SetEnvPrefix(cmdRoot)
@@ -264,6 +457,9 @@ func main() {
 	// subcommands.
 	mainFlags := mainCmd.PersistentFlags()
 	mainFlags.BoolVarP(&versionFlag, "version", "v", false, "Display current version of fabric peer server")
+	mainFlags.StringVarP(&outputFormat, "output", "o", outputText, "Format of the results: text, json or yaml")
+	mainFlags.StringVar(&contextName, "context", "", "Context to connect to the peer with instead of the current one, see peer context")
+	cobra.OnInitialize(checkOutputFormat)
 
 	mainFlags.String("logging-level", "", "Default logging level and overrides, see core.yaml for full syntax")
 	viper.BindPFlag("logging_level", mainFlags.Lookup("logging-level"))
@@ -301,6 +497,21 @@ func main() {
 
 	nodeStopCmd.Flags().StringVar(&stopPidFile, "stop-peer-pid-file", viper.GetString("peer.fileSystemPath"), "Location of peer pid local file, for forces kill")
 	nodeCmd.AddCommand(nodeStopCmd)
+	nodeCmd.AddCommand(nodeHealthCmd)
+	nodeDrainCmd.Flags().DurationVar(&drainTimeout, "timeout", 0, "How long to wait for pending transactions, defaults to peer.admin.drainTimeout")
+	nodeCmd.AddCommand(nodeDrainCmd)
+	for _, cmd := range []*cobra.Command{nodeLogLevelCmd, loggingSetLevelCmd, chaincodeDeployCmd} {
+		cmd.Flags().StringSliceVar(&approvals, "approval", nil, "Approval of the operation by an administrator, obtained with peer node approve, may be repeated")
+	}
+	nodeCmd.AddCommand(nodeLogLevelCmd)
+	nodeApproveCmd.Flags().StringVar(&approvalKeyFile, "key", "", "PEM file of the private key of the administrator")
+	nodeApproveCmd.Flags().StringVar(&approvalCertFile, "cert", "", "PEM file of the certificate of the administrator")
+	nodeApproveCmd.Flags().StringVar(&approvalPeerID, "peer-id", "", "ID of the peer to run the operation on, defaults to peer.id")
+	nodeCmd.AddCommand(nodeApproveCmd)
+	nodeCmd.AddCommand(nodeTakeoverCmd)
+	nodeCmd.AddCommand(nodeRenewCertsCmd)
+	nodeCmd.AddCommand(nodeReEnrollCmd)
+	nodeCmd.AddCommand(nodeReloadCmd)
 
 	mainCmd.AddCommand(versionCmd)
 	mainCmd.AddCommand(nodeCmd)
@@ -309,30 +520,59 @@ func main() {
 
 	networkCmd.AddCommand(networkLoginCmd)
 
+	for _, cmd := range []*cobra.Command{networkExportCmd, networkImportCmd} {
+		cmd.Flags().StringVarP(&bundlePW, "password", "p", undefinedParamValue, "The password of the PKCS#12 bundle. You will be requested to enter the password if this flag is not specified.")
+		networkCmd.AddCommand(cmd)
+	}
+
 	// vmCmd.AddCommand(vmPrimeCmd)
 	// mainCmd.AddCommand(vmCmd)
 
 	networkCmd.AddCommand(networkListCmd)
+	networkCmd.AddCommand(networkMapCmd)
+	networkCmd.AddCommand(networkPeersCmd)
 
 	mainCmd.AddCommand(networkCmd)
 
 	chaincodeCmd.PersistentFlags().StringVarP(&chaincodeLang, "lang", "l", "golang", fmt.Sprintf("Language the %s is written in", chainFuncName))
 	chaincodeCmd.PersistentFlags().StringVarP(&chaincodeCtorJSON, "ctor", "c", "{}", fmt.Sprintf("Constructor message for the %s in JSON format", chainFuncName))
 	chaincodeCmd.PersistentFlags().StringVarP(&chaincodeAttributesJSON, "attributes", "a", "[]", fmt.Sprintf("User attributes for the %s in JSON format", chainFuncName))
+	chaincodeCmd.PersistentFlags().StringVarP(&chaincodeMetadataJSON, "metadata", "m", "{}", "Metadata key/values of the transaction in JSON format")
 	chaincodeCmd.PersistentFlags().StringVarP(&chaincodePath, "path", "p", undefinedParamValue, fmt.Sprintf("Path to %s", chainFuncName))
 	chaincodeCmd.PersistentFlags().StringVarP(&chaincodeName, "name", "n", undefinedParamValue, fmt.Sprintf("Name of the chaincode returned by the deploy transaction"))
 	chaincodeCmd.PersistentFlags().StringVarP(&chaincodeUsr, "username", "u", undefinedParamValue, fmt.Sprintf("Username for chaincode operations when security is enabled"))
 	chaincodeCmd.PersistentFlags().StringVarP(&customIDGenAlg, "tid", "t", undefinedParamValue, fmt.Sprintf("Name of a custom ID generation algorithm (hashing and decoding) e.g. sha256base64"))
 
+	chaincodeInvokeCmd.Flags().BoolVar(&chaincodeWaitForCommit, "wait-for-commit", false, "Wait until the transaction is committed or rejected, and print the result")
+	chaincodeInvokeCmd.Flags().DurationVar(&chaincodeWaitTimeout, "wait-timeout", 30*time.Second, "How long to wait with --wait-for-commit")
+	chaincodeInvokeCmd.Flags().StringVar(&eventsAddress, "events-address", "", "Address of the event hub watched with --wait-for-commit, defaults to peer.validator.events.address on the host of the peer")
+
 	chaincodeQueryCmd.Flags().BoolVarP(&chaincodeQueryRaw, "raw", "r", false, "If true, output the query value as raw bytes, otherwise format as a printable string")
 	chaincodeQueryCmd.Flags().BoolVarP(&chaincodeQueryHex, "hex", "x", false, "If true, output the query value byte array in hexadecimal. Incompatible with --raw")
 
 	chaincodeCmd.AddCommand(chaincodeDeployCmd)
 	chaincodeCmd.AddCommand(chaincodeInvokeCmd)
 	chaincodeCmd.AddCommand(chaincodeQueryCmd)
+	chaincodeCmd.AddCommand(chaincodeListCmd)
+	chaincodeCmd.AddCommand(chaincodeDescribeCmd)
 
 	mainCmd.AddCommand(chaincodeCmd)
 
+	ledgerCmd.AddCommand(ledgerHeightCmd)
+	ledgerCmd.AddCommand(ledgerBlockCmd)
+	ledgerCmd.AddCommand(ledgerTxCmd)
+
+	mainCmd.AddCommand(ledgerCmd)
+
+	addContextCommands()
+	addEventsCommands()
+	addPackageCommands()
+	addOfflineCommands()
+	addConfigCommands()
+	addLoggingCommands()
+	mainCmd.AddCommand(shellCmd)
+	addCompletionCommands()
+
 	runtime.GOMAXPROCS(viper.GetInt("peer.gomaxprocs"))
 
 	// Init the crypto layer
@@ -341,9 +581,9 @@ func main() {
 	}
 
 	// On failure Cobra prints the usage message and error string, so we only
-	// need to exit with a non-0 status
-	if mainCmd.Execute() != nil {
-		os.Exit(1)
+	// need to exit with the status of the error
+	if err := mainCmd.Execute(); err != nil {
+		exit(err)
 	}
 	logger.Info("Exiting.....")
 }
@@ -353,28 +593,76 @@ func createEventHubServer() (net.Listener, *grpc.Server, error) {
 	var grpcServer *grpc.Server
 	var err error
 	if peer.ValidatorEnabled() {
-		lis, err = net.Listen("tcp", viper.GetString("peer.validator.events.address"))
+		access, err := newAccessList("peer.validator.events.accessControl")
+		if err != nil {
+			return nil, nil, err
+		}
+		lis, err = comm.Listen(viper.GetString("peer.validator.events.address"))
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to listen: %v", err)
 		}
+		lis = access.Listener(lis)
 
-		//TODO - do we need different SSL material for events ?
-		var opts []grpc.ServerOption
-		if comm.TLSEnabled() {
-			creds, err := credentials.NewServerTLSFromFile(viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"))
-			if err != nil {
-				return nil, nil, fmt.Errorf("Failed to generate credentials %v", err)
-			}
-			opts = []grpc.ServerOption{grpc.Creds(creds)}
+		grpcServer, err = newServiceServer("peer.validator.events", access)
+		if err != nil {
+			return nil, nil, err
 		}
-
-		grpcServer = grpc.NewServer(opts...)
 		ehServer := producer.NewEventsServer(uint(viper.GetInt("peer.validator.events.buffersize")), viper.GetInt("peer.validator.events.timeout"))
-		pb.RegisterEventsServer(grpcServer, ehServer)
+		comm.RegisterService(grpcServer, pb.EventsServiceDesc, ehServer)
 	}
 	return lis, grpcServer, err
 }
 
+// newServiceServer returns the gRPC server of a service listening on its own
+// address, with the TLS settings and message size limits under key
+func newServiceServer(key string, access *comm.AccessList) (*grpc.Server, error) {
+	var opts []grpc.ServerOption
+	if serviceTLS := comm.GetServiceTLS(key + ".tls"); serviceTLS.Enabled {
+		creds, err := serviceTLS.ServerCredentials()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to generate credentials %v", err)
+		}
+		opts = []grpc.ServerOption{grpc.Creds(access.Credentials(creds))}
+	}
+	opts = append(opts, comm.GetMessageSizeLimits(key+".messageSize").ServerOption())
+	return grpc.NewServer(opts...), nil
+}
+
+// createChaincodeServer returns the server of chaincode support, if it
+// listens on its own address, chaincode.listenAddress
+func createChaincodeServer() (net.Listener, *grpc.Server, error) {
+	listenAddress := viper.GetString("chaincode.listenAddress")
+	if listenAddress == "" {
+		return nil, nil, nil
+	}
+	access, err := newAccessList("chaincode.accessControl")
+	if err != nil {
+		return nil, nil, err
+	}
+	lis, err := comm.Listen(listenAddress)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen: %v", err)
+	}
+	grpcServer, err := newServiceServer("chaincode", access)
+	if err != nil {
+		lis.Close()
+		return nil, nil, err
+	}
+	return access.Listener(lis), grpcServer, nil
+}
+
+// newAccessList reads the access list of a gRPC server from the configuration
+func newAccessList(key string) (*comm.AccessList, error) {
+	access, err := comm.NewAccessList(key)
+	if err != nil {
+		return nil, err
+	}
+	if access.HasSubjectRules() && !comm.TLSClientAuthEnabled() {
+		logger.Warningf("Clients only present certificates with peer.tls.clientAuth.enabled, %s allows no client by subject", key)
+	}
+	return access, nil
+}
+
 var once sync.Once
 
 //this should be called exactly once and the result cached
@@ -387,7 +675,8 @@ func getSecHelper() (crypto.Peer, error) {
 		if core.SecurityEnabled() {
 			enrollID := viper.GetString("security.enrollID")
 			enrollSecret := viper.GetString("security.enrollSecret")
-			if peer.ValidatorEnabled() {
+			// A standby is enrolled as the validator it stands by for
+			if peer.ValidatorEnabled() || peer.StandbyEnabled() {
 				logger.Debugf("Registering validator with enroll ID: %s", enrollID)
 				if err = crypto.RegisterValidator(enrollID, nil, enrollID, enrollSecret); nil != err {
 					return
@@ -415,7 +704,9 @@ func getSecHelper() (crypto.Peer, error) {
 
 func showVersion() {
 	version := viper.GetString("peer.version")
-	fmt.Printf("Fabric peer server version %s\n", version)
+	printResult(map[string]string{"version": version}, func() {
+		fmt.Printf("Fabric peer server version %s\n", version)
+	})
 }
 
 func serve(args []string) error {
@@ -445,20 +736,55 @@ func serve(args []string) error {
 	listenAddr := viper.GetString("peer.listenAddress")
 
 	if "" == listenAddr {
-		logger.Debug("Listen address not specified, using peer endpoint address")
-		listenAddr = peerEndpoint.Address
+		logger.Debug("Listen address not specified, using peer address")
+		listenAddr, err = peer.GetLocalAddress()
+		if err != nil {
+			return err
+		}
+	}
+	if localAddr, _ := peer.GetLocalAddress(); localAddr != peerEndpoint.Address {
+		logger.Infof("Advertising external address %s instead of %s", peerEndpoint.Address, localAddr)
 	}
 
-	lis, err := net.Listen("tcp", listenAddr)
+	access, err := newAccessList("peer.accessControl")
+	if err != nil {
+		return err
+	}
+	lis, err := comm.Listen(listenAddr)
 	if err != nil {
 		grpclog.Fatalf("Failed to listen: %v", err)
 	}
+	lis = access.Listener(lis)
+
+	// The crypto configuration of the chain prevails over the local one
+	cryptoConfig, err := genesis.ChainCryptoConfig()
+	if err != nil {
+		return err
+	}
+	if err = crypto.SetChainSecurityLevel(cryptoConfig.HashAlgorithm, cryptoConfig.Level); err != nil {
+		return err
+	}
+
+	secHelper, err := getSecHelper()
+	if err != nil {
+		return err
+	}
+	// The servers below load the TLS certificate, which may be obtained
+	// from the TLSCA on the first start
+	if err = peer.ProvisionTLSCertificate(secHelper); err != nil {
+		return err
+	}
 
 	ehubLis, ehubGrpcServer, err := createEventHubServer()
 	if err != nil {
 		grpclog.Fatalf("Failed to create ehub server: %v", err)
 	}
 
+	ccLis, ccGrpcServer, err := createChaincodeServer()
+	if err != nil {
+		grpclog.Fatalf("Failed to create chaincode support server: %v", err)
+	}
+
 	logger.Infof("Security enabled status: %t", core.SecurityEnabled())
 	if viper.GetBool("security.privacy") {
 		if core.SecurityEnabled() {
@@ -472,25 +798,33 @@ func serve(args []string) error {
 
 	var opts []grpc.ServerOption
 	if comm.TLSEnabled() {
-		creds, err := credentials.NewServerTLSFromFile(viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"))
+		creds, err := comm.NewServerTLSForPeer()
 		if err != nil {
 			grpclog.Fatalf("Failed to generate credentials %v", err)
 		}
-		opts = []grpc.ServerOption{grpc.Creds(creds)}
+		opts = []grpc.ServerOption{grpc.Creds(access.Credentials(creds))}
+	}
+	// The server hosts the peer, devops and, unless it listens on its own
+	// address, chaincode support services, and admits the messages admitted
+	// by any of them
+	messageSizeLimits := comm.GetMessageSizeLimits("peer.messageSize").
+		Max(comm.GetMessageSizeLimits("peer.devops.messageSize"))
+	if ccGrpcServer == nil {
+		messageSizeLimits = messageSizeLimits.Max(comm.GetMessageSizeLimits("chaincode.messageSize"))
 	}
+	opts = append(opts, messageSizeLimits.ServerOption())
 
 	grpcServer := grpc.NewServer(opts...)
 
-	secHelper, err := getSecHelper()
-	if err != nil {
-		return err
-	}
-
 	secHelperFunc := func() crypto.Peer {
 		return secHelper
 	}
 
-	registerChaincodeSupport(chaincode.DefaultChain, grpcServer, secHelper)
+	if ccGrpcServer != nil {
+		registerChaincodeSupport(chaincode.DefaultChain, ccGrpcServer, secHelper)
+	} else {
+		registerChaincodeSupport(chaincode.DefaultChain, grpcServer, secHelper)
+	}
 
 	var peerServer *peer.PeerImpl
 
@@ -515,14 +849,41 @@ func serve(args []string) error {
 	}
 
 	// Register the Peer server
-	pb.RegisterPeerServer(grpcServer, peerServer)
+	comm.RegisterService(grpcServer, pb.PeerServiceDesc, peerServer)
 
 	// Register the Admin server
-	pb.RegisterAdminServer(grpcServer, core.NewAdminServer())
+	// A non-validating peer creates its engine once promoted
+	peerServer.SetEngineFactory(helper.GetEngine)
+
+	adminServer, err := core.NewAdminServer(peerServer)
+	if err != nil {
+		return fmt.Errorf("Error creating Admin server: %s", err)
+	}
+	shutdown := newShutdown(peerServer, ehubGrpcServer != nil, grpcServer, ehubGrpcServer, ccGrpcServer)
+	adminServer.SetShutdown(shutdown)
+	adminServer.RegisterStatusSource(func(status *pb.NodeStatus) {
+		status.Chaincodes = chaincode.GetChain(chaincode.DefaultChain).Containers()
+	})
+	if ehubGrpcServer != nil {
+		adminServer.RegisterStatusSource(func(status *pb.NodeStatus) {
+			status.EventConsumers = int32(producer.ConsumerCount())
+		})
+		adminServer.RegisterHealthCheck("eventhub", producer.CheckHealth)
+	}
+	adminServer.RegisterHealthCheck("chaincode", func() error {
+		if chaincode.GetChain(chaincode.DefaultChain) == nil {
+			return errors.New("Chaincode support not started")
+		}
+		return nil
+	})
+	if core.SecurityEnabled() {
+		adminServer.RegisterReadinessCheck("membersrvc", checkMembersrvc)
+	}
+	comm.RegisterService(grpcServer, pb.AdminServiceDesc, adminServer)
 
 	// Register Devops server
 	serverDevops := core.NewDevopsServer(peerServer)
-	pb.RegisterDevopsServer(grpcServer, serverDevops)
+	comm.RegisterService(grpcServer, pb.DevopsServiceDesc, serverDevops)
 
 	// Register the ServerOpenchain server
 	serverOpenchain, err := rest.NewOpenchainServerWithPeerInfo(peerServer)
@@ -531,11 +892,27 @@ func serve(args []string) error {
 		return err
 	}
 
-	pb.RegisterOpenchainServer(grpcServer, serverOpenchain)
+	comm.RegisterService(grpcServer, pb.OpenchainServiceDesc, serverOpenchain)
 
 	// Create and register the REST service if configured
 	if viper.GetBool("rest.enabled") {
-		go rest.StartOpenchainRESTServer(serverOpenchain, serverDevops)
+		go rest.StartOpenchainRESTServer(serverOpenchain, serverDevops, adminServer)
+	}
+
+	// Expose the metrics for Prometheus to scrape, the calls to the gRPC
+	// services are only measured then
+	if viper.GetBool("peer.metrics.enabled") {
+		comm.AddUnaryInterceptor(comm.MetricsUnaryInterceptor)
+		comm.AddStreamInterceptor(comm.MetricsStreamInterceptor)
+		go func() {
+			metricsListenAddress := viper.GetString("peer.metrics.listenAddress")
+			mux := http.NewServeMux()
+			mux.Handle(viper.GetString("peer.metrics.path"), metrics.Handler())
+			logger.Infof("Starting metrics server with listenAddress = %s", metricsListenAddress)
+			if metricsErr := http.ListenAndServe(metricsListenAddress, mux); metricsErr != nil {
+				logger.Errorf("Error starting metrics server: %s", metricsErr)
+			}
+		}()
 	}
 
 	logger.Infof("Starting peer with ID=%s, network ID=%s, address=%s, rootnodes=%v, validator=%v",
@@ -551,9 +928,21 @@ func serve(args []string) error {
 		sig := <-sigs
 		fmt.Println()
 		fmt.Println(sig)
+		shutdown.Run(viper.GetDuration("peer.shutdown.timeout"))
 		serve <- nil
 	}()
 
+	// Reload the configuration on SIGHUP
+	hups := make(chan os.Signal, 1)
+	signal.Notify(hups, syscall.SIGHUP)
+	go func() {
+		for range hups {
+			if _, err := core.ReloadConfig(); err != nil {
+				logger.Errorf("Error reloading the configuration: %s", err)
+			}
+		}
+	}()
+
 	go func() {
 		var grpcErr error
 		if grpcErr = grpcServer.Serve(lis); grpcErr != nil {
@@ -573,6 +962,11 @@ func serve(args []string) error {
 		go ehubGrpcServer.Serve(ehubLis)
 	}
 
+	// Start the chaincode support server
+	if ccGrpcServer != nil {
+		go ccGrpcServer.Serve(ccLis)
+	}
+
 	if viper.GetBool("peer.profile.enabled") {
 		go func() {
 			profileListenAddress := viper.GetString("peer.profile.listenAddress")
@@ -591,22 +985,89 @@ func status() (err error) {
 	clientConn, err := peer.NewPeerClientConnection()
 	if err != nil {
 		logger.Infof("Error trying to connect to local peer: %s", err)
-		err = fmt.Errorf("Error trying to connect to local peer: %s", err)
-		fmt.Println(&pb.ServerStatus{Status: pb.ServerStatus_UNKNOWN})
-		return err
+		printText(func() {
+			fmt.Println(&pb.ServerStatus{Status: pb.ServerStatus_UNKNOWN})
+		})
+		return connectionError(err)
 	}
 
 	serverClient := pb.NewAdminClient(clientConn)
 
+	nodeStatus, err := serverClient.GetNodeStatus(core.NewAdminContext(), &google_protobuf.Empty{})
+	if err == nil {
+		return printResult(nodeStatus, func() {
+			printNodeStatus(nodeStatus)
+		})
+	}
+	if !isAdminRefusal(err) {
+		logger.Infof("Error trying to get status from local peer: %s", err)
+		printText(func() {
+			fmt.Println(&pb.ServerStatus{Status: pb.ServerStatus_UNKNOWN})
+		})
+		return peerError("Error trying to connect to local peer", err)
+	}
+	// Without the admin credentials only whether the peer runs is reported
+	logger.Infof("Peer refused the node status, reporting the server status only: %s", err)
+
 	status, err := serverClient.GetStatus(context.Background(), &google_protobuf.Empty{})
 	if err != nil {
 		logger.Infof("Error trying to get status from local peer: %s", err)
-		err = fmt.Errorf("Error trying to connect to local peer: %s", err)
-		fmt.Println(&pb.ServerStatus{Status: pb.ServerStatus_UNKNOWN})
-		return err
+		printText(func() {
+			fmt.Println(&pb.ServerStatus{Status: pb.ServerStatus_UNKNOWN})
+		})
+		return peerError("Error trying to connect to local peer", err)
 	}
-	fmt.Println(status)
-	return nil
+	return printStatus(status)
+}
+
+// printNodeStatus prints the status of the node and its subsystems, one per line
+func printNodeStatus(status *pb.NodeStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	role := "non-validator"
+	if status.Validator {
+		role = "validator"
+	}
+	fmt.Fprintf(w, "Status:\t%s\n", status.Status)
+	fmt.Fprintf(w, "Peer:\t%s %s (%s)\n", status.PeerID, status.Address, role)
+	fmt.Fprintf(w, "Uptime:\t%s\n", time.Duration(status.UptimeSeconds)*time.Second)
+	fmt.Fprintf(w, "Blockchain height:\t%d\n", status.BlockchainHeight)
+	if consensus := status.Consensus; consensus != nil {
+		health := "healthy"
+		if !consensus.Healthy {
+			health = fmt.Sprintf("unhealthy, %s: %s", consensus.Condition, consensus.Detail)
+		}
+		fmt.Fprintf(w, "Consensus:\tview %d, low watermark %d, %d outstanding, %s\n", consensus.View, consensus.LowWatermark, consensus.Outstanding, health)
+	}
+	fmt.Fprintf(w, "Connected peers:\t%d\n", status.ConnectedPeers)
+	fmt.Fprintf(w, "Event consumers:\t%d\n", status.EventConsumers)
+	fmt.Fprintf(w, "Chaincodes:\t%d\n", len(status.Chaincodes))
+	for _, container := range status.Chaincodes {
+		state := "running"
+		if !container.Registered {
+			state = "starting"
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", container.Name, state)
+	}
+	if resources := status.Resources; resources != nil {
+		fmt.Fprintf(w, "Resources:\t%d goroutines, %d MiB heap, %d MiB from the system, %d GC cycles pausing %s\n",
+			resources.Goroutines, resources.HeapAllocBytes>>20, resources.SysBytes>>20, resources.GcCycles,
+			time.Duration(resources.GcPauseMicros)*time.Microsecond)
+	}
+	for _, subsystem := range status.Subsystems {
+		health := "healthy"
+		if !subsystem.Healthy {
+			health = "unhealthy: " + subsystem.Detail
+		}
+		fmt.Fprintf(w, "Subsystem %s:\t%s\n", subsystem.Name, health)
+	}
+	w.Flush()
+}
+
+// printStatus prints a message returned by the peer
+func printStatus(status proto.Message) error {
+	return printResult(status, func() {
+		fmt.Println(status)
+	})
 }
 
 func stop() (err error) {
@@ -631,15 +1092,219 @@ func stop() (err error) {
 	logger.Info("Stopping peer using grpc")
 	serverClient := pb.NewAdminClient(clientConn)
 
-	status, err := serverClient.StopServer(context.Background(), &google_protobuf.Empty{})
+	status, err := serverClient.StopServer(core.NewAdminContext(), &google_protobuf.Empty{})
 	if err != nil {
-		fmt.Println(&pb.ServerStatus{Status: pb.ServerStatus_STOPPED})
-		return nil
+		if isAdminRefusal(err) {
+			return peerError("Error stopping local peer", err)
+		}
+		return printStatus(&pb.ServerStatus{Status: pb.ServerStatus_STOPPED})
 	}
 
-	err = fmt.Errorf("Connection remain opened, peer process doesn't exit")
-	fmt.Println(status)
-	return err
+	printText(func() {
+		fmt.Println(status)
+	})
+	return fmt.Errorf("Connection remain opened, peer process doesn't exit")
+}
+
+// isAdminRefusal tells whether an admin call failed because the peer refused
+// the caller, rather than because the peer exited while answering
+func isAdminRefusal(err error) bool {
+	code := grpc.Code(err)
+	return code == codes.Unauthenticated || code == codes.PermissionDenied || code == codes.Unimplemented
+}
+
+func health() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return connectionError(err)
+	}
+	defer clientConn.Close()
+
+	status, err := pb.NewAdminClient(clientConn).GetNodeStatus(core.NewAdminContext(), &google_protobuf.Empty{})
+	if err != nil {
+		return peerError("Error trying to get health from local peer", err)
+	}
+	if err = printStatus(status); err != nil {
+		return err
+	}
+	for _, subsystem := range status.Subsystems {
+		if !subsystem.Healthy {
+			return fmt.Errorf("Subsystem %s is unhealthy: %s", subsystem.Name, subsystem.Detail)
+		}
+	}
+	return nil
+}
+
+func drain() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return connectionError(err)
+	}
+	logger.Info("Draining peer using grpc")
+
+	req := &pb.DrainRequest{TimeoutSeconds: int32(drainTimeout / time.Second)}
+	status, err := pb.NewAdminClient(clientConn).DrainServer(core.NewAdminContext(), req)
+	if err != nil {
+		if isAdminRefusal(err) {
+			return peerError("Error draining local peer", err)
+		}
+		return printStatus(&pb.ServerStatus{Status: pb.ServerStatus_STOPPED})
+	}
+	printText(func() {
+		fmt.Println(status)
+	})
+	return fmt.Errorf("Connection remain opened, peer process doesn't exit")
+}
+
+func approve(args []string) error {
+	peerID := approvalPeerID
+	if peerID == "" {
+		peerID = viper.GetString("peer.id")
+	}
+	var operation string
+	var payload []byte
+	switch {
+	case len(args) == 2 && args[0] == "deploy":
+		operation, payload = quorum.OperationDeploy, quorum.DeployPayload(args[1])
+	case len(args) == 3 && args[0] == "loglevel":
+		operation, payload = quorum.OperationSetLogLevel, quorum.LogLevelPayload(peerID, args[1], args[2])
+	default:
+		return usageError("Must supply deploy <name> or loglevel <module> <level>")
+	}
+
+	key, err := readPrivateKey(approvalKeyFile, "administrator")
+	if err != nil {
+		return err
+	}
+	cert, err := readCertificate(approvalCertFile, "administrator")
+	if err != nil {
+		return err
+	}
+
+	approval, err := quorum.Approve(key, cert, operation, payload)
+	if err != nil {
+		return fmt.Errorf("Error approving %s: %s", operation, err)
+	}
+	raw, err := proto.Marshal(approval)
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(raw)
+	return printResult(map[string]string{"approval": encoded}, func() {
+		fmt.Println(encoded)
+	})
+}
+
+// readPrivateKey reads the PEM encoded private key of holder, e.g. an
+// administrator
+func readPrivateKey(file, holder string) (gocrypto.Signer, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the key of the %s: %s", holder, err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("No private key in %s", file)
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing the key of the %s: %s", holder, err)
+	}
+	signer, ok := key.(gocrypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+// readCertificate returns the DER encoding of the PEM encoded certificate of
+// holder
+func readCertificate(file, holder string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the certificate of the %s: %s", holder, err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("No certificate in %s", file)
+	}
+	return block.Bytes, nil
+}
+
+// readApprovals decodes the approvals given with --approval
+func readApprovals() ([]*pb.AdminApproval, error) {
+	var result []*pb.AdminApproval
+	for _, encoded := range approvals {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid approval: %s", err)
+		}
+		approval := &pb.AdminApproval{}
+		if err = proto.Unmarshal(raw, approval); err != nil {
+			return nil, fmt.Errorf("Invalid approval: %s", err)
+		}
+		result = append(result, approval)
+	}
+	return result, nil
+}
+
+func takeover() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return connectionError(err)
+	}
+	defer clientConn.Close()
+
+	status, err := pb.NewAdminClient(clientConn).Takeover(core.NewAdminContext(), &google_protobuf.Empty{})
+	if err != nil {
+		return peerError("Error taking over from the primary of local peer", err)
+	}
+	return printStatus(status)
+}
+
+func renewCerts() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return connectionError(err)
+	}
+	defer clientConn.Close()
+
+	status, err := pb.NewAdminClient(clientConn).RenewCertificates(core.NewAdminContext(), &google_protobuf.Empty{})
+	if err != nil {
+		return peerError("Error renewing the certificates of local peer", err)
+	}
+	return printStatus(status)
+}
+
+func reEnroll() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return connectionError(err)
+	}
+	defer clientConn.Close()
+
+	status, err := pb.NewAdminClient(clientConn).ReEnroll(core.NewAdminContext(), &google_protobuf.Empty{})
+	if err != nil {
+		return peerError("Error re-enrolling local peer", err)
+	}
+	return printStatus(status)
+}
+
+func reloadConfig() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return connectionError(err)
+	}
+	defer clientConn.Close()
+
+	report, err := pb.NewAdminClient(clientConn).ReloadConfig(core.NewAdminContext(), &google_protobuf.Empty{})
+	if err != nil {
+		return peerError("Error reloading the configuration of local peer", err)
+	}
+	return printStatus(report)
 }
 
 // login confirms the enrollmentID and secret password of the client with the
@@ -649,13 +1314,13 @@ func networkLogin(args []string) (err error) {
 
 	// Check for username argument
 	if len(args) == 0 {
-		err = errors.New("Must supply username")
+		err = usageError("Must supply username")
 		return
 	}
 
 	// Check for other extraneous arguments
 	if len(args) != 1 {
-		err = errors.New("Must supply username as the 1st and only parameter")
+		err = usageError("Must supply username as the 1st and only parameter")
 		return
 	}
 
@@ -667,7 +1332,7 @@ func networkLogin(args []string) (err error) {
 	// If the user is already logged in, return
 	if _, err = os.Stat(localStore + "loginToken_" + args[0]); err == nil {
 		logger.Infof("User '%s' is already logged in.\n", args[0])
-		return
+		return printResult(&userResult{User: args[0]}, func() {})
 	}
 
 	// If the '--password' flag is not specified, need read it from the terminal
@@ -688,7 +1353,7 @@ func networkLogin(args []string) (err error) {
 	// Get a devopsClient to perform the login
 	clientConn, err := peer.NewPeerClientConnection()
 	if err != nil {
-		err = fmt.Errorf("Error trying to connect to local peer: %s", err)
+		err = connectionError(err)
 		return
 	}
 	devopsClient := pb.NewDevopsClient(clientConn)
@@ -696,6 +1361,10 @@ func networkLogin(args []string) (err error) {
 	// Build the login spec and login
 	loginSpec := &pb.Secret{EnrollId: args[0], EnrollSecret: loginPW}
 	loginResult, err := devopsClient.Login(context.Background(), loginSpec)
+	if err != nil {
+		err = peerError("Error on client login", err)
+		return
+	}
 
 	// Check if login is successful
 	if loginResult.Status == pb.Response_SUCCESS {
@@ -721,11 +1390,92 @@ func networkLogin(args []string) (err error) {
 
 		logger.Infof("Login successful for user '%s'.\n", args[0])
 	} else {
-		err = fmt.Errorf("Error on client login: %s", string(loginResult.Msg))
+		err = &exitError{code: exitRefused, err: fmt.Errorf("Error on client login: %s", string(loginResult.Msg))}
 		return
 	}
 
-	return nil
+	return printResult(&userResult{User: args[0]}, func() {})
+}
+
+// networkExport writes the enrollment of a user logged in on this peer to a
+// PKCS#12 bundle.
+func networkExport(args []string) error {
+	if len(args) != 2 {
+		return usageError("Must supply username and file as parameters")
+	}
+	if _, err := os.Stat(getCliFilePath() + "loginToken_" + args[0]); err != nil {
+		return fmt.Errorf("User '%s' is not logged in", args[0])
+	}
+	password, err := readBundlePassword()
+	if err != nil {
+		return err
+	}
+
+	client, err := crypto.InitClient(args[0], nil)
+	if err != nil {
+		return fmt.Errorf("Error initializing the security context of user '%s': %s", args[0], err)
+	}
+	defer crypto.CloseClient(client)
+
+	bundle, err := client.ExportPKCS12(password)
+	if err != nil {
+		return fmt.Errorf("Error exporting the enrollment of user '%s': %s", args[0], err)
+	}
+	if err = ioutil.WriteFile(args[1], bundle, 0600); err != nil {
+		return fmt.Errorf("Error writing %s: %s", args[1], err)
+	}
+
+	logger.Infof("Enrollment of user '%s' exported to %s.\n", args[0], args[1])
+	return printResult(&userResult{User: args[0], File: args[1]}, func() {})
+}
+
+// networkImport logs in a user with the enrollment of a PKCS#12 bundle, which
+// it stores in the Devops server like a login.
+func networkImport(args []string) error {
+	if len(args) != 2 {
+		return usageError("Must supply username and file as parameters")
+	}
+	localStore := getCliFilePath()
+	if _, err := os.Stat(localStore + "loginToken_" + args[0]); err == nil {
+		return fmt.Errorf("User '%s' is already logged in", args[0])
+	}
+	bundle, err := ioutil.ReadFile(args[1])
+	if err != nil {
+		return fmt.Errorf("Error reading %s: %s", args[1], err)
+	}
+	password, err := readBundlePassword()
+	if err != nil {
+		return err
+	}
+
+	if err = crypto.ImportClient(args[0], nil, bundle, password); err != nil {
+		return fmt.Errorf("Error importing the enrollment of user '%s': %s", args[0], err)
+	}
+
+	if err = os.MkdirAll(localStore, 0755); err != nil {
+		return fmt.Errorf("Error creating %s directory: %s", localStore, err)
+	}
+	if err = ioutil.WriteFile(localStore+"loginToken_"+args[0], []byte(args[0]), 0755); err != nil {
+		return fmt.Errorf("Error storing client login token: %s", err)
+	}
+
+	logger.Infof("Login successful for user '%s'.\n", args[0])
+	return printResult(&userResult{User: args[0], File: args[1]}, func() {})
+}
+
+// readBundlePassword returns the password of the PKCS#12 bundle, read from
+// the terminal if the '--password' flag is not specified.
+func readBundlePassword() (string, error) {
+	if bundlePW != "" {
+		return bundlePW, nil
+	}
+
+	fmt.Print("Enter password for the PKCS#12 bundle: ")
+	pw, err := gopass.GetPasswdMasked()
+	if err != nil {
+		return "", fmt.Errorf("Error trying to read password from console: %s", err)
+	}
+	return string(pw), nil
 }
 
 // getCliFilePath is a helper function to retrieve the local storage directory
@@ -739,6 +1489,67 @@ func getCliFilePath() string {
 	return localStore
 }
 
+// newShutdown returns the ordered shutdown of the peer: refuse new
+// transactions, let the in-flight chaincode executions complete and the
+// pending transactions be ordered, flush the event hub, give the consenter
+// the chance to hand over its duties, stop the servers and export the spans
+// traced
+func newShutdown(peerServer *peer.PeerImpl, events bool, servers ...*grpc.Server) *core.Shutdown {
+	shutdown := &core.Shutdown{}
+	shutdown.AddStep("refusing new transactions", func(time.Time) {
+		peerServer.Drain()
+	})
+	shutdown.AddStep("waiting for chaincode executions", func(deadline time.Time) {
+		if executing := chaincode.GetChain(chaincode.DefaultChain).WaitExecutions(deadline); executing > 0 {
+			logger.Warningf("Stopping with %d chaincode executions in flight", executing)
+		}
+	})
+	shutdown.AddStep("waiting for pending transactions", func(deadline time.Time) {
+		if pending := core.WaitPending(peerServer, deadline); pending > 0 {
+			logger.Warningf("Stopping with %d transactions still pending", pending)
+		}
+	})
+	if events {
+		shutdown.AddStep("flushing the event hub", func(deadline time.Time) {
+			if pending := producer.Flush(deadline); pending > 0 {
+				logger.Warningf("Stopping with %d events not delivered", pending)
+			}
+		})
+	}
+	shutdown.AddStep("stopping consensus", func(time.Time) {
+		if peer.ValidatorEnabled() {
+			helper.StopConsenter(viper.GetDuration("peer.validator.consensus.stoptimeout"))
+		}
+	})
+	shutdown.AddStep("stopping servers", func(time.Time) {
+		for _, server := range servers {
+			if server != nil {
+				server.Stop()
+			}
+		}
+	})
+	if trace.Enabled() {
+		shutdown.AddStep("exporting the remaining spans", trace.Flush)
+	}
+	return shutdown
+}
+
+// membersrvcDialTimeout bounds the time checkMembersrvc waits for the
+// membership services
+const membersrvcDialTimeout = 2 * time.Second
+
+// checkMembersrvc returns why the ECA of the membership services, which
+// enrolls the users of the peer, cannot be reached
+func checkMembersrvc() error {
+	address := viper.GetString("peer.pki.eca.paddr")
+	conn, err := net.DialTimeout("tcp", address, membersrvcDialTimeout)
+	if err != nil {
+		return fmt.Errorf("Membership services unreachable at %s: %s", address, err)
+	}
+	conn.Close()
+	return nil
+}
+
 func registerChaincodeSupport(chainname chaincode.ChainName, grpcServer *grpc.Server, secHelper crypto.Peer) {
 	//get user mode
 	userRunsCC := false
@@ -759,14 +1570,14 @@ func registerChaincodeSupport(chainname chaincode.ChainName, grpcServer *grpc.Se
 	//Now that chaincode is initialized, register all system chaincodes.
 	system_chaincode.RegisterSysCCs()
 
-	pb.RegisterChaincodeSupportServer(grpcServer, ccSrv)
+	comm.RegisterService(grpcServer, pb.ChaincodeSupportServiceDesc, ccSrv)
 }
 
 func checkChaincodeCmdParams(cmd *cobra.Command) (err error) {
 
 	if chaincodeName == undefinedParamValue {
 		if chaincodePath == undefinedParamValue {
-			err = fmt.Errorf("Must supply value for %s path parameter.\n", chainFuncName)
+			err = usageError(fmt.Sprintf("Must supply value for %s path parameter.", chainFuncName))
 			return
 		}
 	}
@@ -780,12 +1591,12 @@ func checkChaincodeCmdParams(cmd *cobra.Command) (err error) {
 		var f interface{}
 		err = json.Unmarshal([]byte(chaincodeCtorJSON), &f)
 		if err != nil {
-			err = fmt.Errorf("Chaincode argument error: %s", err)
+			err = usageError(fmt.Sprintf("Chaincode argument error: %s", err))
 			return
 		}
 		m := f.(map[string]interface{})
 		if len(m) != 2 {
-			err = fmt.Errorf("Non-empty JSON chaincode parameters must contain exactly 2 keys - 'Function' and 'Args'")
+			err = usageError("Non-empty JSON chaincode parameters must contain exactly 2 keys - 'Function' and 'Args'")
 			return
 		}
 		for k := range m {
@@ -793,12 +1604,12 @@ func checkChaincodeCmdParams(cmd *cobra.Command) (err error) {
 			case "function":
 			case "args":
 			default:
-				err = fmt.Errorf("Illegal chaincode key '%s' - must be either 'Function' or 'Args'", k)
+				err = usageError(fmt.Sprintf("Illegal chaincode key '%s' - must be either 'Function' or 'Args'", k))
 				return
 			}
 		}
 	} else {
-		err = errors.New("Empty JSON chaincode parameters must contain exactly 2 keys - 'Function' and 'Args'")
+		err = usageError("Empty JSON chaincode parameters must contain exactly 2 keys - 'Function' and 'Args'")
 		return
 	}
 
@@ -806,7 +1617,7 @@ func checkChaincodeCmdParams(cmd *cobra.Command) (err error) {
 		var f interface{}
 		err = json.Unmarshal([]byte(chaincodeAttributesJSON), &f)
 		if err != nil {
-			err = fmt.Errorf("Chaincode argument error: %s", err)
+			err = usageError(fmt.Sprintf("Chaincode argument error: %s", err))
 			return
 		}
 	}
@@ -817,7 +1628,7 @@ func checkChaincodeCmdParams(cmd *cobra.Command) (err error) {
 func getDevopsClient(cmd *cobra.Command) (pb.DevopsClient, error) {
 	clientConn, err := peer.NewPeerClientConnection()
 	if err != nil {
-		return nil, fmt.Errorf("Error trying to connect to local peer: %s", err)
+		return nil, connectionError(err)
 	}
 	devopsClient := pb.NewDevopsClient(clientConn)
 	return devopsClient, nil
@@ -827,36 +1638,59 @@ func getDevopsClient(cmd *cobra.Command) (pb.DevopsClient, error) {
 // (hash) is printed to STDOUT for use by subsequent chaincode-related CLI
 // commands.
 func chaincodeDeploy(cmd *cobra.Command, args []string) (err error) {
-	if err = checkChaincodeCmdParams(cmd); err != nil {
+	// The spec of a package replaces those of --path and --ctor
+	var packaged *pb.ChaincodeSpec
+	if chaincodePackageFile != "" {
+		var cds *pb.ChaincodeDeploymentSpec
+		if cds, err = readChaincodePackage(chaincodePackageFile); err != nil {
+			return
+		}
+		packaged = cds.ChaincodeSpec
+	} else if err = checkChaincodeCmdParams(cmd); err != nil {
 		return
 	}
 	devopsClient, err := getDevopsClient(cmd)
 	if err != nil {
-		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
 		return
 	}
 	// Build the spec
 	input := &pb.ChaincodeInput{}
 	if err = json.Unmarshal([]byte(chaincodeCtorJSON), &input); err != nil {
-		err = fmt.Errorf("Chaincode argument error: %s", err)
+		err = usageError(fmt.Sprintf("Chaincode argument error: %s", err))
 		return
 	}
 
 	var attributes []string
 	if err = json.Unmarshal([]byte(chaincodeAttributesJSON), &attributes); err != nil {
-		err = fmt.Errorf("Chaincode argument error: %s", err)
+		err = usageError(fmt.Sprintf("Chaincode argument error: %s", err))
+		return
+	}
+
+	var metadata map[string]string
+	if err = json.Unmarshal([]byte(chaincodeMetadataJSON), &metadata); err != nil {
+		err = usageError(fmt.Sprintf("Chaincode metadata error: %s", err))
 		return
 	}
 
 	chaincodeLang = strings.ToUpper(chaincodeLang)
 	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[chaincodeLang]),
-		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath, Name: chaincodeName}, CtorMsg: input, Attributes: attributes}
+		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath, Name: chaincodeName}, CtorMsg: input, Attributes: attributes,
+		MetadataEntries: metadata}
+	if packaged != nil {
+		spec = proto.Clone(packaged).(*pb.ChaincodeSpec)
+		spec.MetadataEntries = metadata
+	}
+	extraApprovals, err := readApprovals()
+	if err != nil {
+		return
+	}
+	spec.Approvals = append(spec.Approvals, extraApprovals...)
 
 	// If security is enabled, add client login token
 	if core.SecurityEnabled() {
 		logger.Debug("Security is enabled. Include security context in deploy spec")
 		if chaincodeUsr == undefinedParamValue {
-			err = errors.New("Must supply username for chaincode when security is enabled")
+			err = usageError("Must supply username for chaincode when security is enabled")
 			return
 		}
 
@@ -902,12 +1736,18 @@ func chaincodeDeploy(cmd *cobra.Command, args []string) (err error) {
 
 	chaincodeDeploymentSpec, err := devopsClient.Deploy(context.Background(), spec)
 	if err != nil {
-		err = fmt.Errorf("Error building %s: %s\n", chainFuncName, err)
+		err = peerError(fmt.Sprintf("Error building %s", chainFuncName), err)
 		return
 	}
 	logger.Infof("Deploy result: %s", chaincodeDeploymentSpec.ChaincodeSpec)
-	fmt.Println(chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name)
-	return nil
+	name := chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name
+	if packaged != nil && name != packaged.ChaincodeID.Name {
+		err = fmt.Errorf("The peer deployed %s %s built from its sources of %s, not %s of the package", chainFuncName, name, packaged.ChaincodeID.Path, packaged.ChaincodeID.Name)
+		return
+	}
+	return printResult(map[string]string{"name": name}, func() {
+		fmt.Println(name)
+	})
 }
 
 func chaincodeInvoke(cmd *cobra.Command, args []string) error {
@@ -931,36 +1771,42 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 	}
 
 	if chaincodeName == "" {
-		err = errors.New("Name not given for invoke/query")
+		err = usageError("Name not given for invoke/query")
 		return
 	}
 
 	devopsClient, err := getDevopsClient(cmd)
 	if err != nil {
-		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
 		return
 	}
 	// Build the spec
 	input := &pb.ChaincodeInput{}
 	if err = json.Unmarshal([]byte(chaincodeCtorJSON), &input); err != nil {
-		err = fmt.Errorf("Chaincode argument error: %s", err)
+		err = usageError(fmt.Sprintf("Chaincode argument error: %s", err))
 		return
 	}
 
 	var attributes []string
 	if err = json.Unmarshal([]byte(chaincodeAttributesJSON), &attributes); err != nil {
-		err = fmt.Errorf("Chaincode argument error: %s", err)
+		err = usageError(fmt.Sprintf("Chaincode argument error: %s", err))
+		return
+	}
+
+	var metadata map[string]string
+	if err = json.Unmarshal([]byte(chaincodeMetadataJSON), &metadata); err != nil {
+		err = usageError(fmt.Sprintf("Chaincode metadata error: %s", err))
 		return
 	}
 
 	chaincodeLang = strings.ToUpper(chaincodeLang)
 	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[chaincodeLang]),
-		ChaincodeID: &pb.ChaincodeID{Name: chaincodeName}, CtorMsg: input, Attributes: attributes}
+		ChaincodeID: &pb.ChaincodeID{Name: chaincodeName}, CtorMsg: input, Attributes: attributes,
+		MetadataEntries: metadata}
 
 	// If security is enabled, add client login token
 	if core.SecurityEnabled() {
 		if chaincodeUsr == undefinedParamValue {
-			err = errors.New("Must supply username for chaincode when security is enabled")
+			err = usageError("Must supply username for chaincode when security is enabled")
 			return
 		}
 
@@ -1010,6 +1856,15 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 		invocation.IdGenerationAlg = customIDGenAlg
 	}
 
+	// Subscribe before submitting, so that the commit cannot be missed
+	var watcher *commitWatcher
+	if invoke && chaincodeWaitForCommit {
+		if watcher, err = watchCommits(); err != nil {
+			return
+		}
+		defer watcher.stop()
+	}
+
 	var resp *pb.Response
 	if invoke {
 		resp, err = devopsClient.Invoke(context.Background(), invocation)
@@ -1019,33 +1874,65 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 
 	if err != nil {
 		if invoke {
-			err = fmt.Errorf("Error invoking %s: %s\n", chainFuncName, err)
+			err = peerError(fmt.Sprintf("Error invoking %s", chainFuncName), err)
 		} else {
-			err = fmt.Errorf("Error querying %s: %s\n", chainFuncName, err)
+			err = peerError(fmt.Sprintf("Error querying %s", chainFuncName), err)
 		}
 		return
 	}
 	if invoke {
 		transactionID := string(resp.Msg)
 		logger.Infof("Successfully invoked transaction: %s(%s)", invocation, transactionID)
-		fmt.Println(transactionID)
-	} else {
-		logger.Infof("Successfully queried transaction: %s", invocation)
-		if resp != nil {
-			if chaincodeQueryRaw {
-				if chaincodeQueryHex {
-					err = errors.New("Options --raw (-r) and --hex (-x) are not compatible\n")
-					return
-				}
-				os.Stdout.Write(resp.Msg)
-			} else {
-				if chaincodeQueryHex {
-					fmt.Printf("%x\n", resp.Msg)
-				} else {
-					fmt.Println(string(resp.Msg))
-				}
-			}
+		if watcher != nil {
+			return waitForCommit(watcher, transactionID)
 		}
+		return printResult(map[string]string{"txid": transactionID}, func() {
+			fmt.Println(transactionID)
+		})
+	}
+
+	logger.Infof("Successfully queried transaction: %s", invocation)
+	if resp == nil {
+		return nil
+	}
+	if chaincodeQueryRaw {
+		if chaincodeQueryHex {
+			return usageError("Options --raw (-r) and --hex (-x) are not compatible")
+		}
+		if outputFormat != outputText {
+			return usageError("Option --raw (-r) is only supported with the text output")
+		}
+		os.Stdout.Write(resp.Msg)
+		return nil
+	}
+	result := string(resp.Msg)
+	if chaincodeQueryHex {
+		result = fmt.Sprintf("%x", resp.Msg)
+	}
+	return printResult(map[string]string{"result": result}, func() {
+		fmt.Println(result)
+	})
+}
+
+// waitForCommit waits until the transaction txid is final and prints the
+// result. The command fails if the transaction is rejected or the wait
+// times out.
+func waitForCommit(watcher *commitWatcher, txid string) error {
+	result, err := watcher.wait(txid, chaincodeWaitTimeout)
+	if err != nil {
+		return err
+	}
+	if err = printResult(result, func() {
+		fmt.Println(txid)
+		fmt.Println(result.Status)
+	}); err != nil {
+		return err
+	}
+	switch result.Status {
+	case txRejected:
+		return &exitError{code: exitRefused, err: fmt.Errorf("Transaction %s was rejected: %s", txid, result.Error)}
+	case txTimedOut:
+		return &exitError{code: exitTimedOut, err: fmt.Errorf("Timed out after %s waiting for transaction %s to be committed", chaincodeWaitTimeout, txid)}
 	}
 	return nil
 }
@@ -1055,20 +1942,270 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 func networkList() (err error) {
 	clientConn, err := peer.NewPeerClientConnection()
 	if err != nil {
-		err = fmt.Errorf("Error trying to connect to local peer: %s", err)
+		err = connectionError(err)
 		return
 	}
 	openchainClient := pb.NewOpenchainClient(clientConn)
 	peers, err := openchainClient.GetPeers(context.Background(), &google_protobuf.Empty{})
 
 	if err != nil {
-		err = fmt.Errorf("Error trying to get peers: %s", err)
+		err = peerError("Error trying to get peers", err)
 		return
 	}
 
-	jsonOutput, _ := json.Marshal(peers)
-	fmt.Println(string(jsonOutput))
-	return nil
+	return printResult(peers, func() {
+		jsonOutput, _ := json.Marshal(peers)
+		fmt.Println(string(jsonOutput))
+	})
+}
+
+// Show the target peer node's view of the network
+func networkMap() (err error) {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		err = connectionError(err)
+		return
+	}
+	openchainClient := pb.NewOpenchainClient(clientConn)
+	networkMap, err := openchainClient.GetNetworkMap(context.Background(), &google_protobuf.Empty{})
+
+	if err != nil {
+		err = peerError("Error trying to get the network map", err)
+		return
+	}
+
+	return printResult(networkMap, func() {
+		jsonOutput, _ := json.Marshal(networkMap)
+		fmt.Println(string(jsonOutput))
+	})
+}
+
+// peerLiveness describes a peer known to the target peer node
+type peerLiveness struct {
+	ID      string `json:"id,omitempty"`
+	Address string `json:"address"`
+	Type    string `json:"type,omitempty"`
+	// self, connected, stale or disconnected
+	State string `json:"state"`
+	// When a message last arrived from the peer, unset if not connected
+	LastSeen        string `json:"lastSeen,omitempty"`
+	ProtocolVersion uint32 `json:"protocolVersion,omitempty"`
+	Drops           int32  `json:"drops,omitempty"`
+}
+
+// livenessOf lists the peers of networkMap, this peer first. A connection
+// on which nothing arrived for half the health timeout is reported stale,
+// as the peer closes it when nothing arrives for the whole timeout.
+func livenessOf(networkMap *pb.NetworkMap, now time.Time) []peerLiveness {
+	var peers []peerLiveness
+	if self := networkMap.Self; self != nil {
+		peers = append(peers, peerLiveness{ID: self.ID.Name, Address: self.Address, Type: self.Type.String(), State: "self"})
+	}
+	staleAfter := viper.GetDuration("peer.connections.health.timeout") / 2
+	for _, connection := range networkMap.Connections {
+		idle := time.Duration(connection.IdleSeconds) * time.Second
+		state := "connected"
+		if staleAfter > 0 && idle >= staleAfter {
+			state = "stale"
+		}
+		peers = append(peers, peerLiveness{
+			ID:              connection.Endpoint.ID.Name,
+			Address:         connection.Endpoint.Address,
+			Type:            connection.Endpoint.Type.String(),
+			State:           state,
+			LastSeen:        now.Add(-idle).UTC().Format(time.RFC3339),
+			ProtocolVersion: connection.ProtocolVersion,
+			Drops:           connection.Drops,
+		})
+	}
+	for _, address := range networkMap.Disconnected {
+		peers = append(peers, peerLiveness{Address: address, State: "disconnected"})
+	}
+	return peers
+}
+
+// List the peers known to the target peer node and whether they are alive
+func networkPeers() (err error) {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		err = connectionError(err)
+		return
+	}
+	openchainClient := pb.NewOpenchainClient(clientConn)
+	networkMap, err := openchainClient.GetNetworkMap(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		err = peerError("Error trying to get the network map", err)
+		return
+	}
+
+	peers := livenessOf(networkMap, time.Now())
+	return printResult(peers, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tADDRESS\tTYPE\tSTATE\tLAST SEEN\tPROTOCOL\tDROPS")
+		for _, p := range peers {
+			id, kind, lastSeen, protocol := "-", "-", "-", "-"
+			if p.ID != "" {
+				id, kind = p.ID, p.Type
+			}
+			if p.LastSeen != "" {
+				lastSeen = p.LastSeen
+			}
+			if p.ProtocolVersion != 0 {
+				protocol = fmt.Sprintf("%d", p.ProtocolVersion)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", id, p.Address, kind, p.State, lastSeen, protocol, p.Drops)
+		}
+		w.Flush()
+	})
+}
+
+// List the chaincodes deployed on the blockchain
+func chaincodeList() (err error) {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		err = connectionError(err)
+		return
+	}
+	openchainClient := pb.NewOpenchainClient(clientConn)
+	chaincodes, err := openchainClient.GetChaincodes(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		err = peerError(fmt.Sprintf("Error trying to list the %ss", chainFuncName), err)
+		return
+	}
+
+	return printResult(chaincodes, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPATH\tTYPE\tBLOCK\tDEPLOYED\tRUNNING")
+		for _, info := range chaincodes.Chaincodes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%t\n", info.ChaincodeID.Name, info.ChaincodeID.Path, info.Type,
+				info.BlockNumber, formatTimestamp(info.Timestamp), info.Running)
+		}
+		w.Flush()
+	})
+}
+
+// Describe the deployment of the chaincode given with -n
+func chaincodeDescribe() (err error) {
+	if chaincodeName == undefinedParamValue || chaincodeName == "" {
+		err = usageError(fmt.Sprintf("Must supply the name of the %s with -n.", chainFuncName))
+		return
+	}
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		err = connectionError(err)
+		return
+	}
+	openchainClient := pb.NewOpenchainClient(clientConn)
+	info, err := openchainClient.GetChaincode(context.Background(), &pb.ChaincodeID{Name: chaincodeName})
+	if err != nil {
+		err = peerError(fmt.Sprintf("Error trying to describe %s %s", chainFuncName, chaincodeName), err)
+		return
+	}
+
+	return printResult(info, func() {
+		jsonOutput, _ := json.Marshal(info)
+		fmt.Println(string(jsonOutput))
+	})
+}
+
+// Show the height of the blockchain of the target peer node
+func ledgerHeight() (err error) {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		err = connectionError(err)
+		return
+	}
+	openchainClient := pb.NewOpenchainClient(clientConn)
+	info, err := openchainClient.GetBlockchainInfo(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		err = peerError("Error trying to get the height of the blockchain", err)
+		return
+	}
+
+	return printResult(info, func() {
+		fmt.Printf("Height: %d\n", info.Height)
+		fmt.Printf("Current block hash: %x\n", info.CurrentBlockHash)
+		fmt.Printf("Previous block hash: %x\n", info.PreviousBlockHash)
+	})
+}
+
+// Show a block of the blockchain of the target peer node, by number or hash
+func ledgerBlock(args []string) (err error) {
+	if len(args) != 1 {
+		err = usageError("Must supply the number or the hash of the block.")
+		return
+	}
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		err = connectionError(err)
+		return
+	}
+	openchainClient := pb.NewOpenchainClient(clientConn)
+
+	var block *pb.Block
+	if number, perr := strconv.ParseUint(args[0], 10, 64); perr == nil && len(args[0]) <= 20 {
+		block, err = openchainClient.GetBlockByNumber(context.Background(), &pb.BlockNumber{Number: number})
+	} else {
+		hash, herr := parseBlockHash(args[0])
+		if herr != nil {
+			err = usageError(herr.Error())
+			return
+		}
+		block, err = openchainClient.GetBlockByHash(context.Background(), &pb.BlockHash{Hash: hash})
+	}
+	if err != nil {
+		err = peerError(fmt.Sprintf("Error trying to get block %s", args[0]), err)
+		return
+	}
+
+	return printResult(block, func() {
+		jsonOutput, _ := json.Marshal(block)
+		fmt.Println(string(jsonOutput))
+	})
+}
+
+// parseBlockHash decodes a block hash given in hexadecimal, as printed by
+// ledger height, or in base64, as in the JSON of the REST API
+func parseBlockHash(arg string) ([]byte, error) {
+	if hash, err := hex.DecodeString(arg); err == nil {
+		return hash, nil
+	}
+	if hash, err := base64.StdEncoding.DecodeString(arg); err == nil {
+		return hash, nil
+	}
+	return nil, fmt.Errorf("%s is neither a block number nor a block hash in hexadecimal or base64", arg)
+}
+
+// Show a transaction of the blockchain of the target peer node
+func ledgerTx(args []string) (err error) {
+	if len(args) != 1 {
+		err = usageError("Must supply the ID of the transaction.")
+		return
+	}
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		err = connectionError(err)
+		return
+	}
+	openchainClient := pb.NewOpenchainClient(clientConn)
+	tx, err := openchainClient.GetTransaction(context.Background(), &pb.TransactionUUID{Uuid: args[0]})
+	if err != nil {
+		err = peerError(fmt.Sprintf("Error trying to get transaction %s", args[0]), err)
+		return
+	}
+
+	return printResult(tx, func() {
+		jsonOutput, _ := json.Marshal(tx)
+		fmt.Println(string(jsonOutput))
+	})
+}
+
+// formatTimestamp formats a transaction timestamp for display
+func formatTimestamp(timestamp *google_protobuf.Timestamp) string {
+	if timestamp == nil {
+		return "-"
+	}
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC().Format(time.RFC3339)
 }
 
 func writePid(fileName string, pid int) error {
@@ -0,0 +1,283 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Transactions signed away from the peer, e.g. on an air-gapped machine or
+// with an HSM, so that the machine talking to the peer never holds the key
+// of the signer: buildtx has the peer build the unsigned transaction, signtx
+// signs it with a key file without contacting the peer, and submittx submits
+// it, optionally with a signature made by other means.
+
+var (
+	signerCertFile  string
+	signerKeyFile   string
+	signedSignature string
+)
+
+var chaincodeBuildTxCmd = &cobra.Command{
+	Use:   "buildtx <deploy|invoke|query> <file>",
+	Short: "Builds an unsigned transaction to sign away from the peer.",
+	Long:  fmt.Sprintf(`Has the peer build the unsigned transaction deploying the %[1]s at --path, or invoking or querying the %[1]s named with --name, for the holder of the certificate of --cert, and writes it to file. The transaction is signed with signtx, or by signing the digest printed, the hash of the transaction, and submitted with submittx.`, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeBuildTx(cmd, args)
+	},
+}
+
+var chaincodeSignTxCmd = &cobra.Command{
+	Use:   "signtx <file> [<signed file>]",
+	Short: "Signs a transaction built with buildtx.",
+	Long:  `Signs the transaction of file with the key of --key, without contacting the peer, and writes it to signed file, or replaces file. The digest of the transaction is checked against its content, with the hash algorithm of security.hashAlgorithm and security.level.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeSignTx(args)
+	},
+}
+
+var chaincodeSubmitTxCmd = &cobra.Command{
+	Use:   "submittx <file>",
+	Short: "Submits a transaction signed away from the peer.",
+	Long:  `Submits the transaction of file, signed with signtx or with the signature of --signature, and prints its ID, or the result of a query.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeSubmitTx(cmd, args)
+	},
+}
+
+func addOfflineCommands() {
+	chaincodeBuildTxCmd.Flags().StringVar(&signerCertFile, "cert", "", "PEM file of the enrollment certificate of the signer")
+	chaincodeSignTxCmd.Flags().StringVar(&signerKeyFile, "key", "", "PEM file of the private key of the signer")
+	chaincodeSubmitTxCmd.Flags().StringVar(&signedSignature, "signature", "", "Base64 encoded ASN.1 ECDSA signature of the digest of the transaction, made by other means than signtx")
+
+	chaincodeCmd.AddCommand(chaincodeBuildTxCmd)
+	chaincodeCmd.AddCommand(chaincodeSignTxCmd)
+	chaincodeCmd.AddCommand(chaincodeSubmitTxCmd)
+}
+
+// offlineTxResult is the result of the buildtx, signtx and submittx commands
+type offlineTxResult struct {
+	Txid   string `json:"txid"`
+	Digest string `json:"digest,omitempty"`
+	File   string `json:"file,omitempty"`
+	Result string `json:"result,omitempty"`
+}
+
+func chaincodeBuildTx(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return usageError("Must supply deploy, invoke or query and the file to write the transaction to.")
+	}
+	request := &pb.UnsignedTransactionRequest{}
+	switch args[0] {
+	case "deploy":
+		if chaincodePath == undefinedParamValue || chaincodePath == "" {
+			return usageError(fmt.Sprintf("Must supply the path of the %s with -p.", chainFuncName))
+		}
+		request.Type = pb.Transaction_CHAINCODE_DEPLOY
+	case "invoke", "query":
+		if chaincodeName == "" {
+			return usageError("Name not given for invoke/query")
+		}
+		request.Type = pb.Transaction_CHAINCODE_INVOKE
+		if args[0] == "query" {
+			request.Type = pb.Transaction_CHAINCODE_QUERY
+		}
+	default:
+		return usageError("Must supply deploy, invoke or query.")
+	}
+
+	input := &pb.ChaincodeInput{}
+	if err := json.Unmarshal([]byte(chaincodeCtorJSON), &input); err != nil {
+		return usageError(fmt.Sprintf("Chaincode argument error: %s", err))
+	}
+	var attributes []string
+	if err := json.Unmarshal([]byte(chaincodeAttributesJSON), &attributes); err != nil {
+		return usageError(fmt.Sprintf("Chaincode argument error: %s", err))
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(chaincodeMetadataJSON), &metadata); err != nil {
+		return usageError(fmt.Sprintf("Chaincode metadata error: %s", err))
+	}
+	lang := strings.ToUpper(chaincodeLang)
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[lang]),
+		ChaincodeID: &pb.ChaincodeID{Name: chaincodeName}, CtorMsg: input, Attributes: attributes,
+		MetadataEntries: metadata}
+	if request.Type == pb.Transaction_CHAINCODE_DEPLOY {
+		spec.ChaincodeID = &pb.ChaincodeID{Path: chaincodePath, Name: chaincodeName}
+		approvals, err := readApprovals()
+		if err != nil {
+			return err
+		}
+		spec.Approvals = approvals
+	}
+	request.InvocationSpec = &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+
+	if signerCertFile != "" {
+		cert, err := readCertificate(signerCertFile, "signer")
+		if err != nil {
+			return err
+		}
+		request.Cert = cert
+	}
+
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		return err
+	}
+	unsigned, err := devopsClient.BuildTransaction(context.Background(), request)
+	if err != nil {
+		return peerError("Error building the transaction", err)
+	}
+	if err = writeUnsignedTransaction(args[1], unsigned); err != nil {
+		return err
+	}
+	result := offlineTxResult{Txid: unsigned.Transaction.Uuid, Digest: fmt.Sprintf("%x", unsigned.Digest), File: args[1]}
+	return printResult(result, func() {
+		fmt.Println(result.Txid)
+		fmt.Println(result.Digest)
+	})
+}
+
+func chaincodeSignTx(args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return usageError("Must supply the transaction file, and optionally the file to write the signed transaction to.")
+	}
+	unsigned, err := readUnsignedTransaction(args[0])
+	if err != nil {
+		return err
+	}
+	tx := unsigned.Transaction
+
+	// Sign the transaction of the file, not a digest of something else
+	tx.Signature = nil
+	raw, err := proto.Marshal(tx)
+	if err != nil {
+		return fmt.Errorf("Error encoding the transaction: %s", err)
+	}
+	if !bytes.Equal(primitives.Hash(raw), unsigned.Digest) {
+		return fmt.Errorf("The digest of %s does not match its transaction, check security.hashAlgorithm and security.level", args[0])
+	}
+
+	key, err := readPrivateKey(signerKeyFile, "signer")
+	if err != nil {
+		return err
+	}
+	public, ok := key.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("Unsupported key type %T, transactions are signed with ECDSA", key.Public())
+	}
+	if len(tx.Cert) != 0 {
+		cert, err := x509.ParseCertificate(tx.Cert)
+		if err != nil {
+			return fmt.Errorf("Invalid certificate in %s: %s", args[0], err)
+		}
+		certPublic, ok := cert.PublicKey.(*ecdsa.PublicKey)
+		if !ok || certPublic.X.Cmp(public.X) != 0 || certPublic.Y.Cmp(public.Y) != 0 {
+			return fmt.Errorf("The key of %s is not that of the certificate of the transaction", signerKeyFile)
+		}
+	}
+	if tx.Signature, err = key.Sign(rand.Reader, unsigned.Digest, nil); err != nil {
+		return fmt.Errorf("Error signing the transaction: %s", err)
+	}
+
+	file := args[len(args)-1]
+	if err = writeUnsignedTransaction(file, unsigned); err != nil {
+		return err
+	}
+	result := offlineTxResult{Txid: tx.Uuid, File: file}
+	return printResult(result, func() {
+		fmt.Println(result.Txid)
+	})
+}
+
+func chaincodeSubmitTx(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return usageError("Must supply the transaction file.")
+	}
+	unsigned, err := readUnsignedTransaction(args[0])
+	if err != nil {
+		return err
+	}
+	tx := unsigned.Transaction
+	if signedSignature != "" {
+		if tx.Signature, err = base64.StdEncoding.DecodeString(signedSignature); err != nil {
+			return usageError(fmt.Sprintf("Invalid signature: %s", err))
+		}
+	}
+
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		return err
+	}
+	resp, err := devopsClient.SubmitTransaction(context.Background(), tx)
+	if err != nil {
+		return peerError("Error submitting the transaction", err)
+	}
+	logger.Infof("Successfully submitted transaction %s", tx.Uuid)
+	result := offlineTxResult{Txid: tx.Uuid}
+	if tx.Type == pb.Transaction_CHAINCODE_QUERY {
+		result.Result = string(resp.Msg)
+	}
+	return printResult(result, func() {
+		if tx.Type == pb.Transaction_CHAINCODE_QUERY {
+			fmt.Println(result.Result)
+		} else {
+			fmt.Println(result.Txid)
+		}
+	})
+}
+
+// readUnsignedTransaction reads a transaction written by buildtx or signtx
+func readUnsignedTransaction(file string) (*pb.UnsignedTransaction, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the transaction: %s", err)
+	}
+	unsigned := &pb.UnsignedTransaction{}
+	if err = proto.Unmarshal(raw, unsigned); err != nil {
+		return nil, fmt.Errorf("Invalid transaction %s: %s", file, err)
+	}
+	if unsigned.Transaction == nil {
+		return nil, fmt.Errorf("Invalid transaction %s: no transaction", file)
+	}
+	return unsigned, nil
+}
+
+func writeUnsignedTransaction(file string, unsigned *pb.UnsignedTransaction) error {
+	raw, err := proto.Marshal(unsigned)
+	if err != nil {
+		return fmt.Errorf("Error encoding the transaction: %s", err)
+	}
+	if err = ioutil.WriteFile(file, raw, 0644); err != nil {
+		return fmt.Errorf("Error writing the transaction: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"gopkg.in/yaml.v2"
+)
+
+// Formats of the results printed by the commands, chosen with --output
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+var outputFormat string
+
+// resultPrinted tells whether the command printed its result
+var resultPrinted bool
+
+// Exit codes of the peer commands
+const (
+	exitFailure     = 1 // the command failed
+	exitUsage       = 2 // invalid arguments
+	exitUnavailable = 3 // the peer could not be reached
+	exitRefused     = 4 // the peer refused the operation
+	exitTimedOut    = 5 // waiting for the peer timed out
+)
+
+// exitError is the error of a command exiting with code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// usageError returns the error of a command given invalid arguments
+func usageError(message string) error {
+	return &exitError{code: exitUsage, err: errors.New(message)}
+}
+
+// connectionError returns the error of a command failing to connect to the
+// local peer
+func connectionError(err error) error {
+	return &exitError{code: exitUnavailable, err: fmt.Errorf("Error trying to connect to local peer: %s", err)}
+}
+
+// peerError returns the error of a command whose call to the peer failed
+// with err, described by message
+func peerError(message string, err error) error {
+	code := exitFailure
+	switch grpc.Code(err) {
+	case codes.Unavailable:
+		code = exitUnavailable
+	case codes.Unauthenticated, codes.PermissionDenied:
+		code = exitRefused
+	}
+	return &exitError{code: code, err: fmt.Errorf("%s: %s", message, err)}
+}
+
+// exitCode returns the exit code of a command failing with err
+func exitCode(err error) int {
+	if e, ok := err.(*exitError); ok {
+		return e.code
+	}
+	return exitFailure
+}
+
+// errorResult is the result of a failed command
+type errorResult struct {
+	Error    string `json:"error"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// userResult is the result of the commands logging a user in
+type userResult struct {
+	User string `json:"user"`
+	File string `json:"file,omitempty"`
+}
+
+// checkOutputFormat exits if the output format is unknown
+func checkOutputFormat() {
+	switch outputFormat {
+	case outputText, outputJSON, outputYAML:
+	default:
+		err := usageError(fmt.Sprintf("Unknown output format %s, must be %s, %s or %s", outputFormat, outputText, outputJSON, outputYAML))
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		exit(err)
+	}
+}
+
+// printText prints text with the text output and nothing with the other
+// outputs, for what has no encoded form, such as the status printed next to
+// an error that the JSON and YAML outputs already describe
+func printText(text func()) {
+	if outputFormat == outputText {
+		text()
+	}
+}
+
+// printResult prints the result of a command, by text with the text output,
+// or else encoded in the output format. Protocol buffer messages are
+// encoded as jsonpb does and other values as encoding/json does, and the
+// YAML output has the field names of the JSON output.
+func printResult(result interface{}, text func()) error {
+	resultPrinted = true
+	if outputFormat == outputText {
+		text()
+		return nil
+	}
+
+	var raw []byte
+	var err error
+	if message, ok := result.(proto.Message); ok {
+		var buf bytes.Buffer
+		err = (&jsonpb.Marshaler{Indent: "  "}).Marshal(&buf, message)
+		raw = buf.Bytes()
+	} else {
+		raw, err = json.MarshalIndent(result, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("Error encoding the result: %s", err)
+	}
+
+	switch outputFormat {
+	case outputJSON:
+		fmt.Println(string(raw))
+	case outputYAML:
+		var value interface{}
+		if err = yaml.Unmarshal(raw, &value); err != nil {
+			return fmt.Errorf("Error encoding the result: %s", err)
+		}
+		if raw, err = yaml.Marshal(value); err != nil {
+			return fmt.Errorf("Error encoding the result: %s", err)
+		}
+		fmt.Print(string(raw))
+	}
+	return nil
+}
+
+// exit ends the peer command failing with err, whose description is printed
+// as the result in the JSON and YAML outputs unless the command printed one.
+// In the shell only the command ends.
+func exit(err error) {
+	code := exitCode(err)
+	if outputFormat != outputText && !resultPrinted {
+		printResult(&errorResult{Error: err.Error(), ExitCode: code}, nil)
+	}
+	if shellActive {
+		panic(shellExit{code})
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,187 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/cobra"
+
+	"github.com/hyperledger/fabric/core/chaincode/platforms/golang"
+	"github.com/hyperledger/fabric/core/container"
+	"github.com/hyperledger/fabric/core/quorum"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// The package deployed with peer chaincode deploy --package
+var chaincodePackageFile string
+
+var chaincodePackageCmd = &cobra.Command{
+	Use:   "package <file>",
+	Short: fmt.Sprintf("Packages the specified %s for signing.", chainFuncName),
+	Long:  fmt.Sprintf(`Packages the %s at --path with the constructor given with --ctor into file, as the peer would to deploy it, and prints its name. The package is the same wherever it is built from the same sources, and is signed by the administrators with signpackage before it is deployed with deploy --package.`, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodePackage(args)
+	},
+}
+
+var chaincodeSignPackageCmd = &cobra.Command{
+	Use:   "signpackage <file> [<signed file>]",
+	Short: fmt.Sprintf("Approves the deployment of a %s package as an administrator.", chainFuncName),
+	Long:  fmt.Sprintf(`Checks that the name of the %s in the package is that of its code, and adds the approval of its deployment by the administrator of --key and --cert, see peer.admin.quorum. The approvals already in the package are kept, so that the package can be passed from one administrator to the next. The signed package is written to signed file, or replaces file.`, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeSignPackage(args)
+	},
+}
+
+func addPackageCommands() {
+	chaincodeDeployCmd.Flags().StringVar(&chaincodePackageFile, "package", "", "Package built with package and signed with signpackage to deploy, instead of --path and --ctor")
+	chaincodeSignPackageCmd.Flags().StringVar(&approvalKeyFile, "key", "", "PEM file of the private key of the administrator")
+	chaincodeSignPackageCmd.Flags().StringVar(&approvalCertFile, "cert", "", "PEM file of the certificate of the administrator")
+
+	chaincodeCmd.AddCommand(chaincodePackageCmd)
+	chaincodeCmd.AddCommand(chaincodeSignPackageCmd)
+}
+
+// packageResult is the result of the package and signpackage commands
+type packageResult struct {
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	Approvals int    `json:"approvals"`
+}
+
+func chaincodePackage(args []string) error {
+	if len(args) != 1 {
+		return usageError("Must supply the file to write the package to.")
+	}
+	if chaincodePath == undefinedParamValue || chaincodePath == "" {
+		return usageError(fmt.Sprintf("Must supply the path of the %s with -p.", chainFuncName))
+	}
+	input := &pb.ChaincodeInput{}
+	if err := json.Unmarshal([]byte(chaincodeCtorJSON), &input); err != nil {
+		return usageError(fmt.Sprintf("Chaincode argument error: %s", err))
+	}
+	var attributes []string
+	if err := json.Unmarshal([]byte(chaincodeAttributesJSON), &attributes); err != nil {
+		return usageError(fmt.Sprintf("Chaincode argument error: %s", err))
+	}
+
+	lang := strings.ToUpper(chaincodeLang)
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[lang]),
+		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath}, CtorMsg: input, Attributes: attributes}
+	// Sets the name of the chaincode, the hash of its code and constructor
+	codePackage, err := container.GetChaincodePackageBytes(spec)
+	if err != nil {
+		return fmt.Errorf("Error packaging %s %s: %s", chainFuncName, chaincodePath, err)
+	}
+
+	cds := &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, CodePackage: codePackage}
+	if err = writeChaincodePackage(args[0], cds); err != nil {
+		return err
+	}
+	result := packageResult{Name: spec.ChaincodeID.Name, File: args[0]}
+	return printResult(result, func() {
+		fmt.Println(result.Name)
+	})
+}
+
+func chaincodeSignPackage(args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return usageError("Must supply the package file, and optionally the file to write the signed package to.")
+	}
+	cds, err := readChaincodePackage(args[0])
+	if err != nil {
+		return err
+	}
+	spec := cds.ChaincodeSpec
+	name := spec.ChaincodeID.Name
+
+	// Refuse to approve a name which is not that of the code in the package
+	if spec.Type == pb.ChaincodeSpec_GOLANG {
+		hashed, err := golang.HashPackage(spec, cds.CodePackage)
+		if err != nil {
+			return fmt.Errorf("Error checking the package %s: %s", args[0], err)
+		}
+		if hashed != name {
+			return fmt.Errorf("The package %s names %s %s but holds the code of %s", args[0], chainFuncName, name, hashed)
+		}
+	} else {
+		logger.Warningf("Cannot check the name of %s package %s against its code", spec.Type, args[0])
+	}
+
+	key, err := readPrivateKey(approvalKeyFile, "administrator")
+	if err != nil {
+		return err
+	}
+	cert, err := readCertificate(approvalCertFile, "administrator")
+	if err != nil {
+		return err
+	}
+	approval, err := quorum.Approve(key, cert, quorum.OperationDeploy, quorum.DeployPayload(name))
+	if err != nil {
+		return fmt.Errorf("Error approving %s: %s", quorum.OperationDeploy, err)
+	}
+
+	// A new approval by the same administrator replaces the previous one
+	approvals := []*pb.AdminApproval{}
+	for _, previous := range spec.Approvals {
+		if !bytes.Equal(previous.Certificate, approval.Certificate) {
+			approvals = append(approvals, previous)
+		}
+	}
+	spec.Approvals = append(approvals, approval)
+
+	file := args[len(args)-1]
+	if err = writeChaincodePackage(file, cds); err != nil {
+		return err
+	}
+	result := packageResult{Name: name, File: file, Approvals: len(spec.Approvals)}
+	return printResult(result, func() {
+		fmt.Printf("%s approved by %d administrators\n", name, result.Approvals)
+	})
+}
+
+// readChaincodePackage reads a package written by the package command
+func readChaincodePackage(file string) (*pb.ChaincodeDeploymentSpec, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the package: %s", err)
+	}
+	cds := &pb.ChaincodeDeploymentSpec{}
+	if err = proto.Unmarshal(raw, cds); err != nil {
+		return nil, fmt.Errorf("Invalid package %s: %s", file, err)
+	}
+	if cds.ChaincodeSpec == nil || cds.ChaincodeSpec.ChaincodeID == nil || cds.ChaincodeSpec.ChaincodeID.Name == "" {
+		return nil, fmt.Errorf("Invalid package %s: no %s name", file, chainFuncName)
+	}
+	return cds, nil
+}
+
+func writeChaincodePackage(file string, cds *pb.ChaincodeDeploymentSpec) error {
+	raw, err := proto.Marshal(cds)
+	if err != nil {
+		return fmt.Errorf("Error encoding the package: %s", err)
+	}
+	if err = ioutil.WriteFile(file, raw, 0644); err != nil {
+		return fmt.Errorf("Error writing the package: %s", err)
+	}
+	return nil
+}
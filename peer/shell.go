@@ -0,0 +1,497 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"google/protobuf"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/net/context"
+
+	"github.com/hyperledger/fabric/core"
+	"github.com/hyperledger/fabric/core/peer"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const shellFuncName = "shell"
+
+// Number of entries kept in the history file, and of recent blocks whose
+// transactions are offered as txid completions
+const (
+	shellHistorySize  = 1000
+	shellTxidBlocks   = 10
+	shellPromptPrefix = "peer"
+)
+
+// shellActive is set while the shell runs commands, for exit to return to
+// the shell instead of ending the process
+var shellActive bool
+
+// shellExit is raised by exit in the shell, with the exit code of the command
+type shellExit struct {
+	code int
+}
+
+var shellCmd = &cobra.Command{
+	Use:   shellFuncName,
+	Short: "Runs peer commands interactively.",
+	Long: fmt.Sprintf(`Reads peer commands from the terminal and runs them against the peer of the current context, completing commands, flags, chaincode names and transaction IDs with the Tab key. The commands are entered without the leading "peer". The shell also understands:
+
+  use [context]   switches the context of the following commands, or shows it
+  history         lists the commands entered, kept in %s or in the file given by PEER_HISTORY
+  exit, quit      leaves the shell, as does Ctrl-D`, shellHistoryFile()),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		core.LoggingInit(shellFuncName)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if shellActive {
+			return usageError("The shell is already running.")
+		}
+		return runShell(os.Stdin, os.Stdout)
+	},
+}
+
+// shellHistoryFile returns the path of the history file, $PEER_HISTORY or
+// .hyperledger/peer-history in the home directory
+func shellHistoryFile() string {
+	if file := os.Getenv("PEER_HISTORY"); file != "" {
+		return file
+	}
+	return filepath.Join(os.Getenv("HOME"), ".hyperledger", "peer-history")
+}
+
+// shell is an interactive session, with the context chosen by use and the
+// completions fetched from the peer
+type shell struct {
+	context    string
+	history    []string
+	chaincodes []string
+	txids      []string
+	term       *terminal.Terminal
+}
+
+// runShell reads commands from in until exit or the end of the input, with
+// line editing and completion when in is a terminal
+func runShell(in *os.File, out *os.File) error {
+	sh := &shell{context: contextName}
+	sh.history = loadShellHistory()
+	shellActive = true
+	defer func() { shellActive = false }()
+
+	fd := int(in.Fd())
+	if !terminal.IsTerminal(fd) {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			if !sh.runLine(scanner.Text()) {
+				break
+			}
+		}
+		return scanner.Err()
+	}
+
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("Error setting up the terminal: %s", err)
+	}
+	defer terminal.Restore(fd, state)
+	sh.term = terminal.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, out}, sh.prompt())
+	sh.term.AutoCompleteCallback = sh.complete
+
+	for {
+		line, err := sh.term.ReadLine()
+		if err == io.EOF {
+			fmt.Fprint(sh.term, "\n")
+			return nil
+		}
+		if err != nil && err != terminal.ErrPasteIndicator {
+			return err
+		}
+		// The commands print to the standard output, which needs the
+		// terminal out of raw mode
+		terminal.Restore(fd, state)
+		more := sh.runLine(line)
+		if _, err = terminal.MakeRaw(fd); err != nil {
+			return fmt.Errorf("Error setting up the terminal: %s", err)
+		}
+		if !more {
+			return nil
+		}
+		sh.term.SetPrompt(sh.prompt())
+	}
+}
+
+// prompt returns the prompt of the shell, naming the context of the session
+func (sh *shell) prompt() string {
+	if sh.context != "" {
+		return fmt.Sprintf("%s[%s]> ", shellPromptPrefix, sh.context)
+	}
+	return shellPromptPrefix + "> "
+}
+
+// runLine runs a line entered in the shell, returning false to leave it
+func (sh *shell) runLine(line string) bool {
+	args, err := splitShellLine(line)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return true
+	}
+	if len(args) > 0 && args[0] == shellPromptPrefix {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return true
+	}
+	sh.addHistory(strings.TrimSpace(line))
+
+	switch args[0] {
+	case "exit", "quit":
+		return false
+	case "history":
+		for i, entry := range sh.history {
+			fmt.Printf("%5d  %s\n", i+1, entry)
+		}
+		return true
+	case "use":
+		sh.use(args[1:])
+		return true
+	}
+
+	sh.execute(args)
+	// Deployments and invocations change what can be completed
+	sh.chaincodes, sh.txids = nil, nil
+	return true
+}
+
+// use switches the context of the session, without changing the current one
+func (sh *shell) use(args []string) {
+	if len(args) == 0 {
+		if sh.context == "" {
+			fmt.Println("Using the current context")
+		} else {
+			fmt.Printf("Using context %s\n", sh.context)
+		}
+		return
+	}
+	contexts, err := loadContexts()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return
+	}
+	if _, ok := contexts.Contexts[args[0]]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: Context %s does not exist\n", args[0])
+		return
+	}
+	sh.context = args[0]
+}
+
+// execute runs a peer command, with the flags of the previous commands reset
+// and the context of the session unless the command names one. The errors
+// are reported as main does, whose exit ends the command only.
+func (sh *shell) execute(args []string) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(shellExit); !ok {
+				panic(r)
+			}
+		}
+	}()
+
+	resetShellFlags(mainCmd)
+	contextName = sh.context
+	resultPrinted = false
+	mainCmd.SetArgs(args)
+	if err := mainCmd.Execute(); err != nil {
+		exit(err)
+	}
+}
+
+// resetShellFlags sets the flags of cmd and its subcommands back to their
+// defaults. Slices are reset through their variables, as setting them again
+// appends to their values.
+func resetShellFlags(cmd *cobra.Command) {
+	reset := func(flag *pflag.Flag) {
+		if flag.Changed && flag.Value.Type() != "stringSlice" {
+			flag.Value.Set(flag.DefValue)
+		}
+		flag.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetShellFlags(sub)
+	}
+	approvals, eventsChaincodes = nil, nil
+}
+
+// splitShellLine splits a line into arguments as a shell does, on spaces
+// outside of single or double quotes, with backslash escaping the next
+// character outside of single quotes
+func splitShellLine(line string) ([]string, error) {
+	var args []string
+	var arg []rune
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			arg = append(arg, r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg = append(arg, r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, string(arg))
+				arg, inArg = nil, false
+			}
+		default:
+			arg = append(arg, r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("Unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, string(arg))
+	}
+	return args, nil
+}
+
+// loadShellHistory reads the history file, which may not exist yet
+func loadShellHistory() []string {
+	raw, err := ioutil.ReadFile(shellHistoryFile())
+	if err != nil {
+		return nil
+	}
+	var history []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		if line != "" {
+			history = append(history, line)
+		}
+	}
+	return history
+}
+
+// addHistory records a line in the history, and in the history file which
+// keeps the last shellHistorySize lines
+func (sh *shell) addHistory(line string) {
+	if n := len(sh.history); n > 0 && sh.history[n-1] == line {
+		return
+	}
+	sh.history = append(sh.history, line)
+	if len(sh.history) > shellHistorySize {
+		sh.history = sh.history[len(sh.history)-shellHistorySize:]
+	}
+	file := shellHistoryFile()
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		logger.Warning("Error saving the shell history: %s", err)
+		return
+	}
+	raw := strings.Join(sh.history, "\n") + "\n"
+	if err := ioutil.WriteFile(file, []byte(raw), 0600); err != nil {
+		logger.Warning("Error saving the shell history: %s", err)
+	}
+}
+
+// complete completes the word before the cursor on Tab, with the names of
+// the commands and flags, or the chaincode names after -n and the txids
+// after ledger tx. Several candidates are completed up to their common
+// prefix and listed.
+func (sh *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	words := strings.Fields(line[:pos])
+	word := ""
+	if len(words) > 0 && !strings.HasSuffix(line[:pos], " ") {
+		word = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+	if len(words) > 0 && words[0] == shellPromptPrefix {
+		words = words[1:]
+	}
+
+	var matches []string
+	for _, candidate := range sh.candidates(words, word) {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	sort.Strings(matches)
+
+	completion := matches[0]
+	if len(matches) == 1 {
+		completion += " "
+	} else {
+		for _, match := range matches[1:] {
+			for !strings.HasPrefix(match, completion) {
+				completion = completion[:len(completion)-1]
+			}
+		}
+		// The terminal is locked while completing, so the candidates are
+		// listed once the line is redrawn
+		listing := strings.Join(matches, "  ") + "\n"
+		if sh.term != nil {
+			go sh.term.Write([]byte(listing))
+		}
+	}
+	if completion == word {
+		return "", 0, false
+	}
+	start := pos - len(word)
+	return line[:start] + completion + line[pos:], start + len(completion), true
+}
+
+// candidates returns the completions of word following words
+func (sh *shell) candidates(words []string, word string) []string {
+	cmd := mainCmd
+	for _, w := range words {
+		if strings.HasPrefix(w, "-") {
+			continue
+		}
+		if sub := findSubcommand(cmd, w); sub != nil {
+			cmd = sub
+		}
+	}
+
+	if n := len(words); n > 0 && (words[n-1] == "-n" || words[n-1] == "--name") {
+		return sh.chaincodeNames()
+	}
+	if strings.HasPrefix(word, "-") {
+		var flags []string
+		add := func(flag *pflag.Flag) {
+			flags = append(flags, "--"+flag.Name)
+		}
+		cmd.Flags().VisitAll(add)
+		cmd.InheritedFlags().VisitAll(add)
+		return flags
+	}
+	if cmd == ledgerTxCmd {
+		return sh.recentTxids()
+	}
+
+	var names []string
+	for _, sub := range cmd.Commands() {
+		if sub.IsAvailableCommand() && sub.Name() != shellFuncName {
+			names = append(names, sub.Name())
+		}
+	}
+	if cmd == mainCmd {
+		names = append(names, "exit", "quit", "history", "use")
+	}
+	return names
+}
+
+// findSubcommand returns the subcommand of cmd with the given name or alias
+func findSubcommand(cmd *cobra.Command, name string) *cobra.Command {
+	for _, sub := range cmd.Commands() {
+		if sub.Name() == name || sub.HasAlias(name) {
+			return sub
+		}
+	}
+	return nil
+}
+
+// shellClient connects to the peer of the context of the session
+func (sh *shell) shellClient() (pb.OpenchainClient, func(), error) {
+	contextName = sh.context
+	if err := applyContext(); err != nil {
+		return nil, nil, err
+	}
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return nil, nil, err
+	}
+	return pb.NewOpenchainClient(clientConn), func() { clientConn.Close() }, nil
+}
+
+// chaincodeNames returns the names of the chaincodes deployed, fetched once
+// until the next command
+func (sh *shell) chaincodeNames() []string {
+	if sh.chaincodes != nil {
+		return sh.chaincodes
+	}
+	client, done, err := sh.shellClient()
+	if err != nil {
+		logger.Debug("Error connecting to the peer for completion: %s", err)
+		return nil
+	}
+	defer done()
+	if sh.chaincodes, err = listChaincodeNames(client); err != nil {
+		logger.Debug("Error listing the chaincodes for completion: %s", err)
+	}
+	return sh.chaincodes
+}
+
+// recentTxids returns the IDs of the transactions of the last
+// shellTxidBlocks blocks, fetched once until the next command
+func (sh *shell) recentTxids() []string {
+	if sh.txids != nil {
+		return sh.txids
+	}
+	client, done, err := sh.shellClient()
+	if err != nil {
+		logger.Debug("Error connecting to the peer for completion: %s", err)
+		return nil
+	}
+	defer done()
+	info, err := client.GetBlockchainInfo(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		logger.Debug("Error getting the height of the blockchain for completion: %s", err)
+		return nil
+	}
+	sh.txids = []string{}
+	for i := uint64(0); i < shellTxidBlocks && i < info.Height; i++ {
+		block, err := client.GetBlockByNumber(context.Background(), &pb.BlockNumber{Number: info.Height - 1 - i})
+		if err != nil {
+			logger.Debug("Error getting a block for completion: %s", err)
+			break
+		}
+		for _, tx := range block.Transactions {
+			sh.txids = append(sh.txids, tx.Uuid)
+		}
+	}
+	return sh.txids
+}
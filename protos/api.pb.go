@@ -16,10 +16,19 @@ It is generated from these files:
 
 It has these top-level messages:
 	BlockNumber
+	BlockHash
+	TransactionUUID
 	BlockCount
+	NetworkMap
+	PeerConnection
+	ConnectionStats
+	ChaincodeInfo
+	ChaincodesMessage
 	ChaincodeEvent
 	ChaincodeID
 	ChaincodeInput
+	ArgumentAudience
+	AdminApproval
 	ChaincodeSpec
 	ChaincodeDeploymentSpec
 	ChaincodeInvocationSpec
@@ -69,6 +78,7 @@ package protos
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf "google/protobuf"
 import google_protobuf1 "google/protobuf"
 
 import (
@@ -90,6 +100,24 @@ func (m *BlockNumber) Reset()         { *m = BlockNumber{} }
 func (m *BlockNumber) String() string { return proto.CompactTextString(m) }
 func (*BlockNumber) ProtoMessage()    {}
 
+// Specifies the hash of the block to be returned from the blockchain.
+type BlockHash struct {
+	Hash []byte `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+}
+
+func (m *BlockHash) Reset()         { *m = BlockHash{} }
+func (m *BlockHash) String() string { return proto.CompactTextString(m) }
+func (*BlockHash) ProtoMessage()    {}
+
+// Specifies the UUID of the transaction to be returned from the blockchain.
+type TransactionUUID struct {
+	Uuid string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+}
+
+func (m *TransactionUUID) Reset()         { *m = TransactionUUID{} }
+func (m *TransactionUUID) String() string { return proto.CompactTextString(m) }
+func (*TransactionUUID) ProtoMessage()    {}
+
 // Specifies the current number of blocks in the blockchain.
 type BlockCount struct {
 	Count uint64 `protobuf:"varint,1,opt,name=count" json:"count,omitempty"`
@@ -99,6 +127,133 @@ func (m *BlockCount) Reset()         { *m = BlockCount{} }
 func (m *BlockCount) String() string { return proto.CompactTextString(m) }
 func (*BlockCount) ProtoMessage()    {}
 
+// The view of the network of a peer, for diagnosing split networks.
+type NetworkMap struct {
+	Self        *PeerEndpoint     `protobuf:"bytes,1,opt,name=self" json:"self,omitempty"`
+	Connections []*PeerConnection `protobuf:"bytes,2,rep,name=connections" json:"connections,omitempty"`
+	// Addresses the peer knows of, e.g. from discovery, but is not connected to.
+	Disconnected []string `protobuf:"bytes,3,rep,name=disconnected" json:"disconnected,omitempty"`
+}
+
+func (m *NetworkMap) Reset()         { *m = NetworkMap{} }
+func (m *NetworkMap) String() string { return proto.CompactTextString(m) }
+func (*NetworkMap) ProtoMessage()    {}
+
+func (m *NetworkMap) GetSelf() *PeerEndpoint {
+	if m != nil {
+		return m.Self
+	}
+	return nil
+}
+
+func (m *NetworkMap) GetConnections() []*PeerConnection {
+	if m != nil {
+		return m.Connections
+	}
+	return nil
+}
+
+// A connection of a peer with another peer.
+type PeerConnection struct {
+	Endpoint *PeerEndpoint `protobuf:"bytes,1,opt,name=endpoint" json:"endpoint,omitempty"`
+	// The protocol agreed on in the handshake.
+	ProtocolVersion uint32   `protobuf:"varint,2,opt,name=protocolVersion" json:"protocolVersion,omitempty"`
+	Capabilities    []string `protobuf:"bytes,3,rep,name=capabilities" json:"capabilities,omitempty"`
+	// Whether the peer dialed the other peer.
+	Initiated        bool  `protobuf:"varint,4,opt,name=initiated" json:"initiated,omitempty"`
+	ConnectedSeconds int64 `protobuf:"varint,5,opt,name=connectedSeconds" json:"connectedSeconds,omitempty"`
+	// Seconds since a message last arrived from the other peer.
+	IdleSeconds int64 `protobuf:"varint,6,opt,name=idleSeconds" json:"idleSeconds,omitempty"`
+	// How often connections to the address of the other peer dropped.
+	Drops int32            `protobuf:"varint,7,opt,name=drops" json:"drops,omitempty"`
+	Stats *ConnectionStats `protobuf:"bytes,8,opt,name=stats" json:"stats,omitempty"`
+}
+
+func (m *PeerConnection) Reset()         { *m = PeerConnection{} }
+func (m *PeerConnection) String() string { return proto.CompactTextString(m) }
+func (*PeerConnection) ProtoMessage()    {}
+
+func (m *PeerConnection) GetEndpoint() *PeerEndpoint {
+	if m != nil {
+		return m.Endpoint
+	}
+	return nil
+}
+
+func (m *PeerConnection) GetStats() *ConnectionStats {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
+// Traffic on a connection with another peer since it was established.
+type ConnectionStats struct {
+	BytesSent        uint64 `protobuf:"varint,1,opt,name=bytesSent" json:"bytesSent,omitempty"`
+	BytesReceived    uint64 `protobuf:"varint,2,opt,name=bytesReceived" json:"bytesReceived,omitempty"`
+	MessagesSent     uint64 `protobuf:"varint,3,opt,name=messagesSent" json:"messagesSent,omitempty"`
+	MessagesReceived uint64 `protobuf:"varint,4,opt,name=messagesReceived" json:"messagesReceived,omitempty"`
+	SendErrors       uint64 `protobuf:"varint,5,opt,name=sendErrors" json:"sendErrors,omitempty"`
+	ReceiveErrors    uint64 `protobuf:"varint,6,opt,name=receiveErrors" json:"receiveErrors,omitempty"`
+	// Smoothed round-trip time in microseconds, 0 until measured.
+	RoundTripMicros int64 `protobuf:"varint,7,opt,name=roundTripMicros" json:"roundTripMicros,omitempty"`
+	// Last round-trip time measured, in microseconds.
+	LastRoundTripMicros int64 `protobuf:"varint,8,opt,name=lastRoundTripMicros" json:"lastRoundTripMicros,omitempty"`
+}
+
+func (m *ConnectionStats) Reset()         { *m = ConnectionStats{} }
+func (m *ConnectionStats) String() string { return proto.CompactTextString(m) }
+func (*ConnectionStats) ProtoMessage()    {}
+
+// A chaincode deployed on the blockchain.
+type ChaincodeInfo struct {
+	ChaincodeID *ChaincodeID       `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	Type        ChaincodeSpec_Type `protobuf:"varint,2,opt,name=type,enum=protos.ChaincodeSpec_Type" json:"type,omitempty"`
+	// The deploy transaction and the block it was committed in.
+	DeployTransaction string                     `protobuf:"bytes,3,opt,name=deployTransaction" json:"deployTransaction,omitempty"`
+	BlockNumber       uint64                     `protobuf:"varint,4,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	Timestamp         *google_protobuf.Timestamp `protobuf:"bytes,5,opt,name=timestamp" json:"timestamp,omitempty"`
+	// Whether a container of the chaincode is running on the target peer.
+	// Chaincodes are only launched on validating peers.
+	Running bool `protobuf:"varint,6,opt,name=running" json:"running,omitempty"`
+	// The size in bytes of the deployed code package.
+	CodePackageSize uint64 `protobuf:"varint,7,opt,name=codePackageSize" json:"codePackageSize,omitempty"`
+}
+
+func (m *ChaincodeInfo) Reset()         { *m = ChaincodeInfo{} }
+func (m *ChaincodeInfo) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeInfo) ProtoMessage()    {}
+
+func (m *ChaincodeInfo) GetChaincodeID() *ChaincodeID {
+	if m != nil {
+		return m.ChaincodeID
+	}
+	return nil
+}
+
+func (m *ChaincodeInfo) GetTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
+// The chaincodes deployed on the blockchain.
+type ChaincodesMessage struct {
+	Chaincodes []*ChaincodeInfo `protobuf:"bytes,1,rep,name=chaincodes" json:"chaincodes,omitempty"`
+}
+
+func (m *ChaincodesMessage) Reset()         { *m = ChaincodesMessage{} }
+func (m *ChaincodesMessage) String() string { return proto.CompactTextString(m) }
+func (*ChaincodesMessage) ProtoMessage()    {}
+
+func (m *ChaincodesMessage) GetChaincodes() []*ChaincodeInfo {
+	if m != nil {
+		return m.Chaincodes
+	}
+	return nil
+}
+
 // Reference imports to suppress errors if they are not otherwise used.
 var _ context.Context
 var _ grpc.ClientConn
@@ -112,12 +267,25 @@ type OpenchainClient interface {
 	// GetBlockByNumber returns the data contained within a specific block in the
 	// blockchain. The genesis block is block zero.
 	GetBlockByNumber(ctx context.Context, in *BlockNumber, opts ...grpc.CallOption) (*Block, error)
+	// GetBlockByHash returns the block with the given hash.
+	GetBlockByHash(ctx context.Context, in *BlockHash, opts ...grpc.CallOption) (*Block, error)
+	// GetTransaction returns the transaction with the given UUID.
+	GetTransaction(ctx context.Context, in *TransactionUUID, opts ...grpc.CallOption) (*Transaction, error)
 	// GetBlockCount returns the current number of blocks in the blockchain data
 	// structure.
 	GetBlockCount(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*BlockCount, error)
 	// GetPeers returns a list of all peer nodes currently connected to the target
 	// peer.
 	GetPeers(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*PeersMessage, error)
+	// GetNetworkMap returns the target peer's view of the network: the peers it
+	// is connected to, and the state of each connection.
+	GetNetworkMap(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NetworkMap, error)
+	// GetChaincodes returns the chaincodes deployed on the blockchain, in the
+	// order they were deployed.
+	GetChaincodes(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ChaincodesMessage, error)
+	// GetChaincode returns the deployment of the chaincode with the name in the
+	// ChaincodeID.
+	GetChaincode(ctx context.Context, in *ChaincodeID, opts ...grpc.CallOption) (*ChaincodeInfo, error)
 }
 
 type openchainClient struct {
@@ -146,6 +314,24 @@ func (c *openchainClient) GetBlockByNumber(ctx context.Context, in *BlockNumber,
 	return out, nil
 }
 
+func (c *openchainClient) GetBlockByHash(ctx context.Context, in *BlockHash, opts ...grpc.CallOption) (*Block, error) {
+	out := new(Block)
+	err := grpc.Invoke(ctx, "/protos.Openchain/GetBlockByHash", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *openchainClient) GetTransaction(ctx context.Context, in *TransactionUUID, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := grpc.Invoke(ctx, "/protos.Openchain/GetTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *openchainClient) GetBlockCount(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*BlockCount, error) {
 	out := new(BlockCount)
 	err := grpc.Invoke(ctx, "/protos.Openchain/GetBlockCount", in, out, c.cc, opts...)
@@ -164,6 +350,33 @@ func (c *openchainClient) GetPeers(ctx context.Context, in *google_protobuf1.Emp
 	return out, nil
 }
 
+func (c *openchainClient) GetNetworkMap(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NetworkMap, error) {
+	out := new(NetworkMap)
+	err := grpc.Invoke(ctx, "/protos.Openchain/GetNetworkMap", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *openchainClient) GetChaincodes(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ChaincodesMessage, error) {
+	out := new(ChaincodesMessage)
+	err := grpc.Invoke(ctx, "/protos.Openchain/GetChaincodes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *openchainClient) GetChaincode(ctx context.Context, in *ChaincodeID, opts ...grpc.CallOption) (*ChaincodeInfo, error) {
+	out := new(ChaincodeInfo)
+	err := grpc.Invoke(ctx, "/protos.Openchain/GetChaincode", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Openchain service
 
 type OpenchainServer interface {
@@ -173,12 +386,25 @@ type OpenchainServer interface {
 	// GetBlockByNumber returns the data contained within a specific block in the
 	// blockchain. The genesis block is block zero.
 	GetBlockByNumber(context.Context, *BlockNumber) (*Block, error)
+	// GetBlockByHash returns the block with the given hash.
+	GetBlockByHash(context.Context, *BlockHash) (*Block, error)
+	// GetTransaction returns the transaction with the given UUID.
+	GetTransaction(context.Context, *TransactionUUID) (*Transaction, error)
 	// GetBlockCount returns the current number of blocks in the blockchain data
 	// structure.
 	GetBlockCount(context.Context, *google_protobuf1.Empty) (*BlockCount, error)
 	// GetPeers returns a list of all peer nodes currently connected to the target
 	// peer.
 	GetPeers(context.Context, *google_protobuf1.Empty) (*PeersMessage, error)
+	// GetNetworkMap returns the target peer's view of the network: the peers it
+	// is connected to, and the state of each connection.
+	GetNetworkMap(context.Context, *google_protobuf1.Empty) (*NetworkMap, error)
+	// GetChaincodes returns the chaincodes deployed on the blockchain, in the
+	// order they were deployed.
+	GetChaincodes(context.Context, *google_protobuf1.Empty) (*ChaincodesMessage, error)
+	// GetChaincode returns the deployment of the chaincode with the name in the
+	// ChaincodeID.
+	GetChaincode(context.Context, *ChaincodeID) (*ChaincodeInfo, error)
 }
 
 func RegisterOpenchainServer(s *grpc.Server, srv OpenchainServer) {
@@ -209,6 +435,30 @@ func _Openchain_GetBlockByNumber_Handler(srv interface{}, ctx context.Context, d
 	return out, nil
 }
 
+func _Openchain_GetBlockByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(BlockHash)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(OpenchainServer).GetBlockByHash(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Openchain_GetTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(TransactionUUID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(OpenchainServer).GetTransaction(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func _Openchain_GetBlockCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
 	in := new(google_protobuf1.Empty)
 	if err := dec(in); err != nil {
@@ -233,6 +483,42 @@ func _Openchain_GetPeers_Handler(srv interface{}, ctx context.Context, dec func(
 	return out, nil
 }
 
+func _Openchain_GetNetworkMap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(OpenchainServer).GetNetworkMap(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Openchain_GetChaincodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(OpenchainServer).GetChaincodes(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Openchain_GetChaincode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(OpenchainServer).GetChaincode(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Openchain_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Openchain",
 	HandlerType: (*OpenchainServer)(nil),
@@ -245,6 +531,14 @@ var _Openchain_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetBlockByNumber",
 			Handler:    _Openchain_GetBlockByNumber_Handler,
 		},
+		{
+			MethodName: "GetBlockByHash",
+			Handler:    _Openchain_GetBlockByHash_Handler,
+		},
+		{
+			MethodName: "GetTransaction",
+			Handler:    _Openchain_GetTransaction_Handler,
+		},
 		{
 			MethodName: "GetBlockCount",
 			Handler:    _Openchain_GetBlockCount_Handler,
@@ -253,6 +547,18 @@ var _Openchain_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetPeers",
 			Handler:    _Openchain_GetPeers_Handler,
 		},
+		{
+			MethodName: "GetNetworkMap",
+			Handler:    _Openchain_GetNetworkMap_Handler,
+		},
+		{
+			MethodName: "GetChaincodes",
+			Handler:    _Openchain_GetChaincodes_Handler,
+		},
+		{
+			MethodName: "GetChaincode",
+			Handler:    _Openchain_GetChaincode_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
@@ -195,6 +195,34 @@ func (m *ChaincodeInput) Reset()         { *m = ChaincodeInput{} }
 func (m *ChaincodeInput) String() string { return proto.CompactTextString(m) }
 func (*ChaincodeInput) ProtoMessage()    {}
 
+// Designates arguments of the ctorMsg to be encrypted, so that only the
+// holders of the keys of the given certificates can read them.
+type ArgumentAudience struct {
+	// The indexes of the arguments in ctorMsg.args
+	Args []int32 `protobuf:"varint,1,rep,name=args" json:"args,omitempty"`
+	// The DER encoded certificates of the audience
+	Certificates [][]byte `protobuf:"bytes,2,rep,name=certificates,proto3" json:"certificates,omitempty"`
+}
+
+func (m *ArgumentAudience) Reset()         { *m = ArgumentAudience{} }
+func (m *ArgumentAudience) String() string { return proto.CompactTextString(m) }
+func (*ArgumentAudience) ProtoMessage()    {}
+
+// The approval of an operation by an administrator, see peer.admin.quorum
+type AdminApproval struct {
+	// The DER encoded certificate of the administrator
+	Certificate []byte `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	// When the administrator approved, in nanoseconds since the epoch
+	Timestamp int64 `protobuf:"varint,2,opt,name=timestamp" json:"timestamp,omitempty"`
+	// The signature of the operation and the timestamp with the key of the
+	// certificate
+	Signature []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *AdminApproval) Reset()         { *m = AdminApproval{} }
+func (m *AdminApproval) String() string { return proto.CompactTextString(m) }
+func (*AdminApproval) ProtoMessage()    {}
+
 // Carries the chaincode specification. This is the actual metadata required for
 // defining a chaincode.
 type ChaincodeSpec struct {
@@ -206,6 +234,13 @@ type ChaincodeSpec struct {
 	ConfidentialityLevel ConfidentialityLevel `protobuf:"varint,6,opt,name=confidentialityLevel,enum=protos.ConfidentialityLevel" json:"confidentialityLevel,omitempty"`
 	Metadata             []byte               `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
 	Attributes           []string             `protobuf:"bytes,8,rep,name=attributes" json:"attributes,omitempty"`
+	// Arguments to encrypt for an audience, removed from the transaction
+	ArgumentAudiences []*ArgumentAudience `protobuf:"bytes,9,rep,name=argumentAudiences" json:"argumentAudiences,omitempty"`
+	// The approvals of the deployment by administrators
+	Approvals []*AdminApproval `protobuf:"bytes,10,rep,name=approvals" json:"approvals,omitempty"`
+	// Key/values carried in the metadata of the transaction, in place of
+	// the raw metadata
+	MetadataEntries map[string]string `protobuf:"bytes,11,rep,name=metadataEntries" json:"metadataEntries,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 }
 
 func (m *ChaincodeSpec) Reset()         { *m = ChaincodeSpec{} }
@@ -226,6 +261,27 @@ func (m *ChaincodeSpec) GetCtorMsg() *ChaincodeInput {
 	return nil
 }
 
+func (m *ChaincodeSpec) GetArgumentAudiences() []*ArgumentAudience {
+	if m != nil {
+		return m.ArgumentAudiences
+	}
+	return nil
+}
+
+func (m *ChaincodeSpec) GetApprovals() []*AdminApproval {
+	if m != nil {
+		return m.Approvals
+	}
+	return nil
+}
+
+func (m *ChaincodeSpec) GetMetadataEntries() map[string]string {
+	if m != nil {
+		return m.MetadataEntries
+	}
+	return nil
+}
+
 // Specify the deployment of a chaincode.
 // TODO: Define `codePackage`.
 type ChaincodeDeploymentSpec struct {
@@ -120,6 +120,79 @@ func (m *TransactionRequest) Reset()         { *m = TransactionRequest{} }
 func (m *TransactionRequest) String() string { return proto.CompactTextString(m) }
 func (*TransactionRequest) ProtoMessage()    {}
 
+// Invocations submitted together, each through its own transaction.
+type ChaincodeInvocationBatch struct {
+	Invocations []*ChaincodeInvocationSpec `protobuf:"bytes,1,rep,name=invocations" json:"invocations,omitempty"`
+}
+
+func (m *ChaincodeInvocationBatch) Reset()         { *m = ChaincodeInvocationBatch{} }
+func (m *ChaincodeInvocationBatch) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeInvocationBatch) ProtoMessage()    {}
+
+func (m *ChaincodeInvocationBatch) GetInvocations() []*ChaincodeInvocationSpec {
+	if m != nil {
+		return m.Invocations
+	}
+	return nil
+}
+
+// Responses to the invocations of a batch, in the same order, each carrying
+// the transaction UUID or the error.
+type BatchResponse struct {
+	Responses []*Response `protobuf:"bytes,1,rep,name=responses" json:"responses,omitempty"`
+}
+
+func (m *BatchResponse) Reset()         { *m = BatchResponse{} }
+func (m *BatchResponse) String() string { return proto.CompactTextString(m) }
+func (*BatchResponse) ProtoMessage()    {}
+
+func (m *BatchResponse) GetResponses() []*Response {
+	if m != nil {
+		return m.Responses
+	}
+	return nil
+}
+
+// UnsignedTransactionRequest asks for the unsigned transaction of the
+// deployment, invocation or query of the chaincode of invocationSpec, to be
+// signed by the holder of cert, the DER encoding of its enrollment or
+// transaction certificate.
+type UnsignedTransactionRequest struct {
+	Type           Transaction_Type         `protobuf:"varint,1,opt,name=type,enum=protos.Transaction_Type" json:"type,omitempty"`
+	InvocationSpec *ChaincodeInvocationSpec `protobuf:"bytes,2,opt,name=invocationSpec" json:"invocationSpec,omitempty"`
+	Cert           []byte                   `protobuf:"bytes,3,opt,name=cert,proto3" json:"cert,omitempty"`
+}
+
+func (m *UnsignedTransactionRequest) Reset()         { *m = UnsignedTransactionRequest{} }
+func (m *UnsignedTransactionRequest) String() string { return proto.CompactTextString(m) }
+func (*UnsignedTransactionRequest) ProtoMessage()    {}
+
+func (m *UnsignedTransactionRequest) GetInvocationSpec() *ChaincodeInvocationSpec {
+	if m != nil {
+		return m.InvocationSpec
+	}
+	return nil
+}
+
+// UnsignedTransaction is a transaction to sign away from the peer. Its
+// signature is the ECDSA signature of digest, the hash of the transaction
+// under the hash algorithm of the peer.
+type UnsignedTransaction struct {
+	Transaction *Transaction `protobuf:"bytes,1,opt,name=transaction" json:"transaction,omitempty"`
+	Digest      []byte       `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
+}
+
+func (m *UnsignedTransaction) Reset()         { *m = UnsignedTransaction{} }
+func (m *UnsignedTransaction) String() string { return proto.CompactTextString(m) }
+func (*UnsignedTransaction) ProtoMessage()    {}
+
+func (m *UnsignedTransaction) GetTransaction() *Transaction {
+	if m != nil {
+		return m.Transaction
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("protos.BuildResult_StatusCode", BuildResult_StatusCode_name, BuildResult_StatusCode_value)
 }
@@ -142,6 +215,8 @@ type DevopsClient interface {
 	Invoke(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error)
 	// Invoke chaincode.
 	Query(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error)
+	// Invoke chaincode through a batch of transactions submitted together.
+	InvokeBatch(ctx context.Context, in *ChaincodeInvocationBatch, opts ...grpc.CallOption) (*BatchResponse, error)
 	// Retrieve a TCert.
 	EXP_GetApplicationTCert(ctx context.Context, in *Secret, opts ...grpc.CallOption) (*Response, error)
 	// Prepare for performing a TX, which will return a binding that can later be used to sign and then execute a transaction.
@@ -150,6 +225,11 @@ type DevopsClient interface {
 	EXP_ProduceSigma(ctx context.Context, in *SigmaInput, opts ...grpc.CallOption) (*Response, error)
 	// Execute a transaction with a specific binding
 	EXP_ExecuteWithBinding(ctx context.Context, in *ExecuteWithBinding, opts ...grpc.CallOption) (*Response, error)
+	// Build the unsigned transaction of a deployment, invocation or query,
+	// to be signed away from the peer.
+	BuildTransaction(ctx context.Context, in *UnsignedTransactionRequest, opts ...grpc.CallOption) (*UnsignedTransaction, error)
+	// Submit a transaction signed away from the peer.
+	SubmitTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Response, error)
 }
 
 type devopsClient struct {
@@ -205,6 +285,15 @@ func (c *devopsClient) Query(ctx context.Context, in *ChaincodeInvocationSpec, o
 	return out, nil
 }
 
+func (c *devopsClient) InvokeBatch(ctx context.Context, in *ChaincodeInvocationBatch, opts ...grpc.CallOption) (*BatchResponse, error) {
+	out := new(BatchResponse)
+	err := grpc.Invoke(ctx, "/protos.Devops/InvokeBatch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *devopsClient) EXP_GetApplicationTCert(ctx context.Context, in *Secret, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
 	err := grpc.Invoke(ctx, "/protos.Devops/EXP_GetApplicationTCert", in, out, c.cc, opts...)
@@ -241,6 +330,24 @@ func (c *devopsClient) EXP_ExecuteWithBinding(ctx context.Context, in *ExecuteWi
 	return out, nil
 }
 
+func (c *devopsClient) BuildTransaction(ctx context.Context, in *UnsignedTransactionRequest, opts ...grpc.CallOption) (*UnsignedTransaction, error) {
+	out := new(UnsignedTransaction)
+	err := grpc.Invoke(ctx, "/protos.Devops/BuildTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) SubmitTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := grpc.Invoke(ctx, "/protos.Devops/SubmitTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Devops service
 
 type DevopsServer interface {
@@ -255,6 +362,8 @@ type DevopsServer interface {
 	Invoke(context.Context, *ChaincodeInvocationSpec) (*Response, error)
 	// Invoke chaincode.
 	Query(context.Context, *ChaincodeInvocationSpec) (*Response, error)
+	// Invoke chaincode through a batch of transactions submitted together.
+	InvokeBatch(context.Context, *ChaincodeInvocationBatch) (*BatchResponse, error)
 	// Retrieve a TCert.
 	EXP_GetApplicationTCert(context.Context, *Secret) (*Response, error)
 	// Prepare for performing a TX, which will return a binding that can later be used to sign and then execute a transaction.
@@ -263,6 +372,11 @@ type DevopsServer interface {
 	EXP_ProduceSigma(context.Context, *SigmaInput) (*Response, error)
 	// Execute a transaction with a specific binding
 	EXP_ExecuteWithBinding(context.Context, *ExecuteWithBinding) (*Response, error)
+	// Build the unsigned transaction of a deployment, invocation or query,
+	// to be signed away from the peer.
+	BuildTransaction(context.Context, *UnsignedTransactionRequest) (*UnsignedTransaction, error)
+	// Submit a transaction signed away from the peer.
+	SubmitTransaction(context.Context, *Transaction) (*Response, error)
 }
 
 func RegisterDevopsServer(s *grpc.Server, srv DevopsServer) {
@@ -329,6 +443,18 @@ func _Devops_Query_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return out, nil
 }
 
+func _Devops_InvokeBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeInvocationBatch)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).InvokeBatch(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func _Devops_EXP_GetApplicationTCert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
 	in := new(Secret)
 	if err := dec(in); err != nil {
@@ -377,6 +503,30 @@ func _Devops_EXP_ExecuteWithBinding_Handler(srv interface{}, ctx context.Context
 	return out, nil
 }
 
+func _Devops_BuildTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(UnsignedTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).BuildTransaction(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_SubmitTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).SubmitTransaction(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Devops_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Devops",
 	HandlerType: (*DevopsServer)(nil),
@@ -401,6 +551,10 @@ var _Devops_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Query",
 			Handler:    _Devops_Query_Handler,
 		},
+		{
+			MethodName: "InvokeBatch",
+			Handler:    _Devops_InvokeBatch_Handler,
+		},
 		{
 			MethodName: "EXP_GetApplicationTCert",
 			Handler:    _Devops_EXP_GetApplicationTCert_Handler,
@@ -417,6 +571,14 @@ var _Devops_serviceDesc = grpc.ServiceDesc{
 			MethodName: "EXP_ExecuteWithBinding",
 			Handler:    _Devops_EXP_ExecuteWithBinding_Handler,
 		},
+		{
+			MethodName: "BuildTransaction",
+			Handler:    _Devops_BuildTransaction_Handler,
+		},
+		{
+			MethodName: "SubmitTransaction",
+			Handler:    _Devops_SubmitTransaction_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
@@ -7,6 +7,7 @@ package protos
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf "google/protobuf"
 
 import (
 	context "golang.org/x/net/context"
@@ -21,10 +22,13 @@ var _ = math.Inf
 type EventType int32
 
 const (
-	EventType_REGISTER  EventType = 0
-	EventType_BLOCK     EventType = 1
-	EventType_CHAINCODE EventType = 2
-	EventType_REJECTION EventType = 3
+	EventType_REGISTER         EventType = 0
+	EventType_BLOCK            EventType = 1
+	EventType_CHAINCODE        EventType = 2
+	EventType_REJECTION        EventType = 3
+	EventType_CONSENSUS_HEALTH EventType = 4
+	EventType_REVOCATION       EventType = 5
+	EventType_SECURITY         EventType = 6
 )
 
 var EventType_name = map[int32]string{
@@ -32,12 +36,18 @@ var EventType_name = map[int32]string{
 	1: "BLOCK",
 	2: "CHAINCODE",
 	3: "REJECTION",
+	4: "CONSENSUS_HEALTH",
+	5: "REVOCATION",
+	6: "SECURITY",
 }
 var EventType_value = map[string]int32{
-	"REGISTER":  0,
-	"BLOCK":     1,
-	"CHAINCODE": 2,
-	"REJECTION": 3,
+	"REGISTER":         0,
+	"BLOCK":            1,
+	"CHAINCODE":        2,
+	"REJECTION":        3,
+	"CONSENSUS_HEALTH": 4,
+	"REVOCATION":       5,
+	"SECURITY":         6,
 }
 
 func (x EventType) String() string {
@@ -170,6 +180,87 @@ func (m *Rejection) GetTx() *Transaction {
 	return nil
 }
 
+// ConsensusHealth is sent by validators when their ability to order
+// transactions changes
+// string type - "consensus_health"
+type ConsensusHealth struct {
+	Healthy      bool   `protobuf:"varint,1,opt,name=healthy" json:"healthy,omitempty"`
+	Condition    string `protobuf:"bytes,2,opt,name=condition" json:"condition,omitempty"`
+	Detail       string `protobuf:"bytes,3,opt,name=detail" json:"detail,omitempty"`
+	View         uint64 `protobuf:"varint,4,opt,name=view" json:"view,omitempty"`
+	LowWatermark uint64 `protobuf:"varint,5,opt,name=lowWatermark" json:"lowWatermark,omitempty"`
+	Window       uint64 `protobuf:"varint,6,opt,name=window" json:"window,omitempty"`
+	Outstanding  uint64 `protobuf:"varint,7,opt,name=outstanding" json:"outstanding,omitempty"`
+	Saturated    bool   `protobuf:"varint,8,opt,name=saturated" json:"saturated,omitempty"`
+}
+
+func (m *ConsensusHealth) Reset()         { *m = ConsensusHealth{} }
+func (m *ConsensusHealth) String() string { return proto.CompactTextString(m) }
+func (*ConsensusHealth) ProtoMessage()    {}
+
+// Revocation is sent by membership services when a CA issues a new
+// certificate revocation list, which is signed by the CA
+// string type - "revocation"
+type Revocation struct {
+	Issuer string `protobuf:"bytes,1,opt,name=issuer" json:"issuer,omitempty"`
+	Crl    []byte `protobuf:"bytes,2,opt,name=crl,proto3" json:"crl,omitempty"`
+}
+
+func (m *Revocation) Reset()         { *m = Revocation{} }
+func (m *Revocation) String() string { return proto.CompactTextString(m) }
+func (*Revocation) ProtoMessage()    {}
+
+type SecurityEvent_Kind int32
+
+const (
+	SecurityEvent_SIGNATURE_INVALID     SecurityEvent_Kind = 0
+	SecurityEvent_CERTIFICATE_EXPIRED   SecurityEvent_Kind = 1
+	SecurityEvent_CERTIFICATE_REVOKED   SecurityEvent_Kind = 2
+	SecurityEvent_CERTIFICATE_UNTRUSTED SecurityEvent_Kind = 3
+	SecurityEvent_ACCESS_DENIED         SecurityEvent_Kind = 4
+)
+
+var SecurityEvent_Kind_name = map[int32]string{
+	0: "SIGNATURE_INVALID",
+	1: "CERTIFICATE_EXPIRED",
+	2: "CERTIFICATE_REVOKED",
+	3: "CERTIFICATE_UNTRUSTED",
+	4: "ACCESS_DENIED",
+}
+var SecurityEvent_Kind_value = map[string]int32{
+	"SIGNATURE_INVALID":     0,
+	"CERTIFICATE_EXPIRED":   1,
+	"CERTIFICATE_REVOKED":   2,
+	"CERTIFICATE_UNTRUSTED": 3,
+	"ACCESS_DENIED":         4,
+}
+
+func (x SecurityEvent_Kind) String() string {
+	return proto.EnumName(SecurityEvent_Kind_name, int32(x))
+}
+
+// SecurityEvent is sent by peers in security audit mode for every failed
+// signature or certificate verification and every access denial
+// string type - "security"
+type SecurityEvent struct {
+	Kind      SecurityEvent_Kind         `protobuf:"varint,1,opt,name=kind,enum=protos.SecurityEvent_Kind" json:"kind,omitempty"`
+	Identity  string                     `protobuf:"bytes,2,opt,name=identity" json:"identity,omitempty"`
+	Source    string                     `protobuf:"bytes,3,opt,name=source" json:"source,omitempty"`
+	Detail    string                     `protobuf:"bytes,4,opt,name=detail" json:"detail,omitempty"`
+	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,5,opt,name=timestamp" json:"timestamp,omitempty"`
+}
+
+func (m *SecurityEvent) Reset()         { *m = SecurityEvent{} }
+func (m *SecurityEvent) String() string { return proto.CompactTextString(m) }
+func (*SecurityEvent) ProtoMessage()    {}
+
+func (m *SecurityEvent) GetTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
 // ---------- producer events ---------
 // Event is used by
 //  - consumers (adapters) to send Register
@@ -180,6 +271,9 @@ type Event struct {
 	//	*Event_Block
 	//	*Event_ChaincodeEvent
 	//	*Event_Rejection
+	//	*Event_ConsensusHealth
+	//	*Event_Revocation
+	//	*Event_SecurityEvent
 	Event isEvent_Event `protobuf_oneof:"Event"`
 }
 
@@ -203,11 +297,23 @@ type Event_ChaincodeEvent struct {
 type Event_Rejection struct {
 	Rejection *Rejection `protobuf:"bytes,4,opt,name=rejection,oneof"`
 }
+type Event_ConsensusHealth struct {
+	ConsensusHealth *ConsensusHealth `protobuf:"bytes,5,opt,name=consensusHealth,oneof"`
+}
+type Event_Revocation struct {
+	Revocation *Revocation `protobuf:"bytes,6,opt,name=revocation,oneof"`
+}
+type Event_SecurityEvent struct {
+	SecurityEvent *SecurityEvent `protobuf:"bytes,7,opt,name=securityEvent,oneof"`
+}
 
-func (*Event_Register) isEvent_Event()       {}
-func (*Event_Block) isEvent_Event()          {}
-func (*Event_ChaincodeEvent) isEvent_Event() {}
-func (*Event_Rejection) isEvent_Event()      {}
+func (*Event_Register) isEvent_Event()        {}
+func (*Event_Block) isEvent_Event()           {}
+func (*Event_ChaincodeEvent) isEvent_Event()  {}
+func (*Event_Rejection) isEvent_Event()       {}
+func (*Event_ConsensusHealth) isEvent_Event() {}
+func (*Event_Revocation) isEvent_Event()      {}
+func (*Event_SecurityEvent) isEvent_Event()   {}
 
 func (m *Event) GetEvent() isEvent_Event {
 	if m != nil {
@@ -244,6 +350,27 @@ func (m *Event) GetRejection() *Rejection {
 	return nil
 }
 
+func (m *Event) GetConsensusHealth() *ConsensusHealth {
+	if x, ok := m.GetEvent().(*Event_ConsensusHealth); ok {
+		return x.ConsensusHealth
+	}
+	return nil
+}
+
+func (m *Event) GetRevocation() *Revocation {
+	if x, ok := m.GetEvent().(*Event_Revocation); ok {
+		return x.Revocation
+	}
+	return nil
+}
+
+func (m *Event) GetSecurityEvent() *SecurityEvent {
+	if x, ok := m.GetEvent().(*Event_SecurityEvent); ok {
+		return x.SecurityEvent
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*Event) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), []interface{}) {
 	return _Event_OneofMarshaler, _Event_OneofUnmarshaler, []interface{}{
@@ -251,6 +378,9 @@ func (*Event) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error,
 		(*Event_Block)(nil),
 		(*Event_ChaincodeEvent)(nil),
 		(*Event_Rejection)(nil),
+		(*Event_ConsensusHealth)(nil),
+		(*Event_Revocation)(nil),
+		(*Event_SecurityEvent)(nil),
 	}
 }
 
@@ -278,6 +408,21 @@ func _Event_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
 		if err := b.EncodeMessage(x.Rejection); err != nil {
 			return err
 		}
+	case *Event_ConsensusHealth:
+		b.EncodeVarint(5<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.ConsensusHealth); err != nil {
+			return err
+		}
+	case *Event_Revocation:
+		b.EncodeVarint(6<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Revocation); err != nil {
+			return err
+		}
+	case *Event_SecurityEvent:
+		b.EncodeVarint(7<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.SecurityEvent); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("Event.Event has unexpected type %T", x)
@@ -320,6 +465,30 @@ func _Event_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer)
 		err := b.DecodeMessage(msg)
 		m.Event = &Event_Rejection{msg}
 		return true, err
+	case 5: // Event.consensusHealth
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(ConsensusHealth)
+		err := b.DecodeMessage(msg)
+		m.Event = &Event_ConsensusHealth{msg}
+		return true, err
+	case 6: // Event.revocation
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Revocation)
+		err := b.DecodeMessage(msg)
+		m.Event = &Event_Revocation{msg}
+		return true, err
+	case 7: // Event.securityEvent
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(SecurityEvent)
+		err := b.DecodeMessage(msg)
+		m.Event = &Event_SecurityEvent{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -327,6 +496,7 @@ func _Event_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer)
 
 func init() {
 	proto.RegisterEnum("protos.EventType", EventType_name, EventType_value)
+	proto.RegisterEnum("protos.SecurityEvent_Kind", SecurityEvent_Kind_name, SecurityEvent_Kind_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
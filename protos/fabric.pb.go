@@ -85,6 +85,8 @@ const (
 	Message_DISC_PEERS              Message_Type = 4
 	Message_DISC_NEWMSG             Message_Type = 5
 	Message_CHAIN_TRANSACTION       Message_Type = 6
+	Message_DISC_PING               Message_Type = 7
+	Message_DISC_PONG               Message_Type = 8
 	Message_SYNC_GET_BLOCKS         Message_Type = 11
 	Message_SYNC_BLOCKS             Message_Type = 12
 	Message_SYNC_BLOCK_ADDED        Message_Type = 13
@@ -92,6 +94,7 @@ const (
 	Message_SYNC_STATE_SNAPSHOT     Message_Type = 15
 	Message_SYNC_STATE_GET_DELTAS   Message_Type = 16
 	Message_SYNC_STATE_DELTAS       Message_Type = 17
+	Message_SYNC_DIGEST             Message_Type = 18
 	Message_RESPONSE                Message_Type = 20
 	Message_CONSENSUS               Message_Type = 21
 )
@@ -104,6 +107,8 @@ var Message_Type_name = map[int32]string{
 	4:  "DISC_PEERS",
 	5:  "DISC_NEWMSG",
 	6:  "CHAIN_TRANSACTION",
+	7:  "DISC_PING",
+	8:  "DISC_PONG",
 	11: "SYNC_GET_BLOCKS",
 	12: "SYNC_BLOCKS",
 	13: "SYNC_BLOCK_ADDED",
@@ -111,6 +116,7 @@ var Message_Type_name = map[int32]string{
 	15: "SYNC_STATE_SNAPSHOT",
 	16: "SYNC_STATE_GET_DELTAS",
 	17: "SYNC_STATE_DELTAS",
+	18: "SYNC_DIGEST",
 	20: "RESPONSE",
 	21: "CONSENSUS",
 }
@@ -122,6 +128,8 @@ var Message_Type_value = map[string]int32{
 	"DISC_PEERS":              4,
 	"DISC_NEWMSG":             5,
 	"CHAIN_TRANSACTION":       6,
+	"DISC_PING":               7,
+	"DISC_PONG":               8,
 	"SYNC_GET_BLOCKS":         11,
 	"SYNC_BLOCKS":             12,
 	"SYNC_BLOCK_ADDED":        13,
@@ -129,6 +137,7 @@ var Message_Type_value = map[string]int32{
 	"SYNC_STATE_SNAPSHOT":     15,
 	"SYNC_STATE_GET_DELTAS":   16,
 	"SYNC_STATE_DELTAS":       17,
+	"SYNC_DIGEST":             18,
 	"RESPONSE":                20,
 	"CONSENSUS":               21,
 }
@@ -143,17 +152,20 @@ const (
 	Response_UNDEFINED Response_StatusCode = 0
 	Response_SUCCESS   Response_StatusCode = 200
 	Response_FAILURE   Response_StatusCode = 500
+	Response_SATURATED Response_StatusCode = 503
 )
 
 var Response_StatusCode_name = map[int32]string{
 	0:   "UNDEFINED",
 	200: "SUCCESS",
 	500: "FAILURE",
+	503: "SATURATED",
 }
 var Response_StatusCode_value = map[string]int32{
 	"UNDEFINED": 0,
 	"SUCCESS":   200,
 	"FAILURE":   500,
+	"SATURATED": 503,
 }
 
 func (x Response_StatusCode) String() string {
@@ -179,6 +191,12 @@ type Transaction struct {
 	ToValidators                   []byte                     `protobuf:"bytes,10,opt,name=toValidators,proto3" json:"toValidators,omitempty"`
 	Cert                           []byte                     `protobuf:"bytes,11,opt,name=cert,proto3" json:"cert,omitempty"`
 	Signature                      []byte                     `protobuf:"bytes,12,opt,name=signature,proto3" json:"signature,omitempty"`
+	// The presentation of an anonymous credential of the TCA, which signs
+	// the transaction in place of cert and signature
+	Credential []byte `protobuf:"bytes,13,opt,name=credential,proto3" json:"credential,omitempty"`
+	// The W3C traceparent of the span which submitted the transaction, for
+	// the peers to trace its lifecycle. Like the signature, it is not signed.
+	TraceContext string `protobuf:"bytes,14,opt,name=traceContext" json:"traceContext,omitempty"`
 }
 
 func (m *Transaction) Reset()         { *m = Transaction{} }
@@ -192,6 +210,23 @@ func (m *Transaction) GetTimestamp() *google_protobuf.Timestamp {
 	return nil
 }
 
+// TransactionMetadata is the metadata of a transaction created from a
+// chaincode spec with metadataEntries.
+type TransactionMetadata struct {
+	Entries map[string]string `protobuf:"bytes,1,rep,name=entries" json:"entries,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *TransactionMetadata) Reset()         { *m = TransactionMetadata{} }
+func (m *TransactionMetadata) String() string { return proto.CompactTextString(m) }
+func (*TransactionMetadata) ProtoMessage()    {}
+
+func (m *TransactionMetadata) GetEntries() map[string]string {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
 // TransactionBlock carries a batch of transactions.
 type TransactionBlock struct {
 	Transactions []*Transaction `protobuf:"bytes,1,rep,name=transactions" json:"transactions,omitempty"`
@@ -373,6 +408,15 @@ func (*PeersAddresses) ProtoMessage()    {}
 type HelloMessage struct {
 	PeerEndpoint   *PeerEndpoint   `protobuf:"bytes,1,opt,name=peerEndpoint" json:"peerEndpoint,omitempty"`
 	BlockchainInfo *BlockchainInfo `protobuf:"bytes,2,opt,name=blockchainInfo" json:"blockchainInfo,omitempty"`
+	// The newest and oldest protocol versions the peer speaks, 0 for peers
+	// predating protocol negotiation
+	ProtocolVersion    uint32 `protobuf:"varint,3,opt,name=protocolVersion" json:"protocolVersion,omitempty"`
+	MinProtocolVersion uint32 `protobuf:"varint,4,opt,name=minProtocolVersion" json:"minProtocolVersion,omitempty"`
+	// Optional features the peer supports, e.g. gossip
+	Capabilities []string `protobuf:"bytes,5,rep,name=capabilities" json:"capabilities,omitempty"`
+	// Random bytes the other peer includes in the signatures of the messages
+	// it sends on this stream, so they cannot be replayed on another stream
+	Nonce []byte `protobuf:"bytes,6,opt,name=nonce,proto3" json:"nonce,omitempty"`
 }
 
 func (m *HelloMessage) Reset()         { *m = HelloMessage{} }
@@ -398,6 +442,14 @@ type Message struct {
 	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=timestamp" json:"timestamp,omitempty"`
 	Payload   []byte                     `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
 	Signature []byte                     `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	// How the payload is compressed, e.g. gzip, empty if it is not. Only
+	// used with peers advertising the encoding in their hello.
+	Encoding string `protobuf:"bytes,5,opt,name=encoding" json:"encoding,omitempty"`
+	// Numbers the messages sent on the stream from 1, only used with peers
+	// advertising the sequence capability in their hello. The signature of
+	// the messages then covers the sequence and the nonce of the hello of
+	// the receiving peer besides the payload.
+	Sequence uint64 `protobuf:"varint,6,opt,name=sequence" json:"sequence,omitempty"`
 }
 
 func (m *Message) Reset()         { *m = Message{} }
@@ -426,8 +478,9 @@ func (*Response) ProtoMessage()    {}
 // block and the delta state to its ledger if the block's previousBlockHash
 // equals to the NVP's current block hash
 type BlockState struct {
-	Block      *Block `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
-	StateDelta []byte `protobuf:"bytes,2,opt,name=stateDelta,proto3" json:"stateDelta,omitempty"`
+	Block       *Block `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
+	StateDelta  []byte `protobuf:"bytes,2,opt,name=stateDelta,proto3" json:"stateDelta,omitempty"`
+	BlockNumber uint64 `protobuf:"varint,3,opt,name=blockNumber" json:"blockNumber,omitempty"`
 }
 
 func (m *BlockState) Reset()         { *m = BlockState{} }
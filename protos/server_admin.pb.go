@@ -59,6 +59,165 @@ func (m *ServerStatus) Reset()         { *m = ServerStatus{} }
 func (m *ServerStatus) String() string { return proto.CompactTextString(m) }
 func (*ServerStatus) ProtoMessage()    {}
 
+type NodeStatus struct {
+	Status           ServerStatus_StatusCode `protobuf:"varint,1,opt,name=status,enum=protos.ServerStatus_StatusCode" json:"status,omitempty"`
+	PeerID           string                  `protobuf:"bytes,2,opt,name=peerID" json:"peerID,omitempty"`
+	Address          string                  `protobuf:"bytes,3,opt,name=address" json:"address,omitempty"`
+	Validator        bool                    `protobuf:"varint,4,opt,name=validator" json:"validator,omitempty"`
+	UptimeSeconds    int64                   `protobuf:"varint,5,opt,name=uptimeSeconds" json:"uptimeSeconds,omitempty"`
+	BlockchainHeight uint64                  `protobuf:"varint,6,opt,name=blockchainHeight" json:"blockchainHeight,omitempty"`
+	ConnectedPeers   int32                   `protobuf:"varint,7,opt,name=connectedPeers" json:"connectedPeers,omitempty"`
+	Draining         bool                    `protobuf:"varint,8,opt,name=draining" json:"draining,omitempty"`
+	Subsystems       []*SubsystemHealth      `protobuf:"bytes,9,rep,name=subsystems" json:"subsystems,omitempty"`
+	// Requests refused since the node started because their client exceeded
+	// its rate limit
+	RateLimitedRequests uint64 `protobuf:"varint,10,opt,name=rateLimitedRequests" json:"rateLimitedRequests,omitempty"`
+	// Address of the primary the node stands by for, empty unless the node
+	// is a standby which did not take over yet
+	StandbyFor string `protobuf:"bytes,11,opt,name=standbyFor" json:"standbyFor,omitempty"`
+	// Security events recorded since the node started, by kind
+	SecurityEvents []*SecurityEventCount `protobuf:"bytes,12,rep,name=securityEvents" json:"securityEvents,omitempty"`
+	// The consensus view and health, for validators
+	Consensus *ConsensusHealth `protobuf:"bytes,13,opt,name=consensus" json:"consensus,omitempty"`
+	// Clients connected to the event hub of the node
+	EventConsumers int32 `protobuf:"varint,14,opt,name=eventConsumers" json:"eventConsumers,omitempty"`
+	// Chaincode containers launched by the node
+	Chaincodes []*ChaincodeContainer `protobuf:"bytes,15,rep,name=chaincodes" json:"chaincodes,omitempty"`
+	Resources  *ResourceUsage        `protobuf:"bytes,16,opt,name=resources" json:"resources,omitempty"`
+}
+
+func (m *NodeStatus) Reset()         { *m = NodeStatus{} }
+func (m *NodeStatus) String() string { return proto.CompactTextString(m) }
+func (*NodeStatus) ProtoMessage()    {}
+
+func (m *NodeStatus) GetSubsystems() []*SubsystemHealth {
+	if m != nil {
+		return m.Subsystems
+	}
+	return nil
+}
+
+func (m *NodeStatus) GetSecurityEvents() []*SecurityEventCount {
+	if m != nil {
+		return m.SecurityEvents
+	}
+	return nil
+}
+
+func (m *NodeStatus) GetConsensus() *ConsensusHealth {
+	if m != nil {
+		return m.Consensus
+	}
+	return nil
+}
+
+func (m *NodeStatus) GetChaincodes() []*ChaincodeContainer {
+	if m != nil {
+		return m.Chaincodes
+	}
+	return nil
+}
+
+func (m *NodeStatus) GetResources() *ResourceUsage {
+	if m != nil {
+		return m.Resources
+	}
+	return nil
+}
+
+type ChaincodeContainer struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// Whether the chaincode registered with the node, the container is
+	// still starting otherwise
+	Registered bool `protobuf:"varint,2,opt,name=registered" json:"registered,omitempty"`
+}
+
+func (m *ChaincodeContainer) Reset()         { *m = ChaincodeContainer{} }
+func (m *ChaincodeContainer) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeContainer) ProtoMessage()    {}
+
+// The resources used by the node process
+type ResourceUsage struct {
+	Goroutines int32 `protobuf:"varint,1,opt,name=goroutines" json:"goroutines,omitempty"`
+	// Bytes of allocated heap objects
+	HeapAllocBytes uint64 `protobuf:"varint,2,opt,name=heapAllocBytes" json:"heapAllocBytes,omitempty"`
+	// Bytes of memory obtained from the operating system
+	SysBytes uint64 `protobuf:"varint,3,opt,name=sysBytes" json:"sysBytes,omitempty"`
+	GcCycles uint32 `protobuf:"varint,4,opt,name=gcCycles" json:"gcCycles,omitempty"`
+	// Total time the garbage collector stopped the node, in microseconds
+	GcPauseMicros uint64 `protobuf:"varint,5,opt,name=gcPauseMicros" json:"gcPauseMicros,omitempty"`
+}
+
+func (m *ResourceUsage) Reset()         { *m = ResourceUsage{} }
+func (m *ResourceUsage) String() string { return proto.CompactTextString(m) }
+func (*ResourceUsage) ProtoMessage()    {}
+
+type SecurityEventCount struct {
+	Kind  string `protobuf:"bytes,1,opt,name=kind" json:"kind,omitempty"`
+	Count uint64 `protobuf:"varint,2,opt,name=count" json:"count,omitempty"`
+}
+
+func (m *SecurityEventCount) Reset()         { *m = SecurityEventCount{} }
+func (m *SecurityEventCount) String() string { return proto.CompactTextString(m) }
+func (*SecurityEventCount) ProtoMessage()    {}
+
+type SubsystemHealth struct {
+	Name    string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Healthy bool   `protobuf:"varint,2,opt,name=healthy" json:"healthy,omitempty"`
+	Detail  string `protobuf:"bytes,3,opt,name=detail" json:"detail,omitempty"`
+}
+
+func (m *SubsystemHealth) Reset()         { *m = SubsystemHealth{} }
+func (m *SubsystemHealth) String() string { return proto.CompactTextString(m) }
+func (*SubsystemHealth) ProtoMessage()    {}
+
+type LogLevelRequest struct {
+	Module string `protobuf:"bytes,1,opt,name=module" json:"module,omitempty"`
+	Level  string `protobuf:"bytes,2,opt,name=level" json:"level,omitempty"`
+	// The approvals of the change by administrators
+	Approvals []*AdminApproval `protobuf:"bytes,3,rep,name=approvals" json:"approvals,omitempty"`
+}
+
+func (m *LogLevelRequest) Reset()         { *m = LogLevelRequest{} }
+func (m *LogLevelRequest) String() string { return proto.CompactTextString(m) }
+func (*LogLevelRequest) ProtoMessage()    {}
+
+func (m *LogLevelRequest) GetApprovals() []*AdminApproval {
+	if m != nil {
+		return m.Approvals
+	}
+	return nil
+}
+
+type LogLevelResponse struct {
+	Module string `protobuf:"bytes,1,opt,name=module" json:"module,omitempty"`
+	Level  string `protobuf:"bytes,2,opt,name=level" json:"level,omitempty"`
+}
+
+func (m *LogLevelResponse) Reset()         { *m = LogLevelResponse{} }
+func (m *LogLevelResponse) String() string { return proto.CompactTextString(m) }
+func (*LogLevelResponse) ProtoMessage()    {}
+
+type DrainRequest struct {
+	// How long to wait for pending transactions, 0 for the configured default
+	TimeoutSeconds int32 `protobuf:"varint,1,opt,name=timeoutSeconds" json:"timeoutSeconds,omitempty"`
+}
+
+func (m *DrainRequest) Reset()         { *m = DrainRequest{} }
+func (m *DrainRequest) String() string { return proto.CompactTextString(m) }
+func (*DrainRequest) ProtoMessage()    {}
+
+type ConfigReloadReport struct {
+	// The changed settings the node applied
+	Applied []string `protobuf:"bytes,1,rep,name=applied" json:"applied,omitempty"`
+	// The changed settings which take effect once the node restarts
+	RestartRequired []string `protobuf:"bytes,2,rep,name=restartRequired" json:"restartRequired,omitempty"`
+}
+
+func (m *ConfigReloadReport) Reset()         { *m = ConfigReloadReport{} }
+func (m *ConfigReloadReport) String() string { return proto.CompactTextString(m) }
+func (*ConfigReloadReport) ProtoMessage()    {}
+
 func init() {
 	proto.RegisterEnum("protos.ServerStatus_StatusCode", ServerStatus_StatusCode_name, ServerStatus_StatusCode_value)
 }
@@ -74,6 +233,32 @@ type AdminClient interface {
 	GetStatus(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
 	StartServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
 	StopServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
+	// Return the status of the node and the health of its subsystems.
+	GetNodeStatus(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NodeStatus, error)
+	// Get and set the log level of a logging module, the empty module
+	// stands for the default level.
+	GetModuleLogLevel(ctx context.Context, in *LogLevelRequest, opts ...grpc.CallOption) (*LogLevelResponse, error)
+	SetModuleLogLevel(ctx context.Context, in *LogLevelRequest, opts ...grpc.CallOption) (*LogLevelResponse, error)
+	// Restore the log levels of the logging specification of the
+	// configuration, undoing the levels set since, and return the default.
+	RevertLogLevels(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*LogLevelResponse, error)
+	// Stop accepting transactions, wait for the pending ones to be ordered,
+	// then stop the server.
+	DrainServer(ctx context.Context, in *DrainRequest, opts ...grpc.CallOption) (*ServerStatus, error)
+	// Load the renewed enrollment and TLS certificates of the node, and
+	// announce the new identity to the connected peers.
+	RenewCertificates(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NodeStatus, error)
+	// Rotate the enrollment key of the node through the ECA, and announce
+	// the new identity to the connected peers.
+	ReEnroll(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NodeStatus, error)
+	// Read the configuration file again and apply the settings which may
+	// change while the node runs.
+	ReloadConfig(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ConfigReloadReport, error)
+	// Make a standby node take over the identity and validating role of its
+	// primary, once the primary is down.
+	Takeover(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NodeStatus, error)
+	// Return the connections of the node with other peers and their traffic.
+	GetConnectionStats(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NetworkMap, error)
 }
 
 type adminClient struct {
@@ -111,6 +296,96 @@ func (c *adminClient) StopServer(ctx context.Context, in *google_protobuf1.Empty
 	return out, nil
 }
 
+func (c *adminClient) GetNodeStatus(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NodeStatus, error) {
+	out := new(NodeStatus)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetNodeStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetModuleLogLevel(ctx context.Context, in *LogLevelRequest, opts ...grpc.CallOption) (*LogLevelResponse, error) {
+	out := new(LogLevelResponse)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetModuleLogLevel", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetModuleLogLevel(ctx context.Context, in *LogLevelRequest, opts ...grpc.CallOption) (*LogLevelResponse, error) {
+	out := new(LogLevelResponse)
+	err := grpc.Invoke(ctx, "/protos.Admin/SetModuleLogLevel", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RevertLogLevels(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*LogLevelResponse, error) {
+	out := new(LogLevelResponse)
+	err := grpc.Invoke(ctx, "/protos.Admin/RevertLogLevels", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DrainServer(ctx context.Context, in *DrainRequest, opts ...grpc.CallOption) (*ServerStatus, error) {
+	out := new(ServerStatus)
+	err := grpc.Invoke(ctx, "/protos.Admin/DrainServer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RenewCertificates(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NodeStatus, error) {
+	out := new(NodeStatus)
+	err := grpc.Invoke(ctx, "/protos.Admin/RenewCertificates", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ReEnroll(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NodeStatus, error) {
+	out := new(NodeStatus)
+	err := grpc.Invoke(ctx, "/protos.Admin/ReEnroll", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ReloadConfig(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ConfigReloadReport, error) {
+	out := new(ConfigReloadReport)
+	err := grpc.Invoke(ctx, "/protos.Admin/ReloadConfig", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Takeover(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NodeStatus, error) {
+	out := new(NodeStatus)
+	err := grpc.Invoke(ctx, "/protos.Admin/Takeover", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetConnectionStats(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NetworkMap, error) {
+	out := new(NetworkMap)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetConnectionStats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Admin service
 
 type AdminServer interface {
@@ -118,6 +393,32 @@ type AdminServer interface {
 	GetStatus(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
 	StartServer(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
 	StopServer(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
+	// Return the status of the node and the health of its subsystems.
+	GetNodeStatus(context.Context, *google_protobuf1.Empty) (*NodeStatus, error)
+	// Get and set the log level of a logging module, the empty module
+	// stands for the default level.
+	GetModuleLogLevel(context.Context, *LogLevelRequest) (*LogLevelResponse, error)
+	SetModuleLogLevel(context.Context, *LogLevelRequest) (*LogLevelResponse, error)
+	// Restore the log levels of the logging specification of the
+	// configuration, undoing the levels set since, and return the default.
+	RevertLogLevels(context.Context, *google_protobuf1.Empty) (*LogLevelResponse, error)
+	// Stop accepting transactions, wait for the pending ones to be ordered,
+	// then stop the server.
+	DrainServer(context.Context, *DrainRequest) (*ServerStatus, error)
+	// Load the renewed enrollment and TLS certificates of the node, and
+	// announce the new identity to the connected peers.
+	RenewCertificates(context.Context, *google_protobuf1.Empty) (*NodeStatus, error)
+	// Rotate the enrollment key of the node through the ECA, and announce
+	// the new identity to the connected peers.
+	ReEnroll(context.Context, *google_protobuf1.Empty) (*NodeStatus, error)
+	// Read the configuration file again and apply the settings which may
+	// change while the node runs.
+	ReloadConfig(context.Context, *google_protobuf1.Empty) (*ConfigReloadReport, error)
+	// Make a standby node take over the identity and validating role of its
+	// primary, once the primary is down.
+	Takeover(context.Context, *google_protobuf1.Empty) (*NodeStatus, error)
+	// Return the connections of the node with other peers and their traffic.
+	GetConnectionStats(context.Context, *google_protobuf1.Empty) (*NetworkMap, error)
 }
 
 func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
@@ -160,6 +461,126 @@ func _Admin_StopServer_Handler(srv interface{}, ctx context.Context, dec func(in
 	return out, nil
 }
 
+func _Admin_GetNodeStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetNodeStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetModuleLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LogLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetModuleLogLevel(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_SetModuleLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LogLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).SetModuleLogLevel(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_RevertLogLevels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).RevertLogLevels(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_DrainServer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DrainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).DrainServer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_RenewCertificates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).RenewCertificates(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_ReEnroll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).ReEnroll(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_ReloadConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).ReloadConfig(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_Takeover_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).Takeover(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetConnectionStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetConnectionStats(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Admin_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Admin",
 	HandlerType: (*AdminServer)(nil),
@@ -176,6 +597,46 @@ var _Admin_serviceDesc = grpc.ServiceDesc{
 			MethodName: "StopServer",
 			Handler:    _Admin_StopServer_Handler,
 		},
+		{
+			MethodName: "GetNodeStatus",
+			Handler:    _Admin_GetNodeStatus_Handler,
+		},
+		{
+			MethodName: "GetModuleLogLevel",
+			Handler:    _Admin_GetModuleLogLevel_Handler,
+		},
+		{
+			MethodName: "SetModuleLogLevel",
+			Handler:    _Admin_SetModuleLogLevel_Handler,
+		},
+		{
+			MethodName: "RevertLogLevels",
+			Handler:    _Admin_RevertLogLevels_Handler,
+		},
+		{
+			MethodName: "DrainServer",
+			Handler:    _Admin_DrainServer_Handler,
+		},
+		{
+			MethodName: "RenewCertificates",
+			Handler:    _Admin_RenewCertificates_Handler,
+		},
+		{
+			MethodName: "ReEnroll",
+			Handler:    _Admin_ReEnroll_Handler,
+		},
+		{
+			MethodName: "ReloadConfig",
+			Handler:    _Admin_ReloadConfig_Handler,
+		},
+		{
+			MethodName: "Takeover",
+			Handler:    _Admin_Takeover_Handler,
+		},
+		{
+			MethodName: "GetConnectionStats",
+			Handler:    _Admin_GetConnectionStats_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
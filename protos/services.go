@@ -0,0 +1,29 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protos
+
+// The descriptions of the gRPC services of the peer, for registering them
+// with comm.RegisterService, which makes their calls pass through the
+// registered interceptors
+var (
+	OpenchainServiceDesc        = &_Openchain_serviceDesc
+	PeerServiceDesc             = &_Peer_serviceDesc
+	DevopsServiceDesc           = &_Devops_serviceDesc
+	AdminServiceDesc            = &_Admin_serviceDesc
+	EventsServiceDesc           = &_Events_serviceDesc
+	ChaincodeSupportServiceDesc = &_ChaincodeSupport_serviceDesc
+)
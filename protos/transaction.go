@@ -87,6 +87,7 @@ func NewChaincodeDeployTransaction(chaincodeDeploymentSpec *ChaincodeDeploymentS
 		return nil, fmt.Errorf("Could not marshal payload for chaincode deployment: %s", err)
 	}
 	transaction.Payload = data
+	transaction.Metadata = chaincodeDeploymentSpec.ChaincodeSpec.Metadata
 	return transaction, nil
 }
 
@@ -109,5 +110,34 @@ func NewChaincodeExecute(chaincodeInvocationSpec *ChaincodeInvocationSpec, uuid
 		return nil, fmt.Errorf("Could not marshal payload for chaincode invocation: %s", err)
 	}
 	transaction.Payload = data
+	transaction.Metadata = chaincodeInvocationSpec.ChaincodeSpec.Metadata
 	return transaction, nil
 }
+
+// NewTransactionMetadata encodes key/values as the metadata of a transaction
+func NewTransactionMetadata(entries map[string]string) ([]byte, error) {
+	data, err := proto.Marshal(&TransactionMetadata{Entries: entries})
+	if err != nil {
+		return nil, fmt.Errorf("Could not marshal transaction metadata: %s", err)
+	}
+	return data, nil
+}
+
+// DecodeTransactionMetadata decodes the key/values of the metadata of a
+// transaction, nil if it has no metadata
+func DecodeTransactionMetadata(metadata []byte) (map[string]string, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	decoded := &TransactionMetadata{}
+	if err := proto.Unmarshal(metadata, decoded); err != nil {
+		return nil, fmt.Errorf("Transaction metadata is not key/values: %s", err)
+	}
+	return decoded.Entries, nil
+}
+
+// GetMetadataEntries returns the key/values of the metadata of the
+// transaction, as given in the metadataEntries of its chaincode spec
+func (transaction *Transaction) GetMetadataEntries() (map[string]string, error) {
+	return DecodeTransactionMetadata(transaction.Metadata)
+}
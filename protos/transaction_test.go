@@ -46,3 +46,28 @@ func Test_Transaction_CreateNew(t *testing.T) {
 	}
 
 }
+
+func Test_Transaction_MetadataEntries(t *testing.T) {
+	entries := map[string]string{"order": "42", "channel": "web"}
+	metadata, err := NewTransactionMetadata(entries)
+	if err != nil {
+		t.Fatalf("Error encoding metadata: %s", err)
+	}
+	spec := &ChaincodeSpec{ChaincodeID: &ChaincodeID{Name: "mycc"}, Metadata: metadata}
+	tx, err := NewChaincodeExecute(&ChaincodeInvocationSpec{ChaincodeSpec: spec}, "uuid", Transaction_CHAINCODE_INVOKE)
+	if err != nil {
+		t.Fatalf("Error creating transaction: %s", err)
+	}
+
+	decoded, err := tx.GetMetadataEntries()
+	if err != nil {
+		t.Fatalf("Error decoding metadata: %s", err)
+	}
+	if len(decoded) != 2 || decoded["order"] != "42" || decoded["channel"] != "web" {
+		t.Fatalf("Expected %v, got %v", entries, decoded)
+	}
+
+	if decoded, err = (&Transaction{}).GetMetadataEntries(); err != nil || decoded != nil {
+		t.Fatalf("Expected no metadata entries, got %v, %v", decoded, err)
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric/consensus/pbft"
+	"github.com/spf13/viper"
+)
+
+func main() {
+	flagSetName := os.Args[0]
+	flagSet := flag.NewFlagSet(flagSetName, flag.ExitOnError)
+	recordingPtr := flagSet.String("recording", "", "path to the recording written by the replica")
+	configPtr := flagSet.String("config", "../../consensus/pbft/config.yaml", "path to the pbft config.yaml the replica ran with")
+	flagSet.Parse(os.Args[1:])
+
+	if *recordingPtr == "" {
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", flagSetName)
+		flagSet.PrintDefaults()
+		os.Exit(3)
+	}
+
+	config := viper.New()
+	config.SetConfigFile(*configPtr)
+	if err := config.ReadInConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading config %s: %s\n", *configPtr, err)
+		os.Exit(4)
+	}
+	// The replay must not overwrite the recording it reads
+	config.Set("general.record", "")
+
+	recording, err := os.Open(*recordingPtr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening recording: %s\n", err)
+		os.Exit(5)
+	}
+	defer recording.Close()
+
+	if err := pbft.Replay(recording, config, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(6)
+	}
+}